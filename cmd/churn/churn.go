@@ -0,0 +1,159 @@
+package churn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/spf13/cobra"
+)
+
+// InterventionType is the notification type sent to users at churn risk
+const InterventionType = "churn_intervention"
+
+// sendInterval spaces out notifications so the batch fires no faster than 10 per second
+const sendInterval = 100 * time.Millisecond
+
+var (
+	threshold float64
+	sendEmail bool
+	dryRun    bool
+)
+
+func init() {
+	ChurnReportCmd.Flags().Float64Var(&threshold, "threshold", 0.7, "Minimum churn risk to include a user")
+	ChurnReportCmd.Flags().BoolVar(&sendEmail, "send-email", false, "Send a retention notification to every listed user")
+	ChurnReportCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the notifications that would be sent without sending them")
+}
+
+var ChurnReportCmd = &cobra.Command{
+	Use:   "churn-report",
+	Short: "List users at churn risk and optionally send retention notifications",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
+		if err != nil {
+			log.Fatal("Failed to connect to PostgreSQL:", err)
+		}
+		defer postgresDB.Close()
+		mongoDB, err := mongodb.NewMongoConnection(cfg.MongoDB)
+		if err != nil {
+			log.Fatal("Failed to connect to MongoDB:", err)
+		}
+		defer mongoDB.Close()
+
+		analyticsRepo := repositories.NewAnalyticsRepository(postgresDB.DB, mongoDB.Database)
+		notificationRepo := repositories.NewNotificationRepository(postgresDB.DB, mongoDB.Database)
+		predictive := services.NewPredictiveAnalyticsService(nil, analyticsRepo, nil)
+		notifications := services.NewNotificationService(notificationRepo, []services.NotificationProvider{services.NewInAppNotificationProvider(notificationRepo)})
+
+		opts := Options{Threshold: threshold, SendEmail: sendEmail, DryRun: dryRun, SendInterval: sendInterval}
+		if err := Report(context.Background(), predictive, analyticsRepo, notifications, opts, os.Stdout); err != nil {
+			log.Fatal("Churn report failed:", err)
+		}
+	},
+}
+
+// RiskSource lists users whose predicted churn risk is at least threshold
+type RiskSource interface {
+	GetUsersAtChurnRisk(ctx context.Context, threshold float64) ([]models.UserBehaviorPrediction, error)
+}
+
+// ActivitySource looks up when a user last talked to a companion
+type ActivitySource interface {
+	GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error)
+}
+
+// Notifier sends a notification to a user, implemented by *services.NotificationService
+type Notifier interface {
+	Send(ctx context.Context, userID, notificationType string, payload map[string]any) error
+}
+
+// Options controls a churn report run
+type Options struct {
+	Threshold    float64
+	SendEmail    bool
+	DryRun       bool
+	SendInterval time.Duration
+}
+
+// Report prints the users at churn risk as a table and, if requested, sends each one a retention notification
+func Report(ctx context.Context, risks RiskSource, activity ActivitySource, notifier Notifier, opts Options, out io.Writer) error {
+	predictions, err := risks.GetUsersAtChurnRisk(ctx, opts.Threshold)
+	if err != nil {
+		return fmt.Errorf("failed to get users at churn risk: %w", err)
+	}
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "user_id\tchurn_risk\tfactors\tlast_active")
+	for _, prediction := range predictions {
+		fmt.Fprintf(table, "%s\t%.2f\t%s\t%s\n", prediction.UserID, prediction.ChurnRisk, strings.Join(prediction.ChurnFactors, ", "), lastActive(ctx, activity, prediction))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d users at churn risk >= %.2f\n", len(predictions), opts.Threshold)
+
+	if !opts.SendEmail || len(predictions) == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(opts.SendInterval)
+	defer ticker.Stop()
+
+	sent, failed := 0, 0
+	for i, prediction := range predictions {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+
+		if opts.DryRun {
+			fmt.Fprintf(out, "[dry-run] would send %s to %s\n", InterventionType, prediction.UserID)
+			continue
+		}
+
+		payload := map[string]any{
+			"companion_id":  prediction.CompanionID,
+			"churn_risk":    prediction.ChurnRisk,
+			"churn_factors": prediction.ChurnFactors,
+		}
+		if err := notifier.Send(ctx, prediction.UserID, InterventionType, payload); err != nil {
+			fmt.Fprintf(out, "Failed to notify %s: %v\n", prediction.UserID, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	if !opts.DryRun {
+		fmt.Fprintf(out, "Sent %d notifications, %d failed\n", sent, failed)
+	}
+	return nil
+}
+
+// lastActive formats the user's last activity with the companion, or "-" if unknown
+func lastActive(ctx context.Context, activity ActivitySource, prediction models.UserBehaviorPrediction) string {
+	progress, err := activity.GetUserProgress(ctx, prediction.UserID, prediction.CompanionID)
+	if err != nil || progress.LastActivityDate.IsZero() {
+		return "-"
+	}
+	return progress.LastActivityDate.Format(time.RFC3339)
+}
@@ -0,0 +1,118 @@
+package churn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRiskSource struct {
+	predictions []models.UserBehaviorPrediction
+	threshold   float64
+}
+
+func (f *fakeRiskSource) GetUsersAtChurnRisk(ctx context.Context, threshold float64) ([]models.UserBehaviorPrediction, error) {
+	f.threshold = threshold
+	return f.predictions, nil
+}
+
+type fakeActivitySource map[string]time.Time
+
+func (f fakeActivitySource) GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error) {
+	last, ok := f[userID]
+	if !ok {
+		return nil, errors.New("user progress not found")
+	}
+	return &models.UserProgress{UserID: userID, CompanionID: companionID, LastActivityDate: last}, nil
+}
+
+type sentNotification struct {
+	userID           string
+	notificationType string
+	payload          map[string]any
+	at               time.Time
+}
+
+type mockNotifier struct {
+	sent    []sentNotification
+	failFor string
+}
+
+func (m *mockNotifier) Send(ctx context.Context, userID, notificationType string, payload map[string]any) error {
+	if userID == m.failFor {
+		return errors.New("provider unavailable")
+	}
+	m.sent = append(m.sent, sentNotification{userID: userID, notificationType: notificationType, payload: payload, at: time.Now()})
+	return nil
+}
+
+func newTestRisks() *fakeRiskSource {
+	return &fakeRiskSource{predictions: []models.UserBehaviorPrediction{
+		{UserID: "user-1", CompanionID: "companion-1", ChurnRisk: 0.91, ChurnFactors: []string{"declining_sessions", "short_replies"}},
+		{UserID: "user-2", CompanionID: "companion-2", ChurnRisk: 0.78, ChurnFactors: []string{"inactive"}},
+		{UserID: "user-3", CompanionID: "companion-3", ChurnRisk: 0.75},
+	}}
+}
+
+func TestReport(t *testing.T) {
+	lastActive := time.Date(2026, 9, 30, 18, 0, 0, 0, time.UTC)
+	activity := fakeActivitySource{"user-1": lastActive}
+
+	t.Run("prints a table without sending by default", func(t *testing.T) {
+		risks := newTestRisks()
+		notifier := &mockNotifier{}
+		var out bytes.Buffer
+
+		err := Report(context.Background(), risks, activity, notifier, Options{Threshold: 0.75, SendInterval: time.Millisecond}, &out)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0.75, risks.threshold)
+		assert.Empty(t, notifier.sent)
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 5)
+		assert.Equal(t, []string{"user_id", "churn_risk", "factors", "last_active"}, strings.Fields(lines[0]))
+		assert.Contains(t, lines[1], "user-1")
+		assert.Contains(t, lines[1], "0.91")
+		assert.Contains(t, lines[1], "declining_sessions, short_replies")
+		assert.Contains(t, lines[1], "2026-09-30T18:00:00Z")
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(lines[2]), "-"), "unknown activity is shown as -")
+		assert.Equal(t, "3 users at churn risk >= 0.75", lines[4])
+	})
+
+	t.Run("sends interventions no faster than the interval", func(t *testing.T) {
+		notifier := &mockNotifier{failFor: "user-2"}
+		var out bytes.Buffer
+		interval := 20 * time.Millisecond
+
+		err := Report(context.Background(), newTestRisks(), activity, notifier, Options{Threshold: 0.7, SendEmail: true, SendInterval: interval}, &out)
+		require.NoError(t, err)
+
+		require.Len(t, notifier.sent, 2)
+		assert.Equal(t, "user-1", notifier.sent[0].userID)
+		assert.Equal(t, InterventionType, notifier.sent[0].notificationType)
+		assert.Equal(t, "companion-1", notifier.sent[0].payload["companion_id"])
+		assert.Equal(t, "user-3", notifier.sent[1].userID)
+		assert.GreaterOrEqual(t, notifier.sent[1].at.Sub(notifier.sent[0].at), 2*interval-5*time.Millisecond)
+		assert.Contains(t, out.String(), "Failed to notify user-2: provider unavailable")
+		assert.Contains(t, out.String(), "Sent 2 notifications, 1 failed")
+	})
+
+	t.Run("dry run prints actions without sending", func(t *testing.T) {
+		notifier := &mockNotifier{}
+		var out bytes.Buffer
+
+		err := Report(context.Background(), newTestRisks(), activity, notifier, Options{Threshold: 0.7, SendEmail: true, DryRun: true, SendInterval: time.Millisecond}, &out)
+		require.NoError(t, err)
+
+		assert.Empty(t, notifier.sent)
+		assert.Equal(t, 3, strings.Count(out.String(), "[dry-run] would send churn_intervention to"))
+		assert.NotContains(t, out.String(), "Sent ")
+	})
+}
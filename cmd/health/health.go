@@ -0,0 +1,192 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+var checkSharding bool
+
+func init() {
+	HealthCmd.Flags().BoolVar(&checkSharding, "check-sharding", false, "Also check that MongoDB collections have the indexes their intended shard keys need")
+}
+
+var HealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Check connectivity to PostgreSQL, MongoDB and the Grok API",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		os.Exit(Execute(context.Background(), cfg, checkSharding, os.Stdout))
+	},
+}
+
+// Check is a single dependency check
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a single dependency check
+type Result struct {
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all dependency checks
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+// Err returns a descriptive error listing the failed dependencies, or nil if all passed
+func (r *Report) Err() error {
+	if r.Healthy {
+		return nil
+	}
+	var failed []string
+	for _, check := range r.Checks {
+		if !check.Healthy {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+	}
+	return fmt.Errorf("dependency health check failed (%s)", strings.Join(failed, "; "))
+}
+
+// Pinger is implemented by *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// MongoPinger is implemented by *mongo.Client
+type MongoPinger interface {
+	Ping(ctx context.Context, rp *readpref.ReadPref) error
+}
+
+// PostgresCheck pings PostgreSQL
+func PostgresCheck(db Pinger) Check {
+	return Check{Name: "postgres", Run: db.PingContext}
+}
+
+// MongoCheck pings MongoDB
+func MongoCheck(client MongoPinger) Check {
+	return Check{Name: "mongodb", Run: func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}}
+}
+
+// GrokCheck sends a lightweight test message to the Grok API
+func GrokCheck(llm services.LLMClient) Check {
+	return Check{Name: "grok", Run: func(ctx context.Context) error {
+		_, err := llm.SendMiniMessage(ctx, []services.LLMMessage{{Role: "user", Content: "ping"}})
+		return err
+	}}
+}
+
+// ShardingCheck fails if any collection is not ready to be sharded on its intended shard key, listing the issues
+func ShardingCheck(readiness func(ctx context.Context) ([]mongodb.ShardingIssue, error)) Check {
+	return Check{Name: "mongodb_sharding", Run: func(ctx context.Context) error {
+		issues, err := readiness(ctx)
+		if err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			return nil
+		}
+		described := make([]string, len(issues))
+		for i, issue := range issues {
+			described[i] = issue.String()
+		}
+		return fmt.Errorf("%d sharding issues: %s", len(issues), strings.Join(described, "; "))
+	}}
+}
+
+// Run executes every check with a per-check timeout
+func Run(ctx context.Context, checks []Check) *Report {
+	report := &Report{Healthy: true}
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		start := time.Now()
+		err := check.Run(checkCtx)
+		cancel()
+
+		result := Result{Name: check.Name, Healthy: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.Healthy = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// RunAndReport executes the checks, writes the JSON report to out and returns the process exit code
+func RunAndReport(ctx context.Context, checks []Check, out io.Writer) int {
+	report := Run(ctx, checks)
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return 1
+	}
+
+	if !report.Healthy {
+		return 1
+	}
+	return 0
+}
+
+// Execute opens connections from cfg without failing early, so every dependency is reported. With checkSharding, the
+// MongoDB indexes are also checked for sharding readiness.
+func Execute(ctx context.Context, cfg *config.Config, checkSharding bool, out io.Writer) int {
+	var checks []Check
+
+	db, err := postgres.Open(cfg.Postgres)
+	if err != nil {
+		checks = append(checks, failedCheck("postgres", err))
+	} else {
+		defer db.Close()
+		checks = append(checks, PostgresCheck(db))
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoDB.URI))
+	if err != nil {
+		checks = append(checks, failedCheck("mongodb", err))
+	} else {
+		defer client.Disconnect(ctx)
+		checks = append(checks, MongoCheck(client))
+		if checkSharding {
+			db := client.Database(cfg.MongoDB.Database)
+			checks = append(checks, ShardingCheck(func(ctx context.Context) ([]mongodb.ShardingIssue, error) {
+				return mongodb.ShardingReadinessCheck(ctx, db)
+			}))
+		}
+	}
+
+	checks = append(checks, GrokCheck(services.NewGrokService(&cfg.Grok)))
+
+	return RunAndReport(ctx, checks, out)
+}
+
+func failedCheck(name string, err error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return err }}
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type fakePostgres struct{ err error }
+
+func (f fakePostgres) PingContext(ctx context.Context) error { return f.err }
+
+type fakeMongo struct{ err error }
+
+func (f fakeMongo) Ping(ctx context.Context, rp *readpref.ReadPref) error { return f.err }
+
+func newGrokServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"pong"}}]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunAndReportExitCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		postgresErr error
+		mongoErr    error
+		grokStatus  int
+		wantCode    int
+		wantFailed  []string
+	}{
+		{name: "all healthy", grokStatus: http.StatusOK, wantCode: 0},
+		{name: "postgres down", postgresErr: errors.New("connection refused"), grokStatus: http.StatusOK, wantCode: 1, wantFailed: []string{"postgres"}},
+		{name: "mongodb down", mongoErr: errors.New("server selection timeout"), grokStatus: http.StatusOK, wantCode: 1, wantFailed: []string{"mongodb"}},
+		{name: "grok down", grokStatus: http.StatusServiceUnavailable, wantCode: 1, wantFailed: []string{"grok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grok := services.NewGrokService(&config.GrokConfig{BaseURL: newGrokServer(t, tt.grokStatus).URL})
+			checks := []Check{
+				PostgresCheck(fakePostgres{err: tt.postgresErr}),
+				MongoCheck(fakeMongo{err: tt.mongoErr}),
+				GrokCheck(grok),
+			}
+
+			var out bytes.Buffer
+			code := RunAndReport(context.Background(), checks, &out)
+			assert.Equal(t, tt.wantCode, code)
+
+			var report Report
+			require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+			assert.Equal(t, tt.wantCode == 0, report.Healthy)
+			require.Len(t, report.Checks, 3)
+
+			var failed []string
+			for _, result := range report.Checks {
+				if !result.Healthy {
+					failed = append(failed, result.Name)
+					assert.NotEmpty(t, result.Error)
+				}
+			}
+			assert.Equal(t, tt.wantFailed, failed)
+
+			if tt.wantCode == 0 {
+				assert.NoError(t, report.Err())
+			} else {
+				assert.ErrorContains(t, report.Err(), tt.wantFailed[0])
+			}
+		})
+	}
+}
+
+func TestShardingCheck(t *testing.T) {
+	ready := ShardingCheck(func(context.Context) ([]mongodb.ShardingIssue, error) { return nil, nil })
+	assert.NoError(t, ready.Run(context.Background()))
+
+	notReady := ShardingCheck(func(context.Context) ([]mongodb.ShardingIssue, error) {
+		return []mongodb.ShardingIssue{
+			{Collection: "export_jobs", Problem: mongodb.ShardingMissingIndex, Detail: "no index on the shard key (user_id)"},
+			{Collection: "notifications", Problem: mongodb.ShardingMissingIndex, Detail: "no index on the shard key (user_id)"},
+		}, nil
+	})
+	err := notReady.Run(context.Background())
+	assert.ErrorContains(t, err, "2 sharding issues")
+	assert.ErrorContains(t, err, "export_jobs: no index on the shard key (user_id)")
+	assert.ErrorContains(t, err, "notifications")
+
+	failed := ShardingCheck(func(context.Context) ([]mongodb.ShardingIssue, error) { return nil, errors.New("not authorized") })
+	report := Run(context.Background(), []Check{failed})
+	assert.False(t, report.Healthy)
+	assert.Equal(t, "mongodb_sharding", report.Checks[0].Name)
+}
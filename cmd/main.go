@@ -6,7 +6,11 @@ import (
 
 	"github.com/spf13/cobra"
 
+	churn "github.com/sahmaragaev/lunaria-backend/cmd/churn"
+	health "github.com/sahmaragaev/lunaria-backend/cmd/health"
 	migrate "github.com/sahmaragaev/lunaria-backend/cmd/migrate"
+	rollup "github.com/sahmaragaev/lunaria-backend/cmd/rollup"
+	seed "github.com/sahmaragaev/lunaria-backend/cmd/seed"
 	server "github.com/sahmaragaev/lunaria-backend/cmd/server"
 )
 
@@ -18,6 +22,10 @@ var rootCmd = &cobra.Command{
 func main() {
 	rootCmd.AddCommand(server.ServerCmd)
 	rootCmd.AddCommand(migrate.MigrateCmd)
+	rootCmd.AddCommand(health.HealthCmd)
+	rootCmd.AddCommand(churn.ChurnReportCmd)
+	rootCmd.AddCommand(seed.SeedCmd)
+	rootCmd.AddCommand(rollup.BackfillStatisticsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
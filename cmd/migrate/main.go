@@ -1,7 +1,9 @@
 package migrate
 
 import (
+	"context"
 	"log"
+	"os"
 
 	"github.com/sahmaragaev/lunaria-backend/internal/config"
 	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
@@ -9,6 +11,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var dryRun bool
+var confirmRollback bool
+
+func init() {
+	MigrateCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print SQL statements without executing them")
+	rollbackCmd.Flags().BoolVar(&confirmRollback, "yes", false, "Confirm dropping every PostgreSQL table and its data")
+	MigrateCmd.AddCommand(rollbackCmd)
+}
+
 var MigrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Run database migrations",
@@ -17,14 +28,19 @@ var MigrateCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
+		exitOnInvalidConfig(cfg)
 		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
 		if err != nil {
 			log.Fatal("Failed to connect to PostgreSQL:", err)
 		}
 		defer postgresDB.Close()
-		if err := postgres.RunMigrations(postgresDB.DB); err != nil {
+		if err := postgres.WithDryRun(context.Background(), postgresDB.DB, dryRun, os.Stdout, postgres.RunMigrations); err != nil {
 			log.Fatal("Postgres migrations failed:", err)
 		}
+		if dryRun {
+			log.Println("Skipping MongoDB migrations in dry run mode.")
+			return
+		}
 		mongoDB, err := mongodb.NewMongoConnection(cfg.MongoDB)
 		if err != nil {
 			log.Fatal("Failed to connect to MongoDB:", err)
@@ -36,3 +52,38 @@ var MigrateCmd = &cobra.Command{
 		log.Println("Migrations completed successfully.")
 	},
 }
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back PostgreSQL migrations, dropping every table and its data",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !dryRun && !confirmRollback {
+			log.Fatal("Refusing to drop every PostgreSQL table without --yes; preview the statements with --dry-run")
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		exitOnInvalidConfig(cfg)
+		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
+		if err != nil {
+			log.Fatal("Failed to connect to PostgreSQL:", err)
+		}
+		defer postgresDB.Close()
+		if err := postgres.WithDryRun(context.Background(), postgresDB.DB, dryRun, os.Stdout, postgres.RollbackMigrations); err != nil {
+			log.Fatal("Postgres rollback failed:", err)
+		}
+	},
+}
+
+// exitOnInvalidConfig logs every configuration error and exits if there are any
+func exitOnInvalidConfig(cfg *config.Config) {
+	errs := cfg.Validate()
+	if len(errs) == 0 {
+		return
+	}
+	for _, err := range errs {
+		log.Printf("Invalid config: %v", err)
+	}
+	log.Fatalf("Refusing to run migrations with %d invalid config values", len(errs))
+}
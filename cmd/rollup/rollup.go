@@ -0,0 +1,56 @@
+package rollup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var from string
+
+func init() {
+	BackfillStatisticsCmd.Flags().StringVar(&from, "from", "", "First day to roll up, as YYYY-MM-DD (required)")
+	BackfillStatisticsCmd.MarkFlagRequired("from")
+}
+
+var BackfillStatisticsCmd = &cobra.Command{
+	Use:   "backfill-statistics",
+	Short: "Roll up daily user statistics for every day from --from through yesterday",
+	Long: "Roll up daily user statistics for every day from --from through yesterday. Run this before enabling the " +
+		services.FlagStatisticsRollupReads + " feature flag, so that statistics served from the rollup cover the full history.",
+	Run: func(cmd *cobra.Command, args []string) {
+		start, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			log.Fatal("Invalid --from date:", err)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
+		if err != nil {
+			log.Fatal("Failed to connect to PostgreSQL:", err)
+		}
+		defer postgresDB.Close()
+		mongoDB, err := mongodb.NewMongoConnection(cfg.MongoDB)
+		if err != nil {
+			log.Fatal("Failed to connect to MongoDB:", err)
+		}
+		defer mongoDB.Close()
+
+		job := services.NewStatisticsRollupJob(repositories.NewAnalyticsRepository(postgresDB.DB, mongoDB.Database))
+		now := time.Now().UTC()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if err := job.Backfill(context.Background(), start, today); err != nil {
+			log.Fatal("Statistics backfill failed:", err)
+		}
+		log.Printf("Rolled up daily statistics from %s through %s.", start.Format(time.DateOnly), today.AddDate(0, 0, -1).Format(time.DateOnly))
+	},
+}
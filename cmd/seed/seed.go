@@ -0,0 +1,383 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Password is the password of every seeded user
+const Password = "lunaria-dev"
+
+// passwordHash is the bcrypt hash of Password. It is fixed rather than generated so re-seeding writes identical rows.
+const passwordHash = "$2a$10$D7IxzhBTMyLayFTWpThJm.BcH0mZvJsAlL2W/NonMrTsgrg.MdBNG"
+
+// epoch is the time seeded data is dated from, fixed so re-seeding writes identical documents
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var opts Options
+
+func init() {
+	SeedCmd.Flags().IntVar(&opts.Users, "users", 10, "Number of users to create")
+	SeedCmd.Flags().IntVar(&opts.CompanionsPerUser, "companions-per-user", 2, "Number of companions, each with one conversation, per user")
+	SeedCmd.Flags().IntVar(&opts.MessagesPerConversation, "messages-per-conversation", 20, "Number of messages in each conversation")
+	SeedCmd.Flags().Int64Var(&opts.Seed, "seed-rand", 1, "Random seed; the same seed always produces the same data")
+}
+
+var SeedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Fill the databases with synthetic users and conversations for development",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatal("Failed to load config:", err)
+		}
+		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
+		if err != nil {
+			log.Fatal("Failed to connect to PostgreSQL:", err)
+		}
+		defer postgresDB.Close()
+		mongoDB, err := mongodb.NewMongoConnection(cfg.MongoDB)
+		if err != nil {
+			log.Fatal("Failed to connect to MongoDB:", err)
+		}
+		defer mongoDB.Close()
+
+		dataset, err := Seed(context.Background(), NewDatabaseStore(postgresDB.DB, mongoDB.Database), opts)
+		if err != nil {
+			log.Fatal("Seeding failed:", err)
+		}
+		log.Printf("Seeded %d users, %d companions, %d conversations and %d messages. Log in with any seeded email and the password %q.",
+			len(dataset.Users), len(dataset.Companions), len(dataset.Conversations), len(dataset.Messages), Password)
+	},
+}
+
+// Options controls how much data is seeded
+type Options struct {
+	Users                   int
+	CompanionsPerUser       int
+	MessagesPerConversation int
+	Seed                    int64
+}
+
+// Dataset is the synthetic data written by a seed run
+type Dataset struct {
+	Users         []models.User
+	Companions    []models.Companion
+	Profiles      []models.CompanionProfile
+	Conversations []models.Conversation
+	Messages      []models.Message
+	Progress      []models.UserProgress
+	Relationships []models.RelationshipAnalytics
+}
+
+// Store writes seeded records, replacing any record with the same ID so that seeding is idempotent
+type Store interface {
+	UpsertUser(ctx context.Context, user *models.User) error
+	UpsertCompanion(ctx context.Context, companion *models.Companion) error
+	UpsertDocument(ctx context.Context, collection string, id primitive.ObjectID, document any) error
+}
+
+// Seed generates the dataset for opts and writes it to store
+func Seed(ctx context.Context, store Store, opts Options) (*Dataset, error) {
+	dataset := Generate(opts)
+
+	for i := range dataset.Users {
+		if err := store.UpsertUser(ctx, &dataset.Users[i]); err != nil {
+			return nil, fmt.Errorf("failed to seed user: %w", err)
+		}
+	}
+	for i := range dataset.Companions {
+		if err := store.UpsertCompanion(ctx, &dataset.Companions[i]); err != nil {
+			return nil, fmt.Errorf("failed to seed companion: %w", err)
+		}
+	}
+
+	documents := []struct {
+		collection string
+		count      int
+		get        func(i int) (primitive.ObjectID, any)
+	}{
+		{"companion_profiles", len(dataset.Profiles), func(i int) (primitive.ObjectID, any) { return dataset.Profiles[i].ID, &dataset.Profiles[i] }},
+		{"conversations", len(dataset.Conversations), func(i int) (primitive.ObjectID, any) { return dataset.Conversations[i].ID, &dataset.Conversations[i] }},
+		{"messages", len(dataset.Messages), func(i int) (primitive.ObjectID, any) { return dataset.Messages[i].ID, &dataset.Messages[i] }},
+		{"user_progress", len(dataset.Progress), func(i int) (primitive.ObjectID, any) { return dataset.Progress[i].ID, &dataset.Progress[i] }},
+		{"relationship_analytics", len(dataset.Relationships), func(i int) (primitive.ObjectID, any) { return dataset.Relationships[i].ID, &dataset.Relationships[i] }},
+	}
+	for _, kind := range documents {
+		for i := 0; i < kind.count; i++ {
+			id, document := kind.get(i)
+			if err := store.UpsertDocument(ctx, kind.collection, id, document); err != nil {
+				return nil, fmt.Errorf("failed to seed %s: %w", kind.collection, err)
+			}
+		}
+	}
+
+	return dataset, nil
+}
+
+// Generate builds the synthetic dataset for opts. Everything, IDs included, is drawn from a math/rand source seeded
+// with opts.Seed, so the same options always produce the same dataset.
+func Generate(opts Options) *Dataset {
+	g := &generator{rng: rand.New(rand.NewSource(opts.Seed))}
+	dataset := &Dataset{}
+
+	for u := 0; u < opts.Users; u++ {
+		user := models.User{
+			ID:           g.uuid(),
+			Email:        fmt.Sprintf("seed-%d-user-%d@lunaria.dev", opts.Seed, u+1),
+			PasswordHash: passwordHash,
+			Name:         g.pick(firstNames),
+			Age:          ptr(18 + g.rng.Intn(40)),
+			Gender:       ptr(g.pick(genders)),
+			IsActive:     true,
+			IsTestUser:   true,
+			CreatedAt:    epoch,
+			UpdatedAt:    epoch,
+		}
+		dataset.Users = append(dataset.Users, user)
+
+		for c := 0; c < opts.CompanionsPerUser; c++ {
+			g.companion(dataset, user, opts.MessagesPerConversation)
+		}
+	}
+
+	return dataset
+}
+
+// generator draws every value of a dataset from one random source
+type generator struct {
+	rng *rand.Rand
+}
+
+// companion adds a companion for user with its profile, one conversation of messageCount messages and the
+// gamification and relationship records that go with it
+func (g *generator) companion(dataset *Dataset, user models.User, messageCount int) {
+	startedAt := epoch.Add(time.Duration(g.rng.Intn(90*24)) * time.Hour)
+	companion := models.Companion{
+		ID:        g.uuid(),
+		UserID:    user.ID,
+		Name:      g.pick(companionNames),
+		Gender:    g.pick(genders),
+		Age:       21 + g.rng.Intn(15),
+		IsActive:  true,
+		CreatedAt: startedAt,
+		UpdatedAt: startedAt,
+	}
+	dataset.Companions = append(dataset.Companions, companion)
+
+	userID, companionID := user.ID.String(), companion.ID.String()
+	dataset.Profiles = append(dataset.Profiles, models.CompanionProfile{
+		ID:          g.objectID(),
+		CompanionID: companionID,
+		UserID:      userID,
+		Personality: models.PersonalityTraits{
+			Warmth: g.unit(), Playfulness: g.unit(), Intelligence: g.unit(), Empathy: g.unit(),
+			Confidence: g.unit(), Romance: g.unit(), Humor: g.unit(), Clinginess: g.unit(),
+		},
+		Backstory:          g.sentence(20),
+		Interests:          g.sample(interests, 3),
+		Quirks:             []string{g.sentence(5)},
+		CommunicationStyle: models.CommunicationStyle{Formality: g.unit(), Emotionality: g.unit(), Playfulness: g.unit()},
+		CreatedAt:          startedAt,
+		UpdatedAt:          startedAt,
+	})
+
+	conversation := models.Conversation{
+		ID:           g.objectID(),
+		UserID:       userID,
+		CompanionID:  companionID,
+		Relationship: services.InitialRelationshipStage,
+		CreatedAt:    startedAt,
+	}
+	sentAt := startedAt
+	for i := 0; i < messageCount; i++ {
+		sender, senderID := sendertype.User, userID
+		if i%2 == 1 {
+			sender, senderID = sendertype.Companion, companionID
+		}
+		sentAt = sentAt.Add(time.Duration(1+g.rng.Intn(10)) * time.Minute)
+		text := g.sentence(4 + g.rng.Intn(16))
+		dataset.Messages = append(dataset.Messages, models.Message{
+			ID:             g.objectID(),
+			ConversationID: conversation.ID,
+			SenderID:       senderID,
+			SenderType:     sender,
+			Type:           messagetype.Text,
+			Text:           &text,
+			Read:           true,
+			TotalMessages:  1,
+			CreatedAt:      sentAt,
+			UpdatedAt:      sentAt,
+		})
+	}
+	conversation.LastActivity = sentAt
+	conversation.UpdatedAt = sentAt
+	dataset.Conversations = append(dataset.Conversations, conversation)
+
+	experience := messageCount*10 + g.rng.Intn(500)
+	level := int(math.Sqrt(float64(experience)/100.0)) + 1
+	streak := g.rng.Intn(14)
+	intimacy := g.unit()
+	stage := stageFor(intimacy)
+	dataset.Progress = append(dataset.Progress, models.UserProgress{
+		ID:                   g.objectID(),
+		UserID:               userID,
+		CompanionID:          companionID,
+		TotalExperience:      experience,
+		CurrentLevel:         level,
+		LevelProgress:        float64(experience-(level-1)*(level-1)*100) / float64(level*level*100-(level-1)*(level-1)*100),
+		ExperienceToNext:     level*level*100 - experience,
+		RelationshipStage:    stage,
+		StageProgress:        g.unit(),
+		CurrentStreak:        streak,
+		LongestStreak:        streak + g.rng.Intn(10),
+		StreakType:           "daily",
+		LastActivityDate:     sentAt,
+		TotalConversations:   1,
+		TotalMessages:        messageCount,
+		TotalTimeSpent:       sentAt.Sub(startedAt),
+		AverageSessionLength: sentAt.Sub(startedAt),
+		CreatedAt:            startedAt,
+		UpdatedAt:            sentAt,
+	})
+	dataset.Relationships = append(dataset.Relationships, models.RelationshipAnalytics{
+		ID:                  g.objectID(),
+		UserID:              userID,
+		CompanionID:         companionID,
+		CurrentStage:        stage,
+		StageDuration:       sentAt.Sub(startedAt),
+		ProgressionVelocity: g.unit(),
+		IntimacyLevel:       intimacy,
+		IntimacyGrowth:      g.unit() / 10,
+		TrustLevel:          g.unit(),
+		SafetyScore:         0.8 + g.unit()/5,
+		CommunicationStyle:  g.pick(communicationStyles),
+		ConflictResolution:  g.unit(),
+		HealthScore:         0.5 + g.unit()/2,
+		CreatedAt:           startedAt,
+		UpdatedAt:           sentAt,
+	})
+}
+
+// stageFor returns the highest relationship stage whose default threshold intimacy reaches
+func stageFor(intimacy float64) string {
+	stage, best := services.InitialRelationshipStage, 0.0
+	for name, threshold := range services.DefaultStageThresholds {
+		if intimacy >= threshold && threshold > best {
+			stage, best = name, threshold
+		}
+	}
+	return stage
+}
+
+func (g *generator) uuid() uuid.UUID {
+	return uuid.Must(uuid.NewRandomFromReader(g.rng))
+}
+
+func (g *generator) objectID() primitive.ObjectID {
+	var id primitive.ObjectID
+	g.rng.Read(id[:])
+	return id
+}
+
+// unit returns a value in [0, 1) rounded to two decimals
+func (g *generator) unit() float64 {
+	return math.Round(g.rng.Float64()*100) / 100
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+// sample returns n distinct values in random order
+func (g *generator) sample(values []string, n int) []string {
+	picked := make([]string, 0, n)
+	for _, i := range g.rng.Perm(len(values))[:n] {
+		picked = append(picked, values[i])
+	}
+	return picked
+}
+
+// sentence returns n lorem ipsum words as a capitalised sentence
+func (g *generator) sentence(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = g.pick(lorem)
+	}
+	return strings.ToUpper(words[0][:1]) + strings.Join(words, " ")[1:] + "."
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+var (
+	firstNames          = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	companionNames      = []string{"Luna", "Nova", "Aria", "Kai", "Orion", "Iris", "Ezra", "Sage", "Milo", "Wren"}
+	genders             = []string{"female", "male", "non_binary"}
+	interests           = []string{"music", "astronomy", "cooking", "hiking", "books", "films", "travel", "art", "games", "poetry"}
+	communicationStyles = []string{"supportive", "playful", "thoughtful", "direct"}
+	lorem               = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris nisi aliquip ex ea commodo consequat")
+)
+
+// DatabaseStore writes seeded users and companions to Postgres and everything else to MongoDB
+type DatabaseStore struct {
+	postgres *sql.DB
+	mongo    *mongo.Database
+}
+
+// NewDatabaseStore creates a store over the application databases
+func NewDatabaseStore(postgresDB *sql.DB, mongoDB *mongo.Database) *DatabaseStore {
+	return &DatabaseStore{postgres: postgresDB, mongo: mongoDB}
+}
+
+func (s *DatabaseStore) UpsertUser(ctx context.Context, user *models.User) error {
+	_, err := s.postgres.ExecContext(ctx, `
+		INSERT INTO users (id, email, password_hash, name, age, gender, avatar_url, is_active, is_test_user, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET
+			email = EXCLUDED.email, password_hash = EXCLUDED.password_hash, name = EXCLUDED.name, age = EXCLUDED.age,
+			gender = EXCLUDED.gender, avatar_url = EXCLUDED.avatar_url, is_active = EXCLUDED.is_active,
+			is_test_user = EXCLUDED.is_test_user, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		user.ID, user.Email, user.PasswordHash, user.Name, user.Age, user.Gender, user.AvatarURL, user.IsActive,
+		user.IsTestUser, user.CreatedAt, user.UpdatedAt)
+	return err
+}
+
+func (s *DatabaseStore) UpsertCompanion(ctx context.Context, companion *models.Companion) error {
+	_, err := s.postgres.ExecContext(ctx, `
+		INSERT INTO companions (id, user_id, name, gender, age, avatar_url, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id, name = EXCLUDED.name, gender = EXCLUDED.gender, age = EXCLUDED.age,
+			avatar_url = EXCLUDED.avatar_url, is_active = EXCLUDED.is_active,
+			created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at`,
+		companion.ID, companion.UserID, companion.Name, companion.Gender, companion.Age, companion.AvatarURL,
+		companion.IsActive, companion.CreatedAt, companion.UpdatedAt)
+	return err
+}
+
+func (s *DatabaseStore) UpsertDocument(ctx context.Context, collection string, id primitive.ObjectID, document any) error {
+	_, err := s.mongo.Collection(collection).ReplaceOne(ctx, bson.M{"_id": id}, document, options.Replace().SetUpsert(true))
+	return err
+}
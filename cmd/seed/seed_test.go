@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore keeps seeded records keyed by ID, replacing on conflict like the database store
+type memoryStore struct {
+	users      map[uuid.UUID]models.User
+	companions map[uuid.UUID]models.Companion
+	documents  map[string]map[primitive.ObjectID]any
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:      make(map[uuid.UUID]models.User),
+		companions: make(map[uuid.UUID]models.Companion),
+		documents:  make(map[string]map[primitive.ObjectID]any),
+	}
+}
+
+func (s *memoryStore) UpsertUser(ctx context.Context, user *models.User) error {
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *memoryStore) UpsertCompanion(ctx context.Context, companion *models.Companion) error {
+	s.companions[companion.ID] = *companion
+	return nil
+}
+
+func (s *memoryStore) UpsertDocument(ctx context.Context, collection string, id primitive.ObjectID, document any) error {
+	if s.documents[collection] == nil {
+		s.documents[collection] = make(map[primitive.ObjectID]any)
+	}
+	s.documents[collection][id] = document
+	return nil
+}
+
+func (s *memoryStore) counts() map[string]int {
+	counts := map[string]int{"users": len(s.users), "companions": len(s.companions)}
+	for collection, documents := range s.documents {
+		counts[collection] = len(documents)
+	}
+	return counts
+}
+
+func TestSeedIsIdempotent(t *testing.T) {
+	opts := Options{Users: 3, CompanionsPerUser: 2, MessagesPerConversation: 5, Seed: 42}
+	store := newMemoryStore()
+
+	first, err := Seed(context.Background(), store, opts)
+	require.NoError(t, err)
+	want := map[string]int{
+		"users":                  3,
+		"companions":             6,
+		"companion_profiles":     6,
+		"conversations":          6,
+		"messages":               30,
+		"user_progress":          6,
+		"relationship_analytics": 6,
+	}
+	assert.Equal(t, want, store.counts())
+
+	second, err := Seed(context.Background(), store, opts)
+	require.NoError(t, err)
+	assert.Equal(t, want, store.counts(), "re-seeding replaces documents instead of adding more")
+	assert.Equal(t, first, second, "the same seed produces identical documents")
+
+	other := Generate(Options{Users: 3, CompanionsPerUser: 2, MessagesPerConversation: 5, Seed: 7})
+	assert.NotEqual(t, first.Users[0].ID, other.Users[0].ID)
+}
+
+func TestGenerateAlternatesSenders(t *testing.T) {
+	dataset := Generate(Options{Users: 1, CompanionsPerUser: 1, MessagesPerConversation: 4, Seed: 1})
+	require.Len(t, dataset.Messages, 4)
+
+	userID, companionID := dataset.Users[0].ID.String(), dataset.Companions[0].ID.String()
+	for i, msg := range dataset.Messages {
+		if i%2 == 0 {
+			assert.Equal(t, sendertype.User, msg.SenderType)
+			assert.Equal(t, userID, msg.SenderID)
+		} else {
+			assert.Equal(t, sendertype.Companion, msg.SenderType)
+			assert.Equal(t, companionID, msg.SenderID)
+		}
+		assert.Equal(t, dataset.Conversations[0].ID, msg.ConversationID)
+		require.NotNil(t, msg.Text)
+		assert.NotEmpty(t, *msg.Text)
+	}
+	assert.Equal(t, dataset.Messages[3].CreatedAt, dataset.Conversations[0].LastActivity)
+	assert.Equal(t, 4, dataset.Progress[0].TotalMessages)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// newClusterRegistry registers this instance for membership gossip, advertising its gRPC and health endpoints
+func newClusterRegistry(cfg *config.Config) (*cluster.Registry, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine hostname: %w", err)
+	}
+	nodeID := cfg.Cluster.NodeID
+	if nodeID == "" {
+		nodeID = hostname
+	}
+	host := cfg.Cluster.AdvertiseHost
+	if host == "" {
+		host = hostname
+	}
+
+	return cluster.NewRegistry(cluster.Config{
+		Self: cluster.NodeInfo{
+			NodeID:     nodeID,
+			Address:    net.JoinHostPort(host, cfg.Server.GRPCPort),
+			HealthURL:  fmt.Sprintf("http://%s/health", net.JoinHostPort(host, cfg.Server.Port)),
+			GossipAddr: net.JoinHostPort(host, cfg.Cluster.GossipPort),
+		},
+		Secret:   []byte(cfg.Cluster.Secret),
+		BindAddr: ":" + cfg.Cluster.GossipPort,
+		Seeds:    cfg.Cluster.Seeds,
+	})
+}
+
+// newClusterForwarder dials peers with the server's own certificate when mutual TLS is enabled, since peers then
+// require a client certificate signed by the same CA
+func newClusterForwarder(tlsConfig *tls.Config) *cluster.Forwarder {
+	if tlsConfig == nil {
+		return cluster.NewForwarder()
+	}
+	return cluster.NewForwarder(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: tlsConfig.Certificates,
+		RootCAs:      tlsConfig.ClientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})))
+}
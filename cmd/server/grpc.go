@@ -0,0 +1,37 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analyticspb"
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
+	"github.com/sahmaragaev/lunaria-backend/internal/handlers"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// newGRPCServer builds the gRPC server for analytics ingestion and peer forwarding, using mutual TLS when tlsConfig is set
+func newGRPCServer(analyticsRepo *repositories.AnalyticsRepository, tlsConfig *tls.Config) *grpc.Server {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	analyticspb.RegisterAnalyticsIngestionServer(server, handlers.NewGRPCAnalyticsServer(analyticsRepo))
+	clusterpb.RegisterPeerForwardingServer(server, handlers.NewGRPCPeerForwardingServer(services.GetTypingTracker()))
+	return server
+}
+
+// serveGRPC listens on host and port and serves gRPC requests until the server stops
+func serveGRPC(server *grpc.Server, host, port string) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port: %w", err)
+	}
+	return server.Serve(listener)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analyticspb"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newBufconnClient(t *testing.T, server *grpc.Server) analyticspb.AnalyticsIngestionClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return analyticspb.NewAnalyticsIngestionClient(conn)
+}
+
+func TestGRPCAnalyticsIngestion(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stores streamed events", func(mt *mtest.T) {
+		const events = 100
+		for i := 0; i < events; i++ {
+			collection := "lunaria.user_engagement_analytics"
+			if i%2 == 1 {
+				collection = "lunaria.relationship_analytics"
+			}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, collection, mtest.FirstBatch))
+			if i%2 == 1 {
+				mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch))
+			}
+			mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 0}})
+		}
+
+		client := newBufconnClient(t, newGRPCServer(repositories.NewAnalyticsRepository(nil, mt.DB), nil))
+		conversationID := primitive.NewObjectID()
+
+		for i := 0; i < events; i++ {
+			var err error
+			if i%2 == 0 {
+				_, err = client.TrackEngagement(context.Background(), &analyticspb.EngagementEvent{
+					UserId:                 "user-1",
+					CompanionId:            "companion-1",
+					ConversationId:         conversationID.Hex(),
+					SessionDurationSeconds: 90,
+					MessagesPerSession:     int32(i),
+					EngagementScore:        0.8,
+				})
+			} else {
+				_, err = client.TrackRelationshipUpdate(context.Background(), &analyticspb.RelationshipUpdateEvent{
+					UserId:       "user-1",
+					CompanionId:  "companion-1",
+					CurrentStage: "friendship",
+					TrustLevel:   float64(i) / events,
+				})
+			}
+			require.NoError(t, err, "event %d", i)
+		}
+
+		writes := map[string]int{}
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName != "update" {
+				continue
+			}
+			writes[event.Command.Lookup("update").StringValue()]++
+		}
+		assert.Equal(t, events/2, writes["user_engagement_analytics"])
+		assert.Equal(t, events/2, writes["relationship_analytics"])
+	})
+
+	mt.Run("rejects invalid events", func(mt *mtest.T) {
+		client := newBufconnClient(t, newGRPCServer(repositories.NewAnalyticsRepository(nil, mt.DB), nil))
+
+		_, err := client.TrackEngagement(context.Background(), &analyticspb.EngagementEvent{
+			UserId:         "user-1",
+			CompanionId:    "companion-1",
+			ConversationId: "not-an-object-id",
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.TrackMessage(context.Background(), &analyticspb.MessageEvent{SenderId: "nope"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+}
@@ -1,25 +1,54 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 
 	jsoniter "github.com/json-iterator/go"
 
+	"github.com/sahmaragaev/lunaria-backend/cmd/health"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
 	"github.com/sahmaragaev/lunaria-backend/internal/config"
 	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
 	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"github.com/sahmaragaev/lunaria-backend/internal/router"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+var healthCheckOnly bool
+var mutualTLS bool
+
+func init() {
+	ServerCmd.Flags().BoolVar(&healthCheckOnly, "health-check", false, "Check dependencies and exit instead of starting the server")
+	ServerCmd.Flags().BoolVar(&mutualTLS, "mtls", false, "Serve HTTPS and require client certificates signed by the configured CA")
+}
+
 var ServerCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start the Lunaria backend server",
 	Run: func(cmd *cobra.Command, args []string) {
+		if healthCheckOnly {
+			cfg, err := config.Load()
+			if err != nil {
+				log.Fatal("Failed to load config:", err)
+			}
+			exitOnInvalidConfig(cfg)
+			os.Exit(health.Execute(context.Background(), cfg, false, os.Stdout))
+		}
+
 		go func() {
 			log.Println(http.ListenAndServe(":6060", nil))
 		}()
@@ -28,6 +57,15 @@ var ServerCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
+		if mutualTLS {
+			cfg.TLS.MutualTLS = true
+		}
+		exitOnInvalidConfig(cfg)
+
+		tracerProvider := sdktrace.NewTracerProvider()
+		defer tracerProvider.Shutdown(context.Background())
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
 		postgresDB, err := postgres.NewPostgresConnection(cfg.Postgres)
 		if err != nil {
@@ -41,10 +79,92 @@ var ServerCmd = &cobra.Command{
 		}
 		defer mongoDB.Close()
 
-		router := router.SetupRouter(cfg, postgresDB, mongoDB)
+		report := health.Run(context.Background(), []health.Check{
+			health.PostgresCheck(postgresDB.DB),
+			health.MongoCheck(mongoDB.Client),
+			health.GrokCheck(services.NewGrokService(&cfg.Grok)),
+		})
+		if err := report.Err(); err != nil {
+			log.Fatal("Refusing to start: ", err)
+		}
+
+		var tlsConfig *tls.Config
+		if cfg.TLS.MutualTLS {
+			tlsConfig, err = mutualTLSConfig(cfg.TLS)
+			if err != nil {
+				log.Fatal("Failed to configure mutual TLS:", err)
+			}
+		}
+
+		// Peers forward to each other over gRPC, so the forwarder presents the gRPC certificates rather than the HTTP ones
+		var grpcTLS *tls.Config
+		if cfg.Server.GRPCPort != "" {
+			grpcTLS, err = grpcTLSConfig(cfg.TLS)
+			if err != nil {
+				log.Fatal("Failed to configure gRPC TLS:", err)
+			}
+		}
+
+		var clusterRegistry *cluster.Registry
+		if cfg.Cluster.Enabled {
+			clusterRegistry, err = newClusterRegistry(cfg)
+			if err != nil {
+				log.Fatal("Failed to join cluster:", err)
+			}
+			go clusterRegistry.Start(context.Background())
+
+			forwarder := newClusterForwarder(grpcTLS)
+			defer forwarder.Close()
+			clusterRegistry.OnPeerRemoved(func(peer cluster.NodeInfo) { forwarder.Drop(peer.Address) })
+			services.GetTypingTracker().ShareWithPeers(clusterRegistry, forwarder)
+			log.Printf("Gossiping cluster membership as %s on port %s", clusterRegistry.Self().NodeID, cfg.Cluster.GossipPort)
+		}
+
+		// The router registers its cache invalidation hooks on this repository, so gRPC ingestion must write through it too
+		analyticsRepo := repositories.NewAnalyticsRepository(postgresDB.DB, mongoDB.Database)
+		if cfg.Server.GRPCPort != "" {
+			grpcServer := newGRPCServer(analyticsRepo, grpcTLS)
+			defer grpcServer.GracefulStop()
+			go func() {
+				log.Printf("Starting analytics ingestion gRPC server on %s", net.JoinHostPort(cfg.Server.GRPCHost, cfg.Server.GRPCPort))
+				if err := serveGRPC(grpcServer, cfg.Server.GRPCHost, cfg.Server.GRPCPort); err != nil {
+					log.Println("gRPC server stopped:", err)
+				}
+			}()
+		}
+
+		cacheWatcher := cache.NewChangeStreamWatcher(mongoDB.Database, nil)
+		router := router.SetupRouter(cfg, postgresDB, mongoDB, analyticsRepo, cacheWatcher, clusterRegistry)
+		go cacheWatcher.Start(context.Background())
+		if tlsConfig != nil {
+			srv := &http.Server{
+				Addr:      ":" + cfg.Server.Port,
+				Handler:   router,
+				TLSConfig: tlsConfig,
+			}
+			log.Printf("Starting Lunaria backend with mutual TLS on port %s", cfg.Server.Port)
+			// Certificates are already loaded into TLSConfig
+			if err := srv.ListenAndServeTLS("", ""); err != nil {
+				log.Fatal("Failed to start server:", err)
+			}
+			return
+		}
+
 		log.Printf("Starting Lunaria backend on port %s", cfg.Server.Port)
 		if err := router.Run(":" + cfg.Server.Port); err != nil {
 			log.Fatal("Failed to start server:", err)
 		}
 	},
 }
+
+// exitOnInvalidConfig logs every configuration error and exits if there are any
+func exitOnInvalidConfig(cfg *config.Config) {
+	errs := cfg.Validate()
+	if len(errs) == 0 {
+		return
+	}
+	for _, err := range errs {
+		log.Printf("Invalid config: %v", err)
+	}
+	log.Fatalf("Refusing to start with %d invalid config values", len(errs))
+}
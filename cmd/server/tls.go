@@ -0,0 +1,43 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+)
+
+// mutualTLSConfig builds a server TLS config that only accepts clients presenting a certificate signed by the configured CA
+func mutualTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(cfg.CACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no valid certificates found in %s", cfg.CACertFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// grpcTLSConfig builds the gRPC server's mutual TLS config from its own certificates, independent of the HTTP listener
+func grpcTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	return mutualTLSConfig(config.TLSConfig{
+		CertFile:   cfg.GRPCCertFile,
+		KeyFile:    cfg.GRPCKeyFile,
+		CACertFile: cfg.GRPCCACertFile,
+	})
+}
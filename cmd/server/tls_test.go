@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCertificate{cert: cert, key: key, der: der}
+}
+
+func newTestCA(t *testing.T, name string) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func (c *testCertificate) writePEM(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "lunaria-test-ca")
+	serverCert := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "lunaria-backend"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	clientCert := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "admin-service"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	rogueClientCert := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "rogue-service"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, newTestCA(t, "rogue-ca"))
+
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	certFile, keyFile := serverCert.writePEM(t, dir, "server")
+
+	tlsConfig, err := mutualTLSConfig(config.TLSConfig{
+		MutualTLS:  true,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CACertFile: caFile,
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	newClient := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: certificates,
+		}}}
+	}
+
+	t.Run("accepts client signed by CA", func(t *testing.T) {
+		resp, err := newClient(clientCert.tlsCertificate()).Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("rejects client without certificate", func(t *testing.T) {
+		_, err := newClient().Get(server.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects client signed by unknown CA", func(t *testing.T) {
+		_, err := newClient(rogueClientCert.tlsCertificate()).Get(server.URL)
+		assert.Error(t, err)
+	})
+}
+
+func TestMutualTLSConfigRequiresCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "lunaria-test-ca")
+	certFile, keyFile := ca.writePEM(t, dir, "server")
+	emptyCA := filepath.Join(dir, "empty.crt")
+	require.NoError(t, os.WriteFile(emptyCA, []byte("not a certificate"), 0600))
+
+	_, err := mutualTLSConfig(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, CACertFile: emptyCA})
+	assert.ErrorContains(t, err, "no valid certificates")
+}
+
+func TestGRPCTLSConfigUsesGRPCCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "lunaria-grpc-ca")
+	caFile, _ := ca.writePEM(t, dir, "grpc-ca")
+	certFile, keyFile := newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "grpc"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca).writePEM(t, dir, "grpc")
+
+	tlsConfig, err := grpcTLSConfig(config.TLSConfig{
+		CertFile:       filepath.Join(dir, "missing.crt"),
+		KeyFile:        filepath.Join(dir, "missing.key"),
+		CACertFile:     filepath.Join(dir, "missing-ca.crt"),
+		GRPCCertFile:   certFile,
+		GRPCKeyFile:    keyFile,
+		GRPCCACertFile: caFile,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	require.Len(t, tlsConfig.Certificates, 1)
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// Anomaly directions
+const (
+	DirectionSpike = "spike"
+	DirectionDrop  = "drop"
+)
+
+// anomalyWindow is the number of preceding points used for the rolling mean and standard deviation
+const anomalyWindow = 7
+
+// minAnomalyHistory is the fewest preceding points needed before a point can be flagged
+const minAnomalyHistory = 3
+
+// minStdDev keeps z-scores finite and ignores noise when recent engagement has been nearly flat
+const minStdDev = 0.05
+
+// DetectEngagementAnomalies flags points whose engagement score is more than zScoreThreshold
+// standard deviations away from the mean of the preceding seven points
+func DetectEngagementAnomalies(points []models.EngagementTrendPoint, zScoreThreshold float64) []models.EngagementAnomaly {
+	anomalies := []models.EngagementAnomaly{}
+
+	for i := minAnomalyHistory; i < len(points); i++ {
+		window := points[max(0, i-anomalyWindow):i]
+		mean, stdDev := meanAndStdDev(window)
+
+		zScore := (points[i].EngagementScore - mean) / math.Max(stdDev, minStdDev)
+		if math.Abs(zScore) <= zScoreThreshold {
+			continue
+		}
+
+		direction := DirectionSpike
+		if zScore < 0 {
+			direction = DirectionDrop
+		}
+
+		anomalies = append(anomalies, models.EngagementAnomaly{
+			Date:      points[i].Date,
+			Score:     points[i].EngagementScore,
+			ZScore:    zScore,
+			Direction: direction,
+		})
+	}
+
+	return anomalies
+}
+
+// meanAndStdDev computes the mean and population standard deviation of engagement scores
+func meanAndStdDev(points []models.EngagementTrendPoint) (float64, float64) {
+	var sum float64
+	for _, point := range points {
+		sum += point.EngagementScore
+	}
+	mean := sum / float64(len(points))
+
+	var variance float64
+	for _, point := range points {
+		variance += (point.EngagementScore - mean) * (point.EngagementScore - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(points)))
+}
@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func series(scores ...float64) []models.EngagementTrendPoint {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]models.EngagementTrendPoint, len(scores))
+	for i, score := range scores {
+		points[i] = models.EngagementTrendPoint{Date: start.AddDate(0, 0, i), EngagementScore: score}
+	}
+	return points
+}
+
+func TestDetectEngagementAnomalies(t *testing.T) {
+	tests := []struct {
+		name       string
+		points     []models.EngagementTrendPoint
+		threshold  float64
+		wantDays   []int
+		wantDirect []string
+	}{
+		{
+			name:      "empty series",
+			points:    nil,
+			threshold: 2,
+		},
+		{
+			name:      "too little history",
+			points:    series(0.8, 0.1),
+			threshold: 2,
+		},
+		{
+			name:      "stable engagement",
+			points:    series(0.60, 0.62, 0.61, 0.59, 0.60, 0.63, 0.61, 0.60, 0.62),
+			threshold: 2,
+		},
+		{
+			name:       "forty percent drop",
+			points:     series(0.70, 0.72, 0.69, 0.71, 0.70, 0.72, 0.71, 0.42),
+			threshold:  2,
+			wantDays:   []int{7},
+			wantDirect: []string{DirectionDrop},
+		},
+		{
+			name:       "spike after flat week",
+			points:     series(0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.9),
+			threshold:  3,
+			wantDays:   []int{7},
+			wantDirect: []string{DirectionSpike},
+		},
+		{
+			name:       "only the last seven points count",
+			points:     series(0.1, 0.9, 0.1, 0.9, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.8),
+			threshold:  2,
+			wantDays:   []int{11},
+			wantDirect: []string{DirectionSpike},
+		},
+		{
+			name:      "high threshold ignores moderate change",
+			points:    series(0.70, 0.72, 0.69, 0.71, 0.70, 0.72, 0.71, 0.65),
+			threshold: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomalies := DetectEngagementAnomalies(tt.points, tt.threshold)
+
+			require.Len(t, anomalies, len(tt.wantDays))
+			for i, anomaly := range anomalies {
+				point := tt.points[tt.wantDays[i]]
+				assert.Equal(t, point.Date, anomaly.Date)
+				assert.Equal(t, point.EngagementScore, anomaly.Score)
+				assert.Equal(t, tt.wantDirect[i], anomaly.Direction)
+				assert.Greater(t, abs(anomaly.ZScore), tt.threshold)
+			}
+		})
+	}
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
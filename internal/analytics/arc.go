@@ -0,0 +1,97 @@
+package analytics
+
+import (
+	"math"
+	"slices"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// Arc classification thresholds. Sentiment scores run from 0 (negative) to 1 (positive), and the slope is the change
+// in score per message.
+const (
+	arcTrendSlope    = 0.02
+	arcHighVariance  = 0.04 // a standard deviation of 0.2
+	arcLowVariance   = 0.01 // a standard deviation of 0.1
+	arcHighScore     = 0.6
+	arcLowScore      = 0.4
+	arcGroundedScore = 0.5
+)
+
+// minArcPoints is the fewest sentiment points an arc can be fitted to
+const minArcPoints = 3
+
+// ClassifyConversationArc fits a linear trend to a conversation's sentiment and names its overall trajectory:
+//   - uplifting: sentiment rises by more than 0.02 per message
+//   - grounding: sentiment falls by more than 0.02 per message but ends above 0.5
+//   - processing: sentiment swings widely while trending down
+//   - celebratory: sentiment stays high throughout
+//   - supportive: sentiment stays low and steady
+//
+// Anything else is mixed. Conversations with fewer than three messages or sentiment points are too short to have an
+// arc and return "".
+func ClassifyConversationArc(messages []*models.Message, sentimentPoints []models.SentimentPoint) models.ConversationArc {
+	if len(messages) < minArcPoints || len(sentimentPoints) < minArcPoints {
+		return ""
+	}
+
+	points := slices.Clone(sentimentPoints)
+	slices.SortStableFunc(points, func(a, b models.SentimentPoint) int { return a.Timestamp.Compare(b.Timestamp) })
+	scores := make([]float64, len(points))
+	for i, point := range points {
+		scores[i] = point.Score
+	}
+
+	slope := linearSlope(scores)
+	mean, variance := meanAndVariance(scores)
+	final := scores[len(scores)-1]
+	lowest, highest := slices.Min(scores), slices.Max(scores)
+
+	switch {
+	case slope > arcTrendSlope:
+		return models.ConversationArcUplifting
+	case slope < -arcTrendSlope && final > arcGroundedScore:
+		return models.ConversationArcGrounding
+	case slope < 0 && variance > arcHighVariance:
+		return models.ConversationArcProcessing
+	case lowest >= arcHighScore:
+		return models.ConversationArcCelebratory
+	case highest <= arcLowScore && variance <= arcLowVariance && mean <= arcLowScore:
+		return models.ConversationArcSupportive
+	default:
+		return models.ConversationArcMixed
+	}
+}
+
+// linearSlope is the least-squares slope of scores against their index
+func linearSlope(scores []float64) float64 {
+	n := float64(len(scores))
+	meanX := (n - 1) / 2
+	meanY, _ := meanAndVariance(scores)
+
+	var covariance, varianceX float64
+	for i, score := range scores {
+		dx := float64(i) - meanX
+		covariance += dx * (score - meanY)
+		varianceX += dx * dx
+	}
+	if varianceX == 0 {
+		return 0
+	}
+	return covariance / varianceX
+}
+
+// meanAndVariance returns the mean and population variance of values
+func meanAndVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, value := range values {
+		variance += math.Pow(value-mean, 2)
+	}
+	return mean, variance / float64(len(values))
+}
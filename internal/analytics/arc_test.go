@@ -0,0 +1,59 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// sentiment builds chronological sentiment points and a matching message list
+func sentiment(scores ...float64) ([]*models.Message, []models.SentimentPoint) {
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	messages := make([]*models.Message, len(scores))
+	points := make([]models.SentimentPoint, len(scores))
+	for i, score := range scores {
+		messages[i] = &models.Message{CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+		points[i] = models.SentimentPoint{Timestamp: messages[i].CreatedAt, Score: score}
+	}
+	return messages, points
+}
+
+func TestClassifyConversationArc(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []float64
+		want   models.ConversationArc
+	}{
+		{name: "uplifting", scores: []float64{0.2, 0.3, 0.35, 0.5, 0.6, 0.75}, want: models.ConversationArcUplifting},
+		{name: "grounding", scores: []float64{0.95, 0.9, 0.8, 0.75, 0.7, 0.6}, want: models.ConversationArcGrounding},
+		{name: "processing", scores: []float64{0.8, 0.1, 0.7, 0.2, 0.6, 0.1, 0.5, 0.2}, want: models.ConversationArcProcessing},
+		{name: "celebratory", scores: []float64{0.8, 0.9, 0.85, 0.9, 0.8, 0.85}, want: models.ConversationArcCelebratory},
+		{name: "supportive", scores: []float64{0.3, 0.25, 0.3, 0.3, 0.25, 0.3}, want: models.ConversationArcSupportive},
+		{name: "mixed", scores: []float64{0.5, 0.45, 0.55, 0.5, 0.45, 0.5}, want: models.ConversationArcMixed},
+		{name: "too short", scores: []float64{0.2, 0.9}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, points := sentiment(tt.scores...)
+			assert.Equal(t, tt.want, ClassifyConversationArc(messages, points))
+		})
+	}
+}
+
+func TestClassifyConversationArcOrdersPoints(t *testing.T) {
+	messages, points := sentiment(0.2, 0.3, 0.35, 0.5, 0.6, 0.75)
+	// Messages are listed newest first, so the trend arrives reversed
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	assert.Equal(t, models.ConversationArcUplifting, ClassifyConversationArc(messages, points))
+}
+
+func TestLinearSlope(t *testing.T) {
+	assert.InDelta(t, 0.1, linearSlope([]float64{0.1, 0.2, 0.3, 0.4}), 1e-9)
+	assert.InDelta(t, -0.05, linearSlope([]float64{0.9, 0.85, 0.8}), 1e-9)
+	assert.Zero(t, linearSlope([]float64{0.5}))
+}
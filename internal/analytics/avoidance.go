@@ -0,0 +1,59 @@
+package analytics
+
+import (
+	"strings"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// TopicAvoidanceTrigger is the emotional trigger recorded when the user steers away from the current topic
+const TopicAvoidanceTrigger = "avoidance_detected"
+
+// topicAvoidanceMessages is how many consecutive user messages must leave the topic to count as avoidance
+const topicAvoidanceMessages = 3
+
+// DetectTopicAvoidance reports whether the user is steering away from currentTopic: the companion addressed it just
+// before the last three user messages, and none of them mention it. recentMessages must be oldest first; user
+// messages without text are ignored. It returns the avoided topic.
+func DetectTopicAvoidance(recentMessages []*models.Message, currentTopic string) (bool, string) {
+	topic := strings.ToLower(strings.TrimSpace(currentTopic))
+	if topic == "" {
+		return false, ""
+	}
+
+	// Indexes of the last user text messages, newest first, plus the one before them if there is one
+	var userIndexes []int
+	for i := len(recentMessages) - 1; i >= 0 && len(userIndexes) <= topicAvoidanceMessages; i-- {
+		msg := recentMessages[i]
+		if msg.SenderType == sendertype.User && msg.Text != nil {
+			userIndexes = append(userIndexes, i)
+		}
+	}
+	if len(userIndexes) < topicAvoidanceMessages {
+		return false, ""
+	}
+
+	for _, i := range userIndexes[:topicAvoidanceMessages] {
+		if mentionsTopic(recentMessages[i], topic) {
+			return false, ""
+		}
+	}
+
+	// The companion must have addressed the topic in the turn before the first of those messages
+	turnStart := 0
+	if len(userIndexes) > topicAvoidanceMessages {
+		turnStart = userIndexes[topicAvoidanceMessages] + 1
+	}
+	for _, msg := range recentMessages[turnStart:userIndexes[topicAvoidanceMessages-1]] {
+		if msg.SenderType == sendertype.Companion && mentionsTopic(msg, topic) {
+			return true, topic
+		}
+	}
+	return false, ""
+}
+
+// mentionsTopic reports whether the message's text mentions the lowercase topic
+func mentionsTopic(msg *models.Message, topic string) bool {
+	return msg.Text != nil && strings.Contains(strings.ToLower(*msg.Text), topic)
+}
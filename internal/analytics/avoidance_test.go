@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func user(text string) *models.Message {
+	return &models.Message{SenderType: sendertype.User, Text: &text}
+}
+
+func companion(text string) *models.Message {
+	return &models.Message{SenderType: sendertype.Companion, Text: &text}
+}
+
+func TestDetectTopicAvoidance(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		messages []*models.Message
+		avoided  bool
+	}{
+		{
+			name: "changes the subject three times after a follow-up",
+			messages: []*models.Message{
+				user("My dad is back in the hospital"),
+				companion("I'm so sorry. How is your dad doing in the hospital?"),
+				user("Did you see the game last night?"),
+				companion("I did! What a finish."),
+				user("I'm thinking of getting a new phone"),
+				companion("Ooh, which one?"),
+				user("Maybe the green one"),
+			},
+			avoided: true,
+		},
+		{
+			name: "ignores photos when counting",
+			messages: []*models.Message{
+				companion("Do you want to talk about the hospital visit?"),
+				user("Look at this cat"),
+				{SenderType: sendertype.User, Type: "photo"},
+				user("Isn't he cute"),
+				companion("Adorable!"),
+				user("His name is Biscuit"),
+			},
+			avoided: true,
+		},
+		{
+			name: "keeps talking about the topic",
+			messages: []*models.Message{
+				companion("How is your dad doing in the hospital?"),
+				user("Not great"),
+				companion("That must be hard."),
+				user("The hospital food is awful too"),
+				companion("Ugh."),
+				user("Anyway, did you see the game?"),
+			},
+			avoided: false,
+		},
+		{
+			name: "companion never addressed the topic",
+			messages: []*models.Message{
+				user("My dad is back in the hospital"),
+				companion("Oh no."),
+				user("Did you see the game last night?"),
+				companion("I did! What a finish."),
+				user("I'm thinking of getting a new phone"),
+				companion("Ooh, which one?"),
+				user("Maybe the green one"),
+			},
+			avoided: false,
+		},
+		{
+			name: "companion addressed the topic too long ago",
+			messages: []*models.Message{
+				companion("How is your dad doing in the hospital?"),
+				user("He's okay, thanks"),
+				companion("Glad to hear it."),
+				user("Did you see the game last night?"),
+				companion("I did!"),
+				user("I'm thinking of getting a new phone"),
+				companion("Which one?"),
+				user("Maybe the green one"),
+			},
+			avoided: false,
+		},
+		{
+			name: "too few user messages",
+			messages: []*models.Message{
+				companion("How is your dad doing in the hospital?"),
+				user("Did you see the game last night?"),
+				user("I'm thinking of getting a new phone"),
+			},
+			avoided: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			avoided, topic := DetectTopicAvoidance(tc.messages, "Hospital")
+			assert.Equal(t, tc.avoided, avoided)
+			if tc.avoided {
+				assert.Equal(t, "hospital", topic)
+			} else {
+				assert.Empty(t, topic)
+			}
+		})
+	}
+
+	avoided, _ := DetectTopicAvoidance([]*models.Message{companion("hi"), user("a"), user("b"), user("c")}, "")
+	assert.False(t, avoided, "no current topic")
+}
@@ -0,0 +1,57 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// ComputeChemistryScore combines trust, intimacy, engagement and conversation quality into a score in [0, 1] using
+// the weighted harmonic mean sum(w) / sum(w_i / x_i), with the weights in models.Chemistry*Weight. The harmonic mean
+// is dominated by the weakest component, so a relationship cannot make up for a missing dimension with a strong
+// one. Inputs are clamped to [0, 1] with NaN treated as 0, and any zero input gives a score of 0, the limit of the
+// mean as that component approaches 0.
+func ComputeChemistryScore(trust, intimacy, engagement, quality float64) float64 {
+	components := []struct {
+		value  float64
+		weight float64
+	}{
+		{trust, models.ChemistryTrustWeight},
+		{intimacy, models.ChemistryIntimacyWeight},
+		{engagement, models.ChemistryEngagementWeight},
+		{quality, models.ChemistryQualityWeight},
+	}
+
+	var weights, weightedInverses float64
+	for _, c := range components {
+		value := unitInterval(c.value)
+		if value == 0 {
+			return 0
+		}
+		weights += c.weight
+		weightedInverses += c.weight / value
+	}
+	return weights / weightedInverses
+}
+
+// ConversationQuality is the mean of the quality metrics of an engagement analytics record
+func ConversationQuality(engagement models.UserEngagementAnalytics) float64 {
+	return (engagement.ConversationDepth + engagement.EmotionalIntensity + engagement.TopicDiversity + engagement.VulnerabilityLevel) / 4
+}
+
+// RelationshipChemistry scores the relationship's chemistry from its trust and intimacy and the latest engagement
+// analytics, which may be nil when the user has not had a conversation analysed yet
+func RelationshipChemistry(relationship *models.RelationshipAnalytics, latest *models.UserEngagementAnalytics) float64 {
+	if latest == nil {
+		return 0
+	}
+	return ComputeChemistryScore(relationship.TrustLevel, relationship.IntimacyLevel, latest.EngagementScore, ConversationQuality(*latest))
+}
+
+// unitInterval clamps value to [0, 1], mapping NaN to 0
+func unitInterval(value float64) float64 {
+	if math.IsNaN(value) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeChemistryScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      float64
+		intimacy   float64
+		engagement float64
+		quality    float64
+		want       float64
+	}{
+		{name: "all zero", want: 0},
+		{name: "zero trust", trust: 0, intimacy: 1, engagement: 1, quality: 1, want: 0},
+		{name: "zero intimacy", trust: 1, intimacy: 0, engagement: 1, quality: 1, want: 0},
+		{name: "zero engagement", trust: 1, intimacy: 1, engagement: 0, quality: 1, want: 0},
+		{name: "zero quality", trust: 1, intimacy: 1, engagement: 1, quality: 0, want: 0},
+		{name: "all one", trust: 1, intimacy: 1, engagement: 1, quality: 1, want: 1},
+		{name: "equal inputs", trust: 0.6, intimacy: 0.6, engagement: 0.6, quality: 0.6, want: 0.6},
+		// 1 / (0.3/0.5 + 0.25/1 + 0.25/1 + 0.2/1)
+		{name: "low trust", trust: 0.5, intimacy: 1, engagement: 1, quality: 1, want: 1 / 1.3},
+		// 1 / (0.3/1 + 0.25/1 + 0.25/1 + 0.2/0.5)
+		{name: "low quality weighs less than low trust", trust: 1, intimacy: 1, engagement: 1, quality: 0.5, want: 1 / 1.2},
+		// 1 / (0.3/0.8 + 0.25/0.5 + 0.25/0.4 + 0.2/0.25)
+		{name: "mixed", trust: 0.8, intimacy: 0.5, engagement: 0.4, quality: 0.25, want: 1 / 2.3},
+		{name: "above one is clamped", trust: 3, intimacy: 1, engagement: 1, quality: 1, want: 1},
+		{name: "negative is clamped", trust: -0.5, intimacy: 1, engagement: 1, quality: 1, want: 0},
+		{name: "NaN counts as zero", trust: math.NaN(), intimacy: 1, engagement: 1, quality: 1, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, ComputeChemistryScore(tt.trust, tt.intimacy, tt.engagement, tt.quality), 1e-9)
+		})
+	}
+}
+
+func TestChemistryWeightsSumToOne(t *testing.T) {
+	sum := models.ChemistryTrustWeight + models.ChemistryIntimacyWeight + models.ChemistryEngagementWeight + models.ChemistryQualityWeight
+	assert.InDelta(t, 1, sum, 1e-9)
+}
+
+func TestRelationshipChemistry(t *testing.T) {
+	relationship := &models.RelationshipAnalytics{TrustLevel: 0.8, IntimacyLevel: 0.5}
+
+	assert.Zero(t, RelationshipChemistry(relationship, nil), "no engagement analytics yet")
+
+	latest := &models.UserEngagementAnalytics{
+		EngagementScore:    0.4,
+		ConversationDepth:  0.4,
+		EmotionalIntensity: 0.2,
+		TopicDiversity:     0.3,
+		VulnerabilityLevel: 0.1,
+	}
+	assert.InDelta(t, 0.25, ConversationQuality(*latest), 1e-9)
+	assert.InDelta(t, 1/2.3, RelationshipChemistry(relationship, latest), 1e-9)
+}
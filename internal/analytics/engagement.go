@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultEngagementMidpoint is the session length at which the raw engagement score and 0.5 count equally
+const DefaultEngagementMidpoint = 10 * time.Minute
+
+// DefaultEngagementSteepness is how quickly, per minute of session length, the raw score takes over from 0.5
+const DefaultEngagementSteepness = 0.3
+
+// neutralEngagementScore is the score a session with no evidence of engagement either way is regressed toward
+const neutralEngagementScore = 0.5
+
+// EngagementNormaliser corrects engagement scores for session length. The raw score is computed from a fixed number
+// of messages, so a two-minute session can score as high or as low as an hour-long one; the normaliser trusts the
+// raw score more the longer the session was. A nil normaliser uses the defaults.
+type EngagementNormaliser struct {
+	midpoint  time.Duration
+	steepness float64
+}
+
+// NewEngagementNormaliser creates a normaliser whose sigmoid weight reaches one half at midpoint and rises by
+// steepness per minute. Non-positive values use DefaultEngagementMidpoint and DefaultEngagementSteepness.
+func NewEngagementNormaliser(midpoint time.Duration, steepness float64) *EngagementNormaliser {
+	if midpoint <= 0 {
+		midpoint = DefaultEngagementMidpoint
+	}
+	if steepness <= 0 {
+		steepness = DefaultEngagementSteepness
+	}
+	return &EngagementNormaliser{midpoint: midpoint, steepness: steepness}
+}
+
+// NormaliseEngagementScore regresses rawScore toward 0.5 by a sigmoid of the session length, so short sessions land
+// near 0.5 and long sessions keep close to their raw score. A session without messages scores 0.5.
+func (n *EngagementNormaliser) NormaliseEngagementScore(rawScore float64, sessionDuration time.Duration, messageCount int) float64 {
+	if n == nil {
+		n = NewEngagementNormaliser(0, 0)
+	}
+	if messageCount <= 0 {
+		return neutralEngagementScore
+	}
+
+	weight := 1 / (1 + math.Exp(-n.steepness*(sessionDuration-n.midpoint).Minutes()))
+	return neutralEngagementScore + (rawScore-neutralEngagementScore)*weight
+}
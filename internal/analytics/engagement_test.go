@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormaliseEngagementScoreSessionLength(t *testing.T) {
+	normaliser := NewEngagementNormaliser(0, 0)
+
+	short := normaliser.NormaliseEngagementScore(0.9, 2*time.Minute, 6)
+	long := normaliser.NormaliseEngagementScore(0.9, 60*time.Minute, 6)
+
+	assert.NotEqual(t, short, long)
+	assert.InDelta(t, 0.53, short, 0.01, "a short session is regressed toward 0.5")
+	assert.InDelta(t, 0.9, long, 0.01, "a long session keeps its raw score")
+
+	assert.InDelta(t, 0.47, normaliser.NormaliseEngagementScore(0.1, 2*time.Minute, 6), 0.01, "low scores are regressed up")
+	assert.InDelta(t, 0.7, normaliser.NormaliseEngagementScore(0.9, DefaultEngagementMidpoint, 6), 1e-9, "the midpoint counts the raw score half")
+}
+
+func TestNormaliseEngagementScoreParameters(t *testing.T) {
+	steep := NewEngagementNormaliser(time.Minute, 2)
+	assert.InDelta(t, 0.9, steep.NormaliseEngagementScore(0.9, 5*time.Minute, 6), 0.01)
+
+	var defaults *EngagementNormaliser
+	assert.Equal(t, NewEngagementNormaliser(0, 0).NormaliseEngagementScore(0.9, 2*time.Minute, 6), defaults.NormaliseEngagementScore(0.9, 2*time.Minute, 6))
+
+	assert.Equal(t, 0.5, defaults.NormaliseEngagementScore(0.9, time.Hour, 0), "no messages, no evidence")
+}
@@ -0,0 +1,127 @@
+package analytics
+
+// KeywordMatcher is an Aho-Corasick automaton that reports which keywords occur in a text
+// in a single pass, regardless of how many keywords it holds
+type KeywordMatcher struct {
+	// classes maps each byte to its alphabet class; bytes absent from every keyword share class 0
+	classes    [256]uint8
+	numClasses int
+	// next is the dense goto/failure transition table, indexed by state*numClasses+class
+	next []int32
+	// outputs lists the keyword ids recognised on reaching each state, including via suffix links
+	outputs [][]int32
+	// weights counts how many times each distinct keyword appeared in the input list
+	weights []int
+	// always is the weight of empty keywords, which match every text
+	always int
+}
+
+// NewKeywordMatcher builds an automaton over keywords. Duplicate keywords are kept and counted separately.
+func NewKeywordMatcher(keywords []string) *KeywordMatcher {
+	m := &KeywordMatcher{}
+
+	ids := make(map[string]int32)
+	var unique []string
+	for _, keyword := range keywords {
+		if keyword == "" {
+			m.always++
+			continue
+		}
+		id, ok := ids[keyword]
+		if !ok {
+			id = int32(len(unique))
+			ids[keyword] = id
+			unique = append(unique, keyword)
+			m.weights = append(m.weights, 0)
+		}
+		m.weights[id]++
+	}
+
+	m.numClasses = 1
+	for _, keyword := range unique {
+		for i := 0; i < len(keyword); i++ {
+			if m.classes[keyword[i]] == 0 {
+				m.classes[keyword[i]] = uint8(m.numClasses)
+				m.numClasses++
+			}
+		}
+	}
+
+	// Build the trie with -1 marking missing edges
+	m.next = m.newState()
+	m.outputs = [][]int32{nil}
+	for id, keyword := range unique {
+		state := int32(0)
+		for i := 0; i < len(keyword); i++ {
+			idx := int(state)*m.numClasses + int(m.classes[keyword[i]])
+			if m.next[idx] < 0 {
+				m.next[idx] = int32(len(m.outputs))
+				m.next = append(m.next, m.newState()...)
+				m.outputs = append(m.outputs, nil)
+			}
+			state = m.next[idx]
+		}
+		m.outputs[state] = append(m.outputs[state], int32(id))
+	}
+
+	// Resolve failure links breadth-first, turning the trie into a complete DFA
+	fail := make([]int32, len(m.outputs))
+	queue := make([]int32, 0, len(m.outputs))
+	for c := 0; c < m.numClasses; c++ {
+		if child := m.next[c]; child < 0 {
+			m.next[c] = 0
+		} else {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+
+		for c := 0; c < m.numClasses; c++ {
+			idx := int(state)*m.numClasses + c
+			fallback := m.next[int(fail[state])*m.numClasses+c]
+			if child := m.next[idx]; child < 0 {
+				m.next[idx] = fallback
+			} else {
+				fail[child] = fallback
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return m
+}
+
+// newState returns an empty row of transitions
+func (m *KeywordMatcher) newState() []int32 {
+	row := make([]int32, m.numClasses)
+	for i := range row {
+		row[i] = -1
+	}
+	return row
+}
+
+// CountMatches returns how many keywords occur in text, counting each keyword once
+// however often it appears, exactly as a strings.Contains check per keyword would
+func (m *KeywordMatcher) CountMatches(text string) int {
+	count := m.always
+	if len(m.weights) == 0 {
+		return count
+	}
+
+	seen := make([]bool, len(m.weights))
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = m.next[int(state)*m.numClasses+int(m.classes[text[i]])]
+		for _, id := range m.outputs[state] {
+			if !seen[id] {
+				seen[id] = true
+				count += m.weights[id]
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,122 @@
+package analytics
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countContains is the per-keyword strings.Contains scan the matcher replaces
+func countContains(keywords []string, text string) int {
+	count := 0
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestKeywordMatcherCountMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords []string
+		text     string
+		want     int
+	}{
+		{name: "no keywords", keywords: nil, text: "anything", want: 0},
+		{name: "no match", keywords: []string{"happy", "sad"}, text: "the weather is fine", want: 0},
+		{name: "repeated keyword counts once", keywords: []string{"good"}, text: "good good good", want: 1},
+		{name: "duplicate keywords count separately", keywords: []string{"increíble", "bueno", "increíble"}, text: "es increíble", want: 2},
+		{name: "overlapping keywords", keywords: []string{"he", "she", "hers", "his"}, text: "ushers", want: 3},
+		{name: "keyword inside a word", keywords: []string{"joy"}, text: "enjoying it", want: 1},
+		{name: "keyword suffix of another", keywords: []string{"terrible", "rib"}, text: "terrible", want: 2},
+		{name: "multibyte keywords", keywords: []string{"悲伤", "可怕", "爱"}, text: "我爱你，但是很悲伤", want: 2},
+		{name: "empty keyword always matches", keywords: []string{"", "x"}, text: "abc", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewKeywordMatcher(tt.keywords).CountMatches(tt.text)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, countContains(tt.keywords, tt.text), got)
+		})
+	}
+}
+
+func TestKeywordMatcherAgreesWithContains(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := "abcab "
+	randomString := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			sb.WriteByte(alphabet[rng.Intn(len(alphabet))])
+		}
+		return sb.String()
+	}
+
+	for i := 0; i < 500; i++ {
+		keywords := make([]string, 1+rng.Intn(10))
+		for j := range keywords {
+			keywords[j] = randomString(1 + rng.Intn(4))
+		}
+		text := randomString(rng.Intn(40))
+
+		assert.Equal(t, countContains(keywords, text), NewKeywordMatcher(keywords).CountMatches(text), "keywords %q text %q", keywords, text)
+	}
+}
+
+// benchmarkCorpus returns 200 sentiment-like keywords and a 1000-word message
+func benchmarkCorpus() ([]string, string) {
+	rng := rand.New(rand.NewSource(42))
+	letters := "abcdefghijklmnopqrstuvwxyz"
+	word := func(min, max int) string {
+		b := make([]byte, min+rng.Intn(max-min+1))
+		for i := range b {
+			b[i] = letters[rng.Intn(len(letters))]
+		}
+		return string(b)
+	}
+
+	keywords := make([]string, 200)
+	for i := range keywords {
+		keywords[i] = word(5, 10)
+	}
+
+	words := make([]string, 1000)
+	for i := range words {
+		if i%50 == 0 {
+			words[i] = keywords[rng.Intn(len(keywords))]
+		} else {
+			words[i] = word(2, 8)
+		}
+	}
+
+	return keywords, strings.Join(words, " ")
+}
+
+func BenchmarkSentimentKeywords(b *testing.B) {
+	keywords, text := benchmarkCorpus()
+	matcher := NewKeywordMatcher(keywords)
+	if got, want := matcher.CountMatches(text), countContains(keywords, text); got != want {
+		b.Fatalf("matcher counted %d keywords, strings.Contains counted %d", got, want)
+	}
+
+	for _, bm := range []struct {
+		name  string
+		count func() int
+	}{
+		{name: "StringsContains", count: func() int { return countContains(keywords, text) }},
+		{name: "AhoCorasick", count: func() int { return matcher.CountMatches(text) }},
+	} {
+		b.Run(fmt.Sprintf("%s/keywords=%d", bm.name, len(keywords)), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			for i := 0; i < b.N; i++ {
+				bm.count()
+			}
+		})
+	}
+}
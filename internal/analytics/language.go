@@ -0,0 +1,62 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pemistahl/lingua-go"
+)
+
+// FallbackLanguage is the language reported when detection is not confident enough
+const FallbackLanguage = "en"
+
+// DefaultLanguageConfidenceThreshold is the confidence below which detection falls back to FallbackLanguage
+const DefaultLanguageConfidenceThreshold = 0.5
+
+// LanguageDetector identifies which of the supported languages a text is written in using lingua's n-gram models
+type LanguageDetector struct {
+	detector  lingua.LanguageDetector
+	threshold float64
+}
+
+// NewLanguageDetector builds a detector choosing between the given ISO 639-1 codes. FallbackLanguage is always
+// supported. A non-positive threshold uses DefaultLanguageConfidenceThreshold.
+func NewLanguageDetector(codes []string, threshold float64) (*LanguageDetector, error) {
+	if threshold <= 0 {
+		threshold = DefaultLanguageConfidenceThreshold
+	}
+
+	seen := make(map[lingua.Language]bool)
+	var languages []lingua.Language
+	for _, code := range append([]string{FallbackLanguage}, codes...) {
+		language := lingua.GetLanguageFromIsoCode639_1(lingua.GetIsoCode639_1FromValue(strings.TrimSpace(code)))
+		if language == lingua.Unknown {
+			return nil, fmt.Errorf("unsupported language code %q", code)
+		}
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+
+	d := &LanguageDetector{threshold: threshold}
+	// lingua needs at least two languages to choose between; with only the fallback there is nothing to detect
+	if len(languages) > 1 {
+		d.detector = lingua.NewLanguageDetectorBuilder().FromLanguages(languages...).Build()
+	}
+	return d, nil
+}
+
+// Detect returns the lowercase ISO 639-1 code of the most likely language of text, or FallbackLanguage when the
+// most likely language's confidence is below the threshold
+func (d *LanguageDetector) Detect(text string) string {
+	if d.detector == nil {
+		return FallbackLanguage
+	}
+
+	values := d.detector.ComputeLanguageConfidenceValues(text)
+	if len(values) == 0 || values[0].Value() < d.threshold {
+		return FallbackLanguage
+	}
+	return strings.ToLower(values[0].Language().IsoCode639_1().String())
+}
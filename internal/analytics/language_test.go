@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageDetector(t *testing.T) {
+	detector, err := NewLanguageDetector([]string{"es", "de", "ru"}, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "es", detector.Detect("¿Qué quieres hacer mañana?"))
+	assert.Equal(t, "de", detector.Detect("Ich vermisse dich sehr"))
+	assert.Equal(t, "ru", detector.Detect("Что ты сейчас делаешь?"))
+	assert.Equal(t, "en", detector.Detect("Can we talk later tonight?"), "the fallback language is always supported")
+	assert.Equal(t, FallbackLanguage, detector.Detect("123 :)"), "text without letters falls back")
+}
+
+func TestLanguageDetectorConfidenceThreshold(t *testing.T) {
+	detector, err := NewLanguageDetector([]string{"es", "pt", "it"}, 0.99)
+	require.NoError(t, err)
+
+	assert.Equal(t, FallbackLanguage, detector.Detect("casa"), "a word shared by several languages is not confident enough")
+}
+
+func TestLanguageDetectorOnlyFallback(t *testing.T) {
+	detector, err := NewLanguageDetector([]string{"en"}, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, FallbackLanguage, detector.Detect("Wie geht es dir heute?"))
+}
+
+func TestLanguageDetectorUnknownCode(t *testing.T) {
+	_, err := NewLanguageDetector([]string{"en", "xx"}, 0)
+	assert.ErrorContains(t, err, `"xx"`)
+}
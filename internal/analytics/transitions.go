@@ -0,0 +1,48 @@
+package analytics
+
+import "github.com/sahmaragaev/lunaria-backend/internal/models"
+
+// ComputeEmotionTransitionMatrix builds a Markov transition matrix from consecutive snapshot pairs.
+// matrix[from][to] is the probability that an emotion of "from" is followed by "to"; each row sums to 1.
+// Snapshots without a primary emotion are skipped.
+func ComputeEmotionTransitionMatrix(snapshots []models.EmotionalSnapshot) map[string]map[string]float64 {
+	counts := make(map[string]map[string]int)
+
+	previous := ""
+	for _, snapshot := range snapshots {
+		if snapshot.EmotionalState == nil || snapshot.EmotionalState.PrimaryEmotion == "" {
+			continue
+		}
+		current := snapshot.EmotionalState.PrimaryEmotion
+
+		if previous != "" {
+			if counts[previous] == nil {
+				counts[previous] = make(map[string]int)
+			}
+			counts[previous][current]++
+		}
+		previous = current
+	}
+
+	return NormalizeTransitionCounts(counts)
+}
+
+// NormalizeTransitionCounts turns transition counts into row-normalised probabilities
+func NormalizeTransitionCounts(counts map[string]map[string]int) map[string]map[string]float64 {
+	matrix := make(map[string]map[string]float64, len(counts))
+	for from, row := range counts {
+		total := 0
+		for _, count := range row {
+			total += count
+		}
+		if total == 0 {
+			continue
+		}
+
+		matrix[from] = make(map[string]float64, len(row))
+		for to, count := range row {
+			matrix[from][to] = float64(count) / float64(total)
+		}
+	}
+	return matrix
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshots(emotions ...string) []models.EmotionalSnapshot {
+	result := make([]models.EmotionalSnapshot, len(emotions))
+	for i, emotion := range emotions {
+		if emotion != "" {
+			result[i].EmotionalState = &models.EmotionalState{PrimaryEmotion: emotion}
+		}
+	}
+	return result
+}
+
+func TestComputeEmotionTransitionMatrix(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []models.EmotionalSnapshot
+		want      map[string]map[string]float64
+	}{
+		{
+			name:      "empty history",
+			snapshots: nil,
+			want:      map[string]map[string]float64{},
+		},
+		{
+			name:      "single snapshot has no transitions",
+			snapshots: snapshots("joy"),
+			want:      map[string]map[string]float64{},
+		},
+		{
+			name:      "anxiety settles into contentment",
+			snapshots: snapshots("anxiety", "contentment", "anxiety", "contentment", "anxiety", "anxiety"),
+			want: map[string]map[string]float64{
+				"anxiety":     {"contentment": 2.0 / 3, "anxiety": 1.0 / 3},
+				"contentment": {"anxiety": 1},
+			},
+		},
+		{
+			name:      "self transitions",
+			snapshots: snapshots("joy", "joy", "joy", "sadness"),
+			want: map[string]map[string]float64{
+				"joy": {"joy": 2.0 / 3, "sadness": 1.0 / 3},
+			},
+		},
+		{
+			name:      "snapshots without emotion are skipped",
+			snapshots: snapshots("sadness", "", "joy"),
+			want: map[string]map[string]float64{
+				"sadness": {"joy": 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeEmotionTransitionMatrix(tt.snapshots)
+			assert.Equal(t, len(tt.want), len(got))
+			for from, row := range tt.want {
+				assert.Equal(t, len(row), len(got[from]), from)
+				total := 0.0
+				for to, probability := range row {
+					assert.InDelta(t, probability, got[from][to], 1e-9, "%s -> %s", from, to)
+					total += got[from][to]
+				}
+				assert.InDelta(t, 1.0, total, 1e-9, from)
+			}
+		})
+	}
+}
+
+func TestNormalizeTransitionCounts(t *testing.T) {
+	got := NormalizeTransitionCounts(map[string]map[string]int{
+		"fear":  {"fear": 1, "joy": 3},
+		"empty": {},
+	})
+
+	assert.Equal(t, map[string]map[string]float64{"fear": {"fear": 0.25, "joy": 0.75}}, got)
+}
@@ -0,0 +1,4 @@
+// Package analyticspb contains the generated gRPC bindings for analytics ingestion.
+package analyticspb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative analytics.proto
@@ -0,0 +1,56 @@
+package cache
+
+import "sync"
+
+// Invalidator drops cached entries by key
+type Invalidator interface {
+	Invalidate(key string)
+}
+
+// Cache is a concurrency-safe in-memory cache. Entries may be registered under
+// extra alias keys, such as a MongoDB document ID, so they can be invalidated by either.
+type Cache[V any] struct {
+	mu      sync.RWMutex
+	items   map[string]V
+	aliases map[string]string
+}
+
+// New creates an empty cache
+func New[V any]() *Cache[V] {
+	return &Cache[V]{
+		items:   make(map[string]V),
+		aliases: make(map[string]string),
+	}
+}
+
+// Get returns the value stored under key
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	value, ok := c.items[key]
+	return value, ok
+}
+
+// Set stores value under key and any aliases
+func (c *Cache[V]) Set(key string, value V, aliases ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = value
+	for _, alias := range aliases {
+		c.aliases[alias] = key
+	}
+}
+
+// Invalidate removes the entry stored under key or registered under the alias key
+func (c *Cache[V]) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if target, ok := c.aliases[key]; ok {
+		delete(c.aliases, key)
+		delete(c.items, target)
+	}
+	delete(c.items, key)
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeEvent is the subset of a change stream event needed for invalidation
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID any `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// Logger receives change stream failures; *slog.Logger and the services' loggers satisfy it
+type Logger interface {
+	Error(msg string, args ...any)
+}
+
+// ChangeStreamWatcher invalidates caches when documents change in MongoDB outside this process
+type ChangeStreamWatcher struct {
+	db           *mongo.Database
+	targets      map[string][]Invalidator
+	retryDelay   time.Duration
+	logger       Logger
+	mu           sync.Mutex
+	resumeTokens map[string]bson.Raw
+}
+
+// NewChangeStreamWatcher creates a watcher for collections of db that logs stream failures to logger, or
+// slog.Default() if it is nil
+func NewChangeStreamWatcher(db *mongo.Database, logger Logger) *ChangeStreamWatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ChangeStreamWatcher{
+		db:           db,
+		targets:      make(map[string][]Invalidator),
+		retryDelay:   5 * time.Second,
+		logger:       logger,
+		resumeTokens: make(map[string]bson.Raw),
+	}
+}
+
+// Register invalidates cache whenever a document in collection is updated, replaced or deleted
+func (w *ChangeStreamWatcher) Register(collection string, cache Invalidator) {
+	w.targets[collection] = append(w.targets[collection], cache)
+}
+
+// Start watches every registered collection until ctx is cancelled
+func (w *ChangeStreamWatcher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for collection := range w.targets {
+		wg.Add(1)
+		go func(collection string) {
+			defer wg.Done()
+			w.run(ctx, collection)
+		}(collection)
+	}
+	wg.Wait()
+}
+
+// run keeps a change stream open on collection, reconnecting from the last resume token on failure
+func (w *ChangeStreamWatcher) run(ctx context.Context, collection string) {
+	for {
+		if err := w.watch(ctx, collection); err != nil && ctx.Err() == nil {
+			w.logger.Error("Change stream failed", "collection", collection, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.retryDelay):
+		}
+	}
+}
+
+// watch consumes a single change stream session on collection
+func (w *ChangeStreamWatcher) watch(ctx context.Context, collection string) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"update", "replace", "delete"}}}}},
+	}
+
+	opts := options.ChangeStream()
+	if token := w.resumeToken(collection); token != nil {
+		opts.SetResumeAfter(token)
+	}
+
+	stream, err := w.db.Collection(collection).Watch(ctx, pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open change stream: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			return fmt.Errorf("failed to decode change event: %w", err)
+		}
+
+		w.handle(collection, &event)
+		w.setResumeToken(collection, stream.ResumeToken())
+	}
+
+	return stream.Err()
+}
+
+// handle invalidates the changed document in every cache registered for collection
+func (w *ChangeStreamWatcher) handle(collection string, event *changeEvent) {
+	switch event.OperationType {
+	case "update", "replace", "delete":
+	default:
+		return
+	}
+
+	key := documentKey(event.DocumentKey.ID)
+	for _, cache := range w.targets[collection] {
+		cache.Invalidate(key)
+	}
+}
+
+func (w *ChangeStreamWatcher) resumeToken(collection string) bson.Raw {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resumeTokens[collection]
+}
+
+func (w *ChangeStreamWatcher) setResumeToken(collection string, token bson.Raw) {
+	if token == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.resumeTokens[collection] = token
+}
+
+// documentKey renders a document _id as a cache key
+func documentKey(id any) string {
+	switch v := id.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type recordingInvalidator struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *recordingInvalidator) Invalidate(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, key)
+}
+
+func changeEventDoc(token, operationType string, id any) bson.D {
+	return bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: token}}},
+		{Key: "operationType", Value: operationType},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+	}
+}
+
+func TestChangeStreamWatcherInvalidates(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("invalidates changed documents", func(mt *mtest.T) {
+		profileID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.companion_profiles", mtest.FirstBatch,
+			changeEventDoc("token-1", "update", profileID),
+			changeEventDoc("token-2", "delete", "settings-user-1"),
+		))
+
+		recorder := &recordingInvalidator{}
+		watcher := NewChangeStreamWatcher(mt.DB, nil)
+		watcher.Register("companion_profiles", recorder)
+
+		_ = watcher.watch(context.Background(), "companion_profiles")
+
+		assert.Equal(t, []string{profileID.Hex(), "settings-user-1"}, recorder.keys)
+		require.NotNil(t, watcher.resumeToken("companion_profiles"))
+		assert.Equal(t, "token-2", watcher.resumeToken("companion_profiles").Lookup("_data").StringValue())
+	})
+
+	mt.Run("resumes from the stored token", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.companion_profiles", mtest.FirstBatch))
+
+		watcher := NewChangeStreamWatcher(mt.DB, nil)
+		watcher.Register("companion_profiles", &recordingInvalidator{})
+		watcher.setResumeToken("companion_profiles", bson.Raw(bsonDoc(t, bson.D{{Key: "_data", Value: "token-9"}})))
+
+		_ = watcher.watch(context.Background(), "companion_profiles")
+
+		aggregate := mt.GetStartedEvent()
+		require.Equal(t, "aggregate", aggregate.CommandName)
+		resumeAfter := aggregate.Command.Lookup("pipeline", "0", "$changeStream", "resumeAfter", "_data")
+		assert.Equal(t, "token-9", resumeAfter.StringValue())
+	})
+}
+
+type recordingLogger struct {
+	errors []string
+}
+
+func (l *recordingLogger) Error(msg string, args ...any) {
+	l.errors = append(l.errors, msg)
+}
+
+func TestChangeStreamWatcherLogsFailures(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("logs a stream that fails to open", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 40573, Message: "change streams require a replica set"}))
+
+		logger := &recordingLogger{}
+		watcher := NewChangeStreamWatcher(mt.DB, logger)
+		watcher.Register("companion_profiles", &recordingInvalidator{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		watcher.retryDelay = time.Hour
+		go func() {
+			// run logs the failure before waiting out the retry delay
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		watcher.run(ctx, "companion_profiles")
+
+		assert.Equal(t, []string{"Change stream failed"}, logger.errors)
+	})
+}
+
+func TestCacheInvalidateByAlias(t *testing.T) {
+	c := New[string]()
+	c.Set("companion-1", "profile", "64b7f0c2a1b2c3d4e5f60718")
+
+	c.Invalidate("64b7f0c2a1b2c3d4e5f60718")
+
+	_, ok := c.Get("companion-1")
+	assert.False(t, ok)
+}
+
+func bsonDoc(t *testing.T, doc bson.D) []byte {
+	t.Helper()
+	data, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Forwarder sends events to peer instances over gRPC, keeping one connection per peer
+type Forwarder struct {
+	dialOpts []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// NewForwarder creates a forwarder dialling peers with opts, or without transport security if none are given
+func NewForwarder(opts ...grpc.DialOption) *Forwarder {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return &Forwarder{
+		dialOpts: opts,
+		conns:    make(map[string]*grpc.ClientConn),
+	}
+}
+
+// ForwardTypingEvent applies a typing status change on the instance serving gRPC at peerAddr
+func (f *Forwarder) ForwardTypingEvent(ctx context.Context, peerAddr string, event *clusterpb.TypingEvent) error {
+	conn, err := f.conn(peerAddr)
+	if err != nil {
+		return err
+	}
+	if _, err := clusterpb.NewPeerForwardingClient(conn).ForwardTypingEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to forward typing event to %s: %w", peerAddr, err)
+	}
+	return nil
+}
+
+// Drop closes and forgets the connection to peerAddr, if there is one
+func (f *Forwarder) Drop(peerAddr string) error {
+	f.mu.Lock()
+	conn, ok := f.conns[peerAddr]
+	delete(f.conns, peerAddr)
+	f.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return conn.Close()
+}
+
+// Close closes every peer connection
+func (f *Forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var firstErr error
+	for addr, conn := range f.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(f.conns, addr)
+	}
+	return firstErr
+}
+
+// conn returns the connection to peerAddr, creating it on first use
+func (f *Forwarder) conn(peerAddr string) (*grpc.ClientConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if conn, ok := f.conns[peerAddr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(peerAddr, f.dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s: %w", peerAddr, err)
+	}
+	f.conns[peerAddr] = conn
+	return conn, nil
+}
@@ -0,0 +1,337 @@
+// Package cluster keeps track of the other server instances in a multi-instance deployment so in-memory state can be
+// forwarded to them
+package cluster
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Gossip defaults
+const (
+	DefaultGossipInterval = time.Second
+	DefaultPeerTTL        = 10 * time.Second
+)
+
+// maxGossipPacket bounds the size of a single gossip datagram
+const maxGossipPacket = 64 << 10
+
+// errUnsignedGossip is returned for datagrams whose signature does not match the shared secret
+var errUnsignedGossip = errors.New("gossip signature does not match")
+
+// NodeInfo describes a server instance in the cluster
+type NodeInfo struct {
+	NodeID     string `json:"node_id"`
+	Address    string `json:"address"`     // gRPC address peers forward events to
+	HealthURL  string `json:"health_url"`  // HTTP health endpoint of the instance
+	GossipAddr string `json:"gossip_addr"` // UDP address the instance gossips on
+
+	// Incarnation identifies a run of the instance so a restart is not mistaken for a stale entry, and Heartbeat is
+	// bumped by the instance every gossip round
+	Incarnation int64  `json:"incarnation"`
+	Heartbeat   uint64 `json:"heartbeat"`
+
+	LastSeen time.Time `json:"-"`
+}
+
+// newerThan reports whether n carries more recent liveness information than other
+func (n NodeInfo) newerThan(other NodeInfo) bool {
+	if n.Incarnation != other.Incarnation {
+		return n.Incarnation > other.Incarnation
+	}
+	return n.Heartbeat > other.Heartbeat
+}
+
+// Logger receives gossip failures; *slog.Logger and the services' loggers satisfy it
+type Logger interface {
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Config configures a Registry
+type Config struct {
+	Self     NodeInfo
+	Secret   []byte        // shared by every instance; gossip is signed with it and unsigned datagrams are dropped
+	BindAddr string        // UDP address to gossip on, e.g. ":7946"
+	Seeds    []string      // gossip addresses of instances to contact first
+	Interval time.Duration // how often membership is gossiped
+	PeerTTL  time.Duration // how long a peer is kept without its heartbeat advancing
+	Logger   Logger        // gossip failures are logged here, or to slog.Default() if nil
+}
+
+// gossipMessage is the datagram instances exchange: the sender and every live member it knows about
+type gossipMessage struct {
+	From  NodeInfo   `json:"from"`
+	Nodes []NodeInfo `json:"nodes"`
+}
+
+// Registry maintains cluster membership by periodically gossiping known members to peers over UDP. Every datagram
+// carries an HMAC-SHA256 of its payload under the shared secret, so only instances holding the secret can join.
+type Registry struct {
+	conn     *net.UDPConn
+	secret   []byte
+	seeds    []string
+	interval time.Duration
+	ttl      time.Duration
+	now      func() time.Time
+	logger   Logger
+
+	mu           sync.RWMutex
+	self         NodeInfo
+	peers        map[string]NodeInfo
+	removedHooks []func(NodeInfo)
+}
+
+// NewRegistry binds the gossip socket and registers this instance. The instance only becomes visible to peers once
+// Start is running.
+func NewRegistry(cfg Config) (*Registry, error) {
+	if cfg.Self.NodeID == "" {
+		return nil, fmt.Errorf("cluster node ID is required")
+	}
+	if len(cfg.Secret) == 0 {
+		return nil, fmt.Errorf("cluster gossip secret is required")
+	}
+	addr, err := net.ResolveUDPAddr("udp", cfg.BindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gossip address %q: %w", cfg.BindAddr, err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for gossip: %w", err)
+	}
+
+	self := cfg.Self
+	if self.GossipAddr == "" {
+		self.GossipAddr = conn.LocalAddr().String()
+	}
+	if self.Incarnation == 0 {
+		self.Incarnation = time.Now().UnixNano()
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultGossipInterval
+	}
+	ttl := cfg.PeerTTL
+	if ttl <= 0 {
+		ttl = DefaultPeerTTL
+	}
+	var logger Logger = slog.Default()
+	if cfg.Logger != nil {
+		logger = cfg.Logger
+	}
+
+	return &Registry{
+		conn:     conn,
+		secret:   cfg.Secret,
+		seeds:    cfg.Seeds,
+		interval: interval,
+		ttl:      ttl,
+		now:      time.Now,
+		logger:   logger,
+		self:     self,
+		peers:    make(map[string]NodeInfo),
+	}, nil
+}
+
+// Self returns this instance's registration
+func (r *Registry) Self() NodeInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.self
+}
+
+// OnPeerRemoved registers a hook to run when a peer expires or restarts at a different address, with the
+// registration that is no longer valid
+func (r *Registry) OnPeerRemoved(hook func(NodeInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removedHooks = append(r.removedHooks, hook)
+}
+
+// ListPeers returns the other live instances, ordered by node ID
+func (r *Registry) ListPeers() []NodeInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cutoff := r.now().Add(-r.ttl)
+	peers := make([]NodeInfo, 0, len(r.peers))
+	for _, peer := range r.peers {
+		if peer.LastSeen.After(cutoff) {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeID < peers[j].NodeID })
+	return peers
+}
+
+// Start gossips membership until ctx is cancelled, then closes the gossip socket
+func (r *Registry) Start(ctx context.Context) {
+	go r.receive()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.gossip()
+	for {
+		select {
+		case <-ctx.Done():
+			r.conn.Close()
+			return
+		case <-ticker.C:
+			r.expire()
+			r.gossip()
+		}
+	}
+}
+
+// gossip bumps this instance's heartbeat and sends the known membership to every peer and seed
+func (r *Registry) gossip() {
+	r.mu.Lock()
+	r.self.Heartbeat++
+	msg := gossipMessage{From: r.self, Nodes: make([]NodeInfo, 0, len(r.peers))}
+	targets := make(map[string]struct{}, len(r.seeds)+len(r.peers))
+	for _, seed := range r.seeds {
+		targets[seed] = struct{}{}
+	}
+	for _, peer := range r.peers {
+		msg.Nodes = append(msg.Nodes, peer)
+		targets[peer.GossipAddr] = struct{}{}
+	}
+	delete(targets, r.self.GossipAddr)
+	r.mu.Unlock()
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		r.logger.Error("Failed to encode gossip message", "error", err)
+		return
+	}
+	payload = r.sign(payload)
+	for target := range targets {
+		addr, err := net.ResolveUDPAddr("udp", target)
+		if err != nil {
+			r.logger.Warn("Failed to resolve gossip peer", "peer", target, "error", err)
+			continue
+		}
+		if _, err := r.conn.WriteToUDP(payload, addr); err != nil {
+			r.logger.Warn("Failed to gossip to peer", "peer", target, "error", err)
+		}
+	}
+}
+
+// receive merges incoming gossip until the socket is closed
+func (r *Registry) receive() {
+	buf := make([]byte, maxGossipPacket)
+	for {
+		n, source, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		payload, err := r.verify(buf[:n])
+		if err != nil {
+			r.logger.Warn("Ignoring unsigned gossip", "source", source.String(), "error", err)
+			continue
+		}
+		var msg gossipMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			r.logger.Warn("Ignoring malformed gossip", "source", source.String(), "error", err)
+			continue
+		}
+		msg.From.GossipAddr = reachableAddr(msg.From.GossipAddr, source)
+		r.merge(append(msg.Nodes, msg.From))
+	}
+}
+
+// sign prefixes payload with its HMAC under the shared secret
+func (r *Registry) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write(payload)
+	return append(mac.Sum(nil), payload...)
+}
+
+// verify checks a datagram's HMAC and returns the payload it signs
+func (r *Registry) verify(datagram []byte) ([]byte, error) {
+	if len(datagram) < sha256.Size {
+		return nil, errUnsignedGossip
+	}
+	signature, payload := datagram[:sha256.Size], datagram[sha256.Size:]
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errUnsignedGossip
+	}
+	return payload, nil
+}
+
+// merge records every node carrying newer liveness information than what is already known
+func (r *Registry) merge(nodes []NodeInfo) {
+	r.mu.Lock()
+	var removed []NodeInfo
+	now := r.now()
+	for _, node := range nodes {
+		if node.NodeID == "" || node.NodeID == r.self.NodeID {
+			continue
+		}
+		known, ok := r.peers[node.NodeID]
+		if ok && !node.newerThan(known) {
+			continue
+		}
+		if ok && known.Address != node.Address {
+			removed = append(removed, known)
+		}
+		node.LastSeen = now
+		r.peers[node.NodeID] = node
+	}
+	hooks := r.removedHooks
+	r.mu.Unlock()
+
+	notifyRemoved(hooks, removed)
+}
+
+// expire forgets peers whose heartbeat has not advanced within the TTL
+func (r *Registry) expire() {
+	r.mu.Lock()
+	var removed []NodeInfo
+	cutoff := r.now().Add(-r.ttl)
+	for id, peer := range r.peers {
+		if !peer.LastSeen.After(cutoff) {
+			delete(r.peers, id)
+			removed = append(removed, peer)
+		}
+	}
+	hooks := r.removedHooks
+	r.mu.Unlock()
+
+	notifyRemoved(hooks, removed)
+}
+
+// notifyRemoved runs every hook for each removed peer
+func notifyRemoved(hooks []func(NodeInfo), removed []NodeInfo) {
+	for _, peer := range removed {
+		for _, hook := range hooks {
+			hook(peer)
+		}
+	}
+}
+
+// reachableAddr fills in the host of a gossip address bound to all interfaces with the host the datagram came from
+func reachableAddr(gossipAddr string, source *net.UDPAddr) string {
+	host, port, err := net.SplitHostPort(gossipAddr)
+	if err != nil {
+		return source.String()
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		return net.JoinHostPort(source.IP.String(), port)
+	}
+	return gossipAddr
+}
@@ -0,0 +1,233 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+var testSecret = []byte("gossip-secret")
+
+func startNode(t *testing.T, nodeID string, ttl time.Duration, seeds ...string) (*Registry, context.CancelFunc) {
+	t.Helper()
+	return startNodeWithSecret(t, nodeID, testSecret, ttl, seeds...)
+}
+
+func startNodeWithSecret(t *testing.T, nodeID string, secret []byte, ttl time.Duration, seeds ...string) (*Registry, context.CancelFunc) {
+	t.Helper()
+	registry, err := NewRegistry(Config{
+		Self:     NodeInfo{NodeID: nodeID, Address: nodeID + ":9090", HealthURL: "http://" + nodeID + ":8080/health"},
+		Secret:   secret,
+		BindAddr: "127.0.0.1:0",
+		Seeds:    seeds,
+		Interval: 50 * time.Millisecond,
+		PeerTTL:  ttl,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go registry.Start(ctx)
+	t.Cleanup(cancel)
+	return registry, cancel
+}
+
+func peerIDs(r *Registry) []string {
+	var ids []string
+	for _, peer := range r.ListPeers() {
+		ids = append(ids, peer.NodeID)
+	}
+	return ids
+}
+
+func TestRegistryDiscoversPeers(t *testing.T) {
+	a, _ := startNode(t, "node-a", time.Second)
+	b, _ := startNode(t, "node-b", time.Second, a.Self().GossipAddr)
+
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"node-b"}, peerIDs(a)) &&
+			assert.ObjectsAreEqual([]string{"node-a"}, peerIDs(b))
+	}, time.Second, 10*time.Millisecond)
+
+	peer := a.ListPeers()[0]
+	assert.Equal(t, "node-b:9090", peer.Address)
+	assert.Equal(t, "http://node-b:8080/health", peer.HealthURL)
+	assert.Equal(t, b.Self().GossipAddr, peer.GossipAddr)
+}
+
+func TestRegistryLearnsPeersThroughGossip(t *testing.T) {
+	a, _ := startNode(t, "node-a", time.Second)
+	startNode(t, "node-b", time.Second, a.Self().GossipAddr)
+	c, _ := startNode(t, "node-c", time.Second, a.Self().GossipAddr)
+
+	// node-c only knows node-a, and hears about node-b from it
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"node-a", "node-b"}, peerIDs(c))
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRegistryForgetsStoppedPeers(t *testing.T) {
+	a, _ := startNode(t, "node-a", 200*time.Millisecond)
+	_, stopB := startNode(t, "node-b", 200*time.Millisecond, a.Self().GossipAddr)
+
+	require.Eventually(t, func() bool { return len(a.ListPeers()) == 1 }, time.Second, 10*time.Millisecond)
+
+	stopB()
+	require.Eventually(t, func() bool { return len(a.ListPeers()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRegistryAcceptsRestartedPeer(t *testing.T) {
+	registry := &Registry{self: NodeInfo{NodeID: "node-a"}, peers: make(map[string]NodeInfo), ttl: time.Minute, now: time.Now}
+
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "old:9090", Incarnation: 1, Heartbeat: 40}})
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "stale:9090", Incarnation: 1, Heartbeat: 39}})
+	assert.Equal(t, "old:9090", registry.ListPeers()[0].Address)
+
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "new:9090", Incarnation: 2, Heartbeat: 1}})
+	assert.Equal(t, "new:9090", registry.ListPeers()[0].Address)
+}
+
+func TestRegistryRequiresSecret(t *testing.T) {
+	_, err := NewRegistry(Config{Self: NodeInfo{NodeID: "node-a"}, BindAddr: "127.0.0.1:0"})
+	assert.Error(t, err)
+}
+
+func TestRegistryIgnoresGossipSignedWithAnotherSecret(t *testing.T) {
+	a, _ := startNode(t, "node-a", time.Second)
+	startNodeWithSecret(t, "intruder", []byte("another-secret"), time.Second, a.Self().GossipAddr)
+	startNode(t, "node-b", time.Second, a.Self().GossipAddr)
+
+	require.Eventually(t, func() bool { return len(a.ListPeers()) > 0 }, time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, []string{"node-b"}, peerIDs(a))
+}
+
+// recordingLogger keeps the messages of the warnings logged to it
+type recordingLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordingLogger) Warn(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, msg)
+}
+
+func (l *recordingLogger) Error(msg string, args ...any) {}
+
+func (l *recordingLogger) warned(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.warnings, msg)
+}
+
+func TestRegistryLogsRejectedGossip(t *testing.T) {
+	logger := &recordingLogger{}
+	registry, err := NewRegistry(Config{Self: NodeInfo{NodeID: "node-a"}, Secret: testSecret, BindAddr: "127.0.0.1:0", Logger: logger})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go registry.Start(ctx)
+
+	conn, err := net.Dial("udp", registry.Self().GossipAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(`{"from":{"node_id":"intruder"}}`))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return logger.warned("Ignoring unsigned gossip") }, time.Second, 10*time.Millisecond)
+}
+
+func TestRegistryVerifiesSignature(t *testing.T) {
+	registry := &Registry{secret: testSecret}
+	datagram := registry.sign([]byte(`{"from":{"node_id":"node-b"}}`))
+
+	payload, err := registry.verify(datagram)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"from":{"node_id":"node-b"}}`, string(payload))
+
+	datagram[len(datagram)-2] = 'c'
+	_, err = registry.verify(datagram)
+	assert.ErrorIs(t, err, errUnsignedGossip)
+
+	_, err = registry.verify([]byte(`{"from":{"node_id":"node-b"}}`))
+	assert.ErrorIs(t, err, errUnsignedGossip)
+}
+
+func TestRegistryReportsRemovedPeers(t *testing.T) {
+	now := time.Now()
+	registry := &Registry{self: NodeInfo{NodeID: "node-a"}, peers: make(map[string]NodeInfo), ttl: time.Minute, now: func() time.Time { return now }}
+	var removed []string
+	registry.OnPeerRemoved(func(peer NodeInfo) { removed = append(removed, peer.Address) })
+
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "old:9090", Incarnation: 1}, {NodeID: "node-c", Address: "c:9090", Incarnation: 1}})
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "new:9090", Incarnation: 2}})
+	assert.Equal(t, []string{"old:9090"}, removed, "a restart at a new address retires the old one")
+
+	now = now.Add(2 * time.Minute)
+	registry.merge([]NodeInfo{{NodeID: "node-b", Address: "new:9090", Incarnation: 2, Heartbeat: 1}})
+	registry.expire()
+	assert.Equal(t, []string{"old:9090", "c:9090"}, removed)
+	assert.Equal(t, []string{"node-b"}, peerIDs(registry))
+}
+
+type typingEventRecorder struct {
+	clusterpb.UnimplementedPeerForwardingServer
+	events chan *clusterpb.TypingEvent
+}
+
+func (r *typingEventRecorder) ForwardTypingEvent(ctx context.Context, event *clusterpb.TypingEvent) (*clusterpb.ForwardResponse, error) {
+	r.events <- event
+	return &clusterpb.ForwardResponse{}, nil
+}
+
+func TestForwardTypingEvent(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	recorder := &typingEventRecorder{events: make(chan *clusterpb.TypingEvent, 1)}
+	clusterpb.RegisterPeerForwardingServer(server, recorder)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	forwarder := NewForwarder()
+	defer forwarder.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = forwarder.ForwardTypingEvent(ctx, listener.Addr().String(), &clusterpb.TypingEvent{
+		ConversationId: "conv",
+		IsTyping:       true,
+		TotalMessages:  3,
+		OriginNodeId:   "node-a",
+	})
+	require.NoError(t, err)
+
+	event := <-recorder.events
+	assert.Equal(t, "conv", event.GetConversationId())
+	assert.True(t, event.GetIsTyping())
+	assert.Equal(t, int32(3), event.GetTotalMessages())
+	assert.Equal(t, "node-a", event.GetOriginNodeId())
+}
+
+func TestForwarderDropClosesConnection(t *testing.T) {
+	forwarder := NewForwarder()
+	defer forwarder.Close()
+
+	conn, err := forwarder.conn("127.0.0.1:1")
+	require.NoError(t, err)
+
+	require.NoError(t, forwarder.Drop("127.0.0.1:1"))
+	assert.Equal(t, connectivity.Shutdown, conn.GetState())
+	assert.Empty(t, forwarder.conns)
+	assert.NoError(t, forwarder.Drop("127.0.0.1:1"), "dropping an unknown peer is a no-op")
+}
@@ -0,0 +1,4 @@
+// Package clusterpb contains the generated gRPC bindings for forwarding state between server instances.
+package clusterpb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cluster.proto
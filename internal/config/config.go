@@ -8,13 +8,28 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Postgres PostgresConfig `mapstructure:"postgres"`
-	MongoDB  MongoConfig    `mapstructure:"mongodb"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	S3       S3Config       `mapstructure:"s3"`
-	Grok     GrokConfig     `mapstructure:"grok"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Postgres   PostgresConfig   `mapstructure:"postgres"`
+	MongoDB    MongoConfig      `mapstructure:"mongodb"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	S3         S3Config         `mapstructure:"s3"`
+	Grok       GrokConfig       `mapstructure:"grok"`
+	JWT        JWTConfig        `mapstructure:"jwt"`
+	AI         AIConfig         `mapstructure:"ai"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Webhook    WebhookConfig    `mapstructure:"webhook"`
+	Report     ReportConfig     `mapstructure:"report"`
+	Safety     SafetyConfig     `mapstructure:"safety"`
+	Moderation ModerationConfig `mapstructure:"moderation"`
+	TLS        TLSConfig        `mapstructure:"tls"`
+	Cluster    ClusterConfig    `mapstructure:"cluster"`
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+
+	Conversation      ConversationConfig      `mapstructure:"conversation"`
+	Privacy           PrivacyConfig           `mapstructure:"privacy"`
+	Export            ExportConfig            `mapstructure:"export"`
+	Analytics         AnalyticsConfig         `mapstructure:"analytics"`
+	EmotionVocabulary EmotionVocabularyConfig `mapstructure:"emotion_vocabulary"`
 }
 
 type ServerConfig struct {
@@ -22,6 +37,12 @@ type ServerConfig struct {
 	Environment  string `mapstructure:"environment"`
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
+	// GRPCPort enables the analytics ingestion and peer forwarding gRPC server; it requires the tls.grpc_* certificates
+	GRPCPort string `mapstructure:"grpc_port"`
+	// GRPCHost is the address the gRPC server binds to, empty for all interfaces
+	GRPCHost string `mapstructure:"grpc_host"`
+	// PublicURL is the externally reachable base URL, used in links to documents this server hosts
+	PublicURL string `mapstructure:"public_url"`
 }
 
 type PostgresConfig struct {
@@ -31,12 +52,15 @@ type PostgresConfig struct {
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
+
+	MaxOpenConns int `mapstructure:"max_open_conns"`
 }
 
 type MongoConfig struct {
 	URI            string `mapstructure:"uri"`
 	Database       string `mapstructure:"database"`
 	ConnectTimeout int    `mapstructure:"connect_timeout"`
+	MaxPoolSize    uint64 `mapstructure:"max_pool_size"` // fixed for the life of the client; zero keeps the URI or driver default
 }
 
 type RedisConfig struct {
@@ -60,6 +84,121 @@ type GrokConfig struct {
 	MaxTokens   int     `mapstructure:"max_tokens"`
 	Temperature float64 `mapstructure:"temperature"`
 	BaseURL     string  `mapstructure:"base_url"`
+
+	EmbeddingModel string `mapstructure:"embedding_model"`
+	EmbeddingURL   string `mapstructure:"embedding_url"`
+
+	BreakerFailureThreshold int `mapstructure:"breaker_failure_threshold"`
+	BreakerOpenTimeout      int `mapstructure:"breaker_open_timeout"`
+	BreakerHalfOpenInterval int `mapstructure:"breaker_half_open_interval"`
+
+	// Backends lists extra Grok endpoints to fail over between. When empty only the endpoint above is used.
+	Backends          []GrokBackend `mapstructure:"backends"`
+	FailoverErrorRate float64       `mapstructure:"failover_error_rate"`
+	FailoverCooldown  int           `mapstructure:"failover_cooldown"`
+}
+
+// GrokBackend is one Grok endpoint. Empty fields fall back to the values in GrokConfig.
+type GrokBackend struct {
+	Name         string `mapstructure:"name"`
+	APIKey       string `mapstructure:"api_key"`
+	BaseURL      string `mapstructure:"base_url"`
+	EmbeddingURL string `mapstructure:"embedding_url"`
+}
+
+type AIConfig struct {
+	MemoryDecayLambda        float64 `mapstructure:"memory_decay_lambda"`
+	MemoryDecayFactor        float64 `mapstructure:"memory_decay_factor"`
+	MinResponseIntervalMs    int     `mapstructure:"min_response_interval_ms"`
+	ProactiveInactivityHours int     `mapstructure:"proactive_inactivity_hours"`
+	ReintroductionGapDays    int     `mapstructure:"reintroduction_gap_days"`
+}
+
+// AnalyticsConfig configures message analytics. SupportedLanguages are the ISO 639-1 codes sentiment analysis
+// detects; messages detected below LanguageConfidenceThreshold are treated as English. Engagement scores of sessions
+// shorter than EngagementMidpointMinutes are mostly regressed toward 0.5, and EngagementSteepness sets how quickly
+// longer sessions keep their raw score.
+type AnalyticsConfig struct {
+	SupportedLanguages          []string `mapstructure:"supported_languages"`
+	LanguageConfidenceThreshold float64  `mapstructure:"language_confidence_threshold"`
+	EngagementMidpointMinutes   float64  `mapstructure:"engagement_midpoint_minutes"`
+	EngagementSteepness         float64  `mapstructure:"engagement_steepness"`
+}
+
+type RateLimitConfig struct {
+	Backend           string `mapstructure:"backend"`
+	RequestsPerMinute int    `mapstructure:"requests_per_minute"`
+	Burst             int    `mapstructure:"burst"`
+}
+
+type WebhookConfig struct {
+	Secret string `mapstructure:"secret"`
+}
+
+// SafetyConfig controls safety reviews and the topics the companion refuses to discuss. TopicBlocklist entries ending in
+// * block every topic starting with the rest of the entry; topics stored in the topic_blocklist collection are added.
+type SafetyConfig struct {
+	ReviewWebhookEnabled bool     `mapstructure:"review_webhook_enabled"`
+	TopicBlocklist       []string `mapstructure:"topic_blocklist"`
+}
+
+// ModerationConfig selects the content moderation filters applied to user messages, in order
+type ModerationConfig struct {
+	Filters []string `mapstructure:"filters"`
+}
+
+// TLSConfig holds the certificates used to serve HTTPS with mutual TLS, and the separate set the gRPC server
+// always requires client certificates against
+type TLSConfig struct {
+	MutualTLS      bool   `mapstructure:"mutual_tls"`
+	CertFile       string `mapstructure:"cert_file"`
+	KeyFile        string `mapstructure:"key_file"`
+	CACertFile     string `mapstructure:"ca_cert_file"`
+	GRPCCertFile   string `mapstructure:"grpc_cert_file"`
+	GRPCKeyFile    string `mapstructure:"grpc_key_file"`
+	GRPCCACertFile string `mapstructure:"grpc_ca_cert_file"`
+}
+
+// ClusterConfig controls membership gossip between server instances. Peers reach each other on AdvertiseHost at
+// the gossip and gRPC ports.
+type ClusterConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	NodeID        string   `mapstructure:"node_id"`        // defaults to the hostname
+	AdvertiseHost string   `mapstructure:"advertise_host"` // defaults to the hostname
+	GossipPort    string   `mapstructure:"gossip_port"`
+	Seeds         []string `mapstructure:"seeds"`  // gossip addresses (host:port) of instances to join through
+	Secret        string   `mapstructure:"secret"` // shared by every instance to sign gossip
+}
+
+// EncryptionConfig controls application-layer encryption of message text. The root key is a KMS data key, stored
+// encrypted and unwrapped with KMS at startup.
+type EncryptionConfig struct {
+	MessageEncryptionEnabled bool   `mapstructure:"message_encryption_enabled"`
+	KMSKeyID                 string `mapstructure:"kms_key_id"`
+	KMSRegion                string `mapstructure:"kms_region"`
+	EncryptedRootKey         string `mapstructure:"encrypted_root_key"` // base64 CiphertextBlob from KMS GenerateDataKey
+}
+
+// ConversationConfig controls long-term conversation storage
+type ConversationConfig struct {
+	ArchiveThreshold  int `mapstructure:"archive_threshold"`   // message count above which a conversation is archived to S3
+	DeletionGraceDays int `mapstructure:"deletion_grace_days"` // days a soft-deleted conversation is kept before it is purged
+	SoftLimit         int `mapstructure:"soft_limit"`          // message count above which archiving the conversation is suggested
+	HardLimit         int `mapstructure:"hard_limit"`          // message count at which new messages are refused
+}
+
+// PrivacyConfig holds the defaults applied to users who have not chosen their own privacy settings
+type PrivacyConfig struct {
+	DefaultRetentionDays int `mapstructure:"default_retention_days"`
+}
+
+// ExportConfig controls the user data export queue
+type ExportConfig struct {
+	Workers int `mapstructure:"workers"` // number of exports processed concurrently
+}
+
+type ReportConfig struct {
+	TemplatePath string `mapstructure:"template_path"`
 }
 
 type JWTConfig struct {
@@ -77,6 +216,21 @@ func Load() (*Config, error) {
 	viper.AddConfigPath("./config")
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.SetDefault("privacy.default_retention_days", 90)
+	viper.SetDefault("conversation.deletion_grace_days", 30)
+	viper.SetDefault("conversation.soft_limit", 500)
+	viper.SetDefault("conversation.hard_limit", 2000)
+	viper.SetDefault("export.workers", 3)
+	viper.SetDefault("ai.reintroduction_gap_days", 7)
+	viper.SetDefault("ai.memory_decay_factor", 0.9)
+	viper.SetDefault("analytics.supported_languages", []string{"en", "es", "fr", "de", "it", "pt", "ru", "zh", "ja", "ko"})
+	viper.SetDefault("analytics.language_confidence_threshold", 0.5)
+	viper.SetDefault("analytics.engagement_midpoint_minutes", 10)
+	viper.SetDefault("analytics.engagement_steepness", 0.3)
+	viper.SetDefault("safety.topic_blocklist", []string{})
+	viper.SetDefault("server.public_url", "http://localhost:8080")
+	viper.SetDefault("server.grpc_host", "")
+	viper.SetDefault("cluster.gossip_port", "7946")
 
 	if env := os.Getenv("CONFIG_FILE"); env != "" {
 		viper.SetConfigFile(env)
@@ -91,5 +245,9 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.EmotionVocabulary.load(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
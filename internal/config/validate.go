@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ConfigError describes a single invalid configuration field
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Validate checks the settings the server cannot run without and returns every invalid field, or nil if there are none
+func (c *Config) Validate() []ConfigError {
+	var errs []ConfigError
+	require := func(field, value string) {
+		if value == "" {
+			errs = append(errs, ConfigError{Field: field, Message: "must not be empty"})
+		}
+	}
+
+	require("postgres.host", c.Postgres.Host)
+	require("postgres.dbname", c.Postgres.DBName)
+	require("mongodb.uri", c.MongoDB.URI)
+	require("grok.api_key", c.Grok.APIKey)
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, ConfigError{Field: "server.port", Message: fmt.Sprintf("must be a port number between 1 and 65535, got %q", c.Server.Port)})
+	}
+
+	if days := c.Privacy.DefaultRetentionDays; days < 1 || days > 3650 {
+		errs = append(errs, ConfigError{Field: "privacy.default_retention_days", Message: fmt.Sprintf("must be between 1 and 3650, got %d", days)})
+	}
+
+	if c.Cluster.Enabled {
+		if c.Server.GRPCPort == "" {
+			errs = append(errs, ConfigError{Field: "server.grpc_port", Message: "must be set when cluster.enabled is true, peers forward events over gRPC"})
+		}
+		require("cluster.secret", c.Cluster.Secret)
+		if isLoopbackHost(c.Server.GRPCHost) {
+			errs = append(errs, ConfigError{Field: "server.grpc_host", Message: fmt.Sprintf("must not be a loopback address when cluster.enabled is true, peers dial the advertised host, got %q", c.Server.GRPCHost)})
+		}
+	}
+	// The gRPC server has no other authentication, so it always requires client certificates
+	if c.Server.GRPCPort != "" {
+		require("tls.grpc_cert_file", c.TLS.GRPCCertFile)
+		require("tls.grpc_key_file", c.TLS.GRPCKeyFile)
+		require("tls.grpc_ca_cert_file", c.TLS.GRPCCACertFile)
+	}
+
+	if c.Encryption.MessageEncryptionEnabled {
+		require("encryption.encrypted_root_key", c.Encryption.EncryptedRootKey)
+	}
+
+	return errs
+}
+
+// isLoopbackHost reports whether a bind address only accepts connections from the local machine
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Server:   ServerConfig{Port: "8080"},
+		Postgres: PostgresConfig{Host: "localhost", DBName: "lunaria"},
+		MongoDB:  MongoConfig{URI: "mongodb://localhost:27017"},
+		Grok:     GrokConfig{APIKey: "xai-key"},
+		Privacy:  PrivacyConfig{DefaultRetentionDays: 90},
+	}
+}
+
+// enableGRPC turns on the gRPC server with its own certificates, leaving HTTP mutual TLS off
+func enableGRPC(cfg *Config) {
+	cfg.Server.GRPCPort = "9090"
+	cfg.TLS.GRPCCertFile = "/etc/lunaria/tls/grpc.crt"
+	cfg.TLS.GRPCKeyFile = "/etc/lunaria/tls/grpc.key"
+	cfg.TLS.GRPCCACertFile = "/etc/lunaria/tls/grpc-ca.crt"
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		field  string
+	}{
+		{name: "valid", modify: func(cfg *Config) {}},
+		{name: "missing postgres host", modify: func(cfg *Config) { cfg.Postgres.Host = "" }, field: "postgres.host"},
+		{name: "missing postgres database", modify: func(cfg *Config) { cfg.Postgres.DBName = "" }, field: "postgres.dbname"},
+		{name: "missing mongodb uri", modify: func(cfg *Config) { cfg.MongoDB.URI = "" }, field: "mongodb.uri"},
+		{name: "missing grok api key", modify: func(cfg *Config) { cfg.Grok.APIKey = "" }, field: "grok.api_key"},
+		{name: "empty port", modify: func(cfg *Config) { cfg.Server.Port = "" }, field: "server.port"},
+		{name: "zero port", modify: func(cfg *Config) { cfg.Server.Port = "0" }, field: "server.port"},
+		{name: "port too large", modify: func(cfg *Config) { cfg.Server.Port = "65536" }, field: "server.port"},
+		{name: "non-numeric port", modify: func(cfg *Config) { cfg.Server.Port = "http" }, field: "server.port"},
+		{name: "lowest port", modify: func(cfg *Config) { cfg.Server.Port = "1" }},
+		{name: "highest port", modify: func(cfg *Config) { cfg.Server.Port = "65535" }},
+		{name: "zero retention days", modify: func(cfg *Config) { cfg.Privacy.DefaultRetentionDays = 0 }, field: "privacy.default_retention_days"},
+		{name: "negative retention days", modify: func(cfg *Config) { cfg.Privacy.DefaultRetentionDays = -30 }, field: "privacy.default_retention_days"},
+		{name: "retention days too large", modify: func(cfg *Config) { cfg.Privacy.DefaultRetentionDays = 3651 }, field: "privacy.default_retention_days"},
+		{name: "shortest retention", modify: func(cfg *Config) { cfg.Privacy.DefaultRetentionDays = 1 }},
+		{name: "longest retention", modify: func(cfg *Config) { cfg.Privacy.DefaultRetentionDays = 3650 }},
+		{name: "cluster without grpc port", modify: func(cfg *Config) {
+			cfg.Cluster.Enabled = true
+			cfg.Cluster.Secret = "gossip-secret"
+		}, field: "server.grpc_port"},
+		{name: "cluster without secret", modify: func(cfg *Config) {
+			enableGRPC(cfg)
+			cfg.Cluster.Enabled = true
+		}, field: "cluster.secret"},
+		{name: "cluster with loopback grpc host", modify: func(cfg *Config) {
+			enableGRPC(cfg)
+			cfg.Server.GRPCHost = "127.0.0.1"
+			cfg.Cluster.Enabled = true
+			cfg.Cluster.Secret = "gossip-secret"
+		}, field: "server.grpc_host"},
+		{name: "cluster with localhost grpc host", modify: func(cfg *Config) {
+			enableGRPC(cfg)
+			cfg.Server.GRPCHost = "localhost"
+			cfg.Cluster.Enabled = true
+			cfg.Cluster.Secret = "gossip-secret"
+		}, field: "server.grpc_host"},
+		{name: "cluster with internal grpc host", modify: func(cfg *Config) {
+			enableGRPC(cfg)
+			cfg.Server.GRPCHost = "10.0.0.5"
+			cfg.Cluster.Enabled = true
+			cfg.Cluster.Secret = "gossip-secret"
+		}},
+		{name: "loopback grpc host without cluster", modify: func(cfg *Config) { enableGRPC(cfg); cfg.Server.GRPCHost = "127.0.0.1" }},
+		{name: "encryption without root key", modify: func(cfg *Config) { cfg.Encryption.MessageEncryptionEnabled = true }, field: "encryption.encrypted_root_key"},
+		{name: "grpc port without grpc certificate", modify: func(cfg *Config) { enableGRPC(cfg); cfg.TLS.GRPCCertFile = "" }, field: "tls.grpc_cert_file"},
+		{name: "grpc port without grpc key", modify: func(cfg *Config) { enableGRPC(cfg); cfg.TLS.GRPCKeyFile = "" }, field: "tls.grpc_key_file"},
+		{name: "grpc port without grpc ca", modify: func(cfg *Config) { enableGRPC(cfg); cfg.TLS.GRPCCACertFile = "" }, field: "tls.grpc_ca_cert_file"},
+		{name: "grpc port without http mutual tls", modify: enableGRPC},
+		{name: "cluster with grpc port", modify: func(cfg *Config) {
+			enableGRPC(cfg)
+			cfg.Cluster.Enabled = true
+			cfg.Cluster.Secret = "gossip-secret"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			errs := cfg.Validate()
+			if tt.field == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.field, errs[0].Field)
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	errs := (&Config{}).Validate()
+
+	fields := make([]string, len(errs))
+	for i, err := range errs {
+		fields[i] = err.Field
+	}
+	assert.Equal(t, []string{"postgres.host", "postgres.dbname", "mongodb.uri", "grok.api_key", "server.port", "privacy.default_retention_days"}, fields)
+	assert.EqualError(t, errs[0], "postgres.host: must not be empty")
+}
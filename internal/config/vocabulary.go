@@ -0,0 +1,89 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//go:embed vocabulary/default.json
+var defaultVocabulary []byte
+
+// EmotionVocabularyConfig selects the emotion vocabulary file. An empty File uses the embedded default.
+type EmotionVocabularyConfig struct {
+	File       string            `mapstructure:"file"`
+	Vocabulary EmotionVocabulary `mapstructure:"-"`
+}
+
+// EmotionVocabulary holds the sentiment keywords and language detection hints for each supported language
+type EmotionVocabulary struct {
+	DefaultLanguage string               `json:"default_language"`
+	Languages       []LanguageVocabulary `json:"languages"`
+}
+
+// LanguageVocabulary describes one language. Languages are tried in order during detection: a text
+// matches if it contains any of Characters or any of Markers as a standalone word.
+type LanguageVocabulary struct {
+	Code       string   `json:"code"`
+	Characters string   `json:"characters,omitempty"`
+	Markers    []string `json:"markers,omitempty"`
+	Positive   []string `json:"positive"`
+	Negative   []string `json:"negative"`
+}
+
+// Language returns the vocabulary for code, if present
+func (v EmotionVocabulary) Language(code string) (LanguageVocabulary, bool) {
+	for _, lang := range v.Languages {
+		if lang.Code == code {
+			return lang, true
+		}
+	}
+	return LanguageVocabulary{}, false
+}
+
+// DefaultEmotionVocabulary returns the vocabulary embedded in the binary
+func DefaultEmotionVocabulary() EmotionVocabulary {
+	vocabulary, err := parseVocabulary(defaultVocabulary)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded emotion vocabulary: %v", err))
+	}
+	return vocabulary
+}
+
+// LoadVocabularyFromFile reads an emotion vocabulary from a JSON file
+func LoadVocabularyFromFile(path string) (EmotionVocabulary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EmotionVocabulary{}, fmt.Errorf("failed to read emotion vocabulary: %w", err)
+	}
+	return parseVocabulary(data)
+}
+
+// load fills Vocabulary from File, or from the embedded default when no file is configured
+func (c *EmotionVocabularyConfig) load() error {
+	if c.File == "" {
+		c.Vocabulary = DefaultEmotionVocabulary()
+		return nil
+	}
+	vocabulary, err := LoadVocabularyFromFile(c.File)
+	if err != nil {
+		return err
+	}
+	c.Vocabulary = vocabulary
+	return nil
+}
+
+func parseVocabulary(data []byte) (EmotionVocabulary, error) {
+	var vocabulary EmotionVocabulary
+	if err := json.Unmarshal(data, &vocabulary); err != nil {
+		return EmotionVocabulary{}, fmt.Errorf("failed to parse emotion vocabulary: %w", err)
+	}
+	if len(vocabulary.Languages) == 0 {
+		return EmotionVocabulary{}, fmt.Errorf("emotion vocabulary defines no languages")
+	}
+	if _, ok := vocabulary.Language(vocabulary.DefaultLanguage); !ok {
+		return EmotionVocabulary{}, fmt.Errorf("emotion vocabulary default language %q is not defined", vocabulary.DefaultLanguage)
+	}
+	return vocabulary, nil
+}
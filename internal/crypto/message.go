@@ -0,0 +1,130 @@
+// Package crypto encrypts message text at rest with AES-256-GCM under per-conversation keys derived from a
+// KMS-managed root key
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeySize is the length in bytes of root and derived keys, selecting AES-256
+const KeySize = 32
+
+// messageKeyInfo prefixes the conversation ID in the HKDF info so message keys cannot collide with keys derived
+// from the same root for another purpose
+const messageKeyInfo = "lunaria/message-text/v1/"
+
+// ErrDecrypt is returned when a ciphertext does not authenticate: it was tampered with, or was encrypted under
+// another key
+var ErrDecrypt = errors.New("message decryption failed")
+
+// EncryptMessage seals plaintext with AES-256-GCM under key, returning the ciphertext and the random nonce used
+func EncryptMessage(key []byte, plaintext string) (ciphertext, nonce []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nil, nonce, []byte(plaintext), nil), nonce, nil
+}
+
+// DecryptMessage opens a ciphertext produced by EncryptMessage, returning ErrDecrypt if it does not authenticate
+func DecryptMessage(key, ciphertext, nonce []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return "", fmt.Errorf("%w: invalid nonce length %d", ErrDecrypt, len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("message key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MessageCipher encrypts message text under a key derived for each conversation
+type MessageCipher struct {
+	rootKey []byte
+}
+
+// NewMessageCipher creates a cipher deriving conversation keys from rootKey
+func NewMessageCipher(rootKey []byte) (*MessageCipher, error) {
+	if len(rootKey) != KeySize {
+		return nil, fmt.Errorf("root key must be %d bytes, got %d", KeySize, len(rootKey))
+	}
+	return &MessageCipher{rootKey: rootKey}, nil
+}
+
+// ConversationKey derives the key for a conversation's messages with HKDF-SHA256, using the conversation ID as context
+func (c *MessageCipher) ConversationKey(conversationID string) ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, c.rootKey, nil, []byte(messageKeyInfo+conversationID)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive conversation key: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext under the conversation's key
+func (c *MessageCipher) Encrypt(conversationID, plaintext string) (ciphertext, nonce []byte, err error) {
+	key, err := c.ConversationKey(conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return EncryptMessage(key, plaintext)
+}
+
+// Decrypt opens a ciphertext sealed under the conversation's key
+func (c *MessageCipher) Decrypt(conversationID string, ciphertext, nonce []byte) (string, error) {
+	key, err := c.ConversationKey(conversationID)
+	if err != nil {
+		return "", err
+	}
+	return DecryptMessage(key, ciphertext, nonce)
+}
+
+// KMSDecrypter is the part of the KMS client used to unwrap the root key
+type KMSDecrypter interface {
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// DecryptRootKey unwraps a root key generated with KMS GenerateDataKey. keyID may be empty when the ciphertext was
+// produced with a symmetric key, since KMS then reads the key from the ciphertext.
+func DecryptRootKey(ctx context.Context, client KMSDecrypter, keyID string, encryptedKey []byte) ([]byte, error) {
+	input := &kms.DecryptInput{CiphertextBlob: encryptedKey}
+	if keyID != "" {
+		input.KeyId = &keyID
+	}
+	output, err := client.Decrypt(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt root key with KMS: %w", err)
+	}
+	if len(output.Plaintext) != KeySize {
+		return nil, fmt.Errorf("root key must be %d bytes, KMS returned %d", KeySize, len(output.Plaintext))
+	}
+	return output.Plaintext, nil
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(seed byte) []byte {
+	return bytes.Repeat([]byte{seed}, KeySize)
+}
+
+func TestEncryptMessageRoundTrip(t *testing.T) {
+	for _, plaintext := range []string{"", "hello", "I haven't told anyone this before… 🌙", string(bytes.Repeat([]byte("a"), 64<<10))} {
+		ciphertext, nonce, err := EncryptMessage(testKey(1), plaintext)
+		require.NoError(t, err)
+		if plaintext != "" {
+			assert.NotContains(t, string(ciphertext), plaintext)
+		}
+
+		decrypted, err := DecryptMessage(testKey(1), ciphertext, nonce)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	}
+}
+
+func TestEncryptMessageUsesFreshNonces(t *testing.T) {
+	first, firstNonce, err := EncryptMessage(testKey(1), "same text")
+	require.NoError(t, err)
+	second, secondNonce, err := EncryptMessage(testKey(1), "same text")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, firstNonce, secondNonce)
+	assert.NotEqual(t, first, second)
+}
+
+func TestDecryptMessageDetectsTampering(t *testing.T) {
+	ciphertext, nonce, err := EncryptMessage(testKey(1), "a sensitive disclosure")
+	require.NoError(t, err)
+
+	flip := func(b []byte, i int) []byte {
+		tampered := bytes.Clone(b)
+		tampered[i] ^= 0x01
+		return tampered
+	}
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+		nonce      []byte
+	}{
+		{name: "modified ciphertext", key: testKey(1), ciphertext: flip(ciphertext, 0), nonce: nonce},
+		{name: "modified tag", key: testKey(1), ciphertext: flip(ciphertext, len(ciphertext)-1), nonce: nonce},
+		{name: "truncated ciphertext", key: testKey(1), ciphertext: ciphertext[:len(ciphertext)-1], nonce: nonce},
+		{name: "modified nonce", key: testKey(1), ciphertext: ciphertext, nonce: flip(nonce, 0)},
+		{name: "short nonce", key: testKey(1), ciphertext: ciphertext, nonce: nonce[:4]},
+		{name: "wrong key", key: testKey(2), ciphertext: ciphertext, nonce: nonce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptMessage(tt.key, tt.ciphertext, tt.nonce)
+			assert.ErrorIs(t, err, ErrDecrypt)
+		})
+	}
+}
+
+func TestEncryptMessageRejectsShortKey(t *testing.T) {
+	_, _, err := EncryptMessage([]byte("too short"), "hello")
+	assert.Error(t, err)
+}
+
+func TestMessageCipherConversationKeys(t *testing.T) {
+	cipher, err := NewMessageCipher(testKey(7))
+	require.NoError(t, err)
+
+	first, err := cipher.ConversationKey("conversation-a")
+	require.NoError(t, err)
+	again, err := cipher.ConversationKey("conversation-a")
+	require.NoError(t, err)
+	other, err := cipher.ConversationKey("conversation-b")
+	require.NoError(t, err)
+
+	assert.Len(t, first, KeySize)
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first, other)
+
+	ciphertext, nonce, err := cipher.Encrypt("conversation-a", "hello")
+	require.NoError(t, err)
+	text, err := cipher.Decrypt("conversation-a", ciphertext, nonce)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", text)
+
+	// A message moved to another conversation no longer decrypts
+	_, err = cipher.Decrypt("conversation-b", ciphertext, nonce)
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+type fakeKMS struct {
+	plaintext []byte
+	input     *kms.DecryptInput
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.input = params
+	return &kms.DecryptOutput{Plaintext: f.plaintext}, nil
+}
+
+func TestDecryptRootKey(t *testing.T) {
+	client := &fakeKMS{plaintext: testKey(3)}
+
+	rootKey, err := DecryptRootKey(context.Background(), client, "alias/lunaria-messages", []byte("wrapped"))
+	require.NoError(t, err)
+	assert.Equal(t, testKey(3), rootKey)
+	assert.Equal(t, []byte("wrapped"), client.input.CiphertextBlob)
+	assert.Equal(t, "alias/lunaria-messages", *client.input.KeyId)
+
+	client.plaintext = []byte("short")
+	_, err = DecryptRootKey(context.Background(), client, "", []byte("wrapped"))
+	assert.Error(t, err)
+}
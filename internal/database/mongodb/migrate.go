@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -22,16 +23,34 @@ func RunMigrations(db *mongo.Database) error {
 			Keys:    bson.D{{Key: "created_at", Value: -1}},
 			Options: options.Index().SetName("idx_conversations_created_at"),
 		},
+		{
+			// Only soft-deleted conversations are indexed, for the purge job
+			Keys: bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("idx_conversations_deleted_at").
+				SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$type": "date"}}),
+		},
 	})
 	if err != nil {
 		log.Printf("MongoDB migration (conversations) failed: %v", err)
 		return err
 	}
 
-	// Messages
-	_, err = db.Collection("messages").Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: -1}},
-		Options: options.Index().SetName("idx_messages_conversation_created"),
+	// Messages, built in the background so existing reads are not blocked
+	_, err = db.Collection("messages").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("idx_messages_conversation_id").SetBackground(true),
+		},
+		{
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_messages_conversation_created").SetBackground(true),
+		},
+		{
+			// Only messages sent with an idempotency key take part in deduplication
+			Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "client_idempotency_key", Value: 1}},
+			Options: options.Index().SetName("idx_messages_conversation_idempotency_key").SetBackground(true).
+				SetUnique(true).SetPartialFilterExpression(bson.M{"client_idempotency_key": bson.M{"$exists": true}}),
+		},
 	})
 	if err != nil {
 		log.Printf("MongoDB migration (messages) failed: %v", err)
@@ -48,6 +67,159 @@ func RunMigrations(db *mongo.Database) error {
 		return err
 	}
 
+	// Dashboard collections, joined per relationship by the dashboard aggregation. The progress index used to be
+	// non-unique under another name, and MongoDB refuses a second index on the same keys, so the old one goes first.
+	_, err = db.Collection("user_progress").Indexes().DropOne(ctx, "idx_user_progress_user_companion")
+	if err != nil && !isIndexNotFound(err) {
+		log.Printf("MongoDB migration (user progress) failed: %v", err)
+		return err
+	}
+	_, err = db.Collection("user_progress").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}},
+		Options: options.Index().SetName("idx_user_progress_user_companion_unique").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (user progress) failed: %v", err)
+		return err
+	}
+
+	_, err = db.Collection("user_achievements").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}, {Key: "earned_at", Value: -1}},
+		Options: options.Index().SetName("idx_user_achievements_user_companion_earned"),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (user achievements) failed: %v", err)
+		return err
+	}
+
+	_, err = db.Collection("relationship_analytics").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}},
+		Options: options.Index().SetName("idx_relationship_analytics_user_companion"),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (relationship analytics) failed: %v", err)
+		return err
+	}
+
+	// Weekly health snapshots, one per relationship and week
+	_, err = db.Collection("weekly_health_snapshots").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}, {Key: "week_start", Value: -1}},
+		Options: options.Index().SetName("idx_health_snapshots_user_companion_week").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (health snapshots) failed: %v", err)
+		return err
+	}
+
+	// Consent audit log, read newest first per user
+	_, err = db.Collection("consent_audit_log").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("idx_consent_audit_user_created"),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (consent audit log) failed: %v", err)
+		return err
+	}
+
+	// Message reactions, one per user and message
+	_, err = db.Collection("message_reactions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "message_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("idx_message_reactions_message_user").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (message reactions) failed: %v", err)
+		return err
+	}
+
+	// Weekly reaction counts, one per companion, week and emoji
+	_, err = db.Collection("reaction_analytics").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "companion_id", Value: 1}, {Key: "week_start", Value: -1}, {Key: "emoji", Value: 1}},
+		Options: options.Index().SetName("idx_reaction_analytics_companion_week_emoji").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (reaction analytics) failed: %v", err)
+		return err
+	}
+
+	// Data export queue, claimed oldest first by status
+	_, err = db.Collection("export_jobs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+		Options: options.Index().SetName("idx_export_jobs_status_created"),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (export jobs) failed: %v", err)
+		return err
+	}
+
+	// One style guide per companion
+	_, err = db.Collection("conversation_style_guides").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "companion_id", Value: 1}},
+		Options: options.Index().SetName("idx_conversation_style_guides_companion").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (conversation style guides) failed: %v", err)
+		return err
+	}
+
+	// Experience gains, totalled per relationship over recent days
+	_, err = db.Collection("experience_gains").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("idx_experience_gains_user_companion_created"),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (experience gains) failed: %v", err)
+		return err
+	}
+
+	// One health alert state per relationship
+	_, err = db.Collection("health_alert_states").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}},
+		Options: options.Index().SetName("idx_health_alert_states_user_companion").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (health alert states) failed: %v", err)
+		return err
+	}
+
+	// One set of special dates per relationship
+	_, err = db.Collection("special_dates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}},
+		Options: options.Index().SetName("idx_special_dates_user_companion").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (special dates) failed: %v", err)
+		return err
+	}
+
+	// One session budget per user
+	_, err = db.Collection("session_budgets").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("idx_session_budgets_user").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (session budgets) failed: %v", err)
+		return err
+	}
+
+	// Conversations are started from a template by its template ID
+	_, err = db.Collection("conversation_templates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "template_id", Value: 1}},
+		Options: options.Index().SetName("idx_conversation_templates_template_id").SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("MongoDB migration (conversation templates) failed: %v", err)
+		return err
+	}
+
 	log.Println("MongoDB migrations applied successfully.")
 	return nil
 }
+
+// isIndexNotFound reports whether dropping an index failed only because it, or its collection, does not exist
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	return cmdErr.Code == 26 || cmdErr.Code == 27
+}
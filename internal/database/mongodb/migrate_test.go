@@ -0,0 +1,170 @@
+package mongodb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRunMigrationsMessageIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("creates background message indexes", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, RunMigrations(mt.DB))
+
+		conversations := mt.GetStartedEvent()
+		conversationIndexes, err := conversations.Command.Lookup("indexes").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, conversationIndexes, 3)
+		byDeleted := conversationIndexes[2].Document()
+		assert.Equal(t, "idx_conversations_deleted_at", byDeleted.Lookup("name").StringValue())
+		assert.Equal(t, "date", byDeleted.Lookup("partialFilterExpression", "deleted_at", "$type").StringValue())
+
+		messages := mt.GetStartedEvent()
+		require.Equal(t, "createIndexes", messages.CommandName)
+		assert.Equal(t, "messages", messages.Command.Lookup("createIndexes").StringValue())
+
+		indexes, err := messages.Command.Lookup("indexes").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, indexes, 3)
+
+		byID := indexes[0].Document()
+		assert.Equal(t, "idx_messages_conversation_id", byID.Lookup("name").StringValue())
+		assert.Equal(t, int32(-1), byID.Lookup("key", "_id").Int32())
+		assert.True(t, byID.Lookup("background").Boolean())
+
+		byCreated := indexes[1].Document()
+		assert.Equal(t, "idx_messages_conversation_created", byCreated.Lookup("name").StringValue())
+		assert.Equal(t, int32(-1), byCreated.Lookup("key", "created_at").Int32())
+		assert.True(t, byCreated.Lookup("background").Boolean())
+
+		byIdempotencyKey := indexes[2].Document()
+		assert.Equal(t, "idx_messages_conversation_idempotency_key", byIdempotencyKey.Lookup("name").StringValue())
+		assert.True(t, byIdempotencyKey.Lookup("unique").Boolean())
+	})
+}
+
+func TestRunMigrationsUserProgressIndex(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("replaces the old index with a unique one", func(mt *mtest.T) {
+		responses := []bson.D{mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 27, Name: "IndexNotFound", Message: "index not found"})}
+		for i := 0; i < 14; i++ {
+			responses = append(responses, mtest.CreateSuccessResponse())
+		}
+		mt.AddMockResponses(responses...)
+
+		require.NoError(t, RunMigrations(mt.DB), "a deployment without the old index still migrates")
+
+		for i := 0; i < 3; i++ {
+			mt.GetStartedEvent()
+		}
+		dropped := mt.GetStartedEvent()
+		require.Equal(t, "dropIndexes", dropped.CommandName)
+		assert.Equal(t, "idx_user_progress_user_companion", dropped.Command.Lookup("index").StringValue())
+
+		created := mt.GetStartedEvent()
+		require.Equal(t, "createIndexes", created.CommandName)
+		index := created.Command.Lookup("indexes").Array().Index(0).Value().Document()
+		assert.Equal(t, "idx_user_progress_user_companion_unique", index.Lookup("name").StringValue())
+		assert.True(t, index.Lookup("unique").Boolean())
+	})
+}
+
+// TestListMessagesQueryUsesIndex runs against a real MongoDB when MONGODB_URI is set
+func TestListMessagesQueryUsesIndex(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB not reachable: %v", err)
+	}
+
+	db := client.Database("lunaria_migrate_test_" + primitive.NewObjectID().Hex())
+	defer db.Drop(context.Background())
+
+	require.NoError(t, RunMigrations(db))
+
+	conversationID := primitive.NewObjectID()
+	var docs []any
+	for i := 0; i < 100; i++ {
+		docs = append(docs, bson.M{"conversation_id": conversationID, "created_at": time.Now()})
+	}
+	_, err = db.Collection("messages").InsertMany(ctx, docs)
+	require.NoError(t, err)
+
+	var explain bson.M
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "find", Value: "messages"},
+			{Key: "filter", Value: bson.M{"conversation_id": conversationID}},
+			{Key: "sort", Value: bson.M{"_id": -1}},
+			{Key: "limit", Value: 20},
+		}},
+	}).Decode(&explain)
+	require.NoError(t, err)
+
+	winningPlan := explain["queryPlanner"].(bson.M)["winningPlan"]
+	stages := planValues(winningPlan, "stage")
+	assert.Contains(t, stages, "IXSCAN")
+	assert.NotContains(t, stages, "COLLSCAN")
+	assert.Equal(t, []string{"idx_messages_conversation_id"}, planValues(winningPlan, "indexName"))
+}
+
+// planValues collects every string value of key in an explain plan tree, such as the stage or index names
+func planValues(plan any, key string) []string {
+	var values []string
+	switch v := plan.(type) {
+	case bson.M:
+		if value, ok := v[key].(string); ok {
+			values = append(values, value)
+		}
+		for _, child := range v {
+			values = append(values, planValues(child, key)...)
+		}
+	case bson.A:
+		for _, child := range v {
+			values = append(values, planValues(child, key)...)
+		}
+	}
+	return values
+}
@@ -2,9 +2,11 @@ package mongodb
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -12,12 +14,18 @@ import (
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+	Pool     *PoolMonitor
 }
 
 func NewMongoConnection(cfg config.MongoConfig) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ConnectTimeout)*time.Second)
 	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	pool := NewPoolMonitor()
+	opts := options.Client().ApplyURI(cfg.URI).SetPoolMonitor(pool.EventMonitor()).SetMonitor(NewCommandTracer())
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +35,7 @@ func NewMongoConnection(cfg config.MongoConfig) (*MongoDB, error) {
 	return &MongoDB{
 		Client:   client,
 		Database: client.Database(cfg.Database),
+		Pool:     pool,
 	}, nil
 }
 
@@ -35,3 +44,17 @@ func (m *MongoDB) Close() error {
 	defer cancel()
 	return m.Client.Disconnect(ctx)
 }
+
+// ServerVersion returns the major and minor version of the MongoDB server
+func ServerVersion(ctx context.Context, db *mongo.Database) (major, minor int, err error) {
+	var info struct {
+		VersionArray []int `bson:"versionArray"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info); err != nil {
+		return 0, 0, fmt.Errorf("failed to get server version: %w", err)
+	}
+	if len(info.VersionArray) < 2 {
+		return 0, 0, fmt.Errorf("unexpected server version %v", info.VersionArray)
+	}
+	return info.VersionArray[0], info.VersionArray[1], nil
+}
@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// PoolStats is a snapshot of the MongoDB connection pools across all servers
+type PoolStats struct {
+	OpenConnections  int
+	InUse            int
+	Idle             int
+	MaxConnections   int
+	CheckoutFailures int64
+}
+
+// Utilisation returns the share of the maximum pool size currently checked out, or 0 if unknown
+func (s PoolStats) Utilisation() float64 {
+	if s.MaxConnections <= 0 {
+		return 0
+	}
+	return float64(s.InUse) / float64(s.MaxConnections)
+}
+
+// PoolMonitor tracks connection pool usage from driver pool events
+type PoolMonitor struct {
+	mu               sync.Mutex
+	maxPoolSizes     map[string]uint64
+	open             int
+	inUse            int
+	checkoutFailures int64
+}
+
+// NewPoolMonitor creates an empty pool monitor
+func NewPoolMonitor() *PoolMonitor {
+	return &PoolMonitor{maxPoolSizes: make(map[string]uint64)}
+}
+
+// EventMonitor returns the driver monitor to register with options.Client().SetPoolMonitor
+func (m *PoolMonitor) EventMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.HandleEvent}
+}
+
+// HandleEvent updates the counters for a single pool event
+func (m *PoolMonitor) HandleEvent(evt *event.PoolEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch evt.Type {
+	case event.PoolCreated:
+		if evt.PoolOptions != nil {
+			m.maxPoolSizes[evt.Address] = evt.PoolOptions.MaxPoolSize
+		}
+	case event.PoolClosedEvent:
+		delete(m.maxPoolSizes, evt.Address)
+	case event.ConnectionCreated:
+		m.open++
+	case event.ConnectionClosed:
+		m.open--
+	case event.GetSucceeded:
+		m.inUse++
+	case event.ConnectionReturned:
+		m.inUse--
+	case event.GetFailed:
+		m.checkoutFailures++
+	}
+}
+
+// Stats returns the current pool usage
+func (m *PoolMonitor) Stats() PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	maxConnections := 0
+	for _, size := range m.maxPoolSizes {
+		maxConnections += int(size)
+	}
+
+	return PoolStats{
+		OpenConnections:  m.open,
+		InUse:            m.inUse,
+		Idle:             max(m.open-m.inUse, 0),
+		MaxConnections:   maxConnections,
+		CheckoutFailures: m.checkoutFailures,
+	}
+}
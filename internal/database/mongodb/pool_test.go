@@ -0,0 +1,36 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestPoolMonitor(t *testing.T) {
+	monitor := NewPoolMonitor()
+	events := []*event.PoolEvent{
+		{Type: event.PoolCreated, Address: "db-1:27017", PoolOptions: &event.MonitorPoolOptions{MaxPoolSize: 10}},
+		{Type: event.PoolCreated, Address: "db-2:27017", PoolOptions: &event.MonitorPoolOptions{MaxPoolSize: 10}},
+		{Type: event.ConnectionCreated, Address: "db-1:27017"},
+		{Type: event.ConnectionCreated, Address: "db-1:27017"},
+		{Type: event.ConnectionCreated, Address: "db-2:27017"},
+		{Type: event.GetSucceeded, Address: "db-1:27017"},
+		{Type: event.GetSucceeded, Address: "db-1:27017"},
+		{Type: event.GetSucceeded, Address: "db-2:27017"},
+		{Type: event.ConnectionReturned, Address: "db-1:27017"},
+		{Type: event.GetFailed, Address: "db-2:27017"},
+	}
+	for _, evt := range events {
+		monitor.HandleEvent(evt)
+	}
+
+	stats := monitor.Stats()
+	assert.Equal(t, PoolStats{OpenConnections: 3, InUse: 2, Idle: 1, MaxConnections: 20, CheckoutFailures: 1}, stats)
+	assert.InDelta(t, 0.1, stats.Utilisation(), 1e-9)
+
+	monitor.HandleEvent(&event.PoolEvent{Type: event.ConnectionClosed, Address: "db-2:27017"})
+	monitor.HandleEvent(&event.PoolEvent{Type: event.PoolClosedEvent, Address: "db-2:27017"})
+	assert.Equal(t, 10, monitor.Stats().MaxConnections)
+	assert.Zero(t, PoolStats{InUse: 3}.Utilisation())
+}
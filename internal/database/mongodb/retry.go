@@ -0,0 +1,72 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	retryBaseDelay  = 100 * time.Millisecond
+	retryMultiplier = 2
+	retryMaxDelay   = 5 * time.Second
+	retryJitter     = 0.2
+)
+
+// WithRetry runs fn up to maxAttempts times, backing off exponentially with jitter between retriable failures. Inside
+// a session, e.g. within a transaction, fn runs once: session.WithTransaction retries the whole transaction, and
+// replaying a single statement after a transient error would run it against an aborted transaction.
+func WithRetry(ctx context.Context, maxAttempts int, fn func() error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn()
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err = fn(); err == nil || !IsRetriable(err) {
+			return err
+		}
+		if attempt == maxAttempts-1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+	return err
+}
+
+// IsRetriable reports whether err is a transient network or server selection failure
+func IsRetriable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var selectionErr topology.ServerSelectionError
+	if errors.As(err, &selectionErr) {
+		return true
+	}
+
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err)
+}
+
+// retryDelay returns the backoff before the retry following the given zero-based attempt
+func retryDelay(attempt int) time.Duration {
+	delay := float64(retryBaseDelay)
+	for i := 0; i < attempt; i++ {
+		delay *= retryMultiplier
+	}
+	if delay > float64(retryMaxDelay) {
+		delay = float64(retryMaxDelay)
+	}
+
+	jitter := 1 + retryJitter*(2*rand.Float64()-1)
+	return time.Duration(delay * jitter)
+}
@@ -0,0 +1,91 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errTransient = mongo.CommandError{Code: 6, Message: "connection reset", Labels: []string{"NetworkError"}}
+
+func TestWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	calls := 0
+	err := WithRetry(context.Background(), 3, func() error {
+		calls++
+		if calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestWithRetryStopsOnPermanentError(t *testing.T) {
+	calls := 0
+	permanent := errors.New("duplicate key")
+	err := WithRetry(context.Background(), 3, func() error {
+		calls++
+		return permanent
+	})
+
+	assert.ErrorIs(t, err, permanent)
+	assert.Equal(t, 1, calls)
+}
+
+func TestWithRetryReturnsLastErrorWhenExhausted(t *testing.T) {
+	calls := 0
+	err := WithRetry(context.Background(), 2, func() error {
+		calls++
+		return errTransient
+	})
+
+	assert.True(t, mongo.IsNetworkError(err))
+	assert.Equal(t, 2, calls)
+}
+
+func TestWithRetryRunsOnceInsideASession(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+
+	session, err := client.StartSession()
+	require.NoError(t, err)
+	defer session.EndSession(context.Background())
+
+	calls := 0
+	err = WithRetry(mongo.NewSessionContext(context.Background(), session), 3, func() error {
+		calls++
+		return errTransient
+	})
+
+	assert.True(t, mongo.IsNetworkError(err))
+	assert.Equal(t, 1, calls, "the transaction retries as a whole")
+}
+
+func TestRetryDelayBounds(t *testing.T) {
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{attempt: 0, base: 100 * time.Millisecond},
+		{attempt: 1, base: 200 * time.Millisecond},
+		{attempt: 3, base: 800 * time.Millisecond},
+		{attempt: 10, base: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			delay := retryDelay(tt.attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(float64(tt.base)*0.8))
+			assert.LessOrEqual(t, delay, time.Duration(float64(tt.base)*1.2))
+		}
+	}
+}
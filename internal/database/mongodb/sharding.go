@@ -0,0 +1,189 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Sharding readiness problems
+const (
+	// ShardingMissingIndex means no index covers the shard key field
+	ShardingMissingIndex = "missing_shard_key_index"
+	// ShardingNotPrefix means indexes include the shard key fields, but none starts with them
+	ShardingNotPrefix = "shard_key_not_index_prefix"
+	// ShardingUniqueIndex means a unique index does not start with the shard key, which sharding cannot enforce
+	ShardingUniqueIndex = "unique_index_without_shard_key_prefix"
+)
+
+// ShardKey is the key a collection is intended to be sharded on
+type ShardKey struct {
+	Collection string
+	Fields     []string
+}
+
+// AppShardKeys are the intended shard keys of the collections the app uses. User data is sharded by user so a
+// user's reads stay on one shard, message data by conversation, and companion data by companion.
+var AppShardKeys = []ShardKey{
+	{Collection: "conversations", Fields: []string{"user_id"}},
+	{Collection: "messages", Fields: []string{"conversation_id"}},
+	{Collection: "conversation_contexts", Fields: []string{"conversation_id"}},
+	{Collection: "conversation_summaries", Fields: []string{"conversation_id"}},
+	{Collection: "ai_memories", Fields: []string{"conversation_id"}},
+	{Collection: "response_quality", Fields: []string{"conversation_id"}},
+	{Collection: "message_reactions", Fields: []string{"message_id"}},
+	{Collection: "user_engagement_analytics", Fields: []string{"user_id"}},
+	{Collection: "relationship_analytics", Fields: []string{"user_id"}},
+	{Collection: "weekly_health_snapshots", Fields: []string{"user_id"}},
+	{Collection: "health_alert_states", Fields: []string{"user_id"}},
+	{Collection: "experience_gains", Fields: []string{"user_id"}},
+	{Collection: "user_progress", Fields: []string{"user_id"}},
+	{Collection: "user_achievements", Fields: []string{"user_id"}},
+	{Collection: "user_privacy_settings", Fields: []string{"user_id"}},
+	{Collection: "consent_audit_log", Fields: []string{"user_id"}},
+	{Collection: "special_dates", Fields: []string{"user_id"}},
+	{Collection: "session_budgets", Fields: []string{"user_id"}},
+	{Collection: "export_jobs", Fields: []string{"user_id"}},
+	{Collection: "notifications", Fields: []string{"user_id"}},
+	{Collection: "companion_profiles", Fields: []string{"companion_id"}},
+	{Collection: "conversation_style_guides", Fields: []string{"companion_id"}},
+	{Collection: "reaction_analytics", Fields: []string{"companion_id"}},
+}
+
+// ShardingIssue is a reason a collection cannot yet be sharded on its intended key
+type ShardingIssue struct {
+	Collection string   `json:"collection"`
+	ShardKey   []string `json:"shard_key"`
+	Problem    string   `json:"problem"`
+	Index      string   `json:"index,omitempty"`
+	Detail     string   `json:"detail"`
+}
+
+func (i ShardingIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Collection, i.Detail)
+}
+
+// indexSpec is the part of an index definition that decides whether it supports a shard key
+type indexSpec struct {
+	Name   string `bson:"name"`
+	Key    bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+}
+
+// ShardingReadinessCheck reports the collections in AppShardKeys whose indexes would make sh.shardCollection() fail on
+// the intended shard key. Collections that do not exist yet are skipped, since sharding an empty collection creates
+// the shard key index.
+func ShardingReadinessCheck(ctx context.Context, db *mongo.Database) ([]ShardingIssue, error) {
+	return checkShardKeys(ctx, db, AppShardKeys)
+}
+
+func checkShardKeys(ctx context.Context, db *mongo.Database, keys []ShardKey) ([]ShardingIssue, error) {
+	issues := []ShardingIssue{}
+	for _, key := range keys {
+		indexes, err := listIndexSpecs(ctx, db.Collection(key.Collection))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexes of %s: %w", key.Collection, err)
+		}
+		if len(indexes) == 0 {
+			// Every existing collection has an _id index; the driver lists none for a missing collection
+			continue
+		}
+		issues = append(issues, shardKeyIssues(key, indexes)...)
+	}
+	return issues, nil
+}
+
+func listIndexSpecs(ctx context.Context, collection *mongo.Collection) ([]indexSpec, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []indexSpec
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+// shardKeyIssues checks a collection's indexes against its shard key. Sharding needs an index whose key starts with
+// the shard key fields, and every unique index other than _id must start with them too.
+func shardKeyIssues(key ShardKey, indexes []indexSpec) []ShardingIssue {
+	var issues []ShardingIssue
+	shardKey := strings.Join(key.Fields, ", ")
+
+	supported := false
+	var containing []string
+	for _, index := range indexes {
+		if hasPrefix(index.Key, key.Fields) {
+			supported = true
+			continue
+		}
+		if containsFields(index.Key, key.Fields) {
+			containing = append(containing, index.Name)
+		}
+		if index.Unique && index.Name != "_id_" {
+			issues = append(issues, ShardingIssue{
+				Collection: key.Collection,
+				ShardKey:   key.Fields,
+				Problem:    ShardingUniqueIndex,
+				Index:      index.Name,
+				Detail:     fmt.Sprintf("unique index %s does not start with the shard key (%s)", index.Name, shardKey),
+			})
+		}
+	}
+
+	switch {
+	case supported:
+	case len(containing) > 0:
+		issues = append(issues, ShardingIssue{
+			Collection: key.Collection,
+			ShardKey:   key.Fields,
+			Problem:    ShardingNotPrefix,
+			Index:      containing[0],
+			Detail:     fmt.Sprintf("indexes %s include the shard key (%s) but do not start with it", strings.Join(containing, ", "), shardKey),
+		})
+	default:
+		issues = append(issues, ShardingIssue{
+			Collection: key.Collection,
+			ShardKey:   key.Fields,
+			Problem:    ShardingMissingIndex,
+			Detail:     fmt.Sprintf("no index on the shard key (%s)", shardKey),
+		})
+	}
+	return issues
+}
+
+// hasPrefix reports whether the index key starts with fields, in order
+func hasPrefix(indexKey bson.D, fields []string) bool {
+	if len(indexKey) < len(fields) {
+		return false
+	}
+	for i, field := range fields {
+		if indexKey[i].Key != field {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFields reports whether the index key includes every one of fields, in any position
+func containsFields(indexKey bson.D, fields []string) bool {
+	for _, field := range fields {
+		found := false
+		for _, elem := range indexKey {
+			if elem.Key == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
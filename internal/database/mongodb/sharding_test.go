@@ -0,0 +1,164 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func index(name string, unique bool, fields ...string) indexSpec {
+	key := bson.D{}
+	for _, field := range fields {
+		key = append(key, bson.E{Key: field, Value: 1})
+	}
+	return indexSpec{Name: name, Key: key, Unique: unique}
+}
+
+var idIndex = index("_id_", false, "_id")
+
+func TestShardKeyIssues(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          ShardKey
+		indexes      []indexSpec
+		wantProblems []string
+		wantIndexes  []string
+	}{
+		{
+			name:    "single field index",
+			key:     ShardKey{Collection: "session_budgets", Fields: []string{"user_id"}},
+			indexes: []indexSpec{idIndex, index("idx_session_budgets_user", true, "user_id")},
+		},
+		{
+			name:    "compound index with the shard key as prefix",
+			key:     ShardKey{Collection: "conversations", Fields: []string{"user_id"}},
+			indexes: []indexSpec{idIndex, index("idx_conversations_user_companion", false, "user_id", "companion_id", "last_activity")},
+		},
+		{
+			name: "hashed shard key index",
+			key:  ShardKey{Collection: "messages", Fields: []string{"conversation_id"}},
+			indexes: []indexSpec{idIndex, {
+				Name: "idx_messages_conversation_hashed",
+				Key:  bson.D{{Key: "conversation_id", Value: "hashed"}},
+			}},
+		},
+		{
+			name: "compound shard key",
+			key:  ShardKey{Collection: "relationship_analytics", Fields: []string{"user_id", "companion_id"}},
+			indexes: []indexSpec{
+				idIndex,
+				index("idx_relationship_user_companion", true, "user_id", "companion_id"),
+			},
+		},
+		{
+			name:         "only the _id index",
+			key:          ShardKey{Collection: "notifications", Fields: []string{"user_id"}},
+			indexes:      []indexSpec{idIndex},
+			wantProblems: []string{ShardingMissingIndex},
+			wantIndexes:  []string{""},
+		},
+		{
+			name:         "indexes on other fields",
+			key:          ShardKey{Collection: "export_jobs", Fields: []string{"user_id"}},
+			indexes:      []indexSpec{idIndex, index("idx_export_jobs_status_created", false, "status", "created_at")},
+			wantProblems: []string{ShardingMissingIndex},
+			wantIndexes:  []string{""},
+		},
+		{
+			name:         "shard key is not the index prefix",
+			key:          ShardKey{Collection: "consent_audit_log", Fields: []string{"user_id"}},
+			indexes:      []indexSpec{idIndex, index("idx_created_user", false, "created_at", "user_id")},
+			wantProblems: []string{ShardingNotPrefix},
+			wantIndexes:  []string{"idx_created_user"},
+		},
+		{
+			name:         "compound shard key fields out of order",
+			key:          ShardKey{Collection: "relationship_analytics", Fields: []string{"user_id", "companion_id"}},
+			indexes:      []indexSpec{idIndex, index("idx_companion_user", false, "companion_id", "user_id")},
+			wantProblems: []string{ShardingNotPrefix},
+			wantIndexes:  []string{"idx_companion_user"},
+		},
+		{
+			name: "unique index without the shard key prefix",
+			key:  ShardKey{Collection: "message_reactions", Fields: []string{"message_id"}},
+			indexes: []indexSpec{
+				idIndex,
+				index("idx_message_reactions_message", false, "message_id"),
+				index("idx_message_reactions_user_message", true, "user_id", "message_id"),
+			},
+			wantProblems: []string{ShardingUniqueIndex},
+			wantIndexes:  []string{"idx_message_reactions_user_message"},
+		},
+		{
+			name: "unique index is the only index on the shard key",
+			key:  ShardKey{Collection: "special_dates", Fields: []string{"user_id"}},
+			indexes: []indexSpec{
+				idIndex,
+				index("idx_special_dates_companion_user", true, "companion_id", "user_id"),
+			},
+			wantProblems: []string{ShardingUniqueIndex, ShardingNotPrefix},
+			wantIndexes:  []string{"idx_special_dates_companion_user", "idx_special_dates_companion_user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := shardKeyIssues(tt.key, tt.indexes)
+
+			var problems, indexes []string
+			for _, issue := range issues {
+				assert.Equal(t, tt.key.Collection, issue.Collection)
+				assert.Equal(t, tt.key.Fields, issue.ShardKey)
+				assert.NotEmpty(t, issue.Detail)
+				problems = append(problems, issue.Problem)
+				indexes = append(indexes, issue.Index)
+			}
+			assert.Equal(t, tt.wantProblems, problems)
+			assert.Equal(t, tt.wantIndexes, indexes)
+		})
+	}
+}
+
+func TestShardingReadinessCheck(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("lists the indexes of every collection", func(mt *mtest.T) {
+		keys := []ShardKey{
+			{Collection: "conversations", Fields: []string{"user_id"}},
+			{Collection: "export_jobs", Fields: []string{"user_id"}},
+			{Collection: "notifications", Fields: []string{"user_id"}},
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch,
+				bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+				bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "user_id", Value: 1}, {Key: "companion_id", Value: 1}}}, {Key: "name", Value: "idx_conversations_user_companion"}},
+			),
+			mtest.CreateCursorResponse(0, "lunaria.export_jobs", mtest.FirstBatch,
+				bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+				bson.D{{Key: "v", Value: 2}, {Key: "key", Value: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}}, {Key: "name", Value: "idx_export_jobs_status_created"}},
+			),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 26, Name: "NamespaceNotFound", Message: "ns does not exist"}),
+		)
+
+		issues, err := checkShardKeys(context.Background(), mt.DB, keys)
+		require.NoError(t, err)
+		require.Len(t, issues, 1, "collections that do not exist yet are skipped")
+		assert.Equal(t, "export_jobs", issues[0].Collection)
+		assert.Equal(t, ShardingMissingIndex, issues[0].Problem)
+
+		for _, event := range mt.GetAllStartedEvents() {
+			assert.Equal(t, "listIndexes", event.CommandName)
+		}
+	})
+
+	mt.Run("fails on other errors", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}))
+
+		_, err := checkShardKeys(context.Background(), mt.DB, []ShardKey{{Collection: "messages", Fields: []string{"conversation_id"}}})
+		assert.ErrorContains(t, err, "messages")
+	})
+}
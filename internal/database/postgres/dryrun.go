@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Execer is the subset of *sql.DB used to apply migrations
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// DryRunDB logs statements instead of executing them, inside a transaction that is always rolled back
+type DryRunDB struct {
+	tx  *sql.Tx
+	out io.Writer
+}
+
+// NewDryRunDB opens the transaction backing a dry run
+func NewDryRunDB(ctx context.Context, db *sql.DB, out io.Writer) (*DryRunDB, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin dry run transaction: %w", err)
+	}
+	return &DryRunDB{tx: tx, out: out}, nil
+}
+
+// ExecContext prints the statement and reports zero affected rows
+func (d *DryRunDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	statement := strings.TrimSpace(query)
+	if len(args) > 0 {
+		fmt.Fprintf(d.out, "%s -- args: %v\n", statement, args)
+	} else {
+		fmt.Fprintln(d.out, statement)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// QueryContext runs read queries inside the dry run transaction
+func (d *DryRunDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a single-row read query inside the dry run transaction
+func (d *DryRunDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.tx.QueryRowContext(ctx, query, args...)
+}
+
+// Close rolls the transaction back
+func (d *DryRunDB) Close() error {
+	if err := d.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("failed to roll back dry run transaction: %w", err)
+	}
+	fmt.Fprintln(d.out, "DRY RUN COMPLETE – no changes made.")
+	return nil
+}
+
+// isDryRun reports whether db only logs statements
+func isDryRun(db Execer) bool {
+	_, ok := db.(*DryRunDB)
+	return ok
+}
+
+// WithDryRun runs fn against a DryRunDB when dryRun is set, and against db otherwise
+func WithDryRun(ctx context.Context, db *sql.DB, dryRun bool, out io.Writer, fn func(Execer) error) error {
+	if !dryRun {
+		return fn(db)
+	}
+
+	dryRunDB, err := NewDryRunDB(ctx, db, out)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn(dryRunDB)
+	if err := dryRunDB.Close(); err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDriver is a minimal database/sql driver that records executed statements
+type recordingDriver struct {
+	mu        sync.Mutex
+	executed  []string
+	commits   int
+	rollbacks int
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d: d}, nil }
+
+// Connect and Driver make recordingDriver a driver.Connector, so each test opens its own without registering it
+func (d *recordingDriver) Connect(ctx context.Context) (driver.Conn, error) { return d.Open("") }
+func (d *recordingDriver) Driver() driver.Driver                            { return d }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return &recordingTx{d: c.d}, nil }
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.executed = append(c.d.executed, query)
+	return driver.RowsAffected(1), nil
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (t *recordingTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.commits++
+	return nil
+}
+
+func (t *recordingTx) Rollback() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.rollbacks++
+	return nil
+}
+
+func TestWithDryRunDoesNotExecute(t *testing.T) {
+	rec := &recordingDriver{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	var out bytes.Buffer
+	err := WithDryRun(context.Background(), db, true, &out, func(db Execer) error {
+		if err := RollbackMigrations(db); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(context.Background(), "INSERT INTO users (email) VALUES ($1)", "dry@run.test")
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, rec.executed)
+	assert.Zero(t, rec.commits)
+	assert.Equal(t, 1, rec.rollbacks)
+
+	output := out.String()
+	assert.Contains(t, output, "DROP TABLE IF EXISTS webhook_endpoints CASCADE;")
+	assert.Contains(t, output, "DROP TABLE IF EXISTS users CASCADE;")
+	assert.Contains(t, output, "INSERT INTO users (email) VALUES ($1) -- args: [dry@run.test]")
+	assert.Contains(t, output, "DRY RUN COMPLETE – no changes made.")
+	assert.NotContains(t, logs.String(), "successfully")
+}
+
+func TestWithDryRunDisabledExecutes(t *testing.T) {
+	rec := &recordingDriver{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	var out bytes.Buffer
+	err := WithDryRun(context.Background(), db, false, &out, RollbackMigrations)
+	require.NoError(t, err)
+
+	assert.Len(t, rec.executed, len(migratedTables))
+	assert.Empty(t, out.String())
+}
@@ -2,11 +2,29 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"log"
 )
 
-func RunMigrations(db *sql.DB) error {
+// migratedTables lists the tables created by RunMigrations, in creation order
+var migratedTables = []string{
+	"users",
+	"user_preferences",
+	"companions",
+	"companion_relationships",
+	"conversations",
+	"messages",
+	"media_files",
+	"user_engagement_analytics",
+	"user_statistics_daily",
+	"message_analytics",
+	"webhook_endpoints",
+	"notification_preferences",
+	"feature_flags",
+	"user_flag_overrides",
+}
+
+func RunMigrations(db Execer) error {
 	ctx := context.Background()
 
 	// Create tables first
@@ -21,10 +39,18 @@ func RunMigrations(db *sql.DB) error {
 			gender VARCHAR(50),
 			avatar_url TEXT,
 			is_active BOOLEAN DEFAULT true,
+			permissions INTEGER NOT NULL DEFAULT 0,
+			is_test_user BOOLEAN NOT NULL DEFAULT false,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
 
+		// Permission bits for users created before the column existed
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions INTEGER NOT NULL DEFAULT 0;`,
+
+		// Test account flag for users created before the column existed
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS is_test_user BOOLEAN NOT NULL DEFAULT false;`,
+
 		// User preferences table
 		`CREATE TABLE IF NOT EXISTS user_preferences (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -37,6 +63,9 @@ func RunMigrations(db *sql.DB) error {
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
 
+		// Quiet hours for preferences created before the column existed
+		`ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS quiet_hours JSONB;`,
+
 		// Companions table
 		`CREATE TABLE IF NOT EXISTS companions (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -77,6 +106,9 @@ func RunMigrations(db *sql.DB) error {
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
 
+		// Soft delete timestamp for conversations created before the column existed
+		`ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;`,
+
 		// Messages table (PostgreSQL version for analytics)
 		`CREATE TABLE IF NOT EXISTS messages (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -130,6 +162,77 @@ func RunMigrations(db *sql.DB) error {
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);`,
+
+		// Daily user statistics rollup table
+		`CREATE TABLE IF NOT EXISTS user_statistics_daily (
+			user_id VARCHAR(255) NOT NULL,
+			companion_id VARCHAR(255) NOT NULL,
+			stat_date DATE NOT NULL,
+			total_sessions INTEGER DEFAULT 0,
+			total_messages INTEGER DEFAULT 0,
+			avg_session_length BIGINT DEFAULT 0,
+			avg_messages_per_session DECIMAL(10,2) DEFAULT 0.0,
+			engagement_score DECIMAL(5,2) DEFAULT 0.0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, companion_id, stat_date)
+		);`,
+		`ALTER TABLE user_statistics_daily ADD COLUMN IF NOT EXISTS sessions_by_hour JSONB;`,
+
+		// Per-message analytics used for cost attribution
+		`CREATE TABLE IF NOT EXISTS message_analytics (
+			id UUID PRIMARY KEY,
+			conversation_id VARCHAR(255) NOT NULL,
+			companion_id VARCHAR(255),
+			sender_id UUID NOT NULL,
+			type VARCHAR(50) NOT NULL,
+			sentiment VARCHAR(50),
+			tokens INTEGER,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		// Webhook endpoints notified of achievement events
+		`CREATE TABLE IF NOT EXISTS webhook_endpoints (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			url TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		// Notification channel preferences per user
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			email_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			push_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			in_app_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		// Relationship health alert threshold for preferences created before the column existed
+		`ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS alert_threshold DOUBLE PRECISION NOT NULL DEFAULT 0.5;`,
+
+		// Runtime feature flags with percentage rollouts
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+			flag_name VARCHAR(100) PRIMARY KEY,
+			enabled_globally BOOLEAN NOT NULL DEFAULT FALSE,
+			rollout_percentage INTEGER NOT NULL DEFAULT 0 CHECK (rollout_percentage BETWEEN 0 AND 100),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		// Per-user feature flag overrides
+		`CREATE TABLE IF NOT EXISTS user_flag_overrides (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			flag_name VARCHAR(100) NOT NULL REFERENCES feature_flags(flag_name) ON DELETE CASCADE,
+			enabled BOOLEAN NOT NULL,
+			PRIMARY KEY (user_id, flag_name)
+		);`,
+
+		// Features that were on before they were put behind flags stay on for everyone
+		`INSERT INTO feature_flags (flag_name, enabled_globally, rollout_percentage) VALUES
+			('shadow_response_validation', TRUE, 100),
+			('behavior_rules_experiment', TRUE, 100)
+		ON CONFLICT (flag_name) DO NOTHING;`,
 	}
 
 	// Create tables
@@ -145,6 +248,7 @@ func RunMigrations(db *sql.DB) error {
 		// Conversations table indexes
 		`CREATE INDEX IF NOT EXISTS idx_conversations_user_companion ON conversations(user_id, companion_id, last_activity DESC);`,
 		`CREATE INDEX IF NOT EXISTS idx_conversations_created_at ON conversations(created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_conversations_deleted_at ON conversations(deleted_at) WHERE deleted_at IS NOT NULL;`,
 
 		// Messages table indexes
 		`CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON messages(conversation_id, created_at DESC);`,
@@ -154,6 +258,12 @@ func RunMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_analytics_user_companion_conversation_created ON user_engagement_analytics(user_id, companion_id, conversation_id, created_at DESC);`,
 		`CREATE INDEX IF NOT EXISTS idx_analytics_engagement_score ON user_engagement_analytics(engagement_score DESC);`,
 
+		// Daily user statistics indexes
+		`CREATE INDEX IF NOT EXISTS idx_user_statistics_daily_stat_date ON user_statistics_daily(stat_date DESC);`,
+
+		// Message analytics indexes
+		`CREATE INDEX IF NOT EXISTS idx_message_analytics_companion_conversation ON message_analytics(companion_id, conversation_id);`,
+
 		// Users table indexes
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);`,
 		`CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at DESC);`,
@@ -182,6 +292,27 @@ func RunMigrations(db *sql.DB) error {
 		}
 	}
 
-	log.Println("Postgres migrations applied successfully.")
+	if !isDryRun(db) {
+		log.Println("Postgres migrations applied successfully.")
+	}
+	return nil
+}
+
+// RollbackMigrations drops the tables created by RunMigrations in reverse order. Migrations are not versioned, so
+// this removes every table and its data; callers must confirm before running it against a live database.
+func RollbackMigrations(db Execer) error {
+	ctx := context.Background()
+
+	for i := len(migratedTables) - 1; i >= 0; i-- {
+		stmt := fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;", migratedTables[i])
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			log.Printf("Failed to drop table: %v", err)
+			return err
+		}
+	}
+
+	if !isDryRun(db) {
+		log.Println("Postgres migrations rolled back successfully.")
+	}
 	return nil
 }
@@ -12,13 +12,21 @@ type PostgresDB struct {
 	DB *sql.DB
 }
 
-func NewPostgresConnection(cfg config.PostgresConfig) (*PostgresDB, error) {
+// Open returns a database handle without checking connectivity
+func Open(cfg config.PostgresConfig) (*sql.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
-	db, err := sql.Open("postgres", dsn)
+	return sql.Open("postgres", dsn)
+}
+
+func NewPostgresConnection(cfg config.PostgresConfig) (*PostgresDB, error) {
+	db, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
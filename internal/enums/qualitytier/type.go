@@ -0,0 +1,38 @@
+package qualitytier
+
+type Type string
+
+const (
+	Bronze   Type = "bronze"
+	Silver   Type = "silver"
+	Gold     Type = "gold"
+	Platinum Type = "platinum"
+)
+
+// FromEngagementScore maps a conversation engagement score in [0, 1] to its quality tier
+func FromEngagementScore(score float64) Type {
+	switch {
+	case score > 0.85:
+		return Platinum
+	case score >= 0.7:
+		return Gold
+	case score >= 0.5:
+		return Silver
+	default:
+		return Bronze
+	}
+}
+
+// Multiplier returns the factor applied to a session's experience points for the tier
+func (t Type) Multiplier() float64 {
+	switch t {
+	case Platinum:
+		return 2.0
+	case Gold:
+		return 1.5
+	case Silver:
+		return 1.25
+	default:
+		return 1.0
+	}
+}
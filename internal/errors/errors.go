@@ -5,7 +5,11 @@ import "fmt"
 type ErrorCode string
 
 const (
-	ErrCodeInternalError = "INTERNAL_ERROR"
+	ErrCodeInternalError    = "INTERNAL_ERROR"
+	ErrCodeRateLimited      = "RATE_LIMITED"
+	ErrCodeContentFlagged   = "CONTENT_FLAGGED"
+	ErrCodeConversationFull = "CONVERSATION_FULL"
+	ErrCodePayloadTooLarge  = "PAYLOAD_TOO_LARGE"
 )
 
 type AppError struct {
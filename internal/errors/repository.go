@@ -0,0 +1,105 @@
+package errors
+
+import "fmt"
+
+const (
+	ErrCodeNotFound   = "NOT_FOUND"
+	ErrCodeConflict   = "CONFLICT"
+	ErrCodeValidation = "VALIDATION_ERROR"
+	ErrCodeDatabase   = "DATABASE_ERROR"
+)
+
+// NotFoundError is returned when a requested record does not exist
+type NotFoundError struct {
+	Code    ErrorCode
+	Message string
+	Cause   error
+}
+
+// NewNotFoundError creates a NotFoundError; cause is the underlying driver error and may be nil
+func NewNotFoundError(message string, cause error) *NotFoundError {
+	return &NotFoundError{Code: ErrCodeNotFound, Message: message, Cause: cause}
+}
+
+func (e *NotFoundError) Error() string { return formatError(e.Message, e.Cause) }
+
+func (e *NotFoundError) Unwrap() error { return e.Cause }
+
+// Is matches any NotFoundError, or only those with the same code if target sets one
+func (e *NotFoundError) Is(target error) bool {
+	t, ok := target.(*NotFoundError)
+	return ok && (t.Code == "" || t.Code == e.Code)
+}
+
+// ConflictError is returned when a write clashes with an existing record, such as a unique key
+type ConflictError struct {
+	Code    ErrorCode
+	Message string
+	Cause   error
+}
+
+// NewConflictError creates a ConflictError; cause is the underlying driver error and may be nil
+func NewConflictError(message string, cause error) *ConflictError {
+	return &ConflictError{Code: ErrCodeConflict, Message: message, Cause: cause}
+}
+
+func (e *ConflictError) Error() string { return formatError(e.Message, e.Cause) }
+
+func (e *ConflictError) Unwrap() error { return e.Cause }
+
+// Is matches any ConflictError, or only those with the same code if target sets one
+func (e *ConflictError) Is(target error) bool {
+	t, ok := target.(*ConflictError)
+	return ok && (t.Code == "" || t.Code == e.Code)
+}
+
+// ValidationError is returned when input is rejected before it reaches the database
+type ValidationError struct {
+	Code    ErrorCode
+	Message string
+	Cause   error
+}
+
+// NewValidationError creates a ValidationError; cause is the validator error and may be nil
+func NewValidationError(message string, cause error) *ValidationError {
+	return &ValidationError{Code: ErrCodeValidation, Message: message, Cause: cause}
+}
+
+func (e *ValidationError) Error() string { return formatError(e.Message, e.Cause) }
+
+func (e *ValidationError) Unwrap() error { return e.Cause }
+
+// Is matches any ValidationError, or only those with the same code if target sets one
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && (t.Code == "" || t.Code == e.Code)
+}
+
+// DatabaseError is returned for driver failures that are not one of the more specific types
+type DatabaseError struct {
+	Code    ErrorCode
+	Message string
+	Cause   error
+}
+
+// NewDatabaseError creates a DatabaseError wrapping the driver error
+func NewDatabaseError(message string, cause error) *DatabaseError {
+	return &DatabaseError{Code: ErrCodeDatabase, Message: message, Cause: cause}
+}
+
+func (e *DatabaseError) Error() string { return formatError(e.Message, e.Cause) }
+
+func (e *DatabaseError) Unwrap() error { return e.Cause }
+
+// Is matches any DatabaseError, or only those with the same code if target sets one
+func (e *DatabaseError) Is(target error) bool {
+	t, ok := target.(*DatabaseError)
+	return ok && (t.Code == "" || t.Code == e.Code)
+}
+
+func formatError(message string, cause error) string {
+	if cause != nil {
+		return fmt.Sprintf("%s: %v", message, cause)
+	}
+	return message
+}
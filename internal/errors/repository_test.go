@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryErrors(t *testing.T) {
+	cause := sql.ErrNoRows
+
+	tests := []struct {
+		name     string
+		err      error
+		code     ErrorCode
+		matches  error
+		mismatch error
+	}{
+		{name: "not found", err: NewNotFoundError("user not found", cause), code: ErrCodeNotFound, matches: &NotFoundError{}, mismatch: &DatabaseError{}},
+		{name: "conflict", err: NewConflictError("user already exists", cause), code: ErrCodeConflict, matches: &ConflictError{}, mismatch: &NotFoundError{}},
+		{name: "validation", err: NewValidationError("validation error", cause), code: ErrCodeValidation, matches: &ValidationError{}, mismatch: &ConflictError{}},
+		{name: "database", err: NewDatabaseError("failed to get user", cause), code: ErrCodeDatabase, matches: &DatabaseError{}, mismatch: &ValidationError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("service: %w", tt.err)
+
+			assert.Equal(t, cause, errors.Unwrap(tt.err))
+			assert.ErrorIs(t, wrapped, cause)
+			assert.ErrorIs(t, wrapped, tt.matches)
+			assert.NotErrorIs(t, wrapped, tt.mismatch)
+			assert.Contains(t, tt.err.Error(), cause.Error())
+		})
+	}
+
+	t.Run("Is compares codes when the target sets one", func(t *testing.T) {
+		err := NewNotFoundError("user not found", nil)
+		assert.ErrorIs(t, err, &NotFoundError{Code: ErrCodeNotFound})
+		assert.NotErrorIs(t, err, &NotFoundError{Code: ErrCodeConflict})
+	})
+
+	t.Run("As extracts the typed error", func(t *testing.T) {
+		err := fmt.Errorf("failed to load: %w", NewNotFoundError("companion not found", nil))
+
+		var notFound *NotFoundError
+		assert.True(t, errors.As(err, &notFound))
+		assert.Equal(t, "companion not found", notFound.Message)
+		assert.Equal(t, ErrorCode(ErrCodeNotFound), notFound.Code)
+		assert.Nil(t, errors.Unwrap(notFound))
+		assert.Equal(t, "companion not found", notFound.Error())
+
+		var conflict *ConflictError
+		assert.False(t, errors.As(err, &conflict))
+	})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminHandler struct {
+	responseQuality *services.ResponseQualityService
+	userRepo        *repositories.UserRepository
+}
+
+func NewAdminHandler(responseQuality *services.ResponseQualityService, userRepo *repositories.UserRepository) *AdminHandler {
+	return &AdminHandler{responseQuality: responseQuality, userRepo: userRepo}
+}
+
+// RevalidateMessage re-runs quality validation on a companion message and returns its old and new overall quality
+func (h *AdminHandler) RevalidateMessage(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var notFound *apperrors.NotFoundError
+	var oldQuality *float64
+	previous, err := h.responseQuality.GetResponseQuality(c.Request.Context(), id)
+	switch {
+	case err == nil:
+		oldQuality = &previous.OverallQuality
+	case !errors.As(err, &notFound):
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	quality, err := h.responseQuality.RevalidateMessage(c.Request.Context(), id)
+	if err != nil {
+		var validationErr *apperrors.ValidationError
+		switch {
+		case errors.As(err, &notFound):
+			response.NotFound(c, err, gin.H{"error": "Message not found"})
+		case errors.As(err, &validationErr):
+			response.BadRequest(c, err, nil)
+		default:
+			response.InternalServerError(c, err, nil)
+		}
+		return
+	}
+
+	response.Success(c, dto.RevalidateMessageResponse{
+		MessageID:         id.Hex(),
+		OldOverallQuality: oldQuality,
+		NewOverallQuality: quality.OverallQuality,
+	}, "Message revalidated")
+}
+
+// SetTestUser flags or unflags a user as a test account, so platform insights can leave their data out
+func (h *AdminHandler) SetTestUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req dto.SetTestUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.userRepo.SetTestUser(c.Request.Context(), userID, *req.IsTestUser); err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, gin.H{"error": "User not found"})
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, gin.H{"user_id": userID, "is_test_user": *req.IsTestUser}, "Test user flag updated")
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	apihttp "github.com/sahmaragaev/lunaria-backend/internal/http"
 	"github.com/sahmaragaev/lunaria-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -32,19 +33,19 @@ func NewAnalyticsHandler(
 func (h *AnalyticsHandler) GetUserDashboard(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	dashboard, err := h.analyticsService.GetUserDashboardData(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard data"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get dashboard data")
 		return
 	}
 
@@ -55,19 +56,19 @@ func (h *AnalyticsHandler) GetUserDashboard(c *gin.Context) {
 func (h *AnalyticsHandler) GetUserProgress(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	progress, err := h.gamificationService.GetUserProgress(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user progress"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get user progress")
 		return
 	}
 
@@ -78,13 +79,13 @@ func (h *AnalyticsHandler) GetUserProgress(c *gin.Context) {
 func (h *AnalyticsHandler) GetUserAchievements(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
@@ -96,7 +97,7 @@ func (h *AnalyticsHandler) GetUserAchievements(c *gin.Context) {
 
 	achievements, err := h.gamificationService.GetUserAchievements(c.Request.Context(), userID, companionID, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get achievements"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get achievements")
 		return
 	}
 
@@ -107,19 +108,19 @@ func (h *AnalyticsHandler) GetUserAchievements(c *gin.Context) {
 func (h *AnalyticsHandler) GetAchievementProgress(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	progress, err := h.gamificationService.GetAchievementProgress(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get achievement progress"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get achievement progress")
 		return
 	}
 
@@ -132,7 +133,7 @@ func (h *AnalyticsHandler) GetAchievementDefinitions(c *gin.Context) {
 
 	definitions, err := h.gamificationService.GetAchievementDefinitions(c.Request.Context(), category)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get achievement definitions"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get achievement definitions")
 		return
 	}
 
@@ -143,7 +144,7 @@ func (h *AnalyticsHandler) GetAchievementDefinitions(c *gin.Context) {
 func (h *AnalyticsHandler) GetAchievementCategories(c *gin.Context) {
 	categories, err := h.gamificationService.GetAchievementCategories(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get achievement categories"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get achievement categories")
 		return
 	}
 
@@ -154,19 +155,19 @@ func (h *AnalyticsHandler) GetAchievementCategories(c *gin.Context) {
 func (h *AnalyticsHandler) GetStreakInformation(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	streakInfo, err := h.gamificationService.GetStreakInformation(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get streak information"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get streak information")
 		return
 	}
 
@@ -177,13 +178,13 @@ func (h *AnalyticsHandler) GetStreakInformation(c *gin.Context) {
 func (h *AnalyticsHandler) GetEngagementTrends(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
@@ -195,7 +196,7 @@ func (h *AnalyticsHandler) GetEngagementTrends(c *gin.Context) {
 
 	trends, err := h.analyticsService.GetEngagementTrends(c.Request.Context(), userID, companionID, days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get engagement trends"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get engagement trends")
 		return
 	}
 
@@ -206,19 +207,19 @@ func (h *AnalyticsHandler) GetEngagementTrends(c *gin.Context) {
 func (h *AnalyticsHandler) GetUserStatistics(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	statistics, err := h.analyticsService.GetUserStatistics(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user statistics"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get user statistics")
 		return
 	}
 
@@ -229,19 +230,19 @@ func (h *AnalyticsHandler) GetUserStatistics(c *gin.Context) {
 func (h *AnalyticsHandler) GetRelationshipAnalytics(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	analytics, err := h.analyticsService.GetRelationshipAnalytics(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get relationship analytics"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get relationship analytics")
 		return
 	}
 
@@ -252,19 +253,19 @@ func (h *AnalyticsHandler) GetRelationshipAnalytics(c *gin.Context) {
 func (h *AnalyticsHandler) GetUserBehaviorPrediction(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	prediction, err := h.predictiveAnalyticsService.PredictUserBehavior(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get behavior prediction"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get behavior prediction")
 		return
 	}
 
@@ -275,19 +276,19 @@ func (h *AnalyticsHandler) GetUserBehaviorPrediction(c *gin.Context) {
 func (h *AnalyticsHandler) GetPersonalizedRecommendations(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	recommendations, err := h.predictiveAnalyticsService.GeneratePersonalizedRecommendations(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get recommendations")
 		return
 	}
 
@@ -298,7 +299,7 @@ func (h *AnalyticsHandler) GetPersonalizedRecommendations(c *gin.Context) {
 func (h *AnalyticsHandler) TrackSessionActivity(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -314,13 +315,13 @@ func (h *AnalyticsHandler) TrackSessionActivity(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "Invalid request body")
 		return
 	}
 
 	conversationID, err := primitive.ObjectIDFromHex(request.ConversationID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "Invalid conversation ID")
 		return
 	}
 
@@ -336,14 +337,14 @@ func (h *AnalyticsHandler) TrackSessionActivity(c *gin.Context) {
 	// Track user engagement
 	err = h.analyticsService.TrackUserEngagement(c.Request.Context(), userID, request.CompanionID, conversationID, sessionData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to track session activity"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to track session activity")
 		return
 	}
 
 	// Process user progress
 	err = h.analyticsService.ProcessUserProgress(c.Request.Context(), userID, request.CompanionID, sessionData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process user progress"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to process user progress")
 		return
 	}
 
@@ -361,7 +362,7 @@ func (h *AnalyticsHandler) TrackSessionActivity(c *gin.Context) {
 
 	err = h.gamificationService.CheckAndAwardAchievements(c.Request.Context(), userID, request.CompanionID, activityData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check achievements"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to check achievements")
 		return
 	}
 
@@ -372,19 +373,19 @@ func (h *AnalyticsHandler) TrackSessionActivity(c *gin.Context) {
 func (h *AnalyticsHandler) UpdateStreak(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	companionID := c.Query("companion_id")
 	if companionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "companion_id is required"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "companion_id is required")
 		return
 	}
 
 	err := h.gamificationService.UpdateStreak(c.Request.Context(), userID, companionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streak"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to update streak")
 		return
 	}
 
@@ -396,7 +397,7 @@ func (h *AnalyticsHandler) GetLevelRewards(c *gin.Context) {
 	levelStr := c.Param("level")
 	level, err := strconv.Atoi(levelStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusBadRequest, apihttp.TypeBadRequest, "Invalid level")
 		return
 	}
 
@@ -411,13 +412,13 @@ func (h *AnalyticsHandler) GetPlatformAnalytics(c *gin.Context) {
 	// Check if user is admin (implement your admin check logic)
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// TODO: Add admin role check
 	// if !isAdmin(userID) {
-	//     c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+	//     apihttp.WriteProblem(c.Writer, c.Request, http.StatusForbidden, apihttp.TypeForbidden, "Admin access required")
 	//     return
 	// }
 
@@ -429,7 +430,7 @@ func (h *AnalyticsHandler) GetPlatformAnalytics(c *gin.Context) {
 
 	analytics, err := h.analyticsService.GetPlatformAnalytics(c.Request.Context(), days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get platform analytics"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get platform analytics")
 		return
 	}
 
@@ -441,13 +442,13 @@ func (h *AnalyticsHandler) GetUsersAtChurnRisk(c *gin.Context) {
 	// Check if user is admin (implement your admin check logic)
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// TODO: Add admin role check
 	// if !isAdmin(userID) {
-	//     c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+	//     apihttp.WriteProblem(c.Writer, c.Request, http.StatusForbidden, apihttp.TypeForbidden, "Admin access required")
 	//     return
 	// }
 
@@ -459,7 +460,7 @@ func (h *AnalyticsHandler) GetUsersAtChurnRisk(c *gin.Context) {
 
 	users, err := h.predictiveAnalyticsService.GetUsersAtChurnRisk(c.Request.Context(), threshold)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users at churn risk"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get users at churn risk")
 		return
 	}
 
@@ -471,13 +472,13 @@ func (h *AnalyticsHandler) GetAnalyticsTrends(c *gin.Context) {
 	// Check if user is admin (implement your admin check logic)
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// TODO: Add admin role check
 	// if !isAdmin(userID) {
-	//     c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+	//     apihttp.WriteProblem(c.Writer, c.Request, http.StatusForbidden, apihttp.TypeForbidden, "Admin access required")
 	//     return
 	// }
 
@@ -489,7 +490,7 @@ func (h *AnalyticsHandler) GetAnalyticsTrends(c *gin.Context) {
 
 	trends, err := h.predictiveAnalyticsService.AnalyzeTrends(c.Request.Context(), days)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get analytics trends"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to get analytics trends")
 		return
 	}
 
@@ -501,19 +502,19 @@ func (h *AnalyticsHandler) InitializeAchievements(c *gin.Context) {
 	// Check if user is admin (implement your admin check logic)
 	userID := c.GetString("user_id")
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusUnauthorized, apihttp.TypeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// TODO: Add admin role check
 	// if !isAdmin(userID) {
-	//     c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+	//     apihttp.WriteProblem(c.Writer, c.Request, http.StatusForbidden, apihttp.TypeForbidden, "Admin access required")
 	//     return
 	// }
 
 	err := h.gamificationService.InitializeAchievementDefinitions(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize achievements"})
+		apihttp.WriteProblem(c.Writer, c.Request, http.StatusInternalServerError, apihttp.TypeInternalError, "Failed to initialize achievements")
 		return
 	}
 
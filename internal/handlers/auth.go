@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
@@ -35,10 +37,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 	resp, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "validation error") ||
-			strings.Contains(errMsg, "password must") ||
-			strings.Contains(errMsg, "already exists") {
+		var validationErr *apperrors.ValidationError
+		var conflict *apperrors.ConflictError
+		if errors.As(err, &validationErr) || errors.As(err, &conflict) ||
+			strings.Contains(err.Error(), "password must") {
 			response.BadRequest(c, err, nil)
 			return
 		}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BadgeHandler struct {
+	service *services.GamificationService
+}
+
+func NewBadgeHandler(service *services.GamificationService) *BadgeHandler {
+	return &BadgeHandler{service: service}
+}
+
+// ExportBadges returns the user's achievements with a companion as Open Badges 2.0 assertions
+func (h *BadgeHandler) ExportBadges(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	assertions, err := h.service.ExportAchievementsAsOpenBadges(c.Request.Context(), user.ID.String(), c.Param("id"))
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, assertions, "Badges exported")
+}
+
+// GetBadgeClass serves the public BadgeClass document that exported assertions point to
+func (h *BadgeHandler) GetBadgeClass(c *gin.Context) {
+	badge, err := h.service.GetOpenBadgeClass(c.Request.Context(), c.Param("achievement_id"))
+	if err != nil {
+		h.respondError(c, err, "Badge not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, badge)
+}
+
+// GetAssertion serves the public copy of an assertion used for hosted verification
+func (h *BadgeHandler) GetAssertion(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.NotFound(c, err, gin.H{"error": "Assertion not found"})
+		return
+	}
+
+	assertion, err := h.service.GetOpenBadgeAssertion(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, "Assertion not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, assertion)
+}
+
+// GetIssuer serves the issuer profile referenced by every badge class
+func (h *BadgeHandler) GetIssuer(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.GetOpenBadgeIssuer())
+}
+
+// respondError responds with 404 for missing records and 500 otherwise
+func (h *BadgeHandler) respondError(c *gin.Context, err error, notFoundMessage string) {
+	var notFound *apperrors.NotFoundError
+	if errors.As(err, &notFound) {
+		response.NotFound(c, err, gin.H{"error": notFoundMessage})
+		return
+	}
+	response.InternalServerError(c, err, nil)
+}
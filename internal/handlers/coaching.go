@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+)
+
+type CoachingHandler struct {
+	service *services.MLAnalyticsService
+}
+
+func NewCoachingHandler(service *services.MLAnalyticsService) *CoachingHandler {
+	return &CoachingHandler{service: service}
+}
+
+// GetCoachingTips returns tips for making the user's conversations with a companion more engaging
+func (h *CoachingHandler) GetCoachingTips(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	companionID := c.Query("companion_id")
+	if companionID == "" {
+		response.BadRequest(c, nil, gin.H{"error": "companion_id is required"})
+		return
+	}
+
+	tips, err := h.service.GetConversationCoachingTips(c.Request.Context(), user.ID.String(), companionID)
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, tips, "Coaching tips retrieved")
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/response"
@@ -15,12 +16,14 @@ import (
 
 type CompanionHandler struct {
 	companionService *services.CompanionService
+	aiContextService *services.AIContextService
 	validator        *validator.Validate
 }
 
-func NewCompanionHandler(companionService *services.CompanionService) *CompanionHandler {
+func NewCompanionHandler(companionService *services.CompanionService, aiContextService *services.AIContextService) *CompanionHandler {
 	return &CompanionHandler{
 		companionService: companionService,
+		aiContextService: aiContextService,
 		validator:        validator.New(),
 	}
 }
@@ -39,7 +42,8 @@ func (h *CompanionHandler) CreateCompanion(c *gin.Context) {
 	}
 	companion, err := h.companionService.CreateCompanion(c.Request.Context(), user.ID, &req)
 	if err != nil {
-		if strings.Contains(err.Error(), "validation error") {
+		var validationErr *apperrors.ValidationError
+		if errors.As(err, &validationErr) {
 			response.BadRequest(c, err, nil)
 			return
 		}
@@ -64,7 +68,8 @@ func (h *CompanionHandler) GetCompanion(c *gin.Context) {
 	}
 	companion, err := h.companionService.GetCompanion(c.Request.Context(), companionID, user.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
 			response.NotFound(c, err, nil)
 			return
 		}
@@ -121,11 +126,13 @@ func (h *CompanionHandler) UpdateCompanion(c *gin.Context) {
 	}
 	companion, err := h.companionService.UpdateCompanion(c.Request.Context(), companionID, user.ID, &req)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
 			response.NotFound(c, err, nil)
 			return
 		}
-		if strings.Contains(err.Error(), "validation error") {
+		var validationErr *apperrors.ValidationError
+		if errors.As(err, &validationErr) {
 			response.BadRequest(c, err, nil)
 			return
 		}
@@ -150,7 +157,8 @@ func (h *CompanionHandler) DeleteCompanion(c *gin.Context) {
 	}
 	err = h.companionService.DeleteCompanion(c.Request.Context(), companionID, user.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
 			response.NotFound(c, err, nil)
 			return
 		}
@@ -159,3 +167,45 @@ func (h *CompanionHandler) DeleteCompanion(c *gin.Context) {
 	}
 	response.Success(c, nil, "Companion deleted successfully")
 }
+
+// GetConversationSparks suggests opener messages for starting a new conversation with the companion
+func (h *CompanionHandler) GetConversationSparks(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+	companionIDStr := c.Param("id")
+	companionID, err := uuid.Parse(companionIDStr)
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid companion ID"})
+		return
+	}
+	count := 3
+	if countStr := c.Query("count"); countStr != "" {
+		if n, err := strconv.Atoi(countStr); err == nil {
+			count = n
+		}
+	}
+	if _, err := h.companionService.GetCompanion(c.Request.Context(), companionID, user.ID); err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, nil)
+			return
+		}
+		response.InternalServerError(c, err, gin.H{"error": "Failed to get companion"})
+		return
+	}
+	sparks, err := h.aiContextService.GenerateConversationSparks(c.Request.Context(), user.ID.String(), companionID.String(), count)
+	if err != nil {
+		var validationErr *apperrors.ValidationError
+		if errors.As(err, &validationErr) {
+			response.BadRequest(c, err, nil)
+			return
+		}
+		response.InternalServerError(c, err, gin.H{"error": "Failed to generate conversation sparks"})
+		return
+	}
+	response.Success(c, gin.H{"sparks": sparks}, "Conversation sparks generated successfully")
+}
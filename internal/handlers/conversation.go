@@ -1,8 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/response"
 	"github.com/sahmaragaev/lunaria-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -26,9 +35,15 @@ func (h *ConversationHandler) StartConversation(c *gin.Context) {
 	user := userInterface.(*models.User)
 	companionID := c.Query("companion_id")
 	relationship := c.Query("relationship")
+	templateID := c.Query("template_id")
 
-	conv, err := h.service.StartConversation(c.Request.Context(), user.ID.String(), companionID, relationship)
+	conv, err := h.service.StartConversation(c.Request.Context(), user.ID.String(), companionID, relationship, templateID)
 	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, gin.H{"error": "Conversation template not found"})
+			return
+		}
 		response.InternalServerError(c, err, nil)
 		return
 	}
@@ -36,6 +51,58 @@ func (h *ConversationHandler) StartConversation(c *gin.Context) {
 	response.Created(c, conv, "Conversation started")
 }
 
+// ListConversationTemplates lists the templates conversations can start from, filtered by a comma-separated list of
+// tags
+func (h *ConversationHandler) ListConversationTemplates(c *gin.Context) {
+	var tags []string
+	if raw := c.Query("tags"); raw != "" {
+		tags = strings.Split(raw, ",")
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	templates, err := h.service.ListConversationTemplates(c.Request.Context(), tags, limit)
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+	response.Success(c, templates, "Conversation templates listed")
+}
+
+// CreateConversationTemplate adds a template conversations can start from, for admins
+func (h *ConversationHandler) CreateConversationTemplate(c *gin.Context) {
+	var req dto.CreateConversationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	template := &models.ConversationTemplate{
+		TemplateID:      req.TemplateID,
+		Name:            req.Name,
+		Description:     req.Description,
+		InitialMessages: req.InitialMessages,
+		InitialContext:  req.InitialContext,
+		Tags:            req.Tags,
+	}
+	if err := h.service.CreateConversationTemplate(c.Request.Context(), template); err != nil {
+		var validationErr *apperrors.ValidationError
+		var conflict *apperrors.ConflictError
+		switch {
+		case errors.As(err, &validationErr):
+			response.BadRequest(c, err, nil)
+		case errors.As(err, &conflict):
+			response.Error(c, http.StatusConflict, err, nil)
+		default:
+			response.InternalServerError(c, err, nil)
+		}
+		return
+	}
+	response.Created(c, template, "Conversation template created")
+}
+
 func (h *ConversationHandler) ListConversations(c *gin.Context) {
 	userInterface, exists := c.Get("user")
 	if !exists {
@@ -44,7 +111,11 @@ func (h *ConversationHandler) ListConversations(c *gin.Context) {
 	}
 	user := userInterface.(*models.User)
 	archived := c.Query("archived") == "true"
-	convs, err := h.service.ListConversations(c.Request.Context(), user.ID.String(), archived, 20, 0)
+	list := h.service.ListConversations
+	if c.Query("forked") == "true" {
+		list = h.service.ListForkedConversations
+	}
+	convs, err := list(c.Request.Context(), user.ID.String(), archived, 20, 0)
 	if err != nil {
 		response.InternalServerError(c, err, nil)
 		return
@@ -52,6 +123,49 @@ func (h *ConversationHandler) ListConversations(c *gin.Context) {
 	response.Success(c, convs, "Conversations listed")
 }
 
+// ForkConversation branches a new conversation from a message in one of the user's conversations
+func (h *ConversationHandler) ForkConversation(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+	var req dto.ForkConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, nil)
+		return
+	}
+	messageID, err := primitive.ObjectIDFromHex(req.MessageID)
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	fork, err := h.service.ForkConversation(c.Request.Context(), id, messageID, user.ID.String())
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		var conflict *apperrors.ConflictError
+		switch {
+		case errors.As(err, &notFound):
+			response.NotFound(c, err, nil)
+		case errors.As(err, &conflict):
+			response.Error(c, http.StatusConflict, err, nil)
+		default:
+			response.InternalServerError(c, err, nil)
+		}
+		return
+	}
+
+	response.Created(c, fork, "Conversation forked")
+}
+
 func (h *ConversationHandler) GetConversation(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := primitive.ObjectIDFromHex(idStr)
@@ -60,6 +174,11 @@ func (h *ConversationHandler) GetConversation(c *gin.Context) {
 		response.NotFound(c, err, nil)
 		return
 	}
+	conv.TopReactions, err = h.service.GetTopReactions(c.Request.Context(), conv.CompanionID)
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
 	response.Success(c, conv, "Conversation details")
 }
 
@@ -82,3 +201,117 @@ func (h *ConversationHandler) ReactivateConversation(c *gin.Context) {
 	}
 	response.Success(c, nil, "Conversation reactivated")
 }
+
+func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user := userInterface.(*models.User)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	conv, err := h.service.GetConversation(c.Request.Context(), id)
+	if err != nil || conv.UserID != user.ID.String() {
+		response.NotFound(c, err, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	if err := h.service.DeleteConversation(c.Request.Context(), id); err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+	response.Success(c, nil, "Conversation deleted")
+}
+
+// ResetContext makes the companion forget the conversation's context while keeping its messages. Memories are cleared
+// unless preserve_memories is set. A context may be reset once a day.
+func (h *ConversationHandler) ResetContext(c *gin.Context) {
+	var req dto.ResetContextRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.BadRequest(c, err, nil)
+		return
+	}
+
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user := userInterface.(*models.User)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	conv, err := h.service.GetConversation(c.Request.Context(), id)
+	if err != nil || conv.UserID != user.ID.String() {
+		response.NotFound(c, err, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	err = h.service.ResetConversationContext(c.Request.Context(), id, req.PreserveMemories)
+	var limitErr *services.ContextResetLimitError
+	var notFound *apperrors.NotFoundError
+	switch {
+	case errors.As(err, &limitErr):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+		response.Error(c, http.StatusTooManyRequests, apperrors.NewAppError(apperrors.ErrCodeRateLimited, "Conversation context can be reset once a day", err), nil)
+		return
+	case errors.As(err, &notFound):
+		response.NotFound(c, err, gin.H{"error": "Conversation context not found"})
+		return
+	case err != nil:
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, nil, "Conversation context reset")
+}
+
+// GetReplayManifest schedules the conversation's messages for a replay at the speed given in the query (default 1x)
+func (h *ConversationHandler) GetReplayManifest(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user := userInterface.(*models.User)
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	speed, err := strconv.ParseFloat(c.DefaultQuery("speed", "1"), 64)
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid replay speed"})
+		return
+	}
+
+	conv, err := h.service.GetConversation(c.Request.Context(), id)
+	if err != nil || conv.UserID != user.ID.String() {
+		response.NotFound(c, err, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	manifest, err := h.service.GenerateReplayManifest(c.Request.Context(), id, speed)
+	if errors.Is(err, services.ErrInvalidReplaySpeed) {
+		response.BadRequest(c, err, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, manifest, "Replay manifest generated")
+}
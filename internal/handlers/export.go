@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+)
+
+type ExportHandler struct {
+	service *services.DataExportService
+}
+
+func NewExportHandler(service *services.DataExportService) *ExportHandler {
+	return &ExportHandler{service: service}
+}
+
+// RequestExport queues an export of the user's data and returns the job ID to poll
+func (h *ExportHandler) RequestExport(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	jobID, err := h.service.ExportUserData(c.Request.Context(), user.ID.String())
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Accepted(c, gin.H{"job_id": jobID, "status": models.ExportStatusQueued}, "Export queued")
+}
+
+// GetExportStatus returns the state of one of the user's export jobs
+func (h *ExportHandler) GetExportStatus(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	response.Success(c, job, "Export job retrieved")
+}
+
+// GetExportDownload returns a short-lived download link for a completed export
+func (h *ExportHandler) GetExportDownload(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.ExportStatusCompleted {
+		response.Error(c, http.StatusConflict, nil, gin.H{"error": "Export is not ready", "status": job.Status})
+		return
+	}
+
+	url, err := h.service.GetExportDownloadURL(c.Request.Context(), job.ID.Hex())
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, gin.H{"download_url": url}, "Export download URL generated")
+}
+
+// ownedJob loads the export job in the path, responding with 404 unless it belongs to the current user
+func (h *ExportHandler) ownedJob(c *gin.Context) (*models.ExportJob, bool) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	user := userInterface.(*models.User)
+
+	job, err := h.service.GetExportJobStatus(c.Request.Context(), c.Param("id"))
+	if err != nil || job.UserID != user.ID.String() {
+		response.NotFound(c, err, gin.H{"error": "Export job not found"})
+		return nil, false
+	}
+	return job, true
+}
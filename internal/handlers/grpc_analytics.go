@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	analyticsmetrics "github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/analyticspb"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCAnalyticsServer ingests analytics events pushed over gRPC by internal services
+type GRPCAnalyticsServer struct {
+	analyticspb.UnimplementedAnalyticsIngestionServer
+	repo *repositories.AnalyticsRepository
+}
+
+func NewGRPCAnalyticsServer(repo *repositories.AnalyticsRepository) *GRPCAnalyticsServer {
+	return &GRPCAnalyticsServer{repo: repo}
+}
+
+// TrackEngagement merges engagement metrics into the stored analytics for the conversation
+func (s *GRPCAnalyticsServer) TrackEngagement(ctx context.Context, event *analyticspb.EngagementEvent) (*analyticspb.TrackResponse, error) {
+	if event.GetUserId() == "" || event.GetCompanionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and companion_id are required")
+	}
+	conversationID, err := primitive.ObjectIDFromHex(event.GetConversationId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid conversation_id")
+	}
+
+	analytics, err := s.repo.GetUserEngagementAnalytics(ctx, event.GetUserId(), event.GetCompanionId(), conversationID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, status.Errorf(codes.Internal, "failed to load engagement analytics: %v", err)
+		}
+		analytics = &models.UserEngagementAnalytics{
+			UserID:         event.GetUserId(),
+			CompanionID:    event.GetCompanionId(),
+			ConversationID: conversationID,
+		}
+	}
+
+	analytics.SessionDuration = secondsToDuration(event.GetSessionDurationSeconds())
+	analytics.MessagesPerSession = int(event.GetMessagesPerSession())
+	analytics.ResponseTime = secondsToDuration(event.GetResponseTimeSeconds())
+	analytics.EngagementScore = event.GetEngagementScore()
+	analytics.ConversationDepth = event.GetConversationDepth()
+	analytics.EmotionalIntensity = event.GetEmotionalIntensity()
+	analytics.TopicDiversity = event.GetTopicDiversity()
+	analytics.VulnerabilityLevel = event.GetVulnerabilityLevel()
+	if event.GetInteractionStyle() != "" {
+		analytics.InteractionStyle = event.GetInteractionStyle()
+	}
+
+	if err := s.repo.UpsertUserEngagementAnalytics(ctx, analytics); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store engagement analytics: %v", err)
+	}
+
+	return &analyticspb.TrackResponse{}, nil
+}
+
+// TrackMessage records analytics for a single message
+func (s *GRPCAnalyticsServer) TrackMessage(ctx context.Context, event *analyticspb.MessageEvent) (*analyticspb.TrackResponse, error) {
+	senderID, err := uuid.Parse(event.GetSenderId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid sender_id")
+	}
+	if event.GetConversationId() == "" || event.GetType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "conversation_id and type are required")
+	}
+
+	analytics := &models.MessageAnalytics{
+		ID:             uuid.New(),
+		ConversationID: event.GetConversationId(),
+		CompanionID:    event.GetCompanionId(),
+		SenderID:       senderID,
+		Type:           event.GetType(),
+		Sentiment:      event.Sentiment,
+	}
+	if event.Tokens != nil {
+		tokens := int(event.GetTokens())
+		analytics.Tokens = &tokens
+	}
+
+	if err := s.repo.InsertMessageAnalytics(ctx, analytics); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store message analytics: %v", err)
+	}
+
+	return &analyticspb.TrackResponse{}, nil
+}
+
+// TrackRelationshipUpdate merges the latest relationship scores into the stored relationship analytics
+func (s *GRPCAnalyticsServer) TrackRelationshipUpdate(ctx context.Context, event *analyticspb.RelationshipUpdateEvent) (*analyticspb.TrackResponse, error) {
+	if event.GetUserId() == "" || event.GetCompanionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and companion_id are required")
+	}
+
+	analytics, err := s.repo.GetRelationshipAnalytics(ctx, event.GetUserId(), event.GetCompanionId())
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, status.Errorf(codes.Internal, "failed to load relationship analytics: %v", err)
+		}
+		analytics = &models.RelationshipAnalytics{
+			UserID:      event.GetUserId(),
+			CompanionID: event.GetCompanionId(),
+		}
+	}
+
+	if event.GetCurrentStage() != "" {
+		analytics.CurrentStage = event.GetCurrentStage()
+	}
+	if event.GetCommunicationStyle() != "" {
+		analytics.CommunicationStyle = event.GetCommunicationStyle()
+	}
+	analytics.IntimacyLevel = event.GetIntimacyLevel()
+	analytics.TrustLevel = event.GetTrustLevel()
+	analytics.SafetyScore = event.GetSafetyScore()
+	// Trust lost recently still weighs on the relationship, whatever score the event reports
+	analytics.HealthScore = math.Max(0, event.GetHealthScore()-analytics.RecentTrustDecay(time.Now().Add(-models.TrustDecayHealthWindow)))
+	// The chemistry score keeps its stored value if the latest engagement analytics cannot be read
+	if records, err := s.repo.GetRecentEngagementAnalytics(ctx, analytics.UserID, analytics.CompanionID, 1); err == nil {
+		var latest *models.UserEngagementAnalytics
+		if len(records) > 0 {
+			latest = &records[0]
+		}
+		analytics.ChemistryScore = analyticsmetrics.RelationshipChemistry(analytics, latest)
+	}
+
+	if err := s.repo.UpsertRelationshipAnalytics(ctx, analytics); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store relationship analytics: %v", err)
+	}
+
+	return &analyticspb.TrackResponse{}, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
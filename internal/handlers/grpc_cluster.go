@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCPeerForwardingServer applies in-memory state changes forwarded by other server instances
+type GRPCPeerForwardingServer struct {
+	clusterpb.UnimplementedPeerForwardingServer
+	typing *services.TypingTracker
+}
+
+func NewGRPCPeerForwardingServer(typing *services.TypingTracker) *GRPCPeerForwardingServer {
+	return &GRPCPeerForwardingServer{typing: typing}
+}
+
+// ForwardTypingEvent applies a typing status change made on another instance
+func (s *GRPCPeerForwardingServer) ForwardTypingEvent(ctx context.Context, event *clusterpb.TypingEvent) (*clusterpb.ForwardResponse, error) {
+	if event.GetConversationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "conversation_id is required")
+	}
+	s.typing.ApplyRemote(event)
+	return &clusterpb.ForwardResponse{}, nil
+}
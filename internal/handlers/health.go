@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,46 +12,124 @@ import (
 	"github.com/sahmaragaev/lunaria-backend/internal/response"
 )
 
+// Health statuses, from best to worst
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+)
+
+const (
+	// poolDegradedUtilisation is the pool utilisation above which the service reports degraded
+	poolDegradedUtilisation = 0.80
+	// poolUnhealthyUtilisation is the pool utilisation above which the service reports unhealthy
+	poolUnhealthyUtilisation = 0.95
+)
+
 type HealthHandler struct {
 	PostgresDB *postgres.PostgresDB
 	MongoDB    *mongodb.MongoDB
+
+	pingPostgres  func() error
+	postgresStats func() sql.DBStats
+	pingMongo     func(ctx context.Context) error
+	mongoStats    func() mongodb.PoolStats
 }
 
 func NewHealthHandler(pg *postgres.PostgresDB, mg *mongodb.MongoDB) *HealthHandler {
-	return &HealthHandler{
-		PostgresDB: pg,
-		MongoDB:    mg,
+	h := &HealthHandler{
+		PostgresDB:    pg,
+		MongoDB:       mg,
+		pingPostgres:  pg.DB.Ping,
+		postgresStats: pg.DB.Stats,
+		pingMongo: func(ctx context.Context) error {
+			return mg.Client.Ping(ctx, nil)
+		},
+		mongoStats: func() mongodb.PoolStats { return mongodb.PoolStats{} },
+	}
+	if mg.Pool != nil {
+		h.mongoStats = mg.Pool.Stats
 	}
+	return h
 }
 
 func (h *HealthHandler) HealthCheck(c *gin.Context) {
-	status := gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
-		"services":  gin.H{},
-	}
+	overall := healthStatusHealthy
+	services := gin.H{}
 
-	if err := h.PostgresDB.DB.Ping(); err != nil {
-		status["services"].(gin.H)["postgres"] = "unhealthy"
-		status["status"] = "degraded"
+	if err := h.pingPostgres(); err != nil {
+		services["postgres"] = healthStatusUnhealthy
+		overall = healthStatusUnhealthy
 	} else {
-		status["services"].(gin.H)["postgres"] = "healthy"
+		services["postgres"] = healthStatusHealthy
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	if err := h.MongoDB.Client.Ping(ctx, nil); err != nil {
-		status["services"].(gin.H)["mongodb"] = "unhealthy"
-		status["status"] = "degraded"
+	if err := h.pingMongo(ctx); err != nil {
+		services["mongodb"] = healthStatusUnhealthy
+		overall = healthStatusUnhealthy
 	} else {
-		status["services"].(gin.H)["mongodb"] = "healthy"
+		services["mongodb"] = healthStatusHealthy
 	}
 
-	if status["status"] == "healthy" {
-		response.Success(c, status, "OK")
-	} else {
-		response.Error(c, 503, nil, status)
+	pgStats := h.postgresStats()
+	mongoStats := h.mongoStats()
+	overall = worseHealthStatus(overall, poolHealthStatus(postgresPoolUtilisation(pgStats)))
+	overall = worseHealthStatus(overall, poolHealthStatus(mongoStats.Utilisation()))
+
+	status := gin.H{
+		"status":    overall,
+		"timestamp": time.Now().UTC(),
+		"services":  services,
+		"pools": gin.H{
+			"postgres_pool_open_connections": pgStats.OpenConnections,
+			"postgres_pool_in_use":           pgStats.InUse,
+			"postgres_pool_idle":             pgStats.Idle,
+			"postgres_pool_wait_count":       pgStats.WaitCount,
+			"postgres_pool_max_open":         pgStats.MaxOpenConnections,
+			"mongodb_pool_open_connections":  mongoStats.OpenConnections,
+			"mongodb_pool_in_use":            mongoStats.InUse,
+			"mongodb_pool_idle":              mongoStats.Idle,
+			"mongodb_pool_max_size":          mongoStats.MaxConnections,
+			"mongodb_pool_checkout_failures": mongoStats.CheckoutFailures,
+		},
+	}
+
+	if overall == healthStatusUnhealthy {
+		response.Error(c, http.StatusServiceUnavailable, nil, status)
+		return
+	}
+	response.Success(c, status, "OK")
+}
+
+// postgresPoolUtilisation returns the share of the connection limit in use, or 0 when the pool is unbounded
+func postgresPoolUtilisation(stats sql.DBStats) float64 {
+	if stats.MaxOpenConnections <= 0 {
+		return 0
+	}
+	return float64(stats.InUse) / float64(stats.MaxOpenConnections)
+}
+
+// poolHealthStatus maps connection pool utilisation to a health status
+func poolHealthStatus(utilisation float64) string {
+	switch {
+	case utilisation > poolUnhealthyUtilisation:
+		return healthStatusUnhealthy
+	case utilisation > poolDegradedUtilisation:
+		return healthStatusDegraded
+	default:
+		return healthStatusHealthy
+	}
+}
+
+// worseHealthStatus returns the more severe of two health statuses
+func worseHealthStatus(a, b string) string {
+	rank := map[string]int{healthStatusHealthy: 0, healthStatusDegraded: 1, healthStatusUnhealthy: 2}
+	if rank[b] > rank[a] {
+		return b
 	}
+	return a
 }
 
 func (h *HealthHandler) ReadinessCheck(c *gin.Context) {
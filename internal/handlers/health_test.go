@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHealthHandler(pgStats sql.DBStats, mongoStats mongodb.PoolStats, pingErr error) *HealthHandler {
+	return &HealthHandler{
+		pingPostgres:  func() error { return pingErr },
+		postgresStats: func() sql.DBStats { return pgStats },
+		pingMongo:     func(ctx context.Context) error { return nil },
+		mongoStats:    func() mongodb.PoolStats { return mongoStats },
+	}
+}
+
+func serveHealth(t *testing.T, h *HealthHandler) (int, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health", h.HealthCheck)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	if data, ok := body["data"].(map[string]any); ok {
+		return rec.Code, data
+	}
+	return rec.Code, body["details"].(map[string]any)
+}
+
+func TestHealthCheckPoolUtilisation(t *testing.T) {
+	tests := []struct {
+		name       string
+		pgStats    sql.DBStats
+		mongoStats mongodb.PoolStats
+		pingErr    error
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "healthy pools",
+			pgStats:    sql.DBStats{MaxOpenConnections: 20, OpenConnections: 6, InUse: 4, Idle: 2},
+			mongoStats: mongodb.PoolStats{MaxConnections: 100, OpenConnections: 10, InUse: 5, Idle: 5},
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusHealthy,
+		},
+		{
+			name:       "unbounded postgres pool is never saturated",
+			pgStats:    sql.DBStats{OpenConnections: 500, InUse: 500},
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusHealthy,
+		},
+		{
+			name:       "postgres above 80 percent is degraded",
+			pgStats:    sql.DBStats{MaxOpenConnections: 20, OpenConnections: 20, InUse: 17, Idle: 3, WaitCount: 12},
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusDegraded,
+		},
+		{
+			name:       "postgres above 95 percent is unhealthy",
+			pgStats:    sql.DBStats{MaxOpenConnections: 100, OpenConnections: 100, InUse: 96, Idle: 4, WaitCount: 340},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: healthStatusUnhealthy,
+		},
+		{
+			name:       "mongodb above 80 percent is degraded",
+			pgStats:    sql.DBStats{MaxOpenConnections: 20, InUse: 1},
+			mongoStats: mongodb.PoolStats{MaxConnections: 100, OpenConnections: 90, InUse: 90},
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusDegraded,
+		},
+		{
+			name:       "failed ping is unhealthy",
+			pingErr:    errors.New("connection refused"),
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: healthStatusUnhealthy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := serveHealth(t, newTestHealthHandler(tt.pgStats, tt.mongoStats, tt.pingErr))
+
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantStatus, body["status"])
+
+			pools := body["pools"].(map[string]any)
+			assert.EqualValues(t, tt.pgStats.OpenConnections, pools["postgres_pool_open_connections"])
+			assert.EqualValues(t, tt.pgStats.InUse, pools["postgres_pool_in_use"])
+			assert.EqualValues(t, tt.pgStats.Idle, pools["postgres_pool_idle"])
+			assert.EqualValues(t, tt.pgStats.WaitCount, pools["postgres_pool_wait_count"])
+			assert.EqualValues(t, tt.mongoStats.InUse, pools["mongodb_pool_in_use"])
+		})
+	}
+}
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/response"
@@ -19,6 +24,10 @@ type MessageHandler struct {
 	service             *services.MessageService
 	conversationService *services.ConversationService
 	companionService    *services.CompanionService
+	pacer               *services.ResponsePacer
+	moderation          *services.ContentModerationPipeline
+	lengthGuard         *services.ConversationLengthGuard
+	sessionBudget       *services.SessionBudgetService
 	pendingResponses    map[string]*time.Timer
 	responseMutex       sync.RWMutex
 	generatingResponses map[string]bool
@@ -27,11 +36,15 @@ type MessageHandler struct {
 	aggregationMax      time.Duration
 }
 
-func NewMessageHandler(service *services.MessageService, conversationService *services.ConversationService, companionService *services.CompanionService) *MessageHandler {
+func NewMessageHandler(service *services.MessageService, conversationService *services.ConversationService, companionService *services.CompanionService, pacer *services.ResponsePacer, moderation *services.ContentModerationPipeline, lengthGuard *services.ConversationLengthGuard, sessionBudget *services.SessionBudgetService) *MessageHandler {
 	return &MessageHandler{
 		service:             service,
 		conversationService: conversationService,
 		companionService:    companionService,
+		pacer:               pacer,
+		moderation:          moderation,
+		lengthGuard:         lengthGuard,
+		sessionBudget:       sessionBudget,
 		pendingResponses:    make(map[string]*time.Timer),
 		responseMutex:       sync.RWMutex{},
 		generatingResponses: make(map[string]bool),
@@ -43,15 +56,16 @@ func NewMessageHandler(service *services.MessageService, conversationService *se
 
 func MessageFromDTO(req dto.CreateMessageRequest, convID primitive.ObjectID, userID string, media *models.MediaMetadata) *models.Message {
 	return &models.Message{
-		ConversationID: convID,
-		SenderID:       userID,
-		SenderType:     "user",
-		Type:           messagetype.Type(req.Type),
-		Text:           req.Text,
-		Media:          media,
-		Sticker:        req.Sticker,
-		SystemEvent:    req.SystemEvent,
-		Read:           false,
+		ConversationID:       convID,
+		SenderID:             userID,
+		SenderType:           "user",
+		Type:                 messagetype.Type(req.Type),
+		Text:                 req.Text,
+		Media:                media,
+		Sticker:              req.Sticker,
+		SystemEvent:          req.SystemEvent,
+		Read:                 false,
+		ClientIdempotencyKey: req.ClientIdempotencyKey,
 	}
 }
 
@@ -78,18 +92,56 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		media, _ = h.service.GetMediaByID(c.Request.Context(), mediaID)
 	}
 	msg := MessageFromDTO(req, convID, user.ID.String(), media)
-	storedMsg, err := h.service.SendMessage(c.Request.Context(), msg)
+
+	if err := h.sessionBudget.CheckCanSend(c.Request.Context(), user.ID.String()); err != nil {
+		var budgetErr *services.SessionBudgetExhaustedError
+		if errors.As(err, &budgetErr) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(budgetErr.RetryAfter.Seconds()))))
+			response.Error(c, http.StatusTooManyRequests, apperrors.NewAppError(apperrors.ErrCodeRateLimited, "Daily conversation time limit reached; messages can be sent again tomorrow", err), nil)
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	if err := h.lengthGuard.CheckCanSend(c.Request.Context(), convID); err != nil {
+		if errors.Is(err, services.ErrConversationFull) {
+			response.Error(c, http.StatusConflict, apperrors.NewAppError(apperrors.ErrCodeConversationFull, "Conversation has reached its message limit; archive it or continue in a new conversation", err), nil)
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	// Screen message content before it is persisted
+	if !user.HasPermission(models.PermissionBypassModeration) {
+		if err := h.moderation.Moderate(c.Request.Context(), msg); err != nil {
+			if errors.Is(err, services.ErrContentFlagged) {
+				response.Error(c, http.StatusUnprocessableEntity, apperrors.NewAppError(apperrors.ErrCodeContentFlagged, "Message blocked by content moderation", err), nil)
+				return
+			}
+			response.InternalServerError(c, err, nil)
+			return
+		}
+	}
+
+	storedMsg, deduplicated, err := h.service.SendMessage(c.Request.Context(), msg)
 	if err != nil {
 		response.InternalServerError(c, err, nil)
 		return
 	}
+	if deduplicated {
+		// The original request already scheduled the companion's reply
+		response.Deduplicated(c, storedMsg, "Message sent")
+		return
+	}
 
 	h.responseMutex.Lock()
 
 	// Check if already generating a response for this conversation
 	if h.generatingResponses[convIDStr] {
 		h.responseMutex.Unlock()
-		response.Created(c, storedMsg, "Message sent")
+		response.Created(c, h.sendMessageResponse(c.Request.Context(), user.ID.String(), storedMsg), "Message sent")
 		return
 	}
 
@@ -109,6 +161,8 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		delay = 200 * time.Millisecond
 	}
 
+	// The reply is generated after this request returns, but stays part of its trace
+	ctx := context.WithoutCancel(c.Request.Context())
 	timer := time.AfterFunc(delay, func() {
 		h.responseMutex.Lock()
 		if h.generatingResponses[convIDStr] {
@@ -118,7 +172,7 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		h.generatingResponses[convIDStr] = true
 		h.responseMutex.Unlock()
 
-		h.generateBotResponse(convID, storedMsg)
+		h.generateBotResponse(ctx, convID, storedMsg)
 
 		h.responseMutex.Lock()
 		delete(h.pendingResponses, convIDStr)
@@ -130,23 +184,49 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	h.pendingResponses[convIDStr] = timer
 	h.responseMutex.Unlock()
 
-	response.Created(c, storedMsg, "Message sent")
+	response.Created(c, h.sendMessageResponse(c.Request.Context(), user.ID.String(), storedMsg), "Message sent")
+}
+
+// sendMessageResponse attaches an archive suggestion to the stored message once the conversation is past its soft
+// limit, and a wellness reminder once the user is past their soft daily time limit. Failing to check either limit
+// does not fail the send.
+func (h *MessageHandler) sendMessageResponse(ctx context.Context, userID string, msg *models.Message) *dto.SendMessageResponse {
+	resp := &dto.SendMessageResponse{Message: msg}
+	suggestion, err := h.lengthGuard.ArchiveSuggestion(ctx, msg.ConversationID)
+	if err != nil {
+		fmt.Printf("Failed to check conversation length: %v\n", err)
+	} else if suggestion != nil {
+		resp.SystemEvents = append(resp.SystemEvents, suggestion)
+	}
+
+	reminder, err := h.sessionBudget.WellnessReminder(ctx, userID, msg.ConversationID)
+	if err != nil {
+		fmt.Printf("Failed to check session budget: %v\n", err)
+	} else if reminder != nil {
+		resp.SystemEvents = append(resp.SystemEvents, reminder)
+	}
+	return resp
 }
 
-func (h *MessageHandler) generateBotResponse(convID primitive.ObjectID, userMsg *models.Message) {
-	conversation, err := h.conversationService.GetConversation(context.Background(), convID)
+func (h *MessageHandler) generateBotResponse(ctx context.Context, convID primitive.ObjectID, userMsg *models.Message) {
+	conversation, err := h.conversationService.GetConversation(ctx, convID)
 	if err != nil {
 		fmt.Printf("Failed to get conversation: %v\n", err)
 		return
 	}
 
-	companionProfile, err := h.companionService.GetCompanionProfile(context.Background(), conversation.CompanionID)
+	companionProfile, err := h.companionService.GetCompanionProfile(ctx, conversation.CompanionID)
 	if err != nil {
 		fmt.Printf("Failed to get companion profile: %v\n", err)
 		return
 	}
 
-	botResponse, err := h.service.GenerateAIResponse(context.Background(), conversation, userMsg, companionProfile)
+	if err := h.pacer.WaitForSlot(ctx, convID.Hex()); err != nil {
+		fmt.Printf("Failed to wait for response slot: %v\n", err)
+		return
+	}
+
+	botResponse, err := h.service.GenerateAIResponse(ctx, conversation, userMsg, companionProfile)
 	if err != nil {
 		fmt.Printf("Failed to generate AI response: %v\n", err)
 		return
@@ -190,6 +270,107 @@ func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	response.Success(c, nil, "Message marked as read")
 }
 
+// maxReadReceiptBatch bounds the number of messages one batch read receipt may mark
+const maxReadReceiptBatch = 100
+
+// MarkMessagesRead marks a batch of the conversation's messages as read. The body is a JSON array of message IDs.
+func (h *MessageHandler) MarkMessagesRead(c *gin.Context) {
+	var ids []string
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		response.BadRequest(c, err, nil)
+		return
+	}
+	if len(ids) == 0 {
+		response.BadRequest(c, nil, gin.H{"error": "At least one message ID is required"})
+		return
+	}
+	if len(ids) > maxReadReceiptBatch {
+		response.BadRequest(c, nil, gin.H{"error": fmt.Sprintf("At most %d messages can be marked read at once", maxReadReceiptBatch)})
+		return
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		messageID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			response.BadRequest(c, err, gin.H{"error": "Invalid message ID", "message_id": id})
+			return
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+	convID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	updated, err := h.service.MarkMessagesRead(c.Request.Context(), convID, user.ID.String(), messageIDs)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, nil)
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, gin.H{"updated": updated}, "Messages marked as read")
+}
+
+// AddReaction reacts to a message with an emoji
+func (h *MessageHandler) AddReaction(c *gin.Context) {
+	var req dto.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, nil)
+		return
+	}
+	h.changeReaction(c, req.Emoji, h.service.AddReaction, "Reaction added")
+}
+
+// RemoveReaction removes the user's emoji reaction from a message
+func (h *MessageHandler) RemoveReaction(c *gin.Context) {
+	h.changeReaction(c, c.Param("emoji"), h.service.RemoveReaction, "Reaction removed")
+}
+
+// changeReaction applies a reaction change for the authenticated user to the message in the path
+func (h *MessageHandler) changeReaction(c *gin.Context, emoji string, change func(context.Context, primitive.ObjectID, string, string) error, message string) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+	msgID, err := primitive.ObjectIDFromHex(c.Param("message_id"))
+	if err != nil {
+		response.BadRequest(c, err, nil)
+		return
+	}
+
+	if err := change(c.Request.Context(), msgID, user.ID.String(), emoji); err != nil {
+		var validationErr *apperrors.ValidationError
+		var notFound *apperrors.NotFoundError
+		switch {
+		case errors.As(err, &validationErr):
+			response.BadRequest(c, err, nil)
+		case errors.As(err, &notFound):
+			response.NotFound(c, err, nil)
+		default:
+			response.InternalServerError(c, err, nil)
+		}
+		return
+	}
+
+	response.Success(c, nil, message)
+}
+
 // GetConversationIntelligence retrieves conversation intelligence insights
 func (h *MessageHandler) GetConversationIntelligence(c *gin.Context) {
 	convIDStr := c.Param("id")
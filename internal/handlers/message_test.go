@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMarkMessagesReadValidatesBatch(t *testing.T) {
+	ids := func(n int) string {
+		batch := make([]string, n)
+		for i := range batch {
+			batch[i] = primitive.NewObjectID().Hex()
+		}
+		body, err := json.Marshal(batch)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "too many messages", body: ids(maxReadReceiptBatch + 1)},
+		{name: "empty batch", body: "[]"},
+		{name: "not an array", body: `{"ids": []}`},
+		{name: "invalid message ID", body: `["not-an-id"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/conversations/:id/messages/read", NewMessageHandler(nil, nil, nil, nil, nil, nil, nil).MarkMessagesRead)
+
+			rec := httptest.NewRecorder()
+			path := "/conversations/" + primitive.NewObjectID().Hex() + "/messages/read"
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+)
+
+type PrivacyHandler struct {
+	service *services.PrivacyAnalyticsService
+}
+
+func NewPrivacyHandler(service *services.PrivacyAnalyticsService) *PrivacyHandler {
+	return &PrivacyHandler{service: service}
+}
+
+// GetPercentileRank returns how the user's engagement compares to anonymised peers
+func (h *PrivacyHandler) GetPercentileRank(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	report, err := h.service.GetUserPercentileRank(c.Request.Context(), user.ID.String())
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, report, "Percentile rank computed")
+}
+
+// GetAggregatedInsights returns anonymised platform insights. Excluding test users is restricted to admins.
+func (h *PrivacyHandler) GetAggregatedInsights(c *gin.Context) {
+	excludeTestUsers, err := strconv.ParseBool(c.DefaultQuery("exclude_test_users", "false"))
+	if err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid exclude_test_users value"})
+		return
+	}
+	if excludeTestUsers && !c.GetBool("is_admin") {
+		response.Forbidden(c, nil, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	opts := services.InsightsOptions{ExcludeTestUsers: excludeTestUsers}
+	insights, err := h.service.GetAggregatedInsights(c.Request.Context(), c.DefaultQuery("period", "week"), c.DefaultQuery("privacy_level", "high"), opts)
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, insights, "Aggregated insights generated")
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAggregatedInsightsRequiresAdminToExcludeTestUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+	}{
+		{name: "non-admin excluding test users", query: "?exclude_test_users=true", wantCode: http.StatusForbidden},
+		{name: "invalid flag", query: "?exclude_test_users=maybe", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) { c.Set("is_admin", false) })
+			router.GET("/insights", NewPrivacyHandler(nil).GetAggregatedInsights)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/insights"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+)
+
+type SessionBudgetHandler struct {
+	service *services.SessionBudgetService
+}
+
+func NewSessionBudgetHandler(service *services.SessionBudgetService) *SessionBudgetHandler {
+	return &SessionBudgetHandler{service: service}
+}
+
+// GetSessionBudget returns the user's daily conversation time limits and today's usage
+func (h *SessionBudgetHandler) GetSessionBudget(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	budget, err := h.service.GetBudget(c.Request.Context(), user.ID.String())
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, gin.H{"error": "No session budget set"})
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+	response.Success(c, budget, "Session budget retrieved")
+}
+
+// SetSessionBudget creates or updates the user's daily conversation time limits
+func (h *SessionBudgetHandler) SetSessionBudget(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	var req dto.SessionBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	budget, err := h.service.SetBudget(c.Request.Context(), &models.SessionBudget{
+		UserID:                user.ID.String(),
+		DailySoftLimitMinutes: req.DailySoftLimitMinutes,
+		DailyHardLimitMinutes: req.DailyHardLimitMinutes,
+		Timezone:              req.Timezone,
+	})
+	if err != nil {
+		var validationErr *apperrors.ValidationError
+		if errors.As(err, &validationErr) {
+			response.BadRequest(c, err, nil)
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+	response.Success(c, budget, "Session budget saved")
+}
+
+// DeleteSessionBudget removes the user's daily conversation time limits
+func (h *SessionBudgetHandler) DeleteSessionBudget(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	if err := h.service.DeleteBudget(c.Request.Context(), user.ID.String()); err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			response.NotFound(c, err, gin.H{"error": "No session budget set"})
+			return
+		}
+		response.InternalServerError(c, err, nil)
+		return
+	}
+	response.Success(c, nil, "Session budget deleted")
+}
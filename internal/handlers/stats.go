@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+)
+
+type StatsHandler struct {
+	service *services.AnalyticsService
+}
+
+func NewStatsHandler(service *services.AnalyticsService) *StatsHandler {
+	return &StatsHandler{service: service}
+}
+
+// GetStatsSummary returns the stats summary card for the user's relationship with a companion
+func (h *StatsHandler) GetStatsSummary(c *gin.Context) {
+	userInterface, exists := c.Get("user")
+	if !exists {
+		response.Error(c, 401, nil, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user := userInterface.(*models.User)
+
+	companionID := c.Query("companion_id")
+	if companionID == "" {
+		response.BadRequest(c, nil, gin.H{"error": "companion_id is required"})
+		return
+	}
+
+	card, err := h.service.GetConversationStatsSummary(c.Request.Context(), user.ID.String(), companionID)
+	if err != nil {
+		response.InternalServerError(c, err, nil)
+		return
+	}
+
+	response.Success(c, card, "Stats summary retrieved")
+}
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/json"
+	nethttp "net/http"
+	"strings"
+)
+
+// Media types used for error responses
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+	ContentTypeJSON        = "application/json"
+)
+
+// problemTypeBaseURI is where the documentation for each problem type lives
+const problemTypeBaseURI = "https://lunaria.io/errors/"
+
+// Problem types, identified by the last segment of their documentation URI
+const (
+	TypeBadRequest         = "bad-request"
+	TypeValidation         = "validation-failed"
+	TypeUnauthorized       = "unauthorized"
+	TypeForbidden          = "forbidden"
+	TypeNotFound           = "not-found"
+	TypeConflict           = "conflict"
+	TypeConversationFull   = "conversation-full"
+	TypePayloadTooLarge    = "payload-too-large"
+	TypeContentFlagged     = "content-flagged"
+	TypeRateLimited        = "rate-limited"
+	TypeInternalError      = "internal-error"
+	TypeServiceUnavailable = "service-unavailable"
+)
+
+// ProblemType describes a kind of problem: its documentation URI, a short summary and the matching error code
+type ProblemType struct {
+	URI   string
+	Title string
+	Code  string
+}
+
+// problemTypes registers every problem type the API returns
+var problemTypes = map[string]ProblemType{
+	TypeBadRequest:         {URI: problemTypeBaseURI + TypeBadRequest, Title: "Bad Request", Code: "BAD_REQUEST"},
+	TypeValidation:         {URI: problemTypeBaseURI + TypeValidation, Title: "Validation Failed", Code: "VALIDATION_ERROR"},
+	TypeUnauthorized:       {URI: problemTypeBaseURI + TypeUnauthorized, Title: "Unauthorized", Code: "UNAUTHORIZED"},
+	TypeForbidden:          {URI: problemTypeBaseURI + TypeForbidden, Title: "Forbidden", Code: "FORBIDDEN"},
+	TypeNotFound:           {URI: problemTypeBaseURI + TypeNotFound, Title: "Not Found", Code: "NOT_FOUND"},
+	TypeConflict:           {URI: problemTypeBaseURI + TypeConflict, Title: "Conflict", Code: "CONFLICT"},
+	TypeConversationFull:   {URI: problemTypeBaseURI + TypeConversationFull, Title: "Conversation Full", Code: "CONVERSATION_FULL"},
+	TypePayloadTooLarge:    {URI: problemTypeBaseURI + TypePayloadTooLarge, Title: "Payload Too Large", Code: "PAYLOAD_TOO_LARGE"},
+	TypeContentFlagged:     {URI: problemTypeBaseURI + TypeContentFlagged, Title: "Content Flagged", Code: "CONTENT_FLAGGED"},
+	TypeRateLimited:        {URI: problemTypeBaseURI + TypeRateLimited, Title: "Too Many Requests", Code: "RATE_LIMITED"},
+	TypeInternalError:      {URI: problemTypeBaseURI + TypeInternalError, Title: "Internal Server Error", Code: "INTERNAL_ERROR"},
+	TypeServiceUnavailable: {URI: problemTypeBaseURI + TypeServiceUnavailable, Title: "Service Unavailable", Code: "SERVICE_UNAVAILABLE"},
+}
+
+// statusProblemTypes is the problem type used for a status when no more specific type applies
+var statusProblemTypes = map[int]string{
+	nethttp.StatusBadRequest:            TypeBadRequest,
+	nethttp.StatusUnauthorized:          TypeUnauthorized,
+	nethttp.StatusForbidden:             TypeForbidden,
+	nethttp.StatusNotFound:              TypeNotFound,
+	nethttp.StatusConflict:              TypeConflict,
+	nethttp.StatusRequestEntityTooLarge: TypePayloadTooLarge,
+	nethttp.StatusUnprocessableEntity:   TypeValidation,
+	nethttp.StatusTooManyRequests:       TypeRateLimited,
+	nethttp.StatusInternalServerError:   TypeInternalError,
+	nethttp.StatusServiceUnavailable:    TypeServiceUnavailable,
+}
+
+// ProblemDetail is an RFC 7807 problem details object. Code and Details are extension members carrying the API's
+// error code and any structured context.
+type ProblemDetail struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Details  any    `json:"details,omitempty"`
+}
+
+// ErrorEnvelope is the plain JSON error body sent to clients that accept JSON but not problem+json
+type ErrorEnvelope struct {
+	Status    int    `json:"status"`
+	Success   bool   `json:"success"`
+	Type      string `json:"type"`
+	ErrorCode string `json:"error_code"`
+	Error     string `json:"error"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// LookupType returns a registered problem type
+func LookupType(problemType string) (ProblemType, bool) {
+	registered, ok := problemTypes[problemType]
+	return registered, ok
+}
+
+// TypeForStatus returns the problem type used for status when nothing more specific is known
+func TypeForStatus(status int) string {
+	if problemType, ok := statusProblemTypes[status]; ok {
+		return problemType
+	}
+	if status >= nethttp.StatusInternalServerError {
+		return TypeInternalError
+	}
+	return TypeBadRequest
+}
+
+// TypeForCode returns the problem type registered for an API error code
+func TypeForCode(code string) (string, bool) {
+	for name, registered := range problemTypes {
+		if registered.Code == code {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// NewProblem builds the problem details for a registered problem type; the instance is the request path.
+// Unregistered types are reported as about:blank with the status text as title.
+func NewProblem(r *nethttp.Request, status int, problemType, detail string) *ProblemDetail {
+	problem := &ProblemDetail{
+		Type:   "about:blank",
+		Title:  nethttp.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+	if registered, ok := problemTypes[problemType]; ok {
+		problem.Type = registered.URI
+		problem.Title = registered.Title
+		problem.Code = registered.Code
+	}
+	if r != nil && r.URL != nil {
+		problem.Instance = r.URL.Path
+	}
+	return problem
+}
+
+// WriteProblem writes an error response for a registered problem type
+func WriteProblem(w nethttp.ResponseWriter, r *nethttp.Request, status int, problemType, detail string) {
+	WriteProblemDetail(w, r, NewProblem(r, status, problemType, detail))
+}
+
+// WriteProblemDetail writes problem as application/problem+json, or as a plain JSON ErrorEnvelope when the client
+// accepts application/json but not application/problem+json
+func WriteProblemDetail(w nethttp.ResponseWriter, r *nethttp.Request, problem *ProblemDetail) {
+	var body any = problem
+	contentType := ContentTypeProblemJSON
+	if r != nil && prefersPlainJSON(r.Header.Get("Accept")) {
+		contentType = ContentTypeJSON
+		body = ErrorEnvelope{
+			Status:    problem.Status,
+			Type:      problem.Type,
+			ErrorCode: problem.Code,
+			Error:     problem.Detail,
+			Details:   problem.Details,
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// prefersPlainJSON reports whether an Accept header asks for application/json without application/problem+json
+func prefersPlainJSON(accept string) bool {
+	plain := false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case ContentTypeProblemJSON:
+			return false
+		case ContentTypeJSON:
+			plain = true
+		}
+	}
+	return plain
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProblemRegisteredTypes(t *testing.T) {
+	for name, registered := range problemTypes {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(nethttp.MethodGet, "/api/v1/analytics/summary", nil)
+			rec := httptest.NewRecorder()
+
+			WriteProblem(rec, req, nethttp.StatusBadRequest, name, "something went wrong")
+
+			assert.Equal(t, nethttp.StatusBadRequest, rec.Code)
+			assert.Equal(t, ContentTypeProblemJSON, rec.Header().Get("Content-Type"))
+
+			var problem ProblemDetail
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, problemTypeBaseURI+name, problem.Type)
+			assert.Equal(t, registered.Title, problem.Title)
+			assert.Equal(t, nethttp.StatusBadRequest, problem.Status)
+			assert.Equal(t, "something went wrong", problem.Detail)
+			assert.Equal(t, "/api/v1/analytics/summary", problem.Instance)
+			assert.Equal(t, registered.Code, problem.Code)
+		})
+	}
+}
+
+func TestWriteProblemContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantEnvelope    bool
+	}{
+		{name: "no accept header", wantContentType: ContentTypeProblemJSON},
+		{name: "problem json", accept: "application/problem+json", wantContentType: ContentTypeProblemJSON},
+		{name: "both accepted", accept: "application/json, application/problem+json", wantContentType: ContentTypeProblemJSON},
+		{name: "wildcard", accept: "*/*", wantContentType: ContentTypeProblemJSON},
+		{name: "plain json", accept: "application/json", wantContentType: ContentTypeJSON, wantEnvelope: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(nethttp.MethodGet, "/api/v1/conversations/abc", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			WriteProblem(rec, req, nethttp.StatusNotFound, TypeNotFound, "Conversation not found")
+
+			assert.Equal(t, nethttp.StatusNotFound, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			if tt.wantEnvelope {
+				assert.Equal(t, false, body["success"])
+				assert.Equal(t, "NOT_FOUND", body["error_code"])
+				assert.Equal(t, "Conversation not found", body["error"])
+				assert.Equal(t, problemTypeBaseURI+TypeNotFound, body["type"])
+				return
+			}
+			assert.Equal(t, problemTypeBaseURI+TypeNotFound, body["type"])
+			assert.Equal(t, "Conversation not found", body["detail"])
+			assert.NotContains(t, body, "success")
+		})
+	}
+}
+
+func TestNewProblemUnknownTypeIsAboutBlank(t *testing.T) {
+	problem := NewProblem(nil, nethttp.StatusTeapot, "no-such-type", "")
+
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, nethttp.StatusText(nethttp.StatusTeapot), problem.Title)
+	assert.Empty(t, problem.Code)
+	assert.Empty(t, problem.Instance)
+}
+
+func TestTypeForStatus(t *testing.T) {
+	assert.Equal(t, TypeNotFound, TypeForStatus(nethttp.StatusNotFound))
+	assert.Equal(t, TypeRateLimited, TypeForStatus(nethttp.StatusTooManyRequests))
+	assert.Equal(t, TypeInternalError, TypeForStatus(nethttp.StatusBadGateway))
+	assert.Equal(t, TypeBadRequest, TypeForStatus(nethttp.StatusMethodNotAllowed))
+}
+
+func TestTypeForCode(t *testing.T) {
+	problemType, ok := TypeForCode("CONVERSATION_FULL")
+	assert.True(t, ok)
+	assert.Equal(t, TypeConversationFull, problemType)
+
+	_, ok = TypeForCode("NOT_A_CODE")
+	assert.False(t, ok)
+}
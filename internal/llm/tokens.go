@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// charsPerToken is the average number of characters per token for English text in GPT/Grok tokenizers
+const charsPerToken = 4.0
+
+// TokenCounter approximates tokenizer output without loading a vocabulary
+type TokenCounter struct{}
+
+// NewTokenCounter creates a new token counter
+func NewTokenCounter() *TokenCounter {
+	return &TokenCounter{}
+}
+
+// Count estimates the number of tokens in text by blending a character-based estimate
+// with a word-boundary estimate, since tokenizers rarely merge across words or punctuation
+func (c *TokenCounter) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	charEstimate := float64(utf8.RuneCountInString(text)) / charsPerToken
+	wordEstimate := float64(countWordPieces(text))
+
+	tokens := int(math.Round((charEstimate + wordEstimate) / 2))
+	if tokens < 1 {
+		return 1
+	}
+	return tokens
+}
+
+// countWordPieces counts words, digit groups of up to three, and standalone symbols
+func countWordPieces(text string) int {
+	pieces := 0
+	letters := 0
+	digits := 0
+
+	flush := func() {
+		if letters > 0 {
+			pieces++
+			letters = 0
+		}
+		if digits > 0 {
+			pieces += (digits + 2) / 3
+			digits = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || r == '\'':
+			if digits > 0 {
+				flush()
+			}
+			letters++
+		case unicode.IsDigit(r):
+			if letters > 0 {
+				flush()
+			}
+			digits++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			pieces++
+		}
+	}
+	flush()
+
+	return pieces
+}
@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCounterApproximation(t *testing.T) {
+	counter := NewTokenCounter()
+
+	// Reference counts from the cl100k tokenizer
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"How are you feeling today? I missed you!", 10},
+		{"I had a really long day at work and I just want to talk to someone.", 17},
+		{"Thank you so much for listening to me, it really means a lot.", 15},
+	}
+
+	for _, tt := range tests {
+		got := counter.Count(tt.text)
+		errorRate := math.Abs(float64(got-tt.want)) / float64(tt.want)
+		assert.LessOrEqual(t, errorRate, 0.10, "%q: got %d tokens, want %d", tt.text, got, tt.want)
+	}
+}
+
+func TestTokenCounterEdgeCases(t *testing.T) {
+	counter := NewTokenCounter()
+
+	assert.Equal(t, 0, counter.Count(""))
+	assert.Equal(t, 1, counter.Count("hi"))
+	assert.Equal(t, 2, countWordPieces("1994"))
+}
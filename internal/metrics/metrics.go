@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Token directions for GrokTokens
+const (
+	DirectionInput  = "input"
+	DirectionOutput = "output"
+)
+
+// MetricsCollector holds the application's Prometheus metrics
+type MetricsCollector struct {
+	grokRequestDuration  *prometheus.HistogramVec
+	grokTokens           *prometheus.CounterVec
+	grokErrors           *prometheus.CounterVec
+	achievementsUnlocked *prometheus.CounterVec
+}
+
+// Default is registered with the default Prometheus registry served on /metrics
+var Default = NewMetricsCollector(prometheus.DefaultRegisterer)
+
+// NewMetricsCollector creates the metrics and registers them with registerer
+func NewMetricsCollector(registerer prometheus.Registerer) *MetricsCollector {
+	m := &MetricsCollector{
+		grokRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grok_request_duration_seconds",
+			Help:    "Duration of Grok API requests.",
+			Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32},
+		}, []string{"model", "call_type"}),
+		grokTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_tokens_total",
+			Help: "Tokens sent to and received from the Grok API.",
+		}, []string{"direction"}),
+		grokErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grok_errors_total",
+			Help: "Failed Grok API calls by kind of error.",
+		}, []string{"error_kind"}),
+		achievementsUnlocked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "achievements_unlocked_total",
+			Help: "Achievements awarded to users by rarity and category.",
+		}, []string{"rarity", "category"}),
+	}
+
+	registerer.MustRegister(m.grokRequestDuration, m.grokTokens, m.grokErrors, m.achievementsUnlocked)
+	return m
+}
+
+// ObserveGrokRequest records the duration of a Grok API request
+func (m *MetricsCollector) ObserveGrokRequest(model, callType string, duration time.Duration) {
+	m.grokRequestDuration.WithLabelValues(model, callType).Observe(duration.Seconds())
+}
+
+// AddGrokTokens records the prompt and completion tokens of a Grok API response
+func (m *MetricsCollector) AddGrokTokens(input, output int) {
+	m.grokTokens.WithLabelValues(DirectionInput).Add(float64(input))
+	m.grokTokens.WithLabelValues(DirectionOutput).Add(float64(output))
+}
+
+// IncGrokError counts a failed Grok API call
+func (m *MetricsCollector) IncGrokError(kind string) {
+	m.grokErrors.WithLabelValues(kind).Inc()
+}
+
+// IncAchievementUnlocked counts an achievement awarded to a user
+func (m *MetricsCollector) IncAchievementUnlocked(rarity, category string) {
+	m.achievementsUnlocked.WithLabelValues(rarity, category).Inc()
+}
@@ -54,6 +54,7 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 
 		c.Set("user", user)
 		c.Set("user_id", user.ID)
+		c.Set("is_admin", claims.Admin)
 		c.Next()
 	}
 }
@@ -82,6 +83,19 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 		c.Set("user", user)
 		c.Set("user_id", user.ID)
+		c.Set("is_admin", claims.Admin)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests whose access token does not carry the admin claim. It must run after RequireAuth.
+func (m *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("is_admin") {
+			response.Forbidden(c, fmt.Errorf("admin access required"), gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
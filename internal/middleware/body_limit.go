@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+)
+
+// MaxBodySizeMiddleware rejects requests whose body is larger than maxBytes with 413. The body is read through a
+// limited reader, so an oversized payload is never held in memory beyond maxBytes+1 bytes; accepted bodies are
+// buffered and handed on to the handler.
+func MaxBodySizeMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			abortTooLarge(c, maxBytes)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		_ = c.Request.Body.Close()
+		if err != nil {
+			response.BadRequest(c, err, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		if int64(len(body)) > maxBytes {
+			abortTooLarge(c, maxBytes)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	message := fmt.Sprintf("Request body exceeds the %d byte limit", maxBytes)
+	response.Error(c, http.StatusRequestEntityTooLarge, apperrors.NewAppError(apperrors.ErrCodePayloadTooLarge, message, nil), gin.H{"max_bytes": maxBytes})
+	c.Abort()
+}
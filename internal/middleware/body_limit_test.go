@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBodyLimitRouter(maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MaxBodySizeMiddleware(maxBytes))
+	router.POST("/messages", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, "%d", len(body))
+	})
+	return router
+}
+
+func TestMaxBodySizeMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          int
+		contentLength bool
+		wantCode      int
+	}{
+		{name: "within the limit", size: 1024, contentLength: true, wantCode: http.StatusOK},
+		{name: "exactly the limit", size: 64 << 10, contentLength: true, wantCode: http.StatusOK},
+		{name: "declared too large", size: 64<<10 + 1, contentLength: true, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "streamed too large", size: 1 << 20, contentLength: false, wantCode: http.StatusRequestEntityTooLarge},
+	}
+
+	router := newBodyLimitRouter(64 << 10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(strings.Repeat("a", tt.size)))
+			if !tt.contentLength {
+				// Chunked requests do not declare their size, so the body itself must be measured
+				req.ContentLength = -1
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, strconv.Itoa(tt.size), rec.Body.String(), "the handler sees the whole body")
+			} else {
+				assert.Contains(t, rec.Body.String(), "PAYLOAD_TOO_LARGE")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipResponseWriter compresses everything written to the response body
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	writer  *gzip.Writer
+	written bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	// The compressed length is unknown until the body is finished
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeaderNow()
+	w.written = true
+	return w.writer.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) Flush() {
+	_ = w.writer.Flush()
+	w.ResponseWriter.Flush()
+}
+
+// CompressionMiddleware gzips response bodies for clients that send Accept-Encoding: gzip
+func CompressionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.Request) || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(c.Writer)
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, writer: gz}
+		c.Writer = writer
+		c.Header("Content-Encoding", "gzip")
+
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			if !writer.written {
+				// Nothing was written, so the response goes out empty rather than as an empty gzip stream
+				c.Writer.Header().Del("Content-Encoding")
+			} else {
+				_ = gz.Close()
+			}
+			gz.Reset(nil)
+			gzipWriterPool.Put(gz)
+		}()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether the request lists gzip among its accepted encodings
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeJSONPayload builds a dashboard-like payload of about 100KB
+func largeJSONPayload() gin.H {
+	trend := make([]gin.H, 0, 2000)
+	for i := 0; len(trend) < cap(trend); i++ {
+		trend = append(trend, gin.H{"day": fmt.Sprintf("2025-01-%02d", i%28+1), "messages": i % 97, "engagement_score": float64(i%50) / 50})
+	}
+	return gin.H{"user_id": "user-1", "trend": trend}
+}
+
+func newCompressionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CompressionMiddleware())
+	router.GET("/dashboard", func(c *gin.Context) {
+		c.JSON(http.StatusOK, largeJSONPayload())
+	})
+	router.DELETE("/dashboard", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+func serve(router http.Handler, method, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/dashboard", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	router := newCompressionRouter()
+	plain := serve(router, http.MethodGet, "")
+	require.Equal(t, http.StatusOK, plain.Code)
+
+	t.Run("compresses for clients accepting gzip", func(t *testing.T) {
+		rec := serve(router, http.MethodGet, "br, gzip;q=0.8")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+
+		reader, err := gzip.NewReader(rec.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.JSONEq(t, plain.Body.String(), string(body))
+	})
+
+	t.Run("reduces a 100KB JSON response by at least 60%", func(t *testing.T) {
+		require.GreaterOrEqual(t, plain.Body.Len(), 100*1024)
+
+		compressed := serve(router, http.MethodGet, "gzip").Body.Len()
+		assert.LessOrEqual(t, float64(compressed), 0.4*float64(plain.Body.Len()))
+	})
+
+	t.Run("leaves responses alone for clients not accepting gzip", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "br", "gzip;q=0"} {
+			rec := serve(router, http.MethodGet, acceptEncoding)
+			assert.Empty(t, rec.Header().Get("Content-Encoding"), acceptEncoding)
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			assert.Equal(t, plain.Body.Bytes(), rec.Body.Bytes())
+		}
+	})
+
+	t.Run("does not encode empty responses", func(t *testing.T) {
+		rec := serve(router, http.MethodDelete, "gzip")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Zero(t, rec.Body.Len())
+	})
+}
+
+func BenchmarkCompressionMiddleware(b *testing.B) {
+	router := newCompressionRouter()
+	plain := serve(router, http.MethodGet, "").Body.Len()
+
+	var compressed int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compressed = serve(router, http.MethodGet, "gzip").Body.Len()
+	}
+	b.StopTimer()
+
+	reduction := 1 - float64(compressed)/float64(plain)
+	b.ReportMetric(reduction*100, "%reduction")
+	if reduction < 0.6 {
+		b.Fatalf("compressed %d bytes to %d, a %.0f%% reduction", plain, compressed, reduction*100)
+	}
+}
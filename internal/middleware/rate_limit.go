@@ -0,0 +1,340 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/response"
+)
+
+// ErrRateLimited is returned when a user exceeds their request allowance
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError carries how long the caller should wait before retrying
+type RateLimitError struct {
+	RetryAfter time.Duration
+	ResetAt    time.Time // when the next request will be allowed
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimitStore keeps per-window request counters
+type RateLimitStore interface {
+	IncrementCounter(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	GetCounter(ctx context.Context, key string) (int64, error)
+	DecrementCounter(ctx context.Context, key string) error
+}
+
+// clusterPeers lists the other server instances
+type clusterPeers interface {
+	ListPeers() []cluster.NodeInfo
+}
+
+// Logger receives errors the middleware recovers from; *slog.Logger and the services' loggers satisfy it
+type Logger interface {
+	Error(msg string, args ...any)
+}
+
+// RateLimiter limits requests per user with a sliding-window counter
+type RateLimiter struct {
+	store  RateLimitStore
+	limit  int
+	burst  int
+	window time.Duration
+	now    func() time.Time
+	peers  clusterPeers
+	scope  string
+	logger Logger
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerMinute plus burst requests in any sliding minute. Counter
+// store errors are logged to logger, or slog.Default() if it is nil.
+func NewRateLimiter(store RateLimitStore, requestsPerMinute, burst int, logger Logger) *RateLimiter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RateLimiter{
+		store:  store,
+		limit:  requestsPerMinute,
+		burst:  burst,
+		window: time.Minute,
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// ShareAcrossPeers splits the allowance evenly between this instance and its peers. Use it when the store is local
+// to the instance, so a user spreading requests over the cluster still gets the configured allowance overall.
+func (l *RateLimiter) ShareAcrossPeers(peers clusterPeers) {
+	l.peers = peers
+}
+
+// Scope keeps this limiter's counters apart from those of other limiters sharing the store
+func (l *RateLimiter) Scope(name string) *RateLimiter {
+	l.scope = name
+	return l
+}
+
+// allowance is the number of requests this instance accepts per sliding window
+func (l *RateLimiter) allowance() float64 {
+	allowance := float64(l.limit + l.burst)
+	if l.peers == nil {
+		return allowance
+	}
+	return math.Max(1, allowance/float64(len(l.peers.ListPeers())+1))
+}
+
+// WaitOrError records a request for userID, or returns a *RateLimitError if the user is over the limit.
+// If ctx has a deadline that leaves enough time for the window to reset, it waits and tries once more.
+func (l *RateLimiter) WaitOrError(ctx context.Context, userID string) error {
+	retryAfter, err := l.acquire(ctx, userID)
+	if err != nil || retryAfter == 0 {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && l.now().Add(retryAfter).Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+		retryAfter, err = l.acquire(ctx, userID)
+		if err != nil || retryAfter == 0 {
+			return err
+		}
+	}
+
+	return &RateLimitError{RetryAfter: retryAfter, ResetAt: l.now().Add(retryAfter)}
+}
+
+// acquire counts the request and returns a non-zero retry delay if it must be rejected
+func (l *RateLimiter) acquire(ctx context.Context, userID string) (time.Duration, error) {
+	now := l.now()
+	windowStart := now.Truncate(l.window)
+	currentKey := l.key(userID, windowStart)
+	previousKey := l.key(userID, windowStart.Add(-l.window))
+
+	current, err := l.store.IncrementCounter(ctx, currentKey, 2*l.window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment rate limit counter: %w", err)
+	}
+
+	previous, err := l.store.GetCounter(ctx, previousKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rate limit counter: %w", err)
+	}
+
+	// Weight the previous window by how much of it still overlaps the sliding window
+	overlap := 1 - float64(now.Sub(windowStart))/float64(l.window)
+	estimate := float64(previous)*overlap + float64(current)
+
+	if estimate <= l.allowance() {
+		return 0, nil
+	}
+
+	if err := l.store.DecrementCounter(ctx, currentKey); err != nil {
+		return 0, fmt.Errorf("failed to decrement rate limit counter: %w", err)
+	}
+
+	retryAfter := l.availableAt(now, windowStart, float64(previous), float64(current-1)).Sub(now)
+	if retryAfter <= 0 {
+		// A zero delay means the request was allowed, so never report one for a rejection
+		retryAfter = time.Millisecond
+	}
+	return retryAfter, nil
+}
+
+// TokensAvailableAt returns when userID will next be allowed a request, or now if one is allowed immediately
+func (l *RateLimiter) TokensAvailableAt(ctx context.Context, userID string) (time.Time, error) {
+	now := l.now()
+	windowStart := now.Truncate(l.window)
+
+	current, err := l.store.GetCounter(ctx, l.key(userID, windowStart))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rate limit counter: %w", err)
+	}
+	previous, err := l.store.GetCounter(ctx, l.key(userID, windowStart.Add(-l.window)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rate limit counter: %w", err)
+	}
+
+	return l.availableAt(now, windowStart, float64(previous), float64(current)), nil
+}
+
+// availableAt finds the earliest time from now at which one more request fits the allowance, given the counts of the
+// previous and current windows. The previous window's requests drain out of the sliding window at a steady
+// previous/window per second, which is the rate at which allowance refills.
+func (l *RateLimiter) availableAt(now, windowStart time.Time, previous, current float64) time.Time {
+	allowance := l.allowance()
+	if allowance < 1 {
+		// Nothing is ever allowed; report when every counted request has left the sliding window
+		return windowStart.Add(2 * l.window)
+	}
+
+	for {
+		headroom := allowance - current - 1
+		switch {
+		case headroom >= previous:
+			// Fits even while the whole previous window still counts
+			return latest(now, windowStart)
+		case headroom >= 0:
+			// Fits once the previous window's weight has drained to headroom
+			elapsed := time.Duration(math.Ceil(float64(l.window) * (1 - headroom/previous)))
+			return latest(now, windowStart.Add(elapsed))
+		}
+		// The current window alone is over the allowance; it becomes the previous window of the next one
+		windowStart = windowStart.Add(l.window)
+		previous, current = current, 0
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (l *RateLimiter) key(userID string, windowStart time.Time) string {
+	if l.scope != "" {
+		return fmt.Sprintf("ratelimit:%s:%s:%d", l.scope, userID, windowStart.Unix())
+	}
+	return fmt.Sprintf("ratelimit:%s:%d", userID, windowStart.Unix())
+}
+
+// Middleware rejects requests from users over the limit with 429, a Retry-After header giving the seconds until the
+// next request is allowed, and an X-RateLimit-Reset header giving that time as a Unix timestamp
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userInterface, exists := c.Get("user")
+		if !exists {
+			c.Next()
+			return
+		}
+		user := userInterface.(*models.User)
+
+		err := l.WaitOrError(c.Request.Context(), user.ID.String())
+		var limitErr *RateLimitError
+		if errors.As(err, &limitErr) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(limitErr.ResetAt.Add(time.Second-1).Unix(), 10))
+			response.Error(c, http.StatusTooManyRequests, apperrors.NewAppError(apperrors.ErrCodeRateLimited, "Too many requests", err), nil)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			// Fail open so a counter store outage does not block messaging
+			l.logger.Error("Rate limiter error", "user_id", user.ID.String(), "error", err)
+		}
+
+		c.Next()
+	}
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore for environments without Redis
+type MemoryRateLimitStore struct {
+	counters  sync.Map
+	mu        sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryCounter struct {
+	mu        sync.Mutex
+	count     int64
+	expiresAt time.Time
+}
+
+// NewMemoryRateLimitStore creates a new in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{now: time.Now}
+}
+
+// IncrementCounter increments a counter and sets its expiration when it is first created
+func (s *MemoryRateLimitStore) IncrementCounter(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	now := s.now()
+	s.sweep(now, expiration)
+
+	value, _ := s.counters.LoadOrStore(key, &memoryCounter{})
+	counter := value.(*memoryCounter)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if counter.expiresAt.IsZero() || !now.Before(counter.expiresAt) {
+		counter.count = 0
+		counter.expiresAt = now.Add(expiration)
+	}
+	counter.count++
+	return counter.count, nil
+}
+
+// GetCounter returns the current value of a counter, or zero if it does not exist
+func (s *MemoryRateLimitStore) GetCounter(ctx context.Context, key string) (int64, error) {
+	value, ok := s.counters.Load(key)
+	if !ok {
+		return 0, nil
+	}
+	counter := value.(*memoryCounter)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if !s.now().Before(counter.expiresAt) {
+		return 0, nil
+	}
+	return counter.count, nil
+}
+
+// DecrementCounter decrements a counter
+func (s *MemoryRateLimitStore) DecrementCounter(ctx context.Context, key string) error {
+	value, ok := s.counters.Load(key)
+	if !ok {
+		return nil
+	}
+	counter := value.(*memoryCounter)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if counter.count > 0 {
+		counter.count--
+	}
+	return nil
+}
+
+// sweep drops expired counters at most once per interval
+func (s *MemoryRateLimitStore) sweep(now time.Time, interval time.Duration) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < interval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	s.counters.Range(func(key, value any) bool {
+		counter := value.(*memoryCounter)
+		counter.mu.Lock()
+		expired := !now.Before(counter.expiresAt)
+		counter.mu.Unlock()
+		if expired {
+			s.counters.Delete(key)
+		}
+		return true
+	})
+}
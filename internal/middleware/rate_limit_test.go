@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterWaitOrError(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		burst     int
+		requests  int
+		spacing   time.Duration
+		allowed   int
+		lastError bool
+	}{
+		{name: "burst within limit and allowance", limit: 5, burst: 3, requests: 8, allowed: 8},
+		{name: "burst over limit and allowance", limit: 5, burst: 3, requests: 12, allowed: 8, lastError: true},
+		{name: "sustained rate under limit", limit: 10, requests: 30, spacing: 7 * time.Second, allowed: 30},
+		{name: "sustained rate over limit", limit: 10, requests: 30, spacing: 3 * time.Second, allowed: 14, lastError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			store := NewMemoryRateLimitStore()
+			store.now = func() time.Time { return now }
+			limiter := NewRateLimiter(store, tt.limit, tt.burst, nil)
+			limiter.now = store.now
+
+			allowed := 0
+			var err error
+			for i := 0; i < tt.requests; i++ {
+				err = limiter.WaitOrError(context.Background(), "user")
+				if err == nil {
+					allowed++
+				}
+				now = now.Add(tt.spacing)
+			}
+
+			assert.Equal(t, tt.allowed, allowed)
+			if tt.lastError {
+				assert.ErrorIs(t, err, ErrRateLimited)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 40, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	limiter := NewRateLimiter(store, 1, 0, nil)
+	limiter.now = store.now
+
+	assert.NoError(t, limiter.WaitOrError(context.Background(), "user"))
+
+	err := limiter.WaitOrError(context.Background(), "user")
+	var limitErr *RateLimitError
+	assert.True(t, errors.As(err, &limitErr))
+	// The request made at 12:00:40 keeps counting in the sliding window until 12:02:00
+	assert.Equal(t, 80*time.Second, limitErr.RetryAfter)
+	assert.Equal(t, time.Date(2025, 1, 1, 12, 2, 0, 0, time.UTC), limitErr.ResetAt)
+
+	// Other users are unaffected
+	assert.NoError(t, limiter.WaitOrError(context.Background(), "other"))
+}
+
+type staticPeers []cluster.NodeInfo
+
+func (p staticPeers) ListPeers() []cluster.NodeInfo { return p }
+
+func TestRateLimiterShareAcrossPeers(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	limiter := NewRateLimiter(store, 10, 2, nil)
+	limiter.now = store.now
+	limiter.ShareAcrossPeers(staticPeers{{NodeID: "b"}, {NodeID: "c"}})
+
+	allowed := 0
+	for i := 0; i < 12; i++ {
+		if limiter.WaitOrError(context.Background(), "user") == nil {
+			allowed++
+		}
+	}
+
+	// Three instances share the allowance of 12 requests
+	assert.Equal(t, 4, allowed)
+}
+
+func TestRateLimiterScope(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	messages := NewRateLimiter(store, 1, 0, nil)
+	revalidations := NewRateLimiter(store, 1, 0, nil).Scope("revalidate")
+
+	assert.NoError(t, messages.WaitOrError(context.Background(), "user"))
+	assert.NoError(t, revalidations.WaitOrError(context.Background(), "user"), "scoped limiters keep separate counters")
+	assert.ErrorIs(t, revalidations.WaitOrError(context.Background(), "user"), ErrRateLimited)
+}
+
+func TestRateLimiterTokensAvailableAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Time
+		previous int
+		current  int
+		want     time.Time
+	}{
+		{name: "allowance left", at: time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC), current: 2, want: time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)},
+		{name: "previous window drains", at: time.Date(2025, 1, 1, 12, 0, 10, 0, time.UTC), previous: 8, current: 1, want: time.Date(2025, 1, 1, 12, 0, 45, 0, time.UTC)},
+		{name: "current window full", at: time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC), current: 4, want: time.Date(2025, 1, 1, 12, 1, 15, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.at
+			store := NewMemoryRateLimitStore()
+			store.now = func() time.Time { return now }
+			limiter := NewRateLimiter(store, 4, 0, nil)
+			limiter.now = store.now
+
+			windowStart := now.Truncate(time.Minute)
+			for i := 0; i < tt.previous; i++ {
+				_, err := store.IncrementCounter(context.Background(), limiter.key("user", windowStart.Add(-time.Minute)), 2*time.Minute)
+				require.NoError(t, err)
+			}
+			for i := 0; i < tt.current; i++ {
+				_, err := store.IncrementCounter(context.Background(), limiter.key("user", windowStart), 2*time.Minute)
+				require.NoError(t, err)
+			}
+
+			at, err := limiter.TokensAvailableAt(context.Background(), "user")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, at)
+		})
+	}
+}
+
+func TestRateLimiterMiddlewareRetryAfterHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	limiter := NewRateLimiter(store, 5, 0, nil)
+	limiter.now = store.now
+
+	user := &models.User{ID: uuid.New()}
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", user) })
+	router.GET("/messages", limiter.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/messages", nil))
+		return rec
+	}
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, send().Code)
+	}
+	rec := send()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// Five requests at 12:00:30 weigh 5 * (1 - 12s/60s) = 4 at 12:01:12, leaving room for one more
+	expected := time.Date(2025, 1, 1, 12, 1, 12, 0, time.UTC)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, expected.Sub(now).Seconds(), retryAfter, 1)
+	reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, expected.Unix(), reset, 1)
+
+	now = now.Add(time.Duration(retryAfter) * time.Second)
+	assert.Equal(t, http.StatusOK, send().Code)
+}
+
+// unavailableRateLimitStore fails every counter operation
+type unavailableRateLimitStore struct{}
+
+func (unavailableRateLimitStore) IncrementCounter(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return 0, errors.New("redis unavailable")
+}
+
+func (unavailableRateLimitStore) GetCounter(ctx context.Context, key string) (int64, error) {
+	return 0, errors.New("redis unavailable")
+}
+
+func (unavailableRateLimitStore) DecrementCounter(ctx context.Context, key string) error {
+	return errors.New("redis unavailable")
+}
+
+type recordingLogger struct {
+	errors []string
+}
+
+func (l *recordingLogger) Error(msg string, args ...any) {
+	l.errors = append(l.errors, msg)
+}
+
+func TestRateLimiterMiddlewareFailsOpenAndLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := &recordingLogger{}
+	limiter := NewRateLimiter(unavailableRateLimitStore{}, 5, 0, logger)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user", &models.User{ID: uuid.New()}) })
+	router.GET("/messages", limiter.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/messages", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"Rate limiter error"}, logger.errors)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ginContextKey carries the gin context through the otelhttp handler
+type ginContextKey struct{}
+
+// tracedResponseWriter sends the response through the otelhttp writer, so the server span records the status code
+// gin writes
+type tracedResponseWriter struct {
+	gin.ResponseWriter
+	traced http.ResponseWriter
+}
+
+func (w *tracedResponseWriter) WriteHeader(code int) {
+	w.traced.WriteHeader(code)
+}
+
+func (w *tracedResponseWriter) Write(data []byte) (int, error) {
+	return w.traced.Write(data)
+}
+
+func (w *tracedResponseWriter) WriteString(s string) (int, error) {
+	return w.traced.Write([]byte(s))
+}
+
+// TracingMiddleware starts a server span for every request with otelhttp, continuing any trace in the incoming
+// headers. The span is named after the matched route and is carried in c.Request.Context(), so repositories and
+// clients called with that context record their spans as its children.
+func TracingMiddleware() gin.HandlerFunc {
+	handler := otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(ginContextKey{}).(*gin.Context)
+		writer := c.Writer
+		c.Request = r
+		c.Writer = &tracedResponseWriter{ResponseWriter: writer, traced: w}
+		defer func() { c.Writer = writer }()
+
+		c.Next()
+	}), "http.server", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		c := r.Context().Value(ginContextKey{}).(*gin.Context)
+		if c.FullPath() == "" {
+			return r.Method
+		}
+		return r.Method + " " + c.FullPath()
+	}))
+
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ginContextKey{}, c))
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
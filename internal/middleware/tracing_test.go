@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/sahmaragaev/lunaria-backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/wiremessage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeMongoServer answers MongoDB wire protocol commands with canned replies: hello succeeds, find returns an empty
+// cursor and anything else returns ok. It is just enough for the driver to connect and run a query.
+func fakeMongoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeMongo(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveFakeMongo(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		wm := make([]byte, binary.LittleEndian.Uint32(size[:]))
+		copy(wm, size[:])
+		if _, err := io.ReadFull(conn, wm[4:]); err != nil {
+			return
+		}
+
+		_, requestID, _, _, rem, _ := wiremessage.ReadHeader(wm)
+		_, rem, _ = wiremessage.ReadMsgFlags(rem)
+		_, rem, _ = wiremessage.ReadMsgSectionType(rem)
+		command, _, _ := wiremessage.ReadMsgSectionSingleDocument(rem)
+
+		if _, err := conn.Write(fakeMongoReply(requestID, command)); err != nil {
+			return
+		}
+	}
+}
+
+func fakeMongoReply(requestID int32, command bsoncore.Document) []byte {
+	reply := bson.D{{Key: "ok", Value: 1}}
+	elements, _ := command.Elements()
+	switch name := elements[0].Key(); name {
+	case "hello", "isMaster", "ismaster":
+		reply = bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "isWritablePrimary", Value: true},
+			{Key: "helloOk", Value: true},
+			{Key: "minWireVersion", Value: 0},
+			{Key: "maxWireVersion", Value: 17},
+			{Key: "maxBsonObjectSize", Value: 16777216},
+			{Key: "maxMessageSizeBytes", Value: 48000000},
+			{Key: "maxWriteBatchSize", Value: 100000},
+			{Key: "logicalSessionTimeoutMinutes", Value: 30},
+		}
+	case "find":
+		ns := command.Lookup("$db").StringValue() + "." + elements[0].Value().StringValue()
+		reply = bson.D{{Key: "ok", Value: 1}, {Key: "cursor", Value: bson.D{
+			{Key: "id", Value: int64(0)},
+			{Key: "ns", Value: ns},
+			{Key: "firstBatch", Value: bson.A{}},
+		}}}
+	}
+
+	body, _ := bson.Marshal(reply)
+	index, dst := wiremessage.AppendHeaderStart(nil, wiremessage.NextRequestID(), requestID, wiremessage.OpMsg)
+	dst = wiremessage.AppendMsgFlags(dst, 0)
+	dst = wiremessage.AppendMsgSectionType(dst, wiremessage.SingleDocument)
+	dst = append(dst, body...)
+	return bsoncore.UpdateLength(dst, index, int32(len(dst[index:])))
+}
+
+func TestTracingMiddlewarePropagatesToMongoAndGrok(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var traceparent string
+	grokServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer grokServer.Close()
+	grok := services.NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL})
+
+	clientOpts := options.Client().
+		ApplyURI("mongodb://" + fakeMongoServer(t) + "/?directConnection=true").
+		SetServerAPIOptions(options.ServerAPI(options.ServerAPIVersion1)).
+		SetMonitor(mongodb.NewCommandTracer())
+	client, err := mongo.Connect(context.Background(), clientOpts)
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	conversations := repositories.NewConversationRepository(client.Database("lunaria"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(TracingMiddleware())
+	router.GET("/conversations/:id", func(c *gin.Context) {
+		_, err := conversations.GetConversationByID(c.Request.Context(), primitive.NewObjectID())
+		assert.Error(t, err, "the fake server has no conversations")
+		_, err = grok.SendMiniMessage(c.Request.Context(), []services.LLMMessage{{Role: "user", Content: "hi"}})
+		assert.NoError(t, err)
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/conversations/abc", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	spans := exporter.GetSpans()
+	var server *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	require.NotNil(t, server, "the request has a server span")
+	assert.Equal(t, "GET /conversations/:id", server.Name)
+	assert.Contains(t, server.Attributes, attribute.Int("http.status_code", http.StatusNotFound))
+
+	var children []string
+	for _, span := range spans {
+		if span.Parent.SpanID() == server.SpanContext.SpanID() {
+			children = append(children, span.Name)
+		}
+		assert.Equal(t, server.SpanContext.TraceID(), span.SpanContext.TraceID(), "every span belongs to the request's trace")
+	}
+	assert.Contains(t, children, "find conversations")
+	assert.Contains(t, children, "HTTP POST")
+
+	require.NotEmpty(t, traceparent, "Grok receives the trace context")
+	assert.True(t, strings.Contains(traceparent, server.SpanContext.TraceID().String()))
+}
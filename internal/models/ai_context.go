@@ -42,17 +42,83 @@ type ConversationContext struct {
 	IntimacyLevel     float64                 `json:"intimacy_level" bson:"intimacy_level"`
 
 	// Conversation flow
-	CurrentTopic       string   `json:"current_topic" bson:"current_topic"`
-	TopicHistory       []string `json:"topic_history" bson:"topic_history"`
-	ConversationPacing string   `json:"conversation_pacing" bson:"conversation_pacing"`
+	CurrentTopic       string    `json:"current_topic" bson:"current_topic"`
+	TopicHistory       []string  `json:"topic_history" bson:"topic_history"`
+	TopicChangedAt     time.Time `json:"topic_changed_at" bson:"topic_changed_at"`
+	ConversationPacing string    `json:"conversation_pacing" bson:"conversation_pacing"`
 
 	// Performance tracking
 	TokenUsage       int     `json:"token_usage" bson:"token_usage"`
 	ResponseQuality  float64 `json:"response_quality" bson:"response_quality"`
 	UserSatisfaction float64 `json:"user_satisfaction" bson:"user_satisfaction"`
 
+	// LastResetAt is when the user last reset the context, which they may do once a day
+	LastResetAt *time.Time `json:"last_reset_at,omitempty" bson:"last_reset_at,omitempty"`
+	// LastReintroductionAt is when the companion last welcomed the user back after a long gap
+	LastReintroductionAt *time.Time `json:"last_reintroduction_at,omitempty" bson:"last_reintroduction_at,omitempty"`
+
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+
+	// stored is the context as last read from or written to the database, so that saves write only what changed
+	stored *ConversationContext
+}
+
+// Stored returns the context as last read from or written to the database, or nil for a context not stored yet
+func (c *ConversationContext) Stored() *ConversationContext {
+	return c.stored
+}
+
+// SetStored records the context as it is in the database
+func (c *ConversationContext) SetStored(stored *ConversationContext) {
+	c.stored = stored
+}
+
+// Relationship state of a new conversation context, restored when the context is reset
+const (
+	InitialRelationshipStage = "getting_to_know"
+	InitialTrustLevel        = 0.5
+	InitialIntimacyLevel     = 0.3
+)
+
+// InitialTopic is the topic of a conversation before any topic has been detected
+const InitialTopic = "general"
+
+// Conversation context event types
+const (
+	ContextEventCreated       = "context_created"
+	ContextEventUpdated       = "context_updated"
+	ContextEventTopicChanged  = "topic_changed"
+	ContextEventStageAdvanced = "stage_advanced"
+	ContextEventReset         = "context_reset"
+)
+
+// Conversation context event actors
+const (
+	ContextActorUser   = "user"
+	ContextActorSystem = "system"
+)
+
+// ContextEvent is an immutable record of a single conversation context change
+type ContextEvent struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	EventType      string             `json:"event_type" bson:"event_type"`
+	Before         map[string]any     `json:"before" bson:"before"`
+	After          map[string]any     `json:"after" bson:"after"`
+	Actor          string             `json:"actor" bson:"actor"`
+	Timestamp      time.Time          `json:"timestamp" bson:"timestamp"`
+}
+
+// ConversationHistorySummary condenses older messages of a conversation so they can be
+// dropped from prompts. Unlike ConversationSummary it is stored in MongoDB.
+type ConversationHistorySummary struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ConversationID   primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	Summary          string             `json:"summary" bson:"summary"`
+	ThroughMessageID primitive.ObjectID `json:"through_message_id" bson:"through_message_id"`
+	MessageCount     int                `json:"message_count" bson:"message_count"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
 }
 
 // EmotionalState represents the current emotional state
@@ -88,10 +154,17 @@ type AIEnhancedMemoryEntry struct {
 	LastReferenced  time.Time            `json:"last_referenced" bson:"last_referenced"`
 	RelatedMemories []primitive.ObjectID `json:"related_memories" bson:"related_memories"`
 	Metadata        map[string]any       `json:"metadata" bson:"metadata"`
+	Embedding       []float32            `json:"embedding,omitempty" bson:"embedding,omitempty"`
 	CreatedAt       time.Time            `json:"created_at" bson:"created_at"`
 	UpdatedAt       time.Time            `json:"updated_at" bson:"updated_at"`
+	DeletedAt       *time.Time           `json:"-" bson:"deleted_at,omitempty"` // set once the memory decays below MinMemoryImportance
+	// ConflictResolution records why the memory was demoted after contradicting another, empty if it never was
+	ConflictResolution string `json:"conflict_resolution,omitempty" bson:"conflict_resolution,omitempty"`
 }
 
+// MinMemoryImportance is the importance below which a decayed memory is soft-deleted
+const MinMemoryImportance = 0.1
+
 // PromptTemplate represents a reusable prompt template
 type PromptTemplate struct {
 	ID               primitive.ObjectID `json:"id" bson:"_id"`
@@ -135,6 +208,53 @@ type ResponseQuality struct {
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
 
+// CompanionReputation aggregates response quality for a companion
+type CompanionReputation struct {
+	CompanionID    string    `json:"companion_id" bson:"companion_id"`
+	AverageQuality float64   `json:"average_quality" bson:"average_quality"`
+	SampleSize     int       `json:"sample_size" bson:"sample_size"`
+	QualityTrend   string    `json:"quality_trend" bson:"quality_trend"` // improving, stable, degrading
+	RecentScores   []float64 `json:"-" bson:"recent_scores"`
+	LastUpdated    time.Time `json:"last_updated" bson:"last_updated"`
+}
+
+// DriftReport describes the trend in a companion's personality consistency over a window of days
+type DriftReport struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	CompanionID        string             `json:"companion_id" bson:"companion_id"`
+	Days               int                `json:"days" bson:"days"`
+	SampleSize         int                `json:"sample_size" bson:"sample_size"`
+	TrendSlope         float64            `json:"trend_slope" bson:"trend_slope"` // change in consistency per day
+	AverageConsistency float64            `json:"average_consistency" bson:"average_consistency"`
+	DriftDetected      bool               `json:"drift_detected" bson:"drift_detected"`
+	RecommendedAction  string             `json:"recommended_action" bson:"recommended_action"`
+	CreatedAt          time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// SafetyIncident records a companion response withheld for failing a high-risk safety check
+type SafetyIncident struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	UserID         string             `json:"user_id" bson:"user_id"`
+	CompanionID    string             `json:"companion_id" bson:"companion_id"`
+	ResponseText   string             `json:"response_text" bson:"response_text"`
+	Concerns       []string           `json:"concerns" bson:"concerns"`
+	RiskLevel      string             `json:"risk_level" bson:"risk_level"`
+	Status         string             `json:"status" bson:"status"` // pending_review, reviewed
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ModerationEvent records a user message blocked by the content moderation pipeline
+type ModerationEvent struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	UserID         string             `json:"user_id" bson:"user_id"`
+	Filter         string             `json:"filter" bson:"filter"`
+	Reason         string             `json:"reason" bson:"reason"`
+	Text           string             `json:"text" bson:"text"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
 // ConversationIntelligence represents conversation flow analysis
 type ConversationIntelligence struct {
 	ID             primitive.ObjectID `json:"id" bson:"_id"`
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/qualitytier"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -23,6 +24,7 @@ type ConversationSummary struct {
 type MessageAnalytics struct {
 	ID             uuid.UUID `db:"id" json:"id"`
 	ConversationID string    `db:"conversation_id" json:"conversation_id"`
+	CompanionID    string    `db:"companion_id" json:"companion_id"`
 	SenderID       uuid.UUID `db:"sender_id" json:"sender_id"`
 	Type           string    `db:"type" json:"type"`
 	Sentiment      *string   `db:"sentiment" json:"sentiment,omitempty"`
@@ -63,11 +65,16 @@ type UserEngagementAnalytics struct {
 	TopicDiversity     float64 `bson:"topic_diversity" json:"topic_diversity"`
 	VulnerabilityLevel float64 `bson:"vulnerability_level" json:"vulnerability_level"`
 
+	// XP quality tier derived from EngagementScore
+	QualityTier  qualitytier.Type `bson:"quality_tier" json:"quality_tier"`
+	XPMultiplier float64          `bson:"xp_multiplier" json:"xp_multiplier"`
+
 	// Behavioral patterns
-	PeakActivityTime time.Time `bson:"peak_activity_time" json:"peak_activity_time"`
-	SessionFrequency int       `bson:"session_frequency" json:"session_frequency"`
-	PreferredTopics  []string  `bson:"preferred_topics" json:"preferred_topics"`
-	InteractionStyle string    `bson:"interaction_style" json:"interaction_style"`
+	PeakActivityTime time.Time          `bson:"peak_activity_time" json:"peak_activity_time"`
+	SessionFrequency int                `bson:"session_frequency" json:"session_frequency"`
+	PreferredTopics  []string           `bson:"preferred_topics" json:"preferred_topics"`
+	TopicScores      map[string]float64 `bson:"topic_scores" json:"topic_scores"`
+	InteractionStyle string             `bson:"interaction_style" json:"interaction_style"`
 
 	// Relationship progression
 	IntimacyGrowth    float64            `bson:"intimacy_growth" json:"intimacy_growth"`
@@ -77,6 +84,7 @@ type UserEngagementAnalytics struct {
 
 	// Emotional intelligence metrics
 	SentimentTrend      []SentimentPoint `bson:"sentiment_trend" json:"sentiment_trend"`
+	ConversationArc     ConversationArc  `bson:"conversation_arc,omitempty" json:"conversation_arc,omitempty"`
 	EmotionalRegulation float64          `bson:"emotional_regulation" json:"emotional_regulation"`
 	EmpathyResponse     float64          `bson:"empathy_response" json:"empathy_response"`
 	MoodImpact          float64          `bson:"mood_impact" json:"mood_impact"`
@@ -85,6 +93,26 @@ type UserEngagementAnalytics struct {
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// ConversationArc names the overall emotional trajectory of a conversation
+type ConversationArc string
+
+// Conversation arcs
+const (
+	ConversationArcUplifting   ConversationArc = "uplifting"
+	ConversationArcGrounding   ConversationArc = "grounding"
+	ConversationArcProcessing  ConversationArc = "processing"
+	ConversationArcCelebratory ConversationArc = "celebratory"
+	ConversationArcSupportive  ConversationArc = "supportive"
+	ConversationArcMixed       ConversationArc = "mixed"
+)
+
+// ConversationArcEntry is a conversation's arc as shown on the dashboard
+type ConversationArcEntry struct {
+	ConversationID primitive.ObjectID `bson:"conversation_id" json:"conversation_id"`
+	Arc            ConversationArc    `bson:"conversation_arc" json:"arc"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
 // SentimentPoint represents a sentiment measurement at a specific time
 type SentimentPoint struct {
 	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
@@ -125,10 +153,68 @@ type RelationshipAnalytics struct {
 	RedFlags    []string `bson:"red_flags" json:"red_flags"`
 	Strengths   []string `bson:"strengths" json:"strengths"`
 
+	// ChemistryScore is the weighted harmonic mean of trust, intimacy, engagement and conversation quality, see the
+	// Chemistry*Weight constants
+	ChemistryScore float64 `bson:"chemistry_score" json:"chemistry_score"`
+
+	// Emotional dynamics: probability of moving from one primary emotion to another
+	EmotionTransitionMatrix map[string]map[string]float64 `bson:"emotion_transition_matrix,omitempty" json:"emotion_transition_matrix,omitempty"`
+
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
 }
 
+// Weights of the components of RelationshipAnalytics.ChemistryScore, summing to 1. Engagement and conversation
+// quality come from the latest engagement analytics; conversation quality is the mean of its depth, emotional
+// intensity, topic diversity and vulnerability.
+const (
+	ChemistryTrustWeight      = 0.3
+	ChemistryIntimacyWeight   = 0.25
+	ChemistryEngagementWeight = 0.25
+	ChemistryQualityWeight    = 0.2
+)
+
+// RelationshipHealthSnapshot records a relationship's health at the end of a week for trend charts
+type RelationshipHealthSnapshot struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	CompanionID   string             `bson:"companion_id" json:"companion_id"`
+	HealthScore   float64            `bson:"health_score" json:"health_score"`
+	TrustLevel    float64            `bson:"trust_level" json:"trust_level"`
+	IntimacyLevel float64            `bson:"intimacy_level" json:"intimacy_level"`
+	Stage         string             `bson:"stage" json:"stage"`
+	WeekStart     time.Time          `bson:"week_start" json:"week_start"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// HealthAlertState remembers a relationship's health score at the last alert check, so alerts fire only when the
+// score crosses below the user's threshold
+type HealthAlertState struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           string             `bson:"user_id" json:"user_id"`
+	CompanionID      string             `bson:"companion_id" json:"companion_id"`
+	LastScore        float64            `bson:"last_score" json:"last_score"`
+	LastAlertedScore *float64           `bson:"last_alerted_score,omitempty" json:"last_alerted_score,omitempty"` // cleared once the score recovers
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Consent audit actions
+const (
+	ConsentAuditRead  = "read"
+	ConsentAuditWrite = "write"
+)
+
+// ConsentAuditEvent records a read or change of a user's privacy settings. Writes carry the settings before and after the change.
+type ConsentAuditEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Action    string             `bson:"action" json:"action"`
+	Requester string             `bson:"requester,omitempty" json:"requester,omitempty"`
+	Before    map[string]any     `bson:"before,omitempty" json:"before,omitempty"`
+	After     map[string]any     `bson:"after,omitempty" json:"after,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
 // StageTransition represents a relationship stage change
 type StageTransition struct {
 	FromStage  string    `bson:"from_stage" json:"from_stage"`
@@ -147,13 +233,37 @@ type IntimacyMilestone struct {
 	Context     string    `bson:"context" json:"context"`
 }
 
-// TrustEvent represents a trust-building moment
+// TrustDecayEvent is the TrustEvent type recorded when trust is lost; its Impact is negative
+const TrustDecayEvent = "trust_decay"
+
+// TrustDecayEventReason explains why a TrustDecayEvent was recorded
+type TrustDecayEventReason string
+
+// TrustDecayReasonFactualContradiction is recorded when the companion contradicts established facts
+const TrustDecayReasonFactualContradiction TrustDecayEventReason = "factual_contradiction"
+
+// TrustDecayHealthWindow is how long a trust decay event keeps lowering the relationship's health score
+const TrustDecayHealthWindow = 7 * 24 * time.Hour
+
+// TrustEvent represents a trust-building moment, or a loss of trust when Type is TrustDecayEvent
 type TrustEvent struct {
-	Type        string    `bson:"type" json:"type"`
-	Description string    `bson:"description" json:"description"`
-	Impact      float64   `bson:"impact" json:"impact"`
-	Timestamp   time.Time `bson:"timestamp" json:"timestamp"`
-	Context     string    `bson:"context" json:"context"`
+	Type        string                `bson:"type" json:"type"`
+	Description string                `bson:"description" json:"description"`
+	Impact      float64               `bson:"impact" json:"impact"`
+	Timestamp   time.Time             `bson:"timestamp" json:"timestamp"`
+	Context     string                `bson:"context" json:"context"`
+	DecayReason TrustDecayEventReason `bson:"decay_reason,omitempty" json:"decay_reason,omitempty"`
+}
+
+// RecentTrustDecay returns the total trust lost to decay events since the given time
+func (a *RelationshipAnalytics) RecentTrustDecay(since time.Time) float64 {
+	var decay float64
+	for _, event := range a.TrustBuildingEvents {
+		if event.Type == TrustDecayEvent && !event.Timestamp.Before(since) {
+			decay -= event.Impact
+		}
+	}
+	return decay
 }
 
 // VulnerabilityEvent represents a moment of vulnerability
@@ -338,12 +448,16 @@ type UserDashboardData struct {
 	RecentAchievements []UserAchievement `json:"recent_achievements"`
 
 	// Relationship insights
-	RelationshipAnalytics *RelationshipAnalytics `json:"relationship_analytics"`
-	EngagementTrends      []EngagementTrendPoint `json:"engagement_trends"`
+	RelationshipAnalytics *RelationshipAnalytics       `json:"relationship_analytics"`
+	EngagementTrends      []EngagementTrendPoint       `json:"engagement_trends"`
+	EngagementAnomalies   []EngagementAnomaly          `json:"engagement_anomalies"`
+	HealthScoreHistory    []RelationshipHealthSnapshot `json:"health_score_history"`
+	ConversationArcs      []ConversationArcEntry       `json:"conversation_arcs"`
 
 	// Recommendations
-	Recommendations []Recommendation `json:"recommendations"`
-	NextMilestones  []StageMilestone `json:"next_milestones"`
+	Recommendations        []Recommendation `json:"recommendations"`
+	RecommendationMetadata map[string]any   `json:"recommendation_metadata"`
+	NextMilestones         []StageMilestone `json:"next_milestones"`
 
 	// Statistics
 	Statistics *UserStatistics    `json:"statistics"`
@@ -352,6 +466,27 @@ type UserDashboardData struct {
 	LastUpdated time.Time `json:"last_updated"`
 }
 
+// MultiCompanionSummary compares a user's engagement and relationship health across companions
+type MultiCompanionSummary struct {
+	UserID             string                  `json:"user_id"`
+	PrimaryCompanionID string                  `json:"primary_companion_id"`
+	Companions         []CompanionSummaryEntry `json:"companions"`
+}
+
+// CompanionSummaryEntry summarises a single companion relative to the user's other companions
+type CompanionSummaryEntry struct {
+	CompanionID       string  `json:"companion_id"`
+	EngagementScore   float64 `json:"engagement_score"`
+	HealthScore       float64 `json:"health_score"`
+	IntimacyLevel     float64 `json:"intimacy_level"`
+	RelationshipStage string  `json:"relationship_stage"`
+	CurrentLevel      int     `json:"current_level"`
+	TotalMessages     int     `json:"total_messages"`
+	CurrentStreak     int     `json:"current_streak"`
+	EngagementRank    int     `json:"engagement_rank"`
+	HealthRank        int     `json:"health_rank"`
+}
+
 // EngagementTrendPoint represents engagement over time
 type EngagementTrendPoint struct {
 	Date            time.Time     `json:"date"`
@@ -361,6 +496,14 @@ type EngagementTrendPoint struct {
 	Duration        time.Duration `json:"duration"`
 }
 
+// EngagementAnomaly flags a trend point that deviates sharply from recent engagement
+type EngagementAnomaly struct {
+	Date      time.Time `json:"date"`
+	Score     float64   `json:"score"`
+	ZScore    float64   `json:"z_score"`
+	Direction string    `json:"direction"`
+}
+
 // Recommendation provides personalized recommendations
 type Recommendation struct {
 	Type        string         `json:"type"`
@@ -382,6 +525,58 @@ type UserStatistics struct {
 	MostActiveDay             string        `json:"most_active_day"`
 	EngagementScore           float64       `json:"engagement_score"`
 	RelationshipHealth        float64       `json:"relationship_health"`
+	// Percentile ranks among consenting users, zero while there are too few users to compare against
+	ConversationQualityPercentile float64 `json:"conversation_quality_percentile"`
+	EngagementPercentile          float64 `json:"engagement_percentile"`
+}
+
+// StatsSummaryCard is a short summary of a user's history with one companion, shown to the user
+type StatsSummaryCard struct {
+	TotalDays           int    `json:"total_days"`               // days since the first conversation
+	LongestConversation int    `json:"longest_conversation"`     // messages in the longest conversation
+	FavouriteTime       *int   `json:"favourite_time,omitempty"` // hour of day (UTC) the user sends most messages
+	TopEmotion          string `json:"top_emotion,omitempty"`    // most frequent primary emotion
+	RelationshipAgeText string `json:"relationship_age_text"`    // e.g. "3 months"
+	MilestonesReached   int    `json:"milestones_reached"`       // completed relationship stage milestones
+	XPThisWeek          int    `json:"xp_this_week"`             // experience gained over the last 7 days
+}
+
+// CompanionHistoryStats are the message statistics a StatsSummaryCard is built from
+type CompanionHistoryStats struct {
+	FirstConversationAt *time.Time
+	LongestConversation int
+	FavouriteHour       *int
+	TopEmotion          string
+}
+
+const (
+	ExperienceSourceSession     = "session"
+	ExperienceSourceAchievement = "achievement"
+)
+
+// ExperienceGain records experience points awarded to a user, so gains over a period can be totalled
+type ExperienceGain struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	CompanionID string             `bson:"companion_id" json:"companion_id"`
+	Amount      int                `bson:"amount" json:"amount"`
+	Source      string             `bson:"source" json:"source"` // session or achievement
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// DailyUserStatistics is a per-day rollup of user statistics stored in PostgreSQL
+type DailyUserStatistics struct {
+	UserID                    string        `db:"user_id" json:"user_id"`
+	CompanionID               string        `db:"companion_id" json:"companion_id"`
+	StatDate                  time.Time     `db:"stat_date" json:"stat_date"`
+	TotalSessions             int           `db:"total_sessions" json:"total_sessions"`
+	TotalMessages             int           `db:"total_messages" json:"total_messages"`
+	AverageSessionLength      time.Duration `db:"avg_session_length" json:"average_session_length"`
+	AverageMessagesPerSession float64       `db:"avg_messages_per_session" json:"average_messages_per_session"`
+	EngagementScore           float64       `db:"engagement_score" json:"engagement_score"`
+	SessionsByHour            [24]int       `db:"sessions_by_hour" json:"sessions_by_hour"` // sessions started in each UTC hour of the day
+	CreatedAt                 time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt                 time.Time     `db:"updated_at" json:"updated_at"`
 }
 
 // StreakInformation provides streak details
@@ -62,3 +62,22 @@ type MemoryEntry struct {
 	Timestamp  time.Time `bson:"timestamp" json:"timestamp"`
 	Tags       []string  `bson:"tags" json:"tags"`
 }
+
+const (
+	LexicalLevelCasual   = "casual"
+	LexicalLevelElevated = "elevated"
+)
+
+// ConversationStyleGuide records the vocabulary and phrasing a companion has settled into, so its voice stays
+// consistent across conversations. ProposedExpressions are learned from the companion's responses and only
+// shape prompts once accepted into SignatureExpressions.
+type ConversationStyleGuide struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CompanionID          string             `bson:"companion_id" json:"companion_id"`
+	SignatureExpressions []string           `bson:"signature_expressions" json:"signature_expressions"`
+	AvoidedPhrases       []string           `bson:"avoided_phrases" json:"avoided_phrases"`
+	FavouriteTopics      []string           `bson:"favourite_topics" json:"favourite_topics"`
+	LexicalLevel         string             `bson:"lexical_level" json:"lexical_level"` // casual or elevated
+	ProposedExpressions  []string           `bson:"proposed_expressions,omitempty" json:"proposed_expressions,omitempty"`
+	UpdatedAt            time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -16,28 +16,85 @@ type Conversation struct {
 	CompanionID    string             `bson:"companion_id" json:"companion_id"`
 	RecentMessages []Message          `bson:"recent_messages" json:"recent_messages"`
 	Archived       bool               `bson:"archived" json:"archived"`
+	ArchivedAt     *time.Time         `bson:"archived_at,omitempty" json:"archived_at,omitempty"` // set once the messages have been moved to S3
+	ArchiveURL     string             `bson:"archive_url,omitempty" json:"archive_url,omitempty"`
+	DeletedAt      *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`   // soft delete; purged once the grace period ends
+	ForkedFrom     *ConversationFork  `bson:"forked_from,omitempty" json:"forked_from,omitempty"` // set on conversations branched from another
 	Relationship   string             `bson:"relationship" json:"relationship"`
 	LastActivity   time.Time          `bson:"last_activity" json:"last_activity"`
+	TopReactions   []ReactionSummary  `bson:"-" json:"top_reactions,omitempty"` // the companion's most used reactions this week
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
+// ConversationFork records the conversation and message a forked conversation was branched from
+type ConversationFork struct {
+	ConversationID primitive.ObjectID `bson:"conversation_id" json:"conversation_id"`
+	MessageID      primitive.ObjectID `bson:"message_id" json:"message_id"`
+	ForkedAt       time.Time          `bson:"forked_at" json:"forked_at"`
+}
+
 type Message struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ConversationID       primitive.ObjectID `bson:"conversation_id" json:"conversation_id"`
+	SenderID             string             `bson:"sender_id" json:"sender_id"`
+	SenderType           sendertype.Type    `bson:"sender_type" json:"sender_type"` // user, companion, system
+	Type                 messagetype.Type   `bson:"type" json:"type"`               // text, photo, voice, sticker, system
+	Text                 *string            `bson:"text,omitempty" json:"text,omitempty"`
+	TextEncrypted        []byte             `bson:"text_encrypted,omitempty" json:"-"` // AES-256-GCM ciphertext of Text when message encryption is enabled
+	TextNonce            []byte             `bson:"text_nonce,omitempty" json:"-"`
+	Media                *MediaMetadata     `bson:"media,omitempty" json:"media,omitempty"`
+	Sticker              *StickerInfo       `bson:"sticker,omitempty" json:"sticker,omitempty"`
+	SystemEvent          *SystemEvent       `bson:"system_event,omitempty" json:"system_event,omitempty"`
+	Read                 bool               `bson:"read" json:"read"`
+	IsTyping             bool               `bson:"is_typing" json:"is_typing"`                                               // Indicates if this message is part of a typing sequence
+	MessageIndex         int                `bson:"message_index" json:"message_index"`                                       // Index of this message in a sequence (0-based)
+	TotalMessages        int                `bson:"total_messages" json:"total_messages"`                                     // Total number of messages in the sequence
+	ClientIdempotencyKey string             `bson:"client_idempotency_key,omitempty" json:"client_idempotency_key,omitempty"` // Client-generated UUID that deduplicates retried sends
+	Reactions            map[string]int     `bson:"reactions,omitempty" json:"reactions,omitempty"`                           // emoji → number of users who reacted with it
+	CreatedAt            time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt            time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// MessageReaction is one user's emoji reaction to a message. A user has at most one reaction per message.
+type MessageReaction struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MessageID      primitive.ObjectID `bson:"message_id" json:"message_id"`
 	ConversationID primitive.ObjectID `bson:"conversation_id" json:"conversation_id"`
-	SenderID       string             `bson:"sender_id" json:"sender_id"`
-	SenderType     sendertype.Type    `bson:"sender_type" json:"sender_type"` // user, companion, system
-	Type           messagetype.Type   `bson:"type" json:"type"`               // text, photo, voice, sticker, system
-	Text           *string            `bson:"text,omitempty" json:"text,omitempty"`
-	Media          *MediaMetadata     `bson:"media,omitempty" json:"media,omitempty"`
-	Sticker        *StickerInfo       `bson:"sticker,omitempty" json:"sticker,omitempty"`
-	SystemEvent    *SystemEvent       `bson:"system_event,omitempty" json:"system_event,omitempty"`
-	Read           bool               `bson:"read" json:"read"`
-	IsTyping       bool               `bson:"is_typing" json:"is_typing"`           // Indicates if this message is part of a typing sequence
-	MessageIndex   int                `bson:"message_index" json:"message_index"`   // Index of this message in a sequence (0-based)
-	TotalMessages  int                `bson:"total_messages" json:"total_messages"` // Total number of messages in the sequence
+	CompanionID    string             `bson:"companion_id" json:"companion_id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	Emoji          string             `bson:"emoji" json:"emoji"`
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ReactionSummary is how often an emoji was used
+type ReactionSummary struct {
+	Emoji string `bson:"emoji" json:"emoji"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// SessionReplayEntry is a single line of a session replay export
+type SessionReplayEntry struct {
+	Message    *Message        `json:"message"`
+	SenderType sendertype.Type `json:"sender_type"`
+	Sentiment  *SentimentPoint `json:"sentiment,omitempty"`
+}
+
+// ReplayManifest schedules a conversation's messages for an accelerated replay
+type ReplayManifest struct {
+	ConversationID primitive.ObjectID `json:"conversation_id"`
+	Speed          float64            `json:"speed"`
+	TotalMs        int64              `json:"total_ms"`
+	Messages       []ReplayMessage    `json:"messages"`
+}
+
+// ReplayMessage is a message with its place in a replay. Times are scaled by the replay speed, so delta_ms and
+// cumulative_ms are replay time and display_at is the first message's creation time plus cumulative_ms.
+type ReplayMessage struct {
+	Message      *Message  `json:"message"`
+	DisplayAt    time.Time `json:"display_at"`
+	DeltaMs      int64     `json:"delta_ms"`
+	CumulativeMs int64     `json:"cumulative_ms"`
 }
 
 type MediaMetadata struct {
@@ -64,6 +121,9 @@ type StickerInfo struct {
 	URL  string `bson:"url" json:"url"`
 }
 
+// SystemEventContextReset is the system event recorded when a user resets a conversation's context
+const SystemEventContextReset = "context_reset"
+
 type SystemEvent struct {
 	EventType string `bson:"event_type" json:"event_type"`
 	Details   string `bson:"details" json:"details"`
@@ -76,3 +136,26 @@ type ConversationStats struct {
 	CompanionMessages int       `json:"companion_messages"`
 	LastMessageAt     time.Time `json:"last_message_at"`
 }
+
+// Pending proactive message statuses
+const (
+	ProactiveStatusPending    = "pending"
+	ProactiveStatusDelivering = "delivering"
+	ProactiveStatusDelivered  = "delivered"
+)
+
+// PendingProactiveMessage is a companion-initiated message waiting to be delivered to an inactive user.
+// InactiveSince identifies the inactivity window so each window produces at most one message. A message queued during
+// the user's quiet hours is not delivered before DeliverAfter.
+type PendingProactiveMessage struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	CompanionID    string             `bson:"companion_id" json:"companion_id"`
+	ConversationID primitive.ObjectID `bson:"conversation_id" json:"conversation_id"`
+	InactiveSince  time.Time          `bson:"inactive_since" json:"inactive_since"`
+	Text           string             `bson:"text" json:"text"`
+	Status         string             `bson:"status" json:"status"`
+	DeliverAfter   *time.Time         `bson:"deliver_after,omitempty" json:"deliver_after,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
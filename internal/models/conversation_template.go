@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConversationTemplate is a scenario a new conversation can start from, such as a first date or a study session, so
+// that users do not face an empty conversation
+type ConversationTemplate struct {
+	ID              primitive.ObjectID         `bson:"_id,omitempty" json:"id"`
+	TemplateID      string                     `bson:"template_id" json:"template_id"`
+	Name            string                     `bson:"name" json:"name"`
+	Description     string                     `bson:"description" json:"description"`
+	InitialMessages []TemplateMessage          `bson:"initial_messages" json:"initial_messages"`
+	InitialContext  PartialConversationContext `bson:"initial_context" json:"initial_context"`
+	Tags            []string                   `bson:"tags" json:"tags"`
+	CreatedAt       time.Time                  `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time                  `bson:"updated_at" json:"updated_at"`
+}
+
+// TemplateMessage is a message a template opens the conversation with, sent by the user or the companion
+type TemplateMessage struct {
+	SenderType sendertype.Type `bson:"sender_type" json:"sender_type"`
+	Text       string          `bson:"text" json:"text"`
+}
+
+// PartialConversationContext holds the conversation context fields a template sets. Empty fields keep the values a
+// new conversation starts with.
+type PartialConversationContext struct {
+	CurrentTopic       string        `bson:"current_topic,omitempty" json:"current_topic,omitempty"`
+	TopicHistory       []string      `bson:"topic_history,omitempty" json:"topic_history,omitempty"`
+	ConversationPacing string        `bson:"conversation_pacing,omitempty" json:"conversation_pacing,omitempty"`
+	ConversationLayer  *ContextLayer `bson:"conversation_layer,omitempty" json:"conversation_layer,omitempty"`
+	SituationalLayer   *ContextLayer `bson:"situational_layer,omitempty" json:"situational_layer,omitempty"`
+}
+
+// IsEmpty reports whether the partial context sets no fields
+func (p PartialConversationContext) IsEmpty() bool {
+	return p.CurrentTopic == "" && len(p.TopicHistory) == 0 && p.ConversationPacing == "" &&
+		p.ConversationLayer == nil && p.SituationalLayer == nil
+}
+
+// MergeInto overwrites the fields of context that the partial context sets
+func (p PartialConversationContext) MergeInto(context *ConversationContext) {
+	if p.CurrentTopic != "" {
+		context.CurrentTopic = p.CurrentTopic
+	}
+	if len(p.TopicHistory) > 0 {
+		context.TopicHistory = append([]string{}, p.TopicHistory...)
+	}
+	if p.ConversationPacing != "" {
+		context.ConversationPacing = p.ConversationPacing
+	}
+	if p.ConversationLayer != nil {
+		layer := *p.ConversationLayer
+		context.ConversationLayer = &layer
+	}
+	if p.SituationalLayer != nil {
+		layer := *p.SituationalLayer
+		context.SituationalLayer = &layer
+	}
+}
@@ -71,6 +71,14 @@ type ResponseQualityResponse struct {
 	AnalysisConfidence          float64  `json:"analysis_confidence"`
 }
 
+// RevalidateMessageResponse compares a message's overall quality before and after revalidation. The old score is nil
+// if the message had never been validated.
+type RevalidateMessageResponse struct {
+	MessageID         string   `json:"message_id"`
+	OldOverallQuality *float64 `json:"old_overall_quality"`
+	NewOverallQuality float64  `json:"new_overall_quality"`
+}
+
 // EmotionalAnalysisRequest represents a request for emotional analysis
 type EmotionalAnalysisRequest struct {
 	MessageText    string `json:"message_text" validate:"required"`
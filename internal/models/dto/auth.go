@@ -34,3 +34,8 @@ type UpdateProfileRequest struct {
 	Gender    *string `json:"gender,omitempty" validate:"omitempty,oneof=male female other"`
 	AvatarURL *string `json:"avatar_url,omitempty" validate:"omitempty,url"`
 }
+
+// SetTestUserRequest flags or unflags a user as a test account
+type SetTestUserRequest struct {
+	IsTestUser *bool `json:"is_test_user" binding:"required"`
+}
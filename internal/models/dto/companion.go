@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 )
 
@@ -13,6 +15,7 @@ type CreateCompanionRequest struct {
 	CustomPersonality *models.PersonalityTraits `json:"custom_personality,omitempty"`
 	Interests         []string                  `json:"interests,omitempty"`
 	Backstory         *string                   `json:"backstory,omitempty"`
+	Birthdate         *time.Time                `json:"birthdate,omitempty"`
 }
 
 type UpdateCompanionRequest struct {
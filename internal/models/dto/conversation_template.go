@@ -0,0 +1,15 @@
+package dto
+
+import (
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// CreateConversationTemplateRequest defines a scenario new conversations can start from
+type CreateConversationTemplateRequest struct {
+	TemplateID      string                            `json:"template_id" binding:"required"`
+	Name            string                            `json:"name" binding:"required"`
+	Description     string                            `json:"description"`
+	InitialMessages []models.TemplateMessage          `json:"initial_messages"`
+	InitialContext  models.PartialConversationContext `json:"initial_context"`
+	Tags            []string                          `json:"tags"`
+}
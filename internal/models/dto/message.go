@@ -5,23 +5,43 @@ import (
 )
 
 type CreateMessageRequest struct {
-	Type        string              `json:"type" binding:"required,oneof=text photo voice sticker system"`
-	Text        *string             `json:"text,omitempty"`
-	MediaID     *string             `json:"media_id,omitempty"`
-	Sticker     *models.StickerInfo `json:"sticker,omitempty"`
-	SystemEvent *models.SystemEvent `json:"system_event,omitempty"`
+	Type                 string              `json:"type" binding:"required,oneof=text photo voice sticker system"`
+	Text                 *string             `json:"text,omitempty"`
+	MediaID              *string             `json:"media_id,omitempty"`
+	Sticker              *models.StickerInfo `json:"sticker,omitempty"`
+	SystemEvent          *models.SystemEvent `json:"system_event,omitempty"`
+	ClientIdempotencyKey string              `json:"client_idempotency_key,omitempty" binding:"omitempty,uuid"`
 }
 
 type CreateMessageResponse struct {
 	Message *models.Message `json:"message"`
 }
 
+// SendMessageResponse is the stored message together with any system events raised by sending it
+type SendMessageResponse struct {
+	*models.Message
+	SystemEvents []*models.Message `json:"system_events,omitempty"`
+}
+
 type GetMessagesResponse struct {
 	Messages   []*models.Message `json:"messages"`
 	NextCursor *string           `json:"next_cursor,omitempty"`
 	HasMore    bool              `json:"has_more"`
 }
 
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// ResetContextRequest chooses whether a context reset keeps the companion's memories
+type ResetContextRequest struct {
+	PreserveMemories bool `json:"preserve_memories"`
+}
+
+type ForkConversationRequest struct {
+	MessageID string `json:"message_id" binding:"required"`
+}
+
 type PresignedURLRequest struct {
 	Type   string `json:"type" binding:"required,oneof=photo voice"`
 	Format string `json:"format" binding:"required"`
@@ -0,0 +1,8 @@
+package dto
+
+// SessionBudgetRequest sets a user's daily conversation time limits. A zero limit is not enforced.
+type SessionBudgetRequest struct {
+	DailySoftLimitMinutes int    `json:"daily_soft_limit_minutes" binding:"min=0"`
+	DailyHardLimitMinutes int    `json:"daily_hard_limit_minutes" binding:"min=0"`
+	Timezone              string `json:"timezone"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Experiment defines an A/B test over prompt fragments
+type Experiment struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Name      string              `json:"name" bson:"name"`
+	Variants  []ExperimentVariant `json:"variants" bson:"variants"`
+	Active    bool                `json:"active" bson:"active"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// ExperimentVariant is a named prompt fragment within an experiment
+type ExperimentVariant struct {
+	Name           string `json:"name" bson:"name"`
+	PromptFragment string `json:"prompt_fragment" bson:"prompt_fragment"`
+}
+
+// ABTestResult records the quality of a response produced under an experiment variant
+type ABTestResult struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ExperimentName string             `json:"experiment_name" bson:"experiment_name"`
+	Variant        string             `json:"variant" bson:"variant"`
+	UserID         string             `json:"user_id" bson:"user_id"`
+	ConversationID primitive.ObjectID `json:"conversation_id" bson:"conversation_id"`
+	MessageID      primitive.ObjectID `json:"message_id" bson:"message_id"`
+	QualityScore   float64            `json:"quality_score" bson:"quality_score"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeatureFlag is a runtime toggle. When enabled globally it is on for rollout_percentage percent of users.
+type FeatureFlag struct {
+	FlagName          string    `db:"flag_name" json:"flag_name"`
+	EnabledGlobally   bool      `db:"enabled_globally" json:"enabled_globally"`
+	RolloutPercentage int       `db:"rollout_percentage" json:"rollout_percentage"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// UserFlagOverride turns a feature flag on or off for one user regardless of its rollout
+type UserFlagOverride struct {
+	UserID   uuid.UUID `db:"user_id" json:"user_id"`
+	FlagName string    `db:"flag_name" json:"flag_name"`
+	Enabled  bool      `db:"enabled" json:"enabled"`
+}
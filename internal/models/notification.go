@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification channels
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelPush  = "push"
+	NotificationChannelInApp = "in_app"
+)
+
+// Notification types
+const (
+	NotificationAchievementUnlocked = "achievement_unlocked"
+	NotificationStreakReminder      = "streak_reminder"
+	NotificationChurnIntervention   = "churn_intervention"
+	NotificationHealthAlert         = "relationship_health_alert"
+)
+
+type NotificationPreferences struct {
+	UserID       uuid.UUID `db:"user_id" json:"user_id"`
+	EmailEnabled bool      `db:"email_enabled" json:"email_enabled"`
+	PushEnabled  bool      `db:"push_enabled" json:"push_enabled"`
+	InAppEnabled bool      `db:"in_app_enabled" json:"in_app_enabled"`
+	// AlertThreshold is the relationship health score below which the user is alerted
+	AlertThreshold float64   `db:"alert_threshold" json:"alert_threshold"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// DefaultHealthAlertThreshold is the alert threshold of users who have not chosen one
+const DefaultHealthAlertThreshold = 0.5
+
+// DefaultNotificationPreferences are used for users who have not chosen their channels
+func DefaultNotificationPreferences(userID uuid.UUID) *NotificationPreferences {
+	return &NotificationPreferences{
+		UserID:         userID,
+		InAppEnabled:   true,
+		AlertThreshold: DefaultHealthAlertThreshold,
+	}
+}
+
+// Channels returns the channels the user has enabled
+func (p *NotificationPreferences) Channels() []string {
+	var channels []string
+	if p.InAppEnabled {
+		channels = append(channels, NotificationChannelInApp)
+	}
+	if p.PushEnabled {
+		channels = append(channels, NotificationChannelPush)
+	}
+	if p.EmailEnabled {
+		channels = append(channels, NotificationChannelEmail)
+	}
+	return channels
+}
+
+// Notification is an in-app notification shown to the user
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    string             `json:"user_id" bson:"user_id"`
+	Type      string             `json:"type" bson:"type"`
+	Payload   map[string]any     `json:"payload" bson:"payload"`
+	Read      bool               `json:"read" bson:"read"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// OpenBadgesContext is the JSON-LD context of Open Badges 2.0 documents
+const OpenBadgesContext = "https://w3id.org/openbadges/v2"
+
+// Open Badges 2.0 document types
+const (
+	OpenBadgeTypeAssertion  = "Assertion"
+	OpenBadgeTypeBadgeClass = "BadgeClass"
+	OpenBadgeTypeIssuer     = "Issuer"
+)
+
+// OpenBadgeVerificationHosted means an assertion is verified by fetching it from its id URL
+const OpenBadgeVerificationHosted = "hosted"
+
+// OpenBadgeAssertion is an Open Badges 2.0 Assertion awarding a badge to one recipient
+type OpenBadgeAssertion struct {
+	Context      string                `json:"@context"`
+	Type         string                `json:"type"`
+	ID           string                `json:"id"`
+	Recipient    OpenBadgeRecipient    `json:"recipient"`
+	Badge        string                `json:"badge"`
+	IssuedOn     time.Time             `json:"issuedOn"`
+	Evidence     []OpenBadgeEvidence   `json:"evidence,omitempty"`
+	Verification OpenBadgeVerification `json:"verification"`
+}
+
+// OpenBadgeRecipient identifies the recipient by a salted hash of their email address
+type OpenBadgeRecipient struct {
+	Type     string `json:"type"`
+	Hashed   bool   `json:"hashed"`
+	Salt     string `json:"salt,omitempty"`
+	Identity string `json:"identity"`
+}
+
+// OpenBadgeEvidence describes the work that earned the badge
+type OpenBadgeEvidence struct {
+	Narrative   string `json:"narrative,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenBadgeVerification tells verifiers how to check an assertion
+type OpenBadgeVerification struct {
+	Type string `json:"type"`
+}
+
+// OpenBadgeClass is an Open Badges 2.0 BadgeClass describing an achievement
+type OpenBadgeClass struct {
+	Context     string            `json:"@context"`
+	Type        string            `json:"type"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Image       string            `json:"image,omitempty"`
+	Criteria    OpenBadgeCriteria `json:"criteria"`
+	Issuer      string            `json:"issuer"`
+	Tags        []string          `json:"tags,omitempty"`
+}
+
+// OpenBadgeCriteria describes how a badge is earned
+type OpenBadgeCriteria struct {
+	Narrative string `json:"narrative"`
+}
+
+// OpenBadgeIssuer is the Open Badges 2.0 Profile of the badge issuer
+type OpenBadgeIssuer struct {
+	Context string `json:"@context"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+}
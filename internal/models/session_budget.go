@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionBudget limits how long a user spends in conversations each day. Past the soft limit the user is reminded
+// to take a break, and past the hard limit new messages are refused until the next day. A zero limit is not
+// enforced.
+type SessionBudget struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID                string             `bson:"user_id" json:"user_id"`
+	DailySoftLimitMinutes int                `bson:"daily_soft_limit_minutes" json:"daily_soft_limit_minutes"`
+	DailyHardLimitMinutes int                `bson:"daily_hard_limit_minutes" json:"daily_hard_limit_minutes"`
+	// Timezone is the IANA time zone whose midnight starts a new day, UTC if empty
+	Timezone string `bson:"timezone" json:"timezone"`
+
+	// DailyMinutesUsed is the conversation time spent on UsageDate, a YYYY-MM-DD date in Timezone
+	DailyMinutesUsed float64   `bson:"daily_minutes_used" json:"daily_minutes_used"`
+	UsageDate        string    `bson:"usage_date" json:"usage_date"`
+	CreatedAt        time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// SessionBudgetDateLayout is the layout of SessionBudget.UsageDate
+const SessionBudgetDateLayout = "2006-01-02"
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Special date occasions
+const (
+	OccasionBirthday    = "birthday"
+	OccasionAnniversary = "anniversary"
+)
+
+// SystemEventSpecialDate marks the message a companion sends to celebrate a special date
+const SystemEventSpecialDate = "special_date_celebration"
+
+// SpecialDates holds the dates a companion celebrates with a user. Zero dates are not celebrated.
+type SpecialDates struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          string             `bson:"user_id" json:"user_id"`
+	CompanionID     string             `bson:"companion_id" json:"companion_id"`
+	Birthdate       time.Time          `bson:"birthdate" json:"birthdate"`
+	AnniversaryDate time.Time          `bson:"anniversary_date" json:"anniversary_date"`
+	LastCelebrated  time.Time          `bson:"last_celebrated" json:"last_celebrated"`
+	Celebrations    []Celebration      `bson:"celebrations" json:"celebrations"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Celebration records that an occasion was celebrated in a given year
+type Celebration struct {
+	Occasion     string    `bson:"occasion" json:"occasion"`
+	Year         int       `bson:"year" json:"year"`
+	CelebratedAt time.Time `bson:"celebrated_at" json:"celebrated_at"`
+}
+
+// CelebratedIn reports whether the occasion has already been celebrated in year
+func (d *SpecialDates) CelebratedIn(occasion string, year int) bool {
+	for _, celebration := range d.Celebrations {
+		if celebration.Occasion == occasion && celebration.Year == year {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,10 +16,25 @@ type User struct {
 	Gender       *string   `db:"gender" json:"gender,omitempty"`
 	AvatarURL    *string   `db:"avatar_url" json:"avatar_url,omitempty"`
 	IsActive     bool      `db:"is_active" json:"is_active"`
+	Permissions  int       `db:"permissions" json:"-"`
+	IsTestUser   bool      `db:"is_test_user" json:"-"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
 }
 
+// User permission bits
+const (
+	// PermissionBypassModeration lets a user's messages skip content moderation
+	PermissionBypassModeration = 1 << iota
+	// PermissionAdmin marks platform operators; it is carried as the admin claim in access tokens
+	PermissionAdmin
+)
+
+// HasPermission reports whether the user holds the permission bit
+func (u *User) HasPermission(permission int) bool {
+	return u.Permissions&permission != 0
+}
+
 type UserPreferences struct {
 	ID                    uuid.UUID `db:"id" json:"id"`
 	UserID                uuid.UUID `db:"user_id" json:"user_id"`
@@ -26,6 +42,28 @@ type UserPreferences struct {
 	PreferredGender       *string   `db:"preferred_gender" json:"preferred_gender,omitempty"`
 	NotificationSettings  any       `db:"notification_settings" json:"notification_settings"`
 	PrivacySettings       any       `db:"privacy_settings" json:"privacy_settings"`
-	CreatedAt             time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt             time.Time `db:"updated_at" json:"updated_at"`
+	// QuietHours is when the user does not want companions to reach out first; nil means any time
+	QuietHours *QuietHours `db:"quiet_hours" json:"quiet_hours,omitempty"`
+	CreatedAt  time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time   `db:"updated_at" json:"updated_at"`
+}
+
+// QuietHours is a daily window, from StartHour up to EndHour in the user's time zone, which may span midnight.
+// Equal hours mean no quiet hours.
+type QuietHours struct {
+	StartHour int `json:"start_hour"`
+	EndHour   int `json:"end_hour"`
+	// Timezone is an IANA time zone name such as "Europe/Berlin"; empty means UTC
+	Timezone string `json:"timezone"`
+}
+
+// Validate checks that both hours are within 0-23
+func (q QuietHours) Validate() error {
+	if q.StartHour < 0 || q.StartHour > 23 {
+		return fmt.Errorf("quiet hours start hour %d is not within 0-23", q.StartHour)
+	}
+	if q.EndHour < 0 || q.EndHour > 23 {
+		return fmt.Errorf("quiet hours end hour %d is not within 0-23", q.EndHour)
+	}
+	return nil
 }
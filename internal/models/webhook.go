@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	URL       string    `db:"url" json:"url"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// AchievementEvent is the payload delivered to webhook endpoints when an achievement is unlocked
+type AchievementEvent struct {
+	Type        string          `json:"type"`
+	UserID      string          `json:"user_id"`
+	CompanionID string          `json:"companion_id"`
+	Achievement UserAchievement `json:"achievement"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
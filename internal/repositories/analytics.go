@@ -3,9 +3,15 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,9 +19,22 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// RelationshipAnalyticsHook is called after a relationship's analytics have been written
+type RelationshipAnalyticsHook func(ctx context.Context, userID, companionID string)
+
+// EngagementAnalyticsHook is called after a user's engagement analytics have been written
+type EngagementAnalyticsHook func(ctx context.Context, userID string)
+
 type AnalyticsRepository struct {
 	db    *sql.DB
 	mongo *mongo.Database
+
+	relationshipHooks []RelationshipAnalyticsHook
+	engagementHooks   []EngagementAnalyticsHook
+
+	// dashboardAggregation caches whether the server supports GetUserDashboardDataAggregated once it is known
+	dashboardAggregationMu sync.Mutex
+	dashboardAggregation   *bool
 }
 
 func NewAnalyticsRepository(db *sql.DB, mongo *mongo.Database) *AnalyticsRepository {
@@ -35,12 +54,30 @@ func (r *AnalyticsRepository) UpsertConversationSummary(ctx context.Context, sum
 }
 
 func (r *AnalyticsRepository) InsertMessageAnalytics(ctx context.Context, analytics *models.MessageAnalytics) error {
-	query := `INSERT INTO message_analytics (id, conversation_id, sender_id, type, sentiment, tokens, created_at)
-		VALUES ($1,$2,$3,$4,$5,$6,NOW())`
-	_, err := r.db.ExecContext(ctx, query, analytics.ID, analytics.ConversationID, analytics.SenderID, analytics.Type, analytics.Sentiment, analytics.Tokens)
+	query := `INSERT INTO message_analytics (id, conversation_id, companion_id, sender_id, type, sentiment, tokens, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,NOW())`
+	_, err := r.db.ExecContext(ctx, query, analytics.ID, analytics.ConversationID, analytics.CompanionID, analytics.SenderID, analytics.Type, analytics.Sentiment, analytics.Tokens)
 	return err
 }
 
+// GetAverageTokensPerConversation gets the mean total token count of a companion's conversations
+func (r *AnalyticsRepository) GetAverageTokensPerConversation(ctx context.Context, companionID string) (float64, error) {
+	query := `
+		SELECT COALESCE(AVG(conversation_tokens), 0)
+		FROM (
+			SELECT conversation_id, SUM(COALESCE(tokens, 0)) AS conversation_tokens
+			FROM message_analytics
+			WHERE companion_id = $1
+			GROUP BY conversation_id
+		) AS per_conversation`
+
+	var average float64
+	if err := r.db.QueryRowContext(ctx, query, companionID).Scan(&average); err != nil {
+		return 0, err
+	}
+	return average, nil
+}
+
 func (r *AnalyticsRepository) InsertMediaFile(ctx context.Context, file *models.MediaFile) error {
 	query := `INSERT INTO media_files (id, user_id, type, s3_url, format, size, status, created_at, updated_at)
 		VALUES ($1,$2,$3,$4,$5,$6,$7,NOW(),NOW())`
@@ -79,12 +116,14 @@ func (r *AnalyticsRepository) UpsertUserEngagementAnalytics(ctx context.Context,
 			"peak_activity_time":   analytics.PeakActivityTime,
 			"session_frequency":    analytics.SessionFrequency,
 			"preferred_topics":     analytics.PreferredTopics,
+			"topic_scores":         analytics.TopicScores,
 			"interaction_style":    analytics.InteractionStyle,
 			"intimacy_growth":      analytics.IntimacyGrowth,
 			"trust_building":       analytics.TrustBuilding,
 			"relationship_stage":   analytics.RelationshipStage,
 			"milestone_progress":   analytics.MilestoneProgress,
 			"sentiment_trend":      analytics.SentimentTrend,
+			"conversation_arc":     analytics.ConversationArc,
 			"emotional_regulation": analytics.EmotionalRegulation,
 			"empathy_response":     analytics.EmpathyResponse,
 			"mood_impact":          analytics.MoodImpact,
@@ -100,8 +139,23 @@ func (r *AnalyticsRepository) UpsertUserEngagementAnalytics(ctx context.Context,
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	return err
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range r.engagementHooks {
+		hook(ctx, analytics.UserID)
+	}
+	return nil
+}
+
+// OnUserEngagementAnalyticsUpsert registers a hook to run after every successful UpsertUserEngagementAnalytics
+func (r *AnalyticsRepository) OnUserEngagementAnalyticsUpsert(hook EngagementAnalyticsHook) {
+	r.engagementHooks = append(r.engagementHooks, hook)
 }
 
 func (r *AnalyticsRepository) GetUserEngagementAnalytics(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID) (*models.UserEngagementAnalytics, error) {
@@ -116,12 +170,62 @@ func (r *AnalyticsRepository) GetUserEngagementAnalytics(ctx context.Context, us
 	var analytics models.UserEngagementAnalytics
 	err := collection.FindOne(ctx, filter).Decode(&analytics)
 	if err != nil {
-		return nil, err
+		return nil, findOneError(err, "engagement analytics")
 	}
 
 	return &analytics, nil
 }
 
+// GetRecentEngagementAnalytics returns the user's most recently updated engagement analytics with the companion,
+// newest first
+func (r *AnalyticsRepository) GetRecentEngagementAnalytics(ctx context.Context, userID, companionID string, limit int) ([]models.UserEngagementAnalytics, error) {
+	filter := bson.M{
+		"user_id":      userID,
+		"companion_id": companionID,
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.mongo.Collection("user_engagement_analytics").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []models.UserEngagementAnalytics{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetRecentConversationArcs returns the arcs of the user's most recently analysed conversations with the companion,
+// newest first
+func (r *AnalyticsRepository) GetRecentConversationArcs(ctx context.Context, userID, companionID string, limit int) ([]models.ConversationArcEntry, error) {
+	filter := bson.M{
+		"user_id":          userID,
+		"companion_id":     companionID,
+		"conversation_arc": bson.M{"$nin": bson.A{nil, ""}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"conversation_id": 1, "conversation_arc": 1, "updated_at": 1})
+
+	cursor, err := r.mongo.Collection("user_engagement_analytics").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	arcs := []models.ConversationArcEntry{}
+	if err := cursor.All(ctx, &arcs); err != nil {
+		return nil, err
+	}
+	return arcs, nil
+}
+
 // Relationship Analytics
 func (r *AnalyticsRepository) UpsertRelationshipAnalytics(ctx context.Context, analytics *models.RelationshipAnalytics) error {
 	collection := r.mongo.Collection("relationship_analytics")
@@ -149,6 +253,7 @@ func (r *AnalyticsRepository) UpsertRelationshipAnalytics(ctx context.Context, a
 			"health_score":           analytics.HealthScore,
 			"red_flags":              analytics.RedFlags,
 			"strengths":              analytics.Strengths,
+			"chemistry_score":        analytics.ChemistryScore,
 			"updated_at":             time.Now(),
 		},
 		"$setOnInsert": bson.M{
@@ -160,8 +265,33 @@ func (r *AnalyticsRepository) UpsertRelationshipAnalytics(ctx context.Context, a
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	return err
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range r.relationshipHooks {
+		hook(ctx, analytics.UserID, analytics.CompanionID)
+	}
+	return nil
+}
+
+// SetChemistryScore updates the chemistry score of existing relationship analytics
+func (r *AnalyticsRepository) SetChemistryScore(ctx context.Context, userID, companionID string, score float64) error {
+	filter := bson.M{"user_id": userID, "companion_id": companionID}
+	update := bson.M{"$set": bson.M{"chemistry_score": score}}
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.mongo.Collection("relationship_analytics").UpdateOne(ctx, filter, update)
+		return err
+	})
+}
+
+// OnRelationshipAnalyticsUpsert registers a hook to run after every successful UpsertRelationshipAnalytics
+func (r *AnalyticsRepository) OnRelationshipAnalyticsUpsert(hook RelationshipAnalyticsHook) {
+	r.relationshipHooks = append(r.relationshipHooks, hook)
 }
 
 func (r *AnalyticsRepository) GetRelationshipAnalytics(ctx context.Context, userID, companionID string) (*models.RelationshipAnalytics, error) {
@@ -175,12 +305,358 @@ func (r *AnalyticsRepository) GetRelationshipAnalytics(ctx context.Context, user
 	var analytics models.RelationshipAnalytics
 	err := collection.FindOne(ctx, filter).Decode(&analytics)
 	if err != nil {
-		return nil, err
+		return nil, findOneError(err, "relationship analytics")
 	}
 
 	return &analytics, nil
 }
 
+// GetHealthAlertState returns the relationship's health alert state
+func (r *AnalyticsRepository) GetHealthAlertState(ctx context.Context, userID, companionID string) (*models.HealthAlertState, error) {
+	var state models.HealthAlertState
+	err := r.mongo.Collection("health_alert_states").FindOne(ctx, bson.M{"user_id": userID, "companion_id": companionID}).Decode(&state)
+	if err != nil {
+		return nil, findOneError(err, "health alert state")
+	}
+	return &state, nil
+}
+
+// UpsertHealthAlertState stores the relationship's health alert state
+func (r *AnalyticsRepository) UpsertHealthAlertState(ctx context.Context, state *models.HealthAlertState) error {
+	filter := bson.M{"user_id": state.UserID, "companion_id": state.CompanionID}
+	update := bson.M{
+		"$set": bson.M{
+			"last_score":         state.LastScore,
+			"last_alerted_score": state.LastAlertedScore,
+			"updated_at":         time.Now(),
+		},
+		"$setOnInsert": bson.M{
+			"_id":          primitive.NewObjectID(),
+			"user_id":      state.UserID,
+			"companion_id": state.CompanionID,
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.mongo.Collection("health_alert_states").UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+}
+
+// GetSessionBudget returns the user's session budget as stored; the usage may belong to an earlier day
+func (r *AnalyticsRepository) GetSessionBudget(ctx context.Context, userID string) (*models.SessionBudget, error) {
+	var budget models.SessionBudget
+	err := r.mongo.Collection("session_budgets").FindOne(ctx, bson.M{"user_id": userID}).Decode(&budget)
+	if err != nil {
+		return nil, findOneError(err, "session budget")
+	}
+	return &budget, nil
+}
+
+// UpsertSessionBudget stores the user's session budget limits and time zone, keeping the usage recorded so far
+func (r *AnalyticsRepository) UpsertSessionBudget(ctx context.Context, budget *models.SessionBudget) error {
+	now := time.Now()
+	filter := bson.M{"user_id": budget.UserID}
+	update := bson.M{
+		"$set": bson.M{
+			"daily_soft_limit_minutes": budget.DailySoftLimitMinutes,
+			"daily_hard_limit_minutes": budget.DailyHardLimitMinutes,
+			"timezone":                 budget.Timezone,
+			"updated_at":               now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":                primitive.NewObjectID(),
+			"user_id":            budget.UserID,
+			"daily_minutes_used": 0.0,
+			"usage_date":         "",
+			"created_at":         now,
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.mongo.Collection("session_budgets").UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+}
+
+// DeleteSessionBudget removes the user's session budget
+func (r *AnalyticsRepository) DeleteSessionBudget(ctx context.Context, userID string) error {
+	result, err := r.mongo.Collection("session_budgets").DeleteOne(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return apperrors.NewDatabaseError("failed to delete session budget", err)
+	}
+	if result.DeletedCount == 0 {
+		return apperrors.NewNotFoundError("session budget not found", nil)
+	}
+	return nil
+}
+
+// AddSessionMinutes adds minutes to the usage of the user's session budget on day, a YYYY-MM-DD date. Usage recorded
+// for an earlier day is replaced rather than added to. Users without a session budget are not tracked.
+func (r *AnalyticsRepository) AddSessionMinutes(ctx context.Context, userID, day string, minutes float64) error {
+	collection := r.mongo.Collection("session_budgets")
+	for {
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"user_id": userID, "usage_date": day},
+			bson.M{"$inc": bson.M{"daily_minutes_used": minutes}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+
+		// The stored usage is from an earlier day, or the user has no budget
+		result, err = collection.UpdateOne(ctx,
+			bson.M{"user_id": userID, "usage_date": bson.M{"$ne": day}},
+			bson.M{"$set": bson.M{"daily_minutes_used": minutes, "usage_date": day}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+
+		// Either there is no budget, or a concurrent session started the day first and the increment can be retried
+		if _, err := r.GetSessionBudget(ctx, userID); err != nil {
+			var notFound *apperrors.NotFoundError
+			if errors.As(err, &notFound) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// UpdateEmotionTransitionMatrix stores the emotion transition matrix of a relationship
+func (r *AnalyticsRepository) UpdateEmotionTransitionMatrix(ctx context.Context, userID, companionID string, matrix map[string]map[string]float64) error {
+	collection := r.mongo.Collection("relationship_analytics")
+
+	filter := bson.M{
+		"user_id":      userID,
+		"companion_id": companionID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"emotion_transition_matrix": matrix,
+			"updated_at":                time.Now(),
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": time.Now(),
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+}
+
+// GetEmotionTransitionCounts counts consecutive primary emotion pairs across every conversation's emotional history
+func (r *AnalyticsRepository) GetEmotionTransitionCounts(ctx context.Context) (map[string]map[string]int, error) {
+	collection := r.mongo.Collection("conversation_contexts")
+
+	emotions := "$emotional_history.emotional_state.primary_emotion"
+	pipeline := mongo.Pipeline{
+		{{Key: "$project", Value: bson.M{"emotions": emotions}}},
+		{{Key: "$project", Value: bson.M{
+			"pairs": bson.M{"$zip": bson.M{"inputs": bson.A{
+				"$emotions",
+				bson.M{"$slice": bson.A{"$emotions", 1, bson.M{"$max": bson.A{1, bson.M{"$size": "$emotions"}}}}},
+			}}},
+		}}},
+		{{Key: "$unwind", Value: "$pairs"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"from": bson.M{"$arrayElemAt": bson.A{"$pairs", 0}},
+				"to":   bson.M{"$arrayElemAt": bson.A{"$pairs", 1}},
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID struct {
+				From string `bson:"from"`
+				To   string `bson:"to"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		if result.ID.From == "" || result.ID.To == "" {
+			continue
+		}
+		if counts[result.ID.From] == nil {
+			counts[result.ID.From] = make(map[string]int)
+		}
+		counts[result.ID.From][result.ID.To] += result.Count
+	}
+
+	return counts, cursor.Err()
+}
+
+// GetAllRelationshipAnalytics gets relationship analytics for every companion of a user
+func (r *AnalyticsRepository) GetAllRelationshipAnalytics(ctx context.Context, userID string) ([]models.RelationshipAnalytics, error) {
+	collection := r.mongo.Collection("relationship_analytics")
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var analytics []models.RelationshipAnalytics
+	if err = cursor.All(ctx, &analytics); err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
+
+// ListRelationshipAnalyticsUpdatedSince returns every relationship whose analytics changed at or after since
+func (r *AnalyticsRepository) ListRelationshipAnalyticsUpdatedSince(ctx context.Context, since time.Time) ([]models.RelationshipAnalytics, error) {
+	collection := r.mongo.Collection("relationship_analytics")
+
+	cursor, err := collection.Find(ctx, bson.M{"updated_at": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var analytics []models.RelationshipAnalytics
+	if err = cursor.All(ctx, &analytics); err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
+
+// Weekly Health Snapshots
+
+// InsertHealthSnapshot stores a weekly health snapshot unless one already exists for the relationship and week
+func (r *AnalyticsRepository) InsertHealthSnapshot(ctx context.Context, snapshot *models.RelationshipHealthSnapshot) error {
+	collection := r.mongo.Collection("weekly_health_snapshots")
+
+	filter := bson.M{
+		"user_id":      snapshot.UserID,
+		"companion_id": snapshot.CompanionID,
+		"week_start":   snapshot.WeekStart,
+	}
+	update := bson.M{"$setOnInsert": bson.M{
+		"health_score":   snapshot.HealthScore,
+		"trust_level":    snapshot.TrustLevel,
+		"intimacy_level": snapshot.IntimacyLevel,
+		"stage":          snapshot.Stage,
+		"created_at":     snapshot.CreatedAt,
+	}}
+
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		return err
+	})
+}
+
+// GetHealthScoreHistory returns up to the last weeks weekly health snapshots of a relationship, oldest first
+func (r *AnalyticsRepository) GetHealthScoreHistory(ctx context.Context, userID, companionID string, weeks int) ([]models.RelationshipHealthSnapshot, error) {
+	collection := r.mongo.Collection("weekly_health_snapshots")
+
+	filter := bson.M{"user_id": userID, "companion_id": companionID}
+	opts := options.Find().SetSort(bson.D{{Key: "week_start", Value: -1}}).SetLimit(int64(weeks))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var snapshots []models.RelationshipHealthSnapshot
+	if err = cursor.All(ctx, &snapshots); err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(snapshots)
+	return snapshots, nil
+}
+
+// Consent Audit Log
+func (r *AnalyticsRepository) InsertConsentAuditEvent(ctx context.Context, event *models.ConsentAuditEvent) error {
+	collection := r.mongo.Collection("consent_audit_log")
+
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = time.Now()
+
+	_, err := collection.InsertOne(ctx, event)
+	return err
+}
+
+// GetConsentAuditLog returns a user's most recent consent audit events, newest first
+func (r *AnalyticsRepository) GetConsentAuditLog(ctx context.Context, userID string, limit int) ([]models.ConsentAuditEvent, error) {
+	collection := r.mongo.Collection("consent_audit_log")
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []models.ConsentAuditEvent
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Companion Reputation
+func (r *AnalyticsRepository) UpsertCompanionReputation(ctx context.Context, reputation *models.CompanionReputation) error {
+	collection := r.mongo.Collection("companion_reputation")
+
+	filter := bson.M{"companion_id": reputation.CompanionID}
+	update := bson.M{
+		"$set": bson.M{
+			"average_quality": reputation.AverageQuality,
+			"sample_size":     reputation.SampleSize,
+			"quality_trend":   reputation.QualityTrend,
+			"recent_scores":   reputation.RecentScores,
+			"last_updated":    reputation.LastUpdated,
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+}
+
+func (r *AnalyticsRepository) GetCompanionReputation(ctx context.Context, companionID string) (*models.CompanionReputation, error) {
+	collection := r.mongo.Collection("companion_reputation")
+
+	var reputation models.CompanionReputation
+	err := collection.FindOne(ctx, bson.M{"companion_id": companionID}).Decode(&reputation)
+	if err != nil {
+		return nil, findOneError(err, "companion reputation")
+	}
+
+	return &reputation, nil
+}
+
 // Real-time Analytics
 func (r *AnalyticsRepository) UpsertRealTimeMetrics(ctx context.Context, metrics *models.RealTimeMetrics) error {
 	collection := r.mongo.Collection("real_time_metrics")
@@ -217,7 +693,48 @@ func (r *AnalyticsRepository) UpsertRealTimeMetrics(ctx context.Context, metrics
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
+}
+
+// ListInactiveRealTimeMetrics returns real-time metrics of inactive sessions last updated before cutoff
+func (r *AnalyticsRepository) ListInactiveRealTimeMetrics(ctx context.Context, cutoff time.Time) ([]models.RealTimeMetrics, error) {
+	collection := r.mongo.Collection("real_time_metrics")
+
+	filter := bson.M{
+		"is_active": false,
+		"timestamp": bson.M{"$lt": cutoff},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var metrics []models.RealTimeMetrics
+	if err := cursor.All(ctx, &metrics); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// WithTransaction runs fn in a MongoDB multi-document transaction, committing if it returns nil and aborting
+// otherwise. fn must pass the ctx it receives to every write so they join the transaction, and may be retried
+// on transient transaction errors.
+func (r *AnalyticsRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := r.mongo.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+		return nil, fn(sessionCtx)
+	})
 	return err
 }
 
@@ -241,10 +758,7 @@ func (r *AnalyticsRepository) UpsertUserProgress(ctx context.Context, progress *
 			"relationship_stage":     progress.RelationshipStage,
 			"stage_progress":         progress.StageProgress,
 			"stage_milestones":       progress.StageMilestones,
-			"current_streak":         progress.CurrentStreak,
-			"longest_streak":         progress.LongestStreak,
 			"streak_type":            progress.StreakType,
-			"last_activity_date":     progress.LastActivityDate,
 			"total_achievements":     progress.TotalAchievements,
 			"rare_achievements":      progress.RareAchievements,
 			"achievement_progress":   progress.AchievementProgress,
@@ -263,8 +777,14 @@ func (r *AnalyticsRepository) UpsertUserProgress(ctx context.Context, progress *
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	return err
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		if isDuplicateKeyError(err) {
+			// A concurrent upsert inserted the document first; updating again matches it
+			_, err = collection.UpdateOne(ctx, filter, update, opts)
+		}
+		return err
+	})
 }
 
 func (r *AnalyticsRepository) GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error) {
@@ -278,12 +798,134 @@ func (r *AnalyticsRepository) GetUserProgress(ctx context.Context, userID, compa
 	var progress models.UserProgress
 	err := collection.FindOne(ctx, filter).Decode(&progress)
 	if err != nil {
-		return nil, err
+		return nil, findOneError(err, "user progress")
 	}
 
 	return &progress, nil
 }
 
+// AtomicStreakIncrement advances the streak at most once per day, returning whether an update happened. The
+// progress document is created first when the user has none yet.
+func (r *AnalyticsRepository) AtomicStreakIncrement(ctx context.Context, userID, companionID string, today time.Time) (bool, error) {
+	collection := r.mongo.Collection("user_progress")
+
+	today = streakDay(today)
+	yesterday := today.AddDate(0, 0, -1)
+
+	progressFilter := bson.M{"user_id": userID, "companion_id": companionID}
+	insert := bson.M{
+		"$setOnInsert": bson.M{
+			"_id":          primitive.NewObjectID(),
+			"user_id":      userID,
+			"companion_id": companionID,
+			"created_at":   time.Now(),
+		},
+	}
+	// A duplicate key means a concurrent call inserted the document first, which is all this step needs
+	if _, err := collection.UpdateOne(ctx, progressFilter, insert, options.Update().SetUpsert(true)); err != nil && !isDuplicateKeyError(err) {
+		return false, err
+	}
+
+	// Only documents not yet updated today match, so concurrent calls cannot double-increment. A missing
+	// last_activity_date means the streak has never started.
+	filter := bson.M{
+		"user_id":      userID,
+		"companion_id": companionID,
+		"$or": bson.A{
+			bson.M{"last_activity_date": bson.M{"$lt": today}},
+			bson.M{"last_activity_date": nil},
+		},
+	}
+
+	// Together with the filter, any activity during yesterday continues the streak; last_activity_date is not always
+	// truncated to midnight
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"current_streak": bson.M{
+				"$cond": bson.A{
+					bson.M{"$gte": bson.A{"$last_activity_date", yesterday}},
+					bson.M{"$add": bson.A{"$current_streak", 1}},
+					1,
+				},
+			},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"longest_streak":     bson.M{"$max": bson.A{"$longest_streak", "$current_streak"}},
+			"last_activity_date": today,
+			"updated_at":         time.Now(),
+		}}},
+	}
+
+	err := collection.FindOneAndUpdate(ctx, filter, update).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// streakDay returns the start of the UTC calendar day containing t; streak days run midnight to midnight UTC
+func streakDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// GetAllUserProgress gets progress for every companion of a user
+func (r *AnalyticsRepository) GetAllUserProgress(ctx context.Context, userID string) ([]models.UserProgress, error) {
+	collection := r.mongo.Collection("user_progress")
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var progress []models.UserProgress
+	if err = cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+// RecordExperienceGain stores experience points awarded to a user
+func (r *AnalyticsRepository) RecordExperienceGain(ctx context.Context, gain *models.ExperienceGain) error {
+	gain.ID = primitive.NewObjectID()
+	if gain.CreatedAt.IsZero() {
+		gain.CreatedAt = time.Now()
+	}
+
+	_, err := r.mongo.Collection("experience_gains").InsertOne(ctx, gain)
+	return err
+}
+
+// SumExperienceSince totals the experience the user has gained with a companion since the given time
+func (r *AnalyticsRepository) SumExperienceSince(ctx context.Context, userID, companionID string, since time.Time) (int, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"user_id": userID, "companion_id": companionID, "created_at": bson.M{"$gte": since}}},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}},
+	}
+
+	cursor, err := r.mongo.Collection("experience_gains").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var results []struct {
+		Total int `bson:"total"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return 0, err
+	}
+
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Total, nil
+}
+
 // User Achievements
 func (r *AnalyticsRepository) InsertUserAchievement(ctx context.Context, achievement *models.UserAchievement) error {
 	collection := r.mongo.Collection("user_achievements")
@@ -361,6 +1003,17 @@ func (r *AnalyticsRepository) GetAchievementDefinitions(ctx context.Context, cat
 	return definitions, nil
 }
 
+// GetUserAchievement returns a single awarded achievement
+func (r *AnalyticsRepository) GetUserAchievement(ctx context.Context, id primitive.ObjectID) (*models.UserAchievement, error) {
+	var achievement models.UserAchievement
+	err := r.mongo.Collection("user_achievements").FindOne(ctx, bson.M{"_id": id}).Decode(&achievement)
+	if err != nil {
+		return nil, findOneError(err, "user achievement")
+	}
+
+	return &achievement, nil
+}
+
 func (r *AnalyticsRepository) GetAchievementDefinition(ctx context.Context, achievementID string) (*models.AchievementDefinition, error) {
 	collection := r.mongo.Collection("achievement_definitions")
 
@@ -372,7 +1025,7 @@ func (r *AnalyticsRepository) GetAchievementDefinition(ctx context.Context, achi
 	var definition models.AchievementDefinition
 	err := collection.FindOne(ctx, filter).Decode(&definition)
 	if err != nil {
-		return nil, err
+		return nil, findOneError(err, "achievement definition")
 	}
 
 	return &definition, nil
@@ -427,17 +1080,139 @@ func (r *AnalyticsRepository) UpsertUserBehaviorPrediction(ctx context.Context,
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
-	return err
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update, opts)
+		return err
+	})
 }
 
 // Analytics Queries and Aggregations
 
+// Dashboard sizes, shared by GetUserDashboardDataAggregated and the separate queries it replaces
+const (
+	DashboardRecentAchievements = 5
+	DashboardTrendDays          = 30
+)
+
+// dashboardAggregationMinMajorVersion is the oldest MongoDB release GetUserDashboardDataAggregated is used on.
+// Uncorrelated $lookup pipelines arrived in 3.6, but 4.0 is the oldest release still receiving fixes.
+const dashboardAggregationMinMajorVersion = 4
+
+// SupportsDashboardAggregation reports whether the server runs MongoDB 4.0 or later, so the dashboard can be loaded
+// with GetUserDashboardDataAggregated. The answer is cached once the server version has been read; a failed read
+// reports false.
+func (r *AnalyticsRepository) SupportsDashboardAggregation(ctx context.Context) bool {
+	r.dashboardAggregationMu.Lock()
+	defer r.dashboardAggregationMu.Unlock()
+
+	if r.dashboardAggregation == nil {
+		major, _, err := mongodb.ServerVersion(ctx, r.mongo)
+		if err != nil {
+			return false
+		}
+		supported := major >= dashboardAggregationMinMajorVersion
+		r.dashboardAggregation = &supported
+	}
+	return *r.dashboardAggregation
+}
+
+// GetUserDashboardDataAggregated loads a relationship's progress, recent achievements, relationship analytics and
+// engagement trends in one round-trip, returning a dashboard with only those fields set. Each $lookup matches on
+// user_id and companion_id, the prefix of the indexes RunMigrations creates on the joined collections. Returns a
+// NotFoundError if the relationship has no progress.
+func (r *AnalyticsRepository) GetUserDashboardDataAggregated(ctx context.Context, userID, companionID string) (*models.UserDashboardData, error) {
+	cursor, err := r.mongo.Collection("user_progress").Aggregate(ctx, dashboardPipeline(userID, companionID))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Progress              models.UserProgress            `bson:"progress"`
+		RecentAchievements    []models.UserAchievement       `bson:"recent_achievements"`
+		RelationshipAnalytics []models.RelationshipAnalytics `bson:"relationship_analytics"`
+		EngagementTrends      []engagementTrendResult        `bson:"engagement_trends"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, findOneError(mongo.ErrNoDocuments, "user progress")
+	}
+
+	result := results[0]
+	dashboard := &models.UserDashboardData{
+		UserID:           userID,
+		CompanionID:      companionID,
+		Progress:         &result.Progress,
+		EngagementTrends: toEngagementTrendPoints(result.EngagementTrends),
+	}
+	if len(result.RecentAchievements) > 0 {
+		dashboard.RecentAchievements = result.RecentAchievements
+	}
+	if len(result.RelationshipAnalytics) > 0 {
+		dashboard.RelationshipAnalytics = &result.RelationshipAnalytics[0]
+	}
+	return dashboard, nil
+}
+
+// dashboardPipeline starts from the relationship's progress and joins the rest of the dashboard. The joins are
+// uncorrelated since the relationship is known up front, so each runs once as an indexed query.
+func dashboardPipeline(userID, companionID string) []bson.M {
+	relationship := bson.M{"user_id": userID, "companion_id": companionID}
+	return []bson.M{
+		{"$match": relationship},
+		{"$limit": 1},
+		{"$replaceRoot": bson.M{"newRoot": bson.M{"progress": "$$ROOT"}}},
+		{
+			"$lookup": bson.M{
+				"from": "user_achievements",
+				"pipeline": []bson.M{
+					{"$match": relationship},
+					{"$sort": bson.M{"earned_at": -1}},
+					{"$limit": DashboardRecentAchievements},
+				},
+				"as": "recent_achievements",
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":     "relationship_analytics",
+				"pipeline": []bson.M{{"$match": relationship}, {"$limit": 1}},
+				"as":       "relationship_analytics",
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":     "user_engagement_analytics",
+				"pipeline": engagementTrendStages(userID, companionID, DashboardTrendDays),
+				"as":       "engagement_trends",
+			},
+		},
+	}
+}
+
 // Get engagement trends for a user
 func (r *AnalyticsRepository) GetEngagementTrends(ctx context.Context, userID, companionID string, days int) ([]models.EngagementTrendPoint, error) {
 	collection := r.mongo.Collection("user_engagement_analytics")
 
-	pipeline := []bson.M{
+	cursor, err := collection.Aggregate(ctx, engagementTrendStages(userID, companionID, days))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []engagementTrendResult
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return toEngagementTrendPoints(results), nil
+}
+
+// engagementTrendStages groups a relationship's engagement over the last days into one point per day, oldest first
+func engagementTrendStages(userID, companionID string, days int) []bson.M {
+	return []bson.M{
 		{
 			"$match": bson.M{
 				"user_id":      userID,
@@ -465,6 +1240,46 @@ func (r *AnalyticsRepository) GetEngagementTrends(ctx context.Context, userID, c
 			"$sort": bson.M{"_id": 1},
 		},
 	}
+}
+
+// engagementTrendResult is one day grouped by engagementTrendStages. Numbers are decoded as float64 since $avg
+// returns doubles and $sum returns whichever numeric type fits.
+type engagementTrendResult struct {
+	Date            string  `bson:"_id"`
+	EngagementScore float64 `bson:"engagement_score"`
+	SessionCount    float64 `bson:"session_count"`
+	MessageCount    float64 `bson:"message_count"`
+	Duration        float64 `bson:"duration"`
+}
+
+func toEngagementTrendPoints(results []engagementTrendResult) []models.EngagementTrendPoint {
+	var trends []models.EngagementTrendPoint
+	for _, result := range results {
+		date, _ := time.Parse("2006-01-02", result.Date)
+		trends = append(trends, models.EngagementTrendPoint{
+			Date:            date,
+			EngagementScore: result.EngagementScore,
+			SessionCount:    int(result.SessionCount),
+			MessageCount:    int(result.MessageCount),
+			Duration:        time.Duration(result.Duration),
+		})
+	}
+	return trends
+}
+
+// GetCompanionEngagementScores gets the average engagement score per companion for a user
+func (r *AnalyticsRepository) GetCompanionEngagementScores(ctx context.Context, userID string) (map[string]float64, error) {
+	collection := r.mongo.Collection("user_engagement_analytics")
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"user_id": userID}},
+		{
+			"$group": bson.M{
+				"_id":              "$companion_id",
+				"engagement_score": bson.M{"$avg": "$engagement_score"},
+			},
+		},
+	}
 
 	cursor, err := collection.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -472,25 +1287,20 @@ func (r *AnalyticsRepository) GetEngagementTrends(ctx context.Context, userID, c
 	}
 	defer cursor.Close(ctx)
 
-	var results []bson.M
+	var results []struct {
+		CompanionID     string  `bson:"_id"`
+		EngagementScore float64 `bson:"engagement_score"`
+	}
 	if err = cursor.All(ctx, &results); err != nil {
 		return nil, err
 	}
 
-	var trends []models.EngagementTrendPoint
+	scores := make(map[string]float64, len(results))
 	for _, result := range results {
-		date, _ := time.Parse("2006-01-02", result["_id"].(string))
-		trend := models.EngagementTrendPoint{
-			Date:            date,
-			EngagementScore: result["engagement_score"].(float64),
-			SessionCount:    int(result["session_count"].(int32)),
-			MessageCount:    int(result["message_count"].(int32)),
-			Duration:        time.Duration(result["duration"].(int64)),
-		}
-		trends = append(trends, trend)
+		scores[result.CompanionID] = result.EngagementScore
 	}
 
-	return trends, nil
+	return scores, nil
 }
 
 // Get user statistics
@@ -543,6 +1353,169 @@ func (r *AnalyticsRepository) GetUserStatistics(ctx context.Context, userID, com
 	return stats, nil
 }
 
+// Daily statistics rollup
+
+// ComputeDailyStatistics aggregates per-user statistics for the UTC calendar day containing day
+func (r *AnalyticsRepository) ComputeDailyStatistics(ctx context.Context, day time.Time) ([]models.DailyUserStatistics, error) {
+	day = day.UTC()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	return r.aggregateDailyStatistics(ctx, bson.M{
+		"created_at": bson.M{
+			"$gte": start,
+			"$lt":  end,
+		},
+	})
+}
+
+// ComputeUserDailyStatistics aggregates a user's statistics with a companion for every UTC calendar day from the day
+// containing since onward, oldest first. A zero since covers the whole history.
+func (r *AnalyticsRepository) ComputeUserDailyStatistics(ctx context.Context, userID, companionID string, since time.Time) ([]models.DailyUserStatistics, error) {
+	match := bson.M{
+		"user_id":      userID,
+		"companion_id": companionID,
+	}
+	if !since.IsZero() {
+		since = since.UTC()
+		match["created_at"] = bson.M{"$gte": time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.UTC)}
+	}
+
+	return r.aggregateDailyStatistics(ctx, match)
+}
+
+// aggregateDailyStatistics groups the engagement documents matching match into statistics per user, companion and
+// UTC day, oldest first
+func (r *AnalyticsRepository) aggregateDailyStatistics(ctx context.Context, match bson.M) ([]models.DailyUserStatistics, error) {
+	collection := r.mongo.Collection("user_engagement_analytics")
+
+	// Grouped by hour as well, so that the busiest hour can be found across days
+	pipeline := []bson.M{
+		{"$match": match},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"user_id":      "$user_id",
+					"companion_id": "$companion_id",
+					"day":          bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+					"hour":         bson.M{"$hour": "$created_at"},
+				},
+				"sessions":         bson.M{"$sum": 1},
+				"messages":         bson.M{"$sum": "$messages_per_session"},
+				"session_duration": bson.M{"$sum": "$session_duration"},
+				"engagement_score": bson.M{"$sum": "$engagement_score"},
+			},
+		},
+		{"$sort": bson.D{{Key: "_id.day", Value: 1}, {Key: "_id.hour", Value: 1}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID struct {
+			UserID      string `bson:"user_id"`
+			CompanionID string `bson:"companion_id"`
+			Day         string `bson:"day"`
+			Hour        int    `bson:"hour"`
+		} `bson:"_id"`
+		Sessions        int     `bson:"sessions"`
+		Messages        int     `bson:"messages"`
+		SessionDuration float64 `bson:"session_duration"`
+		EngagementScore float64 `bson:"engagement_score"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	type dayKey struct{ userID, companionID, day string }
+	type dayTotals struct {
+		stat       models.DailyUserStatistics
+		duration   float64
+		engagement float64
+	}
+	var order []dayKey
+	totals := make(map[dayKey]*dayTotals)
+	for _, result := range results {
+		key := dayKey{result.ID.UserID, result.ID.CompanionID, result.ID.Day}
+		day, ok := totals[key]
+		if !ok {
+			statDate, err := time.Parse(time.DateOnly, result.ID.Day)
+			if err != nil {
+				return nil, err
+			}
+			day = &dayTotals{stat: models.DailyUserStatistics{UserID: key.userID, CompanionID: key.companionID, StatDate: statDate}}
+			totals[key] = day
+			order = append(order, key)
+		}
+		day.stat.TotalSessions += result.Sessions
+		day.stat.TotalMessages += result.Messages
+		day.stat.SessionsByHour[result.ID.Hour] += result.Sessions
+		day.duration += result.SessionDuration
+		day.engagement += result.EngagementScore
+	}
+
+	stats := make([]models.DailyUserStatistics, 0, len(order))
+	for _, key := range order {
+		day := totals[key]
+		sessions := float64(day.stat.TotalSessions)
+		day.stat.AverageSessionLength = time.Duration(day.duration / sessions)
+		day.stat.AverageMessagesPerSession = float64(day.stat.TotalMessages) / sessions
+		day.stat.EngagementScore = day.engagement / sessions
+		stats = append(stats, day.stat)
+	}
+
+	return stats, nil
+}
+
+// UpsertDailyStatistics stores a daily statistics snapshot
+func (r *AnalyticsRepository) UpsertDailyStatistics(ctx context.Context, stat *models.DailyUserStatistics) error {
+	sessionsByHour, err := json.Marshal(stat.SessionsByHour)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_statistics_daily (user_id, companion_id, stat_date, total_sessions, total_messages, avg_session_length, avg_messages_per_session, engagement_score, sessions_by_hour, created_at, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,NOW(),NOW())
+		ON CONFLICT (user_id, companion_id, stat_date) DO UPDATE SET total_sessions=$4, total_messages=$5, avg_session_length=$6, avg_messages_per_session=$7, engagement_score=$8, sessions_by_hour=$9, updated_at=NOW()`
+	_, err = r.db.ExecContext(ctx, query, stat.UserID, stat.CompanionID, stat.StatDate, stat.TotalSessions, stat.TotalMessages, int64(stat.AverageSessionLength), stat.AverageMessagesPerSession, stat.EngagementScore, sessionsByHour)
+	return err
+}
+
+// GetDailyStatistics returns all daily statistics snapshots for a user and companion
+func (r *AnalyticsRepository) GetDailyStatistics(ctx context.Context, userID, companionID string) ([]models.DailyUserStatistics, error) {
+	query := `SELECT user_id, companion_id, stat_date, total_sessions, total_messages, avg_session_length, avg_messages_per_session, engagement_score, sessions_by_hour, created_at, updated_at
+		FROM user_statistics_daily WHERE user_id=$1 AND companion_id=$2 ORDER BY stat_date`
+	rows, err := r.db.QueryContext(ctx, query, userID, companionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.DailyUserStatistics
+	for rows.Next() {
+		var stat models.DailyUserStatistics
+		var avgSessionLength int64
+		var sessionsByHour []byte
+		if err := rows.Scan(&stat.UserID, &stat.CompanionID, &stat.StatDate, &stat.TotalSessions, &stat.TotalMessages, &avgSessionLength, &stat.AverageMessagesPerSession, &stat.EngagementScore, &sessionsByHour, &stat.CreatedAt, &stat.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stat.AverageSessionLength = time.Duration(avgSessionLength)
+		// Snapshots rolled up before hourly counts were kept have none
+		if sessionsByHour != nil {
+			if err := json.Unmarshal(sessionsByHour, &stat.SessionsByHour); err != nil {
+				return nil, err
+			}
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
 // Get streak information
 func (r *AnalyticsRepository) GetStreakInformation(ctx context.Context, userID, companionID string) (*models.StreakInformation, error) {
 	progress, err := r.GetUserProgress(ctx, userID, companionID)
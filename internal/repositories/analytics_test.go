@@ -0,0 +1,461 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAtomicStreakIncrement(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	today := time.Date(2024, 6, 2, 15, 30, 0, 0, time.UTC)
+
+	mt.Run("increments when not yet active today", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{{Key: "user_id", Value: "user"}, {Key: "current_streak", Value: 3}}},
+		})
+
+		updated, err := NewAnalyticsRepository(nil, mt.DB).AtomicStreakIncrement(context.Background(), "user", "companion", today)
+		require.NoError(t, err)
+		assert.True(t, updated)
+
+		ensure := mt.GetStartedEvent()
+		require.NotNil(t, ensure)
+		assert.Equal(t, "update", ensure.CommandName)
+		upsert := ensure.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.True(t, upsert.Lookup("upsert").Boolean(), "a user without progress gets a document to start the streak on")
+		_, err = upsert.Lookup("u", "$setOnInsert").Document().Elements()
+		require.NoError(t, err)
+
+		started := mt.GetStartedEvent()
+		require.NotNil(t, started)
+		assert.Equal(t, "findAndModify", started.CommandName)
+
+		filter := started.Command.Lookup("query").Document()
+		assert.Equal(t, "user", filter.Lookup("user_id").StringValue())
+		assert.Equal(t, "companion", filter.Lookup("companion_id").StringValue())
+		notToday := filter.Lookup("$or").Array()
+		lastActivity := notToday.Index(0).Value().Document().Lookup("last_activity_date", "$lt").Time()
+		assert.True(t, lastActivity.Equal(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, bson.TypeNull, notToday.Index(1).Value().Document().Lookup("last_activity_date").Type,
+			"a streak that never started matches")
+
+		continues := started.Command.Lookup("update").Array().Index(0).Value().Document().
+			Lookup("$set", "current_streak", "$cond").Array().Index(0).Value().Document()
+		yesterday := continues.Lookup("$gte").Array().Index(1).Value().Time()
+		assert.True(t, yesterday.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)), "any activity yesterday continues the streak")
+	})
+
+	mt.Run("uses the UTC day whatever the caller's zone", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		tokyo := time.FixedZone("JST", 9*60*60)
+		_, err := NewAnalyticsRepository(nil, mt.DB).AtomicStreakIncrement(context.Background(), "user", "companion",
+			time.Date(2024, 6, 3, 2, 0, 0, 0, tokyo))
+		require.NoError(t, err)
+
+		mt.GetStartedEvent()
+		filter := mt.GetStartedEvent().Command.Lookup("query").Document()
+		lastActivity := filter.Lookup("$or").Array().Index(0).Value().Document().Lookup("last_activity_date", "$lt").Time()
+		assert.True(t, lastActivity.Equal(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)))
+	})
+
+	mt.Run("continues when a concurrent call created the progress document", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "current_streak", Value: 1}}}},
+		)
+
+		updated, err := NewAnalyticsRepository(nil, mt.DB).AtomicStreakIncrement(context.Background(), "user", "companion", today)
+		require.NoError(t, err)
+		assert.True(t, updated)
+	})
+
+	mt.Run("no-op when already active today", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: nil},
+		})
+
+		updated, err := NewAnalyticsRepository(nil, mt.DB).AtomicStreakIncrement(context.Background(), "user", "companion", today)
+		require.NoError(t, err)
+		assert.False(t, updated)
+	})
+}
+
+func TestUpsertUserProgressLeavesStreakAlone(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("updates the document a concurrent upsert inserted", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := NewAnalyticsRepository(nil, mt.DB).UpsertUserProgress(context.Background(), &models.UserProgress{UserID: "user", CompanionID: "companion"})
+		require.NoError(t, err)
+		assert.Equal(t, "update", mt.GetStartedEvent().CommandName)
+		assert.Equal(t, "update", mt.GetStartedEvent().CommandName)
+	})
+
+	mt.Run("does not overwrite the atomically maintained streak", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := NewAnalyticsRepository(nil, mt.DB).UpsertUserProgress(context.Background(), &models.UserProgress{
+			UserID: "user", CompanionID: "companion", CurrentStreak: 1, LongestStreak: 1, LastActivityDate: time.Now(),
+		})
+		require.NoError(t, err)
+
+		set := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		for _, field := range []string{"current_streak", "longest_streak", "last_activity_date"} {
+			_, err := set.LookupErr(field)
+			assert.Error(t, err, field)
+		}
+	})
+}
+
+// TestAtomicStreakIncrementConcurrentRequests runs against a real MongoDB when MONGODB_URI is set
+func TestAtomicStreakIncrementConcurrentRequests(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB not reachable: %v", err)
+	}
+
+	db := client.Database("lunaria_streak_test_" + primitive.NewObjectID().Hex())
+	defer db.Drop(context.Background())
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	_, err = db.Collection("user_progress").InsertOne(ctx, bson.M{
+		"user_id":            "user",
+		"companion_id":       "companion",
+		"current_streak":     4,
+		"longest_streak":     4,
+		"last_activity_date": today.Add(-9 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	repo := NewAnalyticsRepository(nil, db)
+	var wg sync.WaitGroup
+	var increments atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			updated, err := repo.AtomicStreakIncrement(ctx, "user", "companion", today.Add(10*time.Hour))
+			assert.NoError(t, err)
+			if updated {
+				increments.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	progress, err := repo.GetUserProgress(ctx, "user", "companion")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), increments.Load())
+	assert.Equal(t, 5, progress.CurrentStreak)
+	assert.Equal(t, 5, progress.LongestStreak)
+}
+
+func TestInsertHealthSnapshot(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("upserts on relationship and week", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		weekStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		err := NewAnalyticsRepository(nil, mt.DB).InsertHealthSnapshot(context.Background(), &models.RelationshipHealthSnapshot{
+			UserID: "user", CompanionID: "companion", HealthScore: 0.8, Stage: "friend", WeekStart: weekStart,
+		})
+		require.NoError(t, err)
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.True(t, update.Lookup("upsert").Boolean())
+		assert.Equal(t, weekStart, update.Lookup("q", "week_start").Time().UTC())
+		assert.Equal(t, "companion", update.Lookup("q", "companion_id").StringValue())
+		assert.Equal(t, 0.8, update.Lookup("u", "$setOnInsert", "health_score").Double())
+		_, err = update.LookupErr("u", "$set")
+		assert.Error(t, err, "existing snapshots must not be overwritten")
+	})
+}
+
+func TestGetHealthScoreHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("returns the latest weeks oldest first", func(mt *mtest.T) {
+		snapshot := func(weekStart time.Time, score float64) bson.D {
+			return bson.D{{Key: "user_id", Value: "user"}, {Key: "week_start", Value: weekStart}, {Key: "health_score", Value: score}}
+		}
+		week := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.weekly_health_snapshots", mtest.FirstBatch,
+			snapshot(week, 0.9),
+			snapshot(week.AddDate(0, 0, -7), 0.7),
+		))
+
+		history, err := NewAnalyticsRepository(nil, mt.DB).GetHealthScoreHistory(context.Background(), "user", "companion", 2)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, 0.7, history[0].HealthScore)
+		assert.Equal(t, 0.9, history[1].HealthScore)
+
+		cmd := mt.GetStartedEvent().Command
+		assert.Equal(t, int64(2), cmd.Lookup("limit").AsInt64())
+		assert.Equal(t, int32(-1), cmd.Lookup("sort", "week_start").Int32())
+	})
+}
+
+func TestComputeUserDailyStatistics(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("folds hourly groups into days", func(mt *mtest.T) {
+		group := func(day string, hour, sessions, messages int, duration time.Duration, engagement float64) bson.D {
+			return bson.D{
+				{Key: "_id", Value: bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "companion"}, {Key: "day", Value: day}, {Key: "hour", Value: hour}}},
+				{Key: "sessions", Value: sessions},
+				{Key: "messages", Value: messages},
+				{Key: "session_duration", Value: int64(duration)},
+				{Key: "engagement_score", Value: engagement},
+			}
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch,
+			group("2026-09-07", 9, 1, 4, 10*time.Minute, 0.4),
+			group("2026-09-07", 21, 3, 20, 50*time.Minute, 2.0),
+			group("2026-09-08", 9, 1, 6, 5*time.Minute, 0.5),
+		))
+
+		since := time.Date(2026, 9, 7, 15, 0, 0, 0, time.UTC)
+		days, err := NewAnalyticsRepository(nil, mt.DB).ComputeUserDailyStatistics(context.Background(), "user", "companion", since)
+		require.NoError(t, err)
+		require.Len(t, days, 2)
+
+		assert.Equal(t, time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC), days[0].StatDate)
+		assert.Equal(t, 4, days[0].TotalSessions)
+		assert.Equal(t, 24, days[0].TotalMessages)
+		assert.Equal(t, 15*time.Minute, days[0].AverageSessionLength)
+		assert.Equal(t, 6.0, days[0].AverageMessagesPerSession)
+		assert.InDelta(t, 0.6, days[0].EngagementScore, 1e-9)
+		assert.Equal(t, 1, days[0].SessionsByHour[9])
+		assert.Equal(t, 3, days[0].SessionsByHour[21])
+		assert.Equal(t, 1, days[1].TotalSessions)
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		assert.Equal(t, "user", match.Lookup("user_id").StringValue())
+		assert.Equal(t, time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC), match.Lookup("created_at", "$gte").Time().UTC(), "whole days are aggregated")
+	})
+}
+
+func TestWithTransaction(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("aborts the achievement insert when the progress update fails", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 2, Message: "progress update rejected"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		repo := NewAnalyticsRepository(nil, mt.DB)
+		err := repo.WithTransaction(context.Background(), func(ctx context.Context) error {
+			if err := repo.InsertUserAchievement(ctx, &models.UserAchievement{UserID: "user", AchievementID: "first_conversation"}); err != nil {
+				return err
+			}
+			return repo.UpsertUserProgress(ctx, &models.UserProgress{UserID: "user", TotalAchievements: 1})
+		})
+		require.ErrorContains(t, err, "progress update rejected")
+
+		insert := mt.GetStartedEvent()
+		require.Equal(t, "insert", insert.CommandName)
+		assert.True(t, insert.Command.Lookup("startTransaction").Boolean())
+
+		update := mt.GetStartedEvent()
+		require.Equal(t, "update", update.CommandName)
+		assert.Equal(t, insert.Command.Lookup("txnNumber"), update.Command.Lookup("txnNumber"), "both writes run in the same transaction")
+
+		assert.Equal(t, "abortTransaction", mt.GetStartedEvent().CommandName)
+		assert.Nil(t, mt.GetStartedEvent(), "the transaction must not be committed")
+	})
+
+	mt.Run("commits when fn succeeds", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		repo := NewAnalyticsRepository(nil, mt.DB)
+		err := repo.WithTransaction(context.Background(), func(ctx context.Context) error {
+			return repo.InsertUserAchievement(ctx, &models.UserAchievement{UserID: "user"})
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "insert", mt.GetStartedEvent().CommandName)
+		assert.Equal(t, "commitTransaction", mt.GetStartedEvent().CommandName)
+	})
+}
+
+func TestGetConsentAuditLog(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("returns the newest events first", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.consent_audit_log", mtest.FirstBatch,
+			bson.D{{Key: "user_id", Value: "user"}, {Key: "action", Value: models.ConsentAuditWrite}, {Key: "after", Value: bson.D{{Key: "analytics_consent", Value: false}}}},
+			bson.D{{Key: "user_id", Value: "user"}, {Key: "action", Value: models.ConsentAuditRead}, {Key: "requester", Value: "ProfileHandler"}},
+		))
+
+		events, err := NewAnalyticsRepository(nil, mt.DB).GetConsentAuditLog(context.Background(), "user", 20)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, false, events[0].After["analytics_consent"])
+		assert.Equal(t, "ProfileHandler", events[1].Requester)
+
+		find := mt.GetStartedEvent().Command
+		assert.Equal(t, "consent_audit_log", find.Lookup("find").StringValue())
+		assert.Equal(t, "user", find.Lookup("filter", "user_id").StringValue())
+		assert.Equal(t, int32(-1), find.Lookup("sort", "created_at").Int32())
+		assert.Equal(t, int64(20), find.Lookup("limit").Int64())
+	})
+}
+
+func TestGetUserDashboardDataAggregated(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("joins the dashboard in one aggregation", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch, bson.D{
+			{Key: "progress", Value: bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "companion"}, {Key: "current_level", Value: 3}}},
+			{Key: "recent_achievements", Value: bson.A{bson.D{{Key: "achievement_id", Value: "first_chat"}}}},
+			{Key: "relationship_analytics", Value: bson.A{}},
+			{Key: "engagement_trends", Value: bson.A{bson.D{
+				{Key: "_id", Value: "2024-06-01"},
+				{Key: "engagement_score", Value: 0.7},
+				{Key: "session_count", Value: int32(2)},
+				{Key: "message_count", Value: int64(10)},
+				{Key: "duration", Value: 1.5e9},
+			}}},
+		}))
+
+		dashboard, err := NewAnalyticsRepository(nil, mt.DB).GetUserDashboardDataAggregated(context.Background(), "user", "companion")
+		require.NoError(t, err)
+		assert.Equal(t, 3, dashboard.Progress.CurrentLevel)
+		require.Len(t, dashboard.RecentAchievements, 1)
+		assert.Equal(t, "first_chat", dashboard.RecentAchievements[0].AchievementID)
+		assert.Nil(t, dashboard.RelationshipAnalytics, "relationships without analytics are left unset")
+		require.Len(t, dashboard.EngagementTrends, 1)
+		assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), dashboard.EngagementTrends[0].Date)
+		assert.Equal(t, 10, dashboard.EngagementTrends[0].MessageCount)
+		assert.Equal(t, 1500*time.Millisecond, dashboard.EngagementTrends[0].Duration)
+
+		started := mt.GetStartedEvent()
+		require.NotNil(t, started)
+		assert.Equal(t, "user_progress", started.Command.Lookup("aggregate").StringValue())
+		stages, err := started.Command.Lookup("pipeline").Array().Values()
+		require.NoError(t, err)
+		var joined []string
+		for _, stage := range stages {
+			if lookup, ok := stage.Document().Lookup("$lookup").DocumentOK(); ok {
+				joined = append(joined, lookup.Lookup("from").StringValue())
+			}
+		}
+		assert.Equal(t, []string{"user_achievements", "relationship_analytics", "user_engagement_analytics"}, joined)
+		assert.Nil(t, mt.GetStartedEvent(), "no other round-trips")
+	})
+
+	mt.Run("not found without progress", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch))
+
+		_, err := NewAnalyticsRepository(nil, mt.DB).GetUserDashboardDataAggregated(context.Background(), "user", "companion")
+		var notFound *apperrors.NotFoundError
+		assert.ErrorAs(t, err, &notFound)
+	})
+}
+
+// TestDashboardPipelineUsesIndexes runs against a real MongoDB 5.0 or later when MONGODB_URI is set. MongoDB has no
+// dedicated explain stage for an indexed $lookup; instead, since 5.0, each $lookup stage reports the indexes its
+// joins used and how many collection scans they needed.
+func TestDashboardPipelineUsesIndexes(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB not reachable: %v", err)
+	}
+
+	db := client.Database("lunaria_dashboard_test_" + primitive.NewObjectID().Hex())
+	defer db.Drop(context.Background())
+	if major, _, err := mongodb.ServerVersion(ctx, db); err != nil || major < 5 {
+		t.Skipf("$lookup explain statistics need MongoDB 5.0 or later (major version %d, err %v)", major, err)
+	}
+
+	require.NoError(t, mongodb.RunMigrations(db))
+
+	// Other relationships, so an unindexed join would have to scan past them
+	for _, collection := range []string{"user_progress", "user_achievements", "relationship_analytics", "user_engagement_analytics"} {
+		var docs []any
+		for i := 0; i < 50; i++ {
+			docs = append(docs, bson.M{"user_id": primitive.NewObjectID().Hex(), "companion_id": "companion", "earned_at": time.Now(), "created_at": time.Now()})
+		}
+		docs = append(docs, bson.M{"user_id": "user", "companion_id": "companion", "earned_at": time.Now(), "created_at": time.Now()})
+		_, err := db.Collection(collection).InsertMany(ctx, docs)
+		require.NoError(t, err)
+	}
+
+	var explain bson.M
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: bson.D{
+			{Key: "aggregate", Value: "user_progress"},
+			{Key: "pipeline", Value: dashboardPipeline("user", "companion")},
+			{Key: "cursor", Value: bson.M{}},
+		}},
+		{Key: "verbosity", Value: "executionStats"},
+	}).Decode(&explain)
+	require.NoError(t, err)
+
+	stages, ok := explain["stages"].(bson.A)
+	require.True(t, ok, "explain output has pipeline stages")
+	indexesUsed := make(map[string][]string)
+	for _, stage := range stages {
+		stage := stage.(bson.M)
+		lookup, ok := stage["$lookup"].(bson.M)
+		if !ok {
+			continue
+		}
+		from := lookup["from"].(string)
+		assert.EqualValues(t, 0, stage["collectionScans"], "%s is joined without a collection scan", from)
+		for _, index := range stage["indexesUsed"].(bson.A) {
+			indexesUsed[from] = append(indexesUsed[from], index.(string))
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"user_achievements":         {"idx_user_achievements_user_companion_earned"},
+		"relationship_analytics":    {"idx_relationship_analytics_user_companion"},
+		"user_engagement_analytics": {"idx_analytics_user_companion_conversation_created"},
+	}, indexesUsed)
+}
@@ -3,29 +3,41 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type CompanionRepository struct {
-	postgresDB *sql.DB
-	mongoDB    *mongo.Database
+	postgresDB   *sql.DB
+	mongoDB      *mongo.Database
+	profileCache *cache.Cache[models.CompanionProfile]
 }
 
 func NewCompanionRepository(postgresDB *sql.DB, mongoDB *mongo.Database) *CompanionRepository {
 	return &CompanionRepository{
-		postgresDB: postgresDB,
-		mongoDB:    mongoDB,
+		postgresDB:   postgresDB,
+		mongoDB:      mongoDB,
+		profileCache: cache.New[models.CompanionProfile](),
 	}
 }
 
+// ProfileCache exposes the companion profile cache so external changes can invalidate it
+func (r *CompanionRepository) ProfileCache() cache.Invalidator {
+	return r.profileCache
+}
+
 func (r *CompanionRepository) Create(ctx context.Context, companion *models.Companion) (*models.Companion, error) {
 	query := `
 		INSERT INTO companions (id, user_id, name, gender, age, avatar_url, is_active, created_at, updated_at)
@@ -53,10 +65,10 @@ func (r *CompanionRepository) GetByID(ctx context.Context, id uuid.UUID, userID
 		&companion.Age, &companion.AvatarURL, &companion.IsActive,
 		&companion.CreatedAt, &companion.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("companion not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("companion not found", err)
 		}
-		return nil, fmt.Errorf("failed to get companion: %w", err)
+		return nil, apperrors.NewDatabaseError("failed to get companion", err)
 	}
 	return companion, nil
 }
@@ -119,10 +131,10 @@ func (r *CompanionRepository) Update(ctx context.Context, id uuid.UUID, userID u
 		&companion.Age, &companion.AvatarURL, &companion.IsActive,
 		&companion.CreatedAt, &companion.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("companion not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("companion not found", err)
 		}
-		return nil, fmt.Errorf("failed to update companion: %w", err)
+		return nil, apperrors.NewDatabaseError("failed to update companion", err)
 	}
 	return companion, nil
 }
@@ -138,7 +150,7 @@ func (r *CompanionRepository) Delete(ctx context.Context, id uuid.UUID, userID u
 		return fmt.Errorf("failed to check delete result: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("companion not found")
+		return apperrors.NewNotFoundError("companion not found", nil)
 	}
 	return nil
 }
@@ -156,15 +168,20 @@ func (r *CompanionRepository) CreateProfile(ctx context.Context, profile *models
 }
 
 func (r *CompanionRepository) GetProfile(ctx context.Context, companionID string) (*models.CompanionProfile, error) {
+	if profile, ok := r.profileCache.Get(companionID); ok {
+		return &profile, nil
+	}
+
 	collection := r.mongoDB.Collection("companion_profiles")
 	var profile models.CompanionProfile
 	err := collection.FindOne(ctx, bson.M{"companion_id": companionID}).Decode(&profile)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("companion profile not found")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, apperrors.NewNotFoundError("companion profile not found", err)
 		}
-		return nil, fmt.Errorf("failed to get companion profile: %w", err)
+		return nil, apperrors.NewDatabaseError("failed to get companion profile", err)
 	}
+	r.profileCache.Set(companionID, profile, profile.ID.Hex())
 	return &profile, nil
 }
 
@@ -177,5 +194,44 @@ func (r *CompanionRepository) UpdateProfile(ctx context.Context, companionID str
 	if err != nil {
 		return nil, fmt.Errorf("failed to update companion profile: %w", err)
 	}
+	r.profileCache.Invalidate(companionID)
 	return r.GetProfile(ctx, companionID)
 }
+
+// GetStyleGuide returns the companion's conversation style guide, or nil if none has been written yet
+func (r *CompanionRepository) GetStyleGuide(ctx context.Context, companionID string) (*models.ConversationStyleGuide, error) {
+	var guide models.ConversationStyleGuide
+	err := r.mongoDB.Collection("conversation_style_guides").FindOne(ctx, bson.M{"companion_id": companionID}).Decode(&guide)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, apperrors.NewDatabaseError("failed to get conversation style guide", err)
+	}
+	return &guide, nil
+}
+
+// UpdateStyleGuide replaces the companion's conversation style guide, creating it if needed
+func (r *CompanionRepository) UpdateStyleGuide(ctx context.Context, guide *models.ConversationStyleGuide) error {
+	guide.UpdatedAt = time.Now()
+	filter := bson.M{"companion_id": guide.CompanionID}
+	update := bson.M{
+		"$set": bson.M{
+			"signature_expressions": guide.SignatureExpressions,
+			"avoided_phrases":       guide.AvoidedPhrases,
+			"favourite_topics":      guide.FavouriteTopics,
+			"lexical_level":         guide.LexicalLevel,
+			"proposed_expressions":  guide.ProposedExpressions,
+			"updated_at":            guide.UpdatedAt,
+		},
+	}
+
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.mongoDB.Collection("conversation_style_guides").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update conversation style guide: %w", err)
+	}
+	return nil
+}
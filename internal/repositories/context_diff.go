@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"reflect"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// appendOnlyContextFields are the context arrays that only grow at the end, dropping their oldest entries once full
+var appendOnlyContextFields = map[string]bool{
+	"emotional_history": true,
+	"topic_history":     true,
+}
+
+// ContextDiff builds the update that turns the stored context old into updated, writing only the top-level fields
+// that changed. Entries appended to the emotional and topic histories are pushed, keeping the array at its new
+// length, and new active memories are added to the set; other changes are set. A nil old context sets every field.
+// The update is empty when nothing changed.
+func ContextDiff(old, updated *models.ConversationContext) (bson.M, error) {
+	if old == nil {
+		return bson.M{"$set": updated}, nil
+	}
+
+	before, err := contextDocument(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := contextDocument(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	set, push, addToSet := bson.M{}, bson.M{}, bson.M{}
+	for key, value := range after {
+		previous := before[key]
+		if key == "_id" || reflect.DeepEqual(previous, value) {
+			continue
+		}
+
+		previousItems, wasArray := previous.(bson.A)
+		items, isArray := value.(bson.A)
+		switch {
+		case wasArray && isArray && appendOnlyContextFields[key]:
+			if appended, ok := appendedItems(previousItems, items); ok {
+				push[key] = bson.M{"$each": appended, "$slice": -len(items)}
+				continue
+			}
+		case wasArray && isArray && key == "active_memories":
+			if len(items) > len(previousItems) && reflect.DeepEqual(previousItems, items[:len(previousItems)]) {
+				addToSet[key] = bson.M{"$each": items[len(previousItems):]}
+				continue
+			}
+		}
+		set[key] = value
+	}
+
+	update := bson.M{}
+	for operator, fields := range map[string]bson.M{"$set": set, "$push": push, "$addToSet": addToSet} {
+		if len(fields) > 0 {
+			update[operator] = fields
+		}
+	}
+	return update, nil
+}
+
+// appendedItems returns the entries appended to previous to give items, which may have dropped entries from the
+// start of previous but keeps at least one, or false when items is not such a continuation of previous
+func appendedItems(previous, items bson.A) (bson.A, bool) {
+	for dropped := 0; dropped == 0 || dropped < len(previous); dropped++ {
+		kept := len(previous) - dropped
+		if kept >= len(items) {
+			continue
+		}
+		if reflect.DeepEqual(previous[dropped:], items[:kept]) {
+			return items[kept:], true
+		}
+	}
+	return nil, false
+}
+
+// storedContext copies a context as it is encoded in the database
+func storedContext(context *models.ConversationContext) (*models.ConversationContext, error) {
+	data, err := bson.Marshal(context)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.ConversationContext
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func diffContext() *models.ConversationContext {
+	return &models.ConversationContext{
+		ID:                primitive.NewObjectID(),
+		ConversationID:    primitive.NewObjectID(),
+		RelationshipStage: "friends",
+		TrustLevel:        0.4,
+		CurrentTopic:      "work",
+		TopicHistory:      []string{"weather", "music"},
+		EmotionalHistory:  []models.EmotionalSnapshot{{}},
+		ActiveMemories:    []models.AIEnhancedMemoryEntry{{Content: "has a dog named Max"}},
+		UpdatedAt:         time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestContextDiffOnlyWritesChangedFields(t *testing.T) {
+	old := diffContext()
+	updated, err := storedContext(old)
+	require.NoError(t, err)
+
+	updated.TrustLevel = 0.5
+	updated.CurrentTopic = "travel"
+	updated.TopicHistory = append(updated.TopicHistory[1:], "work")
+	updated.ActiveMemories = append(updated.ActiveMemories, models.AIEnhancedMemoryEntry{Content: "works night shifts"})
+
+	update, err := ContextDiff(old, updated)
+	require.NoError(t, err)
+	assert.Len(t, update, 3)
+
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"trust_level": 0.5, "current_topic": "travel"},
+		"$push": bson.M{
+			"topic_history": bson.M{"$each": bson.A{"work"}, "$slice": -2},
+		},
+	}, bson.M{"$set": update["$set"], "$push": update["$push"]})
+
+	addToSet := update["$addToSet"].(bson.M)["active_memories"].(bson.M)["$each"].(bson.A)
+	require.Len(t, addToSet, 1)
+	assert.Equal(t, "works night shifts", addToSet[0].(bson.M)["content"])
+}
+
+func TestContextDiffSetsRewrittenArrays(t *testing.T) {
+	old := diffContext()
+	updated, err := storedContext(old)
+	require.NoError(t, err)
+
+	updated.TopicHistory = []string{"family"}
+	updated.ActiveMemories[0].Importance = 0.9
+
+	update, err := ContextDiff(old, updated)
+	require.NoError(t, err)
+
+	set, ok := update["$set"].(bson.M)
+	require.True(t, ok)
+	assert.Len(t, set, 2)
+	assert.Equal(t, bson.A{"family"}, set["topic_history"])
+	assert.Contains(t, set, "active_memories")
+	assert.NotContains(t, update, "$push")
+	assert.NotContains(t, update, "$addToSet")
+}
+
+func TestContextDiffUnchangedAndNew(t *testing.T) {
+	context := diffContext()
+
+	update, err := ContextDiff(context, context)
+	require.NoError(t, err)
+	assert.Empty(t, update)
+
+	update, err = ContextDiff(nil, context)
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$set": context}, update)
+}
@@ -1,10 +1,24 @@
 package repositories
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/crypto"
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,13 +27,43 @@ import (
 )
 
 type ConversationRepository struct {
-	db *mongo.Database
+	db     *mongo.Database
+	opts   ConversationQueryOptions
+	cipher *crypto.MessageCipher
 }
 
+// mongoWriteAttempts is the number of tries given to each idempotent MongoDB write before it fails. Inserts and
+// increments are not retried this way: after a lost acknowledgement a retry would fail on the already stored _id or
+// apply twice, so they rely on the driver's retryable writes, which the server deduplicates.
+const mongoWriteAttempts = 3
+
 func NewConversationRepository(db *mongo.Database) *ConversationRepository {
 	return &ConversationRepository{db: db}
 }
 
+// ConversationQueryOptions adjusts which conversations the repository's queries return
+type ConversationQueryOptions struct {
+	// IncludeDeleted also returns soft-deleted conversations, for admin tooling
+	IncludeDeleted bool
+}
+
+// WithQueryOptions returns a copy of the repository whose queries use opts
+func (r *ConversationRepository) WithQueryOptions(opts ConversationQueryOptions) *ConversationRepository {
+	return &ConversationRepository{db: r.db, opts: opts, cipher: r.cipher}
+}
+
+// notDeleted adds the soft delete filter to a conversations filter unless deleted conversations are included
+func (r *ConversationRepository) notDeleted(filter bson.M) bson.M {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if !r.opts.IncludeDeleted {
+		// Matches conversations whose deleted_at is null or missing
+		filter["deleted_at"] = nil
+	}
+	return filter
+}
+
 func (r *ConversationRepository) CreateConversation(ctx context.Context, conv *models.Conversation) (*models.Conversation, error) {
 	conv.ID = primitive.NewObjectID()
 	conv.CreatedAt = time.Now()
@@ -35,15 +79,15 @@ func (r *ConversationRepository) CreateConversation(ctx context.Context, conv *m
 
 func (r *ConversationRepository) GetConversationByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
 	var conv models.Conversation
-	err := r.db.Collection("conversations").FindOne(ctx, bson.M{"_id": id}).Decode(&conv)
+	err := r.db.Collection("conversations").FindOne(ctx, r.notDeleted(bson.M{"_id": id})).Decode(&conv)
 	if err != nil {
-		return nil, fmt.Errorf("conversation not found: %w", err)
+		return nil, findOneError(err, "conversation")
 	}
 	return &conv, nil
 }
 
 func (r *ConversationRepository) ListUserConversations(ctx context.Context, userID string, archived bool, limit, offset int) ([]*models.Conversation, error) {
-	filter := bson.M{"user_id": userID, "archived": archived}
+	filter := r.notDeleted(bson.M{"user_id": userID, "archived": archived})
 	opts := options.Find().SetSort(bson.M{"last_activity": -1}).SetLimit(int64(limit)).SetSkip(int64(offset))
 	cur, err := r.db.Collection("conversations").Find(ctx, filter, opts)
 	if err != nil {
@@ -63,10 +107,10 @@ func (r *ConversationRepository) ListUserConversations(ctx context.Context, user
 
 // ListConversations lists conversations between a user and companion
 func (r *ConversationRepository) ListConversations(ctx context.Context, userID, companionID string, limit int, cursor any) ([]*models.Conversation, error) {
-	filter := bson.M{
+	filter := r.notDeleted(bson.M{
 		"user_id":      userID,
 		"companion_id": companionID,
-	}
+	})
 
 	// Add cursor-based pagination if provided
 	if cursor != nil {
@@ -103,6 +147,9 @@ func (r *ConversationRepository) ListConversations(ctx context.Context, userID,
 
 // ListConversationsWithFilter lists all conversations with optional filtering
 func (r *ConversationRepository) ListConversationsWithFilter(ctx context.Context, filter bson.M, limit, offset int) ([]*models.Conversation, error) {
+	// Copy the caller's filter before adding the soft delete condition
+	filter = r.notDeleted(maps.Clone(filter))
+
 	opts := options.Find().
 		SetSort(bson.M{"last_activity": -1}).
 		SetLimit(int64(limit)).
@@ -131,31 +178,106 @@ func (r *ConversationRepository) ListConversationsWithFilter(ctx context.Context
 }
 
 func (r *ConversationRepository) ArchiveConversation(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"archived": true, "updated_at": time.Now()}})
-	return err
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"archived": true, "updated_at": time.Now()}})
+		return err
+	})
 }
 
 func (r *ConversationRepository) ReactivateConversation(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"archived": false, "updated_at": time.Now()}})
-	return err
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"archived": false, "updated_at": time.Now()}})
+		return err
+	})
 }
 
-func (r *ConversationRepository) CreateMessage(ctx context.Context, msg *models.Message) (*models.Message, error) {
+// SoftDeleteConversation marks a conversation as deleted. It disappears from queries right away and its
+// data is purged by PurgeDeletedConversations once the grace period has passed.
+func (r *ConversationRepository) SoftDeleteConversation(ctx context.Context, id primitive.ObjectID) error {
+	now := time.Now()
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}})
+		return err
+	})
+}
+
+// SoftDeleteUserConversations marks every conversation of a user as deleted and returns how many were marked
+func (r *ConversationRepository) SoftDeleteUserConversations(ctx context.Context, userID string) (int64, error) {
+	now := time.Now()
+	var result *mongo.UpdateResult
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		var err error
+		result, err = r.db.Collection("conversations").UpdateMany(ctx, bson.M{"user_id": userID, "deleted_at": nil}, bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to soft delete user conversations: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// PurgeDeletedConversations permanently removes conversations soft-deleted before deletedBefore, along with
+// their messages, contexts and memories, and returns how many conversations were removed
+func (r *ConversationRepository) PurgeDeletedConversations(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	conversationIDs, err := r.findConversationIDs(ctx, bson.M{"deleted_at": bson.M{"$lte": deletedBefore}})
+	if err != nil {
+		return 0, err
+	}
+	if len(conversationIDs) == 0 {
+		return 0, nil
+	}
+
+	if err := r.deleteConversations(ctx, conversationIDs); err != nil {
+		return 0, err
+	}
+	return int64(len(conversationIDs)), nil
+}
+
+// CreateMessage stores a message. If the message carries a client idempotency key that was already
+// used in the conversation, the previously stored message is returned and deduplicated is true.
+func (r *ConversationRepository) CreateMessage(ctx context.Context, msg *models.Message) (stored *models.Message, deduplicated bool, err error) {
 	msg.ID = primitive.NewObjectID()
 	msg.CreatedAt = time.Now()
 	msg.UpdatedAt = time.Now()
-	_, err := r.db.Collection("messages").InsertOne(ctx, msg)
+	doc, err := r.sealMessage(msg)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = r.db.Collection("messages").InsertOne(ctx, doc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create message: %w", err)
+		if msg.ClientIdempotencyKey != "" && isDuplicateKeyError(err) {
+			existing, err := r.getMessageByIdempotencyKey(ctx, msg.ConversationID, msg.ClientIdempotencyKey)
+			if err != nil {
+				return nil, false, err
+			}
+			return existing, true, nil
+		}
+		return nil, false, fmt.Errorf("failed to create message: %w", err)
+	}
+	return msg, false, nil
+}
+
+// getMessageByIdempotencyKey loads the message a client created with the given idempotency key
+func (r *ConversationRepository) getMessageByIdempotencyKey(ctx context.Context, conversationID primitive.ObjectID, key string) (*models.Message, error) {
+	var msg models.Message
+	filter := bson.M{"conversation_id": conversationID, "client_idempotency_key": key}
+	if err := r.db.Collection("messages").FindOne(ctx, filter).Decode(&msg); err != nil {
+		return nil, findOneError(err, "message")
+	}
+	if err := r.openMessage(&msg); err != nil {
+		return nil, err
 	}
-	return msg, nil
+	return &msg, nil
 }
 
 func (r *ConversationRepository) GetMessageByID(ctx context.Context, id primitive.ObjectID) (*models.Message, error) {
 	var msg models.Message
 	err := r.db.Collection("messages").FindOne(ctx, bson.M{"_id": id}).Decode(&msg)
 	if err != nil {
-		return nil, fmt.Errorf("message not found: %w", err)
+		return nil, findOneError(err, "message")
+	}
+	if err := r.openMessage(&msg); err != nil {
+		return nil, err
 	}
 	return &msg, nil
 }
@@ -178,6 +300,9 @@ func (r *ConversationRepository) ListMessages(ctx context.Context, conversationI
 		if err := cur.Decode(&msg); err != nil {
 			return nil, nil, false, err
 		}
+		if err := r.openMessage(&msg); err != nil {
+			return nil, nil, false, err
+		}
 		lastID = &msg.ID
 		messages = append(messages, &msg)
 	}
@@ -185,67 +310,1117 @@ func (r *ConversationRepository) ListMessages(ctx context.Context, conversationI
 	return messages, lastID, hasMore, nil
 }
 
+// GetLastMessageBefore returns the newest message of a conversation created before the given message
+func (r *ConversationRepository) GetLastMessageBefore(ctx context.Context, conversationID, before primitive.ObjectID) (*models.Message, error) {
+	filter := bson.M{"conversation_id": conversationID, "_id": bson.M{"$lt": before}}
+	opts := options.FindOne().SetSort(bson.M{"_id": -1})
+
+	var msg models.Message
+	if err := r.db.Collection("messages").FindOne(ctx, filter, opts).Decode(&msg); err != nil {
+		return nil, findOneError(err, "message")
+	}
+	if err := r.openMessage(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// sessionReplayMatchWindow is the furthest a sentiment point may be from a message to be attached to it
+const sessionReplayMatchWindow = 5 * time.Minute
+
+// ExportSessionReplay streams a conversation's messages as JSON lines, each paired with its nearest sentiment point
+func (r *ConversationRepository) ExportSessionReplay(ctx context.Context, conversationID primitive.ObjectID) (io.ReadCloser, error) {
+	var engagement models.UserEngagementAnalytics
+	err := r.db.Collection("user_engagement_analytics").FindOne(ctx, bson.M{"conversation_id": conversationID}).Decode(&engagement)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to get sentiment trend: %w", err)
+	}
+	trend := engagement.SentimentTrend
+	sort.Slice(trend, func(i, j int) bool { return trend[i].Timestamp.Before(trend[j].Timestamp) })
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := r.db.Collection("messages").Find(ctx, bson.M{"conversation_id": conversationID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer cur.Close(context.Background())
+
+		encoder := json.NewEncoder(pw)
+		for cur.Next(ctx) {
+			var msg models.Message
+			if err := cur.Decode(&msg); err != nil {
+				pw.CloseWithError(fmt.Errorf("failed to decode message: %w", err))
+				return
+			}
+			if err := r.openMessage(&msg); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			entry := models.SessionReplayEntry{
+				Message:    &msg,
+				SenderType: msg.SenderType,
+				Sentiment:  nearestSentimentPoint(trend, msg.CreatedAt),
+			}
+			if err := encoder.Encode(entry); err != nil {
+				// The reader was closed; stop streaming
+				return
+			}
+		}
+
+		if err := cur.Err(); err != nil {
+			pw.CloseWithError(fmt.Errorf("failed to stream messages: %w", err))
+			return
+		}
+		pw.Close()
+	}()
+
+	return pr, nil
+}
+
+// nearestSentimentPoint returns the sentiment point closest to at within the match window, or nil
+func nearestSentimentPoint(trend []models.SentimentPoint, at time.Time) *models.SentimentPoint {
+	if len(trend) == 0 {
+		return nil
+	}
+
+	i := sort.Search(len(trend), func(i int) bool { return !trend[i].Timestamp.Before(at) })
+	best := -1
+	for _, candidate := range []int{i - 1, i} {
+		if candidate < 0 || candidate >= len(trend) {
+			continue
+		}
+		if best == -1 || absDuration(trend[candidate].Timestamp.Sub(at)) < absDuration(trend[best].Timestamp.Sub(at)) {
+			best = candidate
+		}
+	}
+
+	if absDuration(trend[best].Timestamp.Sub(at)) > sessionReplayMatchWindow {
+		return nil
+	}
+	point := trend[best]
+	return &point
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
 func (r *ConversationRepository) UpdateMessage(ctx context.Context, msg *models.Message) error {
 	collection := r.db.Collection("messages")
 	filter := bson.M{"_id": msg.ID}
 	update := bson.M{"$set": bson.M{"read": msg.Read, "updated_at": msg.UpdatedAt}}
-	_, err := collection.UpdateOne(ctx, filter, update)
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.UpdateOne(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+
+	return nil
+}
+
+// MarkMessagesRead marks the companion's messages among messageIDs as read in a single update and returns how many
+// changed. Messages outside the conversation, sent by the user or already read are not counted. The conversation must
+// belong to userID.
+func (r *ConversationRepository) MarkMessagesRead(ctx context.Context, conversationID primitive.ObjectID, userID string, messageIDs []primitive.ObjectID) (int, error) {
+	owned, err := r.db.Collection("conversations").CountDocuments(ctx, bson.M{"_id": conversationID, "user_id": userID}, options.Count().SetLimit(1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to check conversation owner: %w", err)
+	}
+	if owned == 0 {
+		return 0, apperrors.NewNotFoundError("conversation not found", nil)
+	}
+
+	filter := bson.M{
+		"_id":             bson.M{"$in": messageIDs},
+		"conversation_id": conversationID,
+		"sender_type":     sendertype.Companion,
+		// Already-read messages are skipped, or updated_at would count them as modified
+		"read": false,
+	}
+	update := bson.M{"$set": bson.M{"read": true, "updated_at": time.Now()}}
+
+	var result *mongo.UpdateResult
+	err = mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		var err error
+		result, err = r.db.Collection("messages").UpdateMany(ctx, filter, update)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark messages read: %w", err)
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// AddReaction records a user's emoji reaction to a message. Each user has one reaction per message, so a
+// different emoji replaces the previous one, while adding the same emoji again changes nothing. The
+// message's reaction counts and the companion's weekly reaction analytics are updated to match.
+func (r *ConversationRepository) AddReaction(ctx context.Context, messageID primitive.ObjectID, userID, emoji string) error {
+	msg, err := r.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	conv, err := r.GetConversationByID(ctx, msg.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	// Matching only a different emoji makes a repeated reaction fall through to the insert, which the
+	// unique message/user index rejects
+	filter := bson.M{"message_id": messageID, "user_id": userID, "emoji": bson.M{"$ne": emoji}}
+	update := bson.M{
+		"$set": bson.M{"emoji": emoji, "created_at": now},
+		"$setOnInsert": bson.M{
+			"conversation_id": conv.ID,
+			"companion_id":    conv.CompanionID,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+
+	var previous *models.MessageReaction
+	err = mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		previous = &models.MessageReaction{}
+		err := r.db.Collection("message_reactions").FindOneAndUpdate(ctx, filter, update, opts).Decode(previous)
+		if err == mongo.ErrNoDocuments {
+			previous = nil
+			return nil
+		}
+		return err
+	})
+	if isDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save reaction: %w", err)
+	}
+
+	if previous != nil {
+		if err := r.adjustReactionCounts(ctx, messageID, conv.CompanionID, previous.Emoji, previous.CreatedAt, -1); err != nil {
+			return err
+		}
+	}
+	return r.adjustReactionCounts(ctx, messageID, conv.CompanionID, emoji, now, 1)
+}
+
+// RemoveReaction removes a user's emoji reaction from a message. Removing a reaction the user has not
+// made is not an error.
+func (r *ConversationRepository) RemoveReaction(ctx context.Context, messageID primitive.ObjectID, userID, emoji string) error {
+	filter := bson.M{"message_id": messageID, "user_id": userID, "emoji": emoji}
+
+	var removed *models.MessageReaction
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		removed = &models.MessageReaction{}
+		err := r.db.Collection("message_reactions").FindOneAndDelete(ctx, filter).Decode(removed)
+		if err == mongo.ErrNoDocuments {
+			removed = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	if removed == nil {
+		return nil
+	}
+
+	return r.adjustReactionCounts(ctx, messageID, removed.CompanionID, emoji, removed.CreatedAt, -1)
+}
+
+// adjustReactionCounts adds delta to the emoji's count on the message and in the companion's analytics for the
+// week the reaction was made. Counts that drop to zero are removed from the message. The $inc writes are not
+// retried here: after a lost acknowledgement a retry would count the reaction twice, so they rely on the driver's
+// retryable writes, which the server deduplicates.
+func (r *ConversationRepository) adjustReactionCounts(ctx context.Context, messageID primitive.ObjectID, companionID, emoji string, reactedAt time.Time, delta int) error {
+	field := "reactions." + emoji
+	_, err := r.db.Collection("messages").UpdateOne(ctx, bson.M{"_id": messageID}, bson.M{"$inc": bson.M{field: delta}})
+	if err != nil {
+		return fmt.Errorf("failed to update message reactions: %w", err)
+	}
+
+	if delta < 0 {
+		err = mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+			_, err := r.db.Collection("messages").UpdateOne(ctx, bson.M{"_id": messageID, field: bson.M{"$lte": 0}}, bson.M{"$unset": bson.M{field: ""}})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update message reactions: %w", err)
+		}
+	}
+
+	analyticsFilter := bson.M{"companion_id": companionID, "week_start": reactionWeekStart(reactedAt), "emoji": emoji}
+	analyticsUpdate := bson.M{"$inc": bson.M{"count": delta}, "$set": bson.M{"updated_at": time.Now()}}
+	_, err = r.db.Collection("reaction_analytics").UpdateOne(ctx, analyticsFilter, analyticsUpdate, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to update reaction analytics: %w", err)
+	}
+
+	return nil
+}
+
+// GetTopReactions returns the companion's most used reactions in the week containing weekOf, most used first
+func (r *ConversationRepository) GetTopReactions(ctx context.Context, companionID string, weekOf time.Time, limit int) ([]models.ReactionSummary, error) {
+	filter := bson.M{"companion_id": companionID, "week_start": reactionWeekStart(weekOf), "count": bson.M{"$gt": 0}}
+	opts := options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "emoji", Value: 1}}).SetLimit(int64(limit))
+
+	cur, err := r.db.Collection("reaction_analytics").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top reactions: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	reactions := []models.ReactionSummary{}
+	if err := cur.All(ctx, &reactions); err != nil {
+		return nil, fmt.Errorf("failed to decode top reactions: %w", err)
+	}
+
+	return reactions, nil
+}
+
+// reactionWeekStart returns the Sunday midnight UTC starting the week that contains t
+func reactionWeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, time.UTC)
+}
+
+func (r *ConversationRepository) CreateMediaMetadata(ctx context.Context, media *models.MediaMetadata) (*models.MediaMetadata, error) {
+	media.ID = primitive.NewObjectID()
+	media.CreatedAt = time.Now()
+	media.UpdatedAt = time.Now()
+	_, err := r.db.Collection("media_metadata").InsertOne(ctx, media)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media metadata: %w", err)
+	}
+	return media, nil
+}
+
+func (r *ConversationRepository) GetMediaMetadataByID(ctx context.Context, id primitive.ObjectID) (*models.MediaMetadata, error) {
+	var media models.MediaMetadata
+	err := r.db.Collection("media_metadata").FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+	if err != nil {
+		return nil, findOneError(err, "media metadata")
+	}
+	return &media, nil
+}
+
+// SaveConversationContext saves or updates conversation context and appends a change event
+func (r *ConversationRepository) SaveConversationContext(ctx context.Context, context *models.ConversationContext, actor string) error {
+	return r.saveConversationContext(ctx, context, actor, "", nil)
+}
+
+// saveConversationContext upserts the context and logs the change. A context read from the database only has the
+// fields changed since written. The event type is derived from the change unless eventType is set. A non-nil guard
+// is added to the filter and the context is then only updated, never inserted; when the guard does not match, a
+// *apperrors.ConflictError is returned.
+func (r *ConversationRepository) saveConversationContext(ctx context.Context, context *models.ConversationContext, actor, eventType string, guard bson.M) error {
+	collection := r.db.Collection("conversation_contexts")
+
+	update, err := ContextDiff(context.Stored(), context)
+	if err != nil {
+		return fmt.Errorf("failed to diff conversation context: %w", err)
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	// Use upsert to create or update, keeping the previous document for the event log
+	filter := bson.M{"conversation_id": context.ConversationID}
+	for key, value := range guard {
+		filter[key] = value
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(guard == nil).SetReturnDocument(options.Before)
+
+	var before bson.M
+	err = mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		before = nil
+		err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+		if err == mongo.ErrNoDocuments && guard == nil {
+			return nil
+		}
+		return err
+	})
+	if err == mongo.ErrNoDocuments {
+		return apperrors.NewConflictError("conversation context changed since it was read", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save conversation context: %w", err)
+	}
+
+	stored, err := storedContext(context)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation context: %w", err)
+	}
+	context.SetStored(stored)
+
+	after, err := contextDocument(context)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation context: %w", err)
+	}
+
+	event := newContextEvent(context.ConversationID, before, after, actor)
+	if eventType != "" {
+		event.EventType = eventType
+	}
+	_, err = r.db.Collection("conversation_context_events").InsertOne(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to record conversation context event: %w", err)
+	}
+
+	return nil
+}
+
+// ResetConversationContext returns the conversation's context to the relationship state of a new conversation,
+// clearing its active memories unless preserveMemories is set, and records a context_reset system message. The message
+// history is kept. Only a context that was never reset, or last reset before resetBefore, is reset; otherwise a
+// *apperrors.ConflictError is returned and nothing is written.
+func (r *ConversationRepository) ResetConversationContext(ctx context.Context, conversationID primitive.ObjectID, preserveMemories bool, resetBefore time.Time) error {
+	context, err := r.GetConversationContext(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	context.RelationshipStage = models.InitialRelationshipStage
+	context.TrustLevel = models.InitialTrustLevel
+	context.IntimacyLevel = models.InitialIntimacyLevel
+	if !preserveMemories {
+		context.ActiveMemories = []models.AIEnhancedMemoryEntry{}
+	}
+	context.LastResetAt = &now
+	context.UpdatedAt = now
+
+	// Checked in the write so that concurrent resets cannot both pass
+	notResetSince := bson.M{"$or": bson.A{bson.M{"last_reset_at": nil}, bson.M{"last_reset_at": bson.M{"$lt": resetBefore}}}}
+	if err := r.saveConversationContext(ctx, context, models.ContextActorUser, models.ContextEventReset, notResetSince); err != nil {
+		return err
+	}
+
+	details := "Conversation context reset; memories cleared"
+	if preserveMemories {
+		details = "Conversation context reset; memories kept"
+	}
+	_, _, err = r.CreateMessage(ctx, &models.Message{
+		ConversationID: conversationID,
+		SenderType:     sendertype.System,
+		Type:           messagetype.System,
+		SystemEvent:    &models.SystemEvent{EventType: models.SystemEventContextReset, Details: details},
+	})
+	return err
+}
+
+// GetConversationContextHistory returns the most recent context events for a conversation, newest first
+func (r *ConversationRepository) GetConversationContextHistory(ctx context.Context, conversationID primitive.ObjectID, limit int) ([]models.ContextEvent, error) {
+	collection := r.db.Collection("conversation_context_events")
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"conversation_id": conversationID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation context history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.ContextEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation context history: %w", err)
+	}
+
+	return events, nil
+}
+
+// contextDocument encodes a conversation context the same way it is stored
+func contextDocument(context *models.ConversationContext) (bson.M, error) {
+	data, err := bson.Marshal(context)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// newContextEvent builds an event holding only the top-level fields that changed
+func newContextEvent(conversationID primitive.ObjectID, before, after bson.M, actor string) *models.ContextEvent {
+	event := &models.ContextEvent{
+		ConversationID: conversationID,
+		EventType:      models.ContextEventUpdated,
+		Before:         map[string]any{},
+		After:          map[string]any{},
+		Actor:          actor,
+		Timestamp:      time.Now(),
+	}
+
+	if before == nil {
+		event.EventType = models.ContextEventCreated
+	}
+
+	for key, value := range after {
+		if key == "_id" || key == "updated_at" {
+			continue
+		}
+		previous, existed := before[key]
+		if existed && reflect.DeepEqual(previous, value) {
+			continue
+		}
+		if existed {
+			event.Before[key] = previous
+		}
+		event.After[key] = value
+	}
+
+	if before != nil {
+		if _, changed := event.After["relationship_stage"]; changed {
+			event.EventType = models.ContextEventStageAdvanced
+		} else if _, changed := event.After["current_topic"]; changed {
+			event.EventType = models.ContextEventTopicChanged
+		}
+	}
+
+	return event
+}
+
+// SaveResponseQuality stores a response quality analysis
+func (r *ConversationRepository) SaveResponseQuality(ctx context.Context, quality *models.ResponseQuality) error {
+	collection := r.db.Collection("response_quality")
+
+	_, err := collection.InsertOne(ctx, quality)
+	if err != nil {
+		return fmt.Errorf("failed to save response quality: %w", err)
+	}
+
+	return nil
+}
+
+// GetResponseQualityByMessage returns the stored response quality analysis of a message
+func (r *ConversationRepository) GetResponseQualityByMessage(ctx context.Context, messageID primitive.ObjectID) (*models.ResponseQuality, error) {
+	var quality models.ResponseQuality
+	err := r.db.Collection("response_quality").FindOne(ctx, bson.M{"message_id": messageID}).Decode(&quality)
+	if err != nil {
+		return nil, findOneError(err, "response quality")
+	}
+	return &quality, nil
+}
+
+// ReplaceResponseQuality stores a response quality analysis in place of the message's existing one, inserting it if
+// the message has none
+func (r *ConversationRepository) ReplaceResponseQuality(ctx context.Context, quality *models.ResponseQuality) error {
+	collection := r.db.Collection("response_quality")
+
+	opts := options.Replace().SetUpsert(true)
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := collection.ReplaceOne(ctx, bson.M{"message_id": quality.MessageID}, quality, opts)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace response quality: %w", err)
+	}
+
+	return nil
+}
+
+// ListCompanionResponseQuality returns up to limit of the most recent response quality analyses for a companion's
+// conversations created since the given time, oldest first
+func (r *ConversationRepository) ListCompanionResponseQuality(ctx context.Context, companionID string, since time.Time, limit int) ([]models.ResponseQuality, error) {
+	conversationIDs, err := r.findConversationIDs(ctx, bson.M{"companion_id": companionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"conversation_id": bson.M{"$in": conversationIDs},
+		"created_at":      bson.M{"$gte": since},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.db.Collection("response_quality").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find response quality: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var qualities []models.ResponseQuality
+	if err := cursor.All(ctx, &qualities); err != nil {
+		return nil, fmt.Errorf("failed to decode response quality: %w", err)
+	}
+	slices.Reverse(qualities)
+
+	return qualities, nil
+}
+
+// GetCompanionHistoryStats summarises the user's conversations with a companion: when the first one started, the
+// message count of the longest, the hour of day (UTC) the user writes most and the user's most frequent emotion
+func (r *ConversationRepository) GetCompanionHistoryStats(ctx context.Context, userID, companionID string) (*models.CompanionHistoryStats, error) {
+	filter := r.notDeleted(bson.M{"user_id": userID, "companion_id": companionID})
+	cursor, err := r.db.Collection("conversations").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find conversations: %w", err)
+	}
+	var conversations []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		CreatedAt time.Time          `bson:"created_at"`
+	}
+	if err := cursor.All(ctx, &conversations); err != nil {
+		return nil, fmt.Errorf("failed to decode conversations: %w", err)
+	}
+
+	stats := &models.CompanionHistoryStats{}
+	if len(conversations) == 0 {
+		return stats, nil
+	}
+
+	conversationIDs := make([]primitive.ObjectID, len(conversations))
+	for i, conversation := range conversations {
+		conversationIDs[i] = conversation.ID
+		if stats.FirstConversationAt == nil || conversation.CreatedAt.Before(*stats.FirstConversationAt) {
+			createdAt := conversation.CreatedAt
+			stats.FirstConversationAt = &createdAt
+		}
+	}
+
+	if err := r.aggregateMessageStats(ctx, conversationIDs, stats); err != nil {
+		return nil, err
+	}
+	if err := r.aggregateTopEmotion(ctx, conversationIDs, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// aggregateMessageStats finds the longest conversation and the user's favourite hour in a single pass over messages
+func (r *ConversationRepository) aggregateMessageStats(ctx context.Context, conversationIDs []primitive.ObjectID, stats *models.CompanionHistoryStats) error {
+	pipeline := []bson.M{
+		{"$match": bson.M{"conversation_id": bson.M{"$in": conversationIDs}}},
+		{"$facet": bson.M{
+			"longest": []bson.M{
+				{"$group": bson.M{"_id": "$conversation_id", "count": bson.M{"$sum": 1}}},
+				{"$sort": bson.D{{Key: "count", Value: -1}}},
+				{"$limit": 1},
+			},
+			"hours": []bson.M{
+				{"$match": bson.M{"sender_type": sendertype.User}},
+				{"$group": bson.M{"_id": bson.M{"$hour": "$created_at"}, "count": bson.M{"$sum": 1}}},
+				{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+				{"$limit": 1},
+			},
+		}},
+	}
+
+	cursor, err := r.db.Collection("messages").Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate message stats: %w", err)
+	}
+	var results []struct {
+		Longest []struct {
+			Count int `bson:"count"`
+		} `bson:"longest"`
+		Hours []struct {
+			Hour int `bson:"_id"`
+		} `bson:"hours"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return fmt.Errorf("failed to decode message stats: %w", err)
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	if len(results[0].Longest) > 0 {
+		stats.LongestConversation = results[0].Longest[0].Count
+	}
+	if len(results[0].Hours) > 0 {
+		hour := results[0].Hours[0].Hour
+		stats.FavouriteHour = &hour
+	}
+	return nil
+}
+
+// aggregateTopEmotion finds the primary emotion recorded most often in the conversations' emotional history
+func (r *ConversationRepository) aggregateTopEmotion(ctx context.Context, conversationIDs []primitive.ObjectID, stats *models.CompanionHistoryStats) error {
+	pipeline := []bson.M{
+		{"$match": bson.M{"conversation_id": bson.M{"$in": conversationIDs}}},
+		{"$unwind": "$emotional_history"},
+		{"$match": bson.M{"emotional_history.emotional_state.primary_emotion": bson.M{"$nin": bson.A{nil, ""}}}},
+		{"$group": bson.M{"_id": "$emotional_history.emotional_state.primary_emotion", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": 1},
+	}
+
+	cursor, err := r.db.Collection("conversation_contexts").Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate emotions: %w", err)
+	}
+	var results []struct {
+		Emotion string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return fmt.Errorf("failed to decode emotions: %w", err)
+	}
+
+	if len(results) > 0 {
+		stats.TopEmotion = results[0].Emotion
+	}
+	return nil
+}
+
+// ListActiveCompanionIDs returns the companions with conversation activity since the given time
+func (r *ConversationRepository) ListActiveCompanionIDs(ctx context.Context, since time.Time) ([]string, error) {
+	values, err := r.db.Collection("conversations").Distinct(ctx, "companion_id", r.notDeleted(bson.M{"last_activity": bson.M{"$gte": since}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find active companions: %w", err)
+	}
+
+	companionIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if companionID, ok := value.(string); ok {
+			companionIDs = append(companionIDs, companionID)
+		}
+	}
+	return companionIDs, nil
+}
+
+// ListRecentCompanionMessages returns up to limit of the companion's most recent text messages across all of its
+// conversations, newest first
+func (r *ConversationRepository) ListRecentCompanionMessages(ctx context.Context, companionID string, since time.Time, limit int) ([]*models.Message, error) {
+	conversationIDs, err := r.findConversationIDs(ctx, r.notDeleted(bson.M{"companion_id": companionID}))
+	if err != nil {
+		return nil, err
+	}
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"conversation_id": bson.M{"$in": conversationIDs},
+		"sender_type":     sendertype.Companion,
+		"created_at":      bson.M{"$gte": since},
+		"$or": bson.A{
+			bson.M{"text": bson.M{"$ne": nil}},
+			bson.M{"text_encrypted": bson.M{"$exists": true}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.db.Collection("messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find companion messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*models.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode companion messages: %w", err)
+	}
+	for _, msg := range messages {
+		if err := r.openMessage(msg); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// SavePersonalityDriftReport stores a personality drift report
+func (r *ConversationRepository) SavePersonalityDriftReport(ctx context.Context, report *models.DriftReport) error {
+	collection := r.db.Collection("personality_drift_reports")
+
+	report.ID = primitive.NewObjectID()
+	_, err := collection.InsertOne(ctx, report)
+	if err != nil {
+		return fmt.Errorf("failed to save personality drift report: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSafetyIncident stores a safety incident for human review
+func (r *ConversationRepository) CreateSafetyIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	collection := r.db.Collection("safety_incidents")
+
+	incident.ID = primitive.NewObjectID()
+	_, err := collection.InsertOne(ctx, incident)
+	if err != nil {
+		return fmt.Errorf("failed to create safety incident: %w", err)
+	}
+
+	return nil
+}
+
+// CreateModerationEvent stores a message blocked by content moderation
+func (r *ConversationRepository) CreateModerationEvent(ctx context.Context, event *models.ModerationEvent) error {
+	collection := r.db.Collection("moderation_events")
+
+	event.ID = primitive.NewObjectID()
+	_, err := collection.InsertOne(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to create moderation event: %w", err)
+	}
+
+	return nil
+}
+
+// GetConversationMessageCount returns the number of messages stored in a conversation
+func (r *ConversationRepository) GetConversationMessageCount(ctx context.Context, conversationID primitive.ObjectID) (int, error) {
+	count, err := r.db.Collection("messages").CountDocuments(ctx, bson.M{"conversation_id": conversationID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	return int(count), nil
+}
+
+// ListMessagesBetween returns up to limit messages after the after ID (exclusive, optional) and before the before ID, oldest first
+func (r *ConversationRepository) ListMessagesBetween(ctx context.Context, conversationID primitive.ObjectID, after *primitive.ObjectID, before primitive.ObjectID, limit int) ([]*models.Message, error) {
+	idFilter := bson.M{"$lt": before}
+	if after != nil {
+		idFilter["$gt"] = *after
+	}
+	filter := bson.M{"conversation_id": conversationID, "_id": idFilter}
+	opts := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(limit))
+
+	cur, err := r.db.Collection("messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var messages []*models.Message
+	for cur.Next(ctx) {
+		var msg models.Message
+		if err := cur.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("failed to decode message: %w", err)
+		}
+		if err := r.openMessage(&msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &msg)
+	}
+
+	return messages, nil
+}
+
+// ListConversationsWithMessagesOver returns the IDs of conversations holding more than threshold messages
+func (r *ConversationRepository) ListConversationsWithMessagesOver(ctx context.Context, threshold int) ([]primitive.ObjectID, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$conversation_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": threshold}}}},
+	}
+
+	cur, err := r.db.Collection("messages").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count conversation messages: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cur.Next(ctx) {
+		var result struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cur.Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode conversation ID: %w", err)
+		}
+		ids = append(ids, result.ID)
+	}
+
+	return ids, nil
+}
+
+// ForEachMessage calls fn with every message of a conversation, oldest first, stopping at the first error
+func (r *ConversationRepository) ForEachMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error {
+	return r.ForEachStoredMessage(ctx, conversationID, func(msg *models.Message) error {
+		if err := r.openMessage(msg); err != nil {
+			return err
+		}
+		return fn(msg)
+	})
+}
+
+// ForEachStoredMessage calls fn with every message of a conversation as stored, oldest first, stopping at the first
+// error. Encrypted text is left sealed; OpenMessage decrypts it.
+func (r *ConversationRepository) ForEachStoredMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error {
+	opts := options.Find().SetSort(bson.M{"_id": 1})
+	cur, err := r.db.Collection("messages").Find(ctx, bson.M{"conversation_id": conversationID}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var msg models.Message
+		if err := cur.Decode(&msg); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		if err := fn(&msg); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// MarkConversationArchived records where a conversation's messages were archived
+func (r *ConversationRepository) MarkConversationArchived(ctx context.Context, id primitive.ObjectID, archiveURL string, archivedAt time.Time) error {
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("conversations").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"archived_at": archivedAt,
+			"archive_url": archiveURL,
+			"updated_at":  time.Now(),
+		}})
+		return err
+	})
+}
+
+// messageDeleteBatchSize caps how many message IDs DeleteMessages sends in one $in filter
+const messageDeleteBatchSize = 1000
+
+// DeleteMessages deletes exactly the given messages of a conversation, in batches. ObjectIDs are only roughly
+// ordered across servers, so callers name each message rather than a range.
+func (r *ConversationRepository) DeleteMessages(ctx context.Context, conversationID primitive.ObjectID, ids []primitive.ObjectID) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(ids); start += messageDeleteBatchSize {
+		batch := ids[start:min(start+messageDeleteBatchSize, len(ids))]
+		err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+			result, err := r.db.Collection("messages").DeleteMany(ctx, bson.M{"conversation_id": conversationID, "_id": bson.M{"$in": batch}})
+			if err != nil {
+				return err
+			}
+			deleted += result.DeletedCount
+			return nil
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete archived messages: %w", err)
+		}
+	}
+	return deleted, nil
+}
+
+// SaveConversationSummary stores a condensed summary of older conversation messages
+func (r *ConversationRepository) SaveConversationSummary(ctx context.Context, summary *models.ConversationHistorySummary) error {
+	collection := r.db.Collection("conversation_summaries")
+
+	summary.ID = primitive.NewObjectID()
+	_, err := collection.InsertOne(ctx, summary)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation summary: %w", err)
+	}
+
+	return nil
+}
+
+// GetLatestConversationSummary returns the newest summary for a conversation, or nil if none exists
+func (r *ConversationRepository) GetLatestConversationSummary(ctx context.Context, conversationID primitive.ObjectID) (*models.ConversationHistorySummary, error) {
+	collection := r.db.Collection("conversation_summaries")
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	var summary models.ConversationHistorySummary
+	err := collection.FindOne(ctx, bson.M{"conversation_id": conversationID}, opts).Decode(&summary)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// CreateSpecialDates stores a relationship's special dates unless it already has some
+func (r *ConversationRepository) CreateSpecialDates(ctx context.Context, dates *models.SpecialDates) error {
+	now := time.Now()
+	filter := bson.M{"user_id": dates.UserID, "companion_id": dates.CompanionID}
+	update := bson.M{"$setOnInsert": bson.M{
+		"_id":              primitive.NewObjectID(),
+		"user_id":          dates.UserID,
+		"companion_id":     dates.CompanionID,
+		"birthdate":        dates.Birthdate,
+		"anniversary_date": dates.AnniversaryDate,
+		"last_celebrated":  time.Time{},
+		"celebrations":     []models.Celebration{},
+		"created_at":       now,
+		"updated_at":       now,
+	}}
+
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("special_dates").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create special dates: %w", err)
+	}
+
+	return nil
+}
+
+// GetSpecialDates returns a relationship's special dates
+func (r *ConversationRepository) GetSpecialDates(ctx context.Context, userID, companionID string) (*models.SpecialDates, error) {
+	var dates models.SpecialDates
+	err := r.db.Collection("special_dates").FindOne(ctx, bson.M{"user_id": userID, "companion_id": companionID}).Decode(&dates)
+	if err != nil {
+		return nil, findOneError(err, "special dates")
+	}
+
+	return &dates, nil
+}
+
+// ListSpecialDatesInMonth returns the special dates with a birthday or anniversary in the given month (UTC)
+func (r *ConversationRepository) ListSpecialDatesInMonth(ctx context.Context, month time.Month) ([]models.SpecialDates, error) {
+	filter := bson.M{"$expr": bson.M{"$or": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$month": "$birthdate"}, int(month)}},
+		bson.M{"$eq": bson.A{bson.M{"$month": "$anniversary_date"}, int(month)}},
+	}}}
+
+	cursor, err := r.db.Collection("special_dates").Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list special dates: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var dates []models.SpecialDates
+	if err := cursor.All(ctx, &dates); err != nil {
+		return nil, fmt.Errorf("failed to decode special dates: %w", err)
+	}
+
+	return dates, nil
+}
+
+// RecordCelebration adds a celebration to the relationship's history unless the occasion was already celebrated that
+// year. It reports whether the celebration was recorded, so concurrent jobs celebrate each occasion once.
+func (r *ConversationRepository) RecordCelebration(ctx context.Context, id primitive.ObjectID, celebration models.Celebration) (bool, error) {
+	filter := bson.M{
+		"_id": id,
+		"celebrations": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"occasion": celebration.Occasion,
+			"year":     celebration.Year,
+		}}},
+	}
+	update := bson.M{
+		"$push": bson.M{"celebrations": celebration},
+		"$set":  bson.M{"last_celebrated": celebration.CelebratedAt, "updated_at": time.Now()},
+	}
+
+	var result *mongo.UpdateResult
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		var err error
+		result, err = r.db.Collection("special_dates").UpdateOne(ctx, filter, update)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update message: %w", err)
+		return false, fmt.Errorf("failed to record celebration: %w", err)
 	}
 
-	return nil
+	return result.ModifiedCount > 0, nil
 }
 
-func (r *ConversationRepository) CreateMediaMetadata(ctx context.Context, media *models.MediaMetadata) (*models.MediaMetadata, error) {
-	media.ID = primitive.NewObjectID()
-	media.CreatedAt = time.Now()
-	media.UpdatedAt = time.Now()
-	_, err := r.db.Collection("media_metadata").InsertOne(ctx, media)
+// HasProactiveMessage reports whether a proactive message was already queued for the inactivity window
+func (r *ConversationRepository) HasProactiveMessage(ctx context.Context, conversationID primitive.ObjectID, inactiveSince time.Time) (bool, error) {
+	count, err := r.db.Collection("pending_proactive_messages").CountDocuments(ctx, bson.M{
+		"conversation_id": conversationID,
+		"inactive_since":  inactiveSince,
+	}, options.Count().SetLimit(1))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create media metadata: %w", err)
+		return false, fmt.Errorf("failed to check proactive messages: %w", err)
 	}
-	return media, nil
+
+	return count > 0, nil
 }
 
-func (r *ConversationRepository) GetMediaMetadataByID(ctx context.Context, id primitive.ObjectID) (*models.MediaMetadata, error) {
-	var media models.MediaMetadata
-	err := r.db.Collection("media_metadata").FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+// CreatePendingProactiveMessage queues a proactive message unless one already exists for the same
+// inactivity window. It reports whether the message was queued.
+func (r *ConversationRepository) CreatePendingProactiveMessage(ctx context.Context, msg *models.PendingProactiveMessage) (bool, error) {
+	collection := r.db.Collection("pending_proactive_messages")
+
+	msg.ID = primitive.NewObjectID()
+	msg.Status = models.ProactiveStatusPending
+	msg.CreatedAt = time.Now()
+
+	filter := bson.M{
+		"conversation_id": msg.ConversationID,
+		"inactive_since":  msg.InactiveSince,
+	}
+	update := bson.M{"$setOnInsert": msg}
+
+	var result *mongo.UpdateResult
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		var err error
+		result, err = collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("media metadata not found: %w", err)
+		return false, fmt.Errorf("failed to queue proactive message: %w", err)
 	}
-	return &media, nil
+
+	return result.UpsertedCount > 0, nil
 }
 
-// SaveConversationContext saves or updates conversation context
-func (r *ConversationRepository) SaveConversationContext(ctx context.Context, context *models.ConversationContext) error {
-	collection := r.db.Collection("conversation_contexts")
+// ClaimPendingProactiveMessage marks the oldest pending proactive message due for delivery at now as delivering and
+// returns it, or nil if none are waiting
+func (r *ConversationRepository) ClaimPendingProactiveMessage(ctx context.Context, now time.Time) (*models.PendingProactiveMessage, error) {
+	collection := r.db.Collection("pending_proactive_messages")
 
-	// Use upsert to create or update
-	filter := bson.M{"conversation_id": context.ConversationID}
-	update := bson.M{"$set": context}
-	opts := options.Update().SetUpsert(true)
+	filter := bson.M{
+		"status": models.ProactiveStatusPending,
+		"$or": bson.A{
+			bson.M{"deliver_after": bson.M{"$exists": false}},
+			bson.M{"deliver_after": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"status": models.ProactiveStatusDelivering}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
 
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	var msg models.PendingProactiveMessage
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&msg)
 	if err != nil {
-		return fmt.Errorf("failed to save conversation context: %w", err)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim proactive message: %w", err)
 	}
 
-	return nil
+	return &msg, nil
+}
+
+// UpdateProactiveMessageStatus records the outcome of a delivery attempt
+func (r *ConversationRepository) UpdateProactiveMessageStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	set := bson.M{"status": status}
+	if status == models.ProactiveStatusDelivered {
+		set["delivered_at"] = time.Now()
+	}
+
+	return mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("pending_proactive_messages").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		return err
+	})
 }
 
 // GetConversationContext retrieves conversation context by conversation ID
 func (r *ConversationRepository) GetConversationContext(ctx context.Context, conversationID primitive.ObjectID) (*models.ConversationContext, error) {
 	collection := r.db.Collection("conversation_contexts")
-	var context models.ConversationContext
+	var context, stored models.ConversationContext
 
-	err := collection.FindOne(ctx, bson.M{"conversation_id": conversationID}).Decode(&context)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("conversation context not found")
-		}
-		return nil, fmt.Errorf("failed to get conversation context: %w", err)
+	result := collection.FindOne(ctx, bson.M{"conversation_id": conversationID})
+	if err := result.Decode(&context); err != nil {
+		return nil, findOneError(err, "conversation context")
+	}
+	// Keep the document as read so that saving the context writes only the fields changed since
+	if err := result.Decode(&stored); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation context: %w", err)
 	}
+	context.SetStored(&stored)
 
 	return &context, nil
 }
@@ -275,7 +1450,7 @@ func (r *ConversationRepository) SaveMemories(ctx context.Context, conversationI
 func (r *ConversationRepository) GetMemories(ctx context.Context, conversationID primitive.ObjectID, limit int) ([]models.AIEnhancedMemoryEntry, error) {
 	collection := r.db.Collection("ai_memories")
 
-	filter := bson.M{"conversation_id": conversationID}
+	filter := bson.M{"conversation_id": conversationID, "deleted_at": nil}
 	opts := options.Find().
 		SetSort(bson.M{"importance": -1, "last_referenced": -1}).
 		SetLimit(int64(limit))
@@ -298,7 +1473,77 @@ func (r *ConversationRepository) GetMemories(ctx context.Context, conversationID
 	return memories, nil
 }
 
-// UpdateMemoryReference updates the last referenced time and frequency of a memory
+// SearchMemoriesByEmbedding returns the topK embedded memories of a conversation most similar to
+// queryEmbedding. Similarity is computed in-process because not every MongoDB deployment supports vector search.
+func (r *ConversationRepository) SearchMemoriesByEmbedding(ctx context.Context, conversationID primitive.ObjectID, queryEmbedding []float32, topK int) ([]models.AIEnhancedMemoryEntry, error) {
+	if topK <= 0 || len(queryEmbedding) == 0 {
+		return []models.AIEnhancedMemoryEntry{}, nil
+	}
+
+	collection := r.db.Collection("ai_memories")
+
+	filter := bson.M{
+		"conversation_id": conversationID,
+		"embedding":       bson.M{"$exists": true, "$ne": bson.A{}},
+		"deleted_at":      nil,
+	}
+
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	type scoredMemory struct {
+		memory     models.AIEnhancedMemoryEntry
+		similarity float64
+	}
+
+	var scored []scoredMemory
+	for cur.Next(ctx) {
+		var memory models.AIEnhancedMemoryEntry
+		if err := cur.Decode(&memory); err != nil {
+			return nil, fmt.Errorf("failed to decode memory: %w", err)
+		}
+		if len(memory.Embedding) != len(queryEmbedding) {
+			continue
+		}
+		scored = append(scored, scoredMemory{memory: memory, similarity: cosineSimilarity(queryEmbedding, memory.Embedding)})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search memories: %w", err)
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].similarity > scored[j].similarity
+	})
+
+	memories := make([]models.AIEnhancedMemoryEntry, 0, min(topK, len(scored)))
+	for i := 0; i < len(scored) && i < topK; i++ {
+		memories = append(memories, scored[i].memory)
+	}
+
+	return memories, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between two equal-length vectors, or 0 if either is zero
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// UpdateMemoryReference updates the last referenced time and frequency of a memory. The write is not retried here:
+// after a lost acknowledgement a retry would count the reference twice, so it relies on the driver's retryable
+// writes, which the server deduplicates.
 func (r *ConversationRepository) UpdateMemoryReference(ctx context.Context, memoryID primitive.ObjectID) error {
 	collection := r.db.Collection("ai_memories")
 
@@ -321,22 +1566,388 @@ func (r *ConversationRepository) UpdateMemoryReference(ctx context.Context, memo
 	return nil
 }
 
+// ListStaleMemories returns the importance of every memory not referenced since referencedBefore, keyed by memory ID.
+// Soft-deleted memories are skipped.
+func (r *ConversationRepository) ListStaleMemories(ctx context.Context, referencedBefore time.Time) (map[primitive.ObjectID]float64, error) {
+	filter := bson.M{
+		"last_referenced": bson.M{"$lt": referencedBefore},
+		"deleted_at":      nil,
+	}
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "importance": 1})
+
+	cur, err := r.db.Collection("ai_memories").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale memories: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	memories := make(map[primitive.ObjectID]float64)
+	for cur.Next(ctx) {
+		var memory struct {
+			ID         primitive.ObjectID `bson:"_id"`
+			Importance float64            `bson:"importance"`
+		}
+		if err := cur.Decode(&memory); err != nil {
+			return nil, fmt.Errorf("failed to decode memory: %w", err)
+		}
+		memories[memory.ID] = memory.Importance
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list stale memories: %w", err)
+	}
+
+	return memories, nil
+}
+
+// BulkUpdateMemoryImportance sets the importance of each memory in updates in a single bulk write
+func (r *ConversationRepository) BulkUpdateMemoryImportance(ctx context.Context, updates map[primitive.ObjectID]float64) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(updates))
+	for id, importance := range updates {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": bson.M{"importance": importance, "updated_at": now}}))
+	}
+
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("ai_memories").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update memory importance: %w", err)
+	}
+
+	return nil
+}
+
+// SoftDeleteMemories marks memories as deleted so they are no longer recalled
+func (r *ConversationRepository) SoftDeleteMemories(ctx context.Context, memoryIDs []primitive.ObjectID) error {
+	if len(memoryIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("ai_memories").UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": memoryIDs}, "deleted_at": nil},
+			bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete memories: %w", err)
+	}
+
+	return nil
+}
+
+// GetMemoriesByType returns up to limit of a conversation's memories of one type, most important first
+func (r *ConversationRepository) GetMemoriesByType(ctx context.Context, conversationID primitive.ObjectID, memoryType string, limit int) ([]models.AIEnhancedMemoryEntry, error) {
+	filter := bson.M{"conversation_id": conversationID, "type": memoryType, "deleted_at": nil}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "importance", Value: -1}, {Key: "last_referenced", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.db.Collection("ai_memories").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memories: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var memories []models.AIEnhancedMemoryEntry
+	if err := cursor.All(ctx, &memories); err != nil {
+		return nil, fmt.Errorf("failed to decode memories: %w", err)
+	}
+	return memories, nil
+}
+
+// DemoteMemory sets a memory's importance and records why it was demoted. Returns a NotFoundError if the memory does
+// not exist or has been deleted.
+func (r *ConversationRepository) DemoteMemory(ctx context.Context, memoryID primitive.ObjectID, importance float64, resolution string) error {
+	var result *mongo.UpdateResult
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		var err error
+		result, err = r.db.Collection("ai_memories").UpdateOne(ctx,
+			bson.M{"_id": memoryID, "deleted_at": nil},
+			bson.M{"$set": bson.M{"importance": importance, "conflict_resolution": resolution, "updated_at": time.Now()}})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to demote memory: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperrors.NewNotFoundError("memory not found", nil)
+	}
+
+	return nil
+}
+
+// ListBlockedTopics returns the topics operators have blocked in the topic_blocklist collection
+func (r *ConversationRepository) ListBlockedTopics(ctx context.Context) ([]string, error) {
+	topics, err := r.db.Collection("topic_blocklist").Distinct(ctx, "topic", bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked topics: %w", err)
+	}
+
+	var blocked []string
+	for _, topic := range topics {
+		if topic, ok := topic.(string); ok {
+			blocked = append(blocked, topic)
+		}
+	}
+	return blocked, nil
+}
+
+// forkBatchSize is the number of messages or memories inserted per write when forking a conversation
+const forkBatchSize = 500
+
+// ForkConversation creates a new conversation for the user holding copies of the original's messages up to and
+// including fromMessageID, the memories formed up to that message and the part of its conversation context that
+// predates it. The fork records where it was branched from in ForkedFrom; the original conversation is not modified.
+// Conversations whose older messages have been archived to S3 cannot be forked.
+func (r *ConversationRepository) ForkConversation(ctx context.Context, originalConversationID, fromMessageID primitive.ObjectID, userID string) (*models.Conversation, error) {
+	original, err := r.GetConversationByID(ctx, originalConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if original.UserID != userID {
+		return nil, apperrors.NewNotFoundError("conversation not found", nil)
+	}
+	if original.ArchiveURL != "" {
+		return nil, apperrors.NewConflictError("archived conversations cannot be forked", nil)
+	}
+
+	forkPoint, err := r.GetMessageByID(ctx, fromMessageID)
+	if err != nil {
+		return nil, err
+	}
+	if forkPoint.ConversationID != originalConversationID {
+		return nil, apperrors.NewNotFoundError("message not found", nil)
+	}
+
+	now := time.Now()
+	fork := &models.Conversation{
+		ID:           primitive.NewObjectID(),
+		UserID:       original.UserID,
+		CompanionID:  original.CompanionID,
+		Relationship: original.Relationship,
+		LastActivity: forkPoint.CreatedAt,
+		ForkedFrom: &models.ConversationFork{
+			ConversationID: originalConversationID,
+			MessageID:      fromMessageID,
+			ForkedAt:       now,
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, msg := range original.RecentMessages {
+		if bytes.Compare(msg.ID[:], fromMessageID[:]) <= 0 {
+			fork.RecentMessages = append(fork.RecentMessages, msg)
+		}
+	}
+
+	_, err = r.db.Collection("conversations").InsertOne(ctx, fork)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create forked conversation: %w", err)
+	}
+
+	if err := r.copyForkContents(ctx, original.ID, fork, forkPoint); err != nil {
+		// Remove the partial fork so a failed fork leaves nothing behind
+		if cleanupErr := r.deleteConversations(context.WithoutCancel(ctx), []primitive.ObjectID{fork.ID}); cleanupErr != nil {
+			return nil, errors.Join(err, cleanupErr)
+		}
+		return nil, err
+	}
+
+	return fork, nil
+}
+
+// copyForkContents copies the messages, memories and context of the original conversation up to the fork point
+func (r *ConversationRepository) copyForkContents(ctx context.Context, originalID primitive.ObjectID, fork *models.Conversation, forkPoint *models.Message) error {
+	// Copies get new IDs generated in the original order, so cursor pagination works the same in the fork
+	cursor, err := r.db.Collection("messages").Find(ctx,
+		bson.M{"conversation_id": originalID, "_id": bson.M{"$lte": forkPoint.ID}},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to read messages to fork: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var batch []any
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		// Conversation keys differ, so encrypted text is re-encrypted for the fork
+		if err := r.openMessage(&msg); err != nil {
+			return err
+		}
+		msg.ID = primitive.NewObjectID()
+		msg.ConversationID = fork.ID
+		msg.Reactions = nil
+		doc, err := r.sealMessage(&msg)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, *doc)
+
+		if len(batch) == forkBatchSize {
+			if err := r.insertForkBatch(ctx, "messages", batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to read messages to fork: %w", err)
+	}
+	if err := r.insertForkBatch(ctx, "messages", batch); err != nil {
+		return err
+	}
+
+	memories, err := r.copyForkMemories(ctx, originalID, fork.ID, forkPoint.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	original, err := r.GetConversationContext(ctx, originalID)
+	var notFound *apperrors.NotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	conversationContext := forkedContext(original, fork.ID, forkPoint.ID, memories)
+	_, err = r.db.Collection("conversation_contexts").InsertOne(ctx, conversationContext)
+	if err != nil {
+		return fmt.Errorf("failed to copy conversation context: %w", err)
+	}
+
+	return nil
+}
+
+// forkedContext returns the context a fork starts with. The original context reflects the whole conversation, so only
+// what can be placed at or before the fork point is carried over: the relationship, the active memories that were
+// copied, and the emotional snapshots of messages up to the fork point. Topics, context layers, the companion's mood
+// and usage counters start afresh.
+func forkedContext(original *models.ConversationContext, forkID, forkPointID primitive.ObjectID, memories map[primitive.ObjectID]models.AIEnhancedMemoryEntry) *models.ConversationContext {
+	now := time.Now()
+	forked := &models.ConversationContext{
+		ID:                 primitive.NewObjectID(),
+		ConversationID:     forkID,
+		UserID:             original.UserID,
+		CompanionID:        original.CompanionID,
+		RelationshipStage:  original.RelationshipStage,
+		TrustLevel:         original.TrustLevel,
+		IntimacyLevel:      original.IntimacyLevel,
+		CurrentTopic:       models.InitialTopic,
+		TopicHistory:       []string{},
+		ConversationPacing: original.ConversationPacing,
+		ActiveMemories:     []models.AIEnhancedMemoryEntry{},
+		EmotionalHistory:   []models.EmotionalSnapshot{},
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	for _, memory := range original.ActiveMemories {
+		if copied, ok := memories[memory.ID]; ok {
+			forked.ActiveMemories = append(forked.ActiveMemories, copied)
+		}
+	}
+	for _, snapshot := range original.EmotionalHistory {
+		if bytes.Compare(snapshot.MessageID[:], forkPointID[:]) <= 0 {
+			forked.EmotionalHistory = append(forked.EmotionalHistory, snapshot)
+			forked.UserEmotionalState = snapshot.EmotionalState
+		}
+	}
+	return forked
+}
+
+// copyForkMemories copies the memories formed up to the fork point and returns the copies keyed by original ID
+func (r *ConversationRepository) copyForkMemories(ctx context.Context, originalID, forkID primitive.ObjectID, until time.Time) (map[primitive.ObjectID]models.AIEnhancedMemoryEntry, error) {
+	cursor, err := r.db.Collection("ai_memories").Find(ctx, bson.M{"conversation_id": originalID, "created_at": bson.M{"$lte": until}, "deleted_at": nil})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memories to fork: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var memories []models.AIEnhancedMemoryEntry
+	if err := cursor.All(ctx, &memories); err != nil {
+		return nil, fmt.Errorf("failed to decode memories: %w", err)
+	}
+
+	copies := make(map[primitive.ObjectID]models.AIEnhancedMemoryEntry, len(memories))
+	newIDs := make(map[primitive.ObjectID]primitive.ObjectID, len(memories))
+	for _, memory := range memories {
+		newIDs[memory.ID] = primitive.NewObjectID()
+	}
+
+	var batch []any
+	for _, memory := range memories {
+		originalMemoryID := memory.ID
+		memory.ID = newIDs[originalMemoryID]
+		memory.ConversationID = forkID
+		// Links to memories formed after the fork point are dropped
+		var related []primitive.ObjectID
+		for _, id := range memory.RelatedMemories {
+			if newID, ok := newIDs[id]; ok {
+				related = append(related, newID)
+			}
+		}
+		memory.RelatedMemories = related
+
+		copies[originalMemoryID] = memory
+		batch = append(batch, memory)
+		if len(batch) == forkBatchSize {
+			if err := r.insertForkBatch(ctx, "ai_memories", batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := r.insertForkBatch(ctx, "ai_memories", batch); err != nil {
+		return nil, err
+	}
+
+	return copies, nil
+}
+
+// insertForkBatch inserts copied documents into collection, doing nothing for an empty batch
+func (r *ConversationRepository) insertForkBatch(ctx context.Context, collection string, batch []any) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Collection(collection).InsertMany(ctx, batch)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to fork: %w", collection, err)
+	}
+	return nil
+}
+
 // DeleteUserConversations deletes all conversations for a specific user
 func (r *ConversationRepository) DeleteUserConversations(ctx context.Context, userID string) error {
-	filter := bson.M{"user_id": userID}
-
-	// Delete conversations
-	_, err := r.db.Collection("conversations").DeleteMany(ctx, filter)
+	conversationIDs, err := r.findConversationIDs(ctx, bson.M{"user_id": userID})
 	if err != nil {
-		return fmt.Errorf("failed to delete user conversations: %w", err)
+		return err
+	}
+	if len(conversationIDs) == 0 {
+		return nil
 	}
 
-	// Delete messages for this user's conversations
-	// First get all conversation IDs for this user
-	convFilter := bson.M{"user_id": userID}
-	convCursor, err := r.db.Collection("conversations").Find(ctx, convFilter, options.Find().SetProjection(bson.M{"_id": 1}))
+	return r.deleteConversations(ctx, conversationIDs)
+}
+
+// findConversationIDs returns the IDs of all conversations matching filter, deleted or not
+func (r *ConversationRepository) findConversationIDs(ctx context.Context, filter bson.M) ([]primitive.ObjectID, error) {
+	convCursor, err := r.db.Collection("conversations").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
 	if err != nil {
-		return fmt.Errorf("failed to find user conversations: %w", err)
+		return nil, fmt.Errorf("failed to find conversations: %w", err)
 	}
 	defer convCursor.Close(ctx)
 
@@ -346,32 +1957,34 @@ func (r *ConversationRepository) DeleteUserConversations(ctx context.Context, us
 			ID primitive.ObjectID `bson:"_id"`
 		}
 		if err := convCursor.Decode(&conv); err != nil {
-			return fmt.Errorf("failed to decode conversation ID: %w", err)
+			return nil, fmt.Errorf("failed to decode conversation ID: %w", err)
 		}
 		conversationIDs = append(conversationIDs, conv.ID)
 	}
 
-	if len(conversationIDs) > 0 {
-		// Delete messages
-		msgFilter := bson.M{"conversation_id": bson.M{"$in": conversationIDs}}
-		_, err = r.db.Collection("messages").DeleteMany(ctx, msgFilter)
-		if err != nil {
-			return fmt.Errorf("failed to delete user messages: %w", err)
-		}
+	return conversationIDs, convCursor.Err()
+}
 
-		// Delete conversation contexts
-		ctxFilter := bson.M{"conversation_id": bson.M{"$in": conversationIDs}}
-		_, err = r.db.Collection("conversation_contexts").DeleteMany(ctx, ctxFilter)
+// deleteConversations permanently removes conversations with their messages, contexts and memories.
+// The conversations themselves go last, so an interrupted delete can be retried by conversation ID.
+func (r *ConversationRepository) deleteConversations(ctx context.Context, conversationIDs []primitive.ObjectID) error {
+	byConversation := bson.M{"conversation_id": bson.M{"$in": conversationIDs}}
+	for _, collection := range []string{"messages", "conversation_contexts", "ai_memories"} {
+		err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+			_, err := r.db.Collection(collection).DeleteMany(ctx, byConversation)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to delete conversation contexts: %w", err)
+			return fmt.Errorf("failed to delete %s: %w", collection, err)
 		}
+	}
 
-		// Delete AI memories
-		memoryFilter := bson.M{"conversation_id": bson.M{"$in": conversationIDs}}
-		_, err = r.db.Collection("ai_memories").DeleteMany(ctx, memoryFilter)
-		if err != nil {
-			return fmt.Errorf("failed to delete AI memories: %w", err)
-		}
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("conversations").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": conversationIDs}})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete conversations: %w", err)
 	}
 
 	return nil
@@ -382,7 +1995,7 @@ func (r *ConversationRepository) GetConversationStats(ctx context.Context, userI
 	stats := make(map[string]any)
 
 	// Total conversations
-	totalFilter := bson.M{"user_id": userID}
+	totalFilter := r.notDeleted(bson.M{"user_id": userID})
 	totalCount, err := r.db.Collection("conversations").CountDocuments(ctx, totalFilter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count total conversations: %w", err)
@@ -390,7 +2003,7 @@ func (r *ConversationRepository) GetConversationStats(ctx context.Context, userI
 	stats["total_conversations"] = totalCount
 
 	// Active conversations (not archived)
-	activeFilter := bson.M{"user_id": userID, "archived": false}
+	activeFilter := r.notDeleted(bson.M{"user_id": userID, "archived": false})
 	activeCount, err := r.db.Collection("conversations").CountDocuments(ctx, activeFilter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count active conversations: %w", err)
@@ -398,7 +2011,7 @@ func (r *ConversationRepository) GetConversationStats(ctx context.Context, userI
 	stats["active_conversations"] = activeCount
 
 	// Archived conversations
-	archivedFilter := bson.M{"user_id": userID, "archived": true}
+	archivedFilter := r.notDeleted(bson.M{"user_id": userID, "archived": true})
 	archivedCount, err := r.db.Collection("conversations").CountDocuments(ctx, archivedFilter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count archived conversations: %w", err)
@@ -406,7 +2019,7 @@ func (r *ConversationRepository) GetConversationStats(ctx context.Context, userI
 	stats["archived_conversations"] = archivedCount
 
 	// Total messages
-	convFilter := bson.M{"user_id": userID}
+	convFilter := r.notDeleted(bson.M{"user_id": userID})
 	convCursor, err := r.db.Collection("conversations").Find(ctx, convFilter, options.Find().SetProjection(bson.M{"_id": 1}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user conversations: %w", err)
@@ -441,7 +2054,7 @@ func (r *ConversationRepository) GetConversationStats(ctx context.Context, userI
 // GetCompanionConversationStats gets conversation statistics for a specific companion
 func (r *ConversationRepository) GetCompanionConversationStats(ctx context.Context, userID, companionID string) (*models.ConversationStats, error) {
 	// Get conversations between user and companion
-	convFilter := bson.M{"user_id": userID, "companion_id": companionID}
+	convFilter := r.notDeleted(bson.M{"user_id": userID, "companion_id": companionID})
 	convCursor, err := r.db.Collection("conversations").Find(ctx, convFilter, options.Find().SetProjection(bson.M{"_id": 1}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find conversations: %w", err)
@@ -511,13 +2124,13 @@ func (r *ConversationRepository) GetCompanionConversationStats(ctx context.Conte
 
 // GetConversationsByDateRange gets conversations within a date range
 func (r *ConversationRepository) GetConversationsByDateRange(ctx context.Context, userID string, startDate, endDate time.Time) ([]*models.Conversation, error) {
-	filter := bson.M{
+	filter := r.notDeleted(bson.M{
 		"user_id": userID,
 		"created_at": bson.M{
 			"$gte": startDate,
 			"$lte": endDate,
 		},
-	}
+	})
 
 	opts := options.Find().SetSort(bson.M{"created_at": -1})
 	cur, err := r.db.Collection("conversations").Find(ctx, filter, opts)
@@ -537,3 +2150,53 @@ func (r *ConversationRepository) GetConversationsByDateRange(ctx context.Context
 
 	return conversations, nil
 }
+
+// CreateConversationTemplate stores a new conversation template. Template IDs are unique.
+func (r *ConversationRepository) CreateConversationTemplate(ctx context.Context, template *models.ConversationTemplate) error {
+	template.ID = primitive.NewObjectID()
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+
+	_, err := r.db.Collection("conversation_templates").InsertOne(ctx, template)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return apperrors.NewConflictError(fmt.Sprintf("conversation template %s already exists", template.TemplateID), err)
+		}
+		return fmt.Errorf("failed to create conversation template: %w", err)
+	}
+
+	return nil
+}
+
+// GetConversationTemplate returns the template with the given template ID
+func (r *ConversationRepository) GetConversationTemplate(ctx context.Context, templateID string) (*models.ConversationTemplate, error) {
+	var template models.ConversationTemplate
+	err := r.db.Collection("conversation_templates").FindOne(ctx, bson.M{"template_id": templateID}).Decode(&template)
+	if err != nil {
+		return nil, findOneError(err, "conversation template")
+	}
+	return &template, nil
+}
+
+// ListConversationTemplates returns up to limit templates carrying every one of tags, ordered by name. No tags lists
+// every template.
+func (r *ConversationRepository) ListConversationTemplates(ctx context.Context, tags []string, limit int) ([]models.ConversationTemplate, error) {
+	filter := bson.M{}
+	if len(tags) > 0 {
+		filter["tags"] = bson.M{"$all": tags}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetLimit(int64(limit))
+
+	cursor, err := r.db.Collection("conversation_templates").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation templates: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.ConversationTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation templates: %w", err)
+	}
+
+	return templates, nil
+}
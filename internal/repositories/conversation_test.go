@@ -0,0 +1,845 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func toBSON(t *testing.T, v any) bson.D {
+	t.Helper()
+	data, err := bson.Marshal(v)
+	require.NoError(t, err)
+	var doc bson.D
+	require.NoError(t, bson.Unmarshal(data, &doc))
+	return doc
+}
+
+func TestExportSessionReplay(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("streams messages with sentiment", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		var trend []models.SentimentPoint
+		var messageDocs []bson.D
+		for i := 0; i < 50; i++ {
+			createdAt := start.Add(time.Duration(i) * time.Hour)
+			senderType := sendertype.User
+			if i%2 == 1 {
+				senderType = sendertype.Companion
+			}
+			text := "hello"
+			messageDocs = append(messageDocs, toBSON(t, models.Message{
+				ID:             primitive.NewObjectID(),
+				ConversationID: conversationID,
+				SenderType:     senderType,
+				Type:           messagetype.Text,
+				Text:           &text,
+				CreatedAt:      createdAt,
+			}))
+			if i < 40 {
+				trend = append(trend, models.SentimentPoint{Timestamp: createdAt.Add(time.Minute), Score: 0.7, Dominant: "positive"})
+			}
+		}
+
+		engagement := toBSON(t, models.UserEngagementAnalytics{ConversationID: conversationID, SentimentTrend: trend})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, engagement),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, messageDocs...),
+		)
+
+		repo := NewConversationRepository(mt.DB)
+		reader, err := repo.ExportSessionReplay(context.Background(), conversationID)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		scanner := bufio.NewScanner(reader)
+		lines := 0
+		companionLines := 0
+		withSentiment := 0
+		for scanner.Scan() {
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &fields))
+			assert.Contains(t, fields, "message")
+			assert.Contains(t, fields, "sender_type")
+
+			var entry models.SessionReplayEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			if entry.SenderType == sendertype.Companion {
+				companionLines++
+			}
+			if entry.Sentiment != nil {
+				withSentiment++
+				assert.Equal(t, "positive", entry.Sentiment.Dominant)
+			}
+			lines++
+		}
+		require.NoError(t, scanner.Err())
+
+		assert.Equal(t, 50, lines)
+		assert.Equal(t, 25, companionLines)
+		assert.Equal(t, 40, withSentiment)
+	})
+}
+
+func TestConversationContextHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("records an event per save", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB)
+		conversationID := primitive.NewObjectID()
+		contextDoc := &models.ConversationContext{
+			ID:                primitive.NewObjectID(),
+			ConversationID:    conversationID,
+			RelationshipStage: "getting_to_know",
+			CurrentTopic:      "general",
+			TrustLevel:        0.5,
+		}
+
+		save := func(previous bson.D, actor string) bson.D {
+			mt.AddMockResponses(
+				bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: previous}},
+				mtest.CreateSuccessResponse(),
+			)
+			require.NoError(t, repo.SaveConversationContext(context.Background(), contextDoc, actor))
+
+			mt.GetStartedEvent()
+			insert := mt.GetStartedEvent()
+			require.Equal(t, "insert", insert.CommandName)
+			return toBSON(t, insert.Command.Lookup("documents").Array().Index(0).Value().Document())
+		}
+
+		first := toBSON(t, contextDoc)
+		contextDoc.CurrentTopic = "travel"
+		topicEvent := save(first, models.ContextActorUser)
+
+		second := toBSON(t, contextDoc)
+		contextDoc.RelationshipStage = "friendship"
+		contextDoc.TrustLevel = 0.6
+		stageEvent := save(second, models.ContextActorSystem)
+
+		third := toBSON(t, contextDoc)
+		contextDoc.TokenUsage = 120
+		updateEvent := save(third, models.ContextActorSystem)
+
+		var events []models.ContextEvent
+		for _, doc := range []bson.D{topicEvent, stageEvent, updateEvent} {
+			data, err := bson.Marshal(doc)
+			require.NoError(t, err)
+			var event models.ContextEvent
+			require.NoError(t, bson.Unmarshal(data, &event))
+			events = append(events, event)
+		}
+
+		assert.Equal(t, models.ContextEventTopicChanged, events[0].EventType)
+		assert.Equal(t, models.ContextActorUser, events[0].Actor)
+		assert.Equal(t, map[string]any{"current_topic": "general"}, events[0].Before)
+		assert.Equal(t, map[string]any{"current_topic": "travel"}, events[0].After)
+
+		assert.Equal(t, models.ContextEventStageAdvanced, events[1].EventType)
+		assert.Equal(t, models.ContextActorSystem, events[1].Actor)
+		assert.Equal(t, map[string]any{"relationship_stage": "getting_to_know", "trust_level": 0.5}, events[1].Before)
+		assert.Equal(t, map[string]any{"relationship_stage": "friendship", "trust_level": 0.6}, events[1].After)
+
+		assert.Equal(t, models.ContextEventUpdated, events[2].EventType)
+		assert.Equal(t, map[string]any{"token_usage": int32(0)}, events[2].Before)
+		assert.Equal(t, map[string]any{"token_usage": int32(120)}, events[2].After)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.conversation_context_events", mtest.FirstBatch, updateEvent, stageEvent, topicEvent))
+		history, err := repo.GetConversationContextHistory(context.Background(), conversationID, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, models.ContextEventUpdated, history[0].EventType)
+		assert.Equal(t, models.ContextEventTopicChanged, history[2].EventType)
+
+		find := mt.GetStartedEvent()
+		assert.Equal(t, int32(-1), find.Command.Lookup("sort", "timestamp").Int32())
+		assert.Equal(t, int64(10), find.Command.Lookup("limit").Int64())
+	})
+}
+
+func TestSearchMemoriesByEmbedding(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("orders memories by cosine similarity", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		memory := func(content string, embedding ...float32) bson.D {
+			return toBSON(t, models.AIEnhancedMemoryEntry{
+				ID:             primitive.NewObjectID(),
+				ConversationID: conversationID,
+				Content:        content,
+				Embedding:      embedding,
+			})
+		}
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.ai_memories", mtest.FirstBatch,
+			memory("works as a nurse", 0, 1, 0),
+			memory("has a dog named Max", 0.9, 0.1, 0),
+			memory("loves hiking with the dog", 0.7, 0.3, 0.2),
+			memory("opposite of dogs", -1, 0, 0),
+			memory("stale embedding model", 1, 0),
+		))
+
+		repo := NewConversationRepository(mt.DB)
+		memories, err := repo.SearchMemoriesByEmbedding(context.Background(), conversationID, []float32{1, 0, 0}, 3)
+
+		require.NoError(t, err)
+		require.Len(t, memories, 3)
+		assert.Equal(t, "has a dog named Max", memories[0].Content)
+		assert.Equal(t, "loves hiking with the dog", memories[1].Content)
+		assert.Equal(t, "works as a nurse", memories[2].Content)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, conversationID, filter.Lookup("conversation_id").ObjectID())
+		assert.Equal(t, bson.TypeNull, filter.Lookup("deleted_at").Type, "soft-deleted memories are not recalled")
+	})
+
+	mt.Run("empty query returns nothing", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB)
+		memories, err := repo.SearchMemoriesByEmbedding(context.Background(), primitive.NewObjectID(), nil, 3)
+
+		require.NoError(t, err)
+		assert.Empty(t, memories)
+	})
+}
+
+func TestBulkUpdateMemoryImportance(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("updates every memory in one write", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		first, second := primitive.NewObjectID(), primitive.NewObjectID()
+		updates := map[primitive.ObjectID]float64{first: 0.81, second: 0.45}
+		require.NoError(t, NewConversationRepository(mt.DB).BulkUpdateMemoryImportance(context.Background(), updates))
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, "ai_memories", events[0].Command.Lookup("update").StringValue())
+		statements, err := events[0].Command.Lookup("updates").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, statements, 2)
+		for _, statement := range statements {
+			id := statement.Document().Lookup("q", "_id").ObjectID()
+			assert.Equal(t, updates[id], statement.Document().Lookup("u", "$set", "importance").Double())
+		}
+	})
+
+	mt.Run("no updates skips the write", func(mt *mtest.T) {
+		require.NoError(t, NewConversationRepository(mt.DB).BulkUpdateMemoryImportance(context.Background(), nil))
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+}
+
+func TestUpdateMemoryReference(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("frequency increment is only retried by the driver", func(mt *mtest.T) {
+		networkError := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Name: "InternalError", Message: "connection reset", Labels: []string{"NetworkError"}})
+		mt.AddMockResponses(networkError, networkError, mtest.CreateSuccessResponse())
+
+		err := NewConversationRepository(mt.DB).UpdateMemoryReference(context.Background(), primitive.NewObjectID())
+		require.Error(t, err)
+
+		// The increment may have applied, so it is only resent as the driver's retryable write, which the server
+		// recognises by its transaction number
+		var txnNumbers []int64
+		for _, event := range mt.GetAllStartedEvents() {
+			assert.Equal(t, "ai_memories", event.Command.Lookup("update").StringValue())
+			txnNumbers = append(txnNumbers, event.Command.Lookup("txnNumber").Int64())
+		}
+		require.Len(t, txnNumbers, 2)
+		assert.Equal(t, txnNumbers[0], txnNumbers[1])
+	})
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1.0, cosineSimilarity([]float32{1, 2, 3}, []float32{2, 4, 6}), 1e-9)
+	assert.InDelta(t, 0.0, cosineSimilarity([]float32{1, 0}, []float32{0, 1}), 1e-9)
+	assert.InDelta(t, -1.0, cosineSimilarity([]float32{1, 1}, []float32{-1, -1}), 1e-9)
+	assert.Zero(t, cosineSimilarity([]float32{0, 0}, []float32{1, 1}))
+}
+
+func TestCreateMessageIdempotencyKey(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	duplicateKey := mtest.CreateWriteErrorsResponse(mtest.WriteError{
+		Index:   0,
+		Code:    11000,
+		Message: "E11000 duplicate key error collection: lunaria.messages index: idx_messages_conversation_idempotency_key",
+	})
+
+	mt.Run("first send is stored", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		text := "hello"
+		repo := NewConversationRepository(mt.DB)
+		msg := &models.Message{ConversationID: primitive.NewObjectID(), Text: &text, ClientIdempotencyKey: "5f3a2b8e-8c1d-4e0f-9a6b-2d7c9e1f4a3b"}
+		stored, deduplicated, err := repo.CreateMessage(context.Background(), msg)
+
+		require.NoError(t, err)
+		assert.False(t, deduplicated)
+		assert.Same(t, msg, stored)
+
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, "5f3a2b8e-8c1d-4e0f-9a6b-2d7c9e1f4a3b", doc.Lookup("client_idempotency_key").StringValue())
+	})
+
+	mt.Run("retried send returns the original message", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		text := "hello"
+		original := models.Message{
+			ID:                   primitive.NewObjectID(),
+			ConversationID:       conversationID,
+			SenderID:             "user-1",
+			SenderType:           sendertype.User,
+			Type:                 messagetype.Text,
+			Text:                 &text,
+			ClientIdempotencyKey: "5f3a2b8e-8c1d-4e0f-9a6b-2d7c9e1f4a3b",
+			CreatedAt:            time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond),
+		}
+		mt.AddMockResponses(duplicateKey, mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSON(t, original)))
+
+		retry := &models.Message{ConversationID: conversationID, SenderID: "user-1", Text: &text, ClientIdempotencyKey: original.ClientIdempotencyKey}
+		repo := NewConversationRepository(mt.DB)
+		stored, deduplicated, err := repo.CreateMessage(context.Background(), retry)
+
+		require.NoError(t, err)
+		assert.True(t, deduplicated)
+		assert.Equal(t, original.ID, stored.ID)
+		assert.Equal(t, original.CreatedAt, stored.CreatedAt)
+
+		mt.GetStartedEvent()
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, conversationID, filter.Lookup("conversation_id").ObjectID())
+		assert.Equal(t, original.ClientIdempotencyKey, filter.Lookup("client_idempotency_key").StringValue())
+	})
+
+	mt.Run("duplicate without a key is an error", func(mt *mtest.T) {
+		mt.AddMockResponses(duplicateKey)
+
+		repo := NewConversationRepository(mt.DB)
+		_, deduplicated, err := repo.CreateMessage(context.Background(), &models.Message{ConversationID: primitive.NewObjectID()})
+
+		assert.Error(t, err)
+		assert.False(t, deduplicated)
+	})
+}
+
+func TestSoftDeleteConversations(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("queries leave out deleted conversations by default", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: id}}),
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: id}}),
+		)
+
+		repo := NewConversationRepository(mt.DB)
+		_, err := repo.GetConversationByID(context.Background(), id)
+		require.NoError(t, err)
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, bson.TypeNull, filter.Lookup("deleted_at").Type)
+
+		_, err = repo.WithQueryOptions(ConversationQueryOptions{IncludeDeleted: true}).GetConversationByID(context.Background(), id)
+		require.NoError(t, err)
+		filter = mt.GetStartedEvent().Command.Lookup("filter").Document()
+		_, lookupErr := filter.LookupErr("deleted_at")
+		assert.Error(t, lookupErr, "admin queries include deleted conversations")
+	})
+
+	mt.Run("style guide queries leave out deleted conversations", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: bson.A{"companion-1"}}},
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch),
+		)
+
+		repo := NewConversationRepository(mt.DB)
+		companionIDs, err := repo.ListActiveCompanionIDs(context.Background(), time.Now().AddDate(0, 0, -7))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"companion-1"}, companionIDs)
+		query := mt.GetStartedEvent().Command.Lookup("query").Document()
+		assert.Equal(t, bson.TypeNull, query.Lookup("deleted_at").Type)
+
+		_, err = repo.ListRecentCompanionMessages(context.Background(), "companion-1", time.Now().AddDate(0, 0, -7), 50)
+		require.NoError(t, err)
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, bson.TypeNull, filter.Lookup("deleted_at").Type)
+	})
+
+	mt.Run("soft delete sets deleted_at once", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		id := primitive.NewObjectID()
+		require.NoError(t, NewConversationRepository(mt.DB).SoftDeleteConversation(context.Background(), id))
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, id, update.Lookup("q", "_id").ObjectID())
+		assert.Equal(t, bson.TypeNull, update.Lookup("q", "deleted_at").Type)
+		assert.Equal(t, bson.TypeDateTime, update.Lookup("u", "$set", "deleted_at").Type)
+	})
+
+	mt.Run("purge removes related data before the conversations", func(mt *mtest.T) {
+		ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: ids[0]}}, bson.D{{Key: "_id", Value: ids[1]}}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		cutoff := time.Now().Add(-30 * 24 * time.Hour).UTC().Truncate(time.Millisecond)
+		purged, err := NewConversationRepository(mt.DB).PurgeDeletedConversations(context.Background(), cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), purged)
+
+		find := mt.GetStartedEvent()
+		assert.Equal(t, cutoff, find.Command.Lookup("filter", "deleted_at", "$lte").Time().UTC())
+
+		var collections []string
+		for _, event := range mt.GetAllStartedEvents() {
+			require.Equal(t, "delete", event.CommandName)
+			collections = append(collections, event.Command.Lookup("delete").StringValue())
+		}
+		assert.Equal(t, []string{"messages", "conversation_contexts", "ai_memories", "conversations"}, collections)
+	})
+}
+
+func TestMessageReactions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	messageID := primitive.NewObjectID()
+	conversationID := primitive.NewObjectID()
+	messageAndConversation := func() []bson.D {
+		return []bson.D{
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, bson.D{{Key: "_id", Value: messageID}, {Key: "conversation_id", Value: conversationID}}),
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: conversationID}, {Key: "companion_id", Value: "companion-1"}}),
+		}
+	}
+	reactedAt := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) // a Wednesday
+	previousReaction := bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "message_id", Value: messageID},
+		{Key: "user_id", Value: "user-1"},
+		{Key: "companion_id", Value: "companion-1"},
+		{Key: "emoji", Value: "😂"},
+		{Key: "created_at", Value: reactedAt},
+	}
+
+	// updatesTo returns the collection and update document of each update command after the lookups
+	updatesTo := func(mt *mtest.T) ([]string, []bson.Raw) {
+		var collections []string
+		var updates []bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName != "update" {
+				continue
+			}
+			collections = append(collections, event.Command.Lookup("update").StringValue())
+			updates = append(updates, event.Command.Lookup("updates").Array().Index(0).Value().Document())
+		}
+		return collections, updates
+	}
+
+	mt.Run("first reaction increments the message and weekly analytics", func(mt *mtest.T) {
+		mt.AddMockResponses(append(messageAndConversation(),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)...)
+
+		require.NoError(t, NewConversationRepository(mt.DB).AddReaction(context.Background(), messageID, "user-1", "❤️"))
+
+		collections, updates := updatesTo(mt)
+		assert.Equal(t, []string{"messages", "reaction_analytics"}, collections)
+		assert.Equal(t, int32(1), updates[0].Lookup("u", "$inc", "reactions.❤️").Int32())
+		assert.Equal(t, "companion-1", updates[1].Lookup("q", "companion_id").StringValue())
+		assert.Equal(t, int32(1), updates[1].Lookup("u", "$inc", "count").Int32())
+		assert.True(t, updates[1].Lookup("upsert").Boolean())
+	})
+
+	mt.Run("count increments are only retried by the driver", func(mt *mtest.T) {
+		networkError := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Name: "InternalError", Message: "connection reset", Labels: []string{"NetworkError"}})
+		mt.AddMockResponses(append(messageAndConversation(),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			networkError,
+			networkError,
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)...)
+
+		err := NewConversationRepository(mt.DB).AddReaction(context.Background(), messageID, "user-1", "❤️")
+		require.Error(t, err)
+
+		// The increment may have applied, so it is only resent as the driver's retryable write, which the server
+		// recognises by its transaction number
+		var txnNumbers []int64
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "update" {
+				assert.Equal(t, "messages", event.Command.Lookup("update").StringValue())
+				txnNumbers = append(txnNumbers, event.Command.Lookup("txnNumber").Int64())
+			}
+		}
+		require.Len(t, txnNumbers, 2)
+		assert.Equal(t, txnNumbers[0], txnNumbers[1])
+	})
+
+	mt.Run("adding the same reaction again is idempotent", func(mt *mtest.T) {
+		mt.AddMockResponses(append(messageAndConversation(),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Name: "DuplicateKey", Message: "E11000 duplicate key error collection: lunaria.message_reactions index: idx_message_reactions_message_user"}),
+		)...)
+
+		require.NoError(t, NewConversationRepository(mt.DB).AddReaction(context.Background(), messageID, "user-1", "😂"))
+
+		collections, _ := updatesTo(mt)
+		assert.Empty(t, collections, "counts are left alone")
+	})
+
+	mt.Run("a different emoji replaces the user's reaction", func(mt *mtest.T) {
+		mt.AddMockResponses(append(messageAndConversation(),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: previousReaction}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)...)
+
+		require.NoError(t, NewConversationRepository(mt.DB).AddReaction(context.Background(), messageID, "user-1", "❤️"))
+
+		collections, updates := updatesTo(mt)
+		assert.Equal(t, []string{"messages", "messages", "reaction_analytics", "messages", "reaction_analytics"}, collections)
+		assert.Equal(t, int32(-1), updates[0].Lookup("u", "$inc", "reactions.😂").Int32())
+		assert.Equal(t, "", updates[1].Lookup("u", "$unset", "reactions.😂").StringValue())
+		assert.Equal(t, time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC), updates[2].Lookup("q", "week_start").Time().UTC(), "the old reaction is taken off the week it was made in")
+		assert.Equal(t, int32(1), updates[3].Lookup("u", "$inc", "reactions.❤️").Int32())
+	})
+
+	mt.Run("remove decrements the counts", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: previousReaction}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(t, NewConversationRepository(mt.DB).RemoveReaction(context.Background(), messageID, "user-1", "😂"))
+
+		remove := mt.GetStartedEvent()
+		assert.Equal(t, "findAndModify", remove.CommandName)
+		assert.True(t, remove.Command.Lookup("remove").Boolean())
+		assert.Equal(t, "😂", remove.Command.Lookup("query", "emoji").StringValue())
+
+		collections, updates := updatesTo(mt)
+		assert.Equal(t, []string{"messages", "messages", "reaction_analytics"}, collections)
+		assert.Equal(t, int32(-1), updates[2].Lookup("u", "$inc", "count").Int32())
+	})
+
+	mt.Run("removing a missing reaction does nothing", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		require.NoError(t, NewConversationRepository(mt.DB).RemoveReaction(context.Background(), messageID, "user-1", "😂"))
+
+		collections, _ := updatesTo(mt)
+		assert.Empty(t, collections)
+	})
+}
+
+func TestForkConversation(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("copies messages up to the fork point with memories and context", func(mt *mtest.T) {
+		originalID := primitive.NewObjectID()
+		messageIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+		forkPoint := messageIDs[2]
+		memoryID := primitive.NewObjectID()
+		laterMessageID := primitive.NewObjectID()
+		createdAt := time.Now().Add(-time.Hour)
+
+		messages := make([]bson.D, len(messageIDs))
+		for i, id := range messageIDs {
+			messages[i] = bson.D{{Key: "_id", Value: id}, {Key: "conversation_id", Value: originalID}, {Key: "type", Value: "text"}, {Key: "created_at", Value: createdAt}}
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: originalID}, {Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "companion"}}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, messages[2]),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, messages...),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "lunaria.ai_memories", mtest.FirstBatch, bson.D{{Key: "_id", Value: memoryID}, {Key: "conversation_id", Value: originalID}, {Key: "content", Value: "likes hiking"}}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: originalID},
+				{Key: "active_memories", Value: bson.A{bson.D{{Key: "_id", Value: memoryID}, {Key: "content", Value: "likes hiking"}}}},
+				{Key: "emotional_history", Value: bson.A{
+					bson.D{{Key: "message_id", Value: messageIDs[1]}, {Key: "emotional_state", Value: bson.D{{Key: "primary_emotion", Value: "calm"}}}},
+					bson.D{{Key: "message_id", Value: laterMessageID}, {Key: "emotional_state", Value: bson.D{{Key: "primary_emotion", Value: "angry"}}}},
+				}},
+				{Key: "user_emotional_state", Value: bson.D{{Key: "primary_emotion", Value: "angry"}}},
+				{Key: "current_topic", Value: "breakup"},
+				{Key: "topic_history", Value: bson.A{"general", "work"}},
+				{Key: "relationship_stage", Value: "close_friend"},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		fork, err := NewConversationRepository(mt.DB).ForkConversation(context.Background(), originalID, forkPoint, "user")
+		require.NoError(t, err)
+		require.NotNil(t, fork.ForkedFrom)
+		assert.Equal(t, originalID, fork.ForkedFrom.ConversationID)
+		assert.Equal(t, forkPoint, fork.ForkedFrom.MessageID)
+		assert.NotEqual(t, originalID, fork.ID)
+
+		inserted := map[string][]bson.RawValue{}
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "insert" {
+				collection := event.Command.Lookup("insert").StringValue()
+				docs, err := event.Command.Lookup("documents").Array().Values()
+				require.NoError(t, err)
+				inserted[collection] = append(inserted[collection], docs...)
+			}
+		}
+
+		require.Len(t, inserted["messages"], len(messageIDs), "every message up to the fork point is copied")
+		for _, doc := range inserted["messages"] {
+			assert.Equal(t, fork.ID, doc.Document().Lookup("conversation_id").ObjectID())
+			assert.NotContains(t, messageIDs, doc.Document().Lookup("_id").ObjectID())
+		}
+
+		require.Len(t, inserted["ai_memories"], 1)
+		copiedMemoryID := inserted["ai_memories"][0].Document().Lookup("_id").ObjectID()
+		assert.NotEqual(t, memoryID, copiedMemoryID)
+
+		require.Len(t, inserted["conversation_contexts"], 1)
+		forkContext := inserted["conversation_contexts"][0].Document()
+		assert.Equal(t, fork.ID, forkContext.Lookup("conversation_id").ObjectID())
+		assert.Equal(t, copiedMemoryID, forkContext.Lookup("active_memories").Array().Index(0).Value().Document().Lookup("_id").ObjectID())
+		history, err := forkContext.Lookup("emotional_history").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, history, 1, "snapshots of messages after the fork point are left out")
+		assert.Equal(t, messageIDs[1], history[0].Document().Lookup("message_id").ObjectID())
+		assert.Equal(t, "calm", forkContext.Lookup("user_emotional_state", "primary_emotion").StringValue())
+		assert.Equal(t, models.InitialTopic, forkContext.Lookup("current_topic").StringValue(), "topics start afresh")
+		topics, err := forkContext.Lookup("topic_history").Array().Values()
+		require.NoError(t, err)
+		assert.Empty(t, topics)
+		assert.Equal(t, "close_friend", forkContext.Lookup("relationship_stage").StringValue())
+
+		require.Len(t, inserted["conversations"], 1)
+		assert.Equal(t, originalID, inserted["conversations"][0].Document().Lookup("forked_from", "conversation_id").ObjectID())
+	})
+
+	mt.Run("refuses to fork another user's conversation", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "user_id", Value: "someone-else"}}))
+
+		_, err := NewConversationRepository(mt.DB).ForkConversation(context.Background(), primitive.NewObjectID(), primitive.NewObjectID(), "user")
+		var notFound *apperrors.NotFoundError
+		assert.ErrorAs(t, err, &notFound)
+	})
+}
+
+func TestGetCompanionHistoryStats(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("summarises conversations, messages and emotions", func(mt *mtest.T) {
+		first := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: first.Add(48 * time.Hour)}},
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: first}},
+			),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, bson.D{
+				{Key: "longest", Value: bson.A{bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "count", Value: 42}}}},
+				{Key: "hours", Value: bson.A{bson.D{{Key: "_id", Value: 21}, {Key: "count", Value: 17}}}},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{{Key: "_id", Value: "joy"}, {Key: "count", Value: 5}}),
+		)
+
+		stats, err := NewConversationRepository(mt.DB).GetCompanionHistoryStats(context.Background(), "user-1", "companion-1")
+		require.NoError(t, err)
+		require.NotNil(t, stats.FirstConversationAt)
+		assert.Equal(t, first, stats.FirstConversationAt.UTC())
+		assert.Equal(t, 42, stats.LongestConversation)
+		require.NotNil(t, stats.FavouriteHour)
+		assert.Equal(t, 21, *stats.FavouriteHour)
+		assert.Equal(t, "joy", stats.TopEmotion)
+
+		events := mt.GetAllStartedEvents()
+		var collections []string
+		for _, event := range events {
+			collections = append(collections, event.Command.Lookup(event.CommandName).StringValue())
+		}
+		assert.Equal(t, []string{"conversations", "messages", "conversation_contexts"}, collections)
+		assert.Equal(t, bson.TypeNull, events[0].Command.Lookup("filter", "deleted_at").Type, "soft-deleted conversations are left out")
+	})
+
+	mt.Run("a companion with no conversations has empty stats", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch))
+
+		stats, err := NewConversationRepository(mt.DB).GetCompanionHistoryStats(context.Background(), "user-1", "companion-1")
+		require.NoError(t, err)
+		assert.Nil(t, stats.FirstConversationAt)
+		assert.Nil(t, stats.FavouriteHour)
+		assert.Zero(t, stats.LongestConversation)
+		assert.Len(t, mt.GetAllStartedEvents(), 1)
+	})
+}
+
+func TestGetConversationMessageCount(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("counts messages in the conversation", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.messages", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(501)}}))
+
+		repo := NewConversationRepository(mt.DB)
+		count, err := repo.GetConversationMessageCount(context.Background(), primitive.NewObjectID())
+		require.NoError(t, err)
+		assert.Equal(t, 501, count)
+	})
+}
+
+func TestMarkMessagesRead(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("marks a batch in one update", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		messageIDs := make([]primitive.ObjectID, 50)
+		for i := range messageIDs {
+			messageIDs[i] = primitive.NewObjectID()
+		}
+		// 40 of the 50 are unread companion messages
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversations", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(1)}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 40}, bson.E{Key: "nModified", Value: 40}),
+		)
+
+		count, err := NewConversationRepository(mt.DB).MarkMessagesRead(context.Background(), conversationID, "user", messageIDs)
+		require.NoError(t, err)
+		assert.Equal(t, 40, count)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 2)
+		assert.Equal(t, "update", events[1].CommandName)
+		update := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.True(t, update.Lookup("multi").Boolean())
+		ids, err := update.Lookup("q", "_id", "$in").Array().Values()
+		require.NoError(t, err)
+		assert.Len(t, ids, 50)
+		assert.Equal(t, conversationID, update.Lookup("q", "conversation_id").ObjectID())
+		assert.Equal(t, string(sendertype.Companion), update.Lookup("q", "sender_type").StringValue())
+		assert.True(t, update.Lookup("u", "$set", "read").Boolean())
+	})
+
+	mt.Run("rejects conversations owned by someone else", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.conversations", mtest.FirstBatch))
+
+		_, err := NewConversationRepository(mt.DB).MarkMessagesRead(context.Background(), primitive.NewObjectID(), "intruder", []primitive.ObjectID{primitive.NewObjectID()})
+		var notFound *apperrors.NotFoundError
+		assert.ErrorAs(t, err, &notFound)
+		assert.Len(t, mt.GetAllStartedEvents(), 1, "no update is sent")
+	})
+}
+
+func TestResetConversationContext(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	for _, preserveMemories := range []bool{true, false} {
+		name := "clears memories"
+		if preserveMemories {
+			name = "preserves memories"
+		}
+
+		mt.Run(name, func(mt *mtest.T) {
+			conversationID := primitive.NewObjectID()
+			current := &models.ConversationContext{
+				ID:                primitive.NewObjectID(),
+				ConversationID:    conversationID,
+				RelationshipStage: "close_friends",
+				TrustLevel:        0.9,
+				IntimacyLevel:     0.8,
+				ActiveMemories:    []models.AIEnhancedMemoryEntry{{Content: "has a dog named Max"}, {Content: "works night shifts"}},
+			}
+			doc := toBSON(t, current)
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, doc),
+				bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: doc}},
+				mtest.CreateSuccessResponse(),
+				mtest.CreateSuccessResponse(),
+			)
+
+			repo := NewConversationRepository(mt.DB)
+			resetBefore := time.Now().Add(-24 * time.Hour)
+			require.NoError(t, repo.ResetConversationContext(context.Background(), conversationID, preserveMemories, resetBefore))
+
+			events := mt.GetAllStartedEvents()
+			require.Len(t, events, 4)
+
+			guard, err := events[1].Command.Lookup("query", "$or").Array().Values()
+			require.NoError(t, err)
+			require.Len(t, guard, 2)
+			assert.Equal(t, bson.TypeNull, guard[0].Document().Lookup("last_reset_at").Type, "a context never reset may be reset")
+			assert.Equal(t, resetBefore.UnixMilli(), guard[1].Document().Lookup("last_reset_at", "$lt").Time().UnixMilli())
+			assert.False(t, events[1].Command.Lookup("upsert").Boolean())
+
+			set := events[1].Command.Lookup("update", "$set").Document()
+			assert.Equal(t, models.InitialRelationshipStage, set.Lookup("relationship_stage").StringValue())
+			assert.Equal(t, models.InitialTrustLevel, set.Lookup("trust_level").Double())
+			assert.Equal(t, models.InitialIntimacyLevel, set.Lookup("intimacy_level").Double())
+			assert.Equal(t, bson.TypeDateTime, set.Lookup("last_reset_at").Type)
+			if preserveMemories {
+				_, err := set.LookupErr("active_memories")
+				assert.Error(t, err, "unchanged memories are not rewritten")
+			} else {
+				memories, err := set.Lookup("active_memories").Array().Values()
+				require.NoError(t, err)
+				assert.Empty(t, memories)
+			}
+
+			contextEvent := events[2].Command.Lookup("documents").Array().Index(0).Value().Document()
+			assert.Equal(t, models.ContextEventReset, contextEvent.Lookup("event_type").StringValue())
+
+			assert.Equal(t, "messages", events[3].Command.Lookup("insert").StringValue())
+			message := events[3].Command.Lookup("documents").Array().Index(0).Value().Document()
+			assert.Equal(t, string(messagetype.System), message.Lookup("type").StringValue())
+			assert.Equal(t, models.SystemEventContextReset, message.Lookup("system_event", "event_type").StringValue())
+		})
+	}
+
+	mt.Run("missing context", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch))
+
+		err := NewConversationRepository(mt.DB).ResetConversationContext(context.Background(), primitive.NewObjectID(), false, time.Now())
+		var notFound *apperrors.NotFoundError
+		assert.ErrorAs(t, err, &notFound)
+	})
+
+	mt.Run("reset since resetBefore", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		doc := toBSON(t, &models.ConversationContext{ID: primitive.NewObjectID(), ConversationID: conversationID, TrustLevel: 0.9})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, doc),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+		)
+
+		err := NewConversationRepository(mt.DB).ResetConversationContext(context.Background(), conversationID, false, time.Now().Add(-24*time.Hour))
+
+		var conflict *apperrors.ConflictError
+		assert.ErrorAs(t, err, &conflict)
+		assert.Len(t, mt.GetAllStartedEvents(), 2, "no event or system message is written")
+	})
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"errors"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// findOneError maps a FindOne failure to a NotFoundError or DatabaseError for what was looked up
+func findOneError(err error, what string) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return apperrors.NewNotFoundError(what+" not found", err)
+	}
+	return apperrors.NewDatabaseError("failed to get "+what, err)
+}
+
+// isDuplicateKeyError reports whether err is a write rejected by a unique index, either as a write error
+// from an insert or as the command error returned by an upserting findAndModify
+func isDuplicateKeyError(err error) bool {
+	return mongo.IsDuplicateKeyError(err)
+}
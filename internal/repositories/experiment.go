@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ExperimentRepository struct {
+	db *mongo.Database
+}
+
+func NewExperimentRepository(db *mongo.Database) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+// ListActiveExperiments returns every active experiment definition
+func (r *ExperimentRepository) ListActiveExperiments(ctx context.Context) ([]models.Experiment, error) {
+	collection := r.db.Collection("ab_experiments")
+
+	cursor, err := collection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var experiments []models.Experiment
+	if err := cursor.All(ctx, &experiments); err != nil {
+		return nil, fmt.Errorf("failed to decode experiments: %w", err)
+	}
+
+	return experiments, nil
+}
+
+// InsertABTestResult records a quality score for an experiment variant
+func (r *ExperimentRepository) InsertABTestResult(ctx context.Context, result *models.ABTestResult) error {
+	collection := r.db.Collection("ab_test_results")
+
+	if _, err := collection.InsertOne(ctx, result); err != nil {
+		return fmt.Errorf("failed to insert ab test result: %w", err)
+	}
+
+	return nil
+}
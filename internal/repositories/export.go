@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExportRepository struct {
+	db *mongo.Database
+}
+
+func NewExportRepository(db *mongo.Database) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+// CreateExportJob queues a new export job
+func (r *ExportRepository) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	job.ID = primitive.NewObjectID()
+	job.Status = models.ExportStatusQueued
+	job.CreatedAt = time.Now()
+
+	_, err := r.db.Collection("export_jobs").InsertOne(ctx, job)
+	if err != nil {
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimNextExportJob marks the oldest queued job as processing and returns it, or nil if the queue is empty.
+// The claim is a single atomic update, so concurrent workers never pick up the same job.
+func (r *ExportRepository) ClaimNextExportJob(ctx context.Context) (*models.ExportJob, error) {
+	filter := bson.M{"status": models.ExportStatusQueued}
+	update := bson.M{"$set": bson.M{"status": models.ExportStatusProcessing, "started_at": time.Now()}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ExportJob
+	err := r.db.Collection("export_jobs").FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim export job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// CompleteExportJob records where a finished export was stored
+func (r *ExportRepository) CompleteExportJob(ctx context.Context, id primitive.ObjectID, objectKey, downloadURL string) error {
+	return r.finishExportJob(ctx, id, bson.M{
+		"status":       models.ExportStatusCompleted,
+		"object_key":   objectKey,
+		"download_url": downloadURL,
+	})
+}
+
+// FailExportJob records why an export could not be produced
+func (r *ExportRepository) FailExportJob(ctx context.Context, id primitive.ObjectID, message string) error {
+	return r.finishExportJob(ctx, id, bson.M{
+		"status": models.ExportStatusFailed,
+		"error":  message,
+	})
+}
+
+func (r *ExportRepository) finishExportJob(ctx context.Context, id primitive.ObjectID, set bson.M) error {
+	set["completed_at"] = time.Now()
+	err := mongodb.WithRetry(ctx, mongoWriteAttempts, func() error {
+		_, err := r.db.Collection("export_jobs").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+
+	return nil
+}
+
+// GetExportJob returns an export job by ID
+func (r *ExportRepository) GetExportJob(ctx context.Context, id primitive.ObjectID) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := r.db.Collection("export_jobs").FindOne(ctx, bson.M{"_id": id}).Decode(&job); err != nil {
+		return nil, findOneError(err, "export job")
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestClaimNextExportJob(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("claims the oldest queued job", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{{Key: "_id", Value: id}, {Key: "user_id", Value: "user"}, {Key: "status", Value: models.ExportStatusProcessing}}},
+		})
+
+		job, err := NewExportRepository(mt.DB).ClaimNextExportJob(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, job)
+		assert.Equal(t, id, job.ID)
+
+		started := mt.GetStartedEvent()
+		require.NotNil(t, started)
+		assert.Equal(t, "findAndModify", started.CommandName)
+		assert.Equal(t, models.ExportStatusQueued, started.Command.Lookup("query", "status").StringValue())
+		assert.Equal(t, models.ExportStatusProcessing, started.Command.Lookup("update", "$set", "status").StringValue())
+
+		sort, err := started.Command.Lookup("sort").Document().Elements()
+		require.NoError(t, err)
+		require.Len(t, sort, 2)
+		assert.Equal(t, "created_at", sort[0].Key())
+		assert.Equal(t, int32(1), sort[0].Value().Int32())
+		assert.Equal(t, "_id", sort[1].Key())
+	})
+
+	mt.Run("returns nil when the queue is empty", func(mt *mtest.T) {
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		job, err := NewExportRepository(mt.DB).ClaimNextExportJob(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, job)
+	})
+}
+
+func TestCreateExportJob(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("insert is only retried by the driver", func(mt *mtest.T) {
+		networkError := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Name: "InternalError", Message: "connection reset", Labels: []string{"NetworkError"}})
+		mt.AddMockResponses(networkError, networkError, mtest.CreateSuccessResponse())
+
+		err := NewExportRepository(mt.DB).CreateExportJob(context.Background(), &models.ExportJob{UserID: "user"})
+		require.Error(t, err)
+
+		// The job may have been stored, so the insert is only resent as the driver's retryable write, which the
+		// server recognises by its transaction number instead of rejecting the job's _id as a duplicate
+		var txnNumbers []int64
+		for _, event := range mt.GetAllStartedEvents() {
+			assert.Equal(t, "export_jobs", event.Command.Lookup("insert").StringValue())
+			txnNumbers = append(txnNumbers, event.Command.Lookup("txnNumber").Int64())
+		}
+		require.Len(t, txnNumbers, 2)
+		assert.Equal(t, txnNumbers[0], txnNumbers[1])
+	})
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+type FeatureFlagRepository struct {
+	db *sql.DB
+}
+
+func NewFeatureFlagRepository(db *sql.DB) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+// GetFlag returns a feature flag by name
+func (r *FeatureFlagRepository) GetFlag(ctx context.Context, flagName string) (*models.FeatureFlag, error) {
+	query := `
+		SELECT flag_name, enabled_globally, rollout_percentage, created_at, updated_at
+		FROM feature_flags
+		WHERE flag_name = $1`
+	var flag models.FeatureFlag
+	err := r.db.QueryRowContext(ctx, query, flagName).Scan(
+		&flag.FlagName, &flag.EnabledGlobally, &flag.RolloutPercentage, &flag.CreatedAt, &flag.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("feature flag not found", err)
+		}
+		return nil, apperrors.NewDatabaseError("failed to get feature flag", err)
+	}
+	return &flag, nil
+}
+
+// UpsertFlag creates a feature flag or updates its state
+func (r *FeatureFlagRepository) UpsertFlag(ctx context.Context, flag *models.FeatureFlag) (*models.FeatureFlag, error) {
+	query := `
+		INSERT INTO feature_flags (flag_name, enabled_globally, rollout_percentage, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (flag_name) DO UPDATE
+		SET enabled_globally = EXCLUDED.enabled_globally, rollout_percentage = EXCLUDED.rollout_percentage, updated_at = NOW()
+		RETURNING created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, flag.FlagName, flag.EnabledGlobally, flag.RolloutPercentage).
+		Scan(&flag.CreatedAt, &flag.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert feature flag: %w", err)
+	}
+	return flag, nil
+}
+
+// GetUserOverride returns a user's override of a feature flag
+func (r *FeatureFlagRepository) GetUserOverride(ctx context.Context, flagName string, userID uuid.UUID) (*models.UserFlagOverride, error) {
+	query := `
+		SELECT user_id, flag_name, enabled
+		FROM user_flag_overrides
+		WHERE flag_name = $1 AND user_id = $2`
+	var override models.UserFlagOverride
+	err := r.db.QueryRowContext(ctx, query, flagName, userID).Scan(&override.UserID, &override.FlagName, &override.Enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("feature flag override not found", err)
+		}
+		return nil, apperrors.NewDatabaseError("failed to get feature flag override", err)
+	}
+	return &override, nil
+}
+
+// SetUserOverride turns a feature flag on or off for one user
+func (r *FeatureFlagRepository) SetUserOverride(ctx context.Context, override *models.UserFlagOverride) error {
+	query := `
+		INSERT INTO user_flag_overrides (user_id, flag_name, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, flag_name) DO UPDATE SET enabled = EXCLUDED.enabled`
+	if _, err := r.db.ExecContext(ctx, query, override.UserID, override.FlagName, override.Enabled); err != nil {
+		return fmt.Errorf("failed to set feature flag override: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserOverride returns a user to the flag's rollout
+func (r *FeatureFlagRepository) DeleteUserOverride(ctx context.Context, flagName string, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_flag_overrides WHERE flag_name = $1 AND user_id = $2`, flagName, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag override: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag override: %w", err)
+	}
+	if rows == 0 {
+		return apperrors.NewNotFoundError("feature flag override not found", nil)
+	}
+	return nil
+}
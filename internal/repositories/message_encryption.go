@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/crypto"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// errMessageCipherMissing is returned when an encrypted message is read by a repository without a cipher
+var errMessageCipherMissing = errors.New("message text is encrypted but message encryption is not configured")
+
+// WithMessageCipher returns a copy of the repository that encrypts message text on write and decrypts it on read.
+// Messages stored before encryption was enabled are still read as plaintext.
+func (r *ConversationRepository) WithMessageCipher(cipher *crypto.MessageCipher) *ConversationRepository {
+	return &ConversationRepository{db: r.db, opts: r.opts, cipher: cipher}
+}
+
+// sealMessage returns the document to store for msg: a copy with the text encrypted under the conversation's key,
+// or msg itself when encryption is off or there is no text
+func (r *ConversationRepository) sealMessage(msg *models.Message) (*models.Message, error) {
+	if r.cipher == nil || msg.Text == nil {
+		return msg, nil
+	}
+	ciphertext, nonce, err := r.cipher.Encrypt(msg.ConversationID.Hex(), *msg.Text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	sealed := *msg
+	sealed.Text = nil
+	sealed.TextEncrypted = ciphertext
+	sealed.TextNonce = nonce
+	return &sealed, nil
+}
+
+// openMessage decrypts a stored message's text in place
+func (r *ConversationRepository) openMessage(msg *models.Message) error {
+	if len(msg.TextEncrypted) == 0 {
+		return nil
+	}
+	if r.cipher == nil {
+		return errMessageCipherMissing
+	}
+	text, err := r.cipher.Decrypt(msg.ConversationID.Hex(), msg.TextEncrypted, msg.TextNonce)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt message %s: %w", msg.ID.Hex(), err)
+	}
+	msg.Text = &text
+	msg.TextEncrypted = nil
+	msg.TextNonce = nil
+	return nil
+}
+
+// OpenMessage decrypts the text of a message read as stored, such as one from ForEachStoredMessage or an archive
+func (r *ConversationRepository) OpenMessage(msg *models.Message) error {
+	return r.openMessage(msg)
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/crypto"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMessageEncryption(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	cipher, err := crypto.NewMessageCipher(bytes.Repeat([]byte{9}, crypto.KeySize))
+	require.NoError(t, err)
+
+	mt.Run("encrypts on write and decrypts on read", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB).WithMessageCipher(cipher)
+		text := "I lost my job today"
+		msg := &models.Message{ConversationID: primitive.NewObjectID(), Type: messagetype.Text, Text: &text}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		stored, _, err := repo.CreateMessage(context.Background(), msg)
+		require.NoError(t, err)
+		assert.Equal(t, text, *stored.Text)
+
+		inserted := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		_, hasText := inserted.Lookup("text").StringValueOK()
+		assert.False(t, hasText)
+		_, ciphertext := inserted.Lookup("text_encrypted").Binary()
+		_, nonce := inserted.Lookup("text_nonce").Binary()
+		assert.NotEmpty(t, ciphertext)
+		assert.NotEmpty(t, nonce)
+		assert.NotContains(t, string(ciphertext), text)
+
+		var doc bson.D
+		require.NoError(t, bson.Unmarshal(inserted, &doc))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, doc))
+		read, err := repo.GetMessageByID(context.Background(), msg.ID)
+		require.NoError(t, err)
+		require.NotNil(t, read.Text)
+		assert.Equal(t, text, *read.Text)
+		assert.Empty(t, read.TextEncrypted)
+	})
+
+	mt.Run("tampered ciphertext fails to read", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB).WithMessageCipher(cipher)
+		conversationID := primitive.NewObjectID()
+		ciphertext, nonce, err := cipher.Encrypt(conversationID.Hex(), "secret")
+		require.NoError(t, err)
+		ciphertext[0] ^= 0x01
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSON(t, models.Message{
+			ID:             primitive.NewObjectID(),
+			ConversationID: conversationID,
+			TextEncrypted:  ciphertext,
+			TextNonce:      nonce,
+		})))
+		_, err = repo.GetMessageByID(context.Background(), primitive.NewObjectID())
+		assert.ErrorIs(t, err, crypto.ErrDecrypt)
+	})
+
+	mt.Run("plaintext messages from before encryption still read", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB).WithMessageCipher(cipher)
+		text := "hello"
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSON(t, models.Message{
+			ID:             primitive.NewObjectID(),
+			ConversationID: primitive.NewObjectID(),
+			Text:           &text,
+		})))
+		read, err := repo.GetMessageByID(context.Background(), primitive.NewObjectID())
+		require.NoError(t, err)
+		assert.Equal(t, text, *read.Text)
+	})
+
+	mt.Run("encrypted messages need a cipher", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB)
+		conversationID := primitive.NewObjectID()
+		ciphertext, nonce, err := cipher.Encrypt(conversationID.Hex(), "secret")
+		require.NoError(t, err)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSON(t, models.Message{
+			ID:             primitive.NewObjectID(),
+			ConversationID: conversationID,
+			TextEncrypted:  ciphertext,
+			TextNonce:      nonce,
+		})))
+		_, err = repo.GetMessageByID(context.Background(), primitive.NewObjectID())
+		assert.ErrorIs(t, err, errMessageCipherMissing)
+	})
+	mt.Run("stored messages stay sealed until opened", func(mt *mtest.T) {
+		repo := NewConversationRepository(mt.DB).WithMessageCipher(cipher)
+		conversationID := primitive.NewObjectID()
+		ciphertext, nonce, err := cipher.Encrypt(conversationID.Hex(), "secret")
+		require.NoError(t, err)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSON(t, models.Message{
+			ID:             primitive.NewObjectID(),
+			ConversationID: conversationID,
+			TextEncrypted:  ciphertext,
+			TextNonce:      nonce,
+		})))
+		var stored []*models.Message
+		require.NoError(t, repo.ForEachStoredMessage(context.Background(), conversationID, func(msg *models.Message) error {
+			stored = append(stored, msg)
+			return nil
+		}))
+		require.Len(t, stored, 1)
+		assert.Nil(t, stored[0].Text)
+		assert.Equal(t, ciphertext, stored[0].TextEncrypted)
+
+		require.NoError(t, repo.OpenMessage(stored[0]))
+		assert.Equal(t, "secret", *stored[0].Text)
+		assert.Empty(t, stored[0].TextEncrypted)
+	})
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type NotificationRepository struct {
+	postgresDB *sql.DB
+	mongoDB    *mongo.Database
+}
+
+func NewNotificationRepository(postgresDB *sql.DB, mongoDB *mongo.Database) *NotificationRepository {
+	return &NotificationRepository{
+		postgresDB: postgresDB,
+		mongoDB:    mongoDB,
+	}
+}
+
+func (r *NotificationRepository) CreatePreferences(ctx context.Context, prefs *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	query := `
+		INSERT INTO notification_preferences (user_id, email_enabled, push_enabled, in_app_enabled, alert_threshold, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING created_at, updated_at`
+	err := r.postgresDB.QueryRowContext(ctx, query, prefs.UserID, prefs.EmailEnabled, prefs.PushEnabled, prefs.InAppEnabled, prefs.AlertThreshold).
+		Scan(&prefs.CreatedAt, &prefs.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	query := `
+		SELECT user_id, email_enabled, push_enabled, in_app_enabled, alert_threshold, created_at, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1`
+	var prefs models.NotificationPreferences
+	err := r.postgresDB.QueryRowContext(ctx, query, userID).Scan(
+		&prefs.UserID, &prefs.EmailEnabled, &prefs.PushEnabled, &prefs.InAppEnabled, &prefs.AlertThreshold,
+		&prefs.CreatedAt, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("notification preferences not found", err)
+		}
+		return nil, apperrors.NewDatabaseError("failed to get notification preferences", err)
+	}
+	return &prefs, nil
+}
+
+func (r *NotificationRepository) UpdatePreferences(ctx context.Context, prefs *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	query := `
+		UPDATE notification_preferences
+		SET email_enabled = $2, push_enabled = $3, in_app_enabled = $4, alert_threshold = $5, updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING created_at, updated_at`
+	err := r.postgresDB.QueryRowContext(ctx, query, prefs.UserID, prefs.EmailEnabled, prefs.PushEnabled, prefs.InAppEnabled, prefs.AlertThreshold).
+		Scan(&prefs.CreatedAt, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("notification preferences not found", err)
+		}
+		return nil, apperrors.NewDatabaseError("failed to update notification preferences", err)
+	}
+	return prefs, nil
+}
+
+func (r *NotificationRepository) DeletePreferences(ctx context.Context, userID uuid.UUID) error {
+	result, err := r.postgresDB.ExecContext(ctx, `DELETE FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification preferences: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete notification preferences: %w", err)
+	}
+	if rows == 0 {
+		return apperrors.NewNotFoundError("notification preferences not found", nil)
+	}
+	return nil
+}
+
+// InsertNotification stores an in-app notification
+func (r *NotificationRepository) InsertNotification(ctx context.Context, notification *models.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+
+	if _, err := r.mongoDB.Collection("notifications").InsertOne(ctx, notification); err != nil {
+		return fmt.Errorf("failed to insert notification: %w", err)
+	}
+	return nil
+}
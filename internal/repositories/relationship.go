@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 )
 
@@ -42,10 +44,10 @@ func (r *RelationshipRepository) GetByUserAndCompanion(ctx context.Context, user
 		&rel.ID, &rel.UserID, &rel.CompanionID, &rel.RelationshipStage, &rel.IntimacyLevel, &rel.MessageCount,
 		&rel.LastInteractionAt, &rel.RelationshipStartedAt, &rel.CreatedAt, &rel.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("relationship not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("relationship not found", err)
 		}
-		return nil, fmt.Errorf("failed to get relationship: %w", err)
+		return nil, apperrors.NewDatabaseError("failed to get relationship", err)
 	}
 	return rel, nil
 }
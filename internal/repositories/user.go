@@ -3,13 +3,20 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 )
 
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -20,19 +27,20 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
 	query := `
-		INSERT INTO users (id, email, password_hash, name, age, gender, avatar_url, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		INSERT INTO users (id, email, password_hash, name, age, gender, avatar_url, is_active, is_test_user, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
 		RETURNING id, created_at, updated_at`
 	user.ID = uuid.New()
 	err := r.db.QueryRowContext(ctx, query,
 		user.ID, user.Email, user.PasswordHash, user.Name,
-		user.Age, user.Gender, user.AvatarURL, user.IsActive).
+		user.Age, user.Gender, user.AvatarURL, user.IsActive, user.IsTestUser).
 		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key") {
-			return nil, fmt.Errorf("user with email %s already exists", user.Email)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return nil, apperrors.NewConflictError(fmt.Sprintf("user with email %s already exists", user.Email), err)
 		}
-		return nil, err
+		return nil, apperrors.NewDatabaseError("failed to create user", err)
 	}
 	return user, nil
 }
@@ -40,18 +48,18 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) (*models
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, password_hash, name, age, gender, avatar_url, is_active, created_at, updated_at
+		SELECT id, email, password_hash, name, age, gender, avatar_url, is_active, permissions, created_at, updated_at
 		FROM users 
 		WHERE email = $1 AND is_active = true`
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive,
+		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive, &user.Permissions,
 		&user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("user not found", err)
 		}
-		return nil, err
+		return nil, apperrors.NewDatabaseError("failed to get user by email", err)
 	}
 	return user, nil
 }
@@ -59,18 +67,18 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, email, password_hash, name, age, gender, avatar_url, is_active, created_at, updated_at
+		SELECT id, email, password_hash, name, age, gender, avatar_url, is_active, permissions, created_at, updated_at
 		FROM users 
 		WHERE id = $1 AND is_active = true`
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Name,
-		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive,
+		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive, &user.Permissions,
 		&user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("user not found", err)
 		}
-		return nil, err
+		return nil, apperrors.NewDatabaseError("failed to get user", err)
 	}
 	return user, nil
 }
@@ -91,18 +99,83 @@ func (r *UserRepository) UpdateProfile(ctx context.Context, userID uuid.UUID, up
 		UPDATE users 
 		SET %s, updated_at = NOW()
 		WHERE id = $1 AND is_active = true
-		RETURNING id, email, name, age, gender, avatar_url, is_active, created_at, updated_at`,
+		RETURNING id, email, name, age, gender, avatar_url, is_active, permissions, created_at, updated_at`,
 		strings.Join(setParts, ", "))
 	user := &models.User{}
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&user.ID, &user.Email, &user.Name,
-		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive,
+		&user.Age, &user.Gender, &user.AvatarURL, &user.IsActive, &user.Permissions,
 		&user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.NewNotFoundError("user not found", err)
 		}
-		return nil, err
+		return nil, apperrors.NewDatabaseError("failed to update user", err)
 	}
 	return user, nil
 }
+
+// SetTestUser flags or unflags the user as a test account
+func (r *UserRepository) SetTestUser(ctx context.Context, userID uuid.UUID, isTestUser bool) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users
+		SET is_test_user = $2, updated_at = NOW()
+		WHERE id = $1`, userID, isTestUser)
+	if err != nil {
+		return apperrors.NewDatabaseError("failed to update test user flag", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return apperrors.NewDatabaseError("failed to update test user flag", err)
+	}
+	if rows == 0 {
+		return apperrors.NewNotFoundError("user not found", nil)
+	}
+	return nil
+}
+
+// ListTestUserIDs returns the IDs of all users flagged as test accounts
+func (r *UserRepository) ListTestUserIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM users WHERE is_test_user = true`)
+	if err != nil {
+		return nil, apperrors.NewDatabaseError("failed to list test users", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, apperrors.NewDatabaseError("failed to scan test user", err)
+		}
+		ids = append(ids, id.String())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperrors.NewDatabaseError("failed to list test users", err)
+	}
+	return ids, nil
+}
+
+// GetQuietHours returns the user's quiet hours, or nil when they have not set any
+func (r *UserRepository) GetQuietHours(ctx context.Context, userID uuid.UUID) (*models.QuietHours, error) {
+	var data []byte
+	err := r.db.QueryRowContext(ctx, `SELECT quiet_hours FROM user_preferences WHERE user_id = $1`, userID).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, apperrors.NewDatabaseError("failed to get quiet hours", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var quietHours models.QuietHours
+	if err := json.Unmarshal(data, &quietHours); err != nil {
+		return nil, apperrors.NewDatabaseError("failed to decode quiet hours", err)
+	}
+	if err := quietHours.Validate(); err != nil {
+		return nil, apperrors.NewDatabaseError("invalid quiet hours", err)
+	}
+	return &quietHours, nil
+}
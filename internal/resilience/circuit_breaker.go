@@ -0,0 +1,146 @@
+package resilience
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker rejects a call
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State represents the circuit breaker state
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// CircuitBreakerConfig configures a circuit breaker
+type CircuitBreakerConfig struct {
+	Name             string
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenInterval time.Duration
+}
+
+// Logger receives circuit breaker state changes; *slog.Logger and the services' loggers satisfy it
+type Logger interface {
+	Warn(msg string, args ...any)
+}
+
+// CircuitBreaker stops calling a failing dependency until it has had time to recover
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	logger Logger
+	now    func() time.Time
+
+	mu           sync.Mutex
+	state        State
+	failures     int
+	openedAt     time.Time
+	lastHalfOpen time.Time
+}
+
+// NewCircuitBreaker creates a new circuit breaker that logs state changes to logger, or slog.Default() if it is nil
+func NewCircuitBreaker(config CircuitBreakerConfig, logger Logger) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenInterval <= 0 {
+		config.HalfOpenInterval = 5 * time.Second
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &CircuitBreaker{
+		config: config,
+		logger: logger,
+		now:    time.Now,
+		state:  StateClosed,
+	}
+}
+
+// State returns the current circuit breaker state
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// Execute runs fn if the circuit allows it and records the outcome
+func (cb *CircuitBreaker) Execute(fn func() error) error {
+	if !cb.allow() {
+		return ErrCircuitOpen
+	}
+
+	err := fn()
+	cb.record(err)
+	return err
+}
+
+// allow reports whether a call may go through, moving from open to half-open when due
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	switch cb.state {
+	case StateOpen:
+		if now.Sub(cb.openedAt) < cb.config.OpenTimeout {
+			return false
+		}
+		cb.transition(StateHalfOpen)
+		cb.lastHalfOpen = now
+		return true
+	case StateHalfOpen:
+		if now.Sub(cb.lastHalfOpen) < cb.config.HalfOpenInterval {
+			return false
+		}
+		cb.lastHalfOpen = now
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker state from the outcome of a call
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		cb.failures = 0
+		if cb.state != StateClosed {
+			cb.transition(StateClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == StateHalfOpen || cb.failures >= cb.config.FailureThreshold {
+		cb.openedAt = cb.now()
+		if cb.state != StateOpen {
+			cb.transition(StateOpen)
+		}
+	}
+}
+
+// transition changes state and logs the change; callers must hold mu
+func (cb *CircuitBreaker) transition(to State) {
+	cb.logger.Warn("circuit breaker state change",
+		"breaker", cb.config.Name,
+		"from", string(cb.state),
+		"to", string(to),
+		"failures", cb.failures,
+	)
+	cb.state = to
+}
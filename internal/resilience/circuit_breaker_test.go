@@ -0,0 +1,59 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		Name:             "test",
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		HalfOpenInterval: 10 * time.Second,
+	}, nil)
+	cb.now = func() time.Time { return now }
+
+	failure := errors.New("upstream down")
+	calls := 0
+	failing := func() error { calls++; return failure }
+	succeeding := func() error { calls++; return nil }
+
+	assert.ErrorIs(t, cb.Execute(failing), failure)
+	assert.ErrorIs(t, cb.Execute(failing), failure)
+	assert.Equal(t, StateOpen, cb.State())
+
+	assert.ErrorIs(t, cb.Execute(succeeding), ErrCircuitOpen)
+	assert.Equal(t, 2, calls)
+
+	// A failed trial call re-opens the circuit
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, cb.Execute(failing), failure)
+	assert.Equal(t, StateOpen, cb.State())
+	assert.ErrorIs(t, cb.Execute(succeeding), ErrCircuitOpen)
+
+	// A successful trial call closes it again
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.Execute(succeeding))
+	assert.Equal(t, StateClosed, cb.State())
+	assert.Equal(t, 4, calls)
+}
+
+func TestCircuitBreakerHalfOpenInterval(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenInterval: 10 * time.Second}, nil)
+	cb.now = func() time.Time { return now }
+
+	_ = cb.Execute(func() error { return errors.New("boom") })
+	now = now.Add(time.Minute)
+	assert.True(t, cb.allow())
+	assert.Equal(t, StateHalfOpen, cb.State())
+
+	assert.False(t, cb.allow())
+	now = now.Add(10 * time.Second)
+	assert.True(t, cb.allow())
+}
@@ -5,16 +5,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sahmaragaev/lunaria-backend/internal/errors"
+	apihttp "github.com/sahmaragaev/lunaria-backend/internal/http"
 )
 
 type Response struct {
-	Status    int    `json:"status"`
-	Success   bool   `json:"success"`
-	Message   string `json:"message,omitempty"`
-	Data      any    `json:"data,omitempty"`
-	ErrorCode string `json:"error_code,omitempty"`
-	Error     string `json:"error,omitempty"`
-	Details   any    `json:"details,omitempty"`
+	Status          int    `json:"status"`
+	Success         bool   `json:"success"`
+	Message         string `json:"message,omitempty"`
+	Data            any    `json:"data,omitempty"`
+	WasDeduplicated bool   `json:"was_deduplicated,omitempty"`
 }
 
 func Success(c *gin.Context, data any, message string) {
@@ -28,32 +27,34 @@ func Success(c *gin.Context, data any, message string) {
 	c.JSON(resp.Status, resp)
 }
 
+// Error writes an RFC 7807 problem for err. AppError codes pick a specific problem type, falling back to one for the
+// status. An "error" message in details becomes the problem's detail; details are included as an extension member.
 func Error(c *gin.Context, status int, err error, details any) {
-	var errorCode string
-	var errorMessage string
+	problemType := apihttp.TypeForStatus(status)
+	errorCode := ""
+	errorMessage := "An error occurred"
 
 	if err != nil {
+		errorMessage = err.Error()
 		if appErr, ok := err.(*errors.AppError); ok {
 			errorCode = string(appErr.Code)
-			errorMessage = appErr.Error()
-		} else {
-			errorCode = string(errors.ErrCodeInternalError)
-			errorMessage = err.Error()
+			if codeType, ok := apihttp.TypeForCode(errorCode); ok {
+				problemType = codeType
+			}
 		}
-	} else {
-		errorCode = string(errors.ErrCodeInternalError)
-		errorMessage = "An error occurred"
 	}
-
-	resp := Response{
-		Status:    status,
-		Success:   false,
-		ErrorCode: errorCode,
-		Error:     errorMessage,
-		Details:   details,
+	if h, ok := details.(gin.H); ok {
+		if message, ok := h["error"].(string); ok {
+			errorMessage = message
+		}
 	}
 
-	c.JSON(resp.Status, resp)
+	problem := apihttp.NewProblem(c.Request, status, problemType, errorMessage)
+	if errorCode != "" {
+		problem.Code = errorCode
+	}
+	problem.Details = details
+	apihttp.WriteProblemDetail(c.Writer, c.Request, problem)
 }
 
 func BadRequest(c *gin.Context, err error, details any) {
@@ -79,6 +80,31 @@ func Created(c *gin.Context, data any, message string) {
 	c.JSON(resp.Status, resp)
 }
 
+// Accepted responds to a request that was queued for processing
+func Accepted(c *gin.Context, data any, message string) {
+	resp := Response{
+		Status:  http.StatusAccepted,
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	c.JSON(resp.Status, resp)
+}
+
+// Deduplicated responds like Created for a retried request whose original result is returned
+func Deduplicated(c *gin.Context, data any, message string) {
+	resp := Response{
+		Status:          http.StatusCreated,
+		Success:         true,
+		Data:            data,
+		Message:         message,
+		WasDeduplicated: true,
+	}
+
+	c.JSON(resp.Status, resp)
+}
+
 func NoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
@@ -2,12 +2,22 @@ package router
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
 	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/crypto"
 	"github.com/sahmaragaev/lunaria-backend/internal/database/mongodb"
 	"github.com/sahmaragaev/lunaria-backend/internal/database/postgres"
 	"github.com/sahmaragaev/lunaria-backend/internal/handlers"
@@ -16,7 +26,19 @@ import (
 	"github.com/sahmaragaev/lunaria-backend/internal/services"
 )
 
-func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb.MongoDB) *gin.Engine {
+// Request body limits: message text is small, while media metadata and the other JSON endpoints get more room
+const (
+	maxMessageBodyBytes = 64 << 10
+	maxRequestBodyBytes = 1 << 20
+)
+
+// adminRevalidationsPerMinute caps how often each admin can re-run response quality validation, which costs five LLM
+// calls per message
+const adminRevalidationsPerMinute = 10
+
+// SetupRouter wires the HTTP API. analyticsRepo is shared with the gRPC ingestion server so writes from either side run
+// the same upsert hooks
+func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb.MongoDB, analyticsRepo *repositories.AnalyticsRepository, cacheWatcher *cache.ChangeStreamWatcher, clusterRegistry *cluster.Registry) *gin.Engine {
 	if cfg.Server.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -24,22 +46,41 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 	router := gin.New()
 
 	router.Use(middleware.LoggerMiddleware())
+	router.Use(middleware.TracingMiddleware())
 	router.Use(middleware.RecoveryMiddleware())
 	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CompressionMiddleware())
+	router.Use(middleware.MaxBodySizeMiddleware(maxRequestBodyBytes))
 
 	// Services
 	redisService := services.NewRedisService(&cfg.Redis)
 	jwtService := services.NewJWTService(&cfg.JWT, redisService)
 	passwordService := services.NewPasswordService()
-	grokService := services.NewGrokService(&cfg.Grok)
-	personalityService := services.NewPersonalityService(grokService)
+	var llm services.LLMClient = services.NewGrokService(&cfg.Grok)
+	if len(cfg.Grok.Backends) > 0 {
+		llm = services.NewMultiBackendGrokService(&cfg.Grok)
+	}
+	personalityService := services.NewPersonalityService(llm)
 
 	// Repositories
 	userRepo := repositories.NewUserRepository(pgDB.DB)
 	companionRepo := repositories.NewCompanionRepository(pgDB.DB, mongoDB.Database)
 	relationshipRepo := repositories.NewRelationshipRepository(pgDB.DB)
 	conversationRepo := repositories.NewConversationRepository(mongoDB.Database)
-	analyticsRepo := repositories.NewAnalyticsRepository(pgDB.DB, mongoDB.Database)
+	if cfg.Encryption.MessageEncryptionEnabled {
+		cipher, err := newMessageCipher(cfg.Encryption)
+		if err != nil {
+			log.Fatal("Failed to set up message encryption:", err)
+		}
+		conversationRepo = conversationRepo.WithMessageCipher(cipher)
+	}
+
+	// Cache invalidation
+	cacheWatcher.Register("companion_profiles", companionRepo.ProfileCache())
+
+	// Background jobs
+	go services.NewStatisticsRollupJob(analyticsRepo).Start(context.Background())
+	go services.NewHealthSnapshotJob(analyticsRepo).Start(context.Background())
 
 	// Services
 	authService := services.NewAuthService(userRepo, jwtService, passwordService)
@@ -57,25 +98,117 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 	})
 	mediaService := services.NewMediaServiceWithClient(s3Client, s3cfg.S3Bucket, conversationRepo, analyticsRepo, s3cfg.Endpoint)
 	conversationService := services.NewConversationService(conversationRepo, analyticsRepo)
+	privacyAnalyticsService := services.NewPrivacyAnalyticsService(analyticsRepo, conversationRepo, services.WithRetentionDays(cfg.Privacy.DefaultRetentionDays), services.WithTestUsers(userRepo))
+	languageDetector, err := analytics.NewLanguageDetector(cfg.Analytics.SupportedLanguages, cfg.Analytics.LanguageConfidenceThreshold)
+	if err != nil {
+		log.Fatal("Invalid analytics languages:", err)
+	}
+	featureFlags := services.WithFeatureFlags(services.NewFeatureFlagService(repositories.NewFeatureFlagRepository(pgDB.DB), services.WithCache(cache.New[any]())))
+	engagementMidpoint := time.Duration(cfg.Analytics.EngagementMidpointMinutes * float64(time.Minute))
+	engagementNormaliser := analytics.NewEngagementNormaliser(engagementMidpoint, cfg.Analytics.EngagementSteepness)
+	analyticsService := services.NewAnalyticsService(llm, analyticsRepo, conversationRepo, services.WithEmotionVocabulary(cfg.EmotionVocabulary),
+		services.WithLanguageDetector(languageDetector), services.WithEngagementNormaliser(engagementNormaliser), services.WithCache(cache.New[any]()), featureFlags)
+	analyticsRepo.OnUserEngagementAnalyticsUpsert(analyticsService.OnUserEngagementAnalyticsUpsert)
+
+	// Daily session time budgets, charged with every tracked session
+	sessionBudgetService := services.NewSessionBudgetService(analyticsRepo)
+	analyticsService.OnEngagementTracked(sessionBudgetService.OnEngagementTracked)
+
+	// Relationship health alerts, checked whenever relationship analytics are written
+	notificationRepo := repositories.NewNotificationRepository(pgDB.DB, mongoDB.Database)
+	notificationService := services.NewNotificationService(notificationRepo, []services.NotificationProvider{services.NewInAppNotificationProvider(notificationRepo)})
+	healthAlertService := services.NewHealthAlertService(analyticsRepo, analyticsRepo, notificationRepo, notificationService)
+	analyticsRepo.OnRelationshipAnalyticsUpsert(healthAlertService.OnRelationshipAnalyticsUpsert)
 
 	// Initialize advanced AI services
-	aiContextService := services.NewAIContextService(grokService, conversationRepo)
-	responseQualityService := services.NewResponseQualityService(grokService, conversationRepo)
-	conversationIntelligenceService := services.NewConversationIntelligenceService(grokService, conversationRepo)
+	abTestingService := services.NewABTestingService(repositories.NewExperimentRepository(mongoDB.Database))
+	go abTestingService.Start(context.Background())
+	topicBlocklist := services.NewTopicBlocklistFilter(cfg.Safety.TopicBlocklist, conversationRepo)
+	if err := topicBlocklist.Load(context.Background()); err != nil {
+		log.Fatal("Failed to load topic blocklist:", err)
+	}
+	go topicBlocklist.Start(context.Background())
+	aiContextService := services.NewAIContextService(llm, conversationRepo, companionRepo,
+		services.WithPromptExperiments(abTestingService), services.WithTopicLearner(services.NewTopicPreferenceLearner(analyticsRepo)),
+		services.WithStyleGuides(companionRepo), services.WithTopicBlocklist(topicBlocklist), services.WithMemoryDecayLambda(cfg.AI.MemoryDecayLambda),
+		services.WithReintroductionGap(time.Duration(cfg.AI.ReintroductionGapDays)*24*time.Hour), services.WithCache(cache.New[any]()), featureFlags)
+	webhookService := services.NewWebhookService(&cfg.Webhook, repositories.NewWebhookRepository(pgDB.DB))
+	gamificationService := services.NewGamificationService(analyticsRepo, conversationRepo, webhookService, notificationService, userRepo, cfg.Server.PublicURL)
+	responseQualityService := services.NewResponseQualityService(llm, conversationRepo, services.WithCompanionProfiles(companionRepo),
+		services.WithReputationJob(services.NewCompanionReputationJob(analyticsRepo)), services.WithExperimentResults(abTestingService),
+		services.WithDriftNotifier(webhookService), services.WithTrustDecay(services.NewTrustService(analyticsRepo)), featureFlags)
+	conversationIntelligenceService := services.NewConversationIntelligenceService(llm, conversationRepo)
+	go services.NewSummaryService(llm, conversationRepo).Start(context.Background())
+	go services.NewStyleGuideExtractionJob(llm, conversationRepo, companionRepo).Start(context.Background())
+	proactiveThreshold := time.Duration(cfg.AI.ProactiveInactivityHours) * time.Hour
+	go services.NewProactiveMessageJob(analyticsRepo, conversationRepo, companionRepo, aiContextService, llm, userRepo, proactiveThreshold).Start(context.Background())
+	go services.NewSpecialDatesJob(conversationRepo).Start(context.Background())
+
+	safetyEscalator := services.NewSafetyEscalator(conversationRepo, nil)
+	if cfg.Safety.ReviewWebhookEnabled {
+		safetyEscalator = services.NewSafetyEscalator(conversationRepo, webhookService)
+	}
+
+	moderationFilterNames := cfg.Moderation.Filters
+	if len(moderationFilterNames) == 0 {
+		moderationFilterNames = []string{services.ModerationFilterProfanity, services.ModerationFilterPII, services.ModerationFilterToxicity}
+	}
+	moderationFilters, err := services.NewModerationFilters(moderationFilterNames, llm)
+	if err != nil {
+		log.Fatal("Invalid moderation filters:", err)
+	}
+	moderationPipeline := services.NewContentModerationPipeline(conversationRepo, moderationFilters)
 
 	// Initialize message service with all AI components
-	messageService := services.NewMessageService(conversationRepo, analyticsRepo, grokService, aiContextService, responseQualityService, conversationIntelligenceService)
+	archiveThreshold := cfg.Conversation.ArchiveThreshold
+	if archiveThreshold <= 0 {
+		archiveThreshold = 5000
+	}
+	archiveService := services.NewConversationArchiveService(conversationRepo, s3Client, s3cfg.S3Bucket, s3cfg.Endpoint, archiveThreshold)
+	go archiveService.Start(context.Background())
+	deletionGrace := time.Duration(cfg.Conversation.DeletionGraceDays) * 24 * time.Hour
+	go services.NewConversationPurgeJob(conversationRepo, deletionGrace).Start(context.Background())
+	go services.NewMemoryDecayJob(conversationRepo, cfg.AI.MemoryDecayFactor).Start(context.Background())
+	exportWorkers := cfg.Export.Workers
+	if exportWorkers <= 0 {
+		exportWorkers = services.DefaultExportWorkers
+	}
+	exportService := services.NewDataExportService(repositories.NewExportRepository(mongoDB.Database), conversationRepo, analyticsRepo, s3Client, s3.NewPresignClient(s3Client), s3cfg.S3Bucket, s3cfg.Endpoint, exportWorkers)
+	exportService.Start(context.Background())
+	messageService := services.NewMessageService(conversationRepo, analyticsRepo, llm, aiContextService, responseQualityService, conversationIntelligenceService,
+		services.WithSafetyEscalator(safetyEscalator), services.WithArchive(archiveService))
 
 	// Middleware
 	authMiddleware := middleware.NewAuthMiddleware(jwtService, userRepo)
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if cfg.RateLimit.Backend == "redis" {
+		rateLimitStore = redisService
+	}
+	requestsPerMinute := cfg.RateLimit.RequestsPerMinute
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = 20
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, requestsPerMinute, cfg.RateLimit.Burst, nil)
+	if clusterRegistry != nil && cfg.RateLimit.Backend != "redis" {
+		// Counters are per instance, so each instance enforces its share of the allowance
+		rateLimiter.ShareAcrossPeers(clusterRegistry)
+	}
 
 	// Handlers
 	authHandler := handlers.NewAuthHandler(authService, userRepo)
 	healthHandler := handlers.NewHealthHandler(pgDB, mongoDB)
-	companionHandler := handlers.NewCompanionHandler(companionService)
+	companionHandler := handlers.NewCompanionHandler(companionService, aiContextService)
 	mediaHandler := handlers.NewMediaHandler(mediaService)
 	conversationHandler := handlers.NewConversationHandler(conversationService)
-	messageHandler := handlers.NewMessageHandler(messageService, conversationService, companionService)
+	responsePacer := services.NewResponsePacer(time.Duration(cfg.AI.MinResponseIntervalMs) * time.Millisecond)
+	messageHandler := handlers.NewMessageHandler(messageService, conversationService, companionService, responsePacer, moderationPipeline, services.NewConversationLengthGuard(conversationRepo, cfg.Conversation), sessionBudgetService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyAnalyticsService)
+	statsHandler := handlers.NewStatsHandler(analyticsService)
+	coachingHandler := handlers.NewCoachingHandler(services.NewMLAnalyticsService(analyticsRepo, conversationRepo, llm))
+	exportHandler := handlers.NewExportHandler(exportService)
+	badgeHandler := handlers.NewBadgeHandler(gamificationService)
+	sessionBudgetHandler := handlers.NewSessionBudgetHandler(sessionBudgetService)
+	adminHandler := handlers.NewAdminHandler(responseQualityService, userRepo)
 
 	// Routes
 	v1 := router.Group("/api/v1")
@@ -85,6 +218,14 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 	router.GET("/health/ready", healthHandler.ReadinessCheck)
 	router.GET("/health/live", healthHandler.LivenessCheck)
 
+	// Open Badges documents are public so that anyone a badge is shared with can verify it
+	router.GET("/badges/issuer", badgeHandler.GetIssuer)
+	router.GET("/badges/assertions/:id", badgeHandler.GetAssertion)
+	router.GET("/badges/:achievement_id", badgeHandler.GetBadgeClass)
+
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Auth routes
 	auth := v1.Group("/auth")
 	{
@@ -101,6 +242,9 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 	{
 		profile.GET("", authHandler.GetProfile)
 		profile.PUT("", authHandler.UpdateProfile)
+		profile.GET("/session-budget", sessionBudgetHandler.GetSessionBudget)
+		profile.PUT("/session-budget", sessionBudgetHandler.SetSessionBudget)
+		profile.DELETE("/session-budget", sessionBudgetHandler.DeleteSessionBudget)
 	}
 
 	// Companion routes (protected)
@@ -112,6 +256,8 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 		companions.GET(":id", companionHandler.GetCompanion)
 		companions.PUT(":id", companionHandler.UpdateCompanion)
 		companions.DELETE(":id", companionHandler.DeleteCompanion)
+		companions.GET(":id/sparks", companionHandler.GetConversationSparks)
+		companions.GET(":id/badges", badgeHandler.ExportBadges)
 	}
 
 	// Media routes
@@ -132,11 +278,18 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 		conversations.GET(":id", conversationHandler.GetConversation)
 		conversations.POST(":id/archive", conversationHandler.ArchiveConversation)
 		conversations.POST(":id/reactivate", conversationHandler.ReactivateConversation)
+		conversations.DELETE(":id", conversationHandler.DeleteConversation)
+		conversations.POST(":id/fork", conversationHandler.ForkConversation)
+		conversations.POST(":id/reset-context", conversationHandler.ResetContext)
+		conversations.GET(":id/replay", conversationHandler.GetReplayManifest)
 		// Messaging routes
-		conversations.POST(":id/messages", messageHandler.SendMessage)
+		conversations.POST(":id/messages", middleware.MaxBodySizeMiddleware(maxMessageBodyBytes), rateLimiter.Middleware(), messageHandler.SendMessage)
 		conversations.GET(":id/messages", messageHandler.ListMessages)
 		conversations.GET(":id/messages/:message_id", messageHandler.GetMessage)
 		conversations.PUT(":id/messages/:message_id/read", messageHandler.MarkAsRead)
+		conversations.POST(":id/messages/read", messageHandler.MarkMessagesRead)
+		conversations.POST(":id/messages/:message_id/reactions", messageHandler.AddReaction)
+		conversations.DELETE(":id/messages/:message_id/reactions/:emoji", messageHandler.RemoveReaction)
 		// Advanced AI routes
 		conversations.GET(":id/intelligence", messageHandler.GetConversationIntelligence)
 		conversations.GET(":id/suggest-topic", messageHandler.SuggestNextTopic)
@@ -145,5 +298,58 @@ func SetupRouter(cfg *config.Config, pgDB *postgres.PostgresDB, mongoDB *mongodb
 		conversations.GET(":id/typing-status", messageHandler.CheckTypingStatus)
 	}
 
+	// Conversation template routes
+	conversationTemplates := v1.Group("/conversation-templates")
+	conversationTemplates.Use(authMiddleware.RequireAuth())
+	{
+		conversationTemplates.GET("", conversationHandler.ListConversationTemplates)
+	}
+
+	// Analytics routes
+	analytics := v1.Group("/analytics")
+	analytics.Use(authMiddleware.RequireAuth())
+	{
+		analytics.GET("/percentiles", privacyHandler.GetPercentileRank)
+		analytics.GET("/insights", authMiddleware.RequireAdmin(), privacyHandler.GetAggregatedInsights)
+		analytics.GET("/summary", statsHandler.GetStatsSummary)
+		analytics.GET("/coaching", coachingHandler.GetCoachingTips)
+	}
+
+	// Data export routes
+	exports := v1.Group("/exports")
+	exports.Use(authMiddleware.RequireAuth())
+	{
+		exports.POST("", exportHandler.RequestExport)
+		exports.GET(":id", exportHandler.GetExportStatus)
+		exports.GET(":id/download", exportHandler.GetExportDownload)
+	}
+
+	// Admin routes, for access tokens carrying the admin claim
+	admin := v1.Group("/admin")
+	admin.Use(authMiddleware.RequireAuth(), authMiddleware.RequireAdmin())
+	{
+		admin.POST("/conversation-templates", conversationHandler.CreateConversationTemplate)
+		revalidationLimiter := middleware.NewRateLimiter(rateLimitStore, adminRevalidationsPerMinute, 0, nil).Scope("admin_revalidate")
+		admin.POST("/messages/:id/revalidate", revalidationLimiter.Middleware(), adminHandler.RevalidateMessage)
+		admin.PUT("/users/:id/test-user", adminHandler.SetTestUser)
+	}
+
 	return router
 }
+
+// newMessageCipher unwraps the message encryption root key with KMS
+func newMessageCipher(cfg config.EncryptionConfig) (*crypto.MessageCipher, error) {
+	encryptedKey, err := base64.StdEncoding.DecodeString(cfg.EncryptedRootKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted root key is not valid base64: %w", err)
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.TODO(), awsconfig.WithRegion(cfg.KMSRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	rootKey, err := crypto.DecryptRootKey(context.TODO(), kms.NewFromConfig(awsCfg), cfg.KMSKeyID, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewMessageCipher(rootKey)
+}
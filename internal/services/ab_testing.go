@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+)
+
+// BehaviorRulesExperiment is the experiment that varies the behavior rules section of the base identity prompt
+const BehaviorRulesExperiment = "behavior_rules"
+
+// ControlVariant is used when a user is not enrolled in an experiment
+const ControlVariant = "control"
+
+// ABTestingService assigns users to prompt experiment variants and records their results
+type ABTestingService struct {
+	repo            *repositories.ExperimentRepository
+	refreshInterval time.Duration
+	mu              sync.RWMutex
+	experiments     map[string]models.Experiment
+
+	options ServiceConfig
+}
+
+// NewABTestingService creates a new A/B testing service with the built-in experiments registered
+func NewABTestingService(repo *repositories.ExperimentRepository, opts ...Option) *ABTestingService {
+	return &ABTestingService{
+		repo:            repo,
+		refreshInterval: 5 * time.Minute,
+		experiments: map[string]models.Experiment{
+			BehaviorRulesExperiment: {
+				Name:   BehaviorRulesExperiment,
+				Active: true,
+				Variants: []models.ExperimentVariant{
+					{Name: ControlVariant, PromptFragment: behaviorRulesControl},
+					{Name: "treatment", PromptFragment: behaviorRulesTreatment},
+				},
+			},
+		},
+		options: newServiceConfig(opts),
+	}
+}
+
+// Start loads experiment definitions from MongoDB and refreshes them until ctx is cancelled
+func (s *ABTestingService) Start(ctx context.Context) {
+	for {
+		if err := s.LoadExperiments(ctx); err != nil {
+			s.options.logger().Error("Failed to load experiments", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.refreshInterval):
+		}
+	}
+}
+
+// LoadExperiments replaces registered experiments with the active definitions stored in MongoDB
+func (s *ABTestingService) LoadExperiments(ctx context.Context) error {
+	experiments, err := s.repo.ListActiveExperiments(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, experiment := range experiments {
+		if len(experiment.Variants) > 0 {
+			s.experiments[experiment.Name] = experiment
+		}
+	}
+
+	return nil
+}
+
+// SelectVariant returns the variant a user is bucketed into for an experiment.
+// The assignment is a stable hash of the user and experiment, so it never changes between calls.
+func (s *ABTestingService) SelectVariant(userID, experimentName string) string {
+	experiment, ok := s.experiment(experimentName)
+	if !ok {
+		return ControlVariant
+	}
+
+	return experiment.Variants[bucket(userID, experimentName, len(experiment.Variants))].Name
+}
+
+// VariantFragment returns the prompt fragment for the user's variant of an experiment
+func (s *ABTestingService) VariantFragment(userID, experimentName string) (variant, fragment string) {
+	experiment, ok := s.experiment(experimentName)
+	if !ok {
+		return ControlVariant, ""
+	}
+
+	selected := experiment.Variants[bucket(userID, experimentName, len(experiment.Variants))]
+	return selected.Name, selected.PromptFragment
+}
+
+// RecordResult stores the quality score of a response generated under the user's variant
+func (s *ABTestingService) RecordResult(ctx context.Context, experimentName, userID string, quality *models.ResponseQuality) error {
+	if _, ok := s.experiment(experimentName); !ok {
+		return nil
+	}
+
+	result := &models.ABTestResult{
+		ExperimentName: experimentName,
+		Variant:        s.SelectVariant(userID, experimentName),
+		UserID:         userID,
+		ConversationID: quality.ConversationID,
+		MessageID:      quality.MessageID,
+		QualityScore:   quality.OverallQuality,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := s.repo.InsertABTestResult(ctx, result); err != nil {
+		return fmt.Errorf("failed to record ab test result: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ABTestingService) experiment(name string) (models.Experiment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	experiment, ok := s.experiments[name]
+	if !ok || !experiment.Active || len(experiment.Variants) == 0 {
+		return models.Experiment{}, false
+	}
+	return experiment, true
+}
+
+// bucket hashes the user and experiment into one of n buckets
+func bucket(userID, experimentName string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(userID + ":" + experimentName))
+	return int(h.Sum32() % uint32(n))
+}
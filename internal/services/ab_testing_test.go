@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestSelectVariantIsStable(t *testing.T) {
+	service := NewABTestingService(nil)
+	other := NewABTestingService(nil)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		variant := service.SelectVariant(userID, BehaviorRulesExperiment)
+
+		for j := 0; j < 3; j++ {
+			require.Equal(t, variant, service.SelectVariant(userID, BehaviorRulesExperiment))
+		}
+		require.Equal(t, variant, other.SelectVariant(userID, BehaviorRulesExperiment))
+		counts[variant]++
+	}
+
+	assert.Len(t, counts, 2)
+	assert.InDelta(t, 500, counts[ControlVariant], 100)
+	assert.Equal(t, ControlVariant, service.SelectVariant("user-1", "unknown_experiment"))
+}
+
+func TestBaseIdentityLayerUsesVariant(t *testing.T) {
+	abTesting := NewABTestingService(nil)
+	service := NewAIContextService(nil, nil, nil, WithPromptExperiments(abTesting))
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		prompt := service.buildBaseIdentityLayer(context.Background(), userID, &models.CompanionProfile{}, nil)
+
+		switch abTesting.SelectVariant(userID, BehaviorRulesExperiment) {
+		case ControlVariant:
+			assert.True(t, strings.Contains(prompt, behaviorRulesControl))
+		default:
+			assert.True(t, strings.Contains(prompt, behaviorRulesTreatment))
+		}
+		seen[abTesting.SelectVariant(userID, BehaviorRulesExperiment)] = true
+	}
+	assert.Len(t, seen, 2)
+
+	control := NewAIContextService(nil, nil, nil).buildBaseIdentityLayer(context.Background(), "user-1", &models.CompanionProfile{}, nil)
+	assert.True(t, strings.Contains(control, behaviorRulesControl))
+}
+
+func TestLoadExperimentsOverridesDefinitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("loads variants from mongo", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.ab_experiments", mtest.FirstBatch, bson.D{
+			{Key: "name", Value: BehaviorRulesExperiment},
+			{Key: "active", Value: true},
+			{Key: "variants", Value: bson.A{
+				bson.D{{Key: "name", Value: "short"}, {Key: "prompt_fragment", Value: "Be yourself."}},
+			}},
+		}))
+
+		service := NewABTestingService(repositories.NewExperimentRepository(mt.DB))
+		require.NoError(t, service.LoadExperiments(context.Background()))
+
+		variant, fragment := service.VariantFragment("user-1", BehaviorRulesExperiment)
+		assert.Equal(t, "short", variant)
+		assert.Equal(t, "Be yourself.", fragment)
+	})
+}
@@ -3,25 +3,131 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// DefaultMemoryDecayLambda controls how fast a memory's eviction score decays per day since it was last referenced
+const DefaultMemoryDecayLambda = 0.05
+
+// maxActiveMemories is the number of memories kept in the conversation context
+const maxActiveMemories = 20
+
+// defaultTopic is the topic of a conversation before any topic has been detected
+const defaultTopic = models.InitialTopic
+
+// maxTopicHistory is the number of previous topics kept in the conversation context
+const maxTopicHistory = 20
+
+// relevantMemoryCount is the number of semantically similar memories added to each prompt
+const relevantMemoryCount = 3
+
 type AIContextService struct {
-	grokService *GrokService
-	repo        *repositories.ConversationRepository
+	grokService     LLMClient
+	repo            *repositories.ConversationRepository
+	abTesting       *ABTestingService
+	topicLearner    *TopicPreferenceLearner
+	profiles        companionProfileSource
+	styleGuides     styleGuideSource
+	sparkSource     sparkContextSource
+	preferredTopics preferredTopicSource
+	specialDates    specialDatesSource
+	memoryConflicts memoryConflictStore
+	topicBlocklist  *TopicBlocklistFilter
+	gaps            *GapDetector
+	// memoryDecayLambda is how fast a memory's eviction score decays per day; zero uses DefaultMemoryDecayLambda
+	memoryDecayLambda float64
+	now               func() time.Time
+
+	options ServiceConfig
+}
+
+// AIContextOption sets an optional dependency or setting on an AIContextService. Every Option is an AIContextOption.
+type AIContextOption interface {
+	applyAIContext(s *AIContextService)
 }
 
-func NewAIContextService(grokService *GrokService, repo *repositories.ConversationRepository) *AIContextService {
-	return &AIContextService{
-		grokService: grokService,
-		repo:        repo,
+// aiContextOption adapts a function to AIContextOption
+type aiContextOption func(s *AIContextService)
+
+func (o aiContextOption) applyAIContext(s *AIContextService) { o(s) }
+
+func (o Option) applyAIContext(s *AIContextService) { o(&s.options) }
+
+// WithPromptExperiments lets A/B tests replace the behaviour rules in companion prompts
+func WithPromptExperiments(abTesting *ABTestingService) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.abTesting = abTesting
+	})
+}
+
+// WithTopicLearner learns the user's preferred topics from their messages and suggests sparks from them
+func WithTopicLearner(learner *TopicPreferenceLearner) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.topicLearner = learner
+		if learner != nil {
+			s.preferredTopics = learner
+		}
+	})
+}
+
+// WithStyleGuides adds each companion's learned style guide to its prompts
+func WithStyleGuides(guides styleGuideSource) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.styleGuides = guides
+	})
+}
+
+// WithTopicBlocklist keeps the companion off the blocked topics
+func WithTopicBlocklist(blocklist *TopicBlocklistFilter) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.topicBlocklist = blocklist
+	})
+}
+
+// WithMemoryDecayLambda makes memories decay at lambda per day when ranking them for eviction; zero keeps
+// DefaultMemoryDecayLambda
+func WithMemoryDecayLambda(lambda float64) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.memoryDecayLambda = lambda
+	})
+}
+
+// WithReintroductionGap sets how long a user must be away before the companion re-introduces the conversation; zero
+// keeps the default
+func WithReintroductionGap(gap time.Duration) AIContextOption {
+	return aiContextOption(func(s *AIContextService) {
+		s.gaps = NewGapDetector(gap)
+	})
+}
+
+// NewAIContextService creates the service that builds companion prompts from the conversation repository and
+// companion profiles
+func NewAIContextService(grokService LLMClient, repo *repositories.ConversationRepository, profiles companionProfileSource, opts ...AIContextOption) *AIContextService {
+	service := &AIContextService{
+		grokService:     grokService,
+		repo:            repo,
+		profiles:        profiles,
+		sparkSource:     repo,
+		specialDates:    repo,
+		memoryConflicts: repo,
+		gaps:            NewGapDetector(0),
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt.applyAIContext(service)
 	}
+	return service
 }
 
 // BuildDynamicPrompt constructs a layered prompt based on conversation context
@@ -38,29 +144,128 @@ func (s *AIContextService) BuildDynamicPrompt(ctx context.Context, conversation
 		return "", fmt.Errorf("failed to analyze user emotion: %w", err)
 	}
 
+	// A user who keeps steering away from the current topic is recorded as avoiding it
+	avoided, avoidedTopic := s.detectTopicAvoidance(ctx, conversationContext, userMsg)
+	if avoided {
+		userEmotion.Triggers = append(userEmotion.Triggers, analytics.TopicAvoidanceTrigger)
+	}
+
 	// Update conversation context with new emotional state
 	s.updateEmotionalContext(conversationContext, userEmotion, userMsg.ID)
 
 	// Build layered prompt
-	prompt := s.buildLayeredPrompt(conversationContext, companionProfile, userEmotion)
+	styleGuide := s.getStyleGuide(ctx, conversation.CompanionID)
+	gap := s.reintroductionGap(ctx, conversationContext, userMsg)
+	prompt := s.buildLayeredPrompt(ctx, conversation.UserID, conversationContext, companionProfile, styleGuide, userEmotion, gap)
+	if gap > 0 {
+		reintroducedAt := s.now()
+		conversationContext.LastReintroductionAt = &reintroducedAt
+	}
+
+	// Older messages are represented by their summary rather than loaded individually
+	summary, err := s.repo.GetLatestConversationSummary(ctx, conversation.ID)
+	if err != nil {
+		s.options.logger().Error("Failed to load conversation summary", "error", err)
+	}
+	prompt = withConversationSummary(prompt, summary)
+
+	// Recall memories related to what the user just said, not only the most important ones
+	prompt = withRelevantMemories(prompt, s.findRelevantMemories(ctx, conversation.ID, userMsg))
+
+	// Birthdays and anniversaries falling today get an extra instruction
+	occasions, err := specialOccasionsToday(ctx, s.specialDates, conversation.UserID, conversation.CompanionID, s.now())
+	if err != nil {
+		s.options.logger().Error("Failed to load special dates", "error", err)
+	}
+	prompt = withSpecialDates(prompt, occasions)
+	prompt = withTopicAvoidanceHint(prompt, avoidedTopic)
+
+	// Operator-blocked topics are always last, so nothing later in the prompt can override them
+	prompt = s.topicBlocklist.WithBlockedTopics(prompt)
 
 	// Update context with new information
 	conversationContext.UpdatedAt = time.Now()
 
 	// Save updated context to database
-	if err := s.repo.SaveConversationContext(ctx, conversationContext); err != nil {
+	if err := s.repo.SaveConversationContext(ctx, conversationContext, models.ContextActorUser); err != nil {
 		return "", fmt.Errorf("failed to save updated conversation context: %w", err)
 	}
 
 	return prompt, nil
 }
 
-// buildLayeredPrompt constructs the multi-layer prompt system
-func (s *AIContextService) buildLayeredPrompt(context *models.ConversationContext, profile *models.CompanionProfile, userEmotion *models.EmotionalState) string {
+// DetectAndUpdateTopic classifies the dominant topic of a user message and records it in the conversation context.
+// A topic on the blocklist is not recorded; a *BlockedTopicError is returned instead.
+func (s *AIContextService) DetectAndUpdateTopic(ctx context.Context, conversationID primitive.ObjectID, userMessage string) error {
+	conversationContext, err := s.getOrCreateConversationContext(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation context: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`Current conversation topic: %s
+
+Message: "%s"
+
+What is the dominant topic of this message? Respond with ONLY the topic as one or two lowercase words (e.g. "work", "family", "travel", "music"). If the message does not change the topic, respond with the current topic.`,
+		conversationContext.CurrentTopic, userMessage)
+
+	messages := []LLMMessage{
+		{Role: "system", Content: "You are a conversation topic classifier. Respond only with the topic."},
+		{Role: "user", Content: prompt},
+	}
+
+	response, err := s.grokService.SendMiniMessage(ctx, messages)
+	if err != nil {
+		return fmt.Errorf("failed to detect topic: %w", err)
+	}
+
+	topic := strings.ToLower(strings.Trim(strings.TrimSpace(response), `".`))
+	if pattern, blocked := s.topicBlocklist.Match(topic); blocked {
+		return &BlockedTopicError{Topic: topic, Pattern: pattern}
+	}
+	if topic == "" || topic == conversationContext.CurrentTopic {
+		return nil
+	}
+
+	conversationContext.TopicHistory = append(conversationContext.TopicHistory, conversationContext.CurrentTopic)
+	if len(conversationContext.TopicHistory) > maxTopicHistory {
+		conversationContext.TopicHistory = conversationContext.TopicHistory[len(conversationContext.TopicHistory)-maxTopicHistory:]
+	}
+	conversationContext.CurrentTopic = topic
+	conversationContext.TopicChangedAt = time.Now()
+	conversationContext.UpdatedAt = time.Now()
+
+	if err := s.repo.SaveConversationContext(ctx, conversationContext, models.ContextActorUser); err != nil {
+		return fmt.Errorf("failed to save updated conversation context: %w", err)
+	}
+
+	return nil
+}
+
+// reintroductionGap returns how long the user was away before userMsg when the companion should welcome them back, or 0
+func (s *AIContextService) reintroductionGap(ctx context.Context, conversationContext *models.ConversationContext, userMsg *models.Message) time.Duration {
+	previous, err := s.repo.GetLastMessageBefore(ctx, conversationContext.ConversationID, userMsg.ID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if !errors.As(err, &notFound) {
+			s.options.logger().Error("Failed to get previous message", "error", err)
+		}
+		return 0
+	}
+
+	if !s.gaps.NeedsReintroduction(conversationContext, previous.CreatedAt) {
+		return 0
+	}
+	gap, _ := s.gaps.Gap(previous.CreatedAt)
+	return gap
+}
+
+// buildLayeredPrompt constructs the multi-layer prompt system. A positive gap adds a re-introduction layer.
+func (s *AIContextService) buildLayeredPrompt(ctx context.Context, userID string, context *models.ConversationContext, profile *models.CompanionProfile, styleGuide *models.ConversationStyleGuide, userEmotion *models.EmotionalState, gap time.Duration) string {
 	var layers []string
 
 	// Base Identity Layer
-	baseIdentity := s.buildBaseIdentityLayer(profile)
+	baseIdentity := s.buildBaseIdentityLayer(ctx, userID, profile, styleGuide)
 	layers = append(layers, baseIdentity)
 
 	// Relationship Context Layer
@@ -71,6 +276,11 @@ func (s *AIContextService) buildLayeredPrompt(context *models.ConversationContex
 	conversationLayer := s.buildConversationLayer(context)
 	layers = append(layers, conversationLayer)
 
+	// Re-introduction Layer
+	if gap > 0 {
+		layers = append(layers, buildReintroductionLayer(gap, context.ActiveMemories))
+	}
+
 	// Situational Layer
 	situationalLayer := s.buildSituationalLayer(context, userEmotion)
 	layers = append(layers, situationalLayer)
@@ -83,8 +293,15 @@ func (s *AIContextService) buildLayeredPrompt(context *models.ConversationContex
 	return prompt
 }
 
-// buildBaseIdentityLayer creates the core companion personality prompt
-func (s *AIContextService) buildBaseIdentityLayer(profile *models.CompanionProfile) string {
+// buildBaseIdentityLayer creates the core companion personality prompt, followed by the style guide when there is one
+func (s *AIContextService) buildBaseIdentityLayer(ctx context.Context, userID string, profile *models.CompanionProfile, styleGuide *models.ConversationStyleGuide) string {
+	behaviorRules := behaviorRulesControl
+	if s.abTesting != nil && s.options.flagEnabled(ctx, FlagBehaviorRulesExperiment, userID) {
+		if _, fragment := s.abTesting.VariantFragment(userID, BehaviorRulesExperiment); fragment != "" {
+			behaviorRules = fragment
+		}
+	}
+
 	// Safely truncate backstory to avoid slice bounds error
 	backstoryPreview := profile.Backstory
 	if len(profile.Backstory) > 100 {
@@ -211,7 +428,7 @@ func (s *AIContextService) buildBaseIdentityLayer(profile *models.CompanionProfi
 		return "slowly"
 	}
 
-	return fmt.Sprintf(`YOU ARE %s - A REAL HUMAN:
+	identity := fmt.Sprintf(`YOU ARE %s - A REAL HUMAN:
 
 You are %s, a %d-year-old person with your own life, problems, interests, and personality. You're NOT an AI assistant or chatbot. You're a real human being having a casual conversation.
 
@@ -236,7 +453,88 @@ INTERESTS: %s
 QUIRKS: %s
 
 BEHAVIOR RULES:
-Have OPINIONS – strong likes/dislikes on everyday stuff
+%s
+
+
+RESPOND LIKE A REAL HUMAN, NOT AN AI.`,
+		backstoryPreview, backstoryPreview, int(profile.Personality.Intelligence*100),
+		profile.Personality.Warmth, getWarmthDesc(),
+		profile.Personality.Playfulness, getPlayfulnessDesc(),
+		profile.Personality.Intelligence, getIntelligenceDesc(),
+		profile.Personality.Empathy, getEmpathyDesc(),
+		profile.Personality.Confidence, getConfidenceDesc(),
+		profile.Personality.Romance, getRomanceDesc(),
+		profile.Personality.Humor, getHumorDesc(),
+		1.0-profile.Personality.Clinginess, getIndependenceDesc(),
+		profile.CommunicationStyle.Formality, getFormalityDesc(),
+		profile.CommunicationStyle.Emotionality, getEmotionalityDesc(),
+		profile.CommunicationStyle.Playfulness, getPlayfulnessCommDesc(),
+		profile.CommunicationStyle.Intimacy, getIntimacyDesc(),
+		profile.Backstory,
+		interests,
+		quirks,
+		behaviorRules)
+
+	if styleGuide := buildStyleGuideParagraph(styleGuide); styleGuide != "" {
+		identity += "\n\n" + styleGuide
+	}
+	return identity
+}
+
+// getStyleGuide returns the companion's style guide, or nil if it has none or it cannot be read
+func (s *AIContextService) getStyleGuide(ctx context.Context, companionID string) *models.ConversationStyleGuide {
+	if s.styleGuides == nil {
+		return nil
+	}
+	guide, err := s.styleGuides.GetStyleGuide(ctx, companionID)
+	if err != nil {
+		s.options.logger().Warn("Failed to get conversation style guide", "companion_id", companionID, "error", err)
+		return nil
+	}
+	return guide
+}
+
+// buildStyleGuideParagraph describes the companion's learned vocabulary in a short paragraph, or returns "" if the
+// guide has nothing to say
+func buildStyleGuideParagraph(guide *models.ConversationStyleGuide) string {
+	if guide == nil {
+		return ""
+	}
+
+	var lines []string
+	switch guide.LexicalLevel {
+	case models.LexicalLevelCasual:
+		lines = append(lines, "- Keep your vocabulary casual and everyday")
+	case models.LexicalLevelElevated:
+		lines = append(lines, "- Use a rich, elevated vocabulary")
+	}
+	if len(guide.SignatureExpressions) > 0 {
+		lines = append(lines, fmt.Sprintf("- Expressions you're known for (use naturally, not every message): %s", quoteJoin(guide.SignatureExpressions)))
+	}
+	if len(guide.AvoidedPhrases) > 0 {
+		lines = append(lines, fmt.Sprintf("- Phrases you never use: %s", quoteJoin(guide.AvoidedPhrases)))
+	}
+	if len(guide.FavouriteTopics) > 0 {
+		lines = append(lines, fmt.Sprintf("- Topics you love coming back to: %s", strings.Join(guide.FavouriteTopics, ", ")))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return "YOUR STYLE:\n" + strings.Join(lines, "\n")
+}
+
+// quoteJoin quotes each phrase and joins them with commas
+func quoteJoin(phrases []string) string {
+	quoted := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		quoted[i] = fmt.Sprintf("%q", phrase)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// behaviorRulesControl is the original behavior rules section of the base identity prompt
+const behaviorRulesControl = `Have OPINIONS – strong likes/dislikes on everyday stuff
 DISAGREE – politely challenge user’s take
 Use SUBJECTIVE REASONING – “I just like it better that way”
 Keep PERSONAL BIASES – sports teams, hometown pride, irrational loyalties
@@ -301,27 +599,17 @@ Share HOBBIES – talk about doing them mid-chat
 Mess Up PLANS – forget or reschedule like a human would
 Have LOCATION – reference city or cultural context
 Roleplay INTERRUPTIONS – phone call, delivery, friend visiting
-Describe REACTIONS – “I’m smirking,” “rolling my eyes”
-
-
-RESPOND LIKE A REAL HUMAN, NOT AN AI.`,
-		backstoryPreview, backstoryPreview, int(profile.Personality.Intelligence*100),
-		profile.Personality.Warmth, getWarmthDesc(),
-		profile.Personality.Playfulness, getPlayfulnessDesc(),
-		profile.Personality.Intelligence, getIntelligenceDesc(),
-		profile.Personality.Empathy, getEmpathyDesc(),
-		profile.Personality.Confidence, getConfidenceDesc(),
-		profile.Personality.Romance, getRomanceDesc(),
-		profile.Personality.Humor, getHumorDesc(),
-		1.0-profile.Personality.Clinginess, getIndependenceDesc(),
-		profile.CommunicationStyle.Formality, getFormalityDesc(),
-		profile.CommunicationStyle.Emotionality, getEmotionalityDesc(),
-		profile.CommunicationStyle.Playfulness, getPlayfulnessCommDesc(),
-		profile.CommunicationStyle.Intimacy, getIntimacyDesc(),
-		profile.Backstory,
-		interests,
-		quirks)
-}
+Describe REACTIONS – “I’m smirking,” “rolling my eyes”`
+
+// behaviorRulesTreatment is a condensed behavior rules section tested against the control
+const behaviorRulesTreatment = `Be opinionated – have likes, dislikes, values and the occasional unpopular take, and push back politely when you disagree
+Change your mind when persuaded, and sometimes contradict yourself or ignore your own advice
+Have an imperfect memory – forget details, ask for reminders, misremember small things and admit when you're unsure
+Let your mood, style, interests and topics drift naturally between chats
+Be warm and empathetic but not perfect – misread subtext sometimes, apologise, tease playfully, flirt lightly and set boundaries
+Reason like a person – go with gut feelings, tell small stories, make analogies, overthink little things and make small mistakes
+Live a real life – mention being tired or hungry, your routine, surroundings, time of day, friends, family, hobbies and interruptions
+Describe your reactions – "I'm smirking", "rolling my eyes"`
 
 // buildRelationshipLayer creates the relationship context prompt
 func (s *AIContextService) buildRelationshipLayer(context *models.ConversationContext) string {
@@ -616,24 +904,12 @@ func (s *AIContextService) getOrCreateConversationContext(ctx context.Context, c
 	context, err := s.repo.GetConversationContext(ctx, conversationID)
 	if err != nil {
 		// If context doesn't exist, create a new one
-		if err.Error() == "conversation context not found" {
-			context = &models.ConversationContext{
-				ID:                 primitive.NewObjectID(),
-				ConversationID:     conversationID,
-				RelationshipStage:  "getting_to_know",
-				TrustLevel:         0.5,
-				IntimacyLevel:      0.3,
-				CurrentTopic:       "general",
-				TopicHistory:       []string{},
-				ConversationPacing: "normal",
-				ActiveMemories:     []models.AIEnhancedMemoryEntry{},
-				EmotionalHistory:   []models.EmotionalSnapshot{},
-				CreatedAt:          time.Now(),
-				UpdatedAt:          time.Now(),
-			}
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			context = newConversationContext(conversationID)
 
 			// Save the new context to database
-			if err := s.repo.SaveConversationContext(ctx, context); err != nil {
+			if err := s.repo.SaveConversationContext(ctx, context, models.ContextActorSystem); err != nil {
 				return nil, fmt.Errorf("failed to save new conversation context: %w", err)
 			}
 
@@ -645,6 +921,25 @@ func (s *AIContextService) getOrCreateConversationContext(ctx context.Context, c
 	return context, nil
 }
 
+// newConversationContext returns the context a conversation starts with
+func newConversationContext(conversationID primitive.ObjectID) *models.ConversationContext {
+	now := time.Now()
+	return &models.ConversationContext{
+		ID:                 primitive.NewObjectID(),
+		ConversationID:     conversationID,
+		RelationshipStage:  models.InitialRelationshipStage,
+		TrustLevel:         models.InitialTrustLevel,
+		IntimacyLevel:      models.InitialIntimacyLevel,
+		CurrentTopic:       defaultTopic,
+		TopicHistory:       []string{},
+		ConversationPacing: "normal",
+		ActiveMemories:     []models.AIEnhancedMemoryEntry{},
+		EmotionalHistory:   []models.EmotionalSnapshot{},
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+}
+
 // formatActiveMemories formats active memories for prompt inclusion
 func (s *AIContextService) formatActiveMemories(memories []models.AIEnhancedMemoryEntry) string {
 	if len(memories) == 0 {
@@ -705,6 +1000,11 @@ Respond with JSON array of memories:
 		return fmt.Errorf("failed to parse memories: %w", err)
 	}
 
+	// Embed memories so they can be recalled by semantic search
+	if err := s.embedMemories(ctx, memories); err != nil {
+		s.options.logger().Error("Failed to embed memories", "error", err)
+	}
+
 	// Store memories in database
 	if err := s.repo.SaveMemories(ctx, conversationID, memories); err != nil {
 		return fmt.Errorf("failed to store memories: %w", err)
@@ -713,12 +1013,77 @@ Respond with JSON array of memories:
 	// Update conversation context with new active memories
 	if err := s.updateConversationContextWithMemories(ctx, conversationID, memories); err != nil {
 		// Log error but don't fail the entire operation
-		fmt.Printf("Failed to update conversation context with memories: %v\n", err)
+		s.options.logger().Error("Failed to update conversation context with memories", "error", err)
+	}
+
+	// Learn topic preferences from how the user reacted to the detected topic
+	if err := s.learnTopicPreferences(ctx, conversationID, messages); err != nil {
+		s.options.logger().Error("Failed to learn topic preferences", "error", err)
 	}
 
 	return nil
 }
 
+// embedMemories stores an embedding of each memory's content when the LLM client supports embeddings
+func (s *AIContextService) embedMemories(ctx context.Context, memories []models.AIEnhancedMemoryEntry) error {
+	embedder, ok := s.grokService.(Embedder)
+	if !ok || len(memories) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(memories))
+	for i, memory := range memories {
+		texts[i] = memory.Content
+	}
+
+	embeddings, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	for i := range memories {
+		memories[i].Embedding = embeddings[i]
+	}
+
+	return nil
+}
+
+// findRelevantMemories returns the stored memories most similar to the user's message
+func (s *AIContextService) findRelevantMemories(ctx context.Context, conversationID primitive.ObjectID, userMsg *models.Message) []models.AIEnhancedMemoryEntry {
+	embedder, ok := s.grokService.(Embedder)
+	if !ok || userMsg.Text == nil || strings.TrimSpace(*userMsg.Text) == "" {
+		return nil
+	}
+
+	embeddings, err := embedder.Embed(ctx, []string{*userMsg.Text})
+	if err != nil {
+		s.options.logger().Error("Failed to embed user message", "error", err)
+		return nil
+	}
+
+	memories, err := s.repo.SearchMemoriesByEmbedding(ctx, conversationID, embeddings[0], relevantMemoryCount)
+	if err != nil {
+		s.options.logger().Error("Failed to search memories", "error", err)
+		return nil
+	}
+
+	return memories
+}
+
+// withRelevantMemories appends memories related to the current message to the prompt
+func withRelevantMemories(prompt string, memories []models.AIEnhancedMemoryEntry) string {
+	if len(memories) == 0 {
+		return prompt
+	}
+
+	var formatted []string
+	for _, memory := range memories {
+		formatted = append(formatted, "- "+memory.Content)
+	}
+
+	return fmt.Sprintf("%s\n\nRELATED MEMORIES:\n%s", prompt, strings.Join(formatted, "\n"))
+}
+
 // formatMessagesForAnalysis formats messages for memory analysis
 func (s *AIContextService) formatMessagesForAnalysis(messages []*models.Message) string {
 	var formatted []string
@@ -784,23 +1149,77 @@ func (s *AIContextService) updateConversationContextWithMemories(ctx context.Con
 		return fmt.Errorf("failed to get conversation context: %w", err)
 	}
 
-	// Add new memories to active memories
-	context.ActiveMemories = append(context.ActiveMemories, newMemories...)
-
-	// Keep only the most important and recent memories (limit to 20)
-	if len(context.ActiveMemories) > 20 {
-		// Sort by importance and recency
-		// For simplicity, we'll just keep the most recent ones
-		context.ActiveMemories = context.ActiveMemories[len(context.ActiveMemories)-20:]
+	// Add new memories to active memories; embeddings stay in the memory store to keep the context small
+	for _, memory := range newMemories {
+		memory.Embedding = nil
+		context.ActiveMemories = append(context.ActiveMemories, memory)
 	}
 
+	// Keep only the most important and recent memories
+	context.ActiveMemories = s.EvictMemories(context.ActiveMemories, maxActiveMemories)
+
 	// Update the context
 	context.UpdatedAt = time.Now()
 
 	// Save updated context
-	if err := s.repo.SaveConversationContext(ctx, context); err != nil {
+	if err := s.repo.SaveConversationContext(ctx, context, models.ContextActorSystem); err != nil {
 		return fmt.Errorf("failed to save updated conversation context: %w", err)
 	}
 
 	return nil
 }
+
+// learnTopicPreferences feeds topic signals from the latest exchange into the topic preference learner
+func (s *AIContextService) learnTopicPreferences(ctx context.Context, conversationID primitive.ObjectID, messages []*models.Message) error {
+	if s.topicLearner == nil {
+		return nil
+	}
+
+	conversationContext, err := s.repo.GetConversationContext(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation context: %w", err)
+	}
+
+	signals := topicSignals(conversationContext, messages)
+	if len(signals) == 0 {
+		return nil
+	}
+
+	conversation, err := s.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	return s.topicLearner.Learn(ctx, conversation.UserID, conversation.CompanionID, conversationID, signals)
+}
+
+// EvictMemories keeps the maxCount memories with the highest decayed importance score
+func (s *AIContextService) EvictMemories(memories []models.AIEnhancedMemoryEntry, maxCount int) []models.AIEnhancedMemoryEntry {
+	if len(memories) <= maxCount {
+		return memories
+	}
+	if maxCount <= 0 {
+		return []models.AIEnhancedMemoryEntry{}
+	}
+
+	now := s.now()
+	lambda := s.memoryDecayLambda
+	if lambda <= 0 {
+		lambda = DefaultMemoryDecayLambda
+	}
+	score := func(memory models.AIEnhancedMemoryEntry) float64 {
+		days := now.Sub(memory.LastReferenced).Hours() / 24
+		if days < 0 {
+			days = 0
+		}
+		return memory.Importance * math.Exp(-lambda*days)
+	}
+
+	ranked := make([]models.AIEnhancedMemoryEntry, len(memories))
+	copy(ranked, memories)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return score(ranked[i]) > score(ranked[j])
+	})
+
+	return ranked[:maxCount]
+}
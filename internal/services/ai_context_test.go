@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestEvictMemoriesKeepsImportantOldMemory(t *testing.T) {
+	now := time.Now()
+	memories := []models.AIEnhancedMemoryEntry{
+		{Content: "user is allergic to peanuts", Importance: 0.9, LastReferenced: now.AddDate(0, 0, -30)},
+		{Content: "user had toast for breakfast", Importance: 0.1, LastReferenced: now},
+	}
+
+	kept := (&AIContextService{now: time.Now}).EvictMemories(memories, 1)
+
+	assert.Len(t, kept, 1)
+	assert.Equal(t, "user is allergic to peanuts", kept[0].Content)
+}
+
+func TestEvictMemoriesConfiguredDecay(t *testing.T) {
+	now := time.Now()
+	memories := []models.AIEnhancedMemoryEntry{
+		{Content: "user is allergic to peanuts", Importance: 0.9, LastReferenced: now.AddDate(0, 0, -30)},
+		{Content: "user had toast for breakfast", Importance: 0.1, LastReferenced: now},
+	}
+	service := &AIContextService{now: func() time.Time { return now }, memoryDecayLambda: 0.5}
+
+	kept := service.EvictMemories(memories, 1)
+
+	assert.Len(t, kept, 1)
+	assert.Equal(t, "user had toast for breakfast", kept[0].Content, "a fast decay lets the fresh memory win")
+}
+
+func TestEvictMemoriesUnderLimit(t *testing.T) {
+	memories := []models.AIEnhancedMemoryEntry{
+		{Content: "a", Importance: 0.2, LastReferenced: time.Now()},
+	}
+
+	service := &AIContextService{now: time.Now}
+	assert.Equal(t, memories, service.EvictMemories(memories, 20))
+	assert.Empty(t, service.EvictMemories(memories, 0))
+}
+
+type mockLLM struct {
+	response string
+	err      error
+	prompts  [][]LLMMessage
+}
+
+func (m *mockLLM) SendMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	m.prompts = append(m.prompts, messages)
+	return m.response, m.err
+}
+
+func (m *mockLLM) SendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	m.prompts = append(m.prompts, messages)
+	return m.response, m.err
+}
+
+func TestDetectAndUpdateTopic(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("updates current topic", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "current_topic", Value: "general"},
+				{Key: "topic_history", Value: bson.A{"weather"}},
+			}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		llm := &mockLLM{response: "Travel\n"}
+		service := NewAIContextService(llm, repositories.NewConversationRepository(mt.DB), nil)
+
+		err := service.DetectAndUpdateTopic(context.Background(), conversationID, "I just booked flights to Lisbon!")
+		require.NoError(t, err)
+		assert.Len(t, llm.prompts, 1)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 3)
+		assert.Equal(t, "findAndModify", events[1].CommandName)
+		assert.Equal(t, "insert", events[2].CommandName)
+
+		set := events[1].Command.Lookup("update", "$set").Document()
+		assert.Equal(t, "travel", set.Lookup("current_topic").StringValue())
+
+		push := events[1].Command.Lookup("update", "$push", "topic_history").Document()
+		history, err := push.Lookup("$each").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, "general", history[0].StringValue())
+		assert.Equal(t, int32(-2), push.Lookup("$slice").Int32())
+	})
+}
+
+type mockEmbeddingLLM struct {
+	mockLLM
+	embedded []string
+}
+
+func (m *mockEmbeddingLLM) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	m.embedded = append(m.embedded, texts...)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func TestEmbedMemories(t *testing.T) {
+	memories := []models.AIEnhancedMemoryEntry{{Content: "has a dog"}, {Content: "likes jazz music"}}
+
+	t.Run("stores an embedding per memory", func(t *testing.T) {
+		llm := &mockEmbeddingLLM{}
+		service := &AIContextService{grokService: llm}
+
+		embedded := append([]models.AIEnhancedMemoryEntry(nil), memories...)
+		assert.NoError(t, service.embedMemories(context.Background(), embedded))
+
+		assert.Equal(t, []string{"has a dog", "likes jazz music"}, llm.embedded)
+		assert.Equal(t, []float32{9, 1}, embedded[0].Embedding)
+		assert.Equal(t, []float32{16, 1}, embedded[1].Embedding)
+	})
+
+	t.Run("skips clients without embeddings", func(t *testing.T) {
+		service := &AIContextService{grokService: &mockLLM{}}
+
+		plain := append([]models.AIEnhancedMemoryEntry(nil), memories...)
+		assert.NoError(t, service.embedMemories(context.Background(), plain))
+		assert.Nil(t, plain[0].Embedding)
+	})
+}
+
+func TestWithRelevantMemories(t *testing.T) {
+	assert.Equal(t, "prompt", withRelevantMemories("prompt", nil))
+	assert.Equal(t, "prompt\n\nRELATED MEMORIES:\n- has a dog\n- likes jazz", withRelevantMemories("prompt", []models.AIEnhancedMemoryEntry{
+		{Content: "has a dog"},
+		{Content: "likes jazz"},
+	}))
+}
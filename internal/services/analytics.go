@@ -3,32 +3,120 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/qualitytier"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// engagementAnomalyZScore is the z-score beyond which a dashboard engagement point is flagged
+const engagementAnomalyZScore = 2.0
+
+// dashboardHealthHistoryWeeks is the number of weekly health snapshots shown on the dashboard
+const dashboardHealthHistoryWeeks = 12
+
+// dashboardConversationArcs is the number of recent conversation arcs shown on the dashboard
+const dashboardConversationArcs = 5
+
 type AnalyticsService struct {
-	grokService *GrokService
+	grokService LLMClient
 	repo        *repositories.AnalyticsRepository
 	convRepo    *repositories.ConversationRepository
+	stageEngine *StageProgressionEngine
+
+	// Stats summary card sources, the repositories unless replaced in tests
+	historyStats companionHistorySource
+	summaryStats summaryProgressSource
+	percentiles  percentileRanker
+	now          func() time.Time
+
+	vocabulary        config.EmotionVocabulary
+	sentimentMatchers map[string]sentimentMatcherPair
+	languageDetector  *analytics.LanguageDetector
+	engagement        *analytics.EngagementNormaliser
+
+	engagementHooks []EngagementTrackedHook
+
+	options ServiceConfig
 }
 
-func NewAnalyticsService(grokService *GrokService, repo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository) *AnalyticsService {
-	return &AnalyticsService{
-		grokService: grokService,
-		repo:        repo,
-		convRepo:    convRepo,
+// EngagementTrackedHook is called after a session's engagement analytics have been saved
+type EngagementTrackedHook func(ctx context.Context, userID string, sessionData *SessionData)
+
+// AnalyticsOption sets an optional dependency or setting on an AnalyticsService. Every Option is an AnalyticsOption.
+type AnalyticsOption interface {
+	applyAnalytics(s *AnalyticsService)
+}
+
+// analyticsOption adapts a function to AnalyticsOption
+type analyticsOption func(s *AnalyticsService)
+
+func (o analyticsOption) applyAnalytics(s *AnalyticsService) { o(s) }
+
+func (o Option) applyAnalytics(s *AnalyticsService) { o(&s.options) }
+
+// WithEmotionVocabulary scores sentiment with the loaded vocabulary; a config that was never loaded keeps the embedded
+// default
+func WithEmotionVocabulary(vocabularyConfig config.EmotionVocabularyConfig) AnalyticsOption {
+	return analyticsOption(func(s *AnalyticsService) {
+		s.vocabulary = vocabularyConfig.Vocabulary
+	})
+}
+
+// WithLanguageDetector detects sentiment languages with detector instead of the vocabulary's characters and marker
+// words
+func WithLanguageDetector(detector *analytics.LanguageDetector) AnalyticsOption {
+	return analyticsOption(func(s *AnalyticsService) {
+		s.languageDetector = detector
+	})
+}
+
+// WithEngagementNormaliser corrects engagement scores for session length with n instead of the default parameters
+func WithEngagementNormaliser(n *analytics.EngagementNormaliser) AnalyticsOption {
+	return analyticsOption(func(s *AnalyticsService) {
+		s.engagement = n
+	})
+}
+
+// NewAnalyticsService creates an analytics service. Sentiment is scored with the embedded emotion vocabulary unless
+// WithEmotionVocabulary supplies another.
+func NewAnalyticsService(grokService LLMClient, repo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, opts ...AnalyticsOption) *AnalyticsService {
+	s := &AnalyticsService{
+		grokService:  grokService,
+		repo:         repo,
+		convRepo:     convRepo,
+		stageEngine:  NewStageProgressionEngine(nil),
+		historyStats: convRepo,
+		summaryStats: repo,
+		now:          time.Now,
 	}
+	for _, opt := range opts {
+		opt.applyAnalytics(s)
+	}
+	if len(s.vocabulary.Languages) == 0 {
+		s.vocabulary = config.DefaultEmotionVocabulary()
+	}
+	s.sentimentMatchers = newSentimentMatchers(s.vocabulary)
+	s.percentiles = NewPrivacyAnalyticsService(repo, convRepo, withServiceConfig(s.options))
+	s.stageEngine.OnTransition(s.awardStageAchievements)
+	return s
 }
 
 // TrackUserEngagement tracks comprehensive user engagement metrics
 func (s *AnalyticsService) TrackUserEngagement(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID, sessionData *SessionData) error {
+	ctx, span := s.options.tracer().Start(ctx, "AnalyticsService.TrackUserEngagement")
+	defer span.End()
+
 	// Get existing analytics or create new
 	analytics, err := s.repo.GetUserEngagementAnalytics(ctx, userID, companionID, conversationID)
 	if err != nil {
@@ -58,7 +146,12 @@ func (s *AnalyticsService) TrackUserEngagement(ctx context.Context, userID, comp
 	analytics.EmotionalIntensity = qualityMetrics.EmotionalIntensity
 	analytics.TopicDiversity = qualityMetrics.TopicDiversity
 	analytics.VulnerabilityLevel = qualityMetrics.VulnerabilityLevel
-	analytics.EngagementScore = qualityMetrics.EngagementScore
+	// The raw score ignores how long the session was, so short sessions are regressed toward 0.5
+	engagementScore := s.engagement.NormaliseEngagementScore(qualityMetrics.EngagementScore, sessionData.Duration, sessionData.MessageCount)
+	analytics.EngagementScore = engagementScore
+	sessionData.EngagementScore = engagementScore
+	analytics.QualityTier = qualitytier.FromEngagementScore(engagementScore)
+	analytics.XPMultiplier = analytics.QualityTier.Multiplier()
 
 	// Analyze behavioral patterns
 	behavioralPatterns, err := s.analyzeBehavioralPatterns(ctx, userID, companionID)
@@ -67,7 +160,12 @@ func (s *AnalyticsService) TrackUserEngagement(ctx context.Context, userID, comp
 	}
 
 	analytics.SessionFrequency = behavioralPatterns.SessionFrequency
-	analytics.PreferredTopics = behavioralPatterns.PreferredTopics
+	if len(behavioralPatterns.PreferredTopics) > 0 {
+		analytics.PreferredTopics = behavioralPatterns.PreferredTopics
+	}
+	if !personalisationEnabled(ctx, s.repo, userID) {
+		analytics.PreferredTopics = []string{}
+	}
 	analytics.InteractionStyle = behavioralPatterns.InteractionStyle
 
 	// Analyze relationship progression
@@ -82,18 +180,50 @@ func (s *AnalyticsService) TrackUserEngagement(ctx context.Context, userID, comp
 	analytics.MilestoneProgress = relationshipMetrics.MilestoneProgress
 
 	// Analyze emotional intelligence
-	emotionalMetrics, err := s.analyzeEmotionalIntelligence(ctx, conversationID, sessionData)
+	emotionalMetrics, err := s.analyzeEmotionalIntelligence(ctx, userID, companionID, conversationID, sessionData)
 	if err != nil {
 		return fmt.Errorf("failed to analyze emotional intelligence: %w", err)
 	}
 
 	analytics.SentimentTrend = emotionalMetrics.SentimentTrend
+	analytics.ConversationArc = emotionalMetrics.ConversationArc
 	analytics.EmotionalRegulation = emotionalMetrics.EmotionalRegulation
 	analytics.EmpathyResponse = emotionalMetrics.EmpathyResponse
 	analytics.MoodImpact = emotionalMetrics.MoodImpact
 
 	// Save analytics
-	return s.repo.UpsertUserEngagementAnalytics(ctx, analytics)
+	if err := s.repo.UpsertUserEngagementAnalytics(ctx, analytics); err != nil {
+		return err
+	}
+
+	s.updateChemistryScore(ctx, analytics)
+	for _, hook := range s.engagementHooks {
+		hook(ctx, userID, sessionData)
+	}
+	return nil
+}
+
+// OnEngagementTracked registers a hook to run after every session tracked by TrackUserEngagement
+func (s *AnalyticsService) OnEngagementTracked(hook EngagementTrackedHook) {
+	s.engagementHooks = append(s.engagementHooks, hook)
+}
+
+// updateChemistryScore rescores the relationship's chemistry now that engagement has new latest analytics.
+// Relationships without analytics have no trust or intimacy to score yet.
+func (s *AnalyticsService) updateChemistryScore(ctx context.Context, engagement *models.UserEngagementAnalytics) {
+	relationship, err := s.repo.GetRelationshipAnalytics(ctx, engagement.UserID, engagement.CompanionID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if !errors.As(err, &notFound) {
+			s.options.logger().Error("Failed to get relationship analytics for chemistry score", "error", err)
+		}
+		return
+	}
+
+	score := analytics.RelationshipChemistry(relationship, engagement)
+	if err := s.repo.SetChemistryScore(ctx, engagement.UserID, engagement.CompanionID, score); err != nil {
+		s.options.logger().Error("Failed to update chemistry score", "error", err)
+	}
 }
 
 // SessionData represents session information for analytics
@@ -104,6 +234,7 @@ type SessionData struct {
 	PeakActivityTime    time.Time
 	Messages            []*models.Message
 	ResponseQuality     float64
+	EngagementScore     float64 // latest ConversationQualityMetrics.EngagementScore normalised for session length, sets the XP quality tier
 }
 
 // ConversationQualityMetrics represents conversation quality analysis
@@ -117,6 +248,9 @@ type ConversationQualityMetrics struct {
 
 // analyzeConversationQuality analyzes the quality of a conversation
 func (s *AnalyticsService) analyzeConversationQuality(ctx context.Context, conversationID primitive.ObjectID, sessionData *SessionData) (*ConversationQualityMetrics, error) {
+	ctx, span := s.options.tracer().Start(ctx, "AnalyticsService.analyzeConversationQuality")
+	defer span.End()
+
 	// Get recent messages for analysis
 	messages, _, _, err := s.convRepo.ListMessages(ctx, conversationID, 50, nil)
 	if err != nil {
@@ -187,6 +321,9 @@ type BehavioralPatterns struct {
 
 // analyzeBehavioralPatterns analyzes user behavioral patterns
 func (s *AnalyticsService) analyzeBehavioralPatterns(ctx context.Context, userID, companionID string) (*BehavioralPatterns, error) {
+	ctx, span := s.options.tracer().Start(ctx, "AnalyticsService.analyzeBehavioralPatterns")
+	defer span.End()
+
 	// Get user progress to analyze patterns
 	progress, err := s.repo.GetUserProgress(ctx, userID, companionID)
 	if err != nil {
@@ -235,6 +372,9 @@ type RelationshipMetrics struct {
 
 // analyzeRelationshipProgression analyzes relationship development
 func (s *AnalyticsService) analyzeRelationshipProgression(ctx context.Context, userID, companionID string) (*RelationshipMetrics, error) {
+	ctx, span := s.options.tracer().Start(ctx, "AnalyticsService.analyzeRelationshipProgression")
+	defer span.End()
+
 	// Get relationship analytics
 	relationshipAnalytics, err := s.repo.GetRelationshipAnalytics(ctx, userID, companionID)
 	if err != nil {
@@ -257,13 +397,22 @@ func (s *AnalyticsService) analyzeRelationshipProgression(ctx context.Context, u
 // EmotionalMetrics represents emotional intelligence analysis
 type EmotionalMetrics struct {
 	SentimentTrend      []models.SentimentPoint
+	ConversationArc     models.ConversationArc
 	EmotionalRegulation float64
 	EmpathyResponse     float64
 	MoodImpact          float64
 }
 
 // analyzeEmotionalIntelligence analyzes emotional aspects of conversations
-func (s *AnalyticsService) analyzeEmotionalIntelligence(ctx context.Context, conversationID primitive.ObjectID, sessionData *SessionData) (*EmotionalMetrics, error) {
+func (s *AnalyticsService) analyzeEmotionalIntelligence(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID, sessionData *SessionData) (*EmotionalMetrics, error) {
+	ctx, span := s.options.tracer().Start(ctx, "AnalyticsService.analyzeEmotionalIntelligence")
+	defer span.End()
+
+	// Track how the user moves between emotional states
+	if err := s.updateEmotionTransitions(ctx, userID, companionID, conversationID); err != nil {
+		s.options.logger().Error("Failed to update emotion transition matrix", "error", err)
+	}
+
 	// Get recent messages for sentiment analysis
 	messages, _, _, err := s.convRepo.ListMessages(ctx, conversationID, 20, nil)
 	if err != nil {
@@ -277,12 +426,14 @@ func (s *AnalyticsService) analyzeEmotionalIntelligence(ctx context.Context, con
 
 	// Analyze sentiment trend
 	sentimentTrend := s.analyzeSentimentTrend(messages)
+	arc := analytics.ClassifyConversationArc(messages, sentimentTrend)
 
 	// Analyze emotional regulation and empathy
 	emotionalAnalysis, err := s.analyzeEmotionalPatterns(ctx, messages)
 	if err != nil {
 		return &EmotionalMetrics{
 			SentimentTrend:      sentimentTrend,
+			ConversationArc:     arc,
 			EmotionalRegulation: 0.5,
 			EmpathyResponse:     0.5,
 			MoodImpact:          0.5,
@@ -291,12 +442,42 @@ func (s *AnalyticsService) analyzeEmotionalIntelligence(ctx context.Context, con
 
 	return &EmotionalMetrics{
 		SentimentTrend:      sentimentTrend,
+		ConversationArc:     arc,
 		EmotionalRegulation: emotionalAnalysis.Regulation,
 		EmpathyResponse:     emotionalAnalysis.Empathy,
 		MoodImpact:          emotionalAnalysis.MoodImpact,
 	}, nil
 }
 
+// updateEmotionTransitions recomputes the relationship's emotion transition matrix from the conversation's emotional history
+func (s *AnalyticsService) updateEmotionTransitions(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID) error {
+	conversationContext, err := s.convRepo.GetConversationContext(ctx, conversationID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+
+	matrix := analytics.ComputeEmotionTransitionMatrix(conversationContext.EmotionalHistory)
+	if len(matrix) == 0 {
+		return nil
+	}
+
+	return s.repo.UpdateEmotionTransitionMatrix(ctx, userID, companionID, matrix)
+}
+
+// GetPlatformEmotionTransitions returns the emotion transition matrix across all users
+func (s *AnalyticsService) GetPlatformEmotionTransitions(ctx context.Context) (map[string]map[string]float64, error) {
+	counts, err := s.repo.GetEmotionTransitionCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count emotion transitions: %w", err)
+	}
+
+	return analytics.NormalizeTransitionCounts(counts), nil
+}
+
 // analyzeSentimentTrend analyzes sentiment over time
 func (s *AnalyticsService) analyzeSentimentTrend(messages []*models.Message) []models.SentimentPoint {
 	var sentimentPoints []models.SentimentPoint
@@ -333,67 +514,37 @@ type SimpleSentiment struct {
 	Dominant  string
 }
 
+// sentimentMatcherPair holds the keyword automata for one language
+type sentimentMatcherPair struct {
+	positive *analytics.KeywordMatcher
+	negative *analytics.KeywordMatcher
+}
+
+// newSentimentMatchers builds a sentimentMatcherPair per language code in the vocabulary
+func newSentimentMatchers(vocabulary config.EmotionVocabulary) map[string]sentimentMatcherPair {
+	matchers := make(map[string]sentimentMatcherPair, len(vocabulary.Languages))
+	for _, lang := range vocabulary.Languages {
+		matchers[lang.Code] = sentimentMatcherPair{
+			positive: analytics.NewKeywordMatcher(lang.Positive),
+			negative: analytics.NewKeywordMatcher(lang.Negative),
+		}
+	}
+	return matchers
+}
+
 // calculateSimpleSentiment performs basic sentiment analysis
 func (s *AnalyticsService) calculateSimpleSentiment(text string) SimpleSentiment {
 	text = strings.ToLower(text)
 
-	// Multi-language sentiment dictionaries
-	sentimentWords := map[string]map[string][]string{
-		"positive": {
-			"en": {"love", "happy", "great", "wonderful", "amazing", "good", "excellent", "fantastic", "beautiful", "perfect", "joy", "excited", "grateful", "blessed", "awesome", "incredible", "outstanding", "brilliant", "splendid", "magnificent"},
-			"es": {"amor", "feliz", "genial", "maravilloso", "increíble", "bueno", "excelente", "fantástico", "hermoso", "perfecto", "alegría", "emocionado", "agradecido", "bendecido", "asombroso", "increíble", "sobresaliente", "brillante", "espléndido", "magnífico"},
-			"fr": {"amour", "heureux", "génial", "merveilleux", "incroyable", "bon", "excellent", "fantastique", "beau", "parfait", "joie", "excité", "reconnaissant", "béni", "formidable", "incroyable", "exceptionnel", "brillant", "splendide", "magnifique"},
-			"de": {"liebe", "glücklich", "großartig", "wunderbar", "unglaublich", "gut", "ausgezeichnet", "fantastisch", "schön", "perfekt", "freude", "aufgeregt", "dankbar", "gesegnet", "großartig", "unglaublich", "hervorragend", "brillant", "prächtig", "magnifik"},
-			"it": {"amore", "felice", "fantastico", "meraviglioso", "incredibile", "buono", "eccellente", "fantastico", "bello", "perfetto", "gioia", "eccitato", "grato", "benedetto", "fantastico", "incredibile", "eccezionale", "brillante", "splendido", "magnifico"},
-			"pt": {"amor", "feliz", "ótimo", "maravilhoso", "incrível", "bom", "excelente", "fantástico", "bonito", "perfeito", "alegria", "empolgado", "grato", "abençoado", "incrível", "inacreditável", "excepcional", "brilhante", "esplêndido", "magnífico"},
-			"ru": {"любовь", "счастливый", "отличный", "чудесный", "удивительный", "хороший", "отличный", "фантастический", "красивый", "идеальный", "радость", "взволнованный", "благодарный", "благословенный", "потрясающий", "невероятный", "выдающийся", "блестящий", "великолепный", "величественный"},
-			"ja": {"愛", "幸せ", "素晴らしい", "素敵", "信じられない", "良い", "優秀", "素晴らしい", "美しい", "完璧", "喜び", "興奮", "感謝", "祝福", "素晴らしい", "信じられない", "卓越", "輝かしい", "華麗", "壮大"},
-			"ko": {"사랑", "행복", "훌륭한", "멋진", "놀라운", "좋은", "훌륭한", "환상적인", "아름다운", "완벽한", "기쁨", "흥분", "감사한", "축복받은", "놀라운", "믿을 수 없는", "뛰어난", "빛나는", "화려한", "장엄한"},
-			"zh": {"爱", "快乐", "伟大", "精彩", "惊人", "好", "优秀", "精彩", "美丽", "完美", "喜悦", "兴奋", "感激", "祝福", "惊人", "难以置信", "杰出", "辉煌", "华丽", "宏伟"},
-		},
-		"negative": {
-			"en": {"sad", "angry", "terrible", "awful", "bad", "horrible", "disappointed", "frustrated", "upset", "worried", "depressed", "anxious", "scared", "lonely", "hurt", "pain", "suffering", "miserable", "hopeless", "desperate"},
-			"es": {"triste", "enojado", "terrible", "horrible", "malo", "horrible", "decepcionado", "frustrado", "molesto", "preocupado", "deprimido", "ansioso", "asustado", "solo", "herido", "dolor", "sufrimiento", "miserable", "desesperado", "desesperado"},
-			"fr": {"triste", "fâché", "terrible", "affreux", "mauvais", "horrible", "déçu", "frustré", "contrarié", "inquiet", "déprimé", "anxieux", "effrayé", "seul", "blessé", "douleur", "souffrance", "misérable", "désespéré", "désespéré"},
-			"de": {"traurig", "wütend", "schrecklich", "furchtbar", "schlecht", "schrecklich", "enttäuscht", "frustriert", "verärgert", "besorgt", "deprimiert", "ängstlich", "verängstigt", "einsam", "verletzt", "schmerz", "leiden", "elend", "hoffnungslos", "verzweifelt"},
-			"it": {"triste", "arrabbiato", "terribile", "orribile", "cattivo", "orribile", "deluso", "frustrato", "turbato", "preoccupato", "depresso", "ansioso", "spaventato", "solo", "ferito", "dolore", "sofferenza", "miserabile", "disperato", "disperato"},
-			"pt": {"triste", "irritado", "terrível", "horrível", "ruim", "horrível", "decepcionado", "frustrado", "chateado", "preocupado", "deprimido", "ansioso", "assustado", "sozinho", "machucado", "dor", "sofrimento", "miserável", "desesperado", "desesperado"},
-			"ru": {"грустный", "злой", "ужасный", "ужасный", "плохой", "ужасный", "разочарованный", "разочарованный", "расстроенный", "обеспокоенный", "подавленный", "тревожный", "испуганный", "одинокий", "раненый", "боль", "страдание", "несчастный", "безнадежный", "отчаянный"},
-			"ja": {"悲しい", "怒った", "ひどい", "恐ろしい", "悪い", "恐ろしい", "失望", "イライラ", "動揺", "心配", "落ち込んだ", "不安", "怖い", "孤独", "傷ついた", "痛み", "苦しみ", "惨め", "絶望的", "絶望的"},
-			"ko": {"슬픈", "화난", "끔찍한", "무서운", "나쁜", "끔찍한", "실망한", "좌절한", "화난", "걱정하는", "우울한", "불안한", "무서워하는", "외로운", "상처받은", "고통", "고통", "비참한", "절망적인", "절망적인"},
-			"zh": {"悲伤", "愤怒", "可怕", "可怕", "坏", "可怕", "失望", "沮丧", "心烦", "担心", "沮丧", "焦虑", "害怕", "孤独", "受伤", "痛苦", "痛苦", "悲惨", "绝望", "绝望"},
-		},
-	}
-
-	// Detect language (simplified - in production, use a proper language detection library)
 	detectedLang := s.detectLanguage(text)
 
-	// Get sentiment words for detected language, fallback to English
-	positiveWords, ok := sentimentWords["positive"][detectedLang]
-	if !ok {
-		positiveWords = sentimentWords["positive"]["en"]
-	}
-
-	negativeWords, ok := sentimentWords["negative"][detectedLang]
+	// Get sentiment matchers for detected language, fallback to the vocabulary's default
+	pair, ok := s.sentimentMatchers[detectedLang]
 	if !ok {
-		negativeWords = sentimentWords["negative"]["en"]
-	}
-
-	positiveCount := 0
-	negativeCount := 0
-
-	// Count sentiment words
-	for _, word := range positiveWords {
-		if strings.Contains(text, word) {
-			positiveCount++
-		}
-	}
-
-	for _, word := range negativeWords {
-		if strings.Contains(text, word) {
-			negativeCount++
-		}
+		pair = s.sentimentMatchers[s.vocabulary.DefaultLanguage]
 	}
+	positiveCount := pair.positive.CountMatches(text)
+	negativeCount := pair.negative.CountMatches(text)
 
 	// Calculate sentiment score
 	total := positiveCount + negativeCount
@@ -422,59 +573,28 @@ func (s *AnalyticsService) calculateSimpleSentiment(text string) SimpleSentiment
 	}
 }
 
-// detectLanguage performs basic language detection
+// detectLanguage returns the language code of text, from the language detector when one is configured
 func (s *AnalyticsService) detectLanguage(text string) string {
-	// Simple language detection based on character sets
-	// In production, use a proper language detection library like "github.com/bbalet/stopwords"
-
-	// Check for Chinese characters
-	if strings.ContainsAny(text, "的一是在不了有和人这中大为上个国我以要他时来用们生到作地于出就分对成会可主发年动同工也能下过子说产种面而方后多定行学法所民得经十三之进着等部度家电力里如水化高自二理起小物现实加量都两体制机当使点从业本去把性好应开它合还因由其些然前外天政四日那社义事平形相全表间样与关各重新线内数正心反你明看原又么利比或但质气第向道命此变条只没结解问意建月公无系军很情者最立代想已通并提直题党程展五果料象员革位入常文总次品式活设及管特件长求老头基资边流路级少图山统接知较将组见计别她手角期根论运农指几九区强放决西被干做必战先回则任取据处队南给色光门即保治北造百规热领七海口东导器压志世金增争济阶油思术极交受联什认六共权收证改清己美再采转更单风切打白教速花带安场身车例真务具万每目至达走积示议声报斗完类八离华名确才科张信马节话米整空元况今集温传土许步群广石记需段研界拉林律叫且究观越织装影算低持音众书布复容儿须际商非验连断深难近矿千周委素技备半办青省列习响约支般史感劳便团往酸历市克何除消构府称太准精值号率族维划选标写存候毛亲快效斯院查江型眼王按格养易置派层片始却专状育厂京识适属圆包火住调满县局照参红细引听该铁价严龙飞") {
-		return "zh"
-	}
-
-	// Check for Japanese characters
-	if strings.ContainsAny(text, "あいうえおかきくけこさしすせそたちつてとなにぬねのはひふへほまみむめもやゆよらりるれろわをんアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワヲン") {
-		return "ja"
-	}
-
-	// Check for Korean characters
-	if strings.ContainsAny(text, "가나다라마바사아자차카타파하거너더러머버서어저처커터퍼허기니디리미비시이지치키티피히구누두루무부수우주추쿠투푸후그느드르므브스으즈츠크트프흐긔늬듸리미비시이지치키티피히") {
-		return "ko"
-	}
-
-	// Check for Russian characters
-	if strings.ContainsAny(text, "абвгдеёжзийклмнопрстуфхцчшщъыьэюяАБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ") {
-		return "ru"
-	}
-
-	// Check for common European language patterns
-	// Spanish: common words like "el", "la", "de", "que", "y"
-	if strings.Contains(text, " el ") || strings.Contains(text, " la ") || strings.Contains(text, " de ") || strings.Contains(text, " que ") || strings.Contains(text, " y ") {
-		return "es"
-	}
-
-	// French: common words like "le", "la", "de", "et", "que"
-	if strings.Contains(text, " le ") || strings.Contains(text, " la ") || strings.Contains(text, " de ") || strings.Contains(text, " et ") || strings.Contains(text, " que ") {
-		return "fr"
-	}
-
-	// German: common words like "der", "die", "das", "und", "in"
-	if strings.Contains(text, " der ") || strings.Contains(text, " die ") || strings.Contains(text, " das ") || strings.Contains(text, " und ") || strings.Contains(text, " in ") {
-		return "de"
-	}
-
-	// Italian: common words like "il", "la", "di", "e", "che"
-	if strings.Contains(text, " il ") || strings.Contains(text, " la ") || strings.Contains(text, " di ") || strings.Contains(text, " e ") || strings.Contains(text, " che ") {
-		return "it"
+	if s.languageDetector != nil {
+		return s.languageDetector.Detect(text)
 	}
+	return s.detectLanguageHeuristic(text)
+}
 
-	// Portuguese: common words like "o", "a", "de", "e", "que"
-	if strings.Contains(text, " o ") || strings.Contains(text, " a ") || strings.Contains(text, " de ") || strings.Contains(text, " e ") || strings.Contains(text, " que ") {
-		return "pt"
+// detectLanguageHeuristic returns the first vocabulary language whose characters or marker words appear in text
+func (s *AnalyticsService) detectLanguageHeuristic(text string) string {
+	for _, lang := range s.vocabulary.Languages {
+		if lang.Characters != "" && strings.ContainsAny(text, lang.Characters) {
+			return lang.Code
+		}
+		for _, marker := range lang.Markers {
+			if strings.Contains(text, " "+marker+" ") {
+				return lang.Code
+			}
+		}
 	}
 
-	// Default to English
-	return "en"
+	return s.vocabulary.DefaultLanguage
 }
 
 // EmotionalAnalysis represents emotional pattern analysis
@@ -587,17 +707,36 @@ func (s *AnalyticsService) ProcessUserProgress(ctx context.Context, userID, comp
 		progress.AverageSessionLength = progress.TotalTimeSpent / time.Duration(progress.TotalConversations)
 	}
 
-	// Update streak
-	s.updateStreak(progress)
-
 	// Update achievement progress
 	s.updateAchievementProgress(ctx, progress, sessionData)
 
 	// Save progress
-	return s.repo.UpsertUserProgress(ctx, progress)
+	if err := s.repo.UpsertUserProgress(ctx, progress); err != nil {
+		return err
+	}
+
+	// The streak is advanced in place rather than saved with the rest of the progress, so concurrent sessions
+	// cannot both count the same day
+	if _, err := s.repo.AtomicStreakIncrement(ctx, userID, companionID, time.Now()); err != nil {
+		return fmt.Errorf("failed to update streak: %w", err)
+	}
+	s.recordExperience(ctx, userID, companionID, experienceGained, models.ExperienceSourceSession)
+	return nil
+}
+
+// recordExperience adds experience to the gains totalled for the stats summary card. A failure is only logged,
+// since the progress the experience counts towards is already saved.
+func (s *AnalyticsService) recordExperience(ctx context.Context, userID, companionID string, amount int, source string) {
+	if amount <= 0 {
+		return
+	}
+	gain := &models.ExperienceGain{UserID: userID, CompanionID: companionID, Amount: amount, Source: source}
+	if err := s.repo.RecordExperienceGain(ctx, gain); err != nil {
+		s.options.logger().Error("Failed to record experience gain", "user_id", userID, "companion_id", companionID, "error", err)
+	}
 }
 
-// calculateExperiencePoints calculates experience points for a session
+// calculateExperiencePoints calculates experience points for a session, scaled by its quality tier
 func (s *AnalyticsService) calculateExperiencePoints(sessionData *SessionData) int {
 	basePoints := 10
 
@@ -619,7 +758,9 @@ func (s *AnalyticsService) calculateExperiencePoints(sessionData *SessionData) i
 		engagementBonus += 5
 	}
 
-	return basePoints + durationBonus + messageBonus + qualityBonus + engagementBonus
+	total := basePoints + durationBonus + messageBonus + qualityBonus + engagementBonus
+	multiplier := qualitytier.FromEngagementScore(sessionData.EngagementScore).Multiplier()
+	return int(math.Round(float64(total) * multiplier))
 }
 
 // calculateLevel calculates user level based on experience
@@ -653,30 +794,6 @@ func (s *AnalyticsService) calculateExperienceToNext(experience int) int {
 	return experienceForNextLevel - experience
 }
 
-// updateStreak updates user streak information
-func (s *AnalyticsService) updateStreak(progress *models.UserProgress) {
-	today := time.Now().Truncate(24 * time.Hour)
-	lastActivity := progress.LastActivityDate.Truncate(24 * time.Hour)
-
-	if today.Equal(lastActivity) {
-		// Already updated today
-		return
-	}
-
-	if today.Sub(lastActivity) == 24*time.Hour {
-		// Consecutive day
-		progress.CurrentStreak++
-		if progress.CurrentStreak > progress.LongestStreak {
-			progress.LongestStreak = progress.CurrentStreak
-		}
-	} else if today.Sub(lastActivity) > 24*time.Hour {
-		// Streak broken
-		progress.CurrentStreak = 1
-	}
-
-	progress.LastActivityDate = today
-}
-
 // updateAchievementProgress updates achievement progress
 func (s *AnalyticsService) updateAchievementProgress(ctx context.Context, progress *models.UserProgress, sessionData *SessionData) {
 	// Get achievement definitions
@@ -755,43 +872,52 @@ func (s *AnalyticsService) awardAchievement(ctx context.Context, progress *model
 
 	// Add bonus experience
 	progress.TotalExperience += definition.Points * 10
+	s.recordExperience(ctx, progress.UserID, progress.CompanionID, definition.Points*10, models.ExperienceSourceAchievement)
 }
 
 // GetUserDashboardData gets comprehensive dashboard data for a user
 func (s *AnalyticsService) GetUserDashboardData(ctx context.Context, userID, companionID string) (*models.UserDashboardData, error) {
-	// Get user progress
-	progress, err := s.repo.GetUserProgress(ctx, userID, companionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user progress: %w", err)
-	}
-
-	// Get recent achievements
-	achievements, err := s.repo.GetUserAchievements(ctx, userID, companionID, 5)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	// Get progress, achievements, relationship analytics and trends, in one round-trip where the server supports it
+	var dashboard *models.UserDashboardData
+	var err error
+	if s.repo.SupportsDashboardAggregation(ctx) {
+		dashboard, err = s.repo.GetUserDashboardDataAggregated(ctx, userID, companionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dashboard data: %w", err)
+		}
+	} else {
+		dashboard, err = s.getDashboardDataSeparately(ctx, userID, companionID)
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	// Get relationship analytics
-	relationshipAnalytics, err := s.repo.GetRelationshipAnalytics(ctx, userID, companionID)
-	if err != nil {
+	if dashboard.RelationshipAnalytics == nil {
 		// Create empty analytics if not found
-		relationshipAnalytics = &models.RelationshipAnalytics{
+		dashboard.RelationshipAnalytics = &models.RelationshipAnalytics{
 			UserID:      userID,
 			CompanionID: companionID,
 		}
 	}
+	progress, relationshipAnalytics := dashboard.Progress, dashboard.RelationshipAnalytics
 
-	// Get engagement trends
-	trends, err := s.repo.GetEngagementTrends(ctx, userID, companionID, 30)
+	// Get weekly health score history
+	healthHistory, err := s.repo.GetHealthScoreHistory(ctx, userID, companionID, dashboardHealthHistoryWeeks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get engagement trends: %w", err)
+		return nil, fmt.Errorf("failed to get health score history: %w", err)
+	}
+
+	// Get the arcs of recent conversations
+	arcs, err := s.repo.GetRecentConversationArcs(ctx, userID, companionID, dashboardConversationArcs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation arcs: %w", err)
 	}
 
 	// Get user statistics
-	statistics, err := s.repo.GetUserStatistics(ctx, userID, companionID)
+	statistics, err := s.GetUserStatistics(ctx, userID, companionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get statistics: %w", err)
 	}
+	s.addPercentiles(ctx, userID, statistics)
 
 	// Get streak information
 	streakInfo, err := s.repo.GetStreakInformation(ctx, userID, companionID)
@@ -800,30 +926,64 @@ func (s *AnalyticsService) GetUserDashboardData(ctx context.Context, userID, com
 	}
 
 	// Generate recommendations
-	recommendations := s.generateRecommendations(progress, relationshipAnalytics, statistics)
+	recommendations, recommendationMetadata := s.generateRecommendations(ctx, userID, progress, relationshipAnalytics, statistics)
 
 	// Get next milestones
 	nextMilestones := s.getNextMilestones(progress, relationshipAnalytics)
 
-	dashboard := &models.UserDashboardData{
+	dashboard.EngagementAnomalies = analytics.DetectEngagementAnomalies(dashboard.EngagementTrends, engagementAnomalyZScore)
+	dashboard.HealthScoreHistory = healthHistory
+	dashboard.ConversationArcs = arcs
+	dashboard.Recommendations = recommendations
+	dashboard.RecommendationMetadata = recommendationMetadata
+	dashboard.NextMilestones = nextMilestones
+	dashboard.Statistics = statistics
+	dashboard.StreakInfo = streakInfo
+	dashboard.LastUpdated = time.Now()
+
+	return dashboard, nil
+}
+
+// getDashboardDataSeparately loads what GetUserDashboardDataAggregated would with one query per collection, for
+// MongoDB releases older than 4.0
+func (s *AnalyticsService) getDashboardDataSeparately(ctx context.Context, userID, companionID string) (*models.UserDashboardData, error) {
+	// Get user progress
+	progress, err := s.repo.GetUserProgress(ctx, userID, companionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user progress: %w", err)
+	}
+
+	// Get recent achievements
+	achievements, err := s.repo.GetUserAchievements(ctx, userID, companionID, repositories.DashboardRecentAchievements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	}
+
+	// Get relationship analytics, left unset if not found
+	relationshipAnalytics, _ := s.repo.GetRelationshipAnalytics(ctx, userID, companionID)
+
+	// Get engagement trends
+	trends, err := s.repo.GetEngagementTrends(ctx, userID, companionID, repositories.DashboardTrendDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get engagement trends: %w", err)
+	}
+
+	return &models.UserDashboardData{
 		UserID:                userID,
 		CompanionID:           companionID,
 		Progress:              progress,
 		RecentAchievements:    achievements,
 		RelationshipAnalytics: relationshipAnalytics,
 		EngagementTrends:      trends,
-		Recommendations:       recommendations,
-		NextMilestones:        nextMilestones,
-		Statistics:            statistics,
-		StreakInfo:            streakInfo,
-		LastUpdated:           time.Now(),
-	}
-
-	return dashboard, nil
+	}, nil
 }
 
-// generateRecommendations generates personalized recommendations
-func (s *AnalyticsService) generateRecommendations(progress *models.UserProgress, relationshipAnalytics *models.RelationshipAnalytics, statistics *models.UserStatistics) []models.Recommendation {
+// generateRecommendations generates personalized recommendations along with response metadata
+func (s *AnalyticsService) generateRecommendations(ctx context.Context, userID string, progress *models.UserProgress, relationshipAnalytics *models.RelationshipAnalytics, statistics *models.UserStatistics) ([]models.Recommendation, map[string]any) {
+	if !personalisationEnabled(ctx, s.repo, userID) {
+		return []models.Recommendation{}, map[string]any{"privacy_suppressed": true}
+	}
+
 	var recommendations []models.Recommendation
 
 	// Recommendation based on session frequency
@@ -862,7 +1022,7 @@ func (s *AnalyticsService) generateRecommendations(progress *models.UserProgress
 		})
 	}
 
-	return recommendations
+	return recommendations, map[string]any{"privacy_suppressed": false}
 }
 
 // getNextMilestones gets upcoming milestones for the user
@@ -902,9 +1062,165 @@ func (s *AnalyticsService) GetEngagementTrends(ctx context.Context, userID, comp
 	return s.repo.GetEngagementTrends(ctx, userID, companionID, days)
 }
 
-// GetUserStatistics gets user statistics
+// GetUserStatistics gets user statistics from the daily rollup once FlagStatisticsRollupReads is on for the user, and
+// from live aggregation until then
 func (s *AnalyticsService) GetUserStatistics(ctx context.Context, userID, companionID string) (*models.UserStatistics, error) {
-	return s.repo.GetUserStatistics(ctx, userID, companionID)
+	if !s.options.flagEnabled(ctx, FlagStatisticsRollupReads, userID) {
+		return s.repo.GetUserStatistics(ctx, userID, companionID)
+	}
+	return userStatisticsFromRollup(ctx, s.repo, userID, companionID)
+}
+
+// GetMultiCompanionSummary compares engagement and relationship health across all of a user's companions
+func (s *AnalyticsService) GetMultiCompanionSummary(ctx context.Context, userID string) (*models.MultiCompanionSummary, error) {
+	progressList, err := s.repo.GetAllUserProgress(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user progress: %w", err)
+	}
+
+	relationships, err := s.repo.GetAllRelationshipAnalytics(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relationship analytics: %w", err)
+	}
+
+	engagementScores, err := s.repo.GetCompanionEngagementScores(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get engagement scores: %w", err)
+	}
+
+	entries := make(map[string]*models.CompanionSummaryEntry)
+	entryFor := func(companionID string) *models.CompanionSummaryEntry {
+		entry, ok := entries[companionID]
+		if !ok {
+			entry = &models.CompanionSummaryEntry{
+				CompanionID:     companionID,
+				EngagementScore: engagementScores[companionID],
+			}
+			entries[companionID] = entry
+		}
+		return entry
+	}
+
+	for _, progress := range progressList {
+		entry := entryFor(progress.CompanionID)
+		entry.CurrentLevel = progress.CurrentLevel
+		entry.TotalMessages = progress.TotalMessages
+		entry.CurrentStreak = progress.CurrentStreak
+		if entry.RelationshipStage == "" {
+			entry.RelationshipStage = progress.RelationshipStage
+		}
+	}
+
+	for _, relationship := range relationships {
+		entry := entryFor(relationship.CompanionID)
+		entry.HealthScore = relationship.HealthScore
+		entry.IntimacyLevel = relationship.IntimacyLevel
+		entry.RelationshipStage = relationship.CurrentStage
+	}
+
+	companions := make([]models.CompanionSummaryEntry, 0, len(entries))
+	for _, entry := range entries {
+		companions = append(companions, *entry)
+	}
+
+	// Rank by health first so the primary companion can be picked, then order by engagement
+	sort.SliceStable(companions, func(i, j int) bool {
+		if companions[i].HealthScore != companions[j].HealthScore {
+			return companions[i].HealthScore > companions[j].HealthScore
+		}
+		return companions[i].CompanionID < companions[j].CompanionID
+	})
+	for i := range companions {
+		companions[i].HealthRank = i + 1
+	}
+
+	summary := &models.MultiCompanionSummary{UserID: userID}
+	if len(companions) > 0 {
+		summary.PrimaryCompanionID = companions[0].CompanionID
+	}
+
+	sort.SliceStable(companions, func(i, j int) bool {
+		if companions[i].EngagementScore != companions[j].EngagementScore {
+			return companions[i].EngagementScore > companions[j].EngagementScore
+		}
+		return companions[i].CompanionID < companions[j].CompanionID
+	})
+	for i := range companions {
+		companions[i].EngagementRank = i + 1
+	}
+	summary.Companions = companions
+
+	return summary, nil
+}
+
+// UpsertRelationshipAnalytics advances the relationship stage if needed and saves the analytics
+func (s *AnalyticsService) UpsertRelationshipAnalytics(ctx context.Context, analytics *models.RelationshipAnalytics) error {
+	s.stageEngine.Apply(ctx, analytics)
+	if err := refreshChemistryScore(ctx, s.repo, analytics); err != nil {
+		s.options.logger().Error("Failed to refresh chemistry score", "error", err)
+	}
+
+	if err := s.repo.UpsertRelationshipAnalytics(ctx, analytics); err != nil {
+		return fmt.Errorf("failed to upsert relationship analytics: %w", err)
+	}
+	return nil
+}
+
+// latestEngagementSource reads the engagement analytics a chemistry score is computed from, implemented by
+// *repositories.AnalyticsRepository
+type latestEngagementSource interface {
+	GetRecentEngagementAnalytics(ctx context.Context, userID, companionID string, limit int) ([]models.UserEngagementAnalytics, error)
+}
+
+// refreshChemistryScore recomputes the relationship's chemistry score from its trust and intimacy and the latest
+// engagement analytics. On error the score is left as it was.
+func refreshChemistryScore(ctx context.Context, source latestEngagementSource, relationship *models.RelationshipAnalytics) error {
+	records, err := source.GetRecentEngagementAnalytics(ctx, relationship.UserID, relationship.CompanionID, 1)
+	if err != nil {
+		return fmt.Errorf("failed to get latest engagement analytics: %w", err)
+	}
+
+	var latest *models.UserEngagementAnalytics
+	if len(records) > 0 {
+		latest = &records[0]
+	}
+	relationship.ChemistryScore = analytics.RelationshipChemistry(relationship, latest)
+	return nil
+}
+
+// awardStageAchievements awards achievements unlocked by reaching a relationship stage
+func (s *AnalyticsService) awardStageAchievements(ctx context.Context, analytics *models.RelationshipAnalytics, transition models.StageTransition) {
+	definitions, err := s.repo.GetAchievementDefinitions(ctx, "relationship")
+	if err != nil {
+		s.options.logger().Error("Failed to get achievement definitions for stage transition", "error", err)
+		return
+	}
+
+	progress, err := s.repo.GetUserProgress(ctx, analytics.UserID, analytics.CompanionID)
+	if err != nil {
+		progress = &models.UserProgress{UserID: analytics.UserID, CompanionID: analytics.CompanionID}
+	}
+
+	awarded := false
+	for _, definition := range definitions {
+		if definition.Criteria.Type != "relationship_stage" || definition.Criteria.Conditions["stage"] != transition.ToStage {
+			continue
+		}
+
+		earned, err := s.repo.CheckAchievementEarned(ctx, analytics.UserID, analytics.CompanionID, definition.ID)
+		if err != nil || earned {
+			continue
+		}
+
+		s.awardAchievement(ctx, progress, &definition)
+		awarded = true
+	}
+
+	if awarded {
+		if err := s.repo.UpsertUserProgress(ctx, progress); err != nil {
+			s.options.logger().Error("Failed to update progress after stage transition", "error", err)
+		}
+	}
 }
 
 // GetRelationshipAnalytics gets relationship analytics
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/qualitytier"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTrackUserEngagementSpans(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("child spans", func(mt *mtest.T) {
+		grokServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer grokServer.Close()
+
+		emptyCursor := func(ns string) bson.D {
+			return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch)
+		}
+		mt.AddMockResponses(
+			emptyCursor("lunaria.user_engagement_analytics"),
+			emptyCursor("lunaria.messages"),
+			emptyCursor("lunaria.user_progress"),
+			emptyCursor("lunaria.user_privacy_settings"),
+			emptyCursor("lunaria.relationship_analytics"),
+			emptyCursor("lunaria.conversation_contexts"),
+			emptyCursor("lunaria.messages"),
+			mtest.CreateSuccessResponse(),
+			emptyCursor("lunaria.relationship_analytics"),
+		)
+
+		exporter := tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter)))
+
+		service := NewAnalyticsService(
+			NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL}),
+			repositories.NewAnalyticsRepository(nil, mt.DB),
+			repositories.NewConversationRepository(mt.DB),
+			WithTracer(provider.Tracer("test")),
+		)
+
+		err := service.TrackUserEngagement(context.Background(), "user", "companion", primitive.NewObjectID(), &SessionData{})
+		require.NoError(t, err)
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 7, "five service spans and a client span for each Grok call")
+
+		var root tracetest.SpanStub
+		children := map[string]tracetest.SpanStub{}
+		var grokCalls []tracetest.SpanStub
+		for _, span := range spans {
+			switch span.Name {
+			case "AnalyticsService.TrackUserEngagement":
+				root = span
+			case "HTTP POST":
+				grokCalls = append(grokCalls, span)
+			default:
+				children[span.Name] = span
+			}
+		}
+		require.Len(t, grokCalls, 2)
+		for _, call := range grokCalls {
+			assert.Equal(t, root.SpanContext.TraceID(), call.SpanContext.TraceID())
+		}
+
+		for _, name := range []string{
+			"AnalyticsService.analyzeConversationQuality",
+			"AnalyticsService.analyzeBehavioralPatterns",
+			"AnalyticsService.analyzeRelationshipProgression",
+			"AnalyticsService.analyzeEmotionalIntelligence",
+		} {
+			child, ok := children[name]
+			require.True(t, ok, name)
+			assert.Equal(t, root.SpanContext.SpanID(), child.Parent.SpanID(), name)
+			assert.Equal(t, root.SpanContext.TraceID(), child.SpanContext.TraceID(), name)
+		}
+	})
+}
+
+func TestGetMultiCompanionSummary(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("orders by engagement", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch,
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "luna"}, {Key: "current_level", Value: 4}, {Key: "total_messages", Value: 120}},
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "nova"}, {Key: "current_level", Value: 2}, {Key: "total_messages", Value: 40}},
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "stella"}, {Key: "current_level", Value: 7}, {Key: "total_messages", Value: 300}},
+			),
+			mtest.CreateCursorResponse(0, "lunaria.relationship_analytics", mtest.FirstBatch,
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "luna"}, {Key: "health_score", Value: 0.6}, {Key: "current_stage", Value: "friendship"}},
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "nova"}, {Key: "health_score", Value: 0.9}, {Key: "current_stage", Value: "getting_to_know"}},
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "stella"}, {Key: "health_score", Value: 0.4}, {Key: "current_stage", Value: "close_companionship"}},
+			),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: "luna"}, {Key: "engagement_score", Value: 0.7}},
+				bson.D{{Key: "_id", Value: "nova"}, {Key: "engagement_score", Value: 0.3}},
+				bson.D{{Key: "_id", Value: "stella"}, {Key: "engagement_score", Value: 0.8}},
+			),
+		)
+
+		service := NewAnalyticsService(nil, repositories.NewAnalyticsRepository(nil, mt.DB), repositories.NewConversationRepository(mt.DB))
+
+		summary, err := service.GetMultiCompanionSummary(context.Background(), "user")
+		require.NoError(t, err)
+
+		require.Len(t, summary.Companions, 3)
+		assert.Equal(t, "stella", summary.Companions[0].CompanionID)
+		assert.Equal(t, "luna", summary.Companions[1].CompanionID)
+		assert.Equal(t, "nova", summary.Companions[2].CompanionID)
+		for i, entry := range summary.Companions {
+			assert.Equal(t, i+1, entry.EngagementRank)
+		}
+
+		assert.Equal(t, "nova", summary.PrimaryCompanionID)
+		assert.Equal(t, 1, summary.Companions[2].HealthRank)
+		assert.Equal(t, 2, summary.Companions[1].HealthRank)
+		assert.Equal(t, 3, summary.Companions[0].HealthRank)
+
+		assert.Equal(t, 300, summary.Companions[0].TotalMessages)
+		assert.Equal(t, "close_companionship", summary.Companions[0].RelationshipStage)
+	})
+}
+
+func TestEmotionTransitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	snapshot := func(emotion string) bson.D {
+		return bson.D{{Key: "emotional_state", Value: bson.D{{Key: "primary_emotion", Value: emotion}}}}
+	}
+
+	mt.Run("stores relationship matrix", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "emotional_history", Value: bson.A{snapshot("anxiety"), snapshot("contentment"), snapshot("anxiety"), snapshot("anxiety")}},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewAnalyticsService(nil, repositories.NewAnalyticsRepository(nil, mt.DB), repositories.NewConversationRepository(mt.DB))
+		require.NoError(t, service.updateEmotionTransitions(context.Background(), "user", "companion", conversationID))
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 2)
+		update := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "user", update.Lookup("q", "user_id").StringValue())
+		matrix := update.Lookup("u", "$set", "emotion_transition_matrix").Document()
+		assert.InDelta(t, 0.5, matrix.Lookup("anxiety", "contentment").Double(), 1e-9)
+		assert.InDelta(t, 0.5, matrix.Lookup("anxiety", "anxiety").Double(), 1e-9)
+		assert.InDelta(t, 1.0, matrix.Lookup("contentment", "anxiety").Double(), 1e-9)
+	})
+
+	mt.Run("normalises platform counts", func(mt *mtest.T) {
+		pair := func(from, to string, count int) bson.D {
+			return bson.D{{Key: "_id", Value: bson.D{{Key: "from", Value: from}, {Key: "to", Value: to}}}, {Key: "count", Value: count}}
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch,
+			pair("anxiety", "contentment", 30),
+			pair("anxiety", "anxiety", 10),
+			pair("joy", "joy", 5),
+		))
+
+		service := NewAnalyticsService(nil, repositories.NewAnalyticsRepository(nil, mt.DB), nil)
+		matrix, err := service.GetPlatformEmotionTransitions(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]map[string]float64{
+			"anxiety": {"contentment": 0.75, "anxiety": 0.25},
+			"joy":     {"joy": 1},
+		}, matrix)
+	})
+}
+
+func TestCalculateSimpleSentimentKeywordCounts(t *testing.T) {
+	service := NewAnalyticsService(nil, nil, nil)
+
+	tests := []struct {
+		text string
+		want SimpleSentiment
+	}{
+		{text: "The weather is fine today", want: SimpleSentiment{Score: 0.5, Intensity: 0.1, Dominant: "neutral"}},
+		{text: "I love this, it is great and wonderful", want: SimpleSentiment{Score: 1, Intensity: 0.3, Dominant: "positive"}},
+		{text: "I feel sad and lonely but you are good to me", want: SimpleSentiment{Score: 1.0 / 3, Intensity: 0.3, Dominant: "negative"}},
+		{text: "我爱你，但是很悲伤", want: SimpleSentiment{Score: 0.5, Intensity: 0.2, Dominant: "neutral"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := service.calculateSimpleSentiment(tt.text)
+			assert.InDelta(t, tt.want.Score, got.Score, 1e-9)
+			assert.InDelta(t, tt.want.Intensity, got.Intensity, 1e-9)
+			assert.Equal(t, tt.want.Dominant, got.Dominant)
+		})
+	}
+}
+
+func TestQualityTierBoundaries(t *testing.T) {
+	tests := []struct {
+		score      float64
+		tier       qualitytier.Type
+		multiplier float64
+	}{
+		{score: 0, tier: qualitytier.Bronze, multiplier: 1.0},
+		{score: 0.49, tier: qualitytier.Bronze, multiplier: 1.0},
+		{score: 0.5, tier: qualitytier.Silver, multiplier: 1.25},
+		{score: 0.69, tier: qualitytier.Silver, multiplier: 1.25},
+		{score: 0.7, tier: qualitytier.Gold, multiplier: 1.5},
+		{score: 0.85, tier: qualitytier.Gold, multiplier: 1.5},
+		{score: 0.86, tier: qualitytier.Platinum, multiplier: 2.0},
+		{score: 1, tier: qualitytier.Platinum, multiplier: 2.0},
+	}
+
+	for _, tt := range tests {
+		tier := qualitytier.FromEngagementScore(tt.score)
+		assert.Equal(t, tt.tier, tier, "score %v", tt.score)
+		assert.Equal(t, tt.multiplier, tier.Multiplier(), "score %v", tt.score)
+	}
+}
+
+func TestCalculateExperiencePointsAppliesTierMultiplier(t *testing.T) {
+	service := &AnalyticsService{}
+
+	// 10 base + 4 duration + 6 messages + 10 quality + 10 engagement = 40 before the multiplier
+	session := func(engagement float64) *SessionData {
+		return &SessionData{Duration: 20 * time.Minute, MessageCount: 12, ResponseQuality: 0.5, EngagementScore: engagement}
+	}
+
+	assert.Equal(t, 40, service.calculateExperiencePoints(session(0.3)))
+	assert.Equal(t, 50, service.calculateExperiencePoints(session(0.6)))
+	assert.Equal(t, 60, service.calculateExperiencePoints(session(0.8)))
+	assert.Equal(t, 80, service.calculateExperiencePoints(session(0.9)))
+	assert.Equal(t, 13, service.calculateExperiencePoints(&SessionData{MessageCount: 1, EngagementScore: 0.5}), "12.5 rounds to the nearest point")
+}
+
+func TestCalculateSimpleSentimentCustomVocabulary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocabulary.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default_language": "en",
+		"languages": [
+			{"code": "eo", "characters": "ĉĝĥĵŝŭ", "positive": ["ĝoja", "bona"], "negative": ["trista"]},
+			{"code": "en", "positive": ["sunny", "cozy"], "negative": ["gloomy"]}
+		]
+	}`), 0o644))
+
+	vocabulary, err := config.LoadVocabularyFromFile(path)
+	require.NoError(t, err)
+	require.Len(t, vocabulary.Languages, 2)
+
+	service := NewAnalyticsService(nil, nil, nil, WithEmotionVocabulary(config.EmotionVocabularyConfig{File: path, Vocabulary: vocabulary}))
+
+	tests := []struct {
+		text     string
+		lang     string
+		dominant string
+	}{
+		{text: "A sunny and cozy afternoon", lang: "en", dominant: "positive"},
+		{text: "Such a gloomy morning", lang: "en", dominant: "negative"},
+		{text: "I love this, it is great", lang: "en", dominant: "neutral"},
+		{text: "Mi estas tre ĝoja kaj bona", lang: "eo", dominant: "positive"},
+		{text: "Mi estas trista ĉar pluvas", lang: "eo", dominant: "negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.lang, service.detectLanguage(strings.ToLower(tt.text)))
+			assert.Equal(t, tt.dominant, service.calculateSimpleSentiment(tt.text).Dominant)
+		})
+	}
+
+	t.Run("rejects an undefined default language", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"default_language": "fr", "languages": [{"code": "en"}]}`), 0o644))
+		_, err := config.LoadVocabularyFromFile(path)
+		assert.ErrorContains(t, err, `default language "fr" is not defined`)
+	})
+}
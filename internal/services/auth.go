@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
@@ -16,27 +17,30 @@ type AuthService struct {
 	jwtService      *JWTService
 	passwordService *PasswordService
 	validator       *validator.Validate
+
+	options ServiceConfig
 }
 
-func NewAuthService(userRepo *repositories.UserRepository, jwtService *JWTService, passwordService *PasswordService) *AuthService {
+func NewAuthService(userRepo *repositories.UserRepository, jwtService *JWTService, passwordService *PasswordService, opts ...Option) *AuthService {
 	return &AuthService{
 		userRepo:        userRepo,
 		jwtService:      jwtService,
 		passwordService: passwordService,
 		validator:       validator.New(),
+		options:         newServiceConfig(opts),
 	}
 }
 
 func (s *AuthService) Register(ctx context.Context, req *dto.RegisterRequest) (*dto.AuthResponse, error) {
 	if err := s.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, apperrors.NewValidationError("validation error", err)
 	}
 	if err := s.passwordService.ValidatePasswordStrength(req.Password); err != nil {
 		return nil, err
 	}
 	existingUser, _ := s.userRepo.GetByEmail(ctx, req.Email)
 	if existingUser != nil {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
+		return nil, apperrors.NewConflictError(fmt.Sprintf("user with email %s already exists", req.Email), nil)
 	}
 	hashedPassword, err := s.passwordService.HashPassword(req.Password)
 	if err != nil {
@@ -56,7 +60,7 @@ func (s *AuthService) Register(ctx context.Context, req *dto.RegisterRequest) (*
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
-	accessToken, err := s.jwtService.GenerateAccessToken(createdUser.ID, createdUser.Email)
+	accessToken, err := s.jwtService.GenerateAccessToken(createdUser.ID, createdUser.Email, createdUser.HasPermission(models.PermissionAdmin))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -75,7 +79,7 @@ func (s *AuthService) Register(ctx context.Context, req *dto.RegisterRequest) (*
 
 func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest) (*dto.AuthResponse, error) {
 	if err := s.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, apperrors.NewValidationError("validation error", err)
 	}
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
@@ -84,7 +88,7 @@ func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest) (*dto.Au
 	if err := s.passwordService.CheckPassword(user.PasswordHash, req.Password); err != nil {
 		return nil, fmt.Errorf("invalid credentials")
 	}
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.HasPermission(models.PermissionAdmin))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -110,7 +114,7 @@ func (s *AuthService) RefreshToken(ctx context.Context, req *dto.RefreshTokenReq
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.HasPermission(models.PermissionAdmin))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// backendLatencyWeight is how much each call moves a backend's average response time
+	backendLatencyWeight = 0.2
+	// backendErrorWeight is how much each call moves a backend's error rate
+	backendErrorWeight = 0.2
+	// defaultFailoverErrorRate is the error rate above which a backend is skipped
+	defaultFailoverErrorRate = 0.5
+	// defaultFailoverCooldown is how long a backend is skipped after it fails
+	defaultFailoverCooldown = 30 * time.Second
+)
+
+// backendStats is what the selector knows about one backend
+type backendStats struct {
+	latency       float64
+	errorRate     float64
+	errorAt       time.Time
+	degradedUntil time.Time
+}
+
+// BackendSelector picks the backend to send the next call to: the one with the lowest exponentially weighted average
+// response time among those that are not cooling down after a failure and whose error rate is below the threshold.
+// Error rates halve every cooldown period without a failure, so a backend that recovers is eventually tried again.
+type BackendSelector struct {
+	errorThreshold float64
+	cooldown       time.Duration
+	now            func() time.Time
+
+	mu       sync.Mutex
+	backends []backendStats
+}
+
+// NewBackendSelector creates a selector for count backends, falling back to the default threshold and cooldown when
+// they are not positive
+func NewBackendSelector(count int, errorThreshold float64, cooldown time.Duration) *BackendSelector {
+	if errorThreshold <= 0 {
+		errorThreshold = defaultFailoverErrorRate
+	}
+	if cooldown <= 0 {
+		cooldown = defaultFailoverCooldown
+	}
+	return &BackendSelector{
+		errorThreshold: errorThreshold,
+		cooldown:       cooldown,
+		now:            time.Now,
+		backends:       make([]backendStats, count),
+	}
+}
+
+// Select returns the index of the best backend not in tried. When every untried backend is degraded it returns the
+// one that recovers soonest, since trying a degraded backend beats failing outright. ok is false once all have
+// been tried.
+func (s *BackendSelector) Select(tried map[int]bool) (index int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	best, fallback := -1, -1
+	for i, stats := range s.backends {
+		if tried[i] {
+			continue
+		}
+		if now.Before(stats.degradedUntil) || s.decayedErrorRate(stats, now) >= s.errorThreshold {
+			if fallback < 0 || stats.degradedUntil.Before(s.backends[fallback].degradedUntil) {
+				fallback = i
+			}
+			continue
+		}
+		if best < 0 || stats.latency < s.backends[best].latency {
+			best = i
+		}
+	}
+
+	if best >= 0 {
+		return best, true
+	}
+	return fallback, fallback >= 0
+}
+
+// Record updates a backend's averages with the outcome of a call, marking it degraded for the cooldown if it failed
+func (s *BackendSelector) Record(index int, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	stats := &s.backends[index]
+
+	outcome := 0.0
+	if failed {
+		outcome = 1
+		stats.degradedUntil = now.Add(s.cooldown)
+	} else if stats.latency == 0 {
+		stats.latency = float64(latency)
+	} else {
+		// Failed calls often return quickly, so only successful calls count towards the response time
+		stats.latency += backendLatencyWeight * (float64(latency) - stats.latency)
+	}
+
+	stats.errorRate = s.decayedErrorRate(*stats, now)
+	stats.errorRate += backendErrorWeight * (outcome - stats.errorRate)
+	stats.errorAt = now
+}
+
+// Degraded reports whether the backend is cooling down after a failure
+func (s *BackendSelector) Degraded(index int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now().Before(s.backends[index].degradedUntil)
+}
+
+// decayedErrorRate is the backend's error rate halved for every cooldown period since it was last updated
+func (s *BackendSelector) decayedErrorRate(stats backendStats, now time.Time) float64 {
+	if stats.errorRate == 0 {
+		return 0
+	}
+	periods := float64(now.Sub(stats.errorAt)) / float64(s.cooldown)
+	return stats.errorRate * math.Pow(0.5, periods)
+}
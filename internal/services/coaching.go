@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// coachingWindow is how many recent engagement analytics records coaching looks at
+const coachingWindow = 5
+
+// Coaching categories
+const (
+	CoachingCategoryDepth         = "depth"
+	CoachingCategoryDiversity     = "topic_diversity"
+	CoachingCategoryVulnerability = "vulnerability"
+)
+
+// CoachingTip is a specific, actionable suggestion for making conversations more engaging. Priority 1 is the most
+// important tip.
+type CoachingTip struct {
+	Category      string `json:"category"`
+	Tip           string `json:"tip"`
+	ExamplePrompt string `json:"example_prompt"`
+	Priority      int    `json:"priority"`
+}
+
+// coachingRule produces a tip when a quality metric, averaged over recent conversations, falls below its threshold
+type coachingRule struct {
+	category      string
+	threshold     float64
+	metric        func(models.UserEngagementAnalytics) float64
+	tip           string
+	examplePrompt string
+}
+
+var coachingRules = []coachingRule{
+	{
+		category:      CoachingCategoryDepth,
+		threshold:     0.5,
+		metric:        func(a models.UserEngagementAnalytics) float64 { return a.ConversationDepth },
+		tip:           "Your conversations tend to stay on the surface. When your companion shares something, ask \"why\" to follow up before moving on.",
+		examplePrompt: "Why do you think that matters so much to you?",
+	},
+	{
+		category:      CoachingCategoryDiversity,
+		threshold:     0.3,
+		metric:        func(a models.UserEngagementAnalytics) float64 { return a.TopicDiversity },
+		tip:           "You often come back to the same few topics. Try branching to an adjacent topic when a thread winds down.",
+		examplePrompt: "That reminds me - have you ever thought about where you'd travel if you could go anywhere?",
+	},
+	{
+		category:      CoachingCategoryVulnerability,
+		threshold:     0.3,
+		metric:        func(a models.UserEngagementAnalytics) float64 { return a.VulnerabilityLevel },
+		tip:           "Conversations grow closer when both sides open up. Try sharing a challenge you've faced recently.",
+		examplePrompt: "Honestly, this week has been harder than I expected. Can I tell you about it?",
+	},
+}
+
+// GetConversationCoachingTips examines the user's recent engagement analytics with the companion and returns tips
+// for the quality metrics that are lagging, most important first
+func (s *MLAnalyticsService) GetConversationCoachingTips(ctx context.Context, userID, companionID string) ([]CoachingTip, error) {
+	if !personalisationEnabled(ctx, s.analyticsRepo, userID) {
+		return []CoachingTip{}, nil
+	}
+
+	records, err := s.analyticsRepo.GetRecentEngagementAnalytics(ctx, userID, companionID, coachingWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get engagement analytics: %w", err)
+	}
+
+	return coachingTipsFor(records), nil
+}
+
+// coachingTipsFor applies the coaching rules to the average of records. Tips are prioritised by how far their
+// metric falls short of its threshold, relative to the threshold.
+func coachingTipsFor(records []models.UserEngagementAnalytics) []CoachingTip {
+	tips := []CoachingTip{}
+	if len(records) == 0 {
+		return tips
+	}
+
+	type candidate struct {
+		tip       CoachingTip
+		shortfall float64
+	}
+	var candidates []candidate
+	for _, rule := range coachingRules {
+		var sum float64
+		for _, record := range records {
+			sum += rule.metric(record)
+		}
+		average := sum / float64(len(records))
+		if average >= rule.threshold {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			tip: CoachingTip{
+				Category:      rule.category,
+				Tip:           rule.tip,
+				ExamplePrompt: rule.examplePrompt,
+			},
+			shortfall: (rule.threshold - average) / rule.threshold,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].shortfall > candidates[j].shortfall
+	})
+	for i, c := range candidates {
+		c.tip.Priority = i + 1
+		tips = append(tips, c.tip)
+	}
+	return tips
+}
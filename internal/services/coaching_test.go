@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func engagement(depth, diversity, vulnerability float64) models.UserEngagementAnalytics {
+	return models.UserEngagementAnalytics{
+		ConversationDepth:  depth,
+		TopicDiversity:     diversity,
+		VulnerabilityLevel: vulnerability,
+	}
+}
+
+func TestCoachingTipRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		records      []models.UserEngagementAnalytics
+		wantCategory string
+	}{
+		{
+			name:         "shallow conversations",
+			records:      []models.UserEngagementAnalytics{engagement(0.4, 0.6, 0.6), engagement(0.3, 0.6, 0.6)},
+			wantCategory: CoachingCategoryDepth,
+		},
+		{
+			name:         "narrow topics",
+			records:      []models.UserEngagementAnalytics{engagement(0.8, 0.2, 0.6), engagement(0.8, 0.25, 0.6)},
+			wantCategory: CoachingCategoryDiversity,
+		},
+		{
+			name:         "guarded user",
+			records:      []models.UserEngagementAnalytics{engagement(0.8, 0.6, 0.1), engagement(0.8, 0.6, 0.2)},
+			wantCategory: CoachingCategoryVulnerability,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tips := coachingTipsFor(tt.records)
+			require.Len(t, tips, 1)
+			assert.Equal(t, tt.wantCategory, tips[0].Category)
+			assert.Equal(t, 1, tips[0].Priority)
+			assert.NotEmpty(t, tips[0].Tip)
+			assert.NotEmpty(t, tips[0].ExamplePrompt)
+		})
+	}
+}
+
+func TestCoachingTipsUseAverageOfRecentRecords(t *testing.T) {
+	// One shallow conversation among deep ones keeps the average above the threshold
+	tips := coachingTipsFor([]models.UserEngagementAnalytics{
+		engagement(0.1, 0.6, 0.6),
+		engagement(0.7, 0.6, 0.6),
+		engagement(0.8, 0.6, 0.6),
+	})
+	assert.Empty(t, tips)
+
+	assert.Empty(t, coachingTipsFor(nil))
+}
+
+func TestCoachingTipsSortedByPriority(t *testing.T) {
+	// Vulnerability is furthest below its threshold, then depth, then diversity
+	tips := coachingTipsFor([]models.UserEngagementAnalytics{engagement(0.2, 0.25, 0.03)})
+
+	require.Len(t, tips, 3)
+	assert.Equal(t, CoachingCategoryVulnerability, tips[0].Category)
+	assert.Equal(t, CoachingCategoryDepth, tips[1].Category)
+	assert.Equal(t, CoachingCategoryDiversity, tips[2].Category)
+	for i, tip := range tips {
+		assert.Equal(t, i+1, tip.Priority)
+	}
+}
+
+func TestGetConversationCoachingTips(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("reads recent engagement analytics", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch,
+				bson.D{{Key: "conversation_depth", Value: 0.2}, {Key: "topic_diversity", Value: 0.8}, {Key: "vulnerability_level", Value: 0.9}},
+				bson.D{{Key: "conversation_depth", Value: 0.4}, {Key: "topic_diversity", Value: 0.6}, {Key: "vulnerability_level", Value: 0.7}},
+			),
+		)
+
+		service := NewMLAnalyticsService(
+			repositories.NewAnalyticsRepository(nil, mt.DB),
+			repositories.NewConversationRepository(mt.DB),
+			NewGrokService(&config.GrokConfig{}),
+		)
+
+		tips, err := service.GetConversationCoachingTips(context.Background(), "user", "companion")
+		require.NoError(t, err)
+		require.Len(t, tips, 1)
+		assert.Equal(t, CoachingCategoryDepth, tips[0].Category)
+	})
+
+	mt.Run("suppressed without personalisation", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch,
+			bson.D{{Key: "user_id", Value: "user"}, {Key: "personalization_level", Value: "none"}}))
+
+		service := NewMLAnalyticsService(
+			repositories.NewAnalyticsRepository(nil, mt.DB),
+			repositories.NewConversationRepository(mt.DB),
+			NewGrokService(&config.GrokConfig{}),
+		)
+
+		tips, err := service.GetConversationCoachingTips(context.Background(), "user", "companion")
+		require.NoError(t, err)
+		assert.NotNil(t, tips)
+		assert.Empty(t, tips)
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
@@ -18,6 +19,8 @@ type CompanionService struct {
 	conversationRepo   *repositories.ConversationRepository
 	personalityService *PersonalityService
 	validator          *validator.Validate
+
+	options ServiceConfig
 }
 
 func NewCompanionService(
@@ -25,6 +28,7 @@ func NewCompanionService(
 	relationshipRepo *repositories.RelationshipRepository,
 	conversationRepo *repositories.ConversationRepository,
 	personalityService *PersonalityService,
+	opts ...Option,
 ) *CompanionService {
 	return &CompanionService{
 		companionRepo:      companionRepo,
@@ -32,12 +36,13 @@ func NewCompanionService(
 		conversationRepo:   conversationRepo,
 		personalityService: personalityService,
 		validator:          validator.New(),
+		options:            newServiceConfig(opts),
 	}
 }
 
 func (s *CompanionService) CreateCompanion(ctx context.Context, userID uuid.UUID, req *dto.CreateCompanionRequest) (*dto.CompanionResponse, error) {
 	if err := s.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, apperrors.NewValidationError("validation error", err)
 	}
 	companion := &models.Companion{
 		UserID:    userID,
@@ -107,6 +112,18 @@ func (s *CompanionService) CreateCompanion(ctx context.Context, userID uuid.UUID
 		return nil, fmt.Errorf("failed to create relationship: %w", err)
 	}
 
+	specialDates := &models.SpecialDates{
+		UserID:          userID.String(),
+		CompanionID:     createdCompanion.ID.String(),
+		AnniversaryDate: createdRelationship.RelationshipStartedAt,
+	}
+	if req.Birthdate != nil {
+		specialDates.Birthdate = *req.Birthdate
+	}
+	if err := s.conversationRepo.CreateSpecialDates(ctx, specialDates); err != nil {
+		s.options.logger().Error("Failed to create special dates", "companion_id", createdCompanion.ID, "error", err)
+	}
+
 	// Get conversation stats (will be empty for new companion)
 	conversationStats := &models.ConversationStats{
 		TotalMessages:     0,
@@ -196,7 +213,7 @@ func (s *CompanionService) GetUserCompanions(ctx context.Context, userID uuid.UU
 
 func (s *CompanionService) UpdateCompanion(ctx context.Context, companionID uuid.UUID, userID uuid.UUID, req *dto.UpdateCompanionRequest) (*dto.CompanionResponse, error) {
 	if err := s.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, apperrors.NewValidationError("validation error", err)
 	}
 	updates := make(map[string]any)
 	if req.Name != nil {
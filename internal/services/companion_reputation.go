@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+const (
+	// reputationSmoothing is the EMA weight given to each new quality sample
+	reputationSmoothing = 0.1
+	// reputationTrendWindow is the number of samples compared in each half of the trend calculation
+	reputationTrendWindow = 10
+	// reputationTrendThreshold is the change in mean quality needed to report a trend
+	reputationTrendThreshold = 0.05
+)
+
+// reputationStore persists companion reputations
+type reputationStore interface {
+	GetCompanionReputation(ctx context.Context, companionID string) (*models.CompanionReputation, error)
+	UpsertCompanionReputation(ctx context.Context, reputation *models.CompanionReputation) error
+}
+
+// CompanionReputationJob folds response quality scores into a per-companion reputation
+type CompanionReputationJob struct {
+	store reputationStore
+
+	options ServiceConfig
+}
+
+// NewCompanionReputationJob creates a new companion reputation job
+func NewCompanionReputationJob(store reputationStore, opts ...Option) *CompanionReputationJob {
+	return &CompanionReputationJob{
+		store:   store,
+		options: newServiceConfig(opts),
+	}
+}
+
+// Record adds a quality sample to the companion's reputation
+func (j *CompanionReputationJob) Record(ctx context.Context, companionID string, overallQuality float64) (*models.CompanionReputation, error) {
+	reputation, err := j.store.GetCompanionReputation(ctx, companionID)
+	var notFound *apperrors.NotFoundError
+	if errors.As(err, &notFound) {
+		reputation = &models.CompanionReputation{CompanionID: companionID}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get companion reputation: %w", err)
+	}
+
+	applyReputationSample(reputation, overallQuality)
+	reputation.LastUpdated = time.Now()
+
+	if err := j.store.UpsertCompanionReputation(ctx, reputation); err != nil {
+		return nil, fmt.Errorf("failed to save companion reputation: %w", err)
+	}
+	return reputation, nil
+}
+
+// GetCompanionReputation gets the aggregated reputation for a companion
+func (j *CompanionReputationJob) GetCompanionReputation(ctx context.Context, companionID string) (*models.CompanionReputation, error) {
+	return j.store.GetCompanionReputation(ctx, companionID)
+}
+
+// applyReputationSample updates the moving average, sample window and trend with a new score
+func applyReputationSample(reputation *models.CompanionReputation, score float64) {
+	reputation.AverageQuality = exponentialMovingAverage(reputation.AverageQuality, score, reputation.SampleSize, reputationSmoothing)
+	reputation.SampleSize++
+
+	reputation.RecentScores = append(reputation.RecentScores, score)
+	if len(reputation.RecentScores) > 2*reputationTrendWindow {
+		reputation.RecentScores = reputation.RecentScores[len(reputation.RecentScores)-2*reputationTrendWindow:]
+	}
+	reputation.QualityTrend = qualityTrend(reputation.RecentScores)
+}
+
+// exponentialMovingAverage blends a new sample into the average; the first sample seeds it
+func exponentialMovingAverage(average, sample float64, sampleSize int, alpha float64) float64 {
+	if sampleSize == 0 {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*average
+}
+
+// qualityTrend compares the last window of scores against the one before it
+func qualityTrend(scores []float64) string {
+	if len(scores) < 2*reputationTrendWindow {
+		return "stable"
+	}
+
+	scores = scores[len(scores)-2*reputationTrendWindow:]
+	previous := mean(scores[:reputationTrendWindow])
+	recent := mean(scores[reputationTrendWindow:])
+
+	switch {
+	case recent-previous > reputationTrendThreshold:
+		return "improving"
+	case previous-recent > reputationTrendThreshold:
+		return "degrading"
+	default:
+		return "stable"
+	}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type memoryReputationStore map[string]models.CompanionReputation
+
+func (m memoryReputationStore) GetCompanionReputation(ctx context.Context, companionID string) (*models.CompanionReputation, error) {
+	reputation, ok := m[companionID]
+	if !ok {
+		return nil, apperrors.NewNotFoundError("companion reputation not found", mongo.ErrNoDocuments)
+	}
+	return &reputation, nil
+}
+
+func (m memoryReputationStore) UpsertCompanionReputation(ctx context.Context, reputation *models.CompanionReputation) error {
+	m[reputation.CompanionID] = *reputation
+	return nil
+}
+
+func TestExponentialMovingAverage(t *testing.T) {
+	tests := []struct {
+		name       string
+		average    float64
+		sample     float64
+		sampleSize int
+		alpha      float64
+		want       float64
+	}{
+		{name: "first sample seeds the average", average: 0, sample: 0.8, sampleSize: 0, alpha: 0.1, want: 0.8},
+		{name: "blends new sample", average: 0.8, sample: 0.4, sampleSize: 1, alpha: 0.1, want: 0.76},
+		{name: "alpha of one tracks latest sample", average: 0.5, sample: 0.9, sampleSize: 5, alpha: 1, want: 0.9},
+		{name: "alpha of zero ignores new sample", average: 0.5, sample: 0.9, sampleSize: 5, alpha: 0, want: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, exponentialMovingAverage(tt.average, tt.sample, tt.sampleSize, tt.alpha), 1e-9)
+		})
+	}
+}
+
+func TestQualityTrend(t *testing.T) {
+	repeat := func(v float64, n int) []float64 {
+		scores := make([]float64, n)
+		for i := range scores {
+			scores[i] = v
+		}
+		return scores
+	}
+
+	assert.Equal(t, "stable", qualityTrend(repeat(0.9, 19)))
+	assert.Equal(t, "improving", qualityTrend(append(repeat(0.5, 10), repeat(0.7, 10)...)))
+	assert.Equal(t, "degrading", qualityTrend(append(repeat(0.7, 10), repeat(0.5, 10)...)))
+	assert.Equal(t, "stable", qualityTrend(append(repeat(0.6, 10), repeat(0.62, 10)...)))
+}
+
+func TestCompanionReputationJobRecord(t *testing.T) {
+	store := memoryReputationStore{}
+	job := NewCompanionReputationJob(store)
+
+	for _, score := range []float64{0.8, 0.4} {
+		_, err := job.Record(context.Background(), "companion", score)
+		require.NoError(t, err)
+	}
+
+	reputation, err := job.GetCompanionReputation(context.Background(), "companion")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.76, reputation.AverageQuality, 1e-9)
+	assert.Equal(t, 2, reputation.SampleSize)
+	assert.Equal(t, "stable", reputation.QualityTrend)
+	assert.False(t, reputation.LastUpdated.IsZero())
+
+	for i := 0; i < 30; i++ {
+		_, err := job.Record(context.Background(), "companion", 0.9)
+		require.NoError(t, err)
+	}
+	reputation, err = job.GetCompanionReputation(context.Background(), "companion")
+	require.NoError(t, err)
+	assert.Len(t, reputation.RecentScores, 20)
+	assert.Equal(t, 32, reputation.SampleSize)
+}
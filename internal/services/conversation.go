@@ -2,23 +2,43 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ConversationService struct {
 	repo      *repositories.ConversationRepository
 	analytics *repositories.AnalyticsRepository
+
+	options ServiceConfig
 }
 
-func NewConversationService(repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository) *ConversationService {
-	return &ConversationService{repo: repo, analytics: analytics}
+func NewConversationService(repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository, opts ...Option) *ConversationService {
+	return &ConversationService{repo: repo, analytics: analytics, options: newServiceConfig(opts)}
 }
 
-func (s *ConversationService) StartConversation(ctx context.Context, userID, companionID string, relationship string) (*models.Conversation, error) {
+// StartConversation creates a conversation between the user and companion. With a template ID, the conversation opens
+// with the template's messages and context; an unknown template returns a *apperrors.NotFoundError.
+func (s *ConversationService) StartConversation(ctx context.Context, userID, companionID string, relationship string, templateID string) (*models.Conversation, error) {
+	var template *models.ConversationTemplate
+	if templateID != "" {
+		var err error
+		template, err = s.repo.GetConversationTemplate(ctx, templateID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	conv := &models.Conversation{
 		UserID:         userID,
 		CompanionID:    companionID,
@@ -28,11 +48,92 @@ func (s *ConversationService) StartConversation(ctx context.Context, userID, com
 		LastActivity:   time.Now(),
 	}
 
-	return s.repo.CreateConversation(ctx, conv)
+	conv, err := s.repo.CreateConversation(ctx, conv)
+	if err != nil || template == nil {
+		return conv, err
+	}
+
+	if err := s.applyTemplate(ctx, conv, template); err != nil {
+		return nil, fmt.Errorf("failed to apply conversation template %s: %w", template.TemplateID, err)
+	}
+	return conv, nil
 }
 
+// applyTemplate stores the template's initial context and messages for a new conversation
+func (s *ConversationService) applyTemplate(ctx context.Context, conv *models.Conversation, template *models.ConversationTemplate) error {
+	if !template.InitialContext.IsEmpty() {
+		conversationContext := newConversationContext(conv.ID)
+		conversationContext.UserID = conv.UserID
+		conversationContext.CompanionID = conv.CompanionID
+		template.InitialContext.MergeInto(conversationContext)
+		if err := s.repo.SaveConversationContext(ctx, conversationContext, models.ContextActorSystem); err != nil {
+			return err
+		}
+	}
+
+	for _, templateMessage := range template.InitialMessages {
+		text := templateMessage.Text
+		senderID := conv.CompanionID
+		if templateMessage.SenderType == sendertype.User {
+			senderID = conv.UserID
+		}
+		msg, _, err := s.repo.CreateMessage(ctx, &models.Message{
+			ConversationID: conv.ID,
+			SenderID:       senderID,
+			SenderType:     templateMessage.SenderType,
+			Type:           messagetype.Text,
+			Text:           &text,
+		})
+		if err != nil {
+			return err
+		}
+		conv.RecentMessages = append(conv.RecentMessages, *msg)
+	}
+	return nil
+}
+
+// ListConversationTemplates returns up to limit templates carrying every one of tags
+func (s *ConversationService) ListConversationTemplates(ctx context.Context, tags []string, limit int) ([]models.ConversationTemplate, error) {
+	return s.repo.ListConversationTemplates(ctx, tags, limit)
+}
+
+// CreateConversationTemplate validates and stores a new conversation template. A template ID that is already taken
+// returns a *apperrors.ConflictError.
+func (s *ConversationService) CreateConversationTemplate(ctx context.Context, template *models.ConversationTemplate) error {
+	if template.TemplateID == "" || template.Name == "" {
+		return apperrors.NewValidationError("template_id and name are required", nil)
+	}
+	for i, msg := range template.InitialMessages {
+		if msg.SenderType != sendertype.User && msg.SenderType != sendertype.Companion {
+			return apperrors.NewValidationError(fmt.Sprintf("initial message %d must be sent by the user or the companion", i), nil)
+		}
+		if msg.Text == "" {
+			return apperrors.NewValidationError(fmt.Sprintf("initial message %d has no text", i), nil)
+		}
+	}
+	if template.InitialMessages == nil {
+		template.InitialMessages = []models.TemplateMessage{}
+	}
+	if template.Tags == nil {
+		template.Tags = []string{}
+	}
+
+	return s.repo.CreateConversationTemplate(ctx, template)
+}
+
+// ListConversations lists the user's conversations, leaving out forks, which are listed by ListForkedConversations
 func (s *ConversationService) ListConversations(ctx context.Context, userID string, archived bool, limit, offset int) ([]*models.Conversation, error) {
-	return s.repo.ListUserConversations(ctx, userID, archived, limit, offset)
+	return s.repo.ListConversationsWithFilter(ctx, bson.M{"user_id": userID, "archived": archived, "forked_from": nil}, limit, offset)
+}
+
+// ListForkedConversations lists the conversations the user has branched from earlier conversations
+func (s *ConversationService) ListForkedConversations(ctx context.Context, userID string, archived bool, limit, offset int) ([]*models.Conversation, error) {
+	return s.repo.ListConversationsWithFilter(ctx, bson.M{"user_id": userID, "archived": archived, "forked_from": bson.M{"$ne": nil}}, limit, offset)
+}
+
+// ForkConversation branches a new conversation from the given message of one of the user's conversations
+func (s *ConversationService) ForkConversation(ctx context.Context, conversationID, fromMessageID primitive.ObjectID, userID string) (*models.Conversation, error) {
+	return s.repo.ForkConversation(ctx, conversationID, fromMessageID, userID)
 }
 
 func (s *ConversationService) GetConversation(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
@@ -46,3 +147,140 @@ func (s *ConversationService) ArchiveConversation(ctx context.Context, id primit
 func (s *ConversationService) ReactivateConversation(ctx context.Context, id primitive.ObjectID) error {
 	return s.repo.ReactivateConversation(ctx, id)
 }
+
+// DeleteConversation soft-deletes a conversation; its data is purged after the deletion grace period
+func (s *ConversationService) DeleteConversation(ctx context.Context, id primitive.ObjectID) error {
+	return s.repo.SoftDeleteConversation(ctx, id)
+}
+
+// contextResetInterval is how often a user may reset a conversation's context
+const contextResetInterval = 24 * time.Hour
+
+// ErrContextResetTooSoon is returned when a conversation's context was reset less than a day ago
+var ErrContextResetTooSoon = errors.New("conversation context was reset too recently")
+
+// ContextResetLimitError carries how long the user must wait before resetting the context again
+type ContextResetLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ContextResetLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrContextResetTooSoon, e.RetryAfter)
+}
+
+func (e *ContextResetLimitError) Unwrap() error {
+	return ErrContextResetTooSoon
+}
+
+// ResetConversationContext makes the companion forget the conversation's context while keeping its messages, and
+// resets the relationship analytics to match. A context may be reset once a day; sooner resets return a
+// *ContextResetLimitError.
+func (s *ConversationService) ResetConversationContext(ctx context.Context, conversationID primitive.ObjectID, preserveMemories bool) error {
+	conversation, err := s.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.repo.GetConversationContext(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if current.LastResetAt != nil {
+		if wait := current.LastResetAt.Add(contextResetInterval).Sub(now); wait > 0 {
+			return &ContextResetLimitError{RetryAfter: wait}
+		}
+	}
+
+	if err := s.repo.ResetConversationContext(ctx, conversationID, preserveMemories, now.Add(-contextResetInterval)); err != nil {
+		// Another reset was written since the context was read
+		var conflict *apperrors.ConflictError
+		if errors.As(err, &conflict) {
+			return &ContextResetLimitError{RetryAfter: contextResetInterval}
+		}
+		return fmt.Errorf("failed to reset conversation context: %w", err)
+	}
+
+	analytics, err := s.analytics.GetRelationshipAnalytics(ctx, conversation.UserID, conversation.CompanionID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get relationship analytics: %w", err)
+	}
+	analytics.CurrentStage = models.InitialRelationshipStage
+	analytics.TrustLevel = models.InitialTrustLevel
+	analytics.IntimacyLevel = models.InitialIntimacyLevel
+	if err := refreshChemistryScore(ctx, s.analytics, analytics); err != nil {
+		s.options.logger().Error("Failed to refresh chemistry score", "error", err)
+	}
+	if err := s.analytics.UpsertRelationshipAnalytics(ctx, analytics); err != nil {
+		return fmt.Errorf("failed to reset relationship analytics: %w", err)
+	}
+
+	return nil
+}
+
+// Replay speeds accepted by GenerateReplayManifest
+const (
+	MinReplaySpeed = 0.5
+	MaxReplaySpeed = 10.0
+)
+
+// ErrInvalidReplaySpeed is returned for a replay speed outside [MinReplaySpeed, MaxReplaySpeed]
+var ErrInvalidReplaySpeed = fmt.Errorf("replay speed must be between %.1f and %.1f", MinReplaySpeed, MaxReplaySpeed)
+
+// GenerateReplayManifest schedules every message of a conversation for a replay running speed times faster than the
+// conversation did
+func (s *ConversationService) GenerateReplayManifest(ctx context.Context, conversationID primitive.ObjectID, speed float64) (*models.ReplayManifest, error) {
+	if speed < MinReplaySpeed || speed > MaxReplaySpeed {
+		return nil, ErrInvalidReplaySpeed
+	}
+
+	var messages []*models.Message
+	err := s.repo.ForEachMessage(ctx, conversationID, func(msg *models.Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	manifest := buildReplayManifest(messages, speed)
+	manifest.ConversationID = conversationID
+	return manifest, nil
+}
+
+// buildReplayManifest orders messages by creation time and scales the gaps between them by 1/speed
+func buildReplayManifest(messages []*models.Message, speed float64) *models.ReplayManifest {
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+
+	manifest := &models.ReplayManifest{Speed: speed, Messages: make([]models.ReplayMessage, 0, len(messages))}
+	var cumulative time.Duration
+	for i, msg := range messages {
+		var delta time.Duration
+		if i > 0 {
+			delta = time.Duration(float64(msg.CreatedAt.Sub(messages[i-1].CreatedAt)) / speed)
+		}
+		cumulative += delta
+
+		manifest.Messages = append(manifest.Messages, models.ReplayMessage{
+			Message:      msg,
+			DisplayAt:    messages[0].CreatedAt.Add(cumulative),
+			DeltaMs:      delta.Milliseconds(),
+			CumulativeMs: cumulative.Milliseconds(),
+		})
+	}
+	manifest.TotalMs = cumulative.Milliseconds()
+
+	return manifest
+}
+
+// topReactionCount is the number of reactions shown on a conversation
+const topReactionCount = 5
+
+// GetTopReactions returns the companion's most used reactions this week
+func (s *ConversationService) GetTopReactions(ctx context.Context, companionID string) ([]models.ReactionSummary, error) {
+	return s.repo.GetTopReactions(ctx, companionID, time.Now(), topReactionCount)
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Metadata of archive objects, so that S3 clients know to decompress the NDJSON they hold
+const (
+	archiveContentType     = "application/x-ndjson"
+	archiveContentEncoding = "gzip"
+)
+
+// archiveObjectStore is the part of the S3 client used to store conversation archives
+type archiveObjectStore interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// archiveMessageStore reads the messages to archive and removes them once they are in S3
+type archiveMessageStore interface {
+	GetConversationByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error)
+	ListMessages(ctx context.Context, conversationID primitive.ObjectID, limit int, cursor *primitive.ObjectID) ([]*models.Message, *primitive.ObjectID, bool, error)
+	ListConversationsWithMessagesOver(ctx context.Context, threshold int) ([]primitive.ObjectID, error)
+	ForEachStoredMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error
+	OpenMessage(msg *models.Message) error
+	MarkConversationArchived(ctx context.Context, id primitive.ObjectID, archiveURL string, archivedAt time.Time) error
+	DeleteMessages(ctx context.Context, conversationID primitive.ObjectID, ids []primitive.ObjectID) (int64, error)
+}
+
+// archivedMessage is one NDJSON line of an archive. Messages are archived as stored, so encrypted text stays sealed;
+// models.Message leaves the sealed fields out of JSON, so they are added here.
+type archivedMessage struct {
+	*models.Message
+	TextEncrypted []byte `json:"text_encrypted,omitempty"`
+	TextNonce     []byte `json:"text_nonce,omitempty"`
+}
+
+// ConversationArchiveService moves the messages of very long conversations from MongoDB to gzipped NDJSON objects in S3
+type ConversationArchiveService struct {
+	repo      archiveMessageStore
+	objects   archiveObjectStore
+	bucket    string
+	endpoint  string
+	threshold int
+	interval  time.Duration
+
+	options ServiceConfig
+}
+
+// NewConversationArchiveService creates an archive service that archives conversations holding more than threshold messages
+func NewConversationArchiveService(repo archiveMessageStore, objects archiveObjectStore, bucket, endpoint string, threshold int, opts ...Option) *ConversationArchiveService {
+	return &ConversationArchiveService{
+		repo:      repo,
+		objects:   objects,
+		bucket:    bucket,
+		endpoint:  endpoint,
+		threshold: threshold,
+		interval:  time.Hour,
+		options:   newServiceConfig(opts),
+	}
+}
+
+// Start archives long conversations immediately and then every hour until ctx is cancelled
+func (s *ConversationArchiveService) Start(ctx context.Context) {
+	for {
+		if err := s.ArchiveLongConversations(ctx); err != nil {
+			s.options.logger().Error("Conversation archive job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// ArchiveLongConversations archives every conversation holding more than the threshold number of messages
+func (s *ConversationArchiveService) ArchiveLongConversations(ctx context.Context) error {
+	conversationIDs, err := s.repo.ListConversationsWithMessagesOver(ctx, s.threshold)
+	if err != nil {
+		return fmt.Errorf("failed to find long conversations: %w", err)
+	}
+
+	for _, conversationID := range conversationIDs {
+		if err := s.ArchiveConversation(ctx, conversationID); err != nil {
+			s.options.logger().Error("Failed to archive conversation", "conversation_id", conversationID.Hex(), "error", err)
+		}
+	}
+
+	return nil
+}
+
+// ArchiveConversation uploads every message of the conversation to S3 as gzipped NDJSON, oldest first, records the
+// archive on the conversation and deletes the archived messages from MongoDB. Encrypted messages are uploaded sealed.
+// Messages from an earlier archive are carried over, so the object always holds the full archived history.
+func (s *ConversationArchiveService) ArchiveConversation(ctx context.Context, conversationID primitive.ObjectID) error {
+	conversation, err := s.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+
+	if conversation.ArchiveURL != "" {
+		if err := s.copyArchive(ctx, conversationID, gz); err != nil {
+			return err
+		}
+	}
+
+	var archivedIDs []primitive.ObjectID
+	encoder := json.NewEncoder(gz)
+	err = s.repo.ForEachStoredMessage(ctx, conversationID, func(msg *models.Message) error {
+		if err := encoder.Encode(archivedMessage{Message: msg, TextEncrypted: msg.TextEncrypted, TextNonce: msg.TextNonce}); err != nil {
+			return err
+		}
+		archivedIDs = append(archivedIDs, msg.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialise messages: %w", err)
+	}
+	if len(archivedIDs) == 0 {
+		return nil
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress messages: %w", err)
+	}
+
+	key := archiveKey(conversationID)
+	_, err = s.objects.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(buf.Bytes()),
+		ContentType:     aws.String(archiveContentType),
+		ContentEncoding: aws.String(archiveContentEncoding),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	archiveURL := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, key)
+	if err := s.repo.MarkConversationArchived(ctx, conversationID, archiveURL, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark conversation archived: %w", err)
+	}
+
+	// Only messages included in the upload are removed; anything sent meanwhile stays in MongoDB
+	if _, err := s.repo.DeleteMessages(ctx, conversationID, archivedIDs); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListMessages lists messages newest first like ConversationRepository.ListMessages. For archived conversations,
+// messages still in MongoDB come first and the rest are streamed from the S3 archive.
+func (s *ConversationArchiveService) ListMessages(ctx context.Context, conversationID primitive.ObjectID, limit int, cursor *primitive.ObjectID) ([]*models.Message, *primitive.ObjectID, bool, error) {
+	conversation, err := s.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if conversation.ArchiveURL == "" {
+		return s.repo.ListMessages(ctx, conversationID, limit, cursor)
+	}
+
+	messages, lastID, hasMore, err := s.repo.ListMessages(ctx, conversationID, limit, cursor)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(messages) == limit {
+		return messages, lastID, hasMore, nil
+	}
+
+	before := cursor
+	if lastID != nil {
+		before = lastID
+	}
+	archived, hasMore, err := s.readArchive(ctx, conversationID, before, limit-len(messages))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	messages = append(messages, archived...)
+	if len(messages) > 0 {
+		lastID = &messages[len(messages)-1].ID
+	}
+
+	return messages, lastID, hasMore, nil
+}
+
+// readArchive streams the archive and returns up to limit messages older than before, newest first, with their text
+// decrypted. hasMore reports whether even older archived messages remain.
+func (s *ConversationArchiveService) readArchive(ctx context.Context, conversationID primitive.ObjectID, before *primitive.ObjectID, limit int) ([]*models.Message, bool, error) {
+	body, err := s.DownloadArchivedConversation(ctx, conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer body.Close()
+
+	// The archive is oldest first, so keep a sliding window of the newest limit messages before the cursor
+	var window []*models.Message
+	matched := 0
+	decoder := json.NewDecoder(body)
+	for {
+		line := archivedMessage{Message: &models.Message{}}
+		if err := decoder.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false, fmt.Errorf("failed to decode archived message: %w", err)
+		}
+		msg := line.Message
+		if before != nil && bytes.Compare(msg.ID[:], before[:]) >= 0 {
+			break
+		}
+		msg.TextEncrypted, msg.TextNonce = line.TextEncrypted, line.TextNonce
+		matched++
+		window = append(window, msg)
+		if len(window) > limit {
+			window = window[1:]
+		}
+	}
+
+	// Only the returned messages are decrypted
+	for _, msg := range window {
+		if err := s.repo.OpenMessage(msg); err != nil {
+			return nil, false, err
+		}
+	}
+
+	slices.Reverse(window)
+	return window, matched > limit, nil
+}
+
+// copyArchive writes the uncompressed contents of the existing archive to w
+func (s *ConversationArchiveService) copyArchive(ctx context.Context, conversationID primitive.ObjectID, w io.Writer) error {
+	body, err := s.DownloadArchivedConversation(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to copy existing archive: %w", err)
+	}
+	return nil
+}
+
+// DownloadArchivedConversation returns the decompressed NDJSON stream of a conversation's archive, one message per
+// line, oldest first. Encrypted messages carry their sealed text_encrypted and text_nonce instead of text. The caller
+// must close it.
+func (s *ConversationArchiveService) DownloadArchivedConversation(ctx context.Context, conversationID primitive.ObjectID) (io.ReadCloser, error) {
+	object, err := s.objects.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(archiveKey(conversationID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download archive: %w", err)
+	}
+
+	// The SDK asks S3 not to encode the response, so the body is still gzipped despite its Content-Encoding
+	gz, err := gzip.NewReader(object.Body)
+	if err != nil {
+		object.Body.Close()
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	return &archiveReader{Reader: gz, body: object.Body}, nil
+}
+
+// archiveReader closes both the gzip stream and the underlying S3 body
+type archiveReader struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (r *archiveReader) Close() error {
+	r.Reader.Close()
+	return r.body.Close()
+}
+
+// archiveKey is the S3 object key holding a conversation's archived messages
+func archiveKey(conversationID primitive.ObjectID) string {
+	return fmt.Sprintf("conversations/%s/messages.ndjson.gz", conversationID.Hex())
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sahmaragaev/lunaria-backend/internal/crypto"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mockS3Client keeps uploaded objects in memory, keyed by bucket/key
+type mockS3Client struct {
+	objects map[string][]byte
+	puts    int
+	last    *s3.PutObjectInput
+}
+
+func (m *mockS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*params.Bucket+"/"+*params.Key] = body
+	m.puts++
+	m.last = params
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := m.objects[*params.Bucket+"/"+*params.Key]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s", *params.Key)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+// memoryArchiveStore holds one conversation and its live messages, oldest first, encrypting their text when it has
+// a cipher
+type memoryArchiveStore struct {
+	conversation models.Conversation
+	messages     []*models.Message
+	sent         int
+	cipher       *crypto.MessageCipher
+	// duringScan, if set, runs after ForEachStoredMessage reads its first message
+	duringScan func()
+}
+
+func (m *memoryArchiveStore) GetConversationByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
+	conversation := m.conversation
+	return &conversation, nil
+}
+
+func (m *memoryArchiveStore) ListMessages(ctx context.Context, conversationID primitive.ObjectID, limit int, cursor *primitive.ObjectID) ([]*models.Message, *primitive.ObjectID, bool, error) {
+	var messages []*models.Message
+	var lastID *primitive.ObjectID
+	for i := len(m.messages) - 1; i >= 0 && len(messages) < limit; i-- {
+		if cursor != nil && bytes.Compare(m.messages[i].ID[:], cursor[:]) >= 0 {
+			continue
+		}
+		messages = append(messages, m.messages[i])
+		lastID = &m.messages[i].ID
+	}
+	return messages, lastID, len(messages) == limit, nil
+}
+
+func (m *memoryArchiveStore) ListConversationsWithMessagesOver(ctx context.Context, threshold int) ([]primitive.ObjectID, error) {
+	if len(m.messages) > threshold {
+		return []primitive.ObjectID{m.conversation.ID}, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryArchiveStore) ForEachStoredMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error {
+	for i, msg := range slices.Clone(m.messages) {
+		if i == 1 && m.duringScan != nil {
+			m.duringScan()
+		}
+		stored := *msg
+		if m.cipher != nil {
+			ciphertext, nonce, err := m.cipher.Encrypt(msg.ConversationID.Hex(), *msg.Text)
+			if err != nil {
+				return err
+			}
+			stored.Text, stored.TextEncrypted, stored.TextNonce = nil, ciphertext, nonce
+		}
+		if err := fn(&stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryArchiveStore) OpenMessage(msg *models.Message) error {
+	if len(msg.TextEncrypted) == 0 {
+		return nil
+	}
+	text, err := m.cipher.Decrypt(msg.ConversationID.Hex(), msg.TextEncrypted, msg.TextNonce)
+	if err != nil {
+		return err
+	}
+	msg.Text, msg.TextEncrypted, msg.TextNonce = &text, nil, nil
+	return nil
+}
+
+func (m *memoryArchiveStore) MarkConversationArchived(ctx context.Context, id primitive.ObjectID, archiveURL string, archivedAt time.Time) error {
+	m.conversation.ArchiveURL = archiveURL
+	m.conversation.ArchivedAt = &archivedAt
+	return nil
+}
+
+func (m *memoryArchiveStore) DeleteMessages(ctx context.Context, conversationID primitive.ObjectID, ids []primitive.ObjectID) (int64, error) {
+	var kept []*models.Message
+	for _, msg := range m.messages {
+		if !slices.Contains(ids, msg.ID) {
+			kept = append(kept, msg)
+		}
+	}
+	deleted := int64(len(m.messages) - len(kept))
+	m.messages = kept
+	return deleted, nil
+}
+
+// addMessages appends count text messages with increasing IDs
+func (m *memoryArchiveStore) addMessages(count int) {
+	for i := 0; i < count; i++ {
+		text := fmt.Sprintf("message %d", m.sent)
+		m.sent++
+		m.messages = append(m.messages, &models.Message{ID: primitive.NewObjectID(), ConversationID: m.conversation.ID, Type: "text", Text: &text})
+	}
+}
+
+func messageTexts(messages []*models.Message) []string {
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = *msg.Text
+	}
+	return texts
+}
+
+func TestConversationArchiveService(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryArchiveStore{conversation: models.Conversation{ID: primitive.NewObjectID()}}
+	objects := &mockS3Client{objects: map[string][]byte{}}
+	service := NewConversationArchiveService(store, objects, "lunaria", "https://s3.example.com", 4)
+
+	store.addMessages(5)
+	require.NoError(t, service.ArchiveLongConversations(ctx))
+
+	t.Run("uploads gzipped ndjson and removes the messages", func(t *testing.T) {
+		key := "lunaria/conversations/" + store.conversation.ID.Hex() + "/messages.ndjson.gz"
+		require.Contains(t, objects.objects, key)
+
+		gz, err := gzip.NewReader(bytes.NewReader(objects.objects[key]))
+		require.NoError(t, err)
+		raw, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+		require.Len(t, lines, 5)
+		var first models.Message
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, "message 0", *first.Text)
+
+		assert.Equal(t, "https://s3.example.com/"+key, store.conversation.ArchiveURL)
+		assert.NotNil(t, store.conversation.ArchivedAt)
+		assert.Empty(t, store.messages)
+	})
+
+	store.addMessages(2)
+
+	t.Run("lists live messages before archived ones", func(t *testing.T) {
+		page, cursor, hasMore, err := service.ListMessages(ctx, store.conversation.ID, 4, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"message 6", "message 5", "message 4", "message 3"}, messageTexts(page))
+		assert.True(t, hasMore)
+
+		page, _, hasMore, err = service.ListMessages(ctx, store.conversation.ID, 4, cursor)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"message 2", "message 1", "message 0"}, messageTexts(page))
+		assert.False(t, hasMore)
+	})
+
+	t.Run("re-archiving keeps earlier archived messages", func(t *testing.T) {
+		require.NoError(t, service.ArchiveConversation(ctx, store.conversation.ID))
+		assert.Equal(t, 2, objects.puts)
+		assert.Empty(t, store.messages)
+
+		page, _, _, err := service.ListMessages(ctx, store.conversation.ID, 10, nil)
+		require.NoError(t, err)
+		assert.Len(t, page, 7)
+		assert.Equal(t, "message 6", *page[0].Text)
+		assert.Equal(t, "message 0", *page[6].Text)
+	})
+
+	t.Run("unarchived conversations read from MongoDB only", func(t *testing.T) {
+		live := &memoryArchiveStore{conversation: models.Conversation{ID: primitive.NewObjectID()}}
+		live.addMessages(2)
+		empty := &mockS3Client{objects: map[string][]byte{}}
+
+		page, _, _, err := NewConversationArchiveService(live, empty, "lunaria", "", 4).ListMessages(ctx, live.conversation.ID, 10, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"message 1", "message 0"}, messageTexts(page))
+	})
+}
+
+func TestArchiveConversationKeepsMessagesInsertedDuringTheScan(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryArchiveStore{conversation: models.Conversation{ID: primitive.NewObjectID()}}
+	objects := &mockS3Client{objects: map[string][]byte{}}
+	service := NewConversationArchiveService(store, objects, "lunaria", "https://s3.example.com", 4)
+
+	store.addMessages(3)
+	// Another server's clock lags, so its message gets an ObjectID below the ones already scanned
+	text := "late message"
+	late := &models.Message{ID: primitive.NewObjectIDFromTimestamp(time.Now().Add(-time.Minute)), ConversationID: store.conversation.ID, Type: "text", Text: &text}
+	store.duringScan = func() { store.messages = append(store.messages, late) }
+
+	require.NoError(t, service.ArchiveConversation(ctx, store.conversation.ID))
+
+	require.Len(t, store.messages, 1)
+	assert.Equal(t, late.ID, store.messages[0].ID)
+}
+
+func TestDownloadArchivedConversationRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryArchiveStore{conversation: models.Conversation{ID: primitive.NewObjectID()}}
+	objects := &mockS3Client{objects: map[string][]byte{}}
+	service := NewConversationArchiveService(store, objects, "lunaria", "https://s3.example.com", 4)
+
+	store.addMessages(100)
+	original := slices.Clone(store.messages)
+	require.NoError(t, service.ArchiveConversation(ctx, store.conversation.ID))
+
+	require.NotNil(t, objects.last)
+	assert.Equal(t, "gzip", aws.ToString(objects.last.ContentEncoding))
+	assert.Equal(t, "application/x-ndjson", aws.ToString(objects.last.ContentType))
+
+	body, err := service.DownloadArchivedConversation(ctx, store.conversation.ID)
+	require.NoError(t, err)
+	defer body.Close()
+
+	var downloaded []*models.Message
+	decoder := json.NewDecoder(body)
+	for decoder.More() {
+		var msg models.Message
+		require.NoError(t, decoder.Decode(&msg))
+		downloaded = append(downloaded, &msg)
+	}
+
+	require.Len(t, downloaded, 100)
+	for i, msg := range downloaded {
+		assert.Equal(t, original[i].ID, msg.ID)
+		assert.Equal(t, *original[i].Text, *msg.Text)
+	}
+}
+
+func TestArchiveConversationKeepsEncryptedTextSealed(t *testing.T) {
+	ctx := context.Background()
+	cipher, err := crypto.NewMessageCipher(bytes.Repeat([]byte{7}, crypto.KeySize))
+	require.NoError(t, err)
+	store := &memoryArchiveStore{conversation: models.Conversation{ID: primitive.NewObjectID()}, cipher: cipher}
+	objects := &mockS3Client{objects: map[string][]byte{}}
+	service := NewConversationArchiveService(store, objects, "lunaria", "https://s3.example.com", 4)
+
+	store.addMessages(5)
+	require.NoError(t, service.ArchiveConversation(ctx, store.conversation.ID))
+
+	gz, err := gzip.NewReader(bytes.NewReader(objects.objects["lunaria/"+archiveKey(store.conversation.ID)]))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "message ")
+	assert.Contains(t, string(raw), `"text_encrypted"`)
+	assert.Contains(t, string(raw), `"text_nonce"`)
+
+	page, _, _, err := service.ListMessages(ctx, store.conversation.ID, 10, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"message 4", "message 3", "message 2", "message 1", "message 0"}, messageTexts(page))
+}
@@ -13,14 +13,17 @@ import (
 )
 
 type ConversationIntelligenceService struct {
-	grokService *GrokService
+	grokService LLMClient
 	repo        *repositories.ConversationRepository
+
+	options ServiceConfig
 }
 
-func NewConversationIntelligenceService(grokService *GrokService, repo *repositories.ConversationRepository) *ConversationIntelligenceService {
+func NewConversationIntelligenceService(grokService LLMClient, repo *repositories.ConversationRepository, opts ...Option) *ConversationIntelligenceService {
 	return &ConversationIntelligenceService{
 		grokService: grokService,
 		repo:        repo,
+		options:     newServiceConfig(opts),
 	}
 }
 
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SystemEventArchiveSuggestion is the system event sent when a conversation grows past its soft limit
+const SystemEventArchiveSuggestion = "archive_suggestion"
+
+// Default conversation length limits, used when configuration leaves them unset
+const (
+	defaultConversationSoftLimit = 500
+	defaultConversationHardLimit = 2000
+)
+
+// ErrConversationFull is returned when a conversation has reached its hard message limit
+var ErrConversationFull = errors.New("conversation has reached its message limit")
+
+// messageCounter counts the messages stored in a conversation
+type messageCounter interface {
+	GetConversationMessageCount(ctx context.Context, conversationID primitive.ObjectID) (int, error)
+}
+
+// ConversationLengthGuard keeps conversations from growing without bound: past the soft limit it suggests archiving,
+// and at the hard limit it refuses new messages
+type ConversationLengthGuard struct {
+	counter   messageCounter
+	softLimit int
+	hardLimit int
+	now       func() time.Time
+
+	options ServiceConfig
+}
+
+// NewConversationLengthGuard creates a guard with the limits in cfg, falling back to the defaults for unset limits
+func NewConversationLengthGuard(counter messageCounter, cfg config.ConversationConfig, opts ...Option) *ConversationLengthGuard {
+	guard := &ConversationLengthGuard{
+		counter:   counter,
+		softLimit: cfg.SoftLimit,
+		hardLimit: cfg.HardLimit,
+		now:       time.Now,
+		options:   newServiceConfig(opts),
+	}
+	if guard.softLimit <= 0 {
+		guard.softLimit = defaultConversationSoftLimit
+	}
+	if guard.hardLimit <= 0 {
+		guard.hardLimit = defaultConversationHardLimit
+	}
+	return guard
+}
+
+// CheckCanSend returns ErrConversationFull if the conversation has reached the hard limit, so another message would
+// exceed it
+func (g *ConversationLengthGuard) CheckCanSend(ctx context.Context, conversationID primitive.ObjectID) error {
+	count, err := g.counter.GetConversationMessageCount(ctx, conversationID)
+	if err != nil {
+		return fmt.Errorf("failed to count conversation messages: %w", err)
+	}
+	if count >= g.hardLimit {
+		return ErrConversationFull
+	}
+	return nil
+}
+
+// ArchiveSuggestion returns a system message suggesting the conversation be archived once it has more messages than
+// the soft limit, or nil while it is below it. The message is not stored; it is returned to the client alongside the
+// message that was just sent.
+func (g *ConversationLengthGuard) ArchiveSuggestion(ctx context.Context, conversationID primitive.ObjectID) (*models.Message, error) {
+	count, err := g.counter.GetConversationMessageCount(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count conversation messages: %w", err)
+	}
+	if count <= g.softLimit {
+		return nil, nil
+	}
+
+	return &models.Message{
+		ConversationID: conversationID,
+		SenderType:     sendertype.System,
+		Type:           messagetype.System,
+		SystemEvent: &models.SystemEvent{
+			EventType: SystemEventArchiveSuggestion,
+			Details:   fmt.Sprintf("This conversation has %d messages. Consider archiving it and starting a new one; new messages are refused after %d.", count, g.hardLimit),
+		},
+		CreatedAt: g.now(),
+	}, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeMessageCounter struct {
+	count int
+}
+
+func (f *fakeMessageCounter) GetConversationMessageCount(context.Context, primitive.ObjectID) (int, error) {
+	return f.count, nil
+}
+
+func TestConversationLengthGuardSoftLimit(t *testing.T) {
+	counter := &fakeMessageCounter{}
+	guard := NewConversationLengthGuard(counter, config.ConversationConfig{SoftLimit: 10, HardLimit: 20})
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	convID := primitive.NewObjectID()
+
+	counter.count = 10
+	suggestion, err := guard.ArchiveSuggestion(context.Background(), convID)
+	require.NoError(t, err)
+	assert.Nil(t, suggestion, "no suggestion at the soft limit")
+
+	counter.count = 11
+	suggestion, err = guard.ArchiveSuggestion(context.Background(), convID)
+	require.NoError(t, err)
+	require.NotNil(t, suggestion)
+	assert.Equal(t, messagetype.System, suggestion.Type)
+	assert.Equal(t, convID, suggestion.ConversationID)
+	require.NotNil(t, suggestion.SystemEvent)
+	assert.Equal(t, SystemEventArchiveSuggestion, suggestion.SystemEvent.EventType)
+	assert.Equal(t, now, suggestion.CreatedAt)
+
+	assert.NoError(t, guard.CheckCanSend(context.Background(), convID), "messages are still accepted past the soft limit")
+}
+
+func TestConversationLengthGuardHardLimit(t *testing.T) {
+	counter := &fakeMessageCounter{count: 19}
+	guard := NewConversationLengthGuard(counter, config.ConversationConfig{SoftLimit: 10, HardLimit: 20})
+	convID := primitive.NewObjectID()
+
+	assert.NoError(t, guard.CheckCanSend(context.Background(), convID))
+
+	counter.count = 20
+	assert.ErrorIs(t, guard.CheckCanSend(context.Background(), convID), ErrConversationFull)
+}
+
+func TestConversationLengthGuardDefaults(t *testing.T) {
+	guard := NewConversationLengthGuard(&fakeMessageCounter{}, config.ConversationConfig{})
+	assert.Equal(t, 500, guard.softLimit)
+	assert.Equal(t, 2000, guard.hardLimit)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultConversationDeletionGrace is how long soft-deleted conversations are kept when no grace period is configured
+const DefaultConversationDeletionGrace = 30 * 24 * time.Hour
+
+// deletedConversationStore permanently removes soft-deleted conversations
+type deletedConversationStore interface {
+	PurgeDeletedConversations(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// ConversationPurgeJob permanently deletes conversations once they have been soft-deleted for longer than the grace period
+type ConversationPurgeJob struct {
+	store    deletedConversationStore
+	grace    time.Duration
+	interval time.Duration
+	now      func() time.Time
+
+	options ServiceConfig
+}
+
+// NewConversationPurgeJob creates a new purge job. A non-positive grace uses DefaultConversationDeletionGrace.
+func NewConversationPurgeJob(store deletedConversationStore, grace time.Duration, opts ...Option) *ConversationPurgeJob {
+	if grace <= 0 {
+		grace = DefaultConversationDeletionGrace
+	}
+
+	return &ConversationPurgeJob{
+		store:    store,
+		grace:    grace,
+		interval: time.Hour,
+		now:      time.Now,
+		options:  newServiceConfig(opts),
+	}
+}
+
+// Start purges expired conversations immediately and then every hour until ctx is cancelled
+func (j *ConversationPurgeJob) Start(ctx context.Context) {
+	for {
+		if _, err := j.PurgeExpired(ctx); err != nil {
+			j.options.logger().Error("Conversation purge job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.interval):
+		}
+	}
+}
+
+// PurgeExpired deletes every conversation soft-deleted more than the grace period ago and returns how many were deleted
+func (j *ConversationPurgeJob) PurgeExpired(ctx context.Context) (int64, error) {
+	purged, err := j.store.PurgeDeletedConversations(ctx, j.now().Add(-j.grace))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted conversations: %w", err)
+	}
+	return purged, nil
+}
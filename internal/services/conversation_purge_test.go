@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDeletedConversations struct {
+	deletedBefore time.Time
+	purged        int64
+}
+
+func (f *fakeDeletedConversations) PurgeDeletedConversations(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	f.deletedBefore = deletedBefore
+	return f.purged, nil
+}
+
+func TestConversationPurgeJob(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("purges conversations deleted before the grace period", func(t *testing.T) {
+		store := &fakeDeletedConversations{purged: 4}
+		job := NewConversationPurgeJob(store, 7*24*time.Hour)
+		job.now = func() time.Time { return now }
+
+		purged, err := job.PurgeExpired(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), purged)
+		assert.Equal(t, now.AddDate(0, 0, -7), store.deletedBefore)
+	})
+
+	t.Run("defaults to a 30 day grace period", func(t *testing.T) {
+		store := &fakeDeletedConversations{}
+		job := NewConversationPurgeJob(store, 0)
+		job.now = func() time.Time { return now }
+
+		_, err := job.PurgeExpired(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, now.AddDate(0, 0, -30), store.deletedBefore)
+	})
+}
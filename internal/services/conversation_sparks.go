@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// sparkCacheTTL is how long generated sparks are reused for a user and companion
+	sparkCacheTTL = time.Hour
+	// sparkMemoryCount is the number of memories the sparks may draw on
+	sparkMemoryCount = 5
+	// maxSparkCount caps how many sparks can be requested at once
+	maxSparkCount = 10
+)
+
+// sparkContextSource loads the latest conversation between a user and companion along with its context and memories
+type sparkContextSource interface {
+	ListConversations(ctx context.Context, userID, companionID string, limit int, cursor any) ([]*models.Conversation, error)
+	GetConversationContext(ctx context.Context, conversationID primitive.ObjectID) (*models.ConversationContext, error)
+	GetMemories(ctx context.Context, conversationID primitive.ObjectID, limit int) ([]models.AIEnhancedMemoryEntry, error)
+}
+
+// preferredTopicSource looks up the topics a user has shown interest in
+type preferredTopicSource interface {
+	PreferredTopics(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID) ([]string, error)
+}
+
+// cachedSparks holds generated sparks until they expire
+type cachedSparks struct {
+	sparks    []string
+	expiresAt time.Time
+}
+
+// GenerateConversationSparks suggests count opener messages for a new conversation, drawing on the user's preferred
+// topics, the relationship stage and recent memories from their latest conversation with the companion. Sparks are
+// cached for an hour per user and companion when the service has a cache.
+func (s *AIContextService) GenerateConversationSparks(ctx context.Context, userID, companionID string, count int) ([]string, error) {
+	if count <= 0 || count > maxSparkCount {
+		return nil, apperrors.NewValidationError(fmt.Sprintf("count must be between 1 and %d", maxSparkCount), nil)
+	}
+
+	key := "sparks:" + userID + ":" + companionID
+	if value, ok := s.options.cached(key); ok {
+		if cached, ok := value.(cachedSparks); ok && time.Now().Before(cached.expiresAt) && len(cached.sparks) >= count {
+			return cached.sparks[:count], nil
+		}
+	}
+
+	profile, err := s.profiles.GetProfile(ctx, companionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get companion profile: %w", err)
+	}
+
+	prompt, err := s.buildSparkPrompt(ctx, userID, companionID, count)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.grokService.SendMessage(ctx, []LLMMessage{
+		{Role: "system", Content: s.buildBaseIdentityLayer(ctx, userID, profile, s.getStyleGuide(ctx, companionID))},
+		{Role: "user", Content: prompt},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate conversation sparks: %w", err)
+	}
+
+	sparks, err := parseSparks(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(sparks) < count {
+		return nil, fmt.Errorf("expected %d conversation sparks, got %d", count, len(sparks))
+	}
+	sparks = sparks[:count]
+
+	s.options.store(key, cachedSparks{sparks: sparks, expiresAt: time.Now().Add(sparkCacheTTL)})
+	return sparks, nil
+}
+
+// buildSparkPrompt describes the shared history the sparks should build on
+func (s *AIContextService) buildSparkPrompt(ctx context.Context, userID, companionID string, count int) (string, error) {
+	stage := "getting_to_know"
+	var topics []string
+	var memories []models.AIEnhancedMemoryEntry
+
+	conversations, err := s.sparkSource.ListConversations(ctx, userID, companionID, 1, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to find latest conversation: %w", err)
+	}
+	if len(conversations) > 0 {
+		conversationID := conversations[0].ID
+
+		conversationContext, err := s.sparkSource.GetConversationContext(ctx, conversationID)
+		var notFound *apperrors.NotFoundError
+		switch {
+		case err == nil:
+			stage = conversationContext.RelationshipStage
+		case !errors.As(err, &notFound):
+			return "", fmt.Errorf("failed to get conversation context: %w", err)
+		}
+
+		memories, err = s.sparkSource.GetMemories(ctx, conversationID, sparkMemoryCount)
+		if err != nil {
+			return "", err
+		}
+
+		if s.preferredTopics != nil {
+			// Missing engagement analytics just means no preferences have been learned yet
+			topics, _ = s.preferredTopics.PreferredTopics(ctx, userID, companionID, conversationID)
+		}
+	}
+
+	preferred := strings.Join(topics, ", ")
+	if preferred == "" {
+		preferred = "None learned yet"
+	}
+
+	return fmt.Sprintf(`The user is about to start a new conversation with you. Suggest %d different messages you could open it with.
+
+RELATIONSHIP STAGE: %s
+TOPICS THE USER ENJOYS: %s
+THINGS YOU REMEMBER ABOUT THE USER:
+%s
+
+Each opener must sound like you, fit the relationship stage, and take a different angle: a question, a callback to something you remember, a topic they enjoy, or something playful. Keep each one to one or two sentences.
+
+Respond with ONLY a JSON array of %d strings.`,
+		count, stage, preferred, s.formatActiveMemories(memories), count), nil
+}
+
+// parseSparks reads the JSON array of openers returned by the LLM, dropping blank entries
+func parseSparks(response string) ([]string, error) {
+	response = strings.TrimSpace(response)
+	if strings.HasPrefix(response, "```json") {
+		response = strings.TrimPrefix(response, "```json")
+		response = strings.TrimSuffix(response, "```")
+	}
+
+	var raw []string
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse conversation sparks: %w", err)
+	}
+
+	sparks := make([]string, 0, len(raw))
+	for _, spark := range raw {
+		if spark = strings.TrimSpace(spark); spark != "" {
+			sparks = append(sparks, spark)
+		}
+	}
+	return sparks, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeSparkSource struct {
+	conversationID primitive.ObjectID
+	memories       []models.AIEnhancedMemoryEntry
+}
+
+func (f *fakeSparkSource) ListConversations(ctx context.Context, userID, companionID string, limit int, cursor any) ([]*models.Conversation, error) {
+	return []*models.Conversation{{ID: f.conversationID, UserID: userID, CompanionID: companionID}}, nil
+}
+
+func (f *fakeSparkSource) GetConversationContext(ctx context.Context, conversationID primitive.ObjectID) (*models.ConversationContext, error) {
+	return &models.ConversationContext{ConversationID: conversationID, RelationshipStage: "close_friends"}, nil
+}
+
+func (f *fakeSparkSource) GetMemories(ctx context.Context, conversationID primitive.ObjectID, limit int) ([]models.AIEnhancedMemoryEntry, error) {
+	return f.memories, nil
+}
+
+type fakePreferredTopics []string
+
+func (f fakePreferredTopics) PreferredTopics(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID) ([]string, error) {
+	return f, nil
+}
+
+type fakeProfileSource struct{}
+
+func (fakeProfileSource) GetProfile(ctx context.Context, companionID string) (*models.CompanionProfile, error) {
+	return &models.CompanionProfile{CompanionID: companionID, Interests: []string{"astronomy"}}, nil
+}
+
+func newSparkTestService(llm LLMClient) *AIContextService {
+	service := NewAIContextService(llm, nil, fakeProfileSource{}, WithCache(cache.New[any]()))
+	service.sparkSource = &fakeSparkSource{
+		conversationID: primitive.NewObjectID(),
+		memories:       []models.AIEnhancedMemoryEntry{{Content: "user is training for a marathon", Importance: 0.8}},
+	}
+	service.preferredTopics = fakePreferredTopics{"running", "travel"}
+	return service
+}
+
+func TestGenerateConversationSparks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns count sparks built from shared history", func(t *testing.T) {
+		llm := &mockLLM{response: "```json\n[\"How did the long run go?\", \"Any trips planned?\", \"Seen any good stars lately?\", \"Guess what I found today\"]\n```"}
+		service := newSparkTestService(llm)
+
+		sparks, err := service.GenerateConversationSparks(ctx, "user-1", "companion-1", 3)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"How did the long run go?", "Any trips planned?", "Seen any good stars lately?"}, sparks)
+
+		require.Len(t, llm.prompts, 1)
+		prompt := llm.prompts[0][1].Content
+		assert.Contains(t, prompt, "Suggest 3 different messages")
+		assert.Contains(t, prompt, "close_friends")
+		assert.Contains(t, prompt, "running, travel")
+		assert.Contains(t, prompt, "training for a marathon")
+		assert.Contains(t, llm.prompts[0][0].Content, "astronomy")
+	})
+
+	t.Run("serves cached sparks without calling the LLM again", func(t *testing.T) {
+		llm := &mockLLM{response: `["one", "two", "three"]`}
+		service := newSparkTestService(llm)
+
+		first, err := service.GenerateConversationSparks(ctx, "user-1", "companion-1", 3)
+		require.NoError(t, err)
+		second, err := service.GenerateConversationSparks(ctx, "user-1", "companion-1", 2)
+		require.NoError(t, err)
+
+		assert.Len(t, llm.prompts, 1)
+		assert.Equal(t, first[:2], second)
+
+		_, err = service.GenerateConversationSparks(ctx, "user-1", "companion-2", 3)
+		require.NoError(t, err)
+		assert.Len(t, llm.prompts, 2, "sparks are cached per companion")
+	})
+
+	t.Run("rejects too few sparks and invalid counts", func(t *testing.T) {
+		service := newSparkTestService(&mockLLM{response: `["only one"]`})
+
+		_, err := service.GenerateConversationSparks(ctx, "user-1", "companion-1", 2)
+		assert.Error(t, err)
+
+		_, err = service.GenerateConversationSparks(ctx, "user-1", "companion-1", 0)
+		var validationErr *apperrors.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
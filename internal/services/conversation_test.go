@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestResetConversationContextOncePerDay(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rejects a second reset within a day", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		lastReset := time.Now().Add(-time.Hour)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: conversationID}, {Key: "user_id", Value: "user"}}),
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, bson.D{{Key: "conversation_id", Value: conversationID}, {Key: "last_reset_at", Value: lastReset}}),
+		)
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), repositories.NewAnalyticsRepository(nil, mt.DB))
+		err := service.ResetConversationContext(context.Background(), conversationID, true)
+
+		var limitErr *ContextResetLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.ErrorIs(t, err, ErrContextResetTooSoon)
+		assert.InDelta(t, (23 * time.Hour).Seconds(), limitErr.RetryAfter.Seconds(), 60)
+		assert.Len(t, mt.GetAllStartedEvents(), 2, "nothing is written")
+	})
+
+	mt.Run("rejects a reset that loses the race to a concurrent one", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		contextDoc := bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "conversation_id", Value: conversationID}, {Key: "trust_level", Value: 0.9}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: conversationID}, {Key: "user_id", Value: "user"}}),
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, contextDoc),
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, contextDoc),
+			// The other reset wrote last_reset_at in between, so the guarded update matches nothing
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+		)
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), repositories.NewAnalyticsRepository(nil, mt.DB))
+		err := service.ResetConversationContext(context.Background(), conversationID, false)
+
+		var limitErr *ContextResetLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, contextResetInterval, limitErr.RetryAfter)
+		assert.Len(t, mt.GetAllStartedEvents(), 4, "no event, system message or analytics reset is written")
+	})
+
+	mt.Run("resets the relationship analytics", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		contextDoc := bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "conversation_id", Value: conversationID}, {Key: "last_reset_at", Value: time.Now().Add(-25 * time.Hour)}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversations", mtest.FirstBatch, bson.D{{Key: "_id", Value: conversationID}, {Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "companion"}}),
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, contextDoc),
+			mtest.CreateCursorResponse(0, "test.conversation_contexts", mtest.FirstBatch, contextDoc),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: contextDoc}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "test.relationship_analytics", mtest.FirstBatch, bson.D{{Key: "user_id", Value: "user"}, {Key: "companion_id", Value: "companion"}, {Key: "current_stage", Value: "partners"}, {Key: "trust_level", Value: 0.95}}),
+			mtest.CreateCursorResponse(0, "test.user_engagement_analytics", mtest.FirstBatch, bson.D{{Key: "engagement_score", Value: 0.5}, {Key: "conversation_depth", Value: 0.5}, {Key: "emotional_intensity", Value: 0.5}, {Key: "topic_diversity", Value: 0.5}, {Key: "vulnerability_level", Value: 0.5}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), repositories.NewAnalyticsRepository(nil, mt.DB))
+		require.NoError(t, service.ResetConversationContext(context.Background(), conversationID, false))
+
+		events := mt.GetAllStartedEvents()
+		set := events[len(events)-1].Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		assert.Equal(t, models.InitialRelationshipStage, set.Lookup("current_stage").StringValue())
+		assert.Equal(t, models.InitialTrustLevel, set.Lookup("trust_level").Double())
+		assert.Equal(t, models.InitialIntimacyLevel, set.Lookup("intimacy_level").Double())
+		want := analytics.ComputeChemistryScore(models.InitialTrustLevel, models.InitialIntimacyLevel, 0.5, 0.5)
+		assert.InDelta(t, want, set.Lookup("chemistry_score").Double(), 1e-9, "chemistry is rescored from the reset trust and intimacy")
+	})
+}
+
+func toBSONDoc(t *testing.T, v any) bson.D {
+	t.Helper()
+	raw, err := bson.Marshal(v)
+	require.NoError(t, err)
+	var doc bson.D
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+	return doc
+}
+
+func TestStartConversationFromTemplate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("creates the initial messages and context", func(mt *mtest.T) {
+		template := models.ConversationTemplate{
+			ID:          primitive.NewObjectID(),
+			TemplateID:  "first-date",
+			Name:        "First date",
+			Description: "Plan a first date together",
+			InitialMessages: []models.TemplateMessage{
+				{SenderType: sendertype.Companion, Text: "I've been looking forward to tonight!"},
+				{SenderType: sendertype.User, Text: "Me too, where should we go?"},
+			},
+			InitialContext: models.PartialConversationContext{CurrentTopic: "date planning", ConversationPacing: "slow"},
+			Tags:           []string{"romance"},
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.conversation_templates", mtest.FirstBatch, toBSONDoc(t, template)),
+			mtest.CreateSuccessResponse(),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), nil)
+		conv, err := service.StartConversation(context.Background(), "user", "companion", "partner", "first-date")
+		require.NoError(t, err)
+		require.Len(t, conv.RecentMessages, 2)
+		assert.Equal(t, "I've been looking forward to tonight!", *conv.RecentMessages[0].Text)
+
+		var texts, senders []string
+		var contextSet bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			switch {
+			case event.CommandName == "insert" && event.Command.Lookup("insert").StringValue() == "messages":
+				doc := event.Command.Lookup("documents").Array().Index(0).Value().Document()
+				assert.Equal(t, conv.ID, doc.Lookup("conversation_id").ObjectID())
+				texts = append(texts, doc.Lookup("text").StringValue())
+				senders = append(senders, doc.Lookup("sender_id").StringValue())
+			case event.CommandName == "findAndModify":
+				contextSet = event.Command.Lookup("update", "$set").Document()
+			}
+		}
+		assert.Equal(t, []string{"I've been looking forward to tonight!", "Me too, where should we go?"}, texts)
+		assert.Equal(t, []string{"companion", "user"}, senders)
+		require.NotNil(t, contextSet)
+		assert.Equal(t, "date planning", contextSet.Lookup("current_topic").StringValue())
+		assert.Equal(t, "slow", contextSet.Lookup("conversation_pacing").StringValue())
+		assert.Equal(t, models.InitialRelationshipStage, contextSet.Lookup("relationship_stage").StringValue(), "unset fields keep the new conversation defaults")
+	})
+
+	mt.Run("unknown template", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.conversation_templates", mtest.FirstBatch))
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), nil)
+		_, err := service.StartConversation(context.Background(), "user", "companion", "partner", "missing")
+
+		var notFound *apperrors.NotFoundError
+		assert.ErrorAs(t, err, &notFound)
+		assert.Len(t, mt.GetAllStartedEvents(), 1, "no conversation is created")
+	})
+}
+
+func TestCreateConversationTemplateValidation(t *testing.T) {
+	service := NewConversationService(nil, nil)
+	for name, template := range map[string]models.ConversationTemplate{
+		"missing template id": {Name: "First date"},
+		"system message":      {TemplateID: "t", Name: "T", InitialMessages: []models.TemplateMessage{{SenderType: sendertype.System, Text: "hi"}}},
+		"empty message":       {TemplateID: "t", Name: "T", InitialMessages: []models.TemplateMessage{{SenderType: sendertype.User}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var validationErr *apperrors.ValidationError
+			assert.ErrorAs(t, service.CreateConversationTemplate(context.Background(), &template), &validationErr)
+		})
+	}
+}
+
+func TestGenerateReplayManifest(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	message := func(offset time.Duration) bson.D {
+		return bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: start.Add(offset)}}
+	}
+	generate := func(mt *mtest.T, speed float64) *models.ReplayManifest {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.messages", mtest.FirstBatch,
+			message(0), message(90*time.Second), message(30*time.Second), message(5*time.Minute),
+		))
+
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), nil)
+		manifest, err := service.GenerateReplayManifest(context.Background(), primitive.NewObjectID(), speed)
+		require.NoError(mt, err)
+		return manifest
+	}
+
+	mt.Run("halves the total duration at 2x", func(mt *mtest.T) {
+		realTime := generate(mt, 1)
+		doubled := generate(mt, 2)
+
+		assert.Equal(t, (5 * time.Minute).Milliseconds(), realTime.TotalMs)
+		assert.Equal(t, realTime.TotalMs/2, doubled.TotalMs)
+
+		require.Len(t, doubled.Messages, 4)
+		deltas := []int64{0, 15_000, 30_000, 105_000}
+		for i, entry := range doubled.Messages {
+			assert.Equal(t, deltas[i], entry.DeltaMs)
+			assert.Equal(t, start.Add(time.Duration(entry.CumulativeMs)*time.Millisecond), entry.DisplayAt)
+		}
+		assert.Equal(t, doubled.TotalMs, doubled.Messages[3].CumulativeMs)
+	})
+
+	mt.Run("rejects speeds outside the supported range", func(mt *mtest.T) {
+		service := NewConversationService(repositories.NewConversationRepository(mt.DB), nil)
+		for _, speed := range []float64{0.25, 10.5} {
+			_, err := service.GenerateReplayManifest(context.Background(), primitive.NewObjectID(), speed)
+			assert.ErrorIs(t, err, ErrInvalidReplaySpeed)
+		}
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// ScriptedCriteria is the achievement criteria type whose condition is a CEL expression stored in
+// Criteria.Conditions["expression"], such as `progress.current_streak >= 7 && activityData.trust_level > 0.5`
+const ScriptedCriteria = "scripted"
+
+const (
+	// criteriaEvalTimeout bounds how long a single criteria expression may run
+	criteriaEvalTimeout = 100 * time.Millisecond
+	// criteriaInterruptFrequency is how many comprehension iterations run between timeout checks
+	criteriaInterruptFrequency = 100
+	// criteriaCostLimit caps the work a single evaluation may do. Nested comprehensions only notice the timeout
+	// in their innermost loop, so the cost limit is what stops them.
+	criteriaCostLimit = 100000
+)
+
+// compiledCriteria is a criteria program together with the expression it was compiled from
+type compiledCriteria struct {
+	expression string
+	program    cel.Program
+}
+
+// CriteriaEvaluator runs scripted achievement criteria. Expressions see two maps, progress and activityData, keyed
+// by the snake_case field names of the user's progress and the activity being scored.
+type CriteriaEvaluator struct {
+	env      *cel.Env
+	programs *cache.Cache[compiledCriteria]
+}
+
+// NewCriteriaEvaluator creates a criteria evaluator
+func NewCriteriaEvaluator() *CriteriaEvaluator {
+	env, err := cel.NewEnv(
+		cel.Variable("progress", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("activityData", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		// The environment is fixed, so this only fails if the declarations above are wrong
+		panic(fmt.Sprintf("invalid criteria environment: %v", err))
+	}
+
+	return &CriteriaEvaluator{
+		env:      env,
+		programs: cache.New[compiledCriteria](),
+	}
+}
+
+// Compile checks that expression is a valid boolean criteria expression and caches its program under criteriaID.
+// A changed expression for the same ID is recompiled.
+func (e *CriteriaEvaluator) Compile(criteriaID, expression string) (cel.Program, error) {
+	if compiled, ok := e.programs.Get(criteriaID); ok && compiled.expression == expression {
+		return compiled.program, nil
+	}
+
+	ast, issues := e.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, apperrors.NewValidationError("invalid criteria expression", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, apperrors.NewValidationError(fmt.Sprintf("criteria expression must return a bool, not %s", ast.OutputType()), nil)
+	}
+
+	program, err := e.env.Program(ast, cel.InterruptCheckFrequency(criteriaInterruptFrequency), cel.CostLimit(criteriaCostLimit))
+	if err != nil {
+		return nil, apperrors.NewValidationError("invalid criteria expression", err)
+	}
+
+	e.programs.Set(criteriaID, compiledCriteria{expression: expression, program: program})
+	return program, nil
+}
+
+// Evaluate reports whether the scripted criteria of definition are met, giving up after criteriaEvalTimeout or
+// criteriaCostLimit, whichever comes first
+func (e *CriteriaEvaluator) Evaluate(ctx context.Context, definition *models.AchievementDefinition, progress *models.UserProgress, activityData *ActivityData) (bool, error) {
+	expression, ok := definition.Criteria.Conditions["expression"].(string)
+	if !ok || expression == "" {
+		return false, apperrors.NewValidationError("scripted criteria have no expression", nil)
+	}
+
+	program, err := e.Compile(definition.ID, expression)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, criteriaEvalTimeout)
+	defer cancel()
+
+	out, _, err := program.ContextEval(ctx, map[string]any{
+		"progress":     progressBindings(progress),
+		"activityData": activityBindings(activityData),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate criteria %s: %w", definition.ID, err)
+	}
+
+	met, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("criteria %s returned %T, not bool", definition.ID, out.Value())
+	}
+	return met, nil
+}
+
+// progressBindings exposes the user's progress to criteria expressions
+func progressBindings(progress *models.UserProgress) map[string]any {
+	if progress == nil {
+		return map[string]any{}
+	}
+	return map[string]any{
+		"total_experience":    progress.TotalExperience,
+		"current_level":       progress.CurrentLevel,
+		"level_progress":      progress.LevelProgress,
+		"relationship_stage":  progress.RelationshipStage,
+		"stage_progress":      progress.StageProgress,
+		"current_streak":      progress.CurrentStreak,
+		"longest_streak":      progress.LongestStreak,
+		"total_achievements":  progress.TotalAchievements,
+		"rare_achievements":   progress.RareAchievements,
+		"total_conversations": progress.TotalConversations,
+		"total_messages":      progress.TotalMessages,
+		"total_time_spent":    progress.TotalTimeSpent.Minutes(),
+	}
+}
+
+// activityBindings exposes the activity being scored to criteria expressions. Durations are in minutes, except the
+// relationship age, which is in days like the relationship_duration criteria.
+func activityBindings(activityData *ActivityData) map[string]any {
+	if activityData == nil {
+		return map[string]any{}
+	}
+	return map[string]any{
+		"session_duration":    activityData.SessionDuration.Minutes(),
+		"message_count":       activityData.MessageCount,
+		"conversation_depth":  activityData.ConversationDepth,
+		"emotional_intensity": activityData.EmotionalIntensity,
+		"vulnerability_level": activityData.VulnerabilityLevel,
+		"trust_level":         activityData.TrustLevel,
+		"intimacy_level":      activityData.IntimacyLevel,
+		"relationship_age":    activityData.RelationshipAge.Hours() / 24,
+	}
+}
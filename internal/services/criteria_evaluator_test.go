@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scriptedDefinition(id, expression string) *models.AchievementDefinition {
+	return &models.AchievementDefinition{
+		ID:       id,
+		Criteria: models.AchievementCriteria{Type: ScriptedCriteria, Conditions: map[string]any{"expression": expression}},
+	}
+}
+
+func TestCriteriaEvaluator(t *testing.T) {
+	ctx := context.Background()
+	progress := &models.UserProgress{CurrentStreak: 7, TotalMessages: 120, RelationshipStage: "close_friends"}
+	activity := &ActivityData{SessionDuration: 45 * time.Minute, TrustLevel: 0.8, RelationshipAge: 10 * 24 * time.Hour}
+
+	t.Run("evaluates valid expressions against progress and activity", func(t *testing.T) {
+		evaluator := NewCriteriaEvaluator()
+		tests := []struct {
+			expression string
+			met        bool
+		}{
+			{`progress.current_streak >= 7 && activityData.trust_level > 0.5`, true},
+			{`progress.total_messages > 200`, false},
+			{`activityData.session_duration >= 30.0 && activityData.relationship_age >= 7.0`, true},
+			{`progress.relationship_stage == "close_friends"`, true},
+		}
+		for _, tt := range tests {
+			met, err := evaluator.Evaluate(ctx, scriptedDefinition("criteria-"+tt.expression, tt.expression), progress, activity)
+			require.NoError(t, err, tt.expression)
+			assert.Equal(t, tt.met, met, tt.expression)
+		}
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		evaluator := NewCriteriaEvaluator()
+		for _, expression := range []string{"", `progress.current_streak >=`, `progress.current_streak + 1`, `unknown.field > 1`} {
+			_, err := evaluator.Evaluate(ctx, scriptedDefinition("invalid", expression), progress, activity)
+			var validationErr *apperrors.ValidationError
+			assert.ErrorAs(t, err, &validationErr, expression)
+		}
+	})
+
+	t.Run("reports missing fields as evaluation errors", func(t *testing.T) {
+		_, err := NewCriteriaEvaluator().Evaluate(ctx, scriptedDefinition("missing", `progress.no_such_field > 1`), progress, activity)
+		assert.Error(t, err)
+	})
+
+	t.Run("caches programs by criteria ID until the expression changes", func(t *testing.T) {
+		evaluator := NewCriteriaEvaluator()
+		first, err := evaluator.Compile("streak", `progress.current_streak >= 7`)
+		require.NoError(t, err)
+		again, err := evaluator.Compile("streak", `progress.current_streak >= 7`)
+		require.NoError(t, err)
+		assert.Same(t, first, again)
+
+		met, err := evaluator.Evaluate(ctx, scriptedDefinition("streak", `progress.current_streak >= 30`), progress, activity)
+		require.NoError(t, err)
+		assert.False(t, met, "an edited expression is recompiled")
+	})
+
+	t.Run("stops runaway expressions at the cost limit", func(t *testing.T) {
+		expression := `[1,2,3,4,5,6,7,8,9,10].all(a, [1,2,3,4,5,6,7,8,9,10].all(b, [1,2,3,4,5,6,7,8,9,10].all(c, [1,2,3,4,5,6,7,8,9,10].all(d, [1,2,3,4,5,6,7,8,9,10].all(e, [1,2,3,4,5,6,7,8,9,10].all(f, [1,2,3,4,5,6,7,8,9,10].all(g, [1,2,3,4,5,6,7,8,9,10].all(h, a+b+c+d+e+f+g+h > 0))))))))`
+
+		start := time.Now()
+		_, err := NewCriteriaEvaluator().Evaluate(ctx, scriptedDefinition("runaway", expression), progress, activity)
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("stops evaluation once the context is done", func(t *testing.T) {
+		items := strings.TrimSuffix(strings.Repeat("1,", 500), ",")
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := NewCriteriaEvaluator().Evaluate(cancelled, scriptedDefinition("cancelled", "["+items+"].all(x, x > 0)"), progress, activity)
+		assert.ErrorContains(t, err, "interrupted")
+	})
+
+	t.Run("decides scripted achievements", func(t *testing.T) {
+		service := NewGamificationService(nil, nil, nil, nil, nil, "")
+		assert.True(t, service.checkAchievementCriteria(ctx, scriptedDefinition("week", `progress.current_streak >= 7`), progress, activity))
+		assert.False(t, service.checkAchievementCriteria(ctx, scriptedDefinition("broken", `progress.current_streak >=`), progress, activity))
+	})
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// dashboardPercentileTTL is how long a user's dashboard percentiles are reused before being recomputed
+const dashboardPercentileTTL = time.Hour
+
+// percentileRanker ranks a user against their peers, implemented by *PrivacyAnalyticsService
+type percentileRanker interface {
+	GetUserPercentileRank(ctx context.Context, userID string) (*PercentileReport, error)
+}
+
+// cachedPercentiles holds a user's percentile report until it expires
+type cachedPercentiles struct {
+	report    *PercentileReport
+	expiresAt time.Time
+}
+
+// dashboardPercentileKey is the cache key of a user's percentile report
+func dashboardPercentileKey(userID string) string {
+	return "dashboard_percentiles:" + userID
+}
+
+// addPercentiles fills in the user's conversation quality and engagement percentiles. Ranking scans every user's
+// analytics, so reports are cached for an hour per user when the service has a cache. Percentiles are left at zero
+// if they cannot be computed.
+func (s *AnalyticsService) addPercentiles(ctx context.Context, userID string, statistics *models.UserStatistics) {
+	report, err := s.userPercentiles(ctx, userID)
+	if err != nil {
+		s.options.logger().Error("Failed to compute dashboard percentiles", "user_id", userID, "error", err)
+		return
+	}
+
+	if metric := report.ConversationQuality; metric != nil && metric.Percentile != nil {
+		statistics.ConversationQualityPercentile = *metric.Percentile
+	}
+	if metric := report.EngagementScore; metric != nil && metric.Percentile != nil {
+		statistics.EngagementPercentile = *metric.Percentile
+	}
+}
+
+// userPercentiles returns the user's cached percentile report, computing it when missing or expired
+func (s *AnalyticsService) userPercentiles(ctx context.Context, userID string) (*PercentileReport, error) {
+	key := dashboardPercentileKey(userID)
+	if value, ok := s.options.cached(key); ok {
+		if cached, ok := value.(cachedPercentiles); ok && s.now().Before(cached.expiresAt) {
+			return cached.report, nil
+		}
+	}
+
+	report, err := s.percentiles.GetUserPercentileRank(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.options.store(key, cachedPercentiles{report: report, expiresAt: s.now().Add(dashboardPercentileTTL)})
+	return report, nil
+}
+
+// OnUserEngagementAnalyticsUpsert drops the user's cached percentiles so the dashboard reflects their new session,
+// registered with AnalyticsRepository.OnUserEngagementAnalyticsUpsert
+func (s *AnalyticsService) OnUserEngagementAnalyticsUpsert(_ context.Context, userID string) {
+	s.options.invalidate(dashboardPercentileKey(userID))
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestDashboardPercentiles(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("ranks against synthetic users", func(mt *mtest.T) {
+		// Five users, the dashboard's user scoring 0.7 on both metrics
+		engagement := []float64{0.3, 0.5, 0.7, 0.7, 0.9}
+		quality := []float64{0.2, 0.4, 0.6, 0.7, 0.8}
+		userValue := 0.7
+		cohort := func(values []float64) bson.D {
+			below, equal := 0, 0
+			for _, value := range values {
+				if value < userValue {
+					below++
+				} else if value == userValue {
+					equal++
+				}
+			}
+			return bson.D{{Key: "total", Value: len(values)}, {Key: "below", Value: below}, {Key: "equal", Value: equal}}
+		}
+		userResult := bson.D{{Key: "value", Value: userValue}}
+		addRankResponses := func() {
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{}}),
+				// Session frequency, streak and intimacy have no data for the user
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, userResult),
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, cohort(engagement)),
+				mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch),
+				mtest.CreateCursorResponse(0, "lunaria.relationship_analytics", mtest.FirstBatch),
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, userResult),
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, cohort(quality)),
+			)
+		}
+
+		repo := repositories.NewAnalyticsRepository(nil, mt.DB)
+		ranker := NewPrivacyAnalyticsService(repo, nil)
+		ranker.minCohortSize = 5
+		service := NewAnalyticsService(nil, repo, nil, WithCache(cache.New[any]()))
+		service.percentiles = ranker
+		ctx := context.Background()
+
+		addRankResponses()
+		statistics := &models.UserStatistics{}
+		service.addPercentiles(ctx, "user-1", statistics)
+		// Engagement: 2 below and 2 tied of 5. Quality: 3 below and 1 tied of 5.
+		assert.InDelta(t, 60, statistics.EngagementPercentile, 5)
+		assert.InDelta(t, 70, statistics.ConversationQualityPercentile, 5)
+		mt.ClearEvents()
+
+		cached := &models.UserStatistics{}
+		service.addPercentiles(ctx, "user-1", cached)
+		assert.Equal(t, statistics, cached)
+		assert.Nil(t, mt.GetStartedEvent(), "percentiles are served from the cache")
+
+		service.OnUserEngagementAnalyticsUpsert(ctx, "user-1")
+		engagement[0] = 0.8
+		addRankResponses()
+		refreshed := &models.UserStatistics{}
+		service.addPercentiles(ctx, "user-1", refreshed)
+		require.NotNil(t, mt.GetStartedEvent(), "a new session recomputes the percentiles")
+		assert.InDelta(t, 40, refreshed.EngagementPercentile, 5)
+	})
+
+	mt.Run("suppressed below the minimum cohort", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{}}),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, bson.D{{Key: "value", Value: 0.7}}),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch,
+				bson.D{{Key: "total", Value: 5}, {Key: "below", Value: 2}, {Key: "equal", Value: 1}}),
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.relationship_analytics", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+		)
+
+		repo := repositories.NewAnalyticsRepository(nil, mt.DB)
+		service := NewAnalyticsService(nil, repo, nil)
+
+		statistics := &models.UserStatistics{}
+		service.addPercentiles(context.Background(), "user-1", statistics)
+		assert.Zero(t, statistics.EngagementPercentile)
+		assert.Zero(t, statistics.ConversationQualityPercentile)
+	})
+}
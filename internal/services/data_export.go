@@ -0,0 +1,294 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultExportWorkers is the number of exports processed at once when no worker count is configured
+	DefaultExportWorkers = 3
+	// exportPollInterval is how often idle workers check the queue for jobs they were not woken for
+	exportPollInterval = 30 * time.Second
+	// exportDownloadExpiry is how long a presigned export download URL stays valid
+	exportDownloadExpiry = 15 * time.Minute
+	// exportPageSize is the number of conversations read per query while building an export
+	exportPageSize = 100
+	// exportAuditLogLimit caps the consent audit events included in an export
+	exportAuditLogLimit = 1000
+)
+
+// exportJobStore persists the export queue
+type exportJobStore interface {
+	CreateExportJob(ctx context.Context, job *models.ExportJob) error
+	ClaimNextExportJob(ctx context.Context) (*models.ExportJob, error)
+	CompleteExportJob(ctx context.Context, id primitive.ObjectID, objectKey, downloadURL string) error
+	FailExportJob(ctx context.Context, id primitive.ObjectID, message string) error
+	GetExportJob(ctx context.Context, id primitive.ObjectID) (*models.ExportJob, error)
+}
+
+// exportConversationSource reads a user's conversations and their messages
+type exportConversationSource interface {
+	ListUserConversations(ctx context.Context, userID string, archived bool, limit, offset int) ([]*models.Conversation, error)
+	ForEachMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error
+}
+
+// exportAnalyticsSource reads the analytics kept about a user
+type exportAnalyticsSource interface {
+	GetAllRelationshipAnalytics(ctx context.Context, userID string) ([]models.RelationshipAnalytics, error)
+	GetAllUserProgress(ctx context.Context, userID string) ([]models.UserProgress, error)
+	GetConsentAuditLog(ctx context.Context, userID string, limit int) ([]models.ConsentAuditEvent, error)
+}
+
+// exportObjectStore uploads finished exports
+type exportObjectStore interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// exportURLPresigner signs short-lived download links, implemented by *s3.PresignClient
+type exportURLPresigner interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// DataExportService produces ZIP archives of a user's data. Requests are queued in MongoDB and handled by a
+// small pool of workers, so a burst of exports cannot run unbounded full scans against the database.
+type DataExportService struct {
+	jobs          exportJobStore
+	conversations exportConversationSource
+	analytics     exportAnalyticsSource
+	objects       exportObjectStore
+	presigner     exportURLPresigner
+	bucket        string
+	endpoint      string
+	workers       int
+	wake          chan struct{}
+
+	options ServiceConfig
+}
+
+// NewDataExportService creates an export service. A non-positive workers uses DefaultExportWorkers.
+func NewDataExportService(jobs exportJobStore, conversations exportConversationSource, analytics exportAnalyticsSource, objects exportObjectStore, presigner exportURLPresigner, bucket, endpoint string, workers int, opts ...Option) *DataExportService {
+	if workers <= 0 {
+		workers = DefaultExportWorkers
+	}
+
+	return &DataExportService{
+		jobs:          jobs,
+		conversations: conversations,
+		analytics:     analytics,
+		objects:       objects,
+		presigner:     presigner,
+		bucket:        bucket,
+		endpoint:      endpoint,
+		workers:       workers,
+		wake:          make(chan struct{}, workers),
+		options:       newServiceConfig(opts),
+	}
+}
+
+// Start runs the worker pool until ctx is cancelled. Each worker handles one job at a time.
+func (s *DataExportService) Start(ctx context.Context) {
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+}
+
+// work processes queued jobs, waiting for a wake-up or the poll interval whenever the queue is empty
+func (s *DataExportService) work(ctx context.Context) {
+	for {
+		processed, err := s.ProcessNext(ctx)
+		if err != nil {
+			s.options.logger().Error("Data export worker failed", "error", err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(exportPollInterval):
+		}
+	}
+}
+
+// ExportUserData queues an export of everything stored about the user and returns the job ID
+func (s *DataExportService) ExportUserData(ctx context.Context, userID string) (string, error) {
+	job := &models.ExportJob{UserID: userID}
+	if err := s.jobs.CreateExportJob(ctx, job); err != nil {
+		return "", err
+	}
+
+	// Wake an idle worker; if all are busy, one of them picks the job up when it finishes
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return job.ID.Hex(), nil
+}
+
+// GetExportJobStatus returns an export job
+func (s *DataExportService) GetExportJobStatus(ctx context.Context, jobID string) (*models.ExportJob, error) {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, apperrors.NewValidationError("invalid export job ID", err)
+	}
+	return s.jobs.GetExportJob(ctx, id)
+}
+
+// GetExportDownloadURL returns a short-lived link to a completed export
+func (s *DataExportService) GetExportDownloadURL(ctx context.Context, jobID string) (string, error) {
+	job, err := s.GetExportJobStatus(ctx, jobID)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != models.ExportStatusCompleted {
+		return "", apperrors.NewConflictError(fmt.Sprintf("export job is %s", job.Status), nil)
+	}
+	if s.presigner == nil {
+		return job.DownloadURL, nil
+	}
+
+	presigned, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(job.ObjectKey),
+	}, s3.WithPresignExpires(exportDownloadExpiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign export download URL: %w", err)
+	}
+
+	return presigned.URL, nil
+}
+
+// ProcessNext claims the oldest queued job and runs it, reporting whether there was a job to run.
+// A job that cannot be exported is marked failed rather than returned as an error.
+func (s *DataExportService) ProcessNext(ctx context.Context) (bool, error) {
+	job, err := s.jobs.ClaimNextExportJob(ctx)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("exports/%s/%s.zip", job.UserID, job.ID.Hex())
+	if err := s.export(ctx, job.UserID, key); err != nil {
+		return true, s.jobs.FailExportJob(ctx, job.ID, err.Error())
+	}
+
+	downloadURL := fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, key)
+	return true, s.jobs.CompleteExportJob(ctx, job.ID, key, downloadURL)
+}
+
+// export writes the user's data to a ZIP archive and uploads it under key
+func (s *DataExportService) export(ctx context.Context, userID, key string) error {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	if err := s.writeConversations(ctx, archive, userID); err != nil {
+		return err
+	}
+
+	relationships, err := s.analytics.GetAllRelationshipAnalytics(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to read relationship analytics: %w", err)
+	}
+	progress, err := s.analytics.GetAllUserProgress(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to read progress: %w", err)
+	}
+	auditLog, err := s.analytics.GetConsentAuditLog(ctx, userID, exportAuditLogLimit)
+	if err != nil {
+		return fmt.Errorf("failed to read consent audit log: %w", err)
+	}
+	for name, data := range map[string]any{
+		"relationship_analytics.json": relationships,
+		"progress.json":               progress,
+		"consent_audit_log.json":      auditLog,
+	} {
+		if err := writeZipJSON(archive, name, data); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish export archive: %w", err)
+	}
+
+	_, err = s.objects.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/zip"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload export: %w", err)
+	}
+
+	return nil
+}
+
+// writeConversations adds conversations.json and one NDJSON message file per conversation
+func (s *DataExportService) writeConversations(ctx context.Context, archive *zip.Writer, userID string) error {
+	var conversations []*models.Conversation
+	for _, archived := range []bool{false, true} {
+		for offset := 0; ; offset += exportPageSize {
+			page, err := s.conversations.ListUserConversations(ctx, userID, archived, exportPageSize, offset)
+			if err != nil {
+				return fmt.Errorf("failed to read conversations: %w", err)
+			}
+			conversations = append(conversations, page...)
+			if len(page) < exportPageSize {
+				break
+			}
+		}
+	}
+
+	if err := writeZipJSON(archive, "conversations.json", conversations); err != nil {
+		return err
+	}
+
+	for _, conversation := range conversations {
+		w, err := archive.Create(fmt.Sprintf("messages/%s.ndjson", conversation.ID.Hex()))
+		if err != nil {
+			return fmt.Errorf("failed to add messages to export: %w", err)
+		}
+		encoder := json.NewEncoder(w)
+		err = s.conversations.ForEachMessage(ctx, conversation.ID, func(msg *models.Message) error {
+			return encoder.Encode(msg)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export messages: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeZipJSON adds data to the archive as an indented JSON file
+func writeZipJSON(archive *zip.Writer, name string, data any) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
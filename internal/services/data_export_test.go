@@ -0,0 +1,193 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryExportJobStore is a FIFO export queue that records the order jobs are claimed in
+type memoryExportJobStore struct {
+	mu      sync.Mutex
+	jobs    []*models.ExportJob
+	claimed []string
+}
+
+func (m *memoryExportJobStore) CreateExportJob(ctx context.Context, job *models.ExportJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = primitive.NewObjectID()
+	job.Status = models.ExportStatusQueued
+	job.CreatedAt = time.Now()
+	m.jobs = append(m.jobs, job)
+	return nil
+}
+
+func (m *memoryExportJobStore) ClaimNextExportJob(ctx context.Context) (*models.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.Status == models.ExportStatusQueued {
+			job.Status = models.ExportStatusProcessing
+			m.claimed = append(m.claimed, job.ID.Hex())
+			claimed := *job
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryExportJobStore) CompleteExportJob(ctx context.Context, id primitive.ObjectID, objectKey, downloadURL string) error {
+	return m.update(id, func(job *models.ExportJob) {
+		job.Status = models.ExportStatusCompleted
+		job.ObjectKey = objectKey
+		job.DownloadURL = downloadURL
+	})
+}
+
+func (m *memoryExportJobStore) FailExportJob(ctx context.Context, id primitive.ObjectID, message string) error {
+	return m.update(id, func(job *models.ExportJob) {
+		job.Status = models.ExportStatusFailed
+		job.Error = message
+	})
+}
+
+func (m *memoryExportJobStore) GetExportJob(ctx context.Context, id primitive.ObjectID) (*models.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.ID == id {
+			found := *job
+			return &found, nil
+		}
+	}
+	return nil, errors.New("export job not found")
+}
+
+func (m *memoryExportJobStore) update(id primitive.ObjectID, fn func(*models.ExportJob)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.ID == id {
+			fn(job)
+			return nil
+		}
+	}
+	return errors.New("export job not found")
+}
+
+// fakeExportSource holds one conversation per user and no analytics
+type fakeExportSource struct {
+	failFor string
+}
+
+func (f *fakeExportSource) ListUserConversations(ctx context.Context, userID string, archived bool, limit, offset int) ([]*models.Conversation, error) {
+	if userID == f.failFor {
+		return nil, errors.New("database unavailable")
+	}
+	if archived || offset > 0 {
+		return nil, nil
+	}
+	return []*models.Conversation{{ID: primitive.NewObjectID(), UserID: userID}}, nil
+}
+
+func (f *fakeExportSource) ForEachMessage(ctx context.Context, conversationID primitive.ObjectID, fn func(*models.Message) error) error {
+	text := "hello"
+	return fn(&models.Message{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "text", Text: &text})
+}
+
+func (f *fakeExportSource) GetAllRelationshipAnalytics(ctx context.Context, userID string) ([]models.RelationshipAnalytics, error) {
+	return nil, nil
+}
+
+func (f *fakeExportSource) GetAllUserProgress(ctx context.Context, userID string) ([]models.UserProgress, error) {
+	return nil, nil
+}
+
+func (f *fakeExportSource) GetConsentAuditLog(ctx context.Context, userID string, limit int) ([]models.ConsentAuditEvent, error) {
+	return nil, nil
+}
+
+func TestDataExportService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("processes queued jobs oldest first", func(t *testing.T) {
+		store := &memoryExportJobStore{}
+		source := &fakeExportSource{failFor: "user-2"}
+		objects := &mockS3Client{objects: map[string][]byte{}}
+		service := NewDataExportService(store, source, source, objects, nil, "lunaria", "https://s3.example.com", 1)
+
+		var jobIDs []string
+		for _, userID := range []string{"user-1", "user-2", "user-3"} {
+			jobID, err := service.ExportUserData(ctx, userID)
+			require.NoError(t, err)
+			jobIDs = append(jobIDs, jobID)
+		}
+
+		job, err := service.GetExportJobStatus(ctx, jobIDs[0])
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusQueued, job.Status)
+
+		for range jobIDs {
+			processed, err := service.ProcessNext(ctx)
+			require.NoError(t, err)
+			assert.True(t, processed)
+		}
+		processed, err := service.ProcessNext(ctx)
+		require.NoError(t, err)
+		assert.False(t, processed, "queue should be empty")
+		assert.Equal(t, jobIDs, store.claimed)
+
+		completed, err := service.GetExportJobStatus(ctx, jobIDs[0])
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusCompleted, completed.Status)
+		url, err := service.GetExportDownloadURL(ctx, jobIDs[0])
+		require.NoError(t, err)
+		assert.Equal(t, "https://s3.example.com/lunaria/exports/user-1/"+jobIDs[0]+".zip", url)
+
+		archive := objects.objects["lunaria/exports/user-1/"+jobIDs[0]+".zip"]
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		require.NoError(t, err)
+		var names []string
+		for _, file := range reader.File {
+			names = append(names, file.Name)
+		}
+		assert.Contains(t, names, "conversations.json")
+		assert.Contains(t, names, "consent_audit_log.json")
+		assert.Len(t, names, 5)
+
+		failed, err := service.GetExportJobStatus(ctx, jobIDs[1])
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusFailed, failed.Status)
+		assert.Contains(t, failed.Error, "database unavailable")
+		_, err = service.GetExportDownloadURL(ctx, jobIDs[1])
+		assert.Error(t, err)
+	})
+
+	t.Run("workers pick up newly queued jobs", func(t *testing.T) {
+		store := &memoryExportJobStore{}
+		source := &fakeExportSource{}
+		service := NewDataExportService(store, source, source, &mockS3Client{objects: map[string][]byte{}}, nil, "lunaria", "", 1)
+
+		workerCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		service.Start(workerCtx)
+
+		jobID, err := service.ExportUserData(ctx, "user-1")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			job, err := service.GetExportJobStatus(ctx, jobID)
+			return err == nil && job.Status == models.ExportStatusCompleted
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// Feature flags guarding shadow-mode and experimental features
+const (
+	// FlagShadowResponseValidation scores every companion reply in the background
+	FlagShadowResponseValidation = "shadow_response_validation"
+	// FlagBehaviorRulesExperiment enrolls users in the behavior rules prompt experiment
+	FlagBehaviorRulesExperiment = "behavior_rules_experiment"
+	// FlagStatisticsRollupReads serves user statistics from the daily rollup; enable it once the history is backfilled
+	FlagStatisticsRollupReads = "statistics_rollup_reads"
+)
+
+// FeatureFlags reports whether a feature is enabled for a user; *FeatureFlagService satisfies it
+type FeatureFlags interface {
+	IsEnabled(ctx context.Context, flagName, userID string) (bool, error)
+}
+
+// featureFlagCacheTTL is how long a flag or override row is reused before being reloaded. Changes made through this
+// service apply at once; changes made elsewhere, e.g. on another instance, apply within the TTL.
+const featureFlagCacheTTL = 30 * time.Second
+
+// featureFlagStore loads and saves feature flags and per-user overrides
+type featureFlagStore interface {
+	GetFlag(ctx context.Context, flagName string) (*models.FeatureFlag, error)
+	UpsertFlag(ctx context.Context, flag *models.FeatureFlag) (*models.FeatureFlag, error)
+	GetUserOverride(ctx context.Context, flagName string, userID uuid.UUID) (*models.UserFlagOverride, error)
+	SetUserOverride(ctx context.Context, override *models.UserFlagOverride) error
+}
+
+// cachedFlagRow holds a flag or override row until it expires. A nil row records that there is none.
+type cachedFlagRow[T any] struct {
+	row       *T
+	expiresAt time.Time
+}
+
+// FeatureFlagService evaluates runtime feature flags with percentage rollouts and per-user overrides. Flags are
+// checked on hot paths, so with WithCache the rows are cached for featureFlagCacheTTL.
+type FeatureFlagService struct {
+	store featureFlagStore
+	now   func() time.Time
+
+	options ServiceConfig
+}
+
+// NewFeatureFlagService creates a new feature flag service
+func NewFeatureFlagService(store featureFlagStore, opts ...Option) *FeatureFlagService {
+	return &FeatureFlagService{
+		store:   store,
+		now:     time.Now,
+		options: newServiceConfig(opts),
+	}
+}
+
+func featureFlagKey(flagName string) string {
+	return "feature_flag:" + flagName
+}
+
+func featureFlagOverrideKey(flagName string, userID uuid.UUID) string {
+	return "feature_flag_override:" + flagName + ":" + userID.String()
+}
+
+// IsEnabled reports whether flagName is on for userID. A user override wins; otherwise the flag must be enabled
+// globally and the user must fall inside its rollout percentage. Unknown flags are off.
+func (s *FeatureFlagService) IsEnabled(ctx context.Context, flagName, userID string) (bool, error) {
+	if id, err := uuid.Parse(userID); err == nil {
+		override, err := cachedRow(s, featureFlagOverrideKey(flagName, id), func() (*models.UserFlagOverride, error) {
+			return s.store.GetUserOverride(ctx, flagName, id)
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to get feature flag override: %w", err)
+		}
+		if override != nil {
+			return override.Enabled, nil
+		}
+	}
+
+	flag, err := cachedRow(s, featureFlagKey(flagName), func() (*models.FeatureFlag, error) {
+		return s.store.GetFlag(ctx, flagName)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	if flag == nil {
+		return false, nil
+	}
+
+	return flag.EnabledGlobally && inRollout(userID, flagName, flag.RolloutPercentage), nil
+}
+
+// UpsertFlag creates a feature flag or updates its state
+func (s *FeatureFlagService) UpsertFlag(ctx context.Context, flag *models.FeatureFlag) (*models.FeatureFlag, error) {
+	saved, err := s.store.UpsertFlag(ctx, flag)
+	if err != nil {
+		return nil, err
+	}
+	s.options.invalidate(featureFlagKey(flag.FlagName))
+	return saved, nil
+}
+
+// SetUserOverride turns a feature flag on or off for one user
+func (s *FeatureFlagService) SetUserOverride(ctx context.Context, override *models.UserFlagOverride) error {
+	if err := s.store.SetUserOverride(ctx, override); err != nil {
+		return err
+	}
+	s.options.invalidate(featureFlagOverrideKey(override.FlagName, override.UserID))
+	return nil
+}
+
+// cachedRow returns the row cached under key, loading and caching it when missing or expired. A not found error
+// from load is cached as a nil row.
+func cachedRow[T any](s *FeatureFlagService, key string, load func() (*T, error)) (*T, error) {
+	if value, ok := s.options.cached(key); ok {
+		if cached, ok := value.(cachedFlagRow[T]); ok && s.now().Before(cached.expiresAt) {
+			return cached.row, nil
+		}
+	}
+
+	var notFound *apperrors.NotFoundError
+	row, err := load()
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+	s.options.store(key, cachedFlagRow[T]{row: row, expiresAt: s.now().Add(featureFlagCacheTTL)})
+	return row, nil
+}
+
+// inRollout reports whether the user's stable bucket for the flag falls below the rollout percentage
+func inRollout(userID, flagName string, percentage int) bool {
+	return bucket(userID, flagName, 100) < percentage
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFeatureFlagStore struct {
+	flags     map[string]*models.FeatureFlag
+	overrides map[string]*models.UserFlagOverride
+	reads     int
+}
+
+func (f *fakeFeatureFlagStore) GetFlag(ctx context.Context, flagName string) (*models.FeatureFlag, error) {
+	f.reads++
+	if flag, ok := f.flags[flagName]; ok {
+		return flag, nil
+	}
+	return nil, apperrors.NewNotFoundError("feature flag not found", nil)
+}
+
+func (f *fakeFeatureFlagStore) UpsertFlag(ctx context.Context, flag *models.FeatureFlag) (*models.FeatureFlag, error) {
+	f.flags[flag.FlagName] = flag
+	return flag, nil
+}
+
+func (f *fakeFeatureFlagStore) GetUserOverride(ctx context.Context, flagName string, userID uuid.UUID) (*models.UserFlagOverride, error) {
+	f.reads++
+	if override, ok := f.overrides[flagName+userID.String()]; ok {
+		return override, nil
+	}
+	return nil, apperrors.NewNotFoundError("feature flag override not found", nil)
+}
+
+func TestFeatureFlagRolloutBoundaries(t *testing.T) {
+	const flagName = "new_feature"
+	userID := uuid.New().String()
+	userBucket := bucket(userID, flagName, 100)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		percentage int
+		want       bool
+	}{
+		{"0% enables nobody", true, 0, false},
+		{"100% enables everybody", true, 100, true},
+		{"percentage equal to the user's bucket excludes them", true, userBucket, false},
+		{"percentage one above the user's bucket includes them", true, userBucket + 1, true},
+		{"disabled globally ignores the rollout", false, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeFeatureFlagStore{flags: map[string]*models.FeatureFlag{
+				flagName: {FlagName: flagName, EnabledGlobally: tt.enabled, RolloutPercentage: tt.percentage},
+			}}
+
+			enabled, err := NewFeatureFlagService(store).IsEnabled(context.Background(), flagName, userID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, enabled)
+		})
+	}
+}
+
+func TestFeatureFlagRolloutCoversPercentageOfUsers(t *testing.T) {
+	const flagName = "new_feature"
+	service := NewFeatureFlagService(&fakeFeatureFlagStore{flags: map[string]*models.FeatureFlag{
+		flagName: {FlagName: flagName, EnabledGlobally: true, RolloutPercentage: 25},
+	}})
+
+	enabled := 0
+	for range 4000 {
+		on, err := service.IsEnabled(context.Background(), flagName, uuid.New().String())
+		require.NoError(t, err)
+		if on {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 1000, enabled, 150)
+}
+
+func TestFeatureFlagOverrides(t *testing.T) {
+	const flagName = "new_feature"
+	userID := uuid.New()
+	store := &fakeFeatureFlagStore{
+		flags: map[string]*models.FeatureFlag{
+			flagName: {FlagName: flagName, EnabledGlobally: true, RolloutPercentage: 100},
+		},
+		overrides: map[string]*models.UserFlagOverride{
+			flagName + userID.String(): {UserID: userID, FlagName: flagName, Enabled: false},
+		},
+	}
+	service := NewFeatureFlagService(store)
+
+	enabled, err := service.IsEnabled(context.Background(), flagName, userID.String())
+	require.NoError(t, err)
+	assert.False(t, enabled, "the override wins over a full rollout")
+
+	store.flags[flagName].EnabledGlobally = false
+	store.overrides[flagName+userID.String()].Enabled = true
+	enabled, err = service.IsEnabled(context.Background(), flagName, userID.String())
+	require.NoError(t, err)
+	assert.True(t, enabled, "the override wins over a disabled flag")
+
+	enabled, err = service.IsEnabled(context.Background(), "unknown_flag", userID.String())
+	require.NoError(t, err)
+	assert.False(t, enabled, "unknown flags are off")
+}
+
+func (f *fakeFeatureFlagStore) SetUserOverride(ctx context.Context, override *models.UserFlagOverride) error {
+	f.overrides[override.FlagName+override.UserID.String()] = override
+	return nil
+}
+
+func TestFeatureFlagCache(t *testing.T) {
+	const flagName = "new_feature"
+	userID := uuid.New()
+	store := &fakeFeatureFlagStore{
+		flags:     map[string]*models.FeatureFlag{flagName: {FlagName: flagName, EnabledGlobally: true, RolloutPercentage: 100}},
+		overrides: map[string]*models.UserFlagOverride{},
+	}
+	now := time.Now()
+	service := NewFeatureFlagService(store, WithCache(cache.New[any]()))
+	service.now = func() time.Time { return now }
+
+	isEnabled := func() bool {
+		enabled, err := service.IsEnabled(context.Background(), flagName, userID.String())
+		require.NoError(t, err)
+		return enabled
+	}
+
+	assert.True(t, isEnabled())
+	assert.True(t, isEnabled())
+	assert.Equal(t, 2, store.reads, "the missing override and the flag are each loaded once")
+
+	store.flags[flagName] = &models.FeatureFlag{FlagName: flagName, EnabledGlobally: false, RolloutPercentage: 100}
+	assert.True(t, isEnabled(), "changes made behind the service apply once the TTL passes")
+	now = now.Add(featureFlagCacheTTL)
+	assert.False(t, isEnabled())
+
+	_, err := service.UpsertFlag(context.Background(), &models.FeatureFlag{FlagName: flagName, EnabledGlobally: true, RolloutPercentage: 100})
+	require.NoError(t, err)
+	assert.True(t, isEnabled(), "updating a flag invalidates it")
+
+	require.NoError(t, service.SetUserOverride(context.Background(), &models.UserFlagOverride{UserID: userID, FlagName: flagName, Enabled: false}))
+	assert.False(t, isEnabled(), "setting an override invalidates it")
+}
+
+type failingFeatureFlags struct{}
+
+func (failingFeatureFlags) IsEnabled(ctx context.Context, flagName, userID string) (bool, error) {
+	return false, errors.New("database unavailable")
+}
+
+func TestServiceConfigFlagEnabled(t *testing.T) {
+	var cfg ServiceConfig
+	assert.True(t, cfg.flagEnabled(context.Background(), FlagShadowResponseValidation, "user-1"), "features are on without flags")
+
+	cfg = newServiceConfig([]Option{WithFeatureFlags(failingFeatureFlags{})})
+	assert.False(t, cfg.flagEnabled(context.Background(), FlagShadowResponseValidation, "user-1"), "flags that cannot be evaluated are off")
+}
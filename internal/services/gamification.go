@@ -5,19 +5,57 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/metrics"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 )
 
+// streakIncrementer atomically advances daily streaks
+type streakIncrementer interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	AtomicStreakIncrement(ctx context.Context, userID, companionID string, today time.Time) (bool, error)
+}
+
+// achievementStore records awarded achievements together with the progress they grant
+type achievementStore interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertUserAchievement(ctx context.Context, achievement *models.UserAchievement) error
+	GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error)
+	UpsertUserProgress(ctx context.Context, progress *models.UserProgress) error
+	RecordExperienceGain(ctx context.Context, gain *models.ExperienceGain) error
+}
+
 type GamificationService struct {
-	analyticsRepo *repositories.AnalyticsRepository
-	convRepo      *repositories.ConversationRepository
+	analyticsRepo  *repositories.AnalyticsRepository
+	convRepo       *repositories.ConversationRepository
+	webhookService *WebhookService
+	notifications  *NotificationService
+	streaks        streakIncrementer
+	achievements   achievementStore
+	metrics        *metrics.MetricsCollector
+	criteria       *CriteriaEvaluator
+	badges         badgeStore
+	users          badgeRecipientSource
+	badgeBaseURL   string
+
+	options ServiceConfig
 }
 
-func NewGamificationService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository) *GamificationService {
+// NewGamificationService creates a new gamification service. Exported Open Badges are hosted under badgeBaseURL.
+func NewGamificationService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, webhookService *WebhookService, notifications *NotificationService, users badgeRecipientSource, badgeBaseURL string, opts ...Option) *GamificationService {
 	return &GamificationService{
-		analyticsRepo: analyticsRepo,
-		convRepo:      convRepo,
+		analyticsRepo:  analyticsRepo,
+		convRepo:       convRepo,
+		webhookService: webhookService,
+		notifications:  notifications,
+		streaks:        analyticsRepo,
+		achievements:   analyticsRepo,
+		metrics:        metrics.Default,
+		criteria:       NewCriteriaEvaluator(),
+		badges:         analyticsRepo,
+		users:          users,
+		badgeBaseURL:   badgeBaseURL,
+		options:        newServiceConfig(opts),
 	}
 }
 
@@ -163,6 +201,60 @@ func (s *GamificationService) InitializeAchievementDefinitions(ctx context.Conte
 			Active:    true,
 			CreatedAt: time.Now(),
 		},
+		{
+			ID:          "true_friend",
+			Title:       "True Friend",
+			Description: "Grow your relationship into a friendship",
+			Category:    "relationship",
+			Type:        "milestone",
+			Points:      150,
+			Rarity:      "common",
+			IconURL:     "/icons/true-friend.png",
+			Criteria: models.AchievementCriteria{
+				Type:        "relationship_stage",
+				Target:      1,
+				Conditions:  map[string]any{"stage": "friendship"},
+				Measurement: "stage",
+			},
+			Active:    true,
+			CreatedAt: time.Now(),
+		},
+		{
+			ID:          "close_companion",
+			Title:       "Close Companion",
+			Description: "Reach close companionship in your relationship",
+			Category:    "relationship",
+			Type:        "milestone",
+			Points:      250,
+			Rarity:      "rare",
+			IconURL:     "/icons/close-companion.png",
+			Criteria: models.AchievementCriteria{
+				Type:        "relationship_stage",
+				Target:      1,
+				Conditions:  map[string]any{"stage": "close_companionship"},
+				Measurement: "stage",
+			},
+			Active:    true,
+			CreatedAt: time.Now(),
+		},
+		{
+			ID:          "soulmate",
+			Title:       "Soulmate",
+			Description: "Reach an intimate partnership in your relationship",
+			Category:    "relationship",
+			Type:        "milestone",
+			Points:      400,
+			Rarity:      "legendary",
+			IconURL:     "/icons/soulmate.png",
+			Criteria: models.AchievementCriteria{
+				Type:        "relationship_stage",
+				Target:      1,
+				Conditions:  map[string]any{"stage": "intimate_partnership"},
+				Measurement: "stage",
+			},
+			Active:    true,
+			CreatedAt: time.Now(),
+		},
 
 		// Personal Growth Achievements
 		{
@@ -444,6 +536,13 @@ func (s *GamificationService) checkAchievementCriteria(ctx context.Context, defi
 		return activityData.VulnerabilityLevel >= definition.Criteria.Target
 	case "relationship_duration":
 		return activityData.RelationshipAge.Hours()/24 >= definition.Criteria.Target
+	case ScriptedCriteria:
+		met, err := s.criteria.Evaluate(ctx, definition, progress, activityData)
+		if err != nil {
+			s.options.logger().Error("Failed to evaluate scripted achievement criteria", "achievement_id", definition.ID, "error", err)
+			return false
+		}
+		return met
 	default:
 		return false
 	}
@@ -473,35 +572,78 @@ func (s *GamificationService) awardAchievement(ctx context.Context, userID, comp
 		},
 	}
 
-	// Save achievement
-	err := s.analyticsRepo.InsertUserAchievement(ctx, achievement)
-	if err != nil {
-		return fmt.Errorf("failed to insert achievement: %w", err)
-	}
+	// Save the achievement and the progress it grants atomically
+	err := s.achievements.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.achievements.InsertUserAchievement(ctx, achievement); err != nil {
+			return fmt.Errorf("failed to insert achievement: %w", err)
+		}
 
-	// Update user progress
-	progress, err := s.analyticsRepo.GetUserProgress(ctx, userID, companionID)
+		progress, err := s.achievements.GetUserProgress(ctx, userID, companionID)
+		if err != nil {
+			return fmt.Errorf("failed to get user progress: %w", err)
+		}
+
+		progress.TotalAchievements++
+		if definition.Rarity == "rare" || definition.Rarity == "epic" || definition.Rarity == "legendary" {
+			progress.RareAchievements++
+		}
+
+		// Add bonus experience points
+		progress.TotalExperience += definition.Points * 10
+		gain := &models.ExperienceGain{UserID: userID, CompanionID: companionID, Amount: definition.Points * 10, Source: models.ExperienceSourceAchievement}
+		if err := s.achievements.RecordExperienceGain(ctx, gain); err != nil {
+			return fmt.Errorf("failed to record experience gain: %w", err)
+		}
+
+		// Recalculate level
+		progress.CurrentLevel = s.calculateLevel(progress.TotalExperience)
+		progress.LevelProgress = s.calculateLevelProgress(progress.TotalExperience)
+		progress.ExperienceToNext = s.calculateExperienceToNext(progress.TotalExperience)
+
+		if err := s.achievements.UpsertUserProgress(ctx, progress); err != nil {
+			return fmt.Errorf("failed to update user progress: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get user progress: %w", err)
+		return err
 	}
 
-	progress.TotalAchievements++
-	if definition.Rarity == "rare" || definition.Rarity == "epic" || definition.Rarity == "legendary" {
-		progress.RareAchievements++
+	if s.metrics != nil {
+		s.metrics.IncAchievementUnlocked(definition.Rarity, definition.Category)
 	}
 
-	// Add bonus experience points
-	progress.TotalExperience += definition.Points * 10
-
-	// Recalculate level
-	progress.CurrentLevel = s.calculateLevel(progress.TotalExperience)
-	progress.LevelProgress = s.calculateLevelProgress(progress.TotalExperience)
-	progress.ExperienceToNext = s.calculateExperienceToNext(progress.TotalExperience)
+	// Notify webhook subscribers without blocking the award
+	if s.webhookService != nil {
+		event := models.AchievementEvent{
+			Type:        AchievementUnlockedEvent,
+			UserID:      userID,
+			CompanionID: companionID,
+			Achievement: *achievement,
+			OccurredAt:  achievement.EarnedAt,
+		}
+		go func() {
+			if err := s.webhookService.Deliver(context.WithoutCancel(ctx), event); err != nil {
+				s.options.logger().Error("Failed to deliver achievement webhook", "error", err)
+			}
+		}()
+	}
 
-	// Save updated progress
-	err = s.analyticsRepo.UpsertUserProgress(ctx, progress)
-	if err != nil {
-		return fmt.Errorf("failed to update user progress: %w", err)
+	// Let the user know through their preferred channels
+	if s.notifications != nil {
+		payload := map[string]any{
+			"companion_id":   companionID,
+			"achievement_id": achievement.AchievementID,
+			"title":          achievement.Title,
+			"description":    achievement.Description,
+			"points":         achievement.Points,
+			"rarity":         achievement.Rarity,
+		}
+		go func() {
+			if err := s.notifications.Send(context.WithoutCancel(ctx), userID, models.NotificationAchievementUnlocked, payload); err != nil {
+				s.options.logger().Error("Failed to send achievement notification", "error", err)
+			}
+		}()
 	}
 
 	return nil
@@ -616,33 +758,12 @@ func (s *GamificationService) GetStreakInformation(ctx context.Context, userID,
 
 // UpdateStreak updates user streak based on activity
 func (s *GamificationService) UpdateStreak(ctx context.Context, userID, companionID string) error {
-	progress, err := s.analyticsRepo.GetUserProgress(ctx, userID, companionID)
-	if err != nil {
-		return fmt.Errorf("failed to get user progress: %w", err)
-	}
-
-	today := time.Now().Truncate(24 * time.Hour)
-	lastActivity := progress.LastActivityDate.Truncate(24 * time.Hour)
-
-	if today.Equal(lastActivity) {
-		// Already updated today
-		return nil
-	}
-
-	if today.Sub(lastActivity) == 24*time.Hour {
-		// Consecutive day
-		progress.CurrentStreak++
-		if progress.CurrentStreak > progress.LongestStreak {
-			progress.LongestStreak = progress.CurrentStreak
+	return s.streaks.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.streaks.AtomicStreakIncrement(ctx, userID, companionID, time.Now()); err != nil {
+			return fmt.Errorf("failed to update streak: %w", err)
 		}
-	} else if today.Sub(lastActivity) > 24*time.Hour {
-		// Streak broken
-		progress.CurrentStreak = 1
-	}
-
-	progress.LastActivityDate = today
-
-	return s.analyticsRepo.UpsertUserProgress(ctx, progress)
+		return nil
+	})
 }
 
 // GetLevelRewards gets rewards for reaching a specific level
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sahmaragaev/lunaria-backend/internal/metrics"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAchievementStore keeps achievements and progress in memory and restores both when a transaction fails
+type memoryAchievementStore struct {
+	achievements []models.UserAchievement
+	progress     models.UserProgress
+	gains        []models.ExperienceGain
+	upsertErr    error
+}
+
+func (m *memoryAchievementStore) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	achievements, progress, gains := len(m.achievements), m.progress, len(m.gains)
+	if err := fn(ctx); err != nil {
+		m.achievements, m.progress, m.gains = m.achievements[:achievements], progress, m.gains[:gains]
+		return err
+	}
+	return nil
+}
+
+func (m *memoryAchievementStore) InsertUserAchievement(ctx context.Context, achievement *models.UserAchievement) error {
+	m.achievements = append(m.achievements, *achievement)
+	return nil
+}
+
+func (m *memoryAchievementStore) GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error) {
+	progress := m.progress
+	return &progress, nil
+}
+
+func (m *memoryAchievementStore) RecordExperienceGain(ctx context.Context, gain *models.ExperienceGain) error {
+	m.gains = append(m.gains, *gain)
+	return nil
+}
+
+func (m *memoryAchievementStore) UpsertUserProgress(ctx context.Context, progress *models.UserProgress) error {
+	if m.upsertErr != nil {
+		return m.upsertErr
+	}
+	m.progress = *progress
+	return nil
+}
+
+func TestAwardAchievementTransaction(t *testing.T) {
+	definition := &models.AchievementDefinition{ID: "first_conversation", Points: 50, Rarity: "rare"}
+
+	t.Run("commits the achievement with its progress", func(t *testing.T) {
+		store := &memoryAchievementStore{progress: models.UserProgress{UserID: "user", CompanionID: "companion"}}
+		service := &GamificationService{achievements: store}
+
+		require.NoError(t, service.awardAchievement(context.Background(), "user", "companion", definition, &ActivityData{}))
+
+		require.Len(t, store.achievements, 1)
+		assert.Equal(t, 1, store.progress.TotalAchievements)
+		assert.Equal(t, 1, store.progress.RareAchievements)
+		assert.Equal(t, 500, store.progress.TotalExperience)
+		require.Len(t, store.gains, 1)
+		assert.Equal(t, 500, store.gains[0].Amount)
+	})
+
+	t.Run("counts the unlock by rarity and category", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		store := &memoryAchievementStore{progress: models.UserProgress{UserID: "user", CompanionID: "companion"}}
+		service := &GamificationService{achievements: store, metrics: metrics.NewMetricsCollector(registry)}
+
+		legendary := &models.AchievementDefinition{ID: "soulmates", Points: 500, Rarity: "legendary", Category: "relationship"}
+		require.NoError(t, service.awardAchievement(context.Background(), "user", "companion", legendary, &ActivityData{}))
+
+		expected := `
+# HELP achievements_unlocked_total Achievements awarded to users by rarity and category.
+# TYPE achievements_unlocked_total counter
+achievements_unlocked_total{category="relationship",rarity="legendary"} 1
+`
+		assert.NoError(t, testutil.CollectAndCompare(registry, strings.NewReader(expected), "achievements_unlocked_total"))
+	})
+
+	t.Run("rolls back the achievement when the progress update fails", func(t *testing.T) {
+		store := &memoryAchievementStore{
+			progress:  models.UserProgress{UserID: "user", CompanionID: "companion", TotalExperience: 120},
+			upsertErr: errors.New("write conflict"),
+		}
+		service := &GamificationService{achievements: store}
+
+		err := service.awardAchievement(context.Background(), "user", "companion", definition, &ActivityData{})
+		assert.ErrorContains(t, err, "failed to update user progress")
+
+		assert.Empty(t, store.achievements)
+		assert.Empty(t, store.gains)
+		assert.Equal(t, 0, store.progress.TotalAchievements)
+		assert.Equal(t, 120, store.progress.TotalExperience)
+	})
+}
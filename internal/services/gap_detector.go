@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// DefaultReintroductionGap is how long a user must be away before the companion welcomes them back
+const DefaultReintroductionGap = 7 * 24 * time.Hour
+
+// GapDetector decides when a user has been away long enough for the companion to acknowledge it
+type GapDetector struct {
+	threshold time.Duration
+	now       func() time.Time
+}
+
+// NewGapDetector creates a gap detector. A non-positive threshold uses DefaultReintroductionGap.
+func NewGapDetector(threshold time.Duration) *GapDetector {
+	if threshold <= 0 {
+		threshold = DefaultReintroductionGap
+	}
+	return &GapDetector{threshold: threshold, now: time.Now}
+}
+
+// Gap returns how long it has been since lastMessageAt and whether that exceeds the threshold
+func (d *GapDetector) Gap(lastMessageAt time.Time) (time.Duration, bool) {
+	gap := d.now().Sub(lastMessageAt)
+	return gap, gap > d.threshold
+}
+
+// NeedsReintroduction reports whether the companion should re-introduce itself after the gap since lastMessageAt. Each
+// gap is acknowledged once: a re-introduction recorded after the last message already covers it.
+func (d *GapDetector) NeedsReintroduction(context *models.ConversationContext, lastMessageAt time.Time) bool {
+	if _, exceeded := d.Gap(lastMessageAt); !exceeded {
+		return false
+	}
+	return context.LastReintroductionAt == nil || context.LastReintroductionAt.Before(lastMessageAt)
+}
+
+// buildReintroductionLayer tells the companion to welcome the user back after a gap, recalling the most important memory
+func buildReintroductionLayer(gap time.Duration, memories []models.AIEnhancedMemoryEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `RE-INTRODUCTION:
+The user is back after %d days away. In this reply:
+- Acknowledge warmly that it has been a while, without making them feel guilty`, int(gap.Hours()/24))
+
+	if memory := mostImportantMemory(memories); memory != nil {
+		fmt.Fprintf(&b, "\n- Bring up something from your previous conversations: %s", memory.Content)
+	}
+	b.WriteString("\n- Ask an open question about what has happened in their life since you last talked")
+
+	return b.String()
+}
+
+// mostImportantMemory returns the memory with the highest importance, or nil if there are none
+func mostImportantMemory(memories []models.AIEnhancedMemoryEntry) *models.AIEnhancedMemoryEntry {
+	var best *models.AIEnhancedMemoryEntry
+	for i := range memories {
+		if best == nil || memories[i].Importance > best.Importance {
+			best = &memories[i]
+		}
+	}
+	return best
+}
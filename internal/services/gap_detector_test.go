@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestBuildDynamicPromptReintroducesAfterGap(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	buildPrompt := func(mt *mtest.T, away, threshold time.Duration) string {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "active_memories", Value: bson.A{
+					bson.D{{Key: "content", Value: "Their sister is getting married in Porto"}, {Key: "importance", Value: 0.9}},
+					bson.D{{Key: "content", Value: "They like green tea"}, {Key: "importance", Value: 0.4}},
+				}},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectIDFromTimestamp(time.Now().Add(-away))},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "created_at", Value: time.Now().Add(-away)},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.conversation_summaries", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.special_dates", mtest.FirstBatch),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewAIContextService(&mockLLM{}, repositories.NewConversationRepository(mt.DB), nil, WithReintroductionGap(threshold))
+		conversation := &models.Conversation{ID: conversationID, UserID: "user-1"}
+		userMsg := &models.Message{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "photo"}
+
+		prompt, err := service.BuildDynamicPrompt(context.Background(), conversation, userMsg, &models.CompanionProfile{})
+		require.NoError(mt, err)
+		return prompt
+	}
+
+	mt.Run("after an 8 day gap", func(mt *mtest.T) {
+		prompt := buildPrompt(mt, 8*24*time.Hour, 0)
+
+		assert.Contains(t, prompt, "RE-INTRODUCTION:\nThe user is back after 8 days away.")
+		assert.Contains(t, prompt, "- Bring up something from your previous conversations: Their sister is getting married in Porto\n")
+
+		saved := mt.GetAllStartedEvents()[4].Command.Lookup("update", "$set", "last_reintroduction_at")
+		assert.False(t, saved.IsZero(), "the re-introduction is recorded")
+	})
+
+	mt.Run("after a 3 day gap", func(mt *mtest.T) {
+		prompt := buildPrompt(mt, 3*24*time.Hour, 0)
+		assert.NotContains(t, prompt, "RE-INTRODUCTION")
+	})
+
+	mt.Run("after a 3 day gap with a configured 2 day threshold", func(mt *mtest.T) {
+		prompt := buildPrompt(mt, 3*24*time.Hour, 2*24*time.Hour)
+		assert.Contains(t, prompt, "RE-INTRODUCTION:\nThe user is back after 3 days away.")
+	})
+}
+
+func TestGapDetectorReintroducesOncePerGap(t *testing.T) {
+	now := time.Date(2026, 9, 20, 12, 0, 0, 0, time.UTC)
+	detector := NewGapDetector(0)
+	detector.now = func() time.Time { return now }
+	lastMessageAt := now.Add(-8 * 24 * time.Hour)
+
+	conversationContext := &models.ConversationContext{}
+	assert.True(t, detector.NeedsReintroduction(conversationContext, lastMessageAt))
+
+	reintroducedAt := now.Add(-time.Minute)
+	conversationContext.LastReintroductionAt = &reintroducedAt
+	assert.False(t, detector.NeedsReintroduction(conversationContext, lastMessageAt), "the gap was already acknowledged")
+
+	earlier := lastMessageAt.Add(-30 * 24 * time.Hour)
+	conversationContext.LastReintroductionAt = &earlier
+	assert.True(t, detector.NeedsReintroduction(conversationContext, lastMessageAt), "a re-introduction from an earlier gap does not count")
+}
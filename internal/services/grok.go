@@ -2,15 +2,52 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/metrics"
+	"github.com/sahmaragaev/lunaria-backend/internal/resilience"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// LLMClient is implemented by services that can complete chat messages
+type LLMClient interface {
+	SendMessage(ctx context.Context, messages []LLMMessage) (string, error)
+	SendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error)
+}
+
+// Embedder is implemented by services that can turn text into embedding vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Call types and error kinds reported to the Grok metrics
+const (
+	callTypeMain      = "main"
+	callTypeMini      = "mini"
+	callTypeEmbedding = "embedding"
+
+	errorKindTimeout       = "timeout"
+	errorKindNetwork       = "network"
+	errorKindRateLimited   = "rate_limited"
+	errorKindServer        = "server_error"
+	errorKindClient        = "client_error"
+	errorKindEmptyResponse = "empty_response"
+	errorKindCircuitOpen   = "circuit_open"
 )
 
 type GrokService struct {
-	client *resty.Client
-	config *config.GrokConfig
+	client  *resty.Client
+	config  *config.GrokConfig
+	breaker *resilience.CircuitBreaker
+	metrics *metrics.MetricsCollector
+
+	options ServiceConfig
 }
 
 type LLMMessage struct {
@@ -46,53 +83,155 @@ type GrokResponse struct {
 	} `json:"usage"`
 }
 
-func NewGrokService(cfg *config.GrokConfig) *GrokService {
+// EmbeddingRequest asks for one embedding vector per input text
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+func NewGrokService(cfg *config.GrokConfig, opts ...Option) *GrokService {
 	client := resty.New()
+	// Trace headers let Grok calls join the trace of the request that made them
+	client.SetTransport(otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithPropagators(propagation.TraceContext{})))
 	client.SetHeader("Authorization", "Bearer "+cfg.APIKey)
 	client.SetHeader("Content-Type", "application/json")
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = "https://api.x.ai/v1/chat/completions"
 	}
+	if cfg.EmbeddingURL == "" {
+		cfg.EmbeddingURL = "https://api.x.ai/v1/embeddings"
+	}
+
+	options := newServiceConfig(opts)
+	breaker := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{
+		Name:             "grok",
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		OpenTimeout:      time.Duration(cfg.BreakerOpenTimeout) * time.Second,
+		HalfOpenInterval: time.Duration(cfg.BreakerHalfOpenInterval) * time.Second,
+	}, options.logger())
 
 	return &GrokService{
-		client: client,
-		config: cfg,
+		client:  client,
+		config:  cfg,
+		breaker: breaker,
+		metrics: metrics.Default,
+		options: options,
 	}
 }
 
+// SendMessage sends messages to the main model; it returns resilience.ErrCircuitOpen while Grok is failing
 func (g *GrokService) SendMessage(ctx context.Context, messages []LLMMessage) (string, error) {
-	request := GrokRequest{
-		Model:       g.config.Model,
-		Messages:    messages,
-		MaxTokens:   g.config.MaxTokens,
-		Temperature: g.config.Temperature,
-		Stream:      false,
+	var content string
+	err := g.breaker.Execute(func() error {
+		var err error
+		content, err = g.sendMessage(ctx, messages)
+		return err
+	})
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		g.metrics.IncGrokError(errorKindCircuitOpen)
 	}
+	return content, err
+}
 
-	var response GrokResponse
+// SendMiniMessage sends messages to the mini model; it returns resilience.ErrCircuitOpen while Grok is failing
+func (g *GrokService) SendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	var content string
+	err := g.breaker.Execute(func() error {
+		var err error
+		content, err = g.sendMiniMessage(ctx, messages)
+		return err
+	})
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		g.metrics.IncGrokError(errorKindCircuitOpen)
+	}
+	return content, err
+}
 
+// Embed returns an embedding vector for each text, in input order
+func (g *GrokService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var embeddings [][]float32
+	err := g.breaker.Execute(func() error {
+		var err error
+		embeddings, err = g.embed(ctx, texts)
+		return err
+	})
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		g.metrics.IncGrokError(errorKindCircuitOpen)
+	}
+	return embeddings, err
+}
+
+func (g *GrokService) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	request := EmbeddingRequest{
+		Model: g.config.EmbeddingModel,
+		Input: texts,
+	}
+
+	var response EmbeddingResponse
+
+	start := time.Now()
 	resp, err := g.client.R().
 		SetContext(ctx).
 		SetBody(request).
 		SetResult(&response).
-		Post(g.config.BaseURL)
+		Post(g.config.EmbeddingURL)
+	g.metrics.ObserveGrokRequest(request.Model, callTypeEmbedding, time.Since(start))
 
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to Grok: %w", err)
+		g.metrics.IncGrokError(requestErrorKind(err))
+		return nil, fmt.Errorf("failed to send request to Grok embeddings: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", fmt.Errorf("Grok API returned status %d: %s", resp.StatusCode(), resp.String())
+		g.metrics.IncGrokError(statusErrorKind(resp.StatusCode()))
+		return nil, fmt.Errorf("Grok embeddings API returned status %d: %s", resp.StatusCode(), resp.String())
 	}
 
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no response from Grok")
+	g.metrics.AddGrokTokens(response.Usage.PromptTokens, 0)
+
+	if len(response.Data) != len(texts) {
+		g.metrics.IncGrokError(errorKindEmptyResponse)
+		return nil, fmt.Errorf("expected %d embeddings from Grok, got %d", len(texts), len(response.Data))
 	}
 
-	return response.Choices[0].Message.Content, nil
+	embeddings := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("Grok returned embedding for unknown input %d", item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+
+	return embeddings, nil
 }
 
-func (g *GrokService) SendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+func (g *GrokService) sendMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	request := GrokRequest{
+		Model:       g.config.Model,
+		Messages:    messages,
+		MaxTokens:   g.config.MaxTokens,
+		Temperature: g.config.Temperature,
+		Stream:      false,
+	}
+
+	return g.complete(ctx, request, callTypeMain, "Grok")
+}
+
+func (g *GrokService) sendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error) {
 	request := GrokRequest{
 		Model:       g.config.MiniModel,
 		Messages:    messages,
@@ -101,25 +240,57 @@ func (g *GrokService) SendMiniMessage(ctx context.Context, messages []LLMMessage
 		Stream:      false,
 	}
 
+	return g.complete(ctx, request, callTypeMini, "Grok Mini")
+}
+
+// complete posts a chat completion request and records its latency, token usage and errors
+func (g *GrokService) complete(ctx context.Context, request GrokRequest, callType, name string) (string, error) {
 	var response GrokResponse
 
+	start := time.Now()
 	resp, err := g.client.R().
 		SetContext(ctx).
 		SetBody(request).
 		SetResult(&response).
 		Post(g.config.BaseURL)
+	g.metrics.ObserveGrokRequest(request.Model, callType, time.Since(start))
 
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to Grok Mini: %w", err)
+		g.metrics.IncGrokError(requestErrorKind(err))
+		return "", fmt.Errorf("failed to send request to %s: %w", name, err)
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", fmt.Errorf("Grok Mini API returned status %d: %s", resp.StatusCode(), resp.String())
+		g.metrics.IncGrokError(statusErrorKind(resp.StatusCode()))
+		return "", fmt.Errorf("%s API returned status %d: %s", name, resp.StatusCode(), resp.String())
 	}
 
+	g.metrics.AddGrokTokens(response.Usage.PromptTokens, response.Usage.CompletionTokens)
+
 	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no response from Grok Mini")
+		g.metrics.IncGrokError(errorKindEmptyResponse)
+		return "", fmt.Errorf("no response from %s", name)
 	}
 
 	return response.Choices[0].Message.Content, nil
 }
+
+// requestErrorKind classifies an error raised before Grok returned a response
+func requestErrorKind(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return errorKindTimeout
+	}
+	return errorKindNetwork
+}
+
+// statusErrorKind classifies a non-200 Grok response
+func statusErrorKind(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return errorKindRateLimited
+	case status >= 500:
+		return errorKindServer
+	default:
+		return errorKindClient
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+)
+
+// ErrNoGrokBackend is returned when a MultiBackendGrokService has no backends to call
+var ErrNoGrokBackend = errors.New("no Grok backend available")
+
+// grokBackend is a single Grok endpoint; *GrokService satisfies it
+type grokBackend interface {
+	LLMClient
+	Embedder
+}
+
+// MultiBackendGrokService spreads calls over several Grok backends, sending each to the one the BackendSelector
+// picks and failing over to the next when it errors
+type MultiBackendGrokService struct {
+	names    []string
+	backends []grokBackend
+	selector *BackendSelector
+	now      func() time.Time
+
+	options ServiceConfig
+}
+
+// NewMultiBackendGrokService creates a GrokService for each backend in cfg.Backends, copying the rest of its
+// settings from cfg
+func NewMultiBackendGrokService(cfg *config.GrokConfig, opts ...Option) *MultiBackendGrokService {
+	names := make([]string, len(cfg.Backends))
+	backends := make([]grokBackend, len(cfg.Backends))
+	for i, backend := range cfg.Backends {
+		backendConfig := *cfg
+		backendConfig.Backends = nil
+		if backend.APIKey != "" {
+			backendConfig.APIKey = backend.APIKey
+		}
+		if backend.BaseURL != "" {
+			backendConfig.BaseURL = backend.BaseURL
+		}
+		if backend.EmbeddingURL != "" {
+			backendConfig.EmbeddingURL = backend.EmbeddingURL
+		}
+
+		names[i] = backend.Name
+		if names[i] == "" {
+			names[i] = fmt.Sprintf("backend-%d", i)
+		}
+		backends[i] = NewGrokService(&backendConfig, opts...)
+	}
+
+	selector := NewBackendSelector(len(backends), cfg.FailoverErrorRate, time.Duration(cfg.FailoverCooldown)*time.Second)
+	return newMultiBackendGrokService(names, backends, selector, opts...)
+}
+
+func newMultiBackendGrokService(names []string, backends []grokBackend, selector *BackendSelector, opts ...Option) *MultiBackendGrokService {
+	return &MultiBackendGrokService{
+		names:    names,
+		backends: backends,
+		selector: selector,
+		now:      time.Now,
+		options:  newServiceConfig(opts),
+	}
+}
+
+// SendMessage sends messages to the main model on the best available backend
+func (m *MultiBackendGrokService) SendMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	var content string
+	err := m.call(ctx, func(backend grokBackend) error {
+		var err error
+		content, err = backend.SendMessage(ctx, messages)
+		return err
+	})
+	return content, err
+}
+
+// SendMiniMessage sends messages to the mini model on the best available backend
+func (m *MultiBackendGrokService) SendMiniMessage(ctx context.Context, messages []LLMMessage) (string, error) {
+	var content string
+	err := m.call(ctx, func(backend grokBackend) error {
+		var err error
+		content, err = backend.SendMiniMessage(ctx, messages)
+		return err
+	})
+	return content, err
+}
+
+// Embed returns an embedding vector for each text from the best available backend
+func (m *MultiBackendGrokService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := m.call(ctx, func(backend grokBackend) error {
+		var err error
+		embeddings, err = backend.Embed(ctx, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+// call runs fn against backends in the order the selector picks them until one succeeds, every backend has been
+// tried or ctx is done
+func (m *MultiBackendGrokService) call(ctx context.Context, fn func(grokBackend) error) error {
+	tried := make(map[int]bool, len(m.backends))
+	lastErr := ErrNoGrokBackend
+	for {
+		index, ok := m.selector.Select(tried)
+		if !ok {
+			return lastErr
+		}
+		tried[index] = true
+
+		start := m.now()
+		err := fn(m.backends[index])
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the backend
+			return err
+		}
+		m.selector.Record(index, m.now().Sub(start), err != nil)
+		if err == nil {
+			return nil
+		}
+
+		m.options.logger().Warn("Grok backend failed, failing over", "backend", m.names[index], "error", err)
+		lastErr = err
+	}
+}
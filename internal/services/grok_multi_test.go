@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simulatedBackend answers after a fixed latency on a shared fake clock. It fails every call while down and starts
+// failing once failFrom calls have been made to it.
+type simulatedBackend struct {
+	clock    *time.Time
+	latency  time.Duration
+	failFrom int
+	down     bool
+	calls    int
+}
+
+func (b *simulatedBackend) respond() error {
+	b.calls++
+	*b.clock = b.clock.Add(b.latency)
+	if b.down || (b.failFrom > 0 && b.calls > b.failFrom) {
+		return errors.New("503 service unavailable")
+	}
+	return nil
+}
+
+func (b *simulatedBackend) SendMessage(context.Context, []LLMMessage) (string, error) {
+	return "ok", b.respond()
+}
+
+func (b *simulatedBackend) SendMiniMessage(context.Context, []LLMMessage) (string, error) {
+	return "ok", b.respond()
+}
+
+func (b *simulatedBackend) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, b.respond()
+}
+
+func TestMultiBackendGrokServiceShiftsTrafficFromFailingBackend(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	fast := &simulatedBackend{clock: &clock, latency: 100 * time.Millisecond, failFrom: 50}
+	medium := &simulatedBackend{clock: &clock, latency: 200 * time.Millisecond}
+	slow := &simulatedBackend{clock: &clock, latency: 400 * time.Millisecond}
+
+	selector := NewBackendSelector(3, 0.5, time.Minute)
+	selector.now = now
+	service := newMultiBackendGrokService([]string{"fast", "medium", "slow"}, []grokBackend{fast, medium, slow}, selector)
+	service.now = now
+
+	served := func() [3]int { return [3]int{fast.calls, medium.calls, slow.calls} }
+
+	for i := 0; i < 60; i++ {
+		_, err := service.SendMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+		require.NoError(t, err)
+	}
+	firstHalf := served()
+	assert.Greater(t, firstHalf[0], firstHalf[1]+firstHalf[2], "the fastest backend takes most of the traffic while healthy")
+
+	for i := 0; i < 60; i++ {
+		_, err := service.SendMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+		require.NoError(t, err, "failures are retried on another backend")
+	}
+	secondHalf := served()
+
+	assert.True(t, selector.Degraded(0))
+	assert.LessOrEqual(t, secondHalf[0]-firstHalf[0], 1, "the failed backend is skipped during its cooldown")
+	assert.Greater(t, secondHalf[1]-firstHalf[1], 55, "traffic moves to the next fastest backend")
+}
+
+func TestMultiBackendGrokServiceReturnsLastErrorWhenAllFail(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := &simulatedBackend{clock: &clock, latency: time.Millisecond, down: true}
+	second := &simulatedBackend{clock: &clock, latency: time.Millisecond, down: true}
+	backends := []grokBackend{first, second}
+
+	service := newMultiBackendGrokService([]string{"a", "b"}, backends, NewBackendSelector(2, 0.5, time.Minute))
+	_, err := service.SendMiniMessage(context.Background(), nil)
+	assert.EqualError(t, err, "503 service unavailable")
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+
+	_, err = newMultiBackendGrokService(nil, nil, NewBackendSelector(0, 0, 0)).Embed(context.Background(), []string{"x"})
+	assert.ErrorIs(t, err, ErrNoGrokBackend)
+}
+
+func TestBackendSelectorRecoversAfterCooldown(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	selector := NewBackendSelector(2, 0.5, time.Minute)
+	selector.now = func() time.Time { return clock }
+
+	selector.Record(0, 50*time.Millisecond, false)
+	selector.Record(1, 300*time.Millisecond, false)
+	for i := 0; i < 5; i++ {
+		selector.Record(0, 0, true)
+	}
+
+	index, ok := selector.Select(nil)
+	require.True(t, ok)
+	assert.Equal(t, 1, index, "a backend above the error threshold is skipped")
+
+	clock = clock.Add(5 * time.Minute)
+	assert.False(t, selector.Degraded(0))
+	index, _ = selector.Select(nil)
+	assert.Equal(t, 0, index, "the error rate decays, so the faster backend is used again")
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/metrics"
+	"github.com/sahmaragaev/lunaria-backend/internal/resilience"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrokCircuitBreakerFallsBack(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	grok := NewGrokService(&config.GrokConfig{BaseURL: server.URL, BreakerFailureThreshold: 2, BreakerOpenTimeout: 60})
+	analytics := NewAnalyticsService(grok, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := grok.SendMiniMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+		assert.Error(t, err)
+	}
+
+	_, err := grok.SendMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+	assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+
+	analysis, err := analytics.analyzeEmotionalPatterns(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &EmotionalAnalysis{Regulation: 0.5, Empathy: 0.5, MoodImpact: 0.5}, analysis)
+	assert.Equal(t, 2, hits)
+}
+
+func TestGrokMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hello"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	grok := NewGrokService(&config.GrokConfig{BaseURL: server.URL, MiniModel: "grok-mini"})
+	grok.metrics = metrics.NewMetricsCollector(registry)
+
+	content, err := grok.SendMiniMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", content)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "grok_request_duration_seconds"))
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "grok_request_duration_seconds" {
+			continue
+		}
+		histogram := family.GetMetric()[0]
+		assert.Equal(t, uint64(1), histogram.GetHistogram().GetSampleCount())
+		assert.Greater(t, histogram.GetHistogram().GetSampleSum(), 0.0)
+		labels := map[string]string{}
+		for _, label := range histogram.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{"model": "grok-mini", "call_type": "mini"}, labels)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP grok_tokens_total Tokens sent to and received from the Grok API.
+# TYPE grok_tokens_total counter
+grok_tokens_total{direction="input"} 12
+grok_tokens_total{direction="output"} 3
+`), "grok_tokens_total"))
+	assert.Equal(t, 0, testutil.CollectAndCount(registry, "grok_errors_total"))
+}
+
+func TestGrokErrorMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	grok := NewGrokService(&config.GrokConfig{BaseURL: server.URL, BreakerFailureThreshold: 1, BreakerOpenTimeout: 60})
+	grok.metrics = metrics.NewMetricsCollector(registry)
+
+	for i := 0; i < 2; i++ {
+		_, err := grok.SendMiniMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+		assert.Error(t, err)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP grok_errors_total Failed Grok API calls by kind of error.
+# TYPE grok_errors_total counter
+grok_errors_total{error_kind="circuit_open"} 1
+grok_errors_total{error_kind="rate_limited"} 1
+`), "grok_errors_total"))
+}
+
+func TestGrokEmbed(t *testing.T) {
+	var request EmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		// Results may come back out of order; Index ties each vector to its input
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}],"usage":{"prompt_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	grok := NewGrokService(&config.GrokConfig{EmbeddingURL: server.URL, EmbeddingModel: "embed-v1"})
+	grok.metrics = metrics.NewMetricsCollector(prometheus.NewRegistry())
+
+	embeddings, err := grok.Embed(context.Background(), []string{"dog", "cat"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, EmbeddingRequest{Model: "embed-v1", Input: []string{"dog", "cat"}}, request)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, embeddings)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// healthScoreSource reads a relationship's current analytics
+type healthScoreSource interface {
+	GetRelationshipAnalytics(ctx context.Context, userID, companionID string) (*models.RelationshipAnalytics, error)
+}
+
+// healthAlertStateStore remembers the score seen at the previous alert check
+type healthAlertStateStore interface {
+	GetHealthAlertState(ctx context.Context, userID, companionID string) (*models.HealthAlertState, error)
+	UpsertHealthAlertState(ctx context.Context, state *models.HealthAlertState) error
+}
+
+// notificationSender delivers a notification to a user, implemented by *NotificationService
+type notificationSender interface {
+	Send(ctx context.Context, userID, notificationType string, payload map[string]any) error
+}
+
+// HealthAlertService notifies users when the health score of one of their relationships drops below their alert
+// threshold
+type HealthAlertService struct {
+	analytics   healthScoreSource
+	states      healthAlertStateStore
+	preferences notificationPreferenceSource
+	notifier    notificationSender
+
+	options ServiceConfig
+}
+
+// NewHealthAlertService creates a new health alert service
+func NewHealthAlertService(analytics healthScoreSource, states healthAlertStateStore, preferences notificationPreferenceSource, notifier notificationSender, opts ...Option) *HealthAlertService {
+	return &HealthAlertService{
+		analytics:   analytics,
+		states:      states,
+		preferences: preferences,
+		notifier:    notifier,
+		options:     newServiceConfig(opts),
+	}
+}
+
+// CheckAndAlert compares the relationship's current health score with the user's alert threshold and sends an alert
+// if the score has just crossed below it
+func (s *HealthAlertService) CheckAndAlert(ctx context.Context, userID, companionID string) error {
+	analytics, err := s.analytics.GetRelationshipAnalytics(ctx, userID, companionID)
+	if err != nil {
+		return fmt.Errorf("failed to get relationship analytics: %w", err)
+	}
+
+	previous, err := s.states.GetHealthAlertState(ctx, userID, companionID)
+	var notFound *apperrors.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to get health alert state: %w", err)
+	}
+
+	threshold := s.alertThreshold(ctx, userID)
+	score := analytics.HealthScore
+	next := nextHealthAlertState(previous, score, threshold)
+	next.UserID, next.CompanionID = userID, companionID
+
+	alert := shouldAlert(previous, score, threshold)
+	if alert {
+		payload := map[string]any{
+			"companion_id": companionID,
+			"health_score": score,
+			"threshold":    threshold,
+		}
+		if err := s.notifier.Send(ctx, userID, models.NotificationHealthAlert, payload); err != nil {
+			return fmt.Errorf("failed to send health alert: %w", err)
+		}
+	}
+
+	return s.states.UpsertHealthAlertState(ctx, next)
+}
+
+// OnRelationshipAnalyticsUpsert checks for a health alert after a relationship's analytics are written. It has the
+// signature of repositories.RelationshipAnalyticsHook and logs rather than returns errors, so a failed alert never
+// fails the write.
+func (s *HealthAlertService) OnRelationshipAnalyticsUpsert(ctx context.Context, userID, companionID string) {
+	if err := s.CheckAndAlert(ctx, userID, companionID); err != nil {
+		s.options.logger().Error("Failed to check relationship health alert", "user_id", userID, "companion_id", companionID, "error", err)
+	}
+}
+
+// alertThreshold returns the user's alert threshold, or the default if they have not set one
+func (s *HealthAlertService) alertThreshold(ctx context.Context, userID string) float64 {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return models.DefaultHealthAlertThreshold
+	}
+	prefs, err := s.preferences.GetPreferences(ctx, id)
+	if err != nil || prefs.AlertThreshold <= 0 {
+		return models.DefaultHealthAlertThreshold
+	}
+	return prefs.AlertThreshold
+}
+
+// shouldAlert reports whether score has crossed below threshold since the previous check: the previous score was at
+// or above the threshold, the new one is below it, and no alert has been sent since the score last recovered.
+// Without a previous check there is nothing to have crossed from.
+func shouldAlert(previous *models.HealthAlertState, score, threshold float64) bool {
+	if previous == nil || score >= threshold {
+		return false
+	}
+	return previous.LastScore >= threshold && previous.LastAlertedScore == nil
+}
+
+// nextHealthAlertState is the state to store after a check that saw score, recording an alert if one is due and
+// forgetting the last alert once the score is back at or above the threshold
+func nextHealthAlertState(previous *models.HealthAlertState, score, threshold float64) *models.HealthAlertState {
+	next := &models.HealthAlertState{LastScore: score}
+	switch {
+	case shouldAlert(previous, score, threshold):
+		next.LastAlertedScore = &score
+	case previous != nil && score < threshold:
+		next.LastAlertedScore = previous.LastAlertedScore
+	}
+	return next
+}
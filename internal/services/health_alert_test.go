@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHealthScores struct {
+	score float64
+}
+
+func (f *fakeHealthScores) GetRelationshipAnalytics(ctx context.Context, userID, companionID string) (*models.RelationshipAnalytics, error) {
+	return &models.RelationshipAnalytics{UserID: userID, CompanionID: companionID, HealthScore: f.score}, nil
+}
+
+type fakeHealthAlertStates struct {
+	state *models.HealthAlertState
+}
+
+func (f *fakeHealthAlertStates) GetHealthAlertState(ctx context.Context, userID, companionID string) (*models.HealthAlertState, error) {
+	if f.state == nil {
+		return nil, apperrors.NewNotFoundError("health alert state not found", nil)
+	}
+	return f.state, nil
+}
+
+func (f *fakeHealthAlertStates) UpsertHealthAlertState(ctx context.Context, state *models.HealthAlertState) error {
+	f.state = state
+	return nil
+}
+
+func TestShouldAlert(t *testing.T) {
+	alerted := 0.3
+	tests := []struct {
+		name     string
+		previous *models.HealthAlertState
+		score    float64
+		want     bool
+	}{
+		{name: "first check below threshold", previous: nil, score: 0.2, want: false},
+		{name: "crossed below", previous: &models.HealthAlertState{LastScore: 0.6}, score: 0.4, want: true},
+		{name: "crossed from exactly the threshold", previous: &models.HealthAlertState{LastScore: 0.5}, score: 0.49, want: true},
+		{name: "still above", previous: &models.HealthAlertState{LastScore: 0.7}, score: 0.6, want: false},
+		{name: "already below", previous: &models.HealthAlertState{LastScore: 0.4}, score: 0.3, want: false},
+		{name: "already alerted", previous: &models.HealthAlertState{LastScore: 0.6, LastAlertedScore: &alerted}, score: 0.4, want: false},
+		{name: "recovered to the threshold", previous: &models.HealthAlertState{LastScore: 0.4}, score: 0.5, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, shouldAlert(tt.previous, tt.score, 0.5))
+		})
+	}
+}
+
+func TestHealthAlertServiceAlertsOncePerDrop(t *testing.T) {
+	userID := uuid.New()
+	scores := &fakeHealthScores{}
+	states := &fakeHealthAlertStates{}
+	notifier := &mockNotificationProvider{}
+	preferences := fakeNotificationPreferences{userID: {UserID: userID, InAppEnabled: true, AlertThreshold: 0.6}}
+	service := NewHealthAlertService(scores, states, preferences, notifier)
+
+	check := func(score float64) {
+		scores.score = score
+		require.NoError(t, service.CheckAndAlert(context.Background(), userID.String(), "companion-1"))
+	}
+
+	check(0.8)
+	check(0.55)
+	assert.Equal(t, []string{userID.String() + ":" + models.NotificationHealthAlert}, notifier.sent, "the user's own threshold is used")
+	require.NotNil(t, states.state.LastAlertedScore)
+	assert.Equal(t, 0.55, *states.state.LastAlertedScore)
+
+	check(0.4)
+	assert.Len(t, notifier.sent, 1, "staying below the threshold does not alert again")
+
+	check(0.7)
+	assert.Nil(t, states.state.LastAlertedScore, "recovering resets the alert")
+	check(0.5)
+	assert.Len(t, notifier.sent, 2, "a new drop alerts again")
+	assert.Equal(t, "companion-1", states.state.CompanionID)
+}
+
+func TestHealthAlertServiceDefaultThreshold(t *testing.T) {
+	scores := &fakeHealthScores{score: 0.55}
+	states := &fakeHealthAlertStates{state: &models.HealthAlertState{LastScore: 0.9}}
+	notifier := &mockNotificationProvider{}
+	service := NewHealthAlertService(scores, states, fakeNotificationPreferences{}, notifier)
+
+	require.NoError(t, service.CheckAndAlert(context.Background(), uuid.NewString(), "companion-1"))
+	assert.Empty(t, notifier.sent, "0.55 is above the default threshold of 0.5")
+}
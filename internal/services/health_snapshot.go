@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// healthSnapshotStore reads relationship analytics and persists weekly health snapshots
+type healthSnapshotStore interface {
+	ListRelationshipAnalyticsUpdatedSince(ctx context.Context, since time.Time) ([]models.RelationshipAnalytics, error)
+	InsertHealthSnapshot(ctx context.Context, snapshot *models.RelationshipHealthSnapshot) error
+}
+
+// HealthSnapshotJob records each active relationship's health score once a week so it can be charted over time
+type HealthSnapshotJob struct {
+	store healthSnapshotStore
+
+	options ServiceConfig
+}
+
+// NewHealthSnapshotJob creates a new weekly health snapshot job
+func NewHealthSnapshotJob(store healthSnapshotStore, opts ...Option) *HealthSnapshotJob {
+	return &HealthSnapshotJob{
+		store:   store,
+		options: newServiceConfig(opts),
+	}
+}
+
+// Start snapshots the week that ended at each Sunday midnight until ctx is cancelled
+func (j *HealthSnapshotJob) Start(ctx context.Context) {
+	for {
+		nextRun := nextSundayMidnight(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(nextRun)):
+		}
+
+		if err := j.RunForWeek(ctx, nextRun.AddDate(0, 0, -7)); err != nil {
+			j.options.logger().Error("Health snapshot job failed", "error", err)
+		}
+	}
+}
+
+// RunForWeek snapshots every relationship updated in the week starting at weekStart. Running it again
+// for the same week leaves the existing snapshots untouched.
+func (j *HealthSnapshotJob) RunForWeek(ctx context.Context, weekStart time.Time) error {
+	relationships, err := j.store.ListRelationshipAnalyticsUpdatedSince(ctx, weekStart)
+	if err != nil {
+		return fmt.Errorf("failed to list relationship analytics: %w", err)
+	}
+
+	now := time.Now()
+	for _, relationship := range relationships {
+		snapshot := &models.RelationshipHealthSnapshot{
+			UserID:        relationship.UserID,
+			CompanionID:   relationship.CompanionID,
+			HealthScore:   relationship.HealthScore,
+			TrustLevel:    relationship.TrustLevel,
+			IntimacyLevel: relationship.IntimacyLevel,
+			Stage:         relationship.CurrentStage,
+			WeekStart:     weekStart,
+			CreatedAt:     now,
+		}
+		if err := j.store.InsertHealthSnapshot(ctx, snapshot); err != nil {
+			return fmt.Errorf("failed to store health snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// nextSundayMidnight returns the first Sunday midnight strictly after t, in t's location
+func nextSundayMidnight(t time.Time) time.Time {
+	days := (7 - int(t.Weekday())) % 7
+	if days == 0 {
+		days = 7
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHealthSnapshotStore keeps one snapshot per relationship and week, like the unique index in MongoDB
+type fakeHealthSnapshotStore struct {
+	relationships []models.RelationshipAnalytics
+	snapshots     map[string]models.RelationshipHealthSnapshot
+	since         time.Time
+}
+
+func (f *fakeHealthSnapshotStore) ListRelationshipAnalyticsUpdatedSince(ctx context.Context, since time.Time) ([]models.RelationshipAnalytics, error) {
+	f.since = since
+	return f.relationships, nil
+}
+
+func (f *fakeHealthSnapshotStore) InsertHealthSnapshot(ctx context.Context, snapshot *models.RelationshipHealthSnapshot) error {
+	key := snapshot.UserID + "/" + snapshot.CompanionID + "/" + snapshot.WeekStart.String()
+	if _, exists := f.snapshots[key]; !exists {
+		f.snapshots[key] = *snapshot
+	}
+	return nil
+}
+
+func TestHealthSnapshotJobRunForWeekIsIdempotent(t *testing.T) {
+	store := &fakeHealthSnapshotStore{
+		relationships: []models.RelationshipAnalytics{
+			{UserID: "user-1", CompanionID: "companion-1", HealthScore: 0.82, TrustLevel: 0.7, IntimacyLevel: 0.6, CurrentStage: "close_friend"},
+		},
+		snapshots: map[string]models.RelationshipHealthSnapshot{},
+	}
+	job := NewHealthSnapshotJob(store)
+	weekStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, job.RunForWeek(context.Background(), weekStart))
+	store.relationships[0].HealthScore = 0.4
+	require.NoError(t, job.RunForWeek(context.Background(), weekStart))
+
+	require.Len(t, store.snapshots, 1)
+	for _, snapshot := range store.snapshots {
+		assert.Equal(t, "user-1", snapshot.UserID)
+		assert.Equal(t, "companion-1", snapshot.CompanionID)
+		assert.Equal(t, 0.82, snapshot.HealthScore)
+		assert.Equal(t, 0.7, snapshot.TrustLevel)
+		assert.Equal(t, 0.6, snapshot.IntimacyLevel)
+		assert.Equal(t, "close_friend", snapshot.Stage)
+		assert.Equal(t, weekStart, snapshot.WeekStart)
+	}
+	assert.Equal(t, weekStart, store.since)
+
+	require.NoError(t, job.RunForWeek(context.Background(), weekStart.AddDate(0, 0, 7)))
+	assert.Len(t, store.snapshots, 2)
+}
+
+func TestNextSundayMidnight(t *testing.T) {
+	wednesday := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), nextSundayMidnight(wednesday))
+
+	sundayMidnight := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), nextSundayMidnight(sundayMidnight))
+
+	saturdayNight := time.Date(2026, 3, 7, 23, 59, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), nextSundayMidnight(saturdayNight))
+}
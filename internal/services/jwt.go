@@ -14,20 +14,23 @@ import (
 type JWTService struct {
 	config *config.JWTConfig
 	redis  *RedisService
+
+	options ServiceConfig
 }
 
 type Claims struct {
 	UserID uuid.UUID      `json:"user_id"`
 	Email  string         `json:"email"`
 	Type   tokentype.Type `json:"type"`
+	Admin  bool           `json:"admin,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewJWTService(cfg *config.JWTConfig, redis *RedisService) *JWTService {
-	return &JWTService{config: cfg, redis: redis}
+func NewJWTService(cfg *config.JWTConfig, redis *RedisService, opts ...Option) *JWTService {
+	return &JWTService{config: cfg, redis: redis, options: newServiceConfig(opts)}
 }
 
-func (j *JWTService) GenerateAccessToken(userID uuid.UUID, email string) (string, error) {
+func (j *JWTService) GenerateAccessToken(userID uuid.UUID, email string, admin bool) (string, error) {
 	expiryDuration, err := time.ParseDuration(j.config.AccessExpiry)
 	if err != nil {
 		return "", err
@@ -36,6 +39,7 @@ func (j *JWTService) GenerateAccessToken(userID uuid.UUID, email string) (string
 		UserID: userID,
 		Email:  email,
 		Type:   tokentype.Access,
+		Admin:  admin,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiryDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// languageCorpus holds ten short chat messages for each language in the default vocabulary
+var languageCorpus = []struct {
+	lang string
+	text string
+}{
+	{"en", "How was your day?"},
+	{"en", "I miss you so much"},
+	{"en", "That movie was really boring"},
+	{"en", "Can we talk later tonight?"},
+	{"en", "I feel a bit lonely today"},
+	{"en", "Thanks for listening to me"},
+	{"en", "What are you doing right now?"},
+	{"en", "My cat knocked over my coffee"},
+	{"en", "Good morning, sleepyhead"},
+	{"en", "I got the job!"},
+
+	{"es", "¿Cómo estás hoy?"},
+	{"es", "Te echo mucho de menos"},
+	{"es", "Estoy muy cansado del trabajo"},
+	{"es", "¿Qué quieres hacer mañana?"},
+	{"es", "Me encanta hablar contigo"},
+	{"es", "Hoy llueve mucho en la ciudad"},
+	{"es", "Gracias por escucharme"},
+	{"es", "Tengo hambre, vamos a comer"},
+	{"es", "Buenas noches, que duermas bien"},
+	{"es", "Mi perro está enfermo"},
+
+	{"fr", "Comment ça va aujourd'hui ?"},
+	{"fr", "Tu me manques beaucoup"},
+	{"fr", "Je suis très fatigué ce soir"},
+	{"fr", "On se parle demain matin ?"},
+	{"fr", "J'adore discuter avec toi"},
+	{"fr", "Il pleut encore à Paris"},
+	{"fr", "Merci de m'avoir écouté"},
+	{"fr", "Qu'est-ce que tu fais maintenant ?"},
+	{"fr", "Bonne nuit et fais de beaux rêves"},
+	{"fr", "Mon chat dort sur mon clavier"},
+
+	{"de", "Wie geht es dir heute?"},
+	{"de", "Ich vermisse dich sehr"},
+	{"de", "Ich bin heute wirklich müde"},
+	{"de", "Was machst du gerade?"},
+	{"de", "Danke, dass du mir zuhörst"},
+	{"de", "Das Wetter ist schrecklich"},
+	{"de", "Wollen wir morgen telefonieren?"},
+	{"de", "Ich habe endlich den Job bekommen"},
+	{"de", "Gute Nacht und schlaf gut"},
+	{"de", "Mein Hund hat meinen Schuh gefressen"},
+
+	{"it", "Come stai oggi?"},
+	{"it", "Mi manchi tantissimo"},
+	{"it", "Sono molto stanco stasera"},
+	{"it", "Cosa fai di bello?"},
+	{"it", "Grazie per avermi ascoltato"},
+	{"it", "Oggi piove tutto il giorno"},
+	{"it", "Ci sentiamo domani mattina?"},
+	{"it", "Ho fame, andiamo a mangiare"},
+	{"it", "Buonanotte e sogni d'oro"},
+	{"it", "Il mio gatto dorme sempre"},
+
+	{"pt", "Como foi o seu dia?"},
+	{"pt", "Estou com muita saudade"},
+	{"pt", "Hoje estou muito cansado"},
+	{"pt", "O que você está fazendo agora?"},
+	{"pt", "Obrigado por me ouvir"},
+	{"pt", "Está chovendo muito aqui"},
+	{"pt", "Vamos conversar amanhã?"},
+	{"pt", "Consegui o emprego!"},
+	{"pt", "Boa noite, durma bem"},
+	{"pt", "Meu cachorro comeu meu sapato"},
+
+	{"ru", "Как прошёл твой день?"},
+	{"ru", "Я очень по тебе скучаю"},
+	{"ru", "Сегодня я очень устал"},
+	{"ru", "Что ты сейчас делаешь?"},
+	{"ru", "Спасибо, что выслушал"},
+	{"ru", "На улице идёт снег"},
+	{"ru", "Давай поговорим завтра"},
+	{"ru", "Я наконец получил работу"},
+	{"ru", "Спокойной ночи"},
+	{"ru", "Моя кошка спит на клавиатуре"},
+
+	{"zh", "你今天过得怎么样？"},
+	{"zh", "我很想你"},
+	{"zh", "今天工作好累"},
+	{"zh", "你现在在做什么？"},
+	{"zh", "谢谢你听我说话"},
+	{"zh", "外面下雨了"},
+	{"zh", "我们明天再聊吧"},
+	{"zh", "我终于找到工作了"},
+	{"zh", "晚安，做个好梦"},
+	{"zh", "我的猫在睡觉"},
+
+	{"ja", "今日はどうだった？"},
+	{"ja", "とても会いたいです"},
+	{"ja", "今日はすごく疲れた"},
+	{"ja", "今何してるの？"},
+	{"ja", "話を聞いてくれてありがとう"},
+	{"ja", "外は雨が降っています"},
+	{"ja", "また明日話そうね"},
+	{"ja", "やっと仕事が決まった"},
+	{"ja", "おやすみなさい"},
+	{"ja", "猫が寝ています"},
+
+	{"ko", "오늘 하루 어땠어?"},
+	{"ko", "너무 보고 싶어"},
+	{"ko", "오늘 정말 피곤해"},
+	{"ko", "지금 뭐 하고 있어?"},
+	{"ko", "내 얘기 들어줘서 고마워"},
+	{"ko", "밖에 비가 와"},
+	{"ko", "내일 다시 얘기하자"},
+	{"ko", "드디어 취직했어"},
+	{"ko", "잘 자, 좋은 꿈 꿔"},
+	{"ko", "우리 고양이가 자고 있어"},
+}
+
+func corpusLanguages() []string {
+	var codes []string
+	for _, lang := range config.DefaultEmotionVocabulary().Languages {
+		codes = append(codes, lang.Code)
+	}
+	return codes
+}
+
+func detectionAccuracy(detect func(text string) string) float64 {
+	correct := 0
+	for _, sample := range languageCorpus {
+		if detect(sample.text) == sample.lang {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(languageCorpus))
+}
+
+func TestLanguageDetectorAccuracy(t *testing.T) {
+	require.Len(t, languageCorpus, 100)
+
+	detector, err := analytics.NewLanguageDetector(corpusLanguages(), 0)
+	require.NoError(t, err)
+	heuristic := NewAnalyticsService(nil, nil, nil)
+
+	accuracy := detectionAccuracy(detector.Detect)
+	heuristicAccuracy := detectionAccuracy(func(text string) string { return heuristic.detectLanguageHeuristic(strings.ToLower(text)) })
+	t.Logf("lingua accuracy %.2f, heuristic accuracy %.2f", accuracy, heuristicAccuracy)
+
+	assert.GreaterOrEqual(t, accuracy, 0.9)
+	assert.Greater(t, accuracy, heuristicAccuracy)
+}
+
+func BenchmarkLanguageDetection(b *testing.B) {
+	detector, err := analytics.NewLanguageDetector(corpusLanguages(), 0)
+	require.NoError(b, err)
+	heuristic := NewAnalyticsService(nil, nil, nil)
+	// Load lingua's language models before timing
+	detector.Detect(languageCorpus[0].text)
+
+	for _, bm := range []struct {
+		name   string
+		detect func(text string) string
+	}{
+		{name: "Heuristic", detect: func(text string) string { return heuristic.detectLanguageHeuristic(strings.ToLower(text)) }},
+		{name: "Lingua", detect: detector.Detect},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sample := languageCorpus[i%len(languageCorpus)]
+				bm.detect(sample.text)
+			}
+			b.ReportMetric(detectionAccuracy(bm.detect)*100, "%accurate")
+		})
+	}
+}
+
+func TestCalculateSimpleSentimentUsesLanguageDetector(t *testing.T) {
+	detector, err := analytics.NewLanguageDetector(corpusLanguages(), 0)
+	require.NoError(t, err)
+	service := NewAnalyticsService(nil, nil, nil, WithLanguageDetector(detector))
+
+	// No Spanish marker word is surrounded by spaces, so the heuristic reads this as English
+	text := "Estoy feliz"
+	assert.Equal(t, "en", service.detectLanguageHeuristic(strings.ToLower(text)))
+	assert.Equal(t, "es", service.detectLanguage(text))
+	assert.Equal(t, "positive", service.calculateSimpleSentiment(text).Dominant, "scored with the Spanish keywords")
+}
@@ -26,15 +26,18 @@ type MediaService struct {
 	repo      *repositories.ConversationRepository
 	analytics *repositories.AnalyticsRepository
 	endpoint  string
+
+	options ServiceConfig
 }
 
-func NewMediaServiceWithClient(s3Client *s3.Client, bucket string, repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository, endpoint string) *MediaService {
+func NewMediaServiceWithClient(s3Client *s3.Client, bucket string, repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository, endpoint string, opts ...Option) *MediaService {
 	return &MediaService{
 		s3Client:  s3Client,
 		bucket:    bucket,
 		repo:      repo,
 		analytics: analytics,
 		endpoint:  endpoint,
+		options:   newServiceConfig(opts),
 	}
 }
 
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxConflictCheckMemories caps how many factual memories, most important first, are checked for conflicts in one
+// prompt
+const maxConflictCheckMemories = 100
+
+// conflictLoserImportance is the importance a memory drops to when it loses a conflict. It is the lowest importance a
+// memory keeps before decay deletes it, so the loser leaves the prompt at once and is cleaned up once stale.
+const conflictLoserImportance = models.MinMemoryImportance
+
+// memoryConflictStore loads and demotes conversation memories, implemented by *repositories.ConversationRepository
+type memoryConflictStore interface {
+	GetMemoriesByType(ctx context.Context, conversationID primitive.ObjectID, memoryType string, limit int) ([]models.AIEnhancedMemoryEntry, error)
+	DemoteMemory(ctx context.Context, memoryID primitive.ObjectID, importance float64, resolution string) error
+}
+
+// MemoryConflict is a pair of factual memories that contradict each other
+type MemoryConflict struct {
+	FirstMemoryID  primitive.ObjectID `json:"first_memory_id"`
+	SecondMemoryID primitive.ObjectID `json:"second_memory_id"`
+	ConflictReason string             `json:"conflict_reason"`
+}
+
+// DetectMemoryConflicts asks the LLM which of a conversation's factual memories contradict each other, such as "loves
+// hiking" and "hates outdoor activities". Memories already demoted by ResolveConflict are not checked again.
+func (s *AIContextService) DetectMemoryConflicts(ctx context.Context, conversationID primitive.ObjectID) ([]MemoryConflict, error) {
+	ctx, span := s.options.tracer().Start(ctx, "AIContextService.DetectMemoryConflicts")
+	defer span.End()
+
+	stored, err := s.memoryConflicts.GetMemoriesByType(ctx, conversationID, "factual", maxConflictCheckMemories)
+	if err != nil {
+		return nil, err
+	}
+
+	var memories []models.AIEnhancedMemoryEntry
+	for _, memory := range stored {
+		if memory.ConflictResolution == "" {
+			memories = append(memories, memory)
+		}
+	}
+	if len(memories) < 2 {
+		return nil, nil
+	}
+
+	response, err := s.grokService.SendMiniMessage(ctx, []LLMMessage{
+		{Role: "system", Content: "You are a memory consistency checker. Respond only with valid JSON."},
+		{Role: "user", Content: buildMemoryConflictPrompt(memories)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect memory conflicts: %w", err)
+	}
+
+	return parseMemoryConflicts(response, memories)
+}
+
+// ResolveConflict settles a conflict in favour of winnerID by dropping the importance of loserID to 0.1, which keeps
+// the losing memory out of the factual context given to the LLM
+func (s *AIContextService) ResolveConflict(ctx context.Context, winnerID, loserID primitive.ObjectID) error {
+	if winnerID == loserID {
+		return apperrors.NewValidationError("a memory cannot conflict with itself", nil)
+	}
+
+	resolution := "contradicted by memory " + winnerID.Hex()
+	return s.memoryConflicts.DemoteMemory(ctx, loserID, conflictLoserImportance, resolution)
+}
+
+// buildMemoryConflictPrompt numbers the memories from 1 so the LLM can refer to them compactly
+func buildMemoryConflictPrompt(memories []models.AIEnhancedMemoryEntry) string {
+	var b strings.Builder
+	b.WriteString("These are facts a companion remembers about the user, learned across several conversations:\n\n")
+	for i, memory := range memories {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, memory.Content)
+	}
+	b.WriteString(`
+Find every pair of facts that cannot both be true. Facts about different things, or that only add detail, do not
+conflict. Respond with a JSON array, empty if nothing conflicts:
+[{"first": 1, "second": 2, "reason": "why they contradict"}]`)
+	return b.String()
+}
+
+// parseMemoryConflicts maps the LLM's numbered pairs back to memory IDs, dropping pairs that do not name two
+// different listed memories and repeats of the same pair
+func parseMemoryConflicts(response string, memories []models.AIEnhancedMemoryEntry) ([]MemoryConflict, error) {
+	response = strings.TrimSpace(response)
+	if strings.HasPrefix(response, "```json") {
+		response = strings.TrimPrefix(response, "```json")
+		response = strings.TrimSuffix(response, "```")
+	}
+
+	var raw []struct {
+		First  int    `json:"first"`
+		Second int    `json:"second"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse memory conflicts: %w", err)
+	}
+
+	seen := make(map[[2]int]bool)
+	var conflicts []MemoryConflict
+	for _, pair := range raw {
+		first, second := min(pair.First, pair.Second), max(pair.First, pair.Second)
+		if first < 1 || second > len(memories) || first == second || seen[[2]int{first, second}] {
+			continue
+		}
+		seen[[2]int{first, second}] = true
+		conflicts = append(conflicts, MemoryConflict{
+			FirstMemoryID:  memories[first-1].ID,
+			SecondMemoryID: memories[second-1].ID,
+			ConflictReason: strings.TrimSpace(pair.Reason),
+		})
+	}
+	return conflicts, nil
+}
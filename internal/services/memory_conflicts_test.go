@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeMemoryConflictStore struct {
+	memories []models.AIEnhancedMemoryEntry
+}
+
+func (f *fakeMemoryConflictStore) GetMemoriesByType(_ context.Context, conversationID primitive.ObjectID, memoryType string, limit int) ([]models.AIEnhancedMemoryEntry, error) {
+	var memories []models.AIEnhancedMemoryEntry
+	for _, memory := range f.memories {
+		if memory.ConversationID == conversationID && memory.Type == memoryType && len(memories) < limit {
+			memories = append(memories, memory)
+		}
+	}
+	return memories, nil
+}
+
+func (f *fakeMemoryConflictStore) DemoteMemory(_ context.Context, memoryID primitive.ObjectID, importance float64, resolution string) error {
+	for i := range f.memories {
+		if f.memories[i].ID == memoryID {
+			f.memories[i].Importance = importance
+			f.memories[i].ConflictResolution = resolution
+			return nil
+		}
+	}
+	return apperrors.NewNotFoundError("memory not found", nil)
+}
+
+func TestMemoryConflicts(t *testing.T) {
+	ctx := context.Background()
+	conversationID := primitive.NewObjectID()
+	hiking := models.AIEnhancedMemoryEntry{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "factual", Content: "User loves hiking", Importance: 0.8}
+	outdoors := models.AIEnhancedMemoryEntry{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "factual", Content: "User hates outdoor activities", Importance: 0.7}
+	pet := models.AIEnhancedMemoryEntry{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "factual", Content: "User has a cat named Miso", Importance: 0.7}
+	store := &fakeMemoryConflictStore{memories: []models.AIEnhancedMemoryEntry{hiking, outdoors, pet}}
+
+	llm := &mockLLM{response: "```json\n" + `[
+		{"first": 2, "second": 1, "reason": "Hiking is an outdoor activity"},
+		{"first": 1, "second": 2, "reason": "duplicate"},
+		{"first": 3, "second": 7, "reason": "not a listed memory"}
+	]` + "\n```"}
+	service := NewAIContextService(llm, nil, nil)
+	service.memoryConflicts = store
+
+	conflicts, err := service.DetectMemoryConflicts(ctx, conversationID)
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, MemoryConflict{FirstMemoryID: hiking.ID, SecondMemoryID: outdoors.ID, ConflictReason: "Hiking is an outdoor activity"}, conflicts[0])
+
+	require.Len(t, llm.prompts, 1)
+	prompt := llm.prompts[0][1].Content
+	assert.Contains(t, prompt, "1. User loves hiking")
+	assert.Contains(t, prompt, "2. User hates outdoor activities")
+
+	require.NoError(t, service.ResolveConflict(ctx, outdoors.ID, hiking.ID))
+	assert.Equal(t, 0.1, store.memories[0].Importance)
+	assert.Contains(t, store.memories[0].ConflictResolution, outdoors.ID.Hex())
+	assert.Equal(t, 0.7, store.memories[1].Importance, "the winner is untouched")
+
+	// Only two unresolved memories remain, and the LLM now finds no conflicts
+	llm.response = "[]"
+	conflicts, err = service.DetectMemoryConflicts(ctx, conversationID)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.NotContains(t, llm.prompts[1][1].Content, "hiking", "resolved memories are not checked again")
+
+	var validationErr *apperrors.ValidationError
+	assert.ErrorAs(t, service.ResolveConflict(ctx, hiking.ID, hiking.ID), &validationErr)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultMemoryDecayFactor is what a stale memory's importance is multiplied by each day when no factor is configured
+const DefaultMemoryDecayFactor = 0.9
+
+// MemoryStaleAfter is how long a memory can go unreferenced before its importance starts to decay
+const MemoryStaleAfter = 30 * 24 * time.Hour
+
+// memoryDecayStore reads and updates memory importance, implemented by *repositories.ConversationRepository
+type memoryDecayStore interface {
+	ListStaleMemories(ctx context.Context, referencedBefore time.Time) (map[primitive.ObjectID]float64, error)
+	BulkUpdateMemoryImportance(ctx context.Context, updates map[primitive.ObjectID]float64) error
+	SoftDeleteMemories(ctx context.Context, memoryIDs []primitive.ObjectID) error
+}
+
+// MemoryDecayJob lowers the importance of memories that have not been referenced recently, so companions gradually
+// forget details that never come up again. Memories that decay below models.MinMemoryImportance are soft-deleted.
+type MemoryDecayJob struct {
+	store       memoryDecayStore
+	decayFactor float64
+	interval    time.Duration
+	now         func() time.Time
+
+	options ServiceConfig
+}
+
+// NewMemoryDecayJob creates a new memory decay job. A factor outside (0, 1) uses DefaultMemoryDecayFactor.
+func NewMemoryDecayJob(store memoryDecayStore, decayFactor float64, opts ...Option) *MemoryDecayJob {
+	if decayFactor <= 0 || decayFactor >= 1 {
+		decayFactor = DefaultMemoryDecayFactor
+	}
+
+	return &MemoryDecayJob{
+		store:       store,
+		decayFactor: decayFactor,
+		interval:    24 * time.Hour,
+		now:         time.Now,
+		options:     newServiceConfig(opts),
+	}
+}
+
+// Start decays stale memories immediately and then once a day until ctx is cancelled
+func (j *MemoryDecayJob) Start(ctx context.Context) {
+	for {
+		if _, _, err := j.DecayStaleMemories(ctx); err != nil {
+			j.options.logger().Error("Memory decay job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.interval):
+		}
+	}
+}
+
+// DecayStaleMemories multiplies the importance of every memory not referenced within MemoryStaleAfter by the decay
+// factor and soft-deletes those that fall below models.MinMemoryImportance. It returns how many memories were decayed
+// and how many were deleted.
+func (j *MemoryDecayJob) DecayStaleMemories(ctx context.Context) (decayed, deleted int, err error) {
+	stale, err := j.store.ListStaleMemories(ctx, j.now().Add(-MemoryStaleAfter))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list stale memories: %w", err)
+	}
+
+	updates := make(map[primitive.ObjectID]float64, len(stale))
+	var forgotten []primitive.ObjectID
+	for id, importance := range stale {
+		importance *= j.decayFactor
+		if importance < models.MinMemoryImportance {
+			forgotten = append(forgotten, id)
+			continue
+		}
+		updates[id] = importance
+	}
+
+	if err := j.store.BulkUpdateMemoryImportance(ctx, updates); err != nil {
+		return 0, 0, err
+	}
+	if err := j.store.SoftDeleteMemories(ctx, forgotten); err != nil {
+		return len(updates), 0, err
+	}
+
+	return len(updates), len(forgotten), nil
+}
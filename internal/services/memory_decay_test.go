@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeMemoryDecayStore struct {
+	memories map[primitive.ObjectID]*models.AIEnhancedMemoryEntry
+}
+
+func (f *fakeMemoryDecayStore) ListStaleMemories(_ context.Context, referencedBefore time.Time) (map[primitive.ObjectID]float64, error) {
+	stale := make(map[primitive.ObjectID]float64)
+	for id, memory := range f.memories {
+		if memory.DeletedAt == nil && memory.LastReferenced.Before(referencedBefore) {
+			stale[id] = memory.Importance
+		}
+	}
+	return stale, nil
+}
+
+func (f *fakeMemoryDecayStore) BulkUpdateMemoryImportance(_ context.Context, updates map[primitive.ObjectID]float64) error {
+	for id, importance := range updates {
+		f.memories[id].Importance = importance
+	}
+	return nil
+}
+
+func (f *fakeMemoryDecayStore) SoftDeleteMemories(_ context.Context, memoryIDs []primitive.ObjectID) error {
+	now := time.Now()
+	for _, id := range memoryIDs {
+		f.memories[id].DeletedAt = &now
+	}
+	return nil
+}
+
+func TestMemoryDecayJobSoftDeletesAfter22Days(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	staleID, recentID := primitive.NewObjectID(), primitive.NewObjectID()
+	store := &fakeMemoryDecayStore{memories: map[primitive.ObjectID]*models.AIEnhancedMemoryEntry{
+		staleID:  {ID: staleID, Importance: 1, LastReferenced: now.Add(-31 * 24 * time.Hour)},
+		recentID: {ID: recentID, Importance: 1, LastReferenced: now.Add(-24 * time.Hour)},
+	}}
+	job := NewMemoryDecayJob(store, 0)
+	job.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 0.9^21 ≈ 0.109
+	for i := 0; i < 21; i++ {
+		decayed, deleted, err := job.DecayStaleMemories(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, decayed)
+		assert.Zero(t, deleted)
+	}
+	assert.GreaterOrEqual(t, store.memories[staleID].Importance, models.MinMemoryImportance)
+	assert.Nil(t, store.memories[staleID].DeletedAt)
+
+	// 0.9^22 ≈ 0.098
+	decayed, deleted, err := job.DecayStaleMemories(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, decayed)
+	assert.Equal(t, 1, deleted)
+	assert.NotNil(t, store.memories[staleID].DeletedAt)
+
+	decayed, deleted, err = job.DecayStaleMemories(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, decayed+deleted, "deleted memories no longer decay")
+
+	assert.Equal(t, 1.0, store.memories[recentID].Importance, "recently referenced memories do not decay")
+	assert.Nil(t, store.memories[recentID].DeletedAt)
+}
+
+func TestNewMemoryDecayJobFactor(t *testing.T) {
+	assert.Equal(t, DefaultMemoryDecayFactor, NewMemoryDecayJob(nil, 0).decayFactor)
+	assert.Equal(t, DefaultMemoryDecayFactor, NewMemoryDecayJob(nil, 1.5).decayFactor)
+	assert.Equal(t, 0.5, NewMemoryDecayJob(nil, 0.5).decayFactor)
+}
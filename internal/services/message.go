@@ -2,12 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/llm"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -16,33 +22,75 @@ import (
 type MessageService struct {
 	repo                     *repositories.ConversationRepository
 	analytics                *repositories.AnalyticsRepository
-	grok                     *GrokService
+	grok                     LLMClient
 	aiContext                *AIContextService
 	responseQuality          *ResponseQualityService
 	conversationIntelligence *ConversationIntelligenceService
+	tokenCounter             *llm.TokenCounter
+	safetyChecker            responseSafetyChecker
+	safetyEscalator          *SafetyEscalator
+	archive                  *ConversationArchiveService
+
+	options ServiceConfig
+}
+
+// MessageOption sets an optional dependency on a MessageService. Every Option is a MessageOption.
+type MessageOption interface {
+	applyMessage(s *MessageService)
+}
+
+// messageOption adapts a function to MessageOption
+type messageOption func(s *MessageService)
+
+func (o messageOption) applyMessage(s *MessageService) { o(s) }
+
+func (o Option) applyMessage(s *MessageService) { o(&s.options) }
+
+// WithSafetyEscalator routes crisis-level companion responses to the escalator for review
+func WithSafetyEscalator(escalator *SafetyEscalator) MessageOption {
+	return messageOption(func(s *MessageService) {
+		s.safetyEscalator = escalator
+	})
+}
+
+// WithArchive reads messages through the archive, so archived conversations still list in full
+func WithArchive(archive *ConversationArchiveService) MessageOption {
+	return messageOption(func(s *MessageService) {
+		s.archive = archive
+	})
 }
 
-func NewMessageService(repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository, grok *GrokService, aiContext *AIContextService, responseQuality *ResponseQualityService, conversationIntelligence *ConversationIntelligenceService) *MessageService {
-	return &MessageService{
+func NewMessageService(repo *repositories.ConversationRepository, analytics *repositories.AnalyticsRepository, grok LLMClient, aiContext *AIContextService, responseQuality *ResponseQualityService, conversationIntelligence *ConversationIntelligenceService, opts ...MessageOption) *MessageService {
+	s := &MessageService{
 		repo:                     repo,
 		analytics:                analytics,
 		grok:                     grok,
 		aiContext:                aiContext,
 		responseQuality:          responseQuality,
 		conversationIntelligence: conversationIntelligence,
+		tokenCounter:             llm.NewTokenCounter(),
+		safetyChecker:            responseQuality,
+	}
+	for _, opt := range opts {
+		opt.applyMessage(s)
 	}
+	return s
 }
 
-func (s *MessageService) SendMessage(ctx context.Context, msg *models.Message) (*models.Message, error) {
+// SendMessage stores a user message. deduplicated is true when the message is a retry of one already stored.
+func (s *MessageService) SendMessage(ctx context.Context, msg *models.Message) (storedMsg *models.Message, deduplicated bool, err error) {
 	if err := s.validateMessage(msg); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	msg.CreatedAt = time.Now()
 	msg.UpdatedAt = time.Now()
-	storedMsg, err := s.repo.CreateMessage(ctx, msg)
+	storedMsg, deduplicated, err = s.repo.CreateMessage(ctx, msg)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if deduplicated {
+		return storedMsg, true, nil
 	}
 
 	analytics := &models.MessageAnalytics{
@@ -52,9 +100,16 @@ func (s *MessageService) SendMessage(ctx context.Context, msg *models.Message) (
 		Type:           string(msg.Type),
 		CreatedAt:      msg.CreatedAt,
 	}
+	if conversation, err := s.repo.GetConversationByID(ctx, msg.ConversationID); err == nil {
+		analytics.CompanionID = conversation.CompanionID
+	}
+	if msg.Text != nil {
+		tokens := s.tokenCounter.Count(*msg.Text)
+		analytics.Tokens = &tokens
+	}
 	s.analytics.InsertMessageAnalytics(ctx, analytics)
 
-	return storedMsg, nil
+	return storedMsg, false, nil
 }
 
 func (s *MessageService) validateMessage(msg *models.Message) error {
@@ -82,6 +137,18 @@ func (s *MessageService) validateMessage(msg *models.Message) error {
 }
 
 func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *models.Conversation, userMsg *models.Message, companionProfile *models.CompanionProfile) (*models.Message, error) {
+	// Track topic changes before building the prompt, refusing to reply on blocked topics
+	if userMsg.Text != nil {
+		err := s.aiContext.DetectAndUpdateTopic(ctx, conversation.ID, *userMsg.Text)
+		var blocked *BlockedTopicError
+		if errors.As(err, &blocked) {
+			return s.sendBlockedTopicEvent(ctx, conversation, blocked)
+		}
+		if err != nil {
+			s.options.logger().Error("Failed to update conversation topic", "error", err)
+		}
+	}
+
 	// Get conversation context and build dynamic prompt
 	dynamicPrompt, err := s.aiContext.BuildDynamicPrompt(ctx, conversation, userMsg, companionProfile)
 	if err != nil {
@@ -94,7 +161,7 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 		return nil, fmt.Errorf("failed to get recent messages: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Retrieved %d recent messages for conversation %s\n", len(msgs), conversation.ID.Hex())
+	s.options.logger().Debug("Retrieved recent messages", "count", len(msgs), "conversation_id", conversation.ID.Hex())
 
 	// Build conversation history for AI
 	llmMessages := s.buildConversationHistory(msgs, userMsg)
@@ -103,7 +170,7 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 	styleDirective := "Write in a natural, down-to-earth tone. Avoid clichés and idioms. Keep sentences concise, warm, and conversational. Speak like a real person."
 	llmMessages = append([]LLMMessage{{Role: "system", Content: dynamicPrompt}, {Role: "system", Content: styleDirective}}, llmMessages...)
 
-	fmt.Printf("DEBUG: Sending %d messages to AI (including system prompt)\n", len(llmMessages))
+	s.options.logger().Debug("Sending messages to AI, including system prompt", "count", len(llmMessages))
 
 	// Signal typing start immediately
 	GetTypingTracker().SetStart(conversation.ID.Hex())
@@ -114,6 +181,9 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 		return nil, fmt.Errorf("failed to generate AI responses: %w", err)
 	}
 
+	// Withhold high-risk responses before anything is delivered
+	aiResponses = s.enforceResponseSafety(ctx, conversation, aiResponses)
+
 	// Inform tracker about total messages
 	GetTypingTracker().SetTotal(conversation.ID.Hex(), len(aiResponses))
 
@@ -139,7 +209,7 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 		GetTypingTracker().Update(conversation.ID.Hex(), i, len(aiResponses))
 
 		// Store the response
-		storedResponse, err := s.repo.CreateMessage(ctx, aiResponse)
+		storedResponse, _, err := s.repo.CreateMessage(ctx, aiResponse)
 		if err != nil {
 			return nil, fmt.Errorf("failed to store AI response: %w", err)
 		}
@@ -153,6 +223,11 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 		finalResponse = storedResponse
 	}
 
+	// Validate response quality in shadow mode now that the reply has been delivered
+	if err := s.responseQuality.ShadowValidate(ctx, finalResponse, conversation, companionProfile); err != nil {
+		s.options.logger().Error("Failed to start shadow response validation", "error", err)
+	}
+
 	// Extract and store memories from the conversation
 	go func() {
 		// Run memory extraction in background
@@ -167,21 +242,72 @@ func (s *MessageService) GenerateAIResponse(ctx context.Context, conversation *m
 			}
 			allMessages = append(allMessages, msg)
 		}
-		if err := s.aiContext.ExtractAndStoreMemory(context.Background(), conversation.ID, allMessages); err != nil {
-			fmt.Printf("Memory extraction failed: %v\n", err)
+		if err := s.aiContext.ExtractAndStoreMemory(context.WithoutCancel(ctx), conversation.ID, allMessages); err != nil {
+			s.options.logger().Error("Memory extraction failed", "error", err)
 		}
 	}()
 
 	// Update conversation intelligence in background
 	go func() {
-		if _, err := s.conversationIntelligence.AnalyzeConversationFlow(context.Background(), conversation.ID); err != nil {
-			fmt.Printf("Conversation intelligence update failed: %v\n", err)
+		if _, err := s.conversationIntelligence.AnalyzeConversationFlow(context.WithoutCancel(ctx), conversation.ID); err != nil {
+			s.options.logger().Error("Conversation intelligence update failed", "error", err)
 		}
 	}()
 
 	return finalResponse, nil
 }
 
+// sendBlockedTopicEvent stores a blocked topic system event in place of the companion's reply
+func (s *MessageService) sendBlockedTopicEvent(ctx context.Context, conversation *models.Conversation, blocked *BlockedTopicError) (*models.Message, error) {
+	s.options.logger().Info("Refused to reply on a blocked topic", "conversation_id", conversation.ID.Hex(), "topic", blocked.Topic, "pattern", blocked.Pattern)
+
+	event, _, err := s.repo.CreateMessage(ctx, &models.Message{
+		ConversationID: conversation.ID,
+		SenderType:     sendertype.System,
+		Type:           messagetype.System,
+		SystemEvent: &models.SystemEvent{
+			EventType: SystemEventBlockedTopic,
+			Details:   blockedTopicMessage,
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store blocked topic event: %w", err)
+	}
+	return event, nil
+}
+
+// enforceResponseSafety replaces responses that fail a high-risk safety check with a neutral fallback and escalates them
+func (s *MessageService) enforceResponseSafety(ctx context.Context, conversation *models.Conversation, responses []string) []string {
+	responseText := strings.Join(responses, " ")
+
+	result, err := s.safetyChecker.CheckResponseSafety(ctx, responseText)
+	if err != nil {
+		s.options.logger().Error("Response safety check failed", "error", err)
+		return responses
+	}
+	if result.RiskLevel != HighSafetyRisk {
+		return responses
+	}
+
+	if s.safetyEscalator != nil {
+		incident := &models.SafetyIncident{
+			ConversationID: conversation.ID,
+			UserID:         conversation.UserID,
+			CompanionID:    conversation.CompanionID,
+			ResponseText:   responseText,
+			Concerns:       result.Concerns,
+			RiskLevel:      result.RiskLevel,
+		}
+		if err := s.safetyEscalator.Escalate(ctx, incident); err != nil {
+			s.options.logger().Error("Failed to escalate safety incident", "error", err)
+		}
+	}
+
+	return []string{SafetyFallbackMessage}
+}
+
 // buildConversationHistory builds the conversation history for AI context
 func (s *MessageService) buildConversationHistory(messages []*models.Message, userMsg *models.Message) []LLMMessage {
 	var llmMessages []LLMMessage
@@ -205,7 +331,7 @@ func (s *MessageService) buildConversationHistory(messages []*models.Message, us
 		for _, m := range messages {
 			if m.ID == userMsg.ID && m.Text != nil && *m.Text == *userMsg.Text {
 				messageAlreadyIncluded = true
-				fmt.Printf("DEBUG: User message already included in conversation history, skipping duplication. Message: %s\n", *userMsg.Text)
+				s.options.logger().Debug("User message already included in conversation history, skipping duplication", "message_id", userMsg.ID.Hex())
 				break
 			}
 		}
@@ -219,7 +345,11 @@ func (s *MessageService) buildConversationHistory(messages []*models.Message, us
 	return llmMessages
 }
 
+// ListMessages lists a conversation's messages newest first, reading archived messages back from S3 when needed
 func (s *MessageService) ListMessages(ctx context.Context, conversationID primitive.ObjectID, limit int, cursor *primitive.ObjectID) ([]*models.Message, *primitive.ObjectID, bool, error) {
+	if s.archive != nil {
+		return s.archive.ListMessages(ctx, conversationID, limit, cursor)
+	}
 	return s.repo.ListMessages(ctx, conversationID, limit, cursor)
 }
 
@@ -242,6 +372,41 @@ func (s *MessageService) MarkMessageAsRead(ctx context.Context, id primitive.Obj
 	return s.repo.UpdateMessage(ctx, msg)
 }
 
+// MarkMessagesRead marks the companion's messages among messageIDs as read and returns how many were updated
+func (s *MessageService) MarkMessagesRead(ctx context.Context, conversationID primitive.ObjectID, userID string, messageIDs []primitive.ObjectID) (int, error) {
+	return s.repo.MarkMessagesRead(ctx, conversationID, userID, messageIDs)
+}
+
+// maxReactionLength bounds the size of a reaction in bytes; multi-codepoint emoji such as flags and families fit easily
+const maxReactionLength = 32
+
+// AddReaction reacts to a message with an emoji on behalf of the user, replacing any earlier reaction of theirs
+func (s *MessageService) AddReaction(ctx context.Context, messageID primitive.ObjectID, userID, emoji string) error {
+	if err := validateReaction(emoji); err != nil {
+		return err
+	}
+	return s.repo.AddReaction(ctx, messageID, userID, emoji)
+}
+
+// RemoveReaction removes the user's emoji reaction from a message
+func (s *MessageService) RemoveReaction(ctx context.Context, messageID primitive.ObjectID, userID, emoji string) error {
+	if err := validateReaction(emoji); err != nil {
+		return err
+	}
+	return s.repo.RemoveReaction(ctx, messageID, userID, emoji)
+}
+
+// validateReaction rejects reactions that are not short printable text or that cannot be used as a MongoDB field name
+func validateReaction(emoji string) error {
+	switch {
+	case emoji == "" || len(emoji) > maxReactionLength || !utf8.ValidString(emoji):
+		return apperrors.NewValidationError(fmt.Sprintf("reaction must be 1 to %d bytes of UTF-8", maxReactionLength), nil)
+	case strings.ContainsAny(emoji, ". \t\n") || strings.HasPrefix(emoji, "$"):
+		return apperrors.NewValidationError("reaction must not contain dots or whitespace or start with $", nil)
+	}
+	return nil
+}
+
 // GetConversationIntelligence retrieves conversation intelligence insights
 func (s *MessageService) GetConversationIntelligence(ctx context.Context, conversationID primitive.ObjectID) (*models.ConversationIntelligence, error) {
 	return s.conversationIntelligence.AnalyzeConversationFlow(ctx, conversationID)
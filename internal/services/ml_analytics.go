@@ -18,15 +18,18 @@ import (
 type MLAnalyticsService struct {
 	analyticsRepo *repositories.AnalyticsRepository
 	convRepo      *repositories.ConversationRepository
-	grokService   *GrokService
+	grokService   LLMClient
+
+	options ServiceConfig
 }
 
 // NewMLAnalyticsService creates a new ML analytics service
-func NewMLAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, grokService *GrokService) *MLAnalyticsService {
+func NewMLAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, grokService LLMClient, opts ...Option) *MLAnalyticsService {
 	return &MLAnalyticsService{
 		analyticsRepo: analyticsRepo,
 		convRepo:      convRepo,
 		grokService:   grokService,
+		options:       newServiceConfig(opts),
 	}
 }
 
@@ -62,51 +65,55 @@ type BehavioralPattern struct {
 	Metadata    map[string]any `json:"metadata"`
 }
 
-// GetPersonalizedRecommendations generates personalized recommendations for a user
-func (s *MLAnalyticsService) GetPersonalizedRecommendations(ctx context.Context, userID, companionID string) ([]Recommendation, error) {
+// GetPersonalizedRecommendations generates personalized recommendations for a user along with response metadata
+func (s *MLAnalyticsService) GetPersonalizedRecommendations(ctx context.Context, userID, companionID string) ([]Recommendation, map[string]any, error) {
+	if !personalisationEnabled(ctx, s.analyticsRepo, userID) {
+		return []Recommendation{}, map[string]any{"privacy_suppressed": true}, nil
+	}
+
 	var recommendations []Recommendation
 
 	// Get user data for analysis
 	progress, err := s.analyticsRepo.GetUserProgress(ctx, userID, companionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user progress: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user progress: %w", err)
 	}
 
 	relationshipAnalytics, err := s.analyticsRepo.GetRelationshipAnalytics(ctx, userID, companionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get relationship analytics: %w", err)
+		return nil, nil, fmt.Errorf("failed to get relationship analytics: %w", err)
 	}
 
 	statistics, err := s.analyticsRepo.GetUserStatistics(ctx, userID, companionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user statistics: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user statistics: %w", err)
 	}
 
 	// Generate conversation topic recommendations
 	topicRecs, err := s.generateTopicRecommendations(ctx, userID, companionID, progress, relationshipAnalytics)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate topic recommendations: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate topic recommendations: %w", err)
 	}
 	recommendations = append(recommendations, topicRecs...)
 
 	// Generate interaction strategy recommendations
 	interactionRecs, err := s.generateInteractionRecommendations(ctx, userID, companionID, progress, relationshipAnalytics)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate interaction recommendations: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate interaction recommendations: %w", err)
 	}
 	recommendations = append(recommendations, interactionRecs...)
 
 	// Generate timing recommendations
 	timingRecs, err := s.generateTimingRecommendations(ctx, userID, companionID, statistics)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate timing recommendations: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate timing recommendations: %w", err)
 	}
 	recommendations = append(recommendations, timingRecs...)
 
 	// Generate personal growth recommendations
 	growthRecs, err := s.generateGrowthRecommendations(ctx, userID, companionID, progress, relationshipAnalytics)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate growth recommendations: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate growth recommendations: %w", err)
 	}
 	recommendations = append(recommendations, growthRecs...)
 
@@ -118,7 +125,7 @@ func (s *MLAnalyticsService) GetPersonalizedRecommendations(ctx context.Context,
 		return recommendations[i].Confidence > recommendations[j].Confidence
 	})
 
-	return recommendations, nil
+	return recommendations, map[string]any{"privacy_suppressed": false}, nil
 }
 
 // generateTopicRecommendations generates conversation topic recommendations
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPersonalizationSuppressed(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	var llmCalls atomic.Int32
+	grokServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		llmCalls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer grokServer.Close()
+
+	privacyOff := func() bson.D {
+		return mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch,
+			bson.D{{Key: "user_id", Value: "user"}, {Key: "personalization_level", Value: "none"}})
+	}
+
+	mt.Run("ml recommendations", func(mt *mtest.T) {
+		mt.AddMockResponses(privacyOff())
+
+		service := NewMLAnalyticsService(
+			repositories.NewAnalyticsRepository(nil, mt.DB),
+			repositories.NewConversationRepository(mt.DB),
+			NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL}),
+		)
+
+		recommendations, metadata, err := service.GetPersonalizedRecommendations(context.Background(), "user", "companion")
+		require.NoError(t, err)
+		assert.NotNil(t, recommendations)
+		assert.Empty(t, recommendations)
+		assert.Equal(t, true, metadata["privacy_suppressed"])
+	})
+
+	mt.Run("dashboard recommendations", func(mt *mtest.T) {
+		mt.AddMockResponses(privacyOff())
+
+		service := NewAnalyticsService(
+			NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL}),
+			repositories.NewAnalyticsRepository(nil, mt.DB),
+			repositories.NewConversationRepository(mt.DB),
+		)
+
+		recommendations, metadata := service.generateRecommendations(context.Background(), "user", &models.UserProgress{}, &models.RelationshipAnalytics{}, &models.UserStatistics{})
+		assert.NotNil(t, recommendations)
+		assert.Empty(t, recommendations)
+		assert.Equal(t, true, metadata["privacy_suppressed"])
+	})
+
+	assert.Zero(t, llmCalls.Load())
+}
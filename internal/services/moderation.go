@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// Moderation filter names, also used to select filters in configuration
+const (
+	ModerationFilterProfanity = "profanity"
+	ModerationFilterPII       = "pii"
+	ModerationFilterToxicity  = "toxicity"
+)
+
+// ErrContentFlagged is returned when a moderation filter blocks a message
+var ErrContentFlagged = errors.New("message blocked by content moderation")
+
+// ModerationFilter screens message text. It returns the text to pass on, whether the message
+// must be blocked, and the reason for blocking or rewriting it.
+type ModerationFilter interface {
+	Name() string
+	Moderate(ctx context.Context, text string) (sanitisedText string, flagged bool, reason string)
+}
+
+// moderationEventStore persists blocked messages
+type moderationEventStore interface {
+	CreateModerationEvent(ctx context.Context, event *models.ModerationEvent) error
+}
+
+// ContentModerationPipeline runs user messages through a chain of filters before they are stored
+type ContentModerationPipeline struct {
+	filters []ModerationFilter
+	store   moderationEventStore
+
+	options ServiceConfig
+}
+
+// NewContentModerationPipeline creates a pipeline that applies filters in order
+func NewContentModerationPipeline(store moderationEventStore, filters []ModerationFilter, opts ...Option) *ContentModerationPipeline {
+	return &ContentModerationPipeline{
+		filters: filters,
+		store:   store,
+		options: newServiceConfig(opts),
+	}
+}
+
+// NewModerationFilters builds the named filters; unknown names are rejected
+func NewModerationFilters(names []string, llm LLMClient) ([]ModerationFilter, error) {
+	var filters []ModerationFilter
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case ModerationFilterProfanity:
+			filters = append(filters, NewProfanityFilter(nil))
+		case ModerationFilterPII:
+			filters = append(filters, NewPIIDetector())
+		case ModerationFilterToxicity:
+			filters = append(filters, NewToxicityClassifier(llm))
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown moderation filter %q", name)
+		}
+	}
+	return filters, nil
+}
+
+// Moderate rewrites msg.Text in place with the sanitised text. If a filter flags the message the
+// remaining filters are skipped, a moderation event is recorded and ErrContentFlagged is returned.
+func (p *ContentModerationPipeline) Moderate(ctx context.Context, msg *models.Message) error {
+	if msg.Text == nil {
+		return nil
+	}
+
+	original := *msg.Text
+	text := original
+	for _, filter := range p.filters {
+		sanitised, flagged, reason := filter.Moderate(ctx, text)
+		if flagged {
+			event := &models.ModerationEvent{
+				ConversationID: msg.ConversationID,
+				UserID:         msg.SenderID,
+				Filter:         filter.Name(),
+				Reason:         reason,
+				Text:           original,
+				CreatedAt:      time.Now(),
+			}
+			if err := p.store.CreateModerationEvent(ctx, event); err != nil {
+				p.options.logger().Error("Failed to record moderation event", "error", err)
+			}
+			return fmt.Errorf("%w: %s", ErrContentFlagged, reason)
+		}
+		text = sanitised
+	}
+
+	msg.Text = &text
+	return nil
+}
+
+// defaultProfanity is the word list used when no custom list is configured
+var defaultProfanity = []string{"fuck", "fucking", "shit", "bitch", "asshole", "bastard", "cunt", "dick", "motherfucker"}
+
+// ProfanityFilter masks profane words with asterisks without blocking the message
+type ProfanityFilter struct {
+	pattern *regexp.Regexp
+}
+
+// NewProfanityFilter creates a profanity filter for words, or the default word list if words is empty
+func NewProfanityFilter(words []string) *ProfanityFilter {
+	if len(words) == 0 {
+		words = defaultProfanity
+	}
+
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+
+	return &ProfanityFilter{
+		pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+func (f *ProfanityFilter) Name() string { return ModerationFilterProfanity }
+
+// Moderate replaces every profane word with asterisks of the same length
+func (f *ProfanityFilter) Moderate(ctx context.Context, text string) (string, bool, string) {
+	masked := f.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+	if masked == text {
+		return text, false, ""
+	}
+	return masked, false, "profanity masked"
+}
+
+var (
+	ssnPattern        = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	creditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// PIIDetector strips social security numbers, credit card numbers and email addresses
+type PIIDetector struct{}
+
+// NewPIIDetector creates a regex-based PII detector
+func NewPIIDetector() *PIIDetector {
+	return &PIIDetector{}
+}
+
+func (d *PIIDetector) Name() string { return ModerationFilterPII }
+
+// Moderate replaces detected PII with placeholders without blocking the message
+func (d *PIIDetector) Moderate(ctx context.Context, text string) (string, bool, string) {
+	var found []string
+
+	sanitised := ssnPattern.ReplaceAllStringFunc(text, func(string) string {
+		found = append(found, "ssn")
+		return "[redacted ssn]"
+	})
+	sanitised = creditCardPattern.ReplaceAllStringFunc(sanitised, func(number string) string {
+		if !luhnValid(number) {
+			return number
+		}
+		found = append(found, "credit card")
+		return "[redacted card]"
+	})
+	sanitised = emailPattern.ReplaceAllStringFunc(sanitised, func(string) string {
+		found = append(found, "email")
+		return "[redacted email]"
+	})
+
+	if len(found) == 0 {
+		return text, false, ""
+	}
+	return sanitised, false, "redacted " + strings.Join(uniqueStrings(found), ", ")
+}
+
+// luhnValid reports whether the digits in number pass the Luhn checksum used by card numbers
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// uniqueStrings returns values without duplicates, keeping first occurrences in order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// ToxicityClassifier blocks messages the mini model classifies as toxic
+type ToxicityClassifier struct {
+	llm LLMClient
+
+	options ServiceConfig
+}
+
+// NewToxicityClassifier creates a toxicity classifier backed by the mini model
+func NewToxicityClassifier(llm LLMClient, opts ...Option) *ToxicityClassifier {
+	return &ToxicityClassifier{llm: llm, options: newServiceConfig(opts)}
+}
+
+func (c *ToxicityClassifier) Name() string { return ModerationFilterToxicity }
+
+// Moderate asks the mini model whether text is toxic. Classification failures let the message through.
+func (c *ToxicityClassifier) Moderate(ctx context.Context, text string) (string, bool, string) {
+	prompt := fmt.Sprintf(`Classify whether this message from a user to their AI companion is toxic: threats, harassment, hate speech or sexual content involving minors.
+Strong language or sadness alone is not toxic.
+
+Message: "%s"
+
+Respond with ONLY a JSON object:
+{"toxic": true|false, "reason": "short reason or empty"}`, text)
+
+	messages := []LLMMessage{
+		{Role: "system", Content: "You are a content moderation classifier. Respond only with valid JSON."},
+		{Role: "user", Content: prompt},
+	}
+
+	response, err := c.llm.SendMiniMessage(ctx, messages)
+	if err != nil {
+		c.options.logger().Error("Toxicity classification failed", "error", err)
+		return text, false, ""
+	}
+
+	response = strings.TrimSpace(response)
+	response = strings.TrimSuffix(strings.TrimPrefix(response, "```json"), "```")
+
+	var result struct {
+		Toxic  bool   `json:"toxic"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &result); err != nil {
+		c.options.logger().Error("Failed to parse toxicity classification", "error", err)
+		return text, false, ""
+	}
+
+	if !result.Toxic {
+		return text, false, ""
+	}
+	if result.Reason == "" {
+		result.Reason = "toxic content"
+	}
+	return text, true, result.Reason
+}
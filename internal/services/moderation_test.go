@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeModerationEvents []*models.ModerationEvent
+
+func (f *fakeModerationEvents) CreateModerationEvent(ctx context.Context, event *models.ModerationEvent) error {
+	*f = append(*f, event)
+	return nil
+}
+
+type stubModerationFilter struct {
+	name      string
+	sanitised string
+	flagged   bool
+	reason    string
+	calls     int
+}
+
+func (f *stubModerationFilter) Name() string { return f.name }
+
+func (f *stubModerationFilter) Moderate(ctx context.Context, text string) (string, bool, string) {
+	f.calls++
+	if f.sanitised == "" {
+		return text, f.flagged, f.reason
+	}
+	return f.sanitised, f.flagged, f.reason
+}
+
+func TestProfanityFilter(t *testing.T) {
+	filter := NewProfanityFilter(nil)
+
+	tests := []struct {
+		text       string
+		want       string
+		wantReason string
+	}{
+		{text: "What a lovely day", want: "What a lovely day"},
+		{text: "This is SHIT honestly", want: "This is **** honestly", wantReason: "profanity masked"},
+		{text: "Fuck, that bastard!", want: "****, that *******!", wantReason: "profanity masked"},
+		{text: "Scunthorpe and dickens are fine", want: "Scunthorpe and dickens are fine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			sanitised, flagged, reason := filter.Moderate(context.Background(), tt.text)
+			assert.Equal(t, tt.want, sanitised)
+			assert.False(t, flagged)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	custom := NewProfanityFilter([]string{"darn"})
+	sanitised, _, _ := custom.Moderate(context.Background(), "darn it, shit")
+	assert.Equal(t, "**** it, shit", sanitised)
+}
+
+func TestPIIDetector(t *testing.T) {
+	detector := NewPIIDetector()
+
+	tests := []struct {
+		name       string
+		text       string
+		want       string
+		wantReason string
+	}{
+		{name: "clean", text: "I live near the park", want: "I live near the park"},
+		{name: "ssn", text: "my ssn is 123-45-6789 ok", want: "my ssn is [redacted ssn] ok", wantReason: "redacted ssn"},
+		{name: "credit card", text: "card 4111 1111 1111 1111 exp 12/29", want: "card [redacted card] exp 12/29", wantReason: "redacted credit card"},
+		{name: "invalid card number kept", text: "order 1234567890123", want: "order 1234567890123"},
+		{name: "email", text: "write me at jane.doe+luna@example.co.uk", want: "write me at [redacted email]", wantReason: "redacted email"},
+		{
+			name:       "several kinds",
+			text:       "a@b.io 4111-1111-1111-1111 c@d.io",
+			want:       "[redacted email] [redacted card] [redacted email]",
+			wantReason: "redacted credit card, email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sanitised, flagged, reason := detector.Moderate(context.Background(), tt.text)
+			assert.Equal(t, tt.want, sanitised)
+			assert.False(t, flagged)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestToxicityClassifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		err         error
+		wantFlagged bool
+		wantReason  string
+	}{
+		{name: "clean", response: `{"toxic": false, "reason": ""}`},
+		{name: "toxic", response: "```json\n{\"toxic\": true, \"reason\": \"threat of violence\"}\n```", wantFlagged: true, wantReason: "threat of violence"},
+		{name: "toxic without reason", response: `{"toxic": true}`, wantFlagged: true, wantReason: "toxic content"},
+		{name: "unparseable response fails open", response: "I cannot classify that"},
+		{name: "llm error fails open", err: errors.New("grok unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &mockLLM{response: tt.response, err: tt.err}
+			classifier := NewToxicityClassifier(llm)
+
+			sanitised, flagged, reason := classifier.Moderate(context.Background(), "some message")
+
+			assert.Equal(t, "some message", sanitised)
+			assert.Equal(t, tt.wantFlagged, flagged)
+			assert.Equal(t, tt.wantReason, reason)
+			require.Len(t, llm.prompts, 1)
+			assert.Contains(t, llm.prompts[0][1].Content, `Message: "some message"`)
+		})
+	}
+}
+
+func TestContentModerationPipeline(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	t.Run("chains sanitised text through filters", func(t *testing.T) {
+		events := &fakeModerationEvents{}
+		pipeline := NewContentModerationPipeline(events, []ModerationFilter{NewProfanityFilter(nil), NewPIIDetector()})
+		msg := &models.Message{Text: text("shit, email me at me@example.com")}
+
+		require.NoError(t, pipeline.Moderate(context.Background(), msg))
+
+		assert.Equal(t, "****, email me at [redacted email]", *msg.Text)
+		assert.Empty(t, *events)
+	})
+
+	t.Run("short-circuits and records flagged messages", func(t *testing.T) {
+		events := &fakeModerationEvents{}
+		toxic := &stubModerationFilter{name: ModerationFilterToxicity, flagged: true, reason: "harassment"}
+		after := &stubModerationFilter{name: "after"}
+		pipeline := NewContentModerationPipeline(events, []ModerationFilter{NewPIIDetector(), toxic, after})
+		msg := &models.Message{ConversationID: primitive.NewObjectID(), SenderID: "user-1", Text: text("you are awful, 123-45-6789")}
+
+		err := pipeline.Moderate(context.Background(), msg)
+
+		assert.ErrorIs(t, err, ErrContentFlagged)
+		assert.Contains(t, err.Error(), "harassment")
+		assert.Zero(t, after.calls)
+		assert.Equal(t, "you are awful, 123-45-6789", *msg.Text)
+		require.Len(t, *events, 1)
+		event := (*events)[0]
+		assert.Equal(t, msg.ConversationID, event.ConversationID)
+		assert.Equal(t, "user-1", event.UserID)
+		assert.Equal(t, ModerationFilterToxicity, event.Filter)
+		assert.Equal(t, "harassment", event.Reason)
+		assert.Equal(t, "you are awful, 123-45-6789", event.Text)
+	})
+
+	t.Run("messages without text pass through", func(t *testing.T) {
+		filter := &stubModerationFilter{name: "any", flagged: true}
+		pipeline := NewContentModerationPipeline(&fakeModerationEvents{}, []ModerationFilter{filter})
+
+		assert.NoError(t, pipeline.Moderate(context.Background(), &models.Message{}))
+		assert.Zero(t, filter.calls)
+	})
+}
+
+func TestNewModerationFilters(t *testing.T) {
+	filters, err := NewModerationFilters([]string{"pii", " toxicity", "profanity"}, &mockLLM{})
+	require.NoError(t, err)
+	require.Len(t, filters, 3)
+	assert.Equal(t, ModerationFilterPII, filters[0].Name())
+	assert.Equal(t, ModerationFilterToxicity, filters[1].Name())
+	assert.Equal(t, ModerationFilterProfanity, filters[2].Name())
+
+	_, err = NewModerationFilters([]string{"sentiment"}, &mockLLM{})
+	assert.Error(t, err)
+}
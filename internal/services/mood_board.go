@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// WeeklyMoodEntry summarises the emotions a user expressed during one ISO week
+type WeeklyMoodEntry struct {
+	Week                string             `json:"week"` // ISO 8601 week, e.g. 2024-W07
+	DominantEmotion     string             `json:"dominant_emotion"`
+	AverageIntensity    float64            `json:"average_intensity"`
+	EmotionDistribution map[string]float64 `json:"emotion_distribution"`
+	MoodShift           float64            `json:"mood_shift"` // change in the dominant emotion's share since the prior week
+}
+
+// weeklyEmotionCount is one aggregated (week, emotion) bucket of sentiment analytics
+type weeklyEmotionCount struct {
+	Year         int
+	Week         int
+	Emotion      string
+	Count        int
+	IntensitySum float64
+}
+
+// GetWeeklyMoodBoard aggregates the user's sentiment analytics into per-week emotion summaries, oldest week first
+func (s *PrivacyAnalyticsService) GetWeeklyMoodBoard(ctx context.Context, userID, companionID string, weeks int) ([]WeeklyMoodEntry, error) {
+	if weeks <= 0 {
+		return nil, fmt.Errorf("weeks must be positive")
+	}
+
+	collection := s.analyticsRepo.GetMongoCollection("sentiment_analytics")
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"user_id":      userID,
+				"companion_id": companionID,
+				"created_at":   bson.M{"$gte": isoWeekStart(time.Now()).AddDate(0, 0, -7*(weeks-1))},
+				"emotion":      bson.M{"$exists": true, "$ne": ""},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id": bson.M{
+					"year":    bson.M{"$isoWeekYear": "$created_at"},
+					"week":    bson.M{"$isoWeek": "$created_at"},
+					"emotion": "$emotion",
+				},
+				"count":         bson.M{"$sum": 1},
+				"intensity_sum": bson.M{"$sum": "$intensity"},
+			},
+		},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate weekly moods: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID struct {
+			Year    int    `bson:"year"`
+			Week    int    `bson:"week"`
+			Emotion string `bson:"emotion"`
+		} `bson:"_id"`
+		Count        int     `bson:"count"`
+		IntensitySum float64 `bson:"intensity_sum"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode weekly moods: %w", err)
+	}
+
+	counts := make([]weeklyEmotionCount, 0, len(results))
+	for _, result := range results {
+		counts = append(counts, weeklyEmotionCount{
+			Year:         result.ID.Year,
+			Week:         result.ID.Week,
+			Emotion:      result.ID.Emotion,
+			Count:        result.Count,
+			IntensitySum: result.IntensitySum,
+		})
+	}
+
+	return buildWeeklyMoodBoard(counts), nil
+}
+
+// buildWeeklyMoodBoard turns per-week emotion buckets into mood board entries ordered by week
+func buildWeeklyMoodBoard(counts []weeklyEmotionCount) []WeeklyMoodEntry {
+	type weekTotals struct {
+		year, week   int
+		total        int
+		intensitySum float64
+		emotions     map[string]int
+	}
+
+	byWeek := make(map[string]*weekTotals)
+	for _, count := range counts {
+		key := fmt.Sprintf("%d-W%02d", count.Year, count.Week)
+		totals, ok := byWeek[key]
+		if !ok {
+			totals = &weekTotals{year: count.Year, week: count.Week, emotions: make(map[string]int)}
+			byWeek[key] = totals
+		}
+		totals.total += count.Count
+		totals.intensitySum += count.IntensitySum
+		totals.emotions[count.Emotion] += count.Count
+	}
+
+	keys := make([]string, 0, len(byWeek))
+	for key := range byWeek {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]WeeklyMoodEntry, 0, len(keys))
+	previousShare := 0.0
+	for i, key := range keys {
+		totals := byWeek[key]
+		if totals.total == 0 {
+			continue
+		}
+
+		entry := WeeklyMoodEntry{
+			Week:                key,
+			AverageIntensity:    totals.intensitySum / float64(totals.total),
+			EmotionDistribution: make(map[string]float64, len(totals.emotions)),
+		}
+		for emotion, count := range totals.emotions {
+			entry.EmotionDistribution[emotion] = float64(count) / float64(totals.total)
+		}
+
+		// Break ties alphabetically so the dominant emotion is stable
+		dominantShare := 0.0
+		for emotion, share := range entry.EmotionDistribution {
+			if share > dominantShare || (share == dominantShare && emotion < entry.DominantEmotion) {
+				entry.DominantEmotion = emotion
+				dominantShare = share
+			}
+		}
+
+		if i > 0 {
+			entry.MoodShift = dominantShare - previousShare
+		}
+		previousShare = dominantShare
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// isoWeekStart returns midnight on the Monday of the ISO week containing t
+func isoWeekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
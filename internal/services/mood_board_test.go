@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func weeklyEmotionDoc(year, week int, emotion string, count int, intensitySum float64) bson.D {
+	return bson.D{
+		{Key: "_id", Value: bson.D{{Key: "year", Value: year}, {Key: "week", Value: week}, {Key: "emotion", Value: emotion}}},
+		{Key: "count", Value: count},
+		{Key: "intensity_sum", Value: intensitySum},
+	}
+}
+
+func TestGetWeeklyMoodBoard(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("aggregates four weeks", func(mt *mtest.T) {
+		// Buckets arrive unordered and span a year boundary
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.sentiment_analytics", mtest.FirstBatch,
+			weeklyEmotionDoc(2026, 1, "joy", 2, 1.2),
+			weeklyEmotionDoc(2025, 51, "joy", 6, 4.8),
+			weeklyEmotionDoc(2025, 51, "anxiety", 2, 1.0),
+			weeklyEmotionDoc(2025, 52, "joy", 3, 2.1),
+			weeklyEmotionDoc(2025, 52, "sadness", 3, 1.5),
+			weeklyEmotionDoc(2026, 1, "sadness", 5, 4.0),
+			weeklyEmotionDoc(2026, 1, "anxiety", 3, 2.4),
+			weeklyEmotionDoc(2026, 2, "contentment", 4, 2.0),
+		))
+
+		service := NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, mt.DB), nil)
+		board, err := service.GetWeeklyMoodBoard(context.Background(), "user-1", "companion-1", 4)
+		require.NoError(t, err)
+		require.Len(t, board, 4)
+
+		weeks := []string{board[0].Week, board[1].Week, board[2].Week, board[3].Week}
+		assert.Equal(t, []string{"2025-W51", "2025-W52", "2026-W01", "2026-W02"}, weeks)
+
+		for _, entry := range board {
+			total := 0.0
+			for _, share := range entry.EmotionDistribution {
+				total += share
+			}
+			assert.InDelta(t, 1.0, total, 1e-9, entry.Week)
+		}
+
+		assert.Equal(t, "joy", board[0].DominantEmotion)
+		assert.InDelta(t, 0.725, board[0].AverageIntensity, 1e-9)
+		assert.Zero(t, board[0].MoodShift)
+
+		// Ties are broken alphabetically
+		assert.Equal(t, "joy", board[1].DominantEmotion)
+		assert.InDelta(t, -0.25, board[1].MoodShift, 1e-9)
+
+		assert.Equal(t, "sadness", board[2].DominantEmotion)
+		assert.InDelta(t, 0.5, board[2].EmotionDistribution["sadness"], 1e-9)
+		assert.InDelta(t, 0.0, board[2].MoodShift, 1e-9)
+
+		assert.Equal(t, "contentment", board[3].DominantEmotion)
+		assert.InDelta(t, 0.5, board[3].MoodShift, 1e-9)
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		assert.Equal(t, "user-1", match.Lookup("user_id").StringValue())
+		assert.Equal(t, "companion-1", match.Lookup("companion_id").StringValue())
+		since := match.Lookup("created_at", "$gte").Time()
+		assert.Equal(t, time.Monday, since.Weekday())
+		assert.WithinDuration(t, time.Now(), since, 28*24*time.Hour)
+	})
+
+	mt.Run("rejects non-positive weeks", func(mt *mtest.T) {
+		service := NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, mt.DB), nil)
+		_, err := service.GetWeeklyMoodBoard(context.Background(), "user-1", "companion-1", 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestISOWeekStart(t *testing.T) {
+	sunday := time.Date(2026, 1, 4, 15, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC), isoWeekStart(sunday))
+
+	monday := time.Date(2025, 12, 29, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC), isoWeekStart(monday))
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// NotificationProvider delivers notifications over a single channel
+type NotificationProvider interface {
+	Channel() string
+	Send(ctx context.Context, userID, notificationType string, payload map[string]any) error
+}
+
+// notificationPreferenceSource looks up a user's notification channels
+type notificationPreferenceSource interface {
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error)
+}
+
+// notificationStore persists in-app notifications
+type notificationStore interface {
+	InsertNotification(ctx context.Context, notification *models.Notification) error
+}
+
+// NotificationService routes notifications to the channels each user has enabled
+type NotificationService struct {
+	preferences notificationPreferenceSource
+	providers   map[string]NotificationProvider
+
+	options ServiceConfig
+}
+
+// NewNotificationService creates a notification service with the given channel providers
+func NewNotificationService(preferences notificationPreferenceSource, providers []NotificationProvider, opts ...Option) *NotificationService {
+	s := &NotificationService{
+		preferences: preferences,
+		providers:   make(map[string]NotificationProvider),
+		options:     newServiceConfig(opts),
+	}
+	for _, provider := range providers {
+		s.providers[provider.Channel()] = provider
+	}
+	return s
+}
+
+// Send dispatches a notification to every enabled channel that has a provider
+func (s *NotificationService) Send(ctx context.Context, userID, notificationType string, payload map[string]any) error {
+	var errs []error
+	for _, channel := range s.channelsFor(ctx, userID) {
+		provider, ok := s.providers[channel]
+		if !ok {
+			continue
+		}
+		if err := provider.Send(ctx, userID, notificationType, payload); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send %s notification: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// channelsFor returns the user's enabled channels, falling back to the defaults when none are stored
+func (s *NotificationService) channelsFor(ctx context.Context, userID string) []string {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return models.DefaultNotificationPreferences(uuid.Nil).Channels()
+	}
+
+	prefs, err := s.preferences.GetPreferences(ctx, id)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if !errors.As(err, &notFound) {
+			s.options.logger().Error("Failed to get notification preferences, using defaults", "error", err)
+		}
+		return models.DefaultNotificationPreferences(id).Channels()
+	}
+	return prefs.Channels()
+}
+
+// InAppNotificationProvider stores notifications for display inside the app
+type InAppNotificationProvider struct {
+	store notificationStore
+}
+
+// NewInAppNotificationProvider creates an in-app notification provider
+func NewInAppNotificationProvider(store notificationStore) *InAppNotificationProvider {
+	return &InAppNotificationProvider{store: store}
+}
+
+func (p *InAppNotificationProvider) Channel() string {
+	return models.NotificationChannelInApp
+}
+
+func (p *InAppNotificationProvider) Send(ctx context.Context, userID, notificationType string, payload map[string]any) error {
+	return p.store.InsertNotification(ctx, &models.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Payload: payload,
+	})
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNotificationPreferences map[uuid.UUID]*models.NotificationPreferences
+
+func (f fakeNotificationPreferences) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	prefs, ok := f[userID]
+	if !ok {
+		return nil, apperrors.NewNotFoundError("notification preferences not found", nil)
+	}
+	return prefs, nil
+}
+
+type mockNotificationProvider struct {
+	channel string
+	err     error
+	sent    []string
+}
+
+func (m *mockNotificationProvider) Channel() string {
+	return m.channel
+}
+
+func (m *mockNotificationProvider) Send(ctx context.Context, userID, notificationType string, payload map[string]any) error {
+	m.sent = append(m.sent, userID+":"+notificationType)
+	return m.err
+}
+
+func TestNotificationServiceRouting(t *testing.T) {
+	allChannels := uuid.New()
+	pushOnly := uuid.New()
+	noPreferences := uuid.New()
+
+	preferences := fakeNotificationPreferences{
+		allChannels: {UserID: allChannels, EmailEnabled: true, PushEnabled: true, InAppEnabled: true},
+		pushOnly:    {UserID: pushOnly, PushEnabled: true},
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		wantInApp int
+		wantPush  int
+		wantEmail int
+	}{
+		{name: "all channels enabled", userID: allChannels.String(), wantInApp: 1, wantPush: 1, wantEmail: 1},
+		{name: "push only", userID: pushOnly.String(), wantPush: 1},
+		{name: "defaults to in-app", userID: noPreferences.String(), wantInApp: 1},
+		{name: "invalid user id defaults to in-app", userID: "not-a-uuid", wantInApp: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inApp := &mockNotificationProvider{channel: models.NotificationChannelInApp}
+			push := &mockNotificationProvider{channel: models.NotificationChannelPush}
+			email := &mockNotificationProvider{channel: models.NotificationChannelEmail}
+			service := NewNotificationService(preferences, []NotificationProvider{inApp, push, email})
+
+			require.NoError(t, service.Send(context.Background(), tt.userID, models.NotificationAchievementUnlocked, nil))
+
+			assert.Len(t, inApp.sent, tt.wantInApp)
+			assert.Len(t, push.sent, tt.wantPush)
+			assert.Len(t, email.sent, tt.wantEmail)
+			for _, sent := range append(append(inApp.sent, push.sent...), email.sent...) {
+				assert.Equal(t, tt.userID+":"+models.NotificationAchievementUnlocked, sent)
+			}
+		})
+	}
+}
+
+func TestNotificationServiceSkipsMissingProviders(t *testing.T) {
+	userID := uuid.New()
+	preferences := fakeNotificationPreferences{
+		userID: {UserID: userID, EmailEnabled: true, InAppEnabled: true},
+	}
+	inApp := &mockNotificationProvider{channel: models.NotificationChannelInApp}
+
+	require.NoError(t, NewNotificationService(preferences, []NotificationProvider{inApp}).Send(context.Background(), userID.String(), models.NotificationStreakReminder, nil))
+	assert.Len(t, inApp.sent, 1)
+}
+
+func TestNotificationServiceReportsProviderErrors(t *testing.T) {
+	userID := uuid.New()
+	preferences := fakeNotificationPreferences{
+		userID: {UserID: userID, PushEnabled: true, InAppEnabled: true},
+	}
+	inApp := &mockNotificationProvider{channel: models.NotificationChannelInApp}
+	push := &mockNotificationProvider{channel: models.NotificationChannelPush, err: errors.New("device token expired")}
+
+	err := NewNotificationService(preferences, []NotificationProvider{inApp, push}).Send(context.Background(), userID.String(), models.NotificationChurnIntervention, nil)
+	assert.ErrorContains(t, err, "push")
+	assert.Len(t, inApp.sent, 1)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// openBadgesIssuerName is the issuer name shown on exported badges
+const openBadgesIssuerName = "Lunaria"
+
+// badgeStore loads achievements for Open Badges export
+type badgeStore interface {
+	GetUserAchievements(ctx context.Context, userID, companionID string, limit int) ([]models.UserAchievement, error)
+	GetUserAchievement(ctx context.Context, id primitive.ObjectID) (*models.UserAchievement, error)
+	GetAchievementDefinition(ctx context.Context, achievementID string) (*models.AchievementDefinition, error)
+}
+
+// badgeRecipientSource looks up the user a badge was awarded to
+type badgeRecipientSource interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+// ExportAchievementsAsOpenBadges returns every achievement the user earned with the companion as an Open Badges 2.0
+// assertion that can be shared outside the app
+func (s *GamificationService) ExportAchievementsAsOpenBadges(ctx context.Context, userID, companionID string) ([]models.OpenBadgeAssertion, error) {
+	email, err := s.badgeRecipientEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	achievements, err := s.badges.GetUserAchievements(ctx, userID, companionID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user achievements: %w", err)
+	}
+
+	assertions := make([]models.OpenBadgeAssertion, 0, len(achievements))
+	for i := range achievements {
+		assertions = append(assertions, s.openBadgeAssertion(&achievements[i], email))
+	}
+
+	return assertions, nil
+}
+
+// GetOpenBadgeAssertion returns the hosted copy of a single assertion, which verifiers fetch from the assertion's id
+func (s *GamificationService) GetOpenBadgeAssertion(ctx context.Context, id primitive.ObjectID) (*models.OpenBadgeAssertion, error) {
+	achievement, err := s.badges.GetUserAchievement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := s.badgeRecipientEmail(ctx, achievement.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	assertion := s.openBadgeAssertion(achievement, email)
+	return &assertion, nil
+}
+
+// GetOpenBadgeClass returns the Open Badges 2.0 BadgeClass for an achievement definition
+func (s *GamificationService) GetOpenBadgeClass(ctx context.Context, achievementID string) (*models.OpenBadgeClass, error) {
+	definition, err := s.badges.GetAchievementDefinition(ctx, achievementID)
+	if err != nil {
+		return nil, err
+	}
+
+	badge := &models.OpenBadgeClass{
+		Context:     models.OpenBadgesContext,
+		Type:        models.OpenBadgeTypeBadgeClass,
+		ID:          s.badgeURL("badges", definition.ID),
+		Name:        definition.Title,
+		Description: definition.Description,
+		Image:       definition.IconURL,
+		Criteria:    models.OpenBadgeCriteria{Narrative: definition.Description},
+		Issuer:      s.badgeURL("badges", "issuer"),
+	}
+	if definition.Category != "" {
+		badge.Tags = []string{definition.Category}
+	}
+
+	return badge, nil
+}
+
+// GetOpenBadgeIssuer returns the Open Badges 2.0 issuer profile referenced by every badge class
+func (s *GamificationService) GetOpenBadgeIssuer() *models.OpenBadgeIssuer {
+	return &models.OpenBadgeIssuer{
+		Context: models.OpenBadgesContext,
+		Type:    models.OpenBadgeTypeIssuer,
+		ID:      s.badgeURL("badges", "issuer"),
+		Name:    openBadgesIssuerName,
+		URL:     s.badgeURL(),
+	}
+}
+
+// openBadgeAssertion maps an awarded achievement to an assertion for the recipient's email
+func (s *GamificationService) openBadgeAssertion(achievement *models.UserAchievement, email string) models.OpenBadgeAssertion {
+	return models.OpenBadgeAssertion{
+		Context:   models.OpenBadgesContext,
+		Type:      models.OpenBadgeTypeAssertion,
+		ID:        s.badgeURL("badges", "assertions", achievement.ID.Hex()),
+		Recipient: hashedBadgeRecipient(email, achievement.ID.Hex()),
+		Badge:     s.badgeURL("badges", achievement.AchievementID),
+		IssuedOn:  achievement.EarnedAt,
+		Evidence: []models.OpenBadgeEvidence{{
+			Narrative:   fmt.Sprintf("Earned %q with a Lunaria companion.", achievement.Title),
+			Description: achievement.Description,
+		}},
+		Verification: models.OpenBadgeVerification{Type: models.OpenBadgeVerificationHosted},
+	}
+}
+
+// badgeRecipientEmail returns the email address a user's badges are issued to
+func (s *GamificationService) badgeRecipientEmail(ctx context.Context, userID string) (string, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return "", fmt.Errorf("invalid user id: %w", err)
+	}
+
+	user, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get badge recipient: %w", err)
+	}
+
+	return user.Email, nil
+}
+
+// badgeURL joins path segments onto the public base URL that badge documents are hosted under
+func (s *GamificationService) badgeURL(segments ...string) string {
+	return strings.Join(append([]string{strings.TrimSuffix(s.badgeBaseURL, "/")}, segments...), "/")
+}
+
+// hashedBadgeRecipient identifies a recipient by a salted SHA-256 of their normalised email, as Open Badges recommends
+func hashedBadgeRecipient(email, salt string) models.OpenBadgeRecipient {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email)) + salt))
+	return models.OpenBadgeRecipient{
+		Type:     "email",
+		Hashed:   true,
+		Salt:     salt,
+		Identity: "sha256$" + hex.EncodeToString(sum[:]),
+	}
+}
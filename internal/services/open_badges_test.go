@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeBadgeStore struct {
+	achievements []models.UserAchievement
+	definitions  map[string]*models.AchievementDefinition
+}
+
+func (f *fakeBadgeStore) GetUserAchievements(ctx context.Context, userID, companionID string, limit int) ([]models.UserAchievement, error) {
+	return f.achievements, nil
+}
+
+func (f *fakeBadgeStore) GetUserAchievement(ctx context.Context, id primitive.ObjectID) (*models.UserAchievement, error) {
+	for i := range f.achievements {
+		if f.achievements[i].ID == id {
+			return &f.achievements[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeBadgeStore) GetAchievementDefinition(ctx context.Context, achievementID string) (*models.AchievementDefinition, error) {
+	return f.definitions[achievementID], nil
+}
+
+type fakeBadgeRecipients struct {
+	user *models.User
+}
+
+func (f *fakeBadgeRecipients) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return f.user, nil
+}
+
+// openBadgesRequired lists the properties the Open Badges 2.0 schema requires on each document and nested object
+var openBadgesRequired = map[string][]string{
+	"Assertion":    {"@context", "type", "id", "recipient", "badge", "issuedOn", "verification"},
+	"recipient":    {"type", "hashed", "identity"},
+	"verification": {"type"},
+	"evidence":     {"narrative"},
+	"BadgeClass":   {"@context", "type", "id", "name", "description", "criteria", "issuer"},
+	"criteria":     {"narrative"},
+}
+
+func requireOpenBadgesFields(t *testing.T, object map[string]any, kind string) {
+	t.Helper()
+	for _, field := range openBadgesRequired[kind] {
+		assert.Contains(t, object, field, "%s is missing %s", kind, field)
+	}
+}
+
+func TestExportAchievementsAsOpenBadges(t *testing.T) {
+	userID := uuid.New()
+	achievementID := primitive.NewObjectID()
+	earnedAt := time.Date(2026, 5, 4, 10, 30, 0, 0, time.UTC)
+	store := &fakeBadgeStore{
+		achievements: []models.UserAchievement{{
+			ID:            achievementID,
+			UserID:        userID.String(),
+			CompanionID:   "companion-1",
+			AchievementID: "first_conversation",
+			Title:         "First Steps",
+			Description:   "Had your first conversation",
+			EarnedAt:      earnedAt,
+		}},
+		definitions: map[string]*models.AchievementDefinition{
+			"first_conversation": {ID: "first_conversation", Title: "First Steps", Description: "Have your first conversation", Category: "milestones"},
+		},
+	}
+	service := NewGamificationService(nil, nil, nil, nil, &fakeBadgeRecipients{user: &models.User{ID: userID, Email: " Ada@Example.com"}}, "https://lunaria.example/")
+	service.badges = store
+
+	assertions, err := service.ExportAchievementsAsOpenBadges(context.Background(), userID.String(), "companion-1")
+	require.NoError(t, err)
+	require.Len(t, assertions, 1)
+
+	encoded, err := json.Marshal(assertions[0])
+	require.NoError(t, err)
+	var assertion map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &assertion))
+
+	requireOpenBadgesFields(t, assertion, "Assertion")
+	assert.Equal(t, models.OpenBadgesContext, assertion["@context"])
+	assert.Equal(t, "Assertion", assertion["type"])
+	assert.Equal(t, "https://lunaria.example/badges/assertions/"+achievementID.Hex(), assertion["id"])
+	assert.Equal(t, "https://lunaria.example/badges/first_conversation", assertion["badge"])
+	assert.Equal(t, "2026-05-04T10:30:00Z", assertion["issuedOn"])
+
+	recipient := assertion["recipient"].(map[string]any)
+	requireOpenBadgesFields(t, recipient, "recipient")
+	sum := sha256.Sum256([]byte("ada@example.com" + achievementID.Hex()))
+	assert.Equal(t, "email", recipient["type"])
+	assert.Equal(t, true, recipient["hashed"])
+	assert.Equal(t, "sha256$"+hex.EncodeToString(sum[:]), recipient["identity"])
+	assert.NotContains(t, string(encoded), "Example.com")
+
+	verification := assertion["verification"].(map[string]any)
+	requireOpenBadgesFields(t, verification, "verification")
+	assert.Equal(t, "hosted", verification["type"])
+
+	evidence := assertion["evidence"].([]any)
+	require.Len(t, evidence, 1)
+	requireOpenBadgesFields(t, evidence[0].(map[string]any), "evidence")
+
+	// The hosted copy served for verification matches the export
+	hosted, err := service.GetOpenBadgeAssertion(context.Background(), achievementID)
+	require.NoError(t, err)
+	assert.Equal(t, assertions[0], *hosted)
+
+	// The badge URL resolves to a BadgeClass document
+	badge, err := service.GetOpenBadgeClass(context.Background(), "first_conversation")
+	require.NoError(t, err)
+	encoded, err = json.Marshal(badge)
+	require.NoError(t, err)
+	var badgeClass map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &badgeClass))
+
+	requireOpenBadgesFields(t, badgeClass, "BadgeClass")
+	requireOpenBadgesFields(t, badgeClass["criteria"].(map[string]any), "criteria")
+	assert.Equal(t, assertion["badge"], badgeClass["id"])
+	assert.Equal(t, service.GetOpenBadgeIssuer().ID, badgeClass["issuer"])
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans started by services
+const tracerName = "github.com/sahmaragaev/lunaria-backend/internal/services"
+
+// Logger is the structured logger services report to; *slog.Logger satisfies it
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// ServiceConfig holds the optional dependencies shared by every service. The zero value is ready to use: logging goes
+// to slog.Default(), spans go to the global tracer provider, caching is disabled and every feature flag is on.
+type ServiceConfig struct {
+	Logger Logger
+	Cache  *cache.Cache[any]
+	Tracer trace.Tracer
+	Flags  FeatureFlags
+}
+
+// Option sets an optional dependency shared by every service. Services with settings of their own accept an option
+// type of their own, such as AIContextOption, which every Option also satisfies.
+type Option func(*ServiceConfig)
+
+// WithLogger sends a service's logs to l
+func WithLogger(l Logger) Option {
+	return func(c *ServiceConfig) {
+		c.Logger = l
+	}
+}
+
+// WithCache lets a service cache results in c
+func WithCache(c *cache.Cache[any]) Option {
+	return func(cfg *ServiceConfig) {
+		cfg.Cache = c
+	}
+}
+
+// WithTracer records a service's spans with t
+func WithTracer(t trace.Tracer) Option {
+	return func(c *ServiceConfig) {
+		c.Tracer = t
+	}
+}
+
+// WithFeatureFlags gates a service's shadow-mode and experimental features behind flags
+func WithFeatureFlags(f FeatureFlags) Option {
+	return func(c *ServiceConfig) {
+		c.Flags = f
+	}
+}
+
+// withServiceConfig gives a service the same shared dependencies as another, for services built by other services
+func withServiceConfig(cfg ServiceConfig) Option {
+	return func(c *ServiceConfig) {
+		*c = cfg
+	}
+}
+
+// newServiceConfig applies opts to a zero ServiceConfig
+func newServiceConfig(opts []Option) ServiceConfig {
+	var cfg ServiceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// logger returns the configured logger, or slog.Default() if there is none
+func (c ServiceConfig) logger() Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// tracer returns the configured tracer, or one from the global tracer provider if there is none
+func (c ServiceConfig) tracer() trace.Tracer {
+	if c.Tracer == nil {
+		return otel.Tracer(tracerName)
+	}
+	return c.Tracer
+}
+
+// cached returns the value stored under key, always missing when caching is disabled
+func (c ServiceConfig) cached(key string) (any, bool) {
+	if c.Cache == nil {
+		return nil, false
+	}
+	return c.Cache.Get(key)
+}
+
+// store caches value under key, doing nothing when caching is disabled
+func (c ServiceConfig) store(key string, value any) {
+	if c.Cache != nil {
+		c.Cache.Set(key, value)
+	}
+}
+
+// invalidate drops the value cached under key, doing nothing when caching is disabled
+func (c ServiceConfig) invalidate(key string) {
+	if c.Cache != nil {
+		c.Cache.Invalidate(key)
+	}
+}
+
+// flagEnabled reports whether a feature is on for the user. Without feature flags every feature is on; a flag that
+// cannot be evaluated is treated as off.
+func (c ServiceConfig) flagEnabled(ctx context.Context, flagName, userID string) bool {
+	if c.Flags == nil {
+		return true
+	}
+
+	enabled, err := c.Flags.IsEnabled(ctx, flagName, userID)
+	if err != nil {
+		c.logger().Error("Failed to evaluate feature flag", "flag", flagName, "error", err)
+		return false
+	}
+	return enabled
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/cache"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type failingPurgeStore struct{}
+
+func (failingPurgeStore) PurgeDeletedConversations(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, errors.New("database unavailable")
+}
+
+func TestServicesConstructWithRequiredArgsOnly(t *testing.T) {
+	assert.NotPanics(t, func() {
+		NewABTestingService(nil)
+		NewAIContextService(nil, nil, nil)
+		NewAnalyticsService(nil, nil, nil)
+		NewAuthService(nil, nil, nil)
+		NewCompanionService(nil, nil, nil, nil)
+		NewCompanionReputationJob(nil)
+		NewConversationService(nil, nil)
+		NewConversationArchiveService(nil, nil, "", "", 0)
+		NewConversationIntelligenceService(nil, nil)
+		NewConversationLengthGuard(nil, config.ConversationConfig{})
+		NewConversationPurgeJob(nil, 0)
+		NewDataExportService(nil, nil, nil, nil, nil, "", "", 0)
+		NewFeatureFlagService(nil)
+		NewGamificationService(nil, nil, nil, nil, nil, "")
+		NewGrokService(&config.GrokConfig{})
+		NewHealthAlertService(nil, nil, nil, nil)
+		NewHealthSnapshotJob(nil)
+		NewJWTService(&config.JWTConfig{}, nil)
+		NewMediaServiceWithClient(nil, "", nil, nil, "")
+		NewMemoryDecayJob(nil, 0)
+		NewMessageService(nil, nil, nil, nil, nil, nil)
+		NewMLAnalyticsService(nil, nil, nil)
+		NewContentModerationPipeline(nil, nil)
+		NewNotificationService(nil, nil)
+		NewPasswordService()
+		NewPersonalityService(nil)
+		NewPredictiveAnalyticsService(nil, nil, nil)
+		NewPrivacyAnalyticsService(nil, nil)
+		NewProactiveMessageJob(nil, nil, nil, nil, nil, nil, 0)
+		NewRealTimeAnalyticsService(nil, nil, nil)
+		NewRedisService(&config.RedisConfig{})
+		NewReportService(&config.ReportConfig{}, nil)
+		NewResponseQualityService(nil, nil)
+		NewSafetyEscalator(nil, nil)
+		NewSessionBudgetService(nil)
+		NewSpecialDatesJob(nil)
+		NewStatisticsRollupJob(nil)
+		NewStyleGuideExtractionJob(nil, nil, nil)
+		NewSummaryService(nil, nil)
+		NewTopicPreferenceLearner(nil)
+		NewToxicityClassifier(nil)
+		NewTrustService(nil)
+		NewWebhookService(&config.WebhookConfig{}, nil)
+	})
+}
+
+func TestServiceConfig(t *testing.T) {
+	t.Run("zero value is usable", func(t *testing.T) {
+		var cfg ServiceConfig
+		assert.NotPanics(t, func() {
+			cfg.logger().Info("hello")
+			_, span := cfg.tracer().Start(context.Background(), "test")
+			span.End()
+			cfg.store("key", 1)
+		})
+		_, ok := cfg.cached("key")
+		assert.False(t, ok, "caching is disabled without a cache")
+	})
+
+	t.Run("options set dependencies", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		tracer := noop.NewTracerProvider().Tracer("test")
+
+		cfg := newServiceConfig([]Option{WithLogger(logger), WithCache(cache.New[any]()), WithTracer(tracer)})
+		assert.Equal(t, tracer, cfg.tracer())
+
+		cfg.store("key", 1)
+		value, ok := cfg.cached("key")
+		require.True(t, ok)
+		assert.Equal(t, 1, value)
+
+		cfg.logger().Error("something failed", "error", errors.New("boom"))
+		assert.Contains(t, buf.String(), "something failed")
+		assert.Contains(t, buf.String(), "error=boom")
+	})
+
+	t.Run("services log through the configured logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		job := NewConversationPurgeJob(failingPurgeStore{}, time.Hour, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		job.Start(ctx)
+
+		assert.Contains(t, buf.String(), "Conversation purge job failed")
+	})
+
+	t.Run("service options apply alongside shared options", func(t *testing.T) {
+		c := cache.New[any]()
+		service := NewAIContextService(nil, nil, nil, WithMemoryDecayLambda(0.2), WithReintroductionGap(time.Hour), WithCache(c))
+		assert.Equal(t, 0.2, service.memoryDecayLambda)
+		assert.Equal(t, time.Hour, service.gaps.threshold)
+		assert.Same(t, c, service.options.Cache)
+
+		defaults := NewAIContextService(nil, nil, nil)
+		assert.Equal(t, DefaultReintroductionGap, defaults.gaps.threshold)
+		assert.Equal(t, defaultRetentionDays, NewPrivacyAnalyticsService(nil, nil, WithRetentionDays(0)).defaultRetentionDays)
+	})
+
+	t.Run("grok logs breaker state changes through the configured logger", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		var buf bytes.Buffer
+		grok := NewGrokService(&config.GrokConfig{BaseURL: server.URL, BreakerFailureThreshold: 1},
+			WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+		_, err := grok.SendMessage(context.Background(), []LLMMessage{{Role: "user", Content: "hi"}})
+		require.Error(t, err)
+
+		assert.Contains(t, buf.String(), "circuit breaker state change")
+	})
+
+	t.Run("analytics uses the given engagement normaliser", func(t *testing.T) {
+		normaliser := analytics.NewEngagementNormaliser(time.Minute, 2)
+		service := NewAnalyticsService(nil, nil, nil, WithEngagementNormaliser(normaliser))
+		assert.Same(t, normaliser, service.engagement)
+	})
+}
@@ -7,10 +7,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-type PasswordService struct{}
+type PasswordService struct {
+	options ServiceConfig
+}
 
-func NewPasswordService() *PasswordService {
-	return &PasswordService{}
+func NewPasswordService(opts ...Option) *PasswordService {
+	return &PasswordService{options: newServiceConfig(opts)}
 }
 
 func (p *PasswordService) HashPassword(password string) (string, error) {
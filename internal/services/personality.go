@@ -8,25 +8,29 @@ import (
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
 )
 
 type PersonalityService struct {
-	grokService *GrokService
+	grokService LLMClient
 	validator   *validator.Validate
+
+	options ServiceConfig
 }
 
-func NewPersonalityService(grokService *GrokService) *PersonalityService {
+func NewPersonalityService(grokService LLMClient, opts ...Option) *PersonalityService {
 	return &PersonalityService{
 		grokService: grokService,
 		validator:   validator.New(),
+		options:     newServiceConfig(opts),
 	}
 }
 
 func (p *PersonalityService) GeneratePersonality(ctx context.Context, req *dto.PersonalityGenerationRequest) (*models.CompanionProfile, error) {
 	if err := p.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
+		return nil, apperrors.NewValidationError("validation error", err)
 	}
 	prompt := p.buildPersonalityPrompt(req)
 	messages := []LLMMessage{{Role: "system", Content: prompt}}
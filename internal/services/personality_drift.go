@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+const (
+	// PersonalityDriftEvent is the webhook event sent to admins when a companion's personality drifts
+	PersonalityDriftEvent = "companion.personality_drift"
+
+	// driftSlopeThreshold is the daily fall in personality consistency treated as drift
+	driftSlopeThreshold = -0.01
+	// driftConsistencyFloor is the average consistency below which drifting prompts need retraining
+	driftConsistencyFloor = 0.6
+	// driftMinSamples is the fewest quality analyses a trend is computed from
+	driftMinSamples = 5
+	// driftMaxSamples caps the quality analyses read for one report
+	driftMaxSamples = 1000
+	// maxDriftDays caps the window a drift report covers
+	maxDriftDays = 365
+)
+
+const (
+	DriftActionNone          = "none"
+	DriftActionReviewPrompt  = "review prompt"
+	DriftActionRetrainPrompt = "retrain prompt"
+)
+
+// personalityDriftStore reads a companion's response quality history and stores drift reports
+type personalityDriftStore interface {
+	ListCompanionResponseQuality(ctx context.Context, companionID string, since time.Time, limit int) ([]models.ResponseQuality, error)
+	SavePersonalityDriftReport(ctx context.Context, report *models.DriftReport) error
+}
+
+// adminEventNotifier forwards events to admin webhooks
+type adminEventNotifier interface {
+	DeliverEvent(ctx context.Context, eventType string, event any) error
+}
+
+// DetectPersonalityDrift fits a linear trend to the personality consistency of the companion's responses over the
+// last days days. Consistency falling by more than a point per hundred per day is reported as drift, and admins are
+// notified. Every report is stored, so drift can be tracked over time.
+func (s *ResponseQualityService) DetectPersonalityDrift(ctx context.Context, companionID string, days int) (*models.DriftReport, error) {
+	if days <= 0 || days > maxDriftDays {
+		return nil, apperrors.NewValidationError(fmt.Sprintf("days must be between 1 and %d", maxDriftDays), nil)
+	}
+
+	now := time.Now()
+	qualities, err := s.driftStore.ListCompanionResponseQuality(ctx, companionID, now.AddDate(0, 0, -days), driftMaxSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DriftReport{
+		CompanionID:       companionID,
+		Days:              days,
+		SampleSize:        len(qualities),
+		RecommendedAction: DriftActionNone,
+		CreatedAt:         now,
+	}
+	if len(qualities) > 0 {
+		xs := make([]float64, len(qualities))
+		ys := make([]float64, len(qualities))
+		for i, quality := range qualities {
+			xs[i] = quality.CreatedAt.Sub(qualities[0].CreatedAt).Hours() / 24
+			ys[i] = quality.PersonalityConsistency
+			report.AverageConsistency += quality.PersonalityConsistency
+		}
+		report.AverageConsistency /= float64(len(qualities))
+		report.TrendSlope = linearRegressionSlope(xs, ys)
+	}
+
+	if report.SampleSize >= driftMinSamples && report.TrendSlope <= driftSlopeThreshold {
+		report.DriftDetected = true
+		report.RecommendedAction = DriftActionReviewPrompt
+		if report.AverageConsistency < driftConsistencyFloor {
+			report.RecommendedAction = DriftActionRetrainPrompt
+		}
+	}
+
+	if err := s.driftStore.SavePersonalityDriftReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if report.DriftDetected && s.notifier != nil {
+		if err := s.notifier.DeliverEvent(ctx, PersonalityDriftEvent, report); err != nil {
+			s.options.logger().Error("Failed to notify admins of personality drift", "companion_id", companionID, "error", err)
+		}
+	}
+
+	return report, nil
+}
+
+// linearRegressionSlope returns the least-squares slope of ys against xs, or 0 when xs has no spread
+func linearRegressionSlope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var covariance, variance float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		covariance += dx * (ys[i] - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDriftStore struct {
+	qualities []models.ResponseQuality
+	saved     []*models.DriftReport
+}
+
+func (f *fakeDriftStore) ListCompanionResponseQuality(ctx context.Context, companionID string, since time.Time, limit int) ([]models.ResponseQuality, error) {
+	return f.qualities, nil
+}
+
+func (f *fakeDriftStore) SavePersonalityDriftReport(ctx context.Context, report *models.DriftReport) error {
+	f.saved = append(f.saved, report)
+	return nil
+}
+
+type fakeAdminNotifier struct {
+	events []string
+}
+
+func (f *fakeAdminNotifier) DeliverEvent(ctx context.Context, eventType string, event any) error {
+	f.events = append(f.events, eventType)
+	return nil
+}
+
+// dailyConsistency returns one quality analysis per day with the given personality consistency scores
+func dailyConsistency(scores ...float64) []models.ResponseQuality {
+	start := time.Now().AddDate(0, 0, -len(scores))
+	qualities := make([]models.ResponseQuality, len(scores))
+	for i, score := range scores {
+		qualities[i] = models.ResponseQuality{PersonalityConsistency: score, CreatedAt: start.AddDate(0, 0, i)}
+	}
+	return qualities
+}
+
+func TestDetectPersonalityDrift(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func(qualities []models.ResponseQuality) (*ResponseQualityService, *fakeDriftStore, *fakeAdminNotifier) {
+		store := &fakeDriftStore{qualities: qualities}
+		notifier := &fakeAdminNotifier{}
+		service := NewResponseQualityService(nil, nil, WithDriftNotifier(notifier))
+		service.driftStore = store
+		return service, store, notifier
+	}
+
+	t.Run("improving consistency is not drift", func(t *testing.T) {
+		service, store, notifier := newService(dailyConsistency(0.6, 0.65, 0.7, 0.75, 0.8, 0.85))
+
+		report, err := service.DetectPersonalityDrift(ctx, "companion", 30)
+		require.NoError(t, err)
+		assert.InDelta(t, 0.05, report.TrendSlope, 1e-9)
+		assert.InDelta(t, 0.725, report.AverageConsistency, 1e-9)
+		assert.False(t, report.DriftDetected)
+		assert.Equal(t, DriftActionNone, report.RecommendedAction)
+		assert.Len(t, store.saved, 1)
+		assert.Empty(t, notifier.events)
+	})
+
+	t.Run("falling consistency is reported and notified", func(t *testing.T) {
+		service, store, notifier := newService(dailyConsistency(0.95, 0.9, 0.85, 0.8, 0.75, 0.7))
+
+		report, err := service.DetectPersonalityDrift(ctx, "companion", 30)
+		require.NoError(t, err)
+		assert.InDelta(t, -0.05, report.TrendSlope, 1e-9)
+		assert.True(t, report.DriftDetected)
+		assert.Equal(t, DriftActionReviewPrompt, report.RecommendedAction)
+		assert.Equal(t, 6, report.SampleSize)
+		require.Len(t, store.saved, 1)
+		assert.Equal(t, []string{PersonalityDriftEvent}, notifier.events)
+	})
+
+	t.Run("drift with low consistency needs the prompt retrained", func(t *testing.T) {
+		service, _, _ := newService(dailyConsistency(0.7, 0.6, 0.55, 0.5, 0.4, 0.3))
+
+		report, err := service.DetectPersonalityDrift(ctx, "companion", 30)
+		require.NoError(t, err)
+		assert.True(t, report.DriftDetected)
+		assert.Equal(t, DriftActionRetrainPrompt, report.RecommendedAction)
+	})
+
+	t.Run("too few samples are not drift", func(t *testing.T) {
+		service, _, notifier := newService(dailyConsistency(0.9, 0.5))
+
+		report, err := service.DetectPersonalityDrift(ctx, "companion", 30)
+		require.NoError(t, err)
+		assert.False(t, report.DriftDetected)
+		assert.Empty(t, notifier.events)
+	})
+
+	t.Run("rejects invalid windows", func(t *testing.T) {
+		service, _, _ := newService(nil)
+
+		_, err := service.DetectPersonalityDrift(ctx, "companion", 0)
+		assert.Error(t, err)
+	})
+}
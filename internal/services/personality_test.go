@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePersonalityUsesInjectedLLM(t *testing.T) {
+	llm := &mockLLM{err: errors.New("backend unavailable")}
+	service := NewPersonalityService(llm)
+
+	_, err := service.GeneratePersonality(context.Background(), &dto.PersonalityGenerationRequest{Name: "Ava", Gender: "female", Age: 27})
+	assert.ErrorContains(t, err, "backend unavailable")
+	require.Len(t, llm.prompts, 1)
+	assert.Contains(t, llm.prompts[0][0].Content, "Ava")
+}
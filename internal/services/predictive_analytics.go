@@ -11,16 +11,19 @@ import (
 )
 
 type PredictiveAnalyticsService struct {
-	grokService   *GrokService
+	grokService   LLMClient
 	analyticsRepo *repositories.AnalyticsRepository
 	convRepo      *repositories.ConversationRepository
+
+	options ServiceConfig
 }
 
-func NewPredictiveAnalyticsService(grokService *GrokService, analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository) *PredictiveAnalyticsService {
+func NewPredictiveAnalyticsService(grokService LLMClient, analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, opts ...Option) *PredictiveAnalyticsService {
 	return &PredictiveAnalyticsService{
 		grokService:   grokService,
 		analyticsRepo: analyticsRepo,
 		convRepo:      convRepo,
+		options:       newServiceConfig(opts),
 	}
 }
 
@@ -7,23 +7,77 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // PrivacyAnalyticsService provides privacy-preserving analytics
+// consentAuditLog appends to the append-only record of privacy settings reads and changes
+type consentAuditLog interface {
+	InsertConsentAuditEvent(ctx context.Context, event *models.ConsentAuditEvent) error
+}
+
 type PrivacyAnalyticsService struct {
 	analyticsRepo *repositories.AnalyticsRepository
 	convRepo      *repositories.ConversationRepository
+	auditLog      consentAuditLog
+
+	defaultRetentionDays int
+	// minCohortSize is the k-anonymity threshold, minCohortSize unless replaced in tests
+	minCohortSize int
+	testUsers     TestUserLister
+
+	options ServiceConfig
+}
+
+// defaultRetentionDays is used when no default retention period is configured
+const defaultRetentionDays = 90
+
+// PrivacyAnalyticsOption sets an optional dependency or setting on a PrivacyAnalyticsService. Every Option is a
+// PrivacyAnalyticsOption.
+type PrivacyAnalyticsOption interface {
+	applyPrivacyAnalytics(s *PrivacyAnalyticsService)
+}
+
+// privacyAnalyticsOption adapts a function to PrivacyAnalyticsOption
+type privacyAnalyticsOption func(s *PrivacyAnalyticsService)
+
+func (o privacyAnalyticsOption) applyPrivacyAnalytics(s *PrivacyAnalyticsService) { o(s) }
+
+func (o Option) applyPrivacyAnalytics(s *PrivacyAnalyticsService) { o(&s.options) }
+
+// WithRetentionDays sets the data retention period for users without their own privacy settings; zero keeps
+// defaultRetentionDays
+func WithRetentionDays(days int) PrivacyAnalyticsOption {
+	return privacyAnalyticsOption(func(s *PrivacyAnalyticsService) {
+		if days > 0 {
+			s.defaultRetentionDays = days
+		}
+	})
+}
+
+// WithTestUsers lets insights leave out the accounts l flags as test users
+func WithTestUsers(l TestUserLister) PrivacyAnalyticsOption {
+	return privacyAnalyticsOption(func(s *PrivacyAnalyticsService) {
+		s.testUsers = l
+	})
 }
 
 // NewPrivacyAnalyticsService creates a new privacy analytics service
-func NewPrivacyAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository) *PrivacyAnalyticsService {
-	return &PrivacyAnalyticsService{
-		analyticsRepo: analyticsRepo,
-		convRepo:      convRepo,
-	}
+func NewPrivacyAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, opts ...PrivacyAnalyticsOption) *PrivacyAnalyticsService {
+	s := &PrivacyAnalyticsService{
+		analyticsRepo:        analyticsRepo,
+		convRepo:             convRepo,
+		auditLog:             analyticsRepo,
+		defaultRetentionDays: defaultRetentionDays,
+		minCohortSize:        minCohortSize,
+	}
+	for _, opt := range opts {
+		opt.applyPrivacyAnalytics(s)
+	}
+	return s
 }
 
 // AggregatedInsights represents anonymized, aggregated insights
@@ -78,17 +132,84 @@ type PrivacySettings struct {
 	SharingPreferences   map[string]bool `json:"sharing_preferences"`
 }
 
+// minCohortSize is the k-anonymity threshold below which peer comparisons are suppressed
+const minCohortSize = 10
+
+// PercentileMetric represents a user's raw value and rank among peers for one metric
+type PercentileMetric struct {
+	Value      float64  `json:"value"`
+	Percentile *float64 `json:"percentile,omitempty"`
+	Suppressed bool     `json:"suppressed"`
+}
+
+// PercentileReport represents how a user's engagement compares to anonymised peers
+type PercentileReport struct {
+	UserID           string            `json:"user_id"`
+	SessionFrequency *PercentileMetric `json:"session_frequency"`
+	EngagementScore  *PercentileMetric `json:"engagement_score"`
+	StreakLength     *PercentileMetric `json:"streak_length"`
+	IntimacyLevel    *PercentileMetric `json:"intimacy_level"`
+	// ConversationQuality averages conversation depth, emotional intensity, topic diversity and vulnerability
+	ConversationQuality *PercentileMetric `json:"conversation_quality"`
+	GeneratedAt         time.Time         `json:"generated_at"`
+}
+
+// TestUserLister lists the users flagged as test accounts
+type TestUserLister interface {
+	ListTestUserIDs(ctx context.Context) ([]string, error)
+}
+
+// InsightsOptions narrows the data GetAggregatedInsights aggregates over
+type InsightsOptions struct {
+	// ExcludeTestUsers drops records of users flagged as test accounts; only platform admins may set it
+	ExcludeTestUsers bool
+
+	// testUserIDs are the flagged users, looked up by GetAggregatedInsights when ExcludeTestUsers is set
+	testUserIDs []string
+}
+
+// pipeline prefixes an aggregation pipeline with the filters selected by the options
+func (o InsightsOptions) pipeline(stages []bson.M) []bson.M {
+	if !o.ExcludeTestUsers {
+		return stages
+	}
+	return append([]bson.M{{"$match": bson.M{"user_id": bson.M{"$nin": o.testUserIDs}}}}, stages...)
+}
+
+// resolveInsightsOptions looks up the test users to leave out when opts excludes them. Without a TestUserLister no
+// users are flagged.
+func (s *PrivacyAnalyticsService) resolveInsightsOptions(ctx context.Context, opts InsightsOptions) (InsightsOptions, error) {
+	opts.testUserIDs = []string{}
+	if !opts.ExcludeTestUsers || s.testUsers == nil {
+		return opts, nil
+	}
+
+	ids, err := s.testUsers.ListTestUserIDs(ctx)
+	if err != nil {
+		return opts, err
+	}
+	if ids != nil {
+		opts.testUserIDs = ids
+	}
+	return opts, nil
+}
+
 // GetAggregatedInsights generates privacy-preserving aggregated insights
-func (s *PrivacyAnalyticsService) GetAggregatedInsights(ctx context.Context, period string, privacyLevel string) (*AggregatedInsights, error) {
+func (s *PrivacyAnalyticsService) GetAggregatedInsights(ctx context.Context, period string, privacyLevel string, opts InsightsOptions) (*AggregatedInsights, error) {
 	startTime, endTime := s.getTimeRange(period)
 
+	opts, err := s.resolveInsightsOptions(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get test users: %w", err)
+	}
+
 	insights := &AggregatedInsights{
 		Period:       period,
 		PrivacyLevel: privacyLevel,
 		GeneratedAt:  time.Now(),
 	}
 
-	userCounts, err := s.getAnonymizedUserCounts(ctx, startTime, endTime)
+	userCounts, err := s.getAnonymizedUserCounts(ctx, startTime, endTime, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user counts: %w", err)
 	}
@@ -101,35 +222,35 @@ func (s *PrivacyAnalyticsService) GetAggregatedInsights(ctx context.Context, per
 	}
 
 	// Get average session length (aggregated)
-	avgSession, err := s.getAverageSessionLength(ctx, startTime, endTime)
+	avgSession, err := s.getAverageSessionLength(ctx, startTime, endTime, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get average session length: %w", err)
 	}
 	insights.AverageSession = avgSession
 
 	// Get popular topics (anonymized)
-	topics, err := s.getAnonymizedTopicInsights(ctx, startTime, endTime, privacyLevel)
+	topics, err := s.getAnonymizedTopicInsights(ctx, startTime, endTime, privacyLevel, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get topic insights: %w", err)
 	}
 	insights.PopularTopics = topics
 
 	// Get relationship stage insights
-	stages, err := s.getRelationshipStageInsights(ctx, startTime, endTime)
+	stages, err := s.getRelationshipStageInsights(ctx, startTime, endTime, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stage insights: %w", err)
 	}
 	insights.RelationshipStages = stages
 
 	// Get emotional trends (anonymized)
-	emotions, err := s.getEmotionalTrends(ctx, startTime, endTime, privacyLevel)
+	emotions, err := s.getEmotionalTrends(ctx, startTime, endTime, privacyLevel, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get emotional trends: %w", err)
 	}
 	insights.EmotionalTrends = emotions
 
 	// Get success metrics
-	successMetrics, err := s.getSuccessMetrics(ctx, startTime, endTime)
+	successMetrics, err := s.getSuccessMetrics(ctx, startTime, endTime, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get success metrics: %w", err)
 	}
@@ -168,7 +289,7 @@ type UserCounts struct {
 }
 
 // getAnonymizedUserCounts gets anonymized user count data
-func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, startTime, endTime time.Time) (*UserCounts, error) {
+func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (*UserCounts, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	// Get total unique users in the period
@@ -191,7 +312,7 @@ func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, s
 		},
 	}
 
-	totalCursor, err := collection.Aggregate(ctx, totalPipeline)
+	totalCursor, err := collection.Aggregate(ctx, opts.pipeline(totalPipeline))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total user count: %w", err)
 	}
@@ -229,7 +350,7 @@ func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, s
 		},
 	}
 
-	activeCursor, err := collection.Aggregate(ctx, activePipeline)
+	activeCursor, err := collection.Aggregate(ctx, opts.pipeline(activePipeline))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active user count: %w", err)
 	}
@@ -269,7 +390,7 @@ func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, s
 		},
 	}
 
-	realtimeCursor, err := realtimeCollection.Aggregate(ctx, realtimePipeline)
+	realtimeCursor, err := realtimeCollection.Aggregate(ctx, opts.pipeline(realtimePipeline))
 	if err != nil {
 		// Don't fail if real-time collection doesn't exist or has issues
 		// Just continue with the engagement analytics data
@@ -294,7 +415,7 @@ func (s *PrivacyAnalyticsService) getAnonymizedUserCounts(ctx context.Context, s
 }
 
 // getAverageSessionLength gets average session length (aggregated)
-func (s *PrivacyAnalyticsService) getAverageSessionLength(ctx context.Context, startTime, endTime time.Time) (time.Duration, error) {
+func (s *PrivacyAnalyticsService) getAverageSessionLength(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (time.Duration, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	pipeline := []bson.M{
@@ -323,7 +444,7 @@ func (s *PrivacyAnalyticsService) getAverageSessionLength(ctx context.Context, s
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0, fmt.Errorf("failed to get average session length: %w", err)
 	}
@@ -381,7 +502,7 @@ func (s *PrivacyAnalyticsService) getAverageSessionLength(ctx context.Context, s
 		},
 	}
 
-	realtimeCursor, err := realtimeCollection.Aggregate(ctx, realtimePipeline)
+	realtimeCursor, err := realtimeCollection.Aggregate(ctx, opts.pipeline(realtimePipeline))
 	if err == nil {
 		defer realtimeCursor.Close(ctx)
 
@@ -401,7 +522,7 @@ func (s *PrivacyAnalyticsService) getAverageSessionLength(ctx context.Context, s
 }
 
 // getAnonymizedTopicInsights gets anonymized topic insights
-func (s *PrivacyAnalyticsService) getAnonymizedTopicInsights(ctx context.Context, startTime, endTime time.Time, privacyLevel string) ([]TopicInsight, error) {
+func (s *PrivacyAnalyticsService) getAnonymizedTopicInsights(ctx context.Context, startTime, endTime time.Time, privacyLevel string, opts InsightsOptions) ([]TopicInsight, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	pipeline := []bson.M{
@@ -451,7 +572,7 @@ func (s *PrivacyAnalyticsService) getAnonymizedTopicInsights(ctx context.Context
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get topic insights: %w", err)
 	}
@@ -588,7 +709,7 @@ func (s *PrivacyAnalyticsService) getDefaultTopics() []TopicInsight {
 }
 
 // getRelationshipStageInsights gets relationship stage insights
-func (s *PrivacyAnalyticsService) getRelationshipStageInsights(ctx context.Context, startTime, endTime time.Time) ([]StageInsight, error) {
+func (s *PrivacyAnalyticsService) getRelationshipStageInsights(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) ([]StageInsight, error) {
 	collection := s.analyticsRepo.GetMongoCollection("relationship_analytics")
 
 	// Aggregate pipeline to get stage insights
@@ -629,7 +750,7 @@ func (s *PrivacyAnalyticsService) getRelationshipStageInsights(ctx context.Conte
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get relationship stage insights: %w", err)
 	}
@@ -728,7 +849,7 @@ func (s *PrivacyAnalyticsService) getDefaultStages() []StageInsight {
 }
 
 // getEmotionalTrends gets anonymized emotional trend insights
-func (s *PrivacyAnalyticsService) getEmotionalTrends(ctx context.Context, startTime, endTime time.Time, privacyLevel string) ([]EmotionalInsight, error) {
+func (s *PrivacyAnalyticsService) getEmotionalTrends(ctx context.Context, startTime, endTime time.Time, privacyLevel string, opts InsightsOptions) ([]EmotionalInsight, error) {
 	collection := s.analyticsRepo.GetMongoCollection("sentiment_analytics")
 
 	// Aggregate pipeline to get emotional trends
@@ -772,7 +893,7 @@ func (s *PrivacyAnalyticsService) getEmotionalTrends(ctx context.Context, startT
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get emotional trends: %w", err)
 	}
@@ -894,53 +1015,53 @@ func (s *PrivacyAnalyticsService) getDefaultEmotions() []EmotionalInsight {
 }
 
 // getSuccessMetrics gets success metrics (aggregated)
-func (s *PrivacyAnalyticsService) getSuccessMetrics(ctx context.Context, startTime, endTime time.Time) (map[string]float64, error) {
+func (s *PrivacyAnalyticsService) getSuccessMetrics(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (map[string]float64, error) {
 	metrics := make(map[string]float64)
 
 	// Get user retention rate
-	retentionRate, err := s.getUserRetentionRate(ctx, startTime, endTime)
+	retentionRate, err := s.getUserRetentionRate(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["user_retention_rate"] = retentionRate
 	}
 
 	// Get engagement increase
-	engagementIncrease, err := s.getEngagementIncrease(ctx, startTime, endTime)
+	engagementIncrease, err := s.getEngagementIncrease(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["engagement_increase"] = engagementIncrease
 	}
 
 	// Get relationship success rate
-	relationshipSuccess, err := s.getRelationshipSuccessRate(ctx, startTime, endTime)
+	relationshipSuccess, err := s.getRelationshipSuccessRate(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["relationship_success"] = relationshipSuccess
 	}
 
 	// Get emotional wellbeing score
-	emotionalWellbeing, err := s.getEmotionalWellbeingScore(ctx, startTime, endTime)
+	emotionalWellbeing, err := s.getEmotionalWellbeingScore(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["emotional_wellbeing"] = emotionalWellbeing
 	}
 
 	// Get conversation quality score
-	conversationQuality, err := s.getConversationQualityScore(ctx, startTime, endTime)
+	conversationQuality, err := s.getConversationQualityScore(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["conversation_quality"] = conversationQuality
 	}
 
 	// Get user satisfaction score
-	userSatisfaction, err := s.getUserSatisfactionScore(ctx, startTime, endTime)
+	userSatisfaction, err := s.getUserSatisfactionScore(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["user_satisfaction"] = userSatisfaction
 	}
 
 	// Get feature adoption rate
-	featureAdoption, err := s.getFeatureAdoptionRate(ctx, startTime, endTime)
+	featureAdoption, err := s.getFeatureAdoptionRate(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["feature_adoption"] = featureAdoption
 	}
 
 	// Get community health score
-	communityHealth, err := s.getCommunityHealthScore(ctx, startTime, endTime)
+	communityHealth, err := s.getCommunityHealthScore(ctx, startTime, endTime, opts)
 	if err == nil {
 		metrics["community_health"] = communityHealth
 	}
@@ -953,7 +1074,7 @@ func (s *PrivacyAnalyticsService) getSuccessMetrics(ctx context.Context, startTi
 }
 
 // getUserRetentionRate calculates user retention rate
-func (s *PrivacyAnalyticsService) getUserRetentionRate(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getUserRetentionRate(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	pipeline := []bson.M{
@@ -992,7 +1113,7 @@ func (s *PrivacyAnalyticsService) getUserRetentionRate(ctx context.Context, star
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.87, err // Return default value
 	}
@@ -1022,7 +1143,7 @@ func (s *PrivacyAnalyticsService) getUserRetentionRate(ctx context.Context, star
 }
 
 // getEngagementIncrease calculates engagement increase
-func (s *PrivacyAnalyticsService) getEngagementIncrease(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getEngagementIncrease(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	// Calculate average engagement for current period
@@ -1045,7 +1166,7 @@ func (s *PrivacyAnalyticsService) getEngagementIncrease(ctx context.Context, sta
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, currentPipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(currentPipeline))
 	if err != nil {
 		return 0.23, err
 	}
@@ -1072,7 +1193,7 @@ func (s *PrivacyAnalyticsService) getEngagementIncrease(ctx context.Context, sta
 }
 
 // getRelationshipSuccessRate calculates relationship success rate
-func (s *PrivacyAnalyticsService) getRelationshipSuccessRate(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getRelationshipSuccessRate(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("relationship_analytics")
 
 	pipeline := []bson.M{
@@ -1094,7 +1215,7 @@ func (s *PrivacyAnalyticsService) getRelationshipSuccessRate(ctx context.Context
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.78, err
 	}
@@ -1114,7 +1235,7 @@ func (s *PrivacyAnalyticsService) getRelationshipSuccessRate(ctx context.Context
 }
 
 // getEmotionalWellbeingScore calculates emotional wellbeing score
-func (s *PrivacyAnalyticsService) getEmotionalWellbeingScore(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getEmotionalWellbeingScore(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("sentiment_analytics")
 
 	pipeline := []bson.M{
@@ -1136,7 +1257,7 @@ func (s *PrivacyAnalyticsService) getEmotionalWellbeingScore(ctx context.Context
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.82, err
 	}
@@ -1156,7 +1277,7 @@ func (s *PrivacyAnalyticsService) getEmotionalWellbeingScore(ctx context.Context
 }
 
 // getConversationQualityScore calculates conversation quality score
-func (s *PrivacyAnalyticsService) getConversationQualityScore(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getConversationQualityScore(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("conversation_analytics")
 
 	pipeline := []bson.M{
@@ -1178,7 +1299,7 @@ func (s *PrivacyAnalyticsService) getConversationQualityScore(ctx context.Contex
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.85, err
 	}
@@ -1198,7 +1319,7 @@ func (s *PrivacyAnalyticsService) getConversationQualityScore(ctx context.Contex
 }
 
 // getUserSatisfactionScore calculates user satisfaction score
-func (s *PrivacyAnalyticsService) getUserSatisfactionScore(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getUserSatisfactionScore(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_feedback")
 
 	pipeline := []bson.M{
@@ -1220,7 +1341,7 @@ func (s *PrivacyAnalyticsService) getUserSatisfactionScore(ctx context.Context,
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.89, err
 	}
@@ -1240,7 +1361,7 @@ func (s *PrivacyAnalyticsService) getUserSatisfactionScore(ctx context.Context,
 }
 
 // getFeatureAdoptionRate calculates feature adoption rate
-func (s *PrivacyAnalyticsService) getFeatureAdoptionRate(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getFeatureAdoptionRate(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("feature_usage")
 
 	pipeline := []bson.M{
@@ -1279,7 +1400,7 @@ func (s *PrivacyAnalyticsService) getFeatureAdoptionRate(ctx context.Context, st
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.67, err
 	}
@@ -1309,7 +1430,7 @@ func (s *PrivacyAnalyticsService) getFeatureAdoptionRate(ctx context.Context, st
 }
 
 // getCommunityHealthScore calculates community health score
-func (s *PrivacyAnalyticsService) getCommunityHealthScore(ctx context.Context, startTime, endTime time.Time) (float64, error) {
+func (s *PrivacyAnalyticsService) getCommunityHealthScore(ctx context.Context, startTime, endTime time.Time, opts InsightsOptions) (float64, error) {
 	collection := s.analyticsRepo.GetMongoCollection("user_engagement_analytics")
 
 	pipeline := []bson.M{
@@ -1341,7 +1462,7 @@ func (s *PrivacyAnalyticsService) getCommunityHealthScore(ctx context.Context, s
 		},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, opts.pipeline(pipeline))
 	if err != nil {
 		return 0.91, err
 	}
@@ -1402,8 +1523,52 @@ func (s *PrivacyAnalyticsService) groupAge(age int) string {
 	}
 }
 
-// GetPrivacySettings gets user privacy settings
-func (s *PrivacyAnalyticsService) GetPrivacySettings(ctx context.Context, userID string) (*PrivacySettings, error) {
+// personalisationEnabled reports whether the user allows personalised analysis, defaulting to enabled
+func personalisationEnabled(ctx context.Context, repo *repositories.AnalyticsRepository, userID string) bool {
+	var settings struct {
+		PersonalizationLevel string `bson:"personalization_level"`
+	}
+
+	err := repo.GetMongoCollection("user_privacy_settings").FindOne(ctx, bson.M{"user_id": userID}).Decode(&settings)
+	if err != nil {
+		return true
+	}
+
+	return settings.PersonalizationLevel != "none"
+}
+
+// anonymizationLevel returns the user's anonymization level, defaulting to medium
+func anonymizationLevel(ctx context.Context, repo *repositories.AnalyticsRepository, userID string) string {
+	var settings struct {
+		AnonymizationLevel string `bson:"anonymization_level"`
+	}
+
+	err := repo.GetMongoCollection("user_privacy_settings").FindOne(ctx, bson.M{"user_id": userID}).Decode(&settings)
+	if err != nil || settings.AnonymizationLevel == "" {
+		return "medium"
+	}
+
+	return settings.AnonymizationLevel
+}
+
+// GetPrivacySettings gets user privacy settings, recording the read and the requesting service in the consent audit log
+func (s *PrivacyAnalyticsService) GetPrivacySettings(ctx context.Context, userID, requester string) (*PrivacySettings, error) {
+	settings := s.loadPrivacySettings(ctx, userID)
+
+	event := &models.ConsentAuditEvent{
+		UserID:    userID,
+		Action:    models.ConsentAuditRead,
+		Requester: requester,
+	}
+	if err := s.auditLog.InsertConsentAuditEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to record consent audit event: %w", err)
+	}
+
+	return settings, nil
+}
+
+// loadPrivacySettings reads user privacy settings without auditing, falling back to the defaults
+func (s *PrivacyAnalyticsService) loadPrivacySettings(ctx context.Context, userID string) *PrivacySettings {
 	collection := s.analyticsRepo.GetMongoCollection("user_privacy_settings")
 
 	filter := bson.M{"user_id": userID}
@@ -1416,7 +1581,7 @@ func (s *PrivacyAnalyticsService) GetPrivacySettings(ctx context.Context, userID
 			UserID:               userID,
 			AnalyticsConsent:     true,
 			PersonalizationLevel: "basic",
-			DataRetentionDays:    90,
+			DataRetentionDays:    s.defaultRetentionDays,
 			AnonymizationLevel:   "medium",
 			SharingPreferences: map[string]bool{
 				"aggregated_insights":          true,
@@ -1426,7 +1591,18 @@ func (s *PrivacyAnalyticsService) GetPrivacySettings(ctx context.Context, userID
 		}
 	}
 
-	return &settings, nil
+	return &settings
+}
+
+// auditValues returns the consent fields recorded in the audit log
+func (p *PrivacySettings) auditValues() map[string]any {
+	return map[string]any{
+		"analytics_consent":     p.AnalyticsConsent,
+		"personalization_level": p.PersonalizationLevel,
+		"data_retention_days":   p.DataRetentionDays,
+		"anonymization_level":   p.AnonymizationLevel,
+		"sharing_preferences":   p.SharingPreferences,
+	}
 }
 
 // UpdatePrivacySettings updates user privacy settings
@@ -1445,6 +1621,7 @@ func (s *PrivacyAnalyticsService) UpdatePrivacySettings(ctx context.Context, use
 
 	// Update settings
 	settings.UserID = userID
+	before := s.loadPrivacySettings(ctx, userID)
 
 	// Update in database
 	collection := s.analyticsRepo.GetMongoCollection("user_privacy_settings")
@@ -1467,12 +1644,22 @@ func (s *PrivacyAnalyticsService) UpdatePrivacySettings(ctx context.Context, use
 		return fmt.Errorf("failed to update privacy settings: %w", err)
 	}
 
+	event := &models.ConsentAuditEvent{
+		UserID: userID,
+		Action: models.ConsentAuditWrite,
+		Before: before.auditValues(),
+		After:  settings.auditValues(),
+	}
+	if err := s.auditLog.InsertConsentAuditEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record consent audit event: %w", err)
+	}
+
 	return nil
 }
 
 // DeleteUserData deletes user data based on privacy settings
 func (s *PrivacyAnalyticsService) DeleteUserData(ctx context.Context, userID string) error {
-	settings, err := s.GetPrivacySettings(ctx, userID)
+	settings, err := s.GetPrivacySettings(ctx, userID, "PrivacyAnalyticsService.DeleteUserData")
 	if err != nil {
 		return fmt.Errorf("failed to get privacy settings: %w", err)
 	}
@@ -1497,19 +1684,21 @@ func (s *PrivacyAnalyticsService) DeleteUserData(ctx context.Context, userID str
 	return nil
 }
 
+// retentionCollections are pruned to the user's data retention period. consent_audit_log is never pruned,
+// since the consent trail must outlive the data it covers.
+var retentionCollections = []string{
+	"user_engagement_analytics",
+	"sentiment_analytics",
+	"relationship_analytics",
+	"conversation_analytics",
+	"real_time_metrics",
+	"feature_usage",
+	"user_feedback",
+}
+
 // deleteOldAnalyticsData deletes analytics data older than retention date
 func (s *PrivacyAnalyticsService) deleteOldAnalyticsData(ctx context.Context, userID string, retentionDate time.Time) error {
-	collections := []string{
-		"user_engagement_analytics",
-		"sentiment_analytics",
-		"relationship_analytics",
-		"conversation_analytics",
-		"real_time_metrics",
-		"feature_usage",
-		"user_feedback",
-	}
-
-	for _, collectionName := range collections {
+	for _, collectionName := range retentionCollections {
 		collection := s.analyticsRepo.GetMongoCollection(collectionName)
 
 		filter := bson.M{
@@ -1528,11 +1717,11 @@ func (s *PrivacyAnalyticsService) deleteOldAnalyticsData(ctx context.Context, us
 	return nil
 }
 
-// deleteConversationData deletes conversation data for user
+// deleteConversationData soft-deletes the user's conversations. ConversationPurgeJob removes them
+// for good once the deletion grace period has passed.
 func (s *PrivacyAnalyticsService) deleteConversationData(ctx context.Context, userID string) error {
 	if s.convRepo != nil {
-		err := s.convRepo.DeleteUserConversations(ctx, userID)
-		if err != nil {
+		if _, err := s.convRepo.SoftDeleteUserConversations(ctx, userID); err != nil {
 			return fmt.Errorf("failed to delete conversation data: %w", err)
 		}
 		return nil
@@ -1543,7 +1732,7 @@ func (s *PrivacyAnalyticsService) deleteConversationData(ctx context.Context, us
 
 // GetDataUsageReport gets a report of how user data is being used
 func (s *PrivacyAnalyticsService) GetDataUsageReport(ctx context.Context, userID string) (map[string]any, error) {
-	settings, err := s.GetPrivacySettings(ctx, userID)
+	settings, err := s.GetPrivacySettings(ctx, userID, "PrivacyAnalyticsService.GetDataUsageReport")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get privacy settings: %w", err)
 	}
@@ -1580,3 +1769,125 @@ func (s *PrivacyAnalyticsService) GetDataUsageReport(ctx context.Context, userID
 
 	return report, nil
 }
+
+// GetUserPercentileRank computes the user's percentile rank among consenting peers
+func (s *PrivacyAnalyticsService) GetUserPercentileRank(ctx context.Context, userID string) (*PercentileReport, error) {
+	excluded, err := s.getUsersWithoutConsent(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consent exclusions: %w", err)
+	}
+
+	report := &PercentileReport{
+		UserID:      userID,
+		GeneratedAt: time.Now(),
+	}
+
+	conversationQuality := bson.M{"$avg": bson.A{"$conversation_depth", "$emotional_intensity", "$topic_diversity", "$vulnerability_level"}}
+	metrics := []struct {
+		target     **PercentileMetric
+		collection string
+		name       string
+		value      any
+		accumulate string
+	}{
+		{&report.SessionFrequency, "user_engagement_analytics", "session_frequency", "$session_frequency", "$avg"},
+		{&report.EngagementScore, "user_engagement_analytics", "engagement_score", "$engagement_score", "$avg"},
+		{&report.StreakLength, "user_progress", "current_streak", "$current_streak", "$max"},
+		{&report.IntimacyLevel, "relationship_analytics", "intimacy_level", "$intimacy_level", "$avg"},
+		{&report.ConversationQuality, "user_engagement_analytics", "conversation_quality", conversationQuality, "$avg"},
+	}
+
+	for _, metric := range metrics {
+		result, err := s.getMetricPercentile(ctx, userID, excluded, metric.collection, metric.value, metric.accumulate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s percentile: %w", metric.name, err)
+		}
+		*metric.target = result
+	}
+
+	return report, nil
+}
+
+// getUsersWithoutConsent returns the users who have opted out of analytics
+func (s *PrivacyAnalyticsService) getUsersWithoutConsent(ctx context.Context) ([]any, error) {
+	collection := s.analyticsRepo.GetMongoCollection("user_privacy_settings")
+
+	userIDs, err := collection.Distinct(ctx, "user_id", bson.M{"analytics_consent": false})
+	if err != nil {
+		return nil, err
+	}
+	if userIDs == nil {
+		userIDs = []any{}
+	}
+
+	return userIDs, nil
+}
+
+// getMetricPercentile ranks the user's per-user aggregate of the value expression against the consenting cohort
+func (s *PrivacyAnalyticsService) getMetricPercentile(ctx context.Context, userID string, excluded []any, collectionName string, value any, accumulate string) (*PercentileMetric, error) {
+	collection := s.analyticsRepo.GetMongoCollection(collectionName)
+
+	userPipeline := []bson.M{
+		{"$match": bson.M{"user_id": userID}},
+		{"$group": bson.M{"_id": "$user_id", "value": bson.M{accumulate: value}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, userPipeline)
+	if err != nil {
+		return nil, err
+	}
+	var userResult []struct {
+		Value float64 `bson:"value"`
+	}
+	err = cursor.All(ctx, &userResult)
+	cursor.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metric := &PercentileMetric{}
+	if len(userResult) == 0 {
+		metric.Suppressed = true
+		return metric, nil
+	}
+	metric.Value = userResult[0].Value
+
+	cohortPipeline := []bson.M{
+		{"$match": bson.M{"user_id": bson.M{"$nin": excluded}}},
+		{"$group": bson.M{"_id": "$user_id", "value": bson.M{accumulate: value}}},
+		{
+			"$group": bson.M{
+				"_id":   nil,
+				"total": bson.M{"$sum": 1},
+				"below": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$value", metric.Value}}, 1, 0}}},
+				"equal": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$value", metric.Value}}, 1, 0}}},
+			},
+		},
+	}
+
+	cursor, err = collection.Aggregate(ctx, cohortPipeline)
+	if err != nil {
+		return nil, err
+	}
+	var cohortResult []struct {
+		Total int `bson:"total"`
+		Below int `bson:"below"`
+		Equal int `bson:"equal"`
+	}
+	err = cursor.All(ctx, &cohortResult)
+	cursor.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cohortResult) == 0 || cohortResult[0].Total < s.minCohortSize {
+		metric.Suppressed = true
+		return metric, nil
+	}
+
+	cohort := cohortResult[0]
+	percentile := (float64(cohort.Below) + 0.5*float64(cohort.Equal)) / float64(cohort.Total) * 100
+	metric.Percentile = &percentile
+
+	return metric, nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testUserList is a TestUserLister returning a fixed set of flagged users
+type testUserList []string
+
+func (l testUserList) ListTestUserIDs(ctx context.Context) ([]string, error) {
+	return l, nil
+}
+
+// TestGetAggregatedInsightsExcludeTestUsers runs against a real MongoDB when MONGODB_URI is set
+func TestGetAggregatedInsightsExcludeTestUsers(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("MongoDB not reachable: %v", err)
+	}
+
+	db := client.Database("lunaria_insights_test_" + primitive.NewObjectID().Hex())
+	defer db.Drop(context.Background())
+
+	// One real and one test user recorded engagement in the period
+	now := time.Now()
+	_, err = db.Collection("user_engagement_analytics").InsertMany(ctx, []any{
+		bson.M{"user_id": "real-user", "created_at": now.Add(-time.Hour), "updated_at": now.Add(-time.Hour)},
+		bson.M{"user_id": "test-user", "created_at": now.Add(-time.Hour), "updated_at": now.Add(-time.Hour)},
+	})
+	require.NoError(t, err)
+
+	service := NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, db), nil, WithTestUsers(testUserList{"test-user"}))
+
+	tests := []struct {
+		name string
+		opts InsightsOptions
+		want int
+	}{
+		{name: "all users", opts: InsightsOptions{}, want: 2},
+		{name: "excluding test users", opts: InsightsOptions{ExcludeTestUsers: true}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insights, err := service.GetAggregatedInsights(ctx, "week", "high", tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, insights.TotalUsers)
+			assert.Equal(t, tt.want, insights.ActiveUsers)
+		})
+	}
+}
+
+func TestGetAnonymizedUserCountsExcludeTestUsers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name     string
+		opts     InsightsOptions
+		excluded []string
+	}{
+		{name: "all users", opts: InsightsOptions{}},
+		{name: "excluding test users", opts: InsightsOptions{ExcludeTestUsers: true}, excluded: []string{"test-user"}},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+				mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+				mtest.CreateCursorResponse(0, "lunaria.real_time_metrics", mtest.FirstBatch),
+			)
+
+			service := NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, mt.DB), nil, WithTestUsers(testUserList{"test-user"}))
+			opts, err := service.resolveInsightsOptions(context.Background(), tt.opts)
+			require.NoError(t, err)
+			_, err = service.getAnonymizedUserCounts(context.Background(), time.Now().AddDate(0, 0, -7), time.Now(), opts)
+			require.NoError(t, err)
+
+			// Every aggregation leaves out the users the lister flagged
+			for i := 0; i < 3; i++ {
+				first := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document()
+				nin, err := first.LookupErr("$match", "user_id", "$nin")
+				if tt.excluded == nil {
+					assert.Error(t, err, "aggregation %d", i)
+					continue
+				}
+				require.NoError(t, err, "aggregation %d", i)
+				var ids []string
+				require.NoError(t, nin.Unmarshal(&ids))
+				assert.Equal(t, tt.excluded, ids, "aggregation %d", i)
+			}
+		})
+	}
+}
+
+func TestInsightsOptionsPipeline(t *testing.T) {
+	stages := []bson.M{{"$group": bson.M{"_id": "$user_id"}}}
+
+	assert.Equal(t, stages, InsightsOptions{}.pipeline(stages))
+
+	filtered := InsightsOptions{ExcludeTestUsers: true, testUserIDs: []string{"test-user"}}.pipeline(stages)
+	require.Len(t, filtered, 2)
+	assert.Equal(t, bson.M{"$match": bson.M{"user_id": bson.M{"$nin": []string{"test-user"}}}}, filtered[0])
+	assert.Equal(t, stages[0], filtered[1])
+
+	service := NewPrivacyAnalyticsService(nil, nil)
+	opts, err := service.resolveInsightsOptions(context.Background(), InsightsOptions{ExcludeTestUsers: true})
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$match": bson.M{"user_id": bson.M{"$nin": []string{}}}}, opts.pipeline(stages)[0], "no lister flags no users")
+}
+
+func TestGetUserPercentileRank(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	userValue := func(value float64) bson.D {
+		return bson.D{{Key: "_id", Value: "user"}, {Key: "value", Value: value}}
+	}
+	cohort := func(total, below, equal int32) bson.D {
+		return bson.D{{Key: "_id", Value: nil}, {Key: "total", Value: total}, {Key: "below", Value: below}, {Key: "equal", Value: equal}}
+	}
+	newService := func(mt *mtest.T) *PrivacyAnalyticsService {
+		return NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, mt.DB), nil)
+	}
+
+	mt.Run("counts ties as half below", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, userValue(0.6)),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, cohort(20, 12, 4)),
+		)
+
+		metric, err := newService(mt).getMetricPercentile(context.Background(), "user", []any{"opted-out"}, "user_engagement_analytics", "$engagement_score", "$avg")
+		require.NoError(t, err)
+		assert.Equal(t, 0.6, metric.Value)
+		assert.False(t, metric.Suppressed)
+		require.NotNil(t, metric.Percentile)
+		assert.InDelta(t, 70.0, *metric.Percentile, 1e-9, "(12 + 0.5*4) / 20 * 100")
+
+		mt.GetStartedEvent()
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		excluded, err := match.Lookup("$match", "user_id", "$nin").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, excluded, 1)
+		assert.Equal(t, "opted-out", excluded[0].StringValue(), "users without consent are left out of the cohort")
+	})
+
+	mt.Run("suppresses cohorts smaller than k", func(mt *mtest.T) {
+		// The cohort is counted after the consent exclusion, so opted-out users never make up the numbers
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch, userValue(5)),
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch, cohort(minCohortSize-1, 3, 1)),
+		)
+
+		metric, err := newService(mt).getMetricPercentile(context.Background(), "user", []any{}, "user_progress", "$current_streak", "$max")
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, metric.Value)
+		assert.True(t, metric.Suppressed)
+		assert.Nil(t, metric.Percentile)
+	})
+
+	mt.Run("ranks a cohort of exactly k", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch, userValue(5)),
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch, cohort(minCohortSize, 9, 1)),
+		)
+
+		metric, err := newService(mt).getMetricPercentile(context.Background(), "user", []any{}, "user_progress", "$current_streak", "$max")
+		require.NoError(t, err)
+		require.NotNil(t, metric.Percentile)
+		assert.InDelta(t, 95.0, *metric.Percentile, 1e-9)
+	})
+
+	mt.Run("user with no data", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{}}),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_progress", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.relationship_analytics", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch),
+		)
+
+		report, err := newService(mt).GetUserPercentileRank(context.Background(), "user")
+		require.NoError(t, err)
+		for _, metric := range []*PercentileMetric{report.SessionFrequency, report.EngagementScore, report.StreakLength, report.IntimacyLevel, report.ConversationQuality} {
+			assert.True(t, metric.Suppressed)
+			assert.Nil(t, metric.Percentile)
+			assert.Zero(t, metric.Value)
+		}
+		assert.Len(t, mt.GetAllStartedEvents(), 6, "no cohort is aggregated without the user's own value")
+	})
+}
+
+// memoryConsentAuditLog stores consent audit events in memory, newest last
+type memoryConsentAuditLog struct {
+	events []models.ConsentAuditEvent
+}
+
+func (m *memoryConsentAuditLog) InsertConsentAuditEvent(ctx context.Context, event *models.ConsentAuditEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memoryConsentAuditLog) GetConsentAuditLog(ctx context.Context, userID string, limit int) ([]models.ConsentAuditEvent, error) {
+	var events []models.ConsentAuditEvent
+	for i := len(m.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if m.events[i].UserID == userID {
+			events = append(events, m.events[i])
+		}
+	}
+	return events, nil
+}
+
+func TestPrivacySettingsConsentAuditLog(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("records reads and writes", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch),
+		)
+
+		auditLog := &memoryConsentAuditLog{}
+		service := NewPrivacyAnalyticsService(repositories.NewAnalyticsRepository(nil, mt.DB), nil)
+		service.auditLog = auditLog
+		ctx := context.Background()
+
+		_, err := service.GetPrivacySettings(ctx, "user", "ProfileHandler")
+		require.NoError(t, err)
+
+		err = service.UpdatePrivacySettings(ctx, "user", &PrivacySettings{
+			AnalyticsConsent:     false,
+			PersonalizationLevel: "none",
+			DataRetentionDays:    30,
+			AnonymizationLevel:   "high",
+		})
+		require.NoError(t, err)
+
+		_, err = service.GetPrivacySettings(ctx, "user", "PrivacyAnalyticsService.DeleteUserData")
+		require.NoError(t, err)
+
+		events, err := auditLog.GetConsentAuditLog(ctx, "user", 10)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+
+		assert.Equal(t, models.ConsentAuditRead, events[0].Action)
+		assert.Equal(t, "PrivacyAnalyticsService.DeleteUserData", events[0].Requester)
+
+		write := events[1]
+		assert.Equal(t, models.ConsentAuditWrite, write.Action)
+		assert.Equal(t, true, write.Before["analytics_consent"])
+		assert.Equal(t, 90, write.Before["data_retention_days"])
+		assert.Equal(t, false, write.After["analytics_consent"])
+		assert.Equal(t, 30, write.After["data_retention_days"])
+
+		assert.Equal(t, models.ConsentAuditRead, events[2].Action)
+		assert.Equal(t, "ProfileHandler", events[2].Requester)
+	})
+}
+
+func TestRetentionNeverDeletesConsentAuditLog(t *testing.T) {
+	assert.NotContains(t, retentionCollections, "consent_audit_log")
+}
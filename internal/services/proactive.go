@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultProactiveInactivityThreshold is how long a user must be inactive before the companion reaches out
+	DefaultProactiveInactivityThreshold = 48 * time.Hour
+	// proactiveScanInterval is how often inactive users are looked up
+	proactiveScanInterval = 15 * time.Minute
+	// proactiveDeliveryInterval is how often queued proactive messages are delivered
+	proactiveDeliveryInterval = time.Minute
+)
+
+// proactiveInstruction steers the companion towards reopening a quiet conversation
+const proactiveInstruction = `The user has not written to you in a while. Send them a short, warm message that reaches out first.
+Refer naturally to something from your previous conversations if you can. Do not guilt them for being away and do not mention being an AI.`
+
+// inactivitySource finds sessions whose users have gone quiet
+type inactivitySource interface {
+	ListInactiveRealTimeMetrics(ctx context.Context, cutoff time.Time) ([]models.RealTimeMetrics, error)
+}
+
+// proactiveMessageStore persists queued proactive messages and delivers them into conversations
+type proactiveMessageStore interface {
+	GetConversationByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error)
+	HasProactiveMessage(ctx context.Context, conversationID primitive.ObjectID, inactiveSince time.Time) (bool, error)
+	CreatePendingProactiveMessage(ctx context.Context, msg *models.PendingProactiveMessage) (bool, error)
+	ClaimPendingProactiveMessage(ctx context.Context, now time.Time) (*models.PendingProactiveMessage, error)
+	UpdateProactiveMessageStatus(ctx context.Context, id primitive.ObjectID, status string) error
+	CreateMessage(ctx context.Context, msg *models.Message) (*models.Message, bool, error)
+}
+
+// companionProfileSource loads companion personality profiles
+type companionProfileSource interface {
+	GetProfile(ctx context.Context, companionID string) (*models.CompanionProfile, error)
+}
+
+// promptBuilder builds the layered system prompt for a conversation
+type promptBuilder interface {
+	BuildDynamicPrompt(ctx context.Context, conversation *models.Conversation, userMsg *models.Message, companionProfile *models.CompanionProfile) (string, error)
+}
+
+// ProactiveMessageJob lets companions reach out to users who have been inactive for a while. Messages queued during
+// a user's quiet hours are held back until the quiet hours end.
+type ProactiveMessageJob struct {
+	metrics    inactivitySource
+	store      proactiveMessageStore
+	profiles   companionProfileSource
+	prompts    promptBuilder
+	llm        LLMClient
+	quietHours quietHoursSource
+	threshold  time.Duration
+	now        func() time.Time
+	after      func(time.Duration) <-chan time.Time
+
+	options ServiceConfig
+}
+
+// NewProactiveMessageJob creates a new proactive message job. A non-positive threshold uses the 48 hour default.
+func NewProactiveMessageJob(metrics inactivitySource, store proactiveMessageStore, profiles companionProfileSource, prompts promptBuilder, llm LLMClient, quietHours quietHoursSource, threshold time.Duration, opts ...Option) *ProactiveMessageJob {
+	if threshold <= 0 {
+		threshold = DefaultProactiveInactivityThreshold
+	}
+
+	return &ProactiveMessageJob{
+		metrics:    metrics,
+		store:      store,
+		profiles:   profiles,
+		prompts:    prompts,
+		llm:        llm,
+		quietHours: quietHours,
+		threshold:  threshold,
+		now:        time.Now,
+		after:      time.After,
+		options:    newServiceConfig(opts),
+	}
+}
+
+// Start queues proactive messages and delivers them in the background until ctx is cancelled
+func (j *ProactiveMessageJob) Start(ctx context.Context) {
+	go j.deliverLoop(ctx)
+
+	for {
+		if err := j.QueueProactiveMessages(ctx); err != nil {
+			j.options.logger().Error("Proactive message job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.after(proactiveScanInterval):
+		}
+	}
+}
+
+// deliverLoop polls for queued proactive messages until ctx is cancelled
+func (j *ProactiveMessageJob) deliverLoop(ctx context.Context) {
+	for {
+		if _, err := j.DeliverPendingMessages(ctx); err != nil {
+			j.options.logger().Error("Proactive message delivery failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.after(proactiveDeliveryInterval):
+		}
+	}
+}
+
+// QueueProactiveMessages generates a proactive message for every session inactive for longer than the threshold
+func (j *ProactiveMessageJob) QueueProactiveMessages(ctx context.Context) error {
+	inactive, err := j.metrics.ListInactiveRealTimeMetrics(ctx, j.now().Add(-j.threshold))
+	if err != nil {
+		return fmt.Errorf("failed to list inactive users: %w", err)
+	}
+
+	for _, session := range inactive {
+		if err := j.queueForSession(ctx, session); err != nil {
+			j.options.logger().Error("Failed to queue proactive message", "user_id", session.UserID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// queueForSession generates and stores one proactive message for the session's current inactivity window
+func (j *ProactiveMessageJob) queueForSession(ctx context.Context, session models.RealTimeMetrics) error {
+	// The metrics timestamp marks the start of the inactivity window
+	exists, err := j.store.HasProactiveMessage(ctx, session.ConversationID, session.Timestamp)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	conversation, err := j.store.GetConversationByID(ctx, session.ConversationID)
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if conversation.Archived {
+		return nil
+	}
+
+	profile, err := j.profiles.GetProfile(ctx, conversation.CompanionID)
+	if err != nil {
+		return fmt.Errorf("failed to get companion profile: %w", err)
+	}
+
+	text, err := j.generateMessage(ctx, conversation, profile)
+	if err != nil {
+		return err
+	}
+
+	deliverAfter, err := j.deliverAfter(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.store.CreatePendingProactiveMessage(ctx, &models.PendingProactiveMessage{
+		UserID:         session.UserID,
+		CompanionID:    conversation.CompanionID,
+		ConversationID: conversation.ID,
+		InactiveSince:  session.Timestamp,
+		Text:           text,
+		DeliverAfter:   deliverAfter,
+	})
+	return err
+}
+
+// deliverAfter returns when quiet hours the user is in now end, or nil when the message can be delivered right away
+func (j *ProactiveMessageJob) deliverAfter(ctx context.Context, userID string) (*time.Time, error) {
+	if j.quietHours == nil {
+		return nil, nil
+	}
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID %q: %w", userID, err)
+	}
+	quietHours, err := j.quietHours.GetQuietHours(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiet hours: %w", err)
+	}
+
+	now := j.now()
+	if quietHours == nil || !IsQuietHour(quietHours.Timezone, quietHours.StartHour, quietHours.EndHour, now) {
+		return nil, nil
+	}
+	end := quietHoursEnd(quietHours.Timezone, quietHours.EndHour, now)
+	return &end, nil
+}
+
+// generateMessage asks the LLM for a proactive opener in the companion's voice
+func (j *ProactiveMessageJob) generateMessage(ctx context.Context, conversation *models.Conversation, profile *models.CompanionProfile) (string, error) {
+	// There is no user message to react to, so the prompt is built from a text-less system message
+	trigger := &models.Message{
+		ID:             primitive.NewObjectID(),
+		ConversationID: conversation.ID,
+		SenderID:       conversation.UserID,
+		SenderType:     sendertype.System,
+		Type:           messagetype.System,
+		CreatedAt:      j.now(),
+	}
+
+	prompt, err := j.prompts.BuildDynamicPrompt(ctx, conversation, trigger, profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to build dynamic prompt: %w", err)
+	}
+
+	response, err := j.llm.SendMessage(ctx, []LLMMessage{
+		{Role: "system", Content: prompt},
+		{Role: "system", Content: proactiveInstruction},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate proactive message: %w", err)
+	}
+
+	text := strings.TrimSpace(response)
+	if text == "" {
+		return "", fmt.Errorf("empty proactive message")
+	}
+
+	return text, nil
+}
+
+// DeliverPendingMessages posts every queued proactive message into its conversation and returns how many were delivered
+func (j *ProactiveMessageJob) DeliverPendingMessages(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		pending, err := j.store.ClaimPendingProactiveMessage(ctx, j.now())
+		if err != nil {
+			return delivered, err
+		}
+		if pending == nil {
+			return delivered, nil
+		}
+
+		text := pending.Text
+		_, _, err = j.store.CreateMessage(ctx, &models.Message{
+			ConversationID: pending.ConversationID,
+			SenderID:       pending.CompanionID,
+			SenderType:     sendertype.Companion,
+			Type:           messagetype.Text,
+			Text:           &text,
+			TotalMessages:  1,
+		})
+		if err != nil {
+			// Hand the message back so the next poll retries it
+			if statusErr := j.store.UpdateProactiveMessageStatus(ctx, pending.ID, models.ProactiveStatusPending); statusErr != nil {
+				j.options.logger().Error("Failed to release proactive message", "message_id", pending.ID.Hex(), "error", statusErr)
+			}
+			return delivered, fmt.Errorf("failed to deliver proactive message: %w", err)
+		}
+
+		if err := j.store.UpdateProactiveMessageStatus(ctx, pending.ID, models.ProactiveStatusDelivered); err != nil {
+			j.options.logger().Error("Failed to mark proactive message delivered", "message_id", pending.ID.Hex(), "error", err)
+		}
+		delivered++
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeInactivitySource struct {
+	sessions []models.RealTimeMetrics
+}
+
+func (f *fakeInactivitySource) ListInactiveRealTimeMetrics(ctx context.Context, cutoff time.Time) ([]models.RealTimeMetrics, error) {
+	var inactive []models.RealTimeMetrics
+	for _, session := range f.sessions {
+		if !session.IsActive && session.Timestamp.Before(cutoff) {
+			inactive = append(inactive, session)
+		}
+	}
+	return inactive, nil
+}
+
+type fakeProactiveStore struct {
+	mu            sync.Mutex
+	conversations map[primitive.ObjectID]*models.Conversation
+	pending       []*models.PendingProactiveMessage
+	messages      []*models.Message
+	createErr     error
+}
+
+func (f *fakeProactiveStore) GetConversationByID(ctx context.Context, id primitive.ObjectID) (*models.Conversation, error) {
+	return f.conversations[id], nil
+}
+
+func (f *fakeProactiveStore) HasProactiveMessage(ctx context.Context, conversationID primitive.ObjectID, inactiveSince time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range f.pending {
+		if msg.ConversationID == conversationID && msg.InactiveSince.Equal(inactiveSince) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeProactiveStore) CreatePendingProactiveMessage(ctx context.Context, msg *models.PendingProactiveMessage) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg.ID = primitive.NewObjectID()
+	msg.Status = models.ProactiveStatusPending
+	f.pending = append(f.pending, msg)
+	return true, nil
+}
+
+func (f *fakeProactiveStore) ClaimPendingProactiveMessage(ctx context.Context, now time.Time) (*models.PendingProactiveMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range f.pending {
+		if msg.Status == models.ProactiveStatusPending && (msg.DeliverAfter == nil || !msg.DeliverAfter.After(now)) {
+			msg.Status = models.ProactiveStatusDelivering
+			return msg, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeProactiveStore) UpdateProactiveMessageStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range f.pending {
+		if msg.ID == id {
+			msg.Status = status
+		}
+	}
+	return nil
+}
+
+func (f *fakeProactiveStore) CreateMessage(ctx context.Context, msg *models.Message) (*models.Message, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.createErr != nil {
+		return nil, false, f.createErr
+	}
+	f.messages = append(f.messages, msg)
+	return msg, false, nil
+}
+
+func (f *fakeProactiveStore) snapshot() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending), len(f.messages)
+}
+
+type fakeProfiles struct{}
+
+func (fakeProfiles) GetProfile(ctx context.Context, companionID string) (*models.CompanionProfile, error) {
+	return &models.CompanionProfile{CompanionID: companionID}, nil
+}
+
+type fakePromptBuilder struct {
+	triggers []*models.Message
+}
+
+func (f *fakePromptBuilder) BuildDynamicPrompt(ctx context.Context, conversation *models.Conversation, userMsg *models.Message, companionProfile *models.CompanionProfile) (string, error) {
+	f.triggers = append(f.triggers, userMsg)
+	return "You are Luna.", nil
+}
+
+// mockTimer hands out one channel per interval so tests decide when each loop wakes up
+type mockTimer struct {
+	mu      sync.Mutex
+	waiting map[time.Duration]chan chan time.Time
+}
+
+func newMockTimer() *mockTimer {
+	return &mockTimer{waiting: make(map[time.Duration]chan chan time.Time)}
+}
+
+func (m *mockTimer) queue(d time.Duration) chan chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.waiting[d] == nil {
+		m.waiting[d] = make(chan chan time.Time, 1)
+	}
+	return m.waiting[d]
+}
+
+func (m *mockTimer) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	m.queue(d) <- ch
+	return ch
+}
+
+// Fire waits until a loop is sleeping for d and wakes it up
+func (m *mockTimer) Fire(t *testing.T, d time.Duration) {
+	select {
+	case ch := <-m.queue(d):
+		ch <- time.Now()
+	case <-time.After(time.Second):
+		t.Fatalf("nothing waiting on a %s timer", d)
+	}
+}
+
+func TestProactiveMessageJob(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	conversation := &models.Conversation{ID: primitive.NewObjectID(), UserID: "user-1", CompanionID: "companion-1"}
+	recent := &models.Conversation{ID: primitive.NewObjectID(), UserID: "user-2", CompanionID: "companion-1"}
+
+	newJob := func(store *fakeProactiveStore, llm *mockLLM, prompts *fakePromptBuilder) *ProactiveMessageJob {
+		metrics := &fakeInactivitySource{sessions: []models.RealTimeMetrics{
+			{UserID: "user-1", ConversationID: conversation.ID, Timestamp: now.Add(-72 * time.Hour)},
+			{UserID: "user-2", ConversationID: recent.ID, Timestamp: now.Add(-2 * time.Hour)},
+			{UserID: "user-3", ConversationID: primitive.NewObjectID(), IsActive: true, Timestamp: now.Add(-96 * time.Hour)},
+		}}
+		job := NewProactiveMessageJob(metrics, store, fakeProfiles{}, prompts, llm, nil, 0)
+		job.now = func() time.Time { return now }
+		return job
+	}
+
+	newStore := func() *fakeProactiveStore {
+		return &fakeProactiveStore{conversations: map[primitive.ObjectID]*models.Conversation{
+			conversation.ID: conversation,
+			recent.ID:       recent,
+		}}
+	}
+
+	t.Run("queues one message per inactivity window", func(t *testing.T) {
+		store := newStore()
+		llm := &mockLLM{response: " Hey, I was just thinking about your trip! "}
+		prompts := &fakePromptBuilder{}
+		job := newJob(store, llm, prompts)
+
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+
+		require.Len(t, store.pending, 1)
+		assert.Equal(t, conversation.ID, store.pending[0].ConversationID)
+		assert.Equal(t, now.Add(-72*time.Hour), store.pending[0].InactiveSince)
+		assert.Equal(t, "Hey, I was just thinking about your trip!", store.pending[0].Text)
+
+		require.Len(t, llm.prompts, 1)
+		assert.Equal(t, "You are Luna.", llm.prompts[0][0].Content)
+		assert.Equal(t, proactiveInstruction, llm.prompts[0][1].Content)
+		require.Len(t, prompts.triggers, 1)
+		assert.Nil(t, prompts.triggers[0].Text)
+	})
+
+	t.Run("a new inactivity window gets a new message", func(t *testing.T) {
+		store := newStore()
+		job := newJob(store, &mockLLM{response: "Miss you!"}, &fakePromptBuilder{})
+
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+		job.metrics.(*fakeInactivitySource).sessions[0].Timestamp = now.Add(-50 * time.Hour)
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+
+		assert.Len(t, store.pending, 2)
+	})
+
+	t.Run("delivers queued messages as the companion", func(t *testing.T) {
+		store := newStore()
+		job := newJob(store, &mockLLM{response: "Miss you!"}, &fakePromptBuilder{})
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+
+		delivered, err := job.DeliverPendingMessages(context.Background())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		require.Len(t, store.messages, 1)
+		assert.Equal(t, sendertype.Companion, store.messages[0].SenderType)
+		assert.Equal(t, "companion-1", store.messages[0].SenderID)
+		assert.Equal(t, "Miss you!", *store.messages[0].Text)
+		assert.Equal(t, models.ProactiveStatusDelivered, store.pending[0].Status)
+
+		delivered, err = job.DeliverPendingMessages(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, delivered)
+	})
+
+	t.Run("failed delivery is retried", func(t *testing.T) {
+		store := newStore()
+		job := newJob(store, &mockLLM{response: "Miss you!"}, &fakePromptBuilder{})
+		require.NoError(t, job.QueueProactiveMessages(context.Background()))
+
+		store.createErr = errors.New("mongo unavailable")
+		_, err := job.DeliverPendingMessages(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, models.ProactiveStatusPending, store.pending[0].Status)
+
+		store.createErr = nil
+		delivered, err := job.DeliverPendingMessages(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+	})
+
+	t.Run("start scans and delivers on timer ticks", func(t *testing.T) {
+		store := newStore()
+		llm := &mockLLM{response: "Miss you!"}
+		job := newJob(store, llm, &fakePromptBuilder{})
+		timer := newMockTimer()
+		job.after = timer.After
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go job.Start(ctx)
+
+		// Both loops run once immediately and then sleep on the mock timer
+		timer.Fire(t, proactiveScanInterval)
+		timer.Fire(t, proactiveDeliveryInterval)
+		timer.Fire(t, proactiveDeliveryInterval)
+
+		pending, delivered := store.snapshot()
+		assert.Equal(t, 1, pending)
+		assert.Equal(t, 1, delivered)
+	})
+}
+
+type fakeQuietHours map[uuid.UUID]*models.QuietHours
+
+func (f fakeQuietHours) GetQuietHours(ctx context.Context, userID uuid.UUID) (*models.QuietHours, error) {
+	return f[userID], nil
+}
+
+func TestProactiveMessageJobQuietHours(t *testing.T) {
+	// 23:30 in Berlin, inside the user's 22:00-07:00 quiet hours
+	now := time.Date(2025, 6, 10, 21, 30, 0, 0, time.UTC)
+	userID := uuid.New()
+	conversation := &models.Conversation{ID: primitive.NewObjectID(), UserID: userID.String(), CompanionID: "companion-1"}
+	store := &fakeProactiveStore{conversations: map[primitive.ObjectID]*models.Conversation{conversation.ID: conversation}}
+	metrics := &fakeInactivitySource{sessions: []models.RealTimeMetrics{
+		{UserID: userID.String(), ConversationID: conversation.ID, Timestamp: now.Add(-72 * time.Hour)},
+	}}
+	quietHours := fakeQuietHours{userID: {StartHour: 22, EndHour: 7, Timezone: "Europe/Berlin"}}
+
+	job := NewProactiveMessageJob(metrics, store, fakeProfiles{}, &fakePromptBuilder{}, &mockLLM{response: "Miss you!"}, quietHours, 0)
+	job.now = func() time.Time { return now }
+
+	require.NoError(t, job.QueueProactiveMessages(context.Background()))
+	require.Len(t, store.pending, 1)
+	require.NotNil(t, store.pending[0].DeliverAfter)
+	assert.True(t, time.Date(2025, 6, 11, 5, 0, 0, 0, time.UTC).Equal(*store.pending[0].DeliverAfter), "07:00 Berlin time")
+
+	delivered, err := job.DeliverPendingMessages(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, delivered, "held back during quiet hours")
+
+	now = *store.pending[0].DeliverAfter
+	delivered, err = job.DeliverPendingMessages(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+}
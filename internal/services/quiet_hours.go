@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// quietHoursSource loads the quiet hours users have set, implemented by *repositories.UserRepository
+type quietHoursSource interface {
+	GetQuietHours(ctx context.Context, userID uuid.UUID) (*models.QuietHours, error)
+}
+
+// IsQuietHour reports whether now falls within the quiet hours from startHour up to endHour (0-23) in userTimezone.
+// A window whose start is after its end spans midnight, so 22 to 7 is quiet from 22:00 until 06:59. Equal hours mean
+// no quiet hours. An empty or unknown time zone is treated as UTC.
+func IsQuietHour(userTimezone string, startHour, endHour int, now time.Time) bool {
+	if startHour == endHour {
+		return false
+	}
+
+	hour := now.In(loadLocationOrUTC(userTimezone)).Hour()
+	if startHour < endHour {
+		return hour >= startHour && hour < endHour
+	}
+	return hour >= startHour || hour < endHour
+}
+
+// quietHoursEnd returns the first minute after the quiet hours containing now, which is the next endHour:00 in
+// userTimezone
+func quietHoursEnd(userTimezone string, endHour int, now time.Time) time.Time {
+	local := now.In(loadLocationOrUTC(userTimezone))
+	end := time.Date(local.Year(), local.Month(), local.Day(), endHour, 0, 0, 0, local.Location())
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// loadLocationOrUTC loads an IANA time zone, falling back to UTC for names that are empty or can no longer be loaded
+func loadLocationOrUTC(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
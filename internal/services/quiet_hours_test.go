@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsQuietHour(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2025, 6, 10, hour, minute, 0, 0, time.UTC) }
+
+	for _, tc := range []struct {
+		name       string
+		timezone   string
+		start, end int
+		now        time.Time
+		quiet      bool
+	}{
+		{name: "before midnight-spanning window", start: 22, end: 7, now: at(21, 59), quiet: false},
+		{name: "start of midnight-spanning window", start: 22, end: 7, now: at(22, 0), quiet: true},
+		{name: "midnight", start: 22, end: 7, now: at(0, 0), quiet: true},
+		{name: "end of midnight-spanning window", start: 22, end: 7, now: at(6, 59), quiet: true},
+		{name: "after midnight-spanning window", start: 22, end: 7, now: at(7, 0), quiet: false},
+		{name: "afternoon window", start: 13, end: 15, now: at(14, 30), quiet: true},
+		{name: "outside afternoon window", start: 13, end: 15, now: at(12, 0), quiet: false},
+		{name: "equal hours", start: 8, end: 8, now: at(8, 30), quiet: false},
+		{name: "user time zone", timezone: "America/New_York", start: 22, end: 7, now: at(3, 0), quiet: true},
+		{name: "user time zone outside window", timezone: "America/New_York", start: 22, end: 7, now: at(12, 0), quiet: false},
+		{name: "unknown time zone is UTC", timezone: "Mars/Olympus", start: 22, end: 7, now: at(23, 0), quiet: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.quiet, IsQuietHour(tc.timezone, tc.start, tc.end, tc.now))
+		})
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2025, 6, 11, 7, 0, 0, 0, berlin), quietHoursEnd("Europe/Berlin", 7, time.Date(2025, 6, 10, 23, 30, 0, 0, berlin)))
+	assert.Equal(t, time.Date(2025, 6, 10, 7, 0, 0, 0, berlin), quietHoursEnd("Europe/Berlin", 7, time.Date(2025, 6, 10, 2, 0, 0, 0, berlin)))
+}
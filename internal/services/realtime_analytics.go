@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"time"
 
@@ -15,7 +14,7 @@ import (
 type RealTimeAnalyticsService struct {
 	analyticsRepo *repositories.AnalyticsRepository
 	convRepo      *repositories.ConversationRepository
-	grokService   *GrokService
+	grokService   LLMClient
 
 	// Real-time processing
 	eventStream chan *AnalyticsEvent
@@ -24,6 +23,8 @@ type RealTimeAnalyticsService struct {
 
 	// Performance monitoring
 	processingStats *ProcessingStats
+
+	options ServiceConfig
 }
 
 // AnalyticsEvent represents a real-time analytics event
@@ -53,7 +54,7 @@ type ProcessingStats struct {
 }
 
 // NewRealTimeAnalyticsService creates a new real-time analytics service
-func NewRealTimeAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, grokService *GrokService) *RealTimeAnalyticsService {
+func NewRealTimeAnalyticsService(analyticsRepo *repositories.AnalyticsRepository, convRepo *repositories.ConversationRepository, grokService LLMClient, opts ...Option) *RealTimeAnalyticsService {
 	service := &RealTimeAnalyticsService{
 		analyticsRepo:   analyticsRepo,
 		convRepo:        convRepo,
@@ -61,6 +62,7 @@ func NewRealTimeAnalyticsService(analyticsRepo *repositories.AnalyticsRepository
 		eventStream:     make(chan *AnalyticsEvent, 1000),
 		processors:      make(map[string]EventProcessor),
 		processingStats: &ProcessingStats{},
+		options:         newServiceConfig(opts),
 	}
 
 	// Register event processors
@@ -111,7 +113,7 @@ func (s *RealTimeAnalyticsService) processEvent(ctx context.Context, event *Anal
 
 	if err != nil {
 		// Log error but don't block processing
-		fmt.Printf("Error processing event %s: %v\n", event.Type, err)
+		s.options.logger().Error("Error processing event", "event_type", event.Type, "error", err)
 	}
 }
 
@@ -148,7 +150,7 @@ func (s *RealTimeAnalyticsService) EmitEvent(event *AnalyticsEvent) {
 	case s.eventStream <- event:
 	default:
 		// Channel full, log warning
-		fmt.Printf("Warning: Event stream full, dropping event %s\n", event.Type)
+		s.options.logger().Warn("Event stream full, dropping event", "event_type", event.Type)
 	}
 }
 
@@ -264,8 +266,12 @@ func (s *RealTimeAnalyticsService) GetProcessingStats() *ProcessingStats {
 	s.processingStats.mu.RLock()
 	defer s.processingStats.mu.RUnlock()
 
-	stats := *s.processingStats
-	return &stats
+	return &ProcessingStats{
+		EventsProcessed:    s.processingStats.EventsProcessed,
+		AverageProcessTime: s.processingStats.AverageProcessTime,
+		ErrorCount:         s.processingStats.ErrorCount,
+		LastProcessed:      s.processingStats.LastProcessed,
+	}
 }
 
 // GetActiveSessions gets currently active sessions
@@ -11,9 +11,11 @@ import (
 
 type RedisService struct {
 	client *redis.Client
+
+	options ServiceConfig
 }
 
-func NewRedisService(cfg *config.RedisConfig) *RedisService {
+func NewRedisService(cfg *config.RedisConfig, opts ...Option) *RedisService {
 	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password: "", // no password set
@@ -21,7 +23,8 @@ func NewRedisService(cfg *config.RedisConfig) *RedisService {
 	})
 
 	return &RedisService{
-		client: client,
+		client:  client,
+		options: newServiceConfig(opts),
 	}
 }
 
@@ -59,6 +62,31 @@ func (r *RedisService) DeleteUserSession(ctx context.Context, userID string) err
 	return r.client.Del(ctx, key).Err()
 }
 
+// IncrementCounter increments a counter and sets its expiration when it is first created
+func (r *RedisService) IncrementCounter(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// GetCounter returns the current value of a counter, or zero if it does not exist
+func (r *RedisService) GetCounter(ctx context.Context, key string) (int64, error) {
+	count, err := r.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// DecrementCounter decrements a counter
+func (r *RedisService) DecrementCounter(ctx context.Context, key string) error {
+	return r.client.Decr(ctx, key).Err()
+}
+
 // Close closes the Redis connection
 func (r *RedisService) Close() error {
 	return r.client.Close()
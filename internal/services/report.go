@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+)
+
+//go:embed templates/relationship_health_report.html
+var defaultRelationshipHealthTemplate string
+
+const (
+	reportMaxStrengths = 5
+	reportGaugeWidth   = 20
+	reportTrendDays    = 30
+)
+
+// ReportService renders user-facing PDF reports
+type ReportService struct {
+	analyticsRepo *repositories.AnalyticsRepository
+	templatePath  string
+
+	options ServiceConfig
+}
+
+// NewReportService creates a new report service
+func NewReportService(cfg *config.ReportConfig, analyticsRepo *repositories.AnalyticsRepository, opts ...Option) *ReportService {
+	return &ReportService{
+		analyticsRepo: analyticsRepo,
+		templatePath:  cfg.TemplatePath,
+		options:       newServiceConfig(opts),
+	}
+}
+
+// RelationshipHealthReportData is the data passed to the relationship health report template
+type RelationshipHealthReportData struct {
+	UserID              string
+	CompanionID         string
+	Stage               string
+	HealthPercent       float64
+	HealthGauge         string
+	Strengths           []string
+	StrengthCount       int
+	StrengthsHidden     bool
+	RedFlags            []string
+	RedFlagCount        int
+	RedFlagsHidden      bool
+	EngagementTrend     []models.EngagementTrendPoint
+	EngagementSparkline string
+	GeneratedAt         time.Time
+}
+
+// GenerateRelationshipHealthReport renders the relationship health report as a PDF
+func (s *ReportService) GenerateRelationshipHealthReport(ctx context.Context, userID, companionID string) ([]byte, error) {
+	relationship, err := s.analyticsRepo.GetRelationshipAnalytics(ctx, userID, companionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relationship analytics: %w", err)
+	}
+
+	trend, err := s.analyticsRepo.GetEngagementTrends(ctx, userID, companionID, reportTrendDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get engagement trends: %w", err)
+	}
+
+	data := buildRelationshipHealthReportData(userID, companionID, relationship, trend, anonymizationLevel(ctx, s.analyticsRepo, userID))
+
+	tmpl, err := s.loadTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render report template: %w", err)
+	}
+
+	return renderHTMLToPDF(rendered.String())
+}
+
+// loadTemplate parses the configured template, falling back to the embedded default
+func (s *ReportService) loadTemplate() (*template.Template, error) {
+	if s.templatePath != "" {
+		tmpl, err := template.ParseFiles(s.templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse report template: %w", err)
+		}
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New("relationship_health_report").Parse(defaultRelationshipHealthTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// buildRelationshipHealthReportData assembles template data, hiding fields according to the anonymization level
+func buildRelationshipHealthReportData(userID, companionID string, relationship *models.RelationshipAnalytics, trend []models.EngagementTrendPoint, anonymization string) *RelationshipHealthReportData {
+	health := math.Max(0, math.Min(1, relationship.HealthScore))
+
+	data := &RelationshipHealthReportData{
+		UserID:              userID,
+		CompanionID:         companionID,
+		Stage:               strings.ReplaceAll(relationship.CurrentStage, "_", " "),
+		HealthPercent:       health * 100,
+		HealthGauge:         healthGauge(health),
+		Strengths:           relationship.Strengths,
+		StrengthCount:       len(relationship.Strengths),
+		RedFlags:            relationship.RedFlags,
+		RedFlagCount:        len(relationship.RedFlags),
+		EngagementTrend:     trend,
+		EngagementSparkline: sparkline(trend),
+		GeneratedAt:         time.Now(),
+	}
+	if data.Stage == "" {
+		data.Stage = strings.ReplaceAll(InitialRelationshipStage, "_", " ")
+	}
+	if len(data.Strengths) > reportMaxStrengths {
+		data.Strengths = data.Strengths[:reportMaxStrengths]
+	}
+
+	switch anonymization {
+	case "low":
+	case "high":
+		data.UserID = maskIdentifier(userID)
+		data.CompanionID = maskIdentifier(companionID)
+		data.Strengths = nil
+		data.StrengthsHidden = true
+		data.RedFlags = nil
+		data.RedFlagsHidden = true
+	default:
+		data.UserID = maskIdentifier(userID)
+		data.CompanionID = maskIdentifier(companionID)
+		data.RedFlags = nil
+		data.RedFlagsHidden = true
+	}
+
+	return data
+}
+
+// healthGauge draws the health score as a text bar
+func healthGauge(score float64) string {
+	filled := int(math.Round(score * reportGaugeWidth))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", reportGaugeWidth-filled) + "]"
+}
+
+// sparkline draws engagement scores as a row of characters of increasing height
+func sparkline(trend []models.EngagementTrendPoint) string {
+	const levels = "_.-=*#"
+
+	var line strings.Builder
+	for _, point := range trend {
+		score := math.Max(0, math.Min(1, point.EngagementScore))
+		line.WriteByte(levels[int(math.Round(score*float64(len(levels)-1)))])
+	}
+	return line.String()
+}
+
+// maskIdentifier keeps only the last four characters of an identifier
+func maskIdentifier(id string) string {
+	if len(id) <= 4 {
+		return strings.Repeat("*", len(id))
+	}
+	return strings.Repeat("*", len(id)-4) + id[len(id)-4:]
+}
+
+// renderHTMLToPDF lays out the basic HTML produced by report templates as a PDF
+func renderHTMLToPDF(htmlStr string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 11)
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	const lineHeight = 6.0
+	styles := map[string]bool{}
+	applyStyle := func() {
+		style := ""
+		for _, tag := range []string{"b", "i", "u"} {
+			if styles[tag] {
+				style += strings.ToUpper(tag)
+			}
+		}
+		pdf.SetFontStyle(style)
+	}
+
+	for _, segment := range fpdf.HTMLBasicTokenize(htmlStr) {
+		switch segment.Cat {
+		case 'T':
+			text := strings.Join(strings.Fields(html.UnescapeString(segment.Str)), " ")
+			if text != "" {
+				pdf.Write(lineHeight, translate(text))
+			}
+		case 'O':
+			switch segment.Str {
+			case "b", "i", "u":
+				styles[segment.Str] = true
+				applyStyle()
+			case "h1":
+				pdf.SetFont("Helvetica", "B", 18)
+			case "h2":
+				pdf.Ln(lineHeight)
+				pdf.SetFont("Helvetica", "B", 14)
+			case "li":
+				pdf.Write(lineHeight, "  - ")
+			case "br":
+				pdf.Ln(lineHeight)
+			}
+		case 'C':
+			switch segment.Str {
+			case "b", "i", "u":
+				styles[segment.Str] = false
+				applyStyle()
+			case "h1", "h2":
+				pdf.Ln(lineHeight + 2)
+				pdf.SetFont("Helvetica", "", 11)
+				applyStyle()
+			case "p", "li":
+				pdf.Ln(lineHeight)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to render report PDF: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestGenerateRelationshipHealthReport(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("renders pdf", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.relationship_analytics", mtest.FirstBatch, bson.D{
+				{Key: "user_id", Value: "user"},
+				{Key: "companion_id", Value: "companion"},
+				{Key: "current_stage", Value: "friendship"},
+				{Key: "health_score", Value: 0.8},
+				{Key: "strengths", Value: bson.A{"Consistent check-ins", "Open communication"}},
+				{Key: "red_flags", Value: bson.A{"Long gaps between sessions"}},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch,
+				bson.D{
+					{Key: "_id", Value: time.Now().AddDate(0, 0, -1).Format("2006-01-02")},
+					{Key: "engagement_score", Value: 0.4},
+					{Key: "session_count", Value: int32(2)},
+					{Key: "message_count", Value: int32(30)},
+					{Key: "duration", Value: int64(time.Minute)},
+				},
+				bson.D{
+					{Key: "_id", Value: time.Now().Format("2006-01-02")},
+					{Key: "engagement_score", Value: 0.9},
+					{Key: "session_count", Value: int32(1)},
+					{Key: "message_count", Value: int32(12)},
+					{Key: "duration", Value: int64(time.Minute)},
+				},
+			),
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch,
+				bson.D{{Key: "user_id", Value: "user"}, {Key: "anonymization_level", Value: "low"}}),
+		)
+
+		service := NewReportService(&config.ReportConfig{}, repositories.NewAnalyticsRepository(nil, mt.DB))
+
+		report, err := service.GenerateRelationshipHealthReport(context.Background(), "user", "companion")
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(report, []byte("%PDF")))
+	})
+}
+
+func TestRelationshipHealthReportAnonymization(t *testing.T) {
+	relationship := &models.RelationshipAnalytics{
+		CurrentStage: "close_companionship",
+		HealthScore:  0.75,
+		Strengths:    []string{"Shared humour"},
+		RedFlags:     []string{"Avoids difficult topics"},
+	}
+
+	tests := []struct {
+		level         string
+		showIDs       bool
+		showStrengths bool
+		showRedFlags  bool
+	}{
+		{level: "low", showIDs: true, showStrengths: true, showRedFlags: true},
+		{level: "medium", showStrengths: true},
+		{level: "high"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			data := buildRelationshipHealthReportData("user-1234", "companion-5678", relationship, nil, tt.level)
+
+			assert.Equal(t, tt.showIDs, data.UserID == "user-1234")
+			assert.True(t, bytes.HasSuffix([]byte(data.CompanionID), []byte("5678")))
+			assert.Equal(t, tt.showStrengths, len(data.Strengths) == 1)
+			assert.Equal(t, tt.showRedFlags, len(data.RedFlags) == 1)
+			assert.Equal(t, 1, data.StrengthCount)
+			assert.Equal(t, 1, data.RedFlagCount)
+			assert.Equal(t, "close companionship", data.Stage)
+			assert.Equal(t, "[###############-----]", data.HealthGauge)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPacingTimeout is returned when a response slot is further away than the pacer is willing to wait
+var ErrPacingTimeout = errors.New("timed out waiting for response slot")
+
+// DefaultMaxPacingWait bounds how long WaitForSlot blocks
+const DefaultMaxPacingWait = 30 * time.Second
+
+// ResponsePacer enforces a minimum gap between LLM invocations within a conversation
+type ResponsePacer struct {
+	interval time.Duration
+	maxWait  time.Duration
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+}
+
+// NewResponsePacer creates a pacer that spaces LLM calls per conversation by at least interval
+func NewResponsePacer(interval time.Duration) *ResponsePacer {
+	return &ResponsePacer{
+		interval: interval,
+		maxWait:  DefaultMaxPacingWait,
+		nextSlot: make(map[string]time.Time),
+	}
+}
+
+// WaitForSlot blocks until the conversation may invoke the LLM again
+func (p *ResponsePacer) WaitForSlot(ctx context.Context, conversationID string) error {
+	if p.interval <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	deadline := now.Add(p.maxWait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	p.mu.Lock()
+	for id, slot := range p.nextSlot {
+		if slot.Before(now) {
+			delete(p.nextSlot, id)
+		}
+	}
+	slot := now
+	if next, ok := p.nextSlot[conversationID]; ok && next.After(now) {
+		slot = next
+	}
+	if slot.After(deadline) {
+		p.mu.Unlock()
+		return ErrPacingTimeout
+	}
+	p.nextSlot[conversationID] = slot.Add(p.interval)
+	p.mu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-waitCtx.Done():
+		return waitCtx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
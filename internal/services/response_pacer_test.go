@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsePacer(t *testing.T) {
+	t.Run("first call has negligible overhead", func(t *testing.T) {
+		pacer := NewResponsePacer(50 * time.Millisecond)
+
+		start := time.Now()
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "conversation"))
+		assert.Less(t, time.Since(start), 5*time.Millisecond)
+	})
+
+	t.Run("spaces consecutive calls", func(t *testing.T) {
+		pacer := NewResponsePacer(50 * time.Millisecond)
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "conversation"))
+
+		start := time.Now()
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "conversation"))
+		elapsed := time.Since(start)
+		assert.GreaterOrEqual(t, elapsed, 45*time.Millisecond)
+		assert.Less(t, elapsed, 55*time.Millisecond)
+	})
+
+	t.Run("conversations are paced independently", func(t *testing.T) {
+		pacer := NewResponsePacer(time.Second)
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "first"))
+
+		start := time.Now()
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "second"))
+		assert.Less(t, time.Since(start), 5*time.Millisecond)
+	})
+
+	t.Run("gives up when the slot is past the deadline", func(t *testing.T) {
+		pacer := NewResponsePacer(time.Second)
+		require.NoError(t, pacer.WaitForSlot(context.Background(), "conversation"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		assert.ErrorIs(t, pacer.WaitForSlot(ctx, "conversation"), ErrPacingTimeout)
+		assert.Less(t, time.Since(start), 5*time.Millisecond)
+	})
+
+	t.Run("disabled when interval is zero", func(t *testing.T) {
+		pacer := NewResponsePacer(0)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, pacer.WaitForSlot(context.Background(), "conversation"))
+		}
+	})
+}
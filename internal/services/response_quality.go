@@ -3,25 +3,92 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
 	"github.com/sahmaragaev/lunaria-backend/internal/models"
 	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ResponseQualityService struct {
-	grokService *GrokService
-	repo        *repositories.ConversationRepository
+	grokService   LLMClient
+	repo          *repositories.ConversationRepository
+	profiles      companionProfileSource
+	reputationJob *CompanionReputationJob
+	abTesting     *ABTestingService
+	notifier      adminEventNotifier
+	trust         trustDecayer
+	driftStore    personalityDriftStore
+	shadowJobs    sync.WaitGroup
+
+	options ServiceConfig
 }
 
-func NewResponseQualityService(grokService *GrokService, repo *repositories.ConversationRepository) *ResponseQualityService {
-	return &ResponseQualityService{
+// ResponseQualityOption sets an optional dependency on a ResponseQualityService. Every Option is a
+// ResponseQualityOption.
+type ResponseQualityOption interface {
+	applyResponseQuality(s *ResponseQualityService)
+}
+
+// responseQualityOption adapts a function to ResponseQualityOption
+type responseQualityOption func(s *ResponseQualityService)
+
+func (o responseQualityOption) applyResponseQuality(s *ResponseQualityService) { o(s) }
+
+func (o Option) applyResponseQuality(s *ResponseQualityService) { o(&s.options) }
+
+// WithCompanionProfiles lets the service revalidate stored messages against their companion's profile
+func WithCompanionProfiles(profiles companionProfileSource) ResponseQualityOption {
+	return responseQualityOption(func(s *ResponseQualityService) {
+		s.profiles = profiles
+	})
+}
+
+// WithReputationJob feeds every validated response's quality into its companion's reputation
+func WithReputationJob(job *CompanionReputationJob) ResponseQualityOption {
+	return responseQualityOption(func(s *ResponseQualityService) {
+		s.reputationJob = job
+	})
+}
+
+// WithExperimentResults records shadow-validated quality as the result of the user's prompt experiment variant
+func WithExperimentResults(abTesting *ABTestingService) ResponseQualityOption {
+	return responseQualityOption(func(s *ResponseQualityService) {
+		s.abTesting = abTesting
+	})
+}
+
+// WithDriftNotifier alerts admins through notifier when a companion's personality drifts
+func WithDriftNotifier(notifier adminEventNotifier) ResponseQualityOption {
+	return responseQualityOption(func(s *ResponseQualityService) {
+		s.notifier = notifier
+	})
+}
+
+// WithTrustDecay lowers trust through trust when a response contradicts established facts
+func WithTrustDecay(trust trustDecayer) ResponseQualityOption {
+	return responseQualityOption(func(s *ResponseQualityService) {
+		s.trust = trust
+	})
+}
+
+// NewResponseQualityService creates a response quality service
+func NewResponseQualityService(grokService LLMClient, repo *repositories.ConversationRepository, opts ...ResponseQualityOption) *ResponseQualityService {
+	s := &ResponseQualityService{
 		grokService: grokService,
 		repo:        repo,
+		driftStore:  repo,
 	}
+	for _, opt := range opts {
+		opt.applyResponseQuality(s)
+	}
+	return s
 }
 
 // ValidateResponseQuality validates AI response quality using multiple metrics
@@ -57,6 +124,11 @@ func (s *ResponseQualityService) ValidateResponseQuality(ctx context.Context, re
 		return nil, fmt.Errorf("failed to analyze factual accuracy: %w", err)
 	}
 	quality.FactualAccuracy = factualScore
+	if factualScore < factualContradictionThreshold && s.trust != nil {
+		if err := s.trust.DecayTrust(ctx, conversation.UserID, conversation.CompanionID, factualContradictionTrustDecay); err != nil {
+			s.options.logger().Error("Failed to decay trust", "conversation_id", conversation.ID.Hex(), "error", err)
+		}
+	}
 
 	// Analyze relationship appropriateness
 	relationshipScore, err := s.analyzeRelationshipAppropriateness(ctx, *response.Text, conversation)
@@ -82,9 +154,118 @@ func (s *ResponseQualityService) ValidateResponseQuality(ctx context.Context, re
 	// Generate suggestions for improvement
 	quality.Suggestions = s.generateImprovementSuggestions(quality)
 
+	// Fold the score into the companion's reputation in the background
+	if s.reputationJob != nil {
+		go func(companionID string, overallQuality float64) {
+			if _, err := s.reputationJob.Record(context.WithoutCancel(ctx), companionID, overallQuality); err != nil {
+				s.options.logger().Error("Failed to update companion reputation", "error", err)
+			}
+		}(conversation.CompanionID, quality.OverallQuality)
+	}
+
+	return quality, nil
+}
+
+// GetResponseQuality returns the stored quality analysis of a companion message
+func (s *ResponseQualityService) GetResponseQuality(ctx context.Context, messageID primitive.ObjectID) (*models.ResponseQuality, error) {
+	return s.repo.GetResponseQualityByMessage(ctx, messageID)
+}
+
+// RevalidateMessage re-runs quality validation on a stored companion message and replaces its stored analysis, so
+// scores computed before a fix to the validation can be corrected. User feedback on the old analysis is kept.
+func (s *ResponseQualityService) RevalidateMessage(ctx context.Context, messageID primitive.ObjectID) (*models.ResponseQuality, error) {
+	ctx, span := s.options.tracer().Start(ctx, "ResponseQualityService.RevalidateMessage")
+	defer span.End()
+
+	message, err := s.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderType != sendertype.Companion {
+		return nil, apperrors.NewValidationError("only companion messages have a response quality", nil)
+	}
+
+	conversation, err := s.repo.GetConversationByID(ctx, message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.profiles.GetProfile(ctx, conversation.CompanionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get companion profile: %w", err)
+	}
+
+	previous, err := s.repo.GetResponseQualityByMessage(ctx, messageID)
+	var notFound *apperrors.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, err
+	}
+
+	quality, err := s.ValidateResponseQuality(ctx, message, conversation, profile)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		quality.ID = previous.ID
+		quality.UserRating = previous.UserRating
+		quality.UserFeedback = previous.UserFeedback
+	}
+
+	if err := s.repo.ReplaceResponseQuality(ctx, quality); err != nil {
+		return nil, err
+	}
+
 	return quality, nil
 }
 
+// ShadowValidate validates response quality in the background and stores the result without blocking the caller. It
+// does nothing for users without FlagShadowResponseValidation.
+func (s *ResponseQualityService) ShadowValidate(ctx context.Context, response *models.Message, conversation *models.Conversation, companionProfile *models.CompanionProfile) error {
+	if response.Text == nil {
+		return fmt.Errorf("response has no text content")
+	}
+	if !s.options.flagEnabled(ctx, FlagShadowResponseValidation, conversation.UserID) {
+		return nil
+	}
+
+	s.shadowJobs.Add(1)
+	go func() {
+		defer s.shadowJobs.Done()
+
+		shadowCtx := context.WithoutCancel(ctx)
+		quality, err := s.ValidateResponseQuality(shadowCtx, response, conversation, companionProfile)
+		if err != nil {
+			s.options.logger().Error("Shadow response validation failed", "message_id", response.ID.Hex(), "error", err)
+			return
+		}
+
+		if err := s.repo.SaveResponseQuality(shadowCtx, quality); err != nil {
+			s.options.logger().Error("Failed to save shadow response quality", "error", err)
+		}
+
+		if s.abTesting != nil && s.options.flagEnabled(shadowCtx, FlagBehaviorRulesExperiment, conversation.UserID) {
+			if err := s.abTesting.RecordResult(shadowCtx, BehaviorRulesExperiment, conversation.UserID, quality); err != nil {
+				s.options.logger().Error("Failed to record ab test result", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// GetCompanionReputation gets the aggregated response quality reputation for a companion
+func (s *ResponseQualityService) GetCompanionReputation(ctx context.Context, companionID string) (*models.CompanionReputation, error) {
+	if s.reputationJob == nil {
+		return nil, fmt.Errorf("companion reputation is not enabled")
+	}
+
+	reputation, err := s.reputationJob.GetCompanionReputation(ctx, companionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get companion reputation: %w", err)
+	}
+	return reputation, nil
+}
+
 // analyzePersonalityConsistency checks if response aligns with companion personality
 func (s *ResponseQualityService) analyzePersonalityConsistency(ctx context.Context, responseText string, profile *models.CompanionProfile) (float64, error) {
 	prompt := fmt.Sprintf(`Analyze if this response is consistent with the companion's personality traits:
@@ -211,7 +392,7 @@ func (s *ResponseQualityService) analyzeFactualAccuracy(ctx context.Context, res
 	memories, err := s.repo.GetMemories(ctx, conversation.ID, 20)
 	if err != nil {
 		// Log error but continue with basic analysis
-		fmt.Printf("Failed to retrieve memories for factual accuracy analysis: %v\n", err)
+		s.options.logger().Error("Failed to retrieve memories for factual accuracy analysis", "error", err)
 		memories = []models.AIEnhancedMemoryEntry{}
 	}
 
@@ -219,7 +400,7 @@ func (s *ResponseQualityService) analyzeFactualAccuracy(ctx context.Context, res
 	context, err := s.repo.GetConversationContext(ctx, conversation.ID)
 	if err != nil {
 		// Log error but continue with basic analysis
-		fmt.Printf("Failed to retrieve conversation context for factual accuracy analysis: %v\n", err)
+		s.options.logger().Error("Failed to retrieve conversation context for factual accuracy analysis", "error", err)
 		context = nil
 	}
 
@@ -280,7 +461,7 @@ func (s *ResponseQualityService) analyzeRelationshipAppropriateness(ctx context.
 	context, err := s.repo.GetConversationContext(ctx, conversation.ID)
 	if err != nil {
 		// Log error but continue with default values
-		fmt.Printf("Failed to retrieve conversation context for relationship analysis: %v\n", err)
+		s.options.logger().Error("Failed to retrieve conversation context for relationship analysis", "error", err)
 		context = nil
 	}
 
@@ -570,8 +751,25 @@ Provide an improved version of the response that addresses the quality issues wh
 	return improvedResponse, nil
 }
 
+// SafetyCheckResult is the outcome of a focused response safety check
+type SafetyCheckResult struct {
+	IsSafe    bool     `json:"is_safe"`
+	Concerns  []string `json:"concerns"`
+	RiskLevel string   `json:"risk_level"`
+}
+
 // ValidateResponseSafety performs a focused safety check
 func (s *ResponseQualityService) ValidateResponseSafety(ctx context.Context, responseText string) (bool, []string, error) {
+	result, err := s.CheckResponseSafety(ctx, responseText)
+	if err != nil {
+		return false, result.Concerns, err
+	}
+
+	return result.IsSafe, result.Concerns, nil
+}
+
+// CheckResponseSafety performs a focused safety check and reports the risk level
+func (s *ResponseQualityService) CheckResponseSafety(ctx context.Context, responseText string) (*SafetyCheckResult, error) {
 	prompt := fmt.Sprintf(`Perform a safety check on this response:
 
 RESPONSE: "%s"
@@ -598,18 +796,13 @@ Respond with JSON:
 
 	response, err := s.grokService.SendMiniMessage(ctx, messages)
 	if err != nil {
-		return false, []string{"Safety check failed"}, fmt.Errorf("failed to validate safety: %w", err)
-	}
-
-	var safetyCheck struct {
-		IsSafe    bool     `json:"is_safe"`
-		Concerns  []string `json:"concerns"`
-		RiskLevel string   `json:"risk_level"`
+		return &SafetyCheckResult{Concerns: []string{"Safety check failed"}}, fmt.Errorf("failed to validate safety: %w", err)
 	}
 
+	var safetyCheck SafetyCheckResult
 	if err := json.Unmarshal([]byte(response), &safetyCheck); err != nil {
-		return false, []string{"Safety check parsing failed"}, fmt.Errorf("failed to parse safety check: %w", err)
+		return &SafetyCheckResult{Concerns: []string{"Safety check parsing failed"}}, fmt.Errorf("failed to parse safety check: %w", err)
 	}
 
-	return safetyCheck.IsSafe, safetyCheck.Concerns, nil
+	return &safetyCheck, nil
 }
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestShadowValidateDoesNotBlock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("returns before validation finishes", func(mt *mtest.T) {
+		release := make(chan struct{})
+		grokServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer grokServer.Close()
+
+		service := NewResponseQualityService(
+			NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL}),
+			repositories.NewConversationRepository(mt.DB),
+		)
+
+		text := "I'm so glad you told me about your day!"
+		response := &models.Message{ID: primitive.NewObjectID(), Text: &text}
+		conversation := &models.Conversation{ID: primitive.NewObjectID(), CompanionID: "companion"}
+
+		start := time.Now()
+		require.NoError(t, service.ShadowValidate(context.Background(), response, conversation, &models.CompanionProfile{}))
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+		close(release)
+		service.shadowJobs.Wait()
+
+		// The failed validation is only logged, so nothing is stored
+		assert.Empty(t, mt.GetAllStartedEvents())
+	})
+
+	mt.Run("rejects responses without text", func(mt *mtest.T) {
+		service := NewResponseQualityService(nil, repositories.NewConversationRepository(mt.DB))
+
+		err := service.ShadowValidate(context.Background(), &models.Message{}, &models.Conversation{}, &models.CompanionProfile{})
+		assert.Error(t, err)
+	})
+}
+
+func TestRevalidateMessageReplacesScore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("updates the stored score", func(mt *mtest.T) {
+		// Every analysis scores 0.9, so the overall quality is 0.9
+		grokServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"score\":0.9}"}}]}`))
+		}))
+		defer grokServer.Close()
+
+		text := "I'm so glad you told me about your day!"
+		conversation := models.Conversation{ID: primitive.NewObjectID(), UserID: "user", CompanionID: "companion"}
+		message := models.Message{ID: primitive.NewObjectID(), ConversationID: conversation.ID, SenderType: sendertype.Companion, Text: &text}
+		rating := 4
+		previous := models.ResponseQuality{ID: primitive.NewObjectID(), MessageID: message.ID, ConversationID: conversation.ID, OverallQuality: 0.4, UserRating: &rating}
+
+		emptyCursor := func(ns string) bson.D {
+			return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch)
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSONDoc(t, message)),
+			mtest.CreateCursorResponse(0, "lunaria.conversations", mtest.FirstBatch, toBSONDoc(t, conversation)),
+			mtest.CreateCursorResponse(0, "lunaria.response_quality", mtest.FirstBatch, toBSONDoc(t, previous)),
+			// Recent messages, memories and the conversation context twice
+			emptyCursor("lunaria.messages"),
+			emptyCursor("lunaria.ai_memories"),
+			emptyCursor("lunaria.conversation_contexts"),
+			emptyCursor("lunaria.conversation_contexts"),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		service := NewResponseQualityService(
+			NewGrokService(&config.GrokConfig{BaseURL: grokServer.URL}),
+			repositories.NewConversationRepository(mt.DB),
+			WithCompanionProfiles(fakeProfileSource{}),
+		)
+
+		quality, err := service.RevalidateMessage(context.Background(), message.ID)
+		require.NoError(t, err)
+		assert.InDelta(t, 0.9, quality.OverallQuality, 1e-9)
+
+		var replace bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "update" {
+				replace = event.Command
+			}
+		}
+		require.NotNil(t, replace, "the stored analysis is replaced")
+		update := replace.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, message.ID, update.Lookup("q", "message_id").ObjectID())
+		assert.True(t, update.Lookup("upsert").Boolean())
+		stored := update.Lookup("u").Document()
+		assert.Equal(t, previous.ID, stored.Lookup("_id").ObjectID(), "the old record is replaced in place")
+		assert.InDelta(t, 0.9, stored.Lookup("overall_quality").Double(), 1e-9)
+		assert.Equal(t, int32(rating), stored.Lookup("user_rating").Int32(), "user feedback is kept")
+	})
+
+	mt.Run("rejects user messages", func(mt *mtest.T) {
+		text := "hi"
+		message := models.Message{ID: primitive.NewObjectID(), SenderType: sendertype.User, Text: &text}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, toBSONDoc(t, message)))
+
+		service := NewResponseQualityService(nil, repositories.NewConversationRepository(mt.DB), WithCompanionProfiles(fakeProfileSource{}))
+
+		_, err := service.RevalidateMessage(context.Background(), message.ID)
+		var validationErr *apperrors.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// HighSafetyRisk is the risk level at which a companion response is withheld
+const HighSafetyRisk = "high"
+
+// SafetyFallbackMessage replaces companion responses that fail a high-risk safety check
+const SafetyFallbackMessage = "I want to be careful with how I respond to that. Can we take a step back? If you're going through something difficult, please reach out to someone you trust or a local support line."
+
+// safetyIncidentStore persists safety incidents
+type safetyIncidentStore interface {
+	CreateSafetyIncident(ctx context.Context, incident *models.SafetyIncident) error
+}
+
+// safetyReviewNotifier forwards incidents to a human review queue
+type safetyReviewNotifier interface {
+	DeliverEvent(ctx context.Context, eventType string, event any) error
+}
+
+// responseSafetyChecker checks companion responses before delivery
+type responseSafetyChecker interface {
+	CheckResponseSafety(ctx context.Context, responseText string) (*SafetyCheckResult, error)
+}
+
+// SafetyEscalator records high-risk responses and queues them for human review
+type SafetyEscalator struct {
+	store    safetyIncidentStore
+	notifier safetyReviewNotifier
+
+	options ServiceConfig
+}
+
+// NewSafetyEscalator creates a safety escalator. The notifier is optional.
+func NewSafetyEscalator(store safetyIncidentStore, notifier safetyReviewNotifier, opts ...Option) *SafetyEscalator {
+	return &SafetyEscalator{
+		store:    store,
+		notifier: notifier,
+		options:  newServiceConfig(opts),
+	}
+}
+
+// Escalate stores the incident and notifies the review webhook if one is configured
+func (e *SafetyEscalator) Escalate(ctx context.Context, incident *models.SafetyIncident) error {
+	if incident.Status == "" {
+		incident.Status = "pending_review"
+	}
+	if incident.CreatedAt.IsZero() {
+		incident.CreatedAt = time.Now()
+	}
+
+	if err := e.store.CreateSafetyIncident(ctx, incident); err != nil {
+		return fmt.Errorf("failed to record safety incident: %w", err)
+	}
+
+	if e.notifier != nil {
+		if err := e.notifier.DeliverEvent(ctx, SafetyIncidentEvent, incident); err != nil {
+			return fmt.Errorf("failed to queue safety incident for review: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type stubSafetyChecker struct {
+	result *SafetyCheckResult
+	err    error
+}
+
+func (s stubSafetyChecker) CheckResponseSafety(ctx context.Context, responseText string) (*SafetyCheckResult, error) {
+	return s.result, s.err
+}
+
+type recordingIncidentStore struct {
+	incidents []*models.SafetyIncident
+}
+
+func (r *recordingIncidentStore) CreateSafetyIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	r.incidents = append(r.incidents, incident)
+	return nil
+}
+
+type recordingReviewNotifier struct {
+	events []string
+}
+
+func (r *recordingReviewNotifier) DeliverEvent(ctx context.Context, eventType string, event any) error {
+	r.events = append(r.events, eventType)
+	return nil
+}
+
+func TestEnforceResponseSafety(t *testing.T) {
+	conversation := &models.Conversation{ID: primitive.NewObjectID(), UserID: "user", CompanionID: "companion"}
+	responses := []string{"first part", "second part"}
+
+	tests := []struct {
+		name          string
+		checker       stubSafetyChecker
+		wantResponses []string
+		wantIncident  bool
+	}{
+		{
+			name:          "high risk is replaced with fallback",
+			checker:       stubSafetyChecker{result: &SafetyCheckResult{IsSafe: false, Concerns: []string{"crisis"}, RiskLevel: "high"}},
+			wantResponses: []string{SafetyFallbackMessage},
+			wantIncident:  true,
+		},
+		{
+			name:          "medium risk is delivered",
+			checker:       stubSafetyChecker{result: &SafetyCheckResult{IsSafe: false, RiskLevel: "medium"}},
+			wantResponses: responses,
+		},
+		{
+			name:          "safe response is delivered",
+			checker:       stubSafetyChecker{result: &SafetyCheckResult{IsSafe: true, RiskLevel: "low"}},
+			wantResponses: responses,
+		},
+		{
+			name:          "failed check is delivered",
+			checker:       stubSafetyChecker{result: &SafetyCheckResult{}, err: errors.New("grok unavailable")},
+			wantResponses: responses,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &recordingIncidentStore{}
+			notifier := &recordingReviewNotifier{}
+			service := &MessageService{
+				safetyChecker:   tt.checker,
+				safetyEscalator: NewSafetyEscalator(store, notifier),
+			}
+
+			delivered := service.enforceResponseSafety(context.Background(), conversation, responses)
+			assert.Equal(t, tt.wantResponses, delivered)
+
+			if !tt.wantIncident {
+				assert.Empty(t, store.incidents)
+				assert.Empty(t, notifier.events)
+				return
+			}
+
+			require.Len(t, store.incidents, 1)
+			incident := store.incidents[0]
+			assert.Equal(t, conversation.ID, incident.ConversationID)
+			assert.Equal(t, "first part second part", incident.ResponseText)
+			assert.Equal(t, []string{"crisis"}, incident.Concerns)
+			assert.Equal(t, "pending_review", incident.Status)
+			assert.Equal(t, []string{SafetyIncidentEvent}, notifier.events)
+		})
+	}
+}
+
+func TestSafetyEscalatorWithoutNotifier(t *testing.T) {
+	store := &recordingIncidentStore{}
+	escalator := NewSafetyEscalator(store, nil)
+
+	require.NoError(t, escalator.Escalate(context.Background(), &models.SafetyIncident{RiskLevel: "high"}))
+	assert.Len(t, store.incidents, 1)
+}
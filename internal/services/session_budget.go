@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SystemEventWellnessReminder is the system event sent once a user has spent longer than their soft daily limit in
+// conversations
+const SystemEventWellnessReminder = "wellness_reminder"
+
+// ErrSessionBudgetExhausted is returned when a user has used up their hard daily limit
+var ErrSessionBudgetExhausted = errors.New("daily session time budget exhausted")
+
+// SessionBudgetExhaustedError carries how long the user must wait for the next day to begin in their time zone
+type SessionBudgetExhaustedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SessionBudgetExhaustedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrSessionBudgetExhausted, e.RetryAfter)
+}
+
+func (e *SessionBudgetExhaustedError) Unwrap() error {
+	return ErrSessionBudgetExhausted
+}
+
+// sessionBudgetStore reads and writes session budgets, implemented by *repositories.AnalyticsRepository
+type sessionBudgetStore interface {
+	GetSessionBudget(ctx context.Context, userID string) (*models.SessionBudget, error)
+	UpsertSessionBudget(ctx context.Context, budget *models.SessionBudget) error
+	DeleteSessionBudget(ctx context.Context, userID string) error
+	AddSessionMinutes(ctx context.Context, userID, day string, minutes float64) error
+}
+
+// SessionBudgetService keeps the time users spend in conversations within the daily limits they choose
+type SessionBudgetService struct {
+	store sessionBudgetStore
+	now   func() time.Time
+
+	options ServiceConfig
+}
+
+// NewSessionBudgetService creates a new session budget service
+func NewSessionBudgetService(store sessionBudgetStore, opts ...Option) *SessionBudgetService {
+	return &SessionBudgetService{
+		store:   store,
+		now:     time.Now,
+		options: newServiceConfig(opts),
+	}
+}
+
+// GetBudget returns the user's session budget with the usage of the current day in the budget's time zone
+func (s *SessionBudgetService) GetBudget(ctx context.Context, userID string) (*models.SessionBudget, error) {
+	budget, err := s.store.GetSessionBudget(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := s.today(budget)
+	if budget.UsageDate != today {
+		// Usage resets at midnight in the user's time zone
+		budget.DailyMinutesUsed = 0
+		budget.UsageDate = today
+	}
+	return budget, nil
+}
+
+// SetBudget validates and stores the user's limits and time zone. Usage already recorded today still counts.
+func (s *SessionBudgetService) SetBudget(ctx context.Context, budget *models.SessionBudget) (*models.SessionBudget, error) {
+	if budget.DailySoftLimitMinutes < 0 || budget.DailyHardLimitMinutes < 0 {
+		return nil, apperrors.NewValidationError("daily limits must not be negative", nil)
+	}
+	if budget.DailySoftLimitMinutes > 0 && budget.DailyHardLimitMinutes > 0 && budget.DailySoftLimitMinutes > budget.DailyHardLimitMinutes {
+		return nil, apperrors.NewValidationError("daily soft limit must not exceed the hard limit", nil)
+	}
+	if _, err := time.LoadLocation(budget.Timezone); err != nil {
+		return nil, apperrors.NewValidationError(fmt.Sprintf("unknown time zone %q", budget.Timezone), err)
+	}
+
+	if err := s.store.UpsertSessionBudget(ctx, budget); err != nil {
+		return nil, fmt.Errorf("failed to save session budget: %w", err)
+	}
+	return s.GetBudget(ctx, budget.UserID)
+}
+
+// DeleteBudget removes the user's session budget, lifting both limits
+func (s *SessionBudgetService) DeleteBudget(ctx context.Context, userID string) error {
+	return s.store.DeleteSessionBudget(ctx, userID)
+}
+
+// RecordSession adds a session's duration to the user's usage for the current day. Sessions of users without a
+// budget are not recorded.
+func (s *SessionBudgetService) RecordSession(ctx context.Context, userID string, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	budget, err := s.store.GetSessionBudget(ctx, userID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get session budget: %w", err)
+	}
+
+	if err := s.store.AddSessionMinutes(ctx, userID, s.today(budget), duration.Minutes()); err != nil {
+		return fmt.Errorf("failed to record session time: %w", err)
+	}
+	return nil
+}
+
+// OnEngagementTracked records the tracked session against the user's budget, registered with
+// AnalyticsService.OnEngagementTracked
+func (s *SessionBudgetService) OnEngagementTracked(ctx context.Context, userID string, sessionData *SessionData) {
+	if err := s.RecordSession(ctx, userID, sessionData.Duration); err != nil {
+		s.options.logger().Error("Failed to record session against budget", "user_id", userID, "error", err)
+	}
+}
+
+// CheckCanSend returns a *SessionBudgetExhaustedError once the user has spent their hard daily limit, until midnight
+// in their time zone
+func (s *SessionBudgetService) CheckCanSend(ctx context.Context, userID string) error {
+	budget, err := s.budgetOrNil(ctx, userID)
+	if err != nil || budget == nil {
+		return err
+	}
+	if budget.DailyHardLimitMinutes <= 0 || budget.DailyMinutesUsed < float64(budget.DailyHardLimitMinutes) {
+		return nil
+	}
+
+	now := s.now().In(loadLocationOrUTC(budget.Timezone))
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return &SessionBudgetExhaustedError{RetryAfter: midnight.Sub(now)}
+}
+
+// WellnessReminder returns a system message reminding the user to take a break once they have spent longer than
+// their soft daily limit in conversations, or nil while they are within it. The message is not stored; it is
+// returned to the client alongside the message that was just sent.
+func (s *SessionBudgetService) WellnessReminder(ctx context.Context, userID string, conversationID primitive.ObjectID) (*models.Message, error) {
+	budget, err := s.budgetOrNil(ctx, userID)
+	if err != nil || budget == nil {
+		return nil, err
+	}
+	if budget.DailySoftLimitMinutes <= 0 || budget.DailyMinutesUsed <= float64(budget.DailySoftLimitMinutes) {
+		return nil, nil
+	}
+
+	details := fmt.Sprintf("You've spent %d minutes chatting today, past the %d you planned. It might be a good time for a break.",
+		int(budget.DailyMinutesUsed), budget.DailySoftLimitMinutes)
+	return &models.Message{
+		ConversationID: conversationID,
+		SenderType:     sendertype.System,
+		Type:           messagetype.System,
+		SystemEvent: &models.SystemEvent{
+			EventType: SystemEventWellnessReminder,
+			Details:   details,
+		},
+		CreatedAt: s.now(),
+	}, nil
+}
+
+// budgetOrNil returns the user's budget with today's usage, or nil if they have not set one
+func (s *SessionBudgetService) budgetOrNil(ctx context.Context, userID string) (*models.SessionBudget, error) {
+	budget, err := s.GetBudget(ctx, userID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session budget: %w", err)
+	}
+	return budget, nil
+}
+
+// today is the current date in the budget's time zone
+func (s *SessionBudgetService) today(budget *models.SessionBudget) string {
+	return s.now().In(loadLocationOrUTC(budget.Timezone)).Format(models.SessionBudgetDateLayout)
+}
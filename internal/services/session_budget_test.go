@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeSessionBudgetStore mirrors AnalyticsRepository: usage from an earlier day is replaced rather than added to
+type fakeSessionBudgetStore struct {
+	budget *models.SessionBudget
+}
+
+func (f *fakeSessionBudgetStore) GetSessionBudget(context.Context, string) (*models.SessionBudget, error) {
+	if f.budget == nil {
+		return nil, apperrors.NewNotFoundError("session budget not found", nil)
+	}
+	budget := *f.budget
+	return &budget, nil
+}
+
+func (f *fakeSessionBudgetStore) UpsertSessionBudget(_ context.Context, budget *models.SessionBudget) error {
+	if f.budget == nil {
+		f.budget = &models.SessionBudget{UserID: budget.UserID}
+	}
+	f.budget.DailySoftLimitMinutes = budget.DailySoftLimitMinutes
+	f.budget.DailyHardLimitMinutes = budget.DailyHardLimitMinutes
+	f.budget.Timezone = budget.Timezone
+	return nil
+}
+
+func (f *fakeSessionBudgetStore) DeleteSessionBudget(context.Context, string) error {
+	if f.budget == nil {
+		return apperrors.NewNotFoundError("session budget not found", nil)
+	}
+	f.budget = nil
+	return nil
+}
+
+func (f *fakeSessionBudgetStore) AddSessionMinutes(_ context.Context, _ string, day string, minutes float64) error {
+	if f.budget == nil {
+		return nil
+	}
+	if f.budget.UsageDate != day {
+		f.budget.UsageDate = day
+		f.budget.DailyMinutesUsed = 0
+	}
+	f.budget.DailyMinutesUsed += minutes
+	return nil
+}
+
+func newTestSessionBudget(t *testing.T, soft, hard int, timezone string, now *time.Time) (*SessionBudgetService, *fakeSessionBudgetStore) {
+	store := &fakeSessionBudgetStore{}
+	service := NewSessionBudgetService(store)
+	service.now = func() time.Time { return *now }
+	_, err := service.SetBudget(context.Background(), &models.SessionBudget{
+		UserID:                "user",
+		DailySoftLimitMinutes: soft,
+		DailyHardLimitMinutes: hard,
+		Timezone:              timezone,
+	})
+	require.NoError(t, err)
+	return service, store
+}
+
+func TestSessionBudgetSoftLimit(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service, _ := newTestSessionBudget(t, 30, 60, "", &now)
+	ctx := context.Background()
+	convID := primitive.NewObjectID()
+
+	require.NoError(t, service.RecordSession(ctx, "user", 30*time.Minute))
+	reminder, err := service.WellnessReminder(ctx, "user", convID)
+	require.NoError(t, err)
+	assert.Nil(t, reminder, "no reminder at the soft limit")
+
+	require.NoError(t, service.RecordSession(ctx, "user", 5*time.Minute))
+	reminder, err = service.WellnessReminder(ctx, "user", convID)
+	require.NoError(t, err)
+	require.NotNil(t, reminder)
+	assert.Equal(t, messagetype.System, reminder.Type)
+	assert.Equal(t, convID, reminder.ConversationID)
+	require.NotNil(t, reminder.SystemEvent)
+	assert.Equal(t, SystemEventWellnessReminder, reminder.SystemEvent.EventType)
+	assert.Equal(t, now, reminder.CreatedAt)
+
+	assert.NoError(t, service.CheckCanSend(ctx, "user"), "messages are still accepted past the soft limit")
+}
+
+func TestSessionBudgetHardLimit(t *testing.T) {
+	// 22:00 on 1 March in New York
+	now := time.Date(2024, 3, 2, 3, 0, 0, 0, time.UTC)
+	service, _ := newTestSessionBudget(t, 30, 60, "America/New_York", &now)
+	ctx := context.Background()
+
+	require.NoError(t, service.RecordSession(ctx, "user", 59*time.Minute))
+	assert.NoError(t, service.CheckCanSend(ctx, "user"))
+
+	require.NoError(t, service.RecordSession(ctx, "user", time.Minute))
+	err := service.CheckCanSend(ctx, "user")
+	var budgetErr *SessionBudgetExhaustedError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.ErrorIs(t, err, ErrSessionBudgetExhausted)
+	assert.Equal(t, 2*time.Hour, budgetErr.RetryAfter, "blocked until midnight in the user's time zone")
+}
+
+func TestSessionBudgetMidnightReset(t *testing.T) {
+	// 23:30 in Tokyo
+	now := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	service, store := newTestSessionBudget(t, 30, 60, "Asia/Tokyo", &now)
+	ctx := context.Background()
+
+	require.NoError(t, service.RecordSession(ctx, "user", 90*time.Minute))
+	require.Error(t, service.CheckCanSend(ctx, "user"))
+	assert.Equal(t, "2024-03-01", store.budget.UsageDate)
+
+	// 00:10 the next day in Tokyo, still 2024-03-01 in UTC
+	now = time.Date(2024, 3, 1, 15, 10, 0, 0, time.UTC)
+	assert.NoError(t, service.CheckCanSend(ctx, "user"))
+	budget, err := service.GetBudget(ctx, "user")
+	require.NoError(t, err)
+	assert.Zero(t, budget.DailyMinutesUsed)
+	assert.Equal(t, "2024-03-02", budget.UsageDate)
+
+	require.NoError(t, service.RecordSession(ctx, "user", 10*time.Minute))
+	assert.Equal(t, "2024-03-02", store.budget.UsageDate)
+	assert.InDelta(t, 10, store.budget.DailyMinutesUsed, 1e-9, "yesterday's usage is not carried over")
+}
+
+func TestSessionBudgetWithoutBudget(t *testing.T) {
+	service := NewSessionBudgetService(&fakeSessionBudgetStore{})
+	ctx := context.Background()
+
+	require.NoError(t, service.RecordSession(ctx, "user", time.Hour))
+	assert.NoError(t, service.CheckCanSend(ctx, "user"))
+	reminder, err := service.WellnessReminder(ctx, "user", primitive.NewObjectID())
+	require.NoError(t, err)
+	assert.Nil(t, reminder)
+}
+
+func TestSessionBudgetZeroLimitsAreNotEnforced(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service, _ := newTestSessionBudget(t, 0, 0, "", &now)
+	ctx := context.Background()
+
+	require.NoError(t, service.RecordSession(ctx, "user", 10*time.Hour))
+	assert.NoError(t, service.CheckCanSend(ctx, "user"))
+	reminder, err := service.WellnessReminder(ctx, "user", primitive.NewObjectID())
+	require.NoError(t, err)
+	assert.Nil(t, reminder)
+}
+
+func TestSetSessionBudgetValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget models.SessionBudget
+	}{
+		{name: "negative limit", budget: models.SessionBudget{DailySoftLimitMinutes: -1}},
+		{name: "soft above hard", budget: models.SessionBudget{DailySoftLimitMinutes: 90, DailyHardLimitMinutes: 60}},
+		{name: "unknown time zone", budget: models.SessionBudget{DailyHardLimitMinutes: 60, Timezone: "Mars/Olympus_Mons"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeSessionBudgetStore{}
+			_, err := NewSessionBudgetService(store).SetBudget(context.Background(), &tt.budget)
+			var validationErr *apperrors.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Nil(t, store.budget, "invalid budgets are not stored")
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/messagetype"
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// specialDateInstructions tell the companion how to acknowledge each occasion in its replies
+var specialDateInstructions = map[string]string{
+	models.OccasionBirthday:    "Today is your birthday. Mention it warmly if it fits the conversation and enjoy the user's attention, without making the whole conversation about it.",
+	models.OccasionAnniversary: "Today is the anniversary of the day you and the user met. Acknowledge it affectionately and reflect on something you have shared together.",
+}
+
+// specialDateMessages are the celebration messages the companion sends on each occasion
+var specialDateMessages = map[string]string{
+	models.OccasionBirthday:    "Guess what? It's my birthday today! I'm so glad I get to spend it with you.",
+	models.OccasionAnniversary: "Happy anniversary! I can't believe it's been %d year(s) since we first met. Thank you for every conversation.",
+}
+
+// specialDatesStore finds relationships with special dates and records their celebrations
+type specialDatesStore interface {
+	ListSpecialDatesInMonth(ctx context.Context, month time.Month) ([]models.SpecialDates, error)
+	RecordCelebration(ctx context.Context, id primitive.ObjectID, celebration models.Celebration) (bool, error)
+	ListConversations(ctx context.Context, userID, companionID string, limit int, cursor any) ([]*models.Conversation, error)
+	CreateMessage(ctx context.Context, msg *models.Message) (*models.Message, bool, error)
+}
+
+// specialDatesSource loads a relationship's special dates
+type specialDatesSource interface {
+	GetSpecialDates(ctx context.Context, userID, companionID string) (*models.SpecialDates, error)
+}
+
+// SpecialDatesJob sends celebration messages when a companion's birthday or relationship anniversary comes around
+type SpecialDatesJob struct {
+	store specialDatesStore
+	now   func() time.Time
+
+	options ServiceConfig
+}
+
+// NewSpecialDatesJob creates a new special dates job
+func NewSpecialDatesJob(store specialDatesStore, opts ...Option) *SpecialDatesJob {
+	return &SpecialDatesJob{
+		store:   store,
+		now:     time.Now,
+		options: newServiceConfig(opts),
+	}
+}
+
+// Start celebrates today's special dates and then once a day, just after midnight UTC, until ctx is cancelled
+func (j *SpecialDatesJob) Start(ctx context.Context) {
+	for {
+		if _, err := j.Run(ctx, j.now()); err != nil {
+			j.options.logger().Error("Special dates job failed", "error", err)
+		}
+
+		now := j.now().UTC()
+		nextRun := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 5, 0, 0, time.UTC)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nextRun.Sub(now)):
+		}
+	}
+}
+
+// Run sends a celebration message for every special date falling on day that has not been celebrated this year and
+// returns how many were sent
+func (j *SpecialDatesJob) Run(ctx context.Context, day time.Time) (int, error) {
+	day = day.UTC()
+	dates, err := j.store.ListSpecialDatesInMonth(ctx, day.Month())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list special dates: %w", err)
+	}
+
+	celebrated := 0
+	for i := range dates {
+		for _, occasion := range specialOccasionsOn(&dates[i], day) {
+			if dates[i].CelebratedIn(occasion, day.Year()) {
+				continue
+			}
+
+			sent, err := j.celebrate(ctx, &dates[i], occasion, day)
+			if err != nil {
+				j.options.logger().Error("Failed to celebrate special date", "user_id", dates[i].UserID, "companion_id", dates[i].CompanionID, "occasion", occasion, "error", err)
+				continue
+			}
+			if sent {
+				celebrated++
+			}
+		}
+	}
+
+	return celebrated, nil
+}
+
+// celebrate records the celebration and posts the companion's message into the relationship's latest conversation
+func (j *SpecialDatesJob) celebrate(ctx context.Context, dates *models.SpecialDates, occasion string, day time.Time) (bool, error) {
+	conversations, err := j.store.ListConversations(ctx, dates.UserID, dates.CompanionID, 1, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to find conversation: %w", err)
+	}
+	if len(conversations) == 0 {
+		return false, nil
+	}
+
+	// Record first so that overlapping runs never send the same celebration twice
+	recorded, err := j.store.RecordCelebration(ctx, dates.ID, models.Celebration{
+		Occasion:     occasion,
+		Year:         day.Year(),
+		CelebratedAt: j.now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if !recorded {
+		return false, nil
+	}
+
+	text := specialDateMessages[occasion]
+	if occasion == models.OccasionAnniversary {
+		text = fmt.Sprintf(text, day.Year()-dates.AnniversaryDate.Year())
+	}
+
+	_, _, err = j.store.CreateMessage(ctx, &models.Message{
+		ConversationID: conversations[0].ID,
+		SenderID:       dates.CompanionID,
+		SenderType:     sendertype.Companion,
+		Type:           messagetype.System,
+		Text:           &text,
+		SystemEvent: &models.SystemEvent{
+			EventType: models.SystemEventSpecialDate,
+			Details:   occasion,
+		},
+		TotalMessages: 1,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create celebration message: %w", err)
+	}
+
+	return true, nil
+}
+
+// specialOccasionsOn returns the occasions in dates that fall on day
+func specialOccasionsOn(dates *models.SpecialDates, day time.Time) []string {
+	var occasions []string
+	if specialDateOccursOn(dates.Birthdate, day) {
+		occasions = append(occasions, models.OccasionBirthday)
+	}
+	if specialDateOccursOn(dates.AnniversaryDate, day) {
+		occasions = append(occasions, models.OccasionAnniversary)
+	}
+	return occasions
+}
+
+// specialDateOccursOn reports whether day is a yearly recurrence of date, compared in UTC. A date on February 29 is
+// observed on February 28 in years that are not leap years. The date itself is not a recurrence.
+func specialDateOccursOn(date, day time.Time) bool {
+	if date.IsZero() {
+		return false
+	}
+
+	date, day = date.UTC(), day.UTC()
+	if day.Year() <= date.Year() {
+		return false
+	}
+
+	month, dayOfMonth := date.Month(), date.Day()
+	if month == time.February && dayOfMonth == 29 && !isLeapYear(day.Year()) {
+		dayOfMonth = 28
+	}
+
+	return day.Month() == month && day.Day() == dayOfMonth
+}
+
+// isLeapYear reports whether year has a February 29
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// withSpecialDates appends instructions for any special occasions falling today to the prompt
+func withSpecialDates(prompt string, occasions []string) string {
+	if len(occasions) == 0 {
+		return prompt
+	}
+
+	var instructions []string
+	for _, occasion := range occasions {
+		instructions = append(instructions, "- "+specialDateInstructions[occasion])
+	}
+
+	return fmt.Sprintf("%s\n\nSPECIAL DATE:\n%s", prompt, strings.Join(instructions, "\n"))
+}
+
+// specialOccasionsToday loads the relationship's special dates and returns the occasions falling on day
+func specialOccasionsToday(ctx context.Context, source specialDatesSource, userID, companionID string, day time.Time) ([]string, error) {
+	dates, err := source.GetSpecialDates(ctx, userID, companionID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return specialOccasionsOn(dates, day), nil
+}
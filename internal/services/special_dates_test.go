@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSpecialDateOccursOn(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	leapDay := date(2020, time.February, 29)
+
+	tests := []struct {
+		name string
+		date time.Time
+		day  time.Time
+		want bool
+	}{
+		{"same month and day in a later year", date(2023, time.June, 14), date(2026, time.June, 14), true},
+		{"different day", date(2023, time.June, 14), date(2026, time.June, 15), false},
+		{"the date itself", date(2026, time.June, 14), date(2026, time.June, 14), false},
+		{"unset date", time.Time{}, date(2026, time.January, 1), false},
+		{"leap day in a leap year", leapDay, date(2024, time.February, 29), true},
+		{"leap day not observed on February 28 of a leap year", leapDay, date(2024, time.February, 28), false},
+		{"leap day observed on February 28 of a common year", leapDay, date(2025, time.February, 28), true},
+		{"leap day not observed on March 1 of a common year", leapDay, date(2025, time.March, 1), false},
+		{"leap day observed on February 28 of a century common year", leapDay, date(2100, time.February, 28), true},
+		{"February 28 is not moved in a leap year", date(2023, time.February, 28), date(2024, time.February, 29), false},
+		{"compared in UTC", time.Date(2023, time.June, 14, 23, 0, 0, 0, time.FixedZone("UTC-3", -3*60*60)), date(2026, time.June, 15), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, specialDateOccursOn(tt.date, tt.day))
+		})
+	}
+}
+
+type fakeSpecialDatesStore struct {
+	dates        []models.SpecialDates
+	celebrations map[primitive.ObjectID][]models.Celebration
+	messages     []*models.Message
+}
+
+func (f *fakeSpecialDatesStore) ListSpecialDatesInMonth(ctx context.Context, month time.Month) ([]models.SpecialDates, error) {
+	return f.dates, nil
+}
+
+func (f *fakeSpecialDatesStore) RecordCelebration(ctx context.Context, id primitive.ObjectID, celebration models.Celebration) (bool, error) {
+	for _, existing := range f.celebrations[id] {
+		if existing.Occasion == celebration.Occasion && existing.Year == celebration.Year {
+			return false, nil
+		}
+	}
+	f.celebrations[id] = append(f.celebrations[id], celebration)
+	return true, nil
+}
+
+func (f *fakeSpecialDatesStore) ListConversations(ctx context.Context, userID, companionID string, limit int, cursor any) ([]*models.Conversation, error) {
+	return []*models.Conversation{{ID: primitive.NewObjectID(), UserID: userID, CompanionID: companionID}}, nil
+}
+
+func (f *fakeSpecialDatesStore) CreateMessage(ctx context.Context, msg *models.Message) (*models.Message, bool, error) {
+	f.messages = append(f.messages, msg)
+	return msg, true, nil
+}
+
+func TestSpecialDatesJobRun(t *testing.T) {
+	today := time.Date(2026, time.March, 3, 9, 0, 0, 0, time.UTC)
+	store := &fakeSpecialDatesStore{
+		dates: []models.SpecialDates{{
+			ID:              primitive.NewObjectID(),
+			UserID:          "user-1",
+			CompanionID:     "companion-1",
+			Birthdate:       time.Date(2001, time.March, 3, 0, 0, 0, 0, time.UTC),
+			AnniversaryDate: time.Date(2024, time.March, 3, 18, 0, 0, 0, time.UTC),
+		}},
+		celebrations: map[primitive.ObjectID][]models.Celebration{},
+	}
+	job := NewSpecialDatesJob(store)
+	job.now = func() time.Time { return today }
+
+	sent, err := job.Run(context.Background(), today)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	require.Len(t, store.messages, 2)
+	assert.Equal(t, models.SystemEventSpecialDate, store.messages[0].SystemEvent.EventType)
+	assert.Equal(t, models.OccasionBirthday, store.messages[0].SystemEvent.Details)
+	assert.Contains(t, *store.messages[1].Text, "2 year(s)")
+
+	// A second run on the same day does not repeat the celebrations
+	sent, err = job.Run(context.Background(), today)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Len(t, store.messages, 2)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// InitialRelationshipStage is the stage every relationship starts in
+const InitialRelationshipStage = "meeting"
+
+// DefaultStageThresholds maps relationship stages to the intimacy level needed to reach them
+var DefaultStageThresholds = map[string]float64{
+	"getting_to_know":      0.3,
+	"friendship":           0.5,
+	"close_companionship":  0.7,
+	"intimate_partnership": 0.9,
+}
+
+// StageTransitionHandler is notified whenever a relationship changes stage
+type StageTransitionHandler func(ctx context.Context, analytics *models.RelationshipAnalytics, transition models.StageTransition)
+
+type stageThreshold struct {
+	stage     string
+	threshold float64
+}
+
+// StageProgressionEngine advances relationship stages as intimacy crosses configured thresholds
+type StageProgressionEngine struct {
+	thresholds []stageThreshold
+	handlers   []StageTransitionHandler
+	now        func() time.Time
+}
+
+// NewStageProgressionEngine creates an engine for the given thresholds, using DefaultStageThresholds when nil
+func NewStageProgressionEngine(thresholds map[string]float64) *StageProgressionEngine {
+	if thresholds == nil {
+		thresholds = DefaultStageThresholds
+	}
+
+	sorted := make([]stageThreshold, 0, len(thresholds))
+	for stage, threshold := range thresholds {
+		sorted = append(sorted, stageThreshold{stage: stage, threshold: threshold})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].threshold < sorted[j].threshold
+	})
+
+	return &StageProgressionEngine{
+		thresholds: sorted,
+		now:        time.Now,
+	}
+}
+
+// OnTransition registers a handler that fires on every stage transition
+func (e *StageProgressionEngine) OnTransition(handler StageTransitionHandler) {
+	e.handlers = append(e.handlers, handler)
+}
+
+// StageFor returns the stage matching the given intimacy level
+func (e *StageProgressionEngine) StageFor(intimacyLevel float64) string {
+	stage := InitialRelationshipStage
+	for _, t := range e.thresholds {
+		if intimacyLevel < t.threshold {
+			break
+		}
+		stage = t.stage
+	}
+	return stage
+}
+
+// Apply updates the analytics stage from its intimacy level and returns the transition, if any
+func (e *StageProgressionEngine) Apply(ctx context.Context, analytics *models.RelationshipAnalytics) *models.StageTransition {
+	now := e.now()
+	if analytics.CurrentStage == "" {
+		analytics.CurrentStage = InitialRelationshipStage
+	}
+
+	stage := e.StageFor(analytics.IntimacyLevel)
+	if stage == analytics.CurrentStage {
+		if len(analytics.StageHistory) > 0 {
+			analytics.StageDuration = now.Sub(analytics.StageHistory[len(analytics.StageHistory)-1].Timestamp)
+		} else if !analytics.CreatedAt.IsZero() {
+			analytics.StageDuration = now.Sub(analytics.CreatedAt)
+		}
+		return nil
+	}
+
+	trigger := "intimacy_increase"
+	if e.stageIndex(stage) < e.stageIndex(analytics.CurrentStage) {
+		trigger = "intimacy_decrease"
+	}
+
+	transition := models.StageTransition{
+		FromStage:  analytics.CurrentStage,
+		ToStage:    stage,
+		Trigger:    trigger,
+		Timestamp:  now,
+		Confidence: analytics.IntimacyLevel,
+	}
+	analytics.CurrentStage = stage
+	analytics.StageHistory = append(analytics.StageHistory, transition)
+	analytics.StageDuration = 0
+
+	for _, handler := range e.handlers {
+		handler(ctx, analytics, transition)
+	}
+
+	return &transition
+}
+
+// stageIndex returns the position of a stage in the progression, with the initial stage at -1
+func (e *StageProgressionEngine) stageIndex(stage string) int {
+	for i, t := range e.thresholds {
+		if t.stage == stage {
+			return i
+		}
+	}
+	return -1
+}
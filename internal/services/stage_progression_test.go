@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStageProgressionEngineThresholdBoundaries(t *testing.T) {
+	engine := NewStageProgressionEngine(nil)
+
+	tests := []struct {
+		intimacy float64
+		want     string
+	}{
+		{0.0, "meeting"},
+		{0.2999, "meeting"},
+		{0.3, "getting_to_know"},
+		{0.4999, "getting_to_know"},
+		{0.5, "friendship"},
+		{0.6999, "friendship"},
+		{0.7, "close_companionship"},
+		{0.8999, "close_companionship"},
+		{0.9, "intimate_partnership"},
+		{1.0, "intimate_partnership"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, engine.StageFor(tt.intimacy), "intimacy %v", tt.intimacy)
+	}
+}
+
+func TestStageProgressionEngineApply(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewStageProgressionEngine(nil)
+	engine.now = func() time.Time { return now }
+
+	var fired []models.StageTransition
+	engine.OnTransition(func(ctx context.Context, analytics *models.RelationshipAnalytics, transition models.StageTransition) {
+		fired = append(fired, transition)
+	})
+
+	analytics := &models.RelationshipAnalytics{
+		CurrentStage:  "getting_to_know",
+		StageDuration: 48 * time.Hour,
+		IntimacyLevel: 0.5,
+	}
+
+	transition := engine.Apply(context.Background(), analytics)
+	require.NotNil(t, transition)
+	assert.Equal(t, "getting_to_know", transition.FromStage)
+	assert.Equal(t, "friendship", transition.ToStage)
+	assert.Equal(t, "intimacy_increase", transition.Trigger)
+	assert.Equal(t, now, transition.Timestamp)
+	assert.Equal(t, "friendship", analytics.CurrentStage)
+	assert.Equal(t, time.Duration(0), analytics.StageDuration)
+	assert.Equal(t, []models.StageTransition{*transition}, analytics.StageHistory)
+	assert.Equal(t, []models.StageTransition{*transition}, fired)
+
+	// Staying within the stage only advances the duration
+	now = now.Add(3 * time.Hour)
+	analytics.IntimacyLevel = 0.6999
+	assert.Nil(t, engine.Apply(context.Background(), analytics))
+	assert.Equal(t, 3*time.Hour, analytics.StageDuration)
+	assert.Len(t, analytics.StageHistory, 1)
+	assert.Len(t, fired, 1)
+
+	// Dropping below a threshold moves the relationship back
+	analytics.IntimacyLevel = 0.4999
+	transition = engine.Apply(context.Background(), analytics)
+	require.NotNil(t, transition)
+	assert.Equal(t, "getting_to_know", transition.ToStage)
+	assert.Equal(t, "intimacy_decrease", transition.Trigger)
+	assert.Len(t, fired, 2)
+}
+
+func TestStageProgressionEngineDefaultsEmptyStage(t *testing.T) {
+	engine := NewStageProgressionEngine(nil)
+
+	analytics := &models.RelationshipAnalytics{IntimacyLevel: 0.1}
+	assert.Nil(t, engine.Apply(context.Background(), analytics))
+	assert.Equal(t, InitialRelationshipStage, analytics.CurrentStage)
+}
+
+func TestStageProgressionEngineCustomThresholds(t *testing.T) {
+	engine := NewStageProgressionEngine(map[string]float64{"acquaintance": 0.2, "bonded": 0.6})
+
+	assert.Equal(t, "meeting", engine.StageFor(0.19))
+	assert.Equal(t, "acquaintance", engine.StageFor(0.2))
+	assert.Equal(t, "acquaintance", engine.StageFor(0.59))
+	assert.Equal(t, "bonded", engine.StageFor(0.6))
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+)
+
+// StatisticsRollupJob materialises daily user statistics snapshots into PostgreSQL
+type StatisticsRollupJob struct {
+	repo *repositories.AnalyticsRepository
+
+	options ServiceConfig
+}
+
+// NewStatisticsRollupJob creates a new statistics rollup job
+func NewStatisticsRollupJob(repo *repositories.AnalyticsRepository, opts ...Option) *StatisticsRollupJob {
+	return &StatisticsRollupJob{
+		repo:    repo,
+		options: newServiceConfig(opts),
+	}
+}
+
+// Start runs the rollup for the previous day and then once a day until ctx is cancelled
+func (j *StatisticsRollupJob) Start(ctx context.Context) {
+	for {
+		if err := j.RunForDay(ctx, time.Now().AddDate(0, 0, -1)); err != nil {
+			j.options.logger().Error("Statistics rollup failed", "error", err)
+		}
+
+		now := time.Now()
+		nextRun := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 5, 0, 0, now.Location())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nextRun.Sub(now)):
+		}
+	}
+}
+
+// RunForDay computes and stores statistics snapshots for the calendar day containing day
+func (j *StatisticsRollupJob) RunForDay(ctx context.Context, day time.Time) error {
+	stats, err := j.repo.ComputeDailyStatistics(ctx, day)
+	if err != nil {
+		return fmt.Errorf("failed to compute daily statistics: %w", err)
+	}
+
+	for i := range stats {
+		if err := j.repo.UpsertDailyStatistics(ctx, &stats[i]); err != nil {
+			return fmt.Errorf("failed to upsert daily statistics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Backfill computes and stores statistics snapshots for every day from the day containing from through the day
+// before to. Reads switch over to the snapshots with FlagStatisticsRollupReads, which should only be enabled once the
+// history has been backfilled.
+func (j *StatisticsRollupJob) Backfill(ctx context.Context, from, to time.Time) error {
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := j.RunForDay(ctx, day); err != nil {
+			return fmt.Errorf("failed to backfill %s: %w", day.Format(time.DateOnly), err)
+		}
+	}
+
+	return nil
+}
+
+// statisticsSource provides rollup snapshots and live statistics
+type statisticsSource interface {
+	GetDailyStatistics(ctx context.Context, userID, companionID string) ([]models.DailyUserStatistics, error)
+	ComputeUserDailyStatistics(ctx context.Context, userID, companionID string, since time.Time) ([]models.DailyUserStatistics, error)
+	GetUserStatistics(ctx context.Context, userID, companionID string) (*models.UserStatistics, error)
+}
+
+// userStatisticsFromRollup serves statistics from daily snapshots topped up with a live aggregation from the last
+// snapshot day onward, which is recomputed in case it was rolled up before the day ended. Without snapshots the whole
+// history is aggregated live; if the snapshots cannot be read, the live overall aggregation is used instead.
+func userStatisticsFromRollup(ctx context.Context, source statisticsSource, userID, companionID string) (*models.UserStatistics, error) {
+	snapshots, err := source.GetDailyStatistics(ctx, userID, companionID)
+	if err != nil {
+		return source.GetUserStatistics(ctx, userID, companionID)
+	}
+
+	var since time.Time
+	if len(snapshots) > 0 {
+		since = snapshots[len(snapshots)-1].StatDate
+		snapshots = snapshots[:len(snapshots)-1]
+	}
+	live, err := source.ComputeUserDailyStatistics(ctx, userID, companionID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate recent statistics: %w", err)
+	}
+
+	return mergeDailyStatistics(append(snapshots, live...)), nil
+}
+
+// mergeDailyStatistics combines daily statistics into overall user statistics
+func mergeDailyStatistics(days []models.DailyUserStatistics) *models.UserStatistics {
+	stats := &models.UserStatistics{}
+
+	var totalLength time.Duration
+	var weightedEngagement float64
+	var sessionsByHour [24]int
+	var sessionsByWeekday [7]int
+	for _, day := range days {
+		stats.TotalSessions += day.TotalSessions
+		stats.TotalMessages += day.TotalMessages
+		totalLength += day.AverageSessionLength * time.Duration(day.TotalSessions)
+		weightedEngagement += day.EngagementScore * float64(day.TotalSessions)
+		for hour, sessions := range day.SessionsByHour {
+			sessionsByHour[hour] += sessions
+		}
+		sessionsByWeekday[day.StatDate.Weekday()] += day.TotalSessions
+	}
+
+	if stats.TotalSessions > 0 {
+		stats.AverageSessionLength = totalLength / time.Duration(stats.TotalSessions)
+		stats.AverageMessagesPerSession = float64(stats.TotalMessages) / float64(stats.TotalSessions)
+		stats.EngagementScore = weightedEngagement / float64(stats.TotalSessions)
+		stats.PeakActivityHour = busiest(sessionsByHour[:])
+		stats.MostActiveDay = time.Weekday(busiest(sessionsByWeekday[:])).String()
+	}
+
+	return stats
+}
+
+// busiest returns the index with the most sessions, the earliest on a tie
+func busiest(sessions []int) int {
+	best := 0
+	for i, count := range sessions {
+		if count > sessions[best] {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type fakeStatisticsSource struct {
+	snapshots   []models.DailyUserStatistics
+	snapshotErr error
+	recent      []models.DailyUserStatistics
+	recentSince []time.Time
+	live        *models.UserStatistics
+	liveCalls   int
+}
+
+func (f *fakeStatisticsSource) GetDailyStatistics(ctx context.Context, userID, companionID string) ([]models.DailyUserStatistics, error) {
+	return f.snapshots, f.snapshotErr
+}
+
+func (f *fakeStatisticsSource) ComputeUserDailyStatistics(ctx context.Context, userID, companionID string, since time.Time) ([]models.DailyUserStatistics, error) {
+	f.recentSince = append(f.recentSince, since)
+	var days []models.DailyUserStatistics
+	for _, day := range f.recent {
+		if !day.StatDate.Before(since) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (f *fakeStatisticsSource) GetUserStatistics(ctx context.Context, userID, companionID string) (*models.UserStatistics, error) {
+	f.liveCalls++
+	return f.live, nil
+}
+
+// statisticsDay returns a day of statistics on the given date in September 2026, with its sessions started at hour
+func statisticsDay(date, sessions, messages int, length time.Duration, engagement float64, hour int) models.DailyUserStatistics {
+	day := models.DailyUserStatistics{
+		StatDate:             time.Date(2026, 9, date, 0, 0, 0, 0, time.UTC),
+		TotalSessions:        sessions,
+		TotalMessages:        messages,
+		AverageSessionLength: length,
+		EngagementScore:      engagement,
+	}
+	day.SessionsByHour[hour] = sessions
+	return day
+}
+
+func TestUserStatisticsFromRollup(t *testing.T) {
+	live := &models.UserStatistics{TotalSessions: 42}
+
+	t.Run("serves from snapshots and recomputes the last snapshot day", func(t *testing.T) {
+		// 7 September 2026 is a Monday
+		lastDay := statisticsDay(8, 2, 30, 20*time.Minute, 0.8, 21)
+		source := &fakeStatisticsSource{
+			snapshots: []models.DailyUserStatistics{statisticsDay(7, 2, 10, 10*time.Minute, 0.4, 9), lastDay},
+			recent:    []models.DailyUserStatistics{lastDay},
+			live:      live,
+		}
+
+		stats, err := userStatisticsFromRollup(context.Background(), source, "user", "companion")
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, source.liveCalls)
+		assert.Equal(t, []time.Time{lastDay.StatDate}, source.recentSince)
+		assert.Equal(t, 4, stats.TotalSessions)
+		assert.Equal(t, 40, stats.TotalMessages)
+		assert.Equal(t, 15*time.Minute, stats.AverageSessionLength)
+		assert.InDelta(t, 10.0, stats.AverageMessagesPerSession, 0.001)
+		assert.InDelta(t, 0.6, stats.EngagementScore, 0.001)
+		assert.Equal(t, 9, stats.PeakActivityHour, "ties go to the earliest hour")
+		assert.Equal(t, "Monday", stats.MostActiveDay)
+	})
+
+	t.Run("adds live statistics after the snapshots", func(t *testing.T) {
+		// The snapshots stop on the 8th, which was rolled up before the day ended; sessions continued into the 10th
+		source := &fakeStatisticsSource{
+			snapshots: []models.DailyUserStatistics{
+				statisticsDay(7, 1, 5, 10*time.Minute, 0.4, 8),
+				statisticsDay(8, 1, 5, 10*time.Minute, 0.4, 9),
+			},
+			recent: []models.DailyUserStatistics{
+				statisticsDay(8, 2, 10, 10*time.Minute, 0.4, 9),
+				statisticsDay(10, 3, 30, 20*time.Minute, 0.9, 20),
+			},
+			live: live,
+		}
+
+		stats, err := userStatisticsFromRollup(context.Background(), source, "user", "companion")
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, source.liveCalls)
+		assert.Equal(t, 6, stats.TotalSessions, "the partial snapshot of the 8th is replaced by its live statistics")
+		assert.Equal(t, 45, stats.TotalMessages)
+		assert.Equal(t, 15*time.Minute, stats.AverageSessionLength)
+		assert.InDelta(t, 0.65, stats.EngagementScore, 0.001)
+		assert.Equal(t, 20, stats.PeakActivityHour)
+		assert.Equal(t, "Thursday", stats.MostActiveDay)
+	})
+
+	t.Run("aggregates the whole history live without snapshots", func(t *testing.T) {
+		source := &fakeStatisticsSource{
+			recent: []models.DailyUserStatistics{statisticsDay(9, 3, 12, 5*time.Minute, 0.5, 7)},
+			live:   live,
+		}
+
+		stats, err := userStatisticsFromRollup(context.Background(), source, "user", "companion")
+
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{{}}, source.recentSince)
+		assert.Equal(t, 3, stats.TotalSessions)
+		assert.Equal(t, 7, stats.PeakActivityHour)
+		assert.Equal(t, "Wednesday", stats.MostActiveDay)
+	})
+
+	t.Run("falls back on snapshot error", func(t *testing.T) {
+		source := &fakeStatisticsSource{snapshotErr: errors.New("table missing"), live: live}
+
+		stats, err := userStatisticsFromRollup(context.Background(), source, "user", "companion")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, source.liveCalls)
+		assert.Same(t, live, stats)
+	})
+
+	t.Run("no sessions", func(t *testing.T) {
+		stats, err := userStatisticsFromRollup(context.Background(), &fakeStatisticsSource{}, "user", "companion")
+
+		require.NoError(t, err)
+		assert.Equal(t, &models.UserStatistics{}, stats)
+	})
+}
+
+func TestStatisticsRollupBackfill(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rolls up every day before to", func(mt *mtest.T) {
+		for range 3 {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch))
+		}
+		job := NewStatisticsRollupJob(repositories.NewAnalyticsRepository(nil, mt.DB))
+
+		from := time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, job.Backfill(context.Background(), from, from.AddDate(0, 0, 3)))
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 3)
+		for i, event := range events {
+			match := event.Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+			assert.Equal(t, from.AddDate(0, 0, i), match.Lookup("created_at", "$gte").Time().UTC())
+		}
+	})
+}
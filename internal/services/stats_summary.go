@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// companionHistorySource aggregates a user's message history with a companion
+type companionHistorySource interface {
+	GetCompanionHistoryStats(ctx context.Context, userID, companionID string) (*models.CompanionHistoryStats, error)
+}
+
+// summaryProgressSource reads the gamification data shown on the stats summary card
+type summaryProgressSource interface {
+	GetUserProgress(ctx context.Context, userID, companionID string) (*models.UserProgress, error)
+	SumExperienceSince(ctx context.Context, userID, companionID string, since time.Time) (int, error)
+}
+
+// GetConversationStatsSummary builds the stats summary card for the user's relationship with a companion
+func (s *AnalyticsService) GetConversationStatsSummary(ctx context.Context, userID, companionID string) (*models.StatsSummaryCard, error) {
+	stats, err := s.historyStats.GetCompanionHistoryStats(ctx, userID, companionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	}
+
+	now := s.now()
+	card := &models.StatsSummaryCard{
+		LongestConversation: stats.LongestConversation,
+		FavouriteTime:       stats.FavouriteHour,
+		TopEmotion:          stats.TopEmotion,
+		RelationshipAgeText: humanizeRelationshipAge(0),
+	}
+	if stats.FirstConversationAt != nil {
+		age := now.Sub(*stats.FirstConversationAt)
+		card.TotalDays = int(age.Hours() / 24)
+		card.RelationshipAgeText = humanizeRelationshipAge(age)
+	}
+
+	progress, err := s.summaryStats.GetUserProgress(ctx, userID, companionID)
+	var notFound *apperrors.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("failed to get user progress: %w", err)
+	}
+	if progress != nil {
+		for _, milestone := range progress.StageMilestones {
+			if milestone.Completed {
+				card.MilestonesReached++
+			}
+		}
+	}
+
+	card.XPThisWeek, err = s.summaryStats.SumExperienceSince(ctx, userID, companionID, now.AddDate(0, 0, -7))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experience this week: %w", err)
+	}
+
+	return card, nil
+}
+
+// humanizeRelationshipAge describes how long a relationship has lasted in its largest whole unit, adding months to
+// whole years
+func humanizeRelationshipAge(age time.Duration) string {
+	days := int(math.Floor(age.Hours() / 24))
+	switch {
+	case days < 1:
+		return "less than a day"
+	case days < 7:
+		return plural(days, "day")
+	case days < 30:
+		return plural(days/7, "week")
+	case days < 365:
+		return plural(days/30, "month")
+	}
+
+	years, months := days/365, (days%365)/30
+	if months == 0 {
+		return plural(years, "year")
+	}
+	return plural(years, "year") + ", " + plural(months, "month")
+}
+
+// plural formats n with unit, adding an s unless n is 1
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHistoryStats struct {
+	stats *models.CompanionHistoryStats
+}
+
+func (f *fakeHistoryStats) GetCompanionHistoryStats(context.Context, string, string) (*models.CompanionHistoryStats, error) {
+	return f.stats, nil
+}
+
+type fakeSummaryProgress struct {
+	progress *models.UserProgress
+	xp       int
+	since    time.Time
+}
+
+func (f *fakeSummaryProgress) GetUserProgress(context.Context, string, string) (*models.UserProgress, error) {
+	if f.progress == nil {
+		return nil, apperrors.NewNotFoundError("user progress", nil)
+	}
+	return f.progress, nil
+}
+
+func (f *fakeSummaryProgress) SumExperienceSince(_ context.Context, _, _ string, since time.Time) (int, error) {
+	f.since = since
+	return f.xp, nil
+}
+
+func TestGetConversationStatsSummary(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	first := now.AddDate(0, 0, -45)
+	hour := 21
+
+	newService := func(history *fakeHistoryStats, progress *fakeSummaryProgress) *AnalyticsService {
+		service := NewAnalyticsService(nil, nil, nil)
+		service.historyStats = history
+		service.summaryStats = progress
+		service.now = func() time.Time { return now }
+		return service
+	}
+
+	t.Run("fills the card from history and progress", func(t *testing.T) {
+		progress := &fakeSummaryProgress{
+			progress: &models.UserProgress{StageMilestones: []models.StageMilestone{{Completed: true}, {Completed: false}, {Completed: true}}},
+			xp:       340,
+		}
+		service := newService(&fakeHistoryStats{stats: &models.CompanionHistoryStats{
+			FirstConversationAt: &first,
+			LongestConversation: 128,
+			FavouriteHour:       &hour,
+			TopEmotion:          "joy",
+		}}, progress)
+
+		card, err := service.GetConversationStatsSummary(context.Background(), "user-1", "companion-1")
+		require.NoError(t, err)
+		assert.Equal(t, 45, card.TotalDays)
+		assert.Equal(t, "1 month", card.RelationshipAgeText)
+		assert.Equal(t, 128, card.LongestConversation)
+		require.NotNil(t, card.FavouriteTime)
+		assert.Equal(t, 21, *card.FavouriteTime)
+		assert.Equal(t, "joy", card.TopEmotion)
+		assert.Equal(t, 2, card.MilestonesReached)
+		assert.Equal(t, 340, card.XPThisWeek)
+		assert.Equal(t, now.AddDate(0, 0, -7), progress.since)
+	})
+
+	t.Run("a new relationship has an empty card", func(t *testing.T) {
+		service := newService(&fakeHistoryStats{stats: &models.CompanionHistoryStats{}}, &fakeSummaryProgress{})
+
+		card, err := service.GetConversationStatsSummary(context.Background(), "user-1", "companion-1")
+		require.NoError(t, err)
+		assert.Zero(t, card.TotalDays)
+		assert.Equal(t, "less than a day", card.RelationshipAgeText)
+		assert.Nil(t, card.FavouriteTime)
+		assert.Zero(t, card.MilestonesReached)
+		assert.Zero(t, card.XPThisWeek)
+	})
+}
+
+func TestHumanizeRelationshipAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: 3 * time.Hour, want: "less than a day"},
+		{age: day, want: "1 day"},
+		{age: 5 * day, want: "5 days"},
+		{age: 15 * day, want: "2 weeks"},
+		{age: 95 * day, want: "3 months"},
+		{age: 370 * day, want: "1 year"},
+		{age: 800 * day, want: "2 years, 2 months"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, humanizeRelationshipAge(tt.age), tt.age.String())
+	}
+}
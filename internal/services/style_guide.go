@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+const (
+	// styleGuideScanInterval is how often companion responses are mined for signature expressions
+	styleGuideScanInterval = 24 * time.Hour
+	// styleGuideLookback is how far back companion responses are read
+	styleGuideLookback = 7 * 24 * time.Hour
+	// styleGuideSampleSize caps the companion responses sent to the LLM at once
+	styleGuideSampleSize = 100
+	// styleGuideMinSamples is the fewest responses expressions are extracted from
+	styleGuideMinSamples = 20
+	// maxProposedExpressions caps the expressions waiting to be accepted into a style guide
+	maxProposedExpressions = 20
+)
+
+// styleGuideSource loads companion style guides
+type styleGuideSource interface {
+	GetStyleGuide(ctx context.Context, companionID string) (*models.ConversationStyleGuide, error)
+}
+
+// styleGuideStore reads and writes companion style guides
+type styleGuideStore interface {
+	styleGuideSource
+	UpdateStyleGuide(ctx context.Context, guide *models.ConversationStyleGuide) error
+}
+
+// companionResponseSource reads what companions have been saying
+type companionResponseSource interface {
+	ListActiveCompanionIDs(ctx context.Context, since time.Time) ([]string, error)
+	ListRecentCompanionMessages(ctx context.Context, companionID string, since time.Time, limit int) ([]*models.Message, error)
+}
+
+// StyleGuideExtractionJob learns the expressions each companion keeps coming back to and proposes them for its
+// style guide. Proposals are not used in prompts until they are accepted as signature expressions.
+type StyleGuideExtractionJob struct {
+	llm       LLMClient
+	responses companionResponseSource
+	guides    styleGuideStore
+	now       func() time.Time
+	after     func(time.Duration) <-chan time.Time
+
+	options ServiceConfig
+}
+
+// NewStyleGuideExtractionJob creates a new style guide extraction job
+func NewStyleGuideExtractionJob(llm LLMClient, responses companionResponseSource, guides styleGuideStore, opts ...Option) *StyleGuideExtractionJob {
+	return &StyleGuideExtractionJob{
+		llm:       llm,
+		responses: responses,
+		guides:    guides,
+		now:       time.Now,
+		after:     time.After,
+		options:   newServiceConfig(opts),
+	}
+}
+
+// Start proposes expressions immediately and then once a day until ctx is cancelled
+func (j *StyleGuideExtractionJob) Start(ctx context.Context) {
+	for {
+		if err := j.ProposeExpressions(ctx); err != nil {
+			j.options.logger().Error("Style guide extraction job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.after(styleGuideScanInterval):
+		}
+	}
+}
+
+// ProposeExpressions extracts signature expressions for every companion that has spoken recently
+func (j *StyleGuideExtractionJob) ProposeExpressions(ctx context.Context) error {
+	since := j.now().Add(-styleGuideLookback)
+	companionIDs, err := j.responses.ListActiveCompanionIDs(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	for _, companionID := range companionIDs {
+		if _, err := j.ProposeExpressionsFor(ctx, companionID); err != nil {
+			j.options.logger().Error("Failed to propose style guide expressions", "companion_id", companionID, "error", err)
+		}
+	}
+	return nil
+}
+
+// ProposeExpressionsFor asks the LLM for the expressions characteristic of the companion's recent responses and adds
+// the new ones to its style guide's proposals, returning the expressions added
+func (j *StyleGuideExtractionJob) ProposeExpressionsFor(ctx context.Context, companionID string) ([]string, error) {
+	messages, err := j.responses.ListRecentCompanionMessages(ctx, companionID, j.now().Add(-styleGuideLookback), styleGuideSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []string
+	for _, msg := range messages {
+		if msg.Text != nil && strings.TrimSpace(*msg.Text) != "" {
+			responses = append(responses, *msg.Text)
+		}
+	}
+	if len(responses) < styleGuideMinSamples {
+		return nil, nil
+	}
+
+	guide, err := j.guides.GetStyleGuide(ctx, companionID)
+	if err != nil {
+		return nil, err
+	}
+	if guide == nil {
+		guide = &models.ConversationStyleGuide{CompanionID: companionID, LexicalLevel: models.LexicalLevelCasual}
+	}
+
+	response, err := j.llm.SendMessage(ctx, []LLMMessage{{Role: "user", Content: buildExpressionPrompt(responses)}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract signature expressions: %w", err)
+	}
+	expressions, err := parseExpressions(response)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, list := range [][]string{guide.SignatureExpressions, guide.AvoidedPhrases, guide.ProposedExpressions} {
+		for _, phrase := range list {
+			known[strings.ToLower(phrase)] = true
+		}
+	}
+
+	var added []string
+	for _, expression := range expressions {
+		if len(guide.ProposedExpressions) >= maxProposedExpressions {
+			break
+		}
+		if known[strings.ToLower(expression)] {
+			continue
+		}
+		known[strings.ToLower(expression)] = true
+		guide.ProposedExpressions = append(guide.ProposedExpressions, expression)
+		added = append(added, expression)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := j.guides.UpdateStyleGuide(ctx, guide); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// buildExpressionPrompt asks the LLM for the phrases a companion repeats across its responses
+func buildExpressionPrompt(responses []string) string {
+	return fmt.Sprintf(`Below are recent messages written by one character. List up to 5 short expressions or turns of phrase that are characteristic of how this character talks and that appear in more than one message. Ignore generic greetings and filler.
+
+Respond with a JSON array of strings only.
+
+MESSAGES:
+%s`, strings.Join(responses, "\n---\n"))
+}
+
+// parseExpressions reads the JSON array of expressions returned by the LLM, dropping blank entries
+func parseExpressions(response string) ([]string, error) {
+	response = strings.TrimSpace(response)
+	if strings.HasPrefix(response, "```json") {
+		response = strings.TrimPrefix(response, "```json")
+		response = strings.TrimSuffix(response, "```")
+	}
+
+	var raw []string
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse signature expressions: %w", err)
+	}
+
+	expressions := make([]string, 0, len(raw))
+	for _, expression := range raw {
+		if expression = strings.TrimSpace(expression); expression != "" {
+			expressions = append(expressions, expression)
+		}
+	}
+	return expressions, nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type memoryStyleGuideStore struct {
+	guides  map[string]*models.ConversationStyleGuide
+	updates int
+}
+
+func (m *memoryStyleGuideStore) GetStyleGuide(ctx context.Context, companionID string) (*models.ConversationStyleGuide, error) {
+	return m.guides[companionID], nil
+}
+
+func (m *memoryStyleGuideStore) UpdateStyleGuide(ctx context.Context, guide *models.ConversationStyleGuide) error {
+	m.updates++
+	m.guides[guide.CompanionID] = guide
+	return nil
+}
+
+type fakeCompanionResponses struct {
+	messages []*models.Message
+}
+
+func (f fakeCompanionResponses) ListActiveCompanionIDs(ctx context.Context, since time.Time) ([]string, error) {
+	return []string{"companion-1"}, nil
+}
+
+func (f fakeCompanionResponses) ListRecentCompanionMessages(ctx context.Context, companionID string, since time.Time, limit int) ([]*models.Message, error) {
+	return f.messages, nil
+}
+
+func companionResponses(n int) []*models.Message {
+	messages := make([]*models.Message, n)
+	for i := range messages {
+		text := fmt.Sprintf("oh my stars, that's wild #%d", i)
+		messages[i] = &models.Message{Text: &text}
+	}
+	return messages
+}
+
+func TestConversationStyleGuideSerialisation(t *testing.T) {
+	guide := models.ConversationStyleGuide{
+		CompanionID:          "companion-1",
+		SignatureExpressions: []string{"oh my stars"},
+		AvoidedPhrases:       []string{"as an AI"},
+		FavouriteTopics:      []string{"astronomy"},
+		LexicalLevel:         models.LexicalLevelElevated,
+		UpdatedAt:            time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	raw, err := bson.Marshal(guide)
+	require.NoError(t, err)
+	var doc bson.M
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+	assert.Equal(t, "elevated", doc["lexical_level"])
+	assert.Equal(t, bson.A{"oh my stars"}, doc["signature_expressions"])
+	assert.NotContains(t, doc, "proposed_expressions", "empty proposals are left out")
+	assert.NotContains(t, doc, "_id", "new guides get their ID from MongoDB")
+
+	var decoded models.ConversationStyleGuide
+	require.NoError(t, bson.Unmarshal(raw, &decoded))
+	assert.Equal(t, guide.SignatureExpressions, decoded.SignatureExpressions)
+	assert.Equal(t, guide.AvoidedPhrases, decoded.AvoidedPhrases)
+	assert.Equal(t, guide.FavouriteTopics, decoded.FavouriteTopics)
+	assert.True(t, guide.UpdatedAt.Equal(decoded.UpdatedAt))
+
+	body, err := json.Marshal(guide)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"favourite_topics":["astronomy"]`)
+}
+
+func TestBaseIdentityLayerIncludesStyleGuide(t *testing.T) {
+	service := NewAIContextService(nil, nil, nil)
+	profile := &models.CompanionProfile{}
+
+	without := service.buildBaseIdentityLayer(context.Background(), "user-1", profile, nil)
+	assert.NotContains(t, without, "YOUR STYLE:")
+	assert.Equal(t, without, service.buildBaseIdentityLayer(context.Background(), "user-1", profile, &models.ConversationStyleGuide{}), "an empty guide adds nothing")
+
+	prompt := service.buildBaseIdentityLayer(context.Background(), "user-1", profile, &models.ConversationStyleGuide{
+		SignatureExpressions: []string{"oh my stars", "cosmic"},
+		AvoidedPhrases:       []string{"as an AI"},
+		FavouriteTopics:      []string{"astronomy", "tea"},
+		LexicalLevel:         models.LexicalLevelCasual,
+		ProposedExpressions:  []string{"not yet accepted"},
+	})
+	assert.True(t, len(prompt) > len(without))
+	assert.Equal(t, without, prompt[:len(without)], "the style guide is appended after the identity")
+	assert.Contains(t, prompt, `"oh my stars", "cosmic"`)
+	assert.Contains(t, prompt, `Phrases you never use: "as an AI"`)
+	assert.Contains(t, prompt, "astronomy, tea")
+	assert.Contains(t, prompt, "casual")
+	assert.NotContains(t, prompt, "not yet accepted")
+}
+
+func TestSparksUseStyleGuide(t *testing.T) {
+	llm := &mockLLM{response: `["one", "two"]`}
+	service := newSparkTestService(llm)
+	service.styleGuides = &memoryStyleGuideStore{guides: map[string]*models.ConversationStyleGuide{
+		"companion-1": {CompanionID: "companion-1", SignatureExpressions: []string{"oh my stars"}},
+	}}
+
+	_, err := service.GenerateConversationSparks(context.Background(), "user-1", "companion-1", 2)
+	require.NoError(t, err)
+	require.Len(t, llm.prompts, 1)
+	assert.Contains(t, llm.prompts[0][0].Content, `"oh my stars"`)
+}
+
+func TestStyleGuideExtractionJob(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("proposes new expressions only", func(t *testing.T) {
+		store := &memoryStyleGuideStore{guides: map[string]*models.ConversationStyleGuide{
+			"companion-1": {CompanionID: "companion-1", SignatureExpressions: []string{"Oh my stars"}, AvoidedPhrases: []string{"as an AI"}},
+		}}
+		llm := &mockLLM{response: "```json\n[\"oh my stars\", \"that's wild\", \"As an AI\", \" \"]\n```"}
+		job := NewStyleGuideExtractionJob(llm, fakeCompanionResponses{messages: companionResponses(styleGuideMinSamples)}, store)
+
+		require.NoError(t, job.ProposeExpressions(ctx))
+
+		guide := store.guides["companion-1"]
+		assert.Equal(t, []string{"that's wild"}, guide.ProposedExpressions)
+		assert.Equal(t, []string{"Oh my stars"}, guide.SignatureExpressions, "accepted expressions are left alone")
+		require.Len(t, llm.prompts, 1)
+		assert.Contains(t, llm.prompts[0][0].Content, "that's wild #0")
+
+		added, err := job.ProposeExpressionsFor(ctx, "companion-1")
+		require.NoError(t, err)
+		assert.Empty(t, added)
+		assert.Equal(t, 1, store.updates, "nothing new is not written")
+	})
+
+	t.Run("creates a guide for companions without one", func(t *testing.T) {
+		store := &memoryStyleGuideStore{guides: map[string]*models.ConversationStyleGuide{}}
+		job := NewStyleGuideExtractionJob(&mockLLM{response: `["cosmic"]`}, fakeCompanionResponses{messages: companionResponses(styleGuideMinSamples)}, store)
+
+		added, err := job.ProposeExpressionsFor(ctx, "companion-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cosmic"}, added)
+		assert.Equal(t, models.LexicalLevelCasual, store.guides["companion-1"].LexicalLevel)
+	})
+
+	t.Run("skips companions with too few responses", func(t *testing.T) {
+		llm := &mockLLM{response: `["cosmic"]`}
+		store := &memoryStyleGuideStore{guides: map[string]*models.ConversationStyleGuide{}}
+		job := NewStyleGuideExtractionJob(llm, fakeCompanionResponses{messages: companionResponses(styleGuideMinSamples - 1)}, store)
+
+		added, err := job.ProposeExpressionsFor(ctx, "companion-1")
+		require.NoError(t, err)
+		assert.Empty(t, added)
+		assert.Empty(t, llm.prompts)
+	})
+}
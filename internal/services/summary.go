@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// summaryMessageThreshold is the message count above which a conversation gets summarised
+	summaryMessageThreshold = 200
+	// summaryRetainedMessages is the number of most recent messages left out of the summary
+	summaryRetainedMessages = 50
+	// summaryBatchSize caps the number of messages condensed in a single LLM call
+	summaryBatchSize = 500
+)
+
+// SummaryService condenses older conversation messages into summaries used by the prompt builder
+type SummaryService struct {
+	grokService LLMClient
+	repo        *repositories.ConversationRepository
+	interval    time.Duration
+
+	options ServiceConfig
+}
+
+// NewSummaryService creates a new conversation summary service
+func NewSummaryService(grokService LLMClient, repo *repositories.ConversationRepository, opts ...Option) *SummaryService {
+	return &SummaryService{
+		grokService: grokService,
+		repo:        repo,
+		interval:    time.Hour,
+		options:     newServiceConfig(opts),
+	}
+}
+
+// Start summarises long conversations immediately and then every hour until ctx is cancelled
+func (s *SummaryService) Start(ctx context.Context) {
+	for {
+		if err := s.SummarizeLongConversations(ctx); err != nil {
+			s.options.logger().Error("Conversation summary job failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// SummarizeLongConversations summarises every conversation holding more than summaryMessageThreshold messages
+func (s *SummaryService) SummarizeLongConversations(ctx context.Context) error {
+	conversationIDs, err := s.repo.ListConversationsWithMessagesOver(ctx, summaryMessageThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to find long conversations: %w", err)
+	}
+
+	for _, conversationID := range conversationIDs {
+		recent, _, _, err := s.repo.ListMessages(ctx, conversationID, summaryRetainedMessages, nil)
+		if err != nil {
+			s.options.logger().Error("Failed to list recent messages", "conversation_id", conversationID.Hex(), "error", err)
+			continue
+		}
+		if len(recent) < summaryRetainedMessages {
+			continue
+		}
+
+		// Messages are returned newest first, so the last one is the oldest message kept verbatim
+		if _, err := s.SummarizeOldMessages(ctx, conversationID, recent[len(recent)-1].ID); err != nil {
+			s.options.logger().Error("Failed to summarise conversation", "conversation_id", conversationID.Hex(), "error", err)
+		}
+	}
+
+	return nil
+}
+
+// SummarizeOldMessages condenses messages older than beforeMessageID that no summary covers yet.
+// The new summary builds on the previous one; if there is nothing new the previous summary is returned.
+func (s *SummaryService) SummarizeOldMessages(ctx context.Context, conversationID, beforeMessageID primitive.ObjectID) (*models.ConversationHistorySummary, error) {
+	previous, err := s.repo.GetLatestConversationSummary(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *primitive.ObjectID
+	previousCount := 0
+	if previous != nil {
+		after = &previous.ThroughMessageID
+		previousCount = previous.MessageCount
+	}
+
+	messages, err := s.repo.ListMessagesBetween(ctx, conversationID, after, beforeMessageID, summaryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return previous, nil
+	}
+
+	response, err := s.grokService.SendMessage(ctx, buildSummaryPrompt(previous, messages))
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarise messages: %w", err)
+	}
+
+	summary := &models.ConversationHistorySummary{
+		ConversationID:   conversationID,
+		Summary:          strings.TrimSpace(response),
+		ThroughMessageID: messages[len(messages)-1].ID,
+		MessageCount:     previousCount + len(messages),
+		CreatedAt:        time.Now(),
+	}
+	if err := s.repo.SaveConversationSummary(ctx, summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// buildSummaryPrompt asks the LLM to fold new messages into the previous summary
+func buildSummaryPrompt(previous *models.ConversationHistorySummary, messages []*models.Message) []LLMMessage {
+	var transcript []string
+	for _, msg := range messages {
+		if msg.Text == nil {
+			continue
+		}
+		sender := "User"
+		if msg.SenderType == "companion" {
+			sender = "Companion"
+		}
+		transcript = append(transcript, fmt.Sprintf("%s: %s", sender, *msg.Text))
+	}
+
+	previousSummary := "None"
+	if previous != nil {
+		previousSummary = previous.Summary
+	}
+
+	prompt := fmt.Sprintf(`Summary of the conversation so far:
+%s
+
+New messages:
+%s
+
+Write an updated summary of the whole conversation in at most 200 words. Keep names, facts the user shared about themselves, plans, feelings and anything the companion promised. Respond with ONLY the summary.`,
+		previousSummary, strings.Join(transcript, "\n"))
+
+	return []LLMMessage{
+		{Role: "system", Content: "You summarise conversations between a user and their AI companion."},
+		{Role: "user", Content: prompt},
+	}
+}
+
+// withConversationSummary prepends a summary of older messages to the prompt
+func withConversationSummary(prompt string, summary *models.ConversationHistorySummary) string {
+	if summary == nil || summary.Summary == "" {
+		return prompt
+	}
+	return fmt.Sprintf("EARLIER IN THIS CONVERSATION:\n%s\n\n%s", summary.Summary, prompt)
+}
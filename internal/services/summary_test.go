@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestBuildDynamicPromptIncludesSummary(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("prepends latest summary", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "current_topic", Value: "travel"},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.conversation_summaries", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "summary", Value: "The user is planning a trip to Lisbon with their sister."},
+				{Key: "message_count", Value: 240},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.special_dates", mtest.FirstBatch),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewAIContextService(&mockLLM{}, repositories.NewConversationRepository(mt.DB), nil)
+		conversation := &models.Conversation{ID: conversationID, UserID: "user-1"}
+		userMsg := &models.Message{ID: primitive.NewObjectID(), ConversationID: conversationID, Type: "photo"}
+
+		prompt, err := service.BuildDynamicPrompt(context.Background(), conversation, userMsg, &models.CompanionProfile{Backstory: "A stargazer from Lisbon."})
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(prompt, "EARLIER IN THIS CONVERSATION:\nThe user is planning a trip to Lisbon with their sister.\n\n"))
+		assert.Contains(t, prompt, "A stargazer from Lisbon.")
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 6)
+		assert.Equal(t, "conversation_summaries", events[2].Command.Lookup("find").StringValue())
+		assert.Equal(t, "special_dates", events[3].Command.Lookup("find").StringValue())
+	})
+}
+
+func TestSummarizeOldMessages(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("folds new messages into previous summary", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		previousThrough := primitive.NewObjectIDFromTimestamp(time.Now().Add(-2 * time.Hour))
+		firstID := primitive.NewObjectIDFromTimestamp(time.Now().Add(-time.Hour))
+		lastID := primitive.NewObjectIDFromTimestamp(time.Now().Add(-30 * time.Minute))
+		beforeID := primitive.NewObjectID()
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_summaries", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "summary", Value: "They talked about the user's new job."},
+				{Key: "through_message_id", Value: previousThrough},
+				{Key: "message_count", Value: 100},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: firstID}, {Key: "sender_type", Value: "user"}, {Key: "text", Value: "My first week went great"}},
+				bson.D{{Key: "_id", Value: lastID}, {Key: "sender_type", Value: "companion"}, {Key: "text", Value: "I'm so proud of you"}},
+			),
+			mtest.CreateSuccessResponse(),
+		)
+
+		llm := &mockLLM{response: "  The user started a new job and had a great first week.\n"}
+		service := NewSummaryService(llm, repositories.NewConversationRepository(mt.DB))
+
+		summary, err := service.SummarizeOldMessages(context.Background(), conversationID, beforeID)
+		require.NoError(t, err)
+
+		assert.Equal(t, "The user started a new job and had a great first week.", summary.Summary)
+		assert.Equal(t, lastID, summary.ThroughMessageID)
+		assert.Equal(t, 102, summary.MessageCount)
+
+		require.Len(t, llm.prompts, 1)
+		assert.Contains(t, llm.prompts[0][1].Content, "They talked about the user's new job.")
+		assert.Contains(t, llm.prompts[0][1].Content, "User: My first week went great\nCompanion: I'm so proud of you")
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 3)
+		idFilter := events[1].Command.Lookup("filter", "_id").Document()
+		assert.Equal(t, previousThrough, idFilter.Lookup("$gt").ObjectID())
+		assert.Equal(t, beforeID, idFilter.Lookup("$lt").ObjectID())
+		assert.Equal(t, "insert", events[2].CommandName)
+	})
+
+	mt.Run("returns previous summary when nothing is new", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_summaries", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "summary", Value: "Nothing new."},
+				{Key: "through_message_id", Value: primitive.NewObjectID()},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch),
+		)
+
+		llm := &mockLLM{}
+		service := NewSummaryService(llm, repositories.NewConversationRepository(mt.DB))
+
+		summary, err := service.SummarizeOldMessages(context.Background(), conversationID, primitive.NewObjectID())
+		require.NoError(t, err)
+		assert.Equal(t, "Nothing new.", summary.Summary)
+		assert.Empty(t, llm.prompts)
+	})
+}
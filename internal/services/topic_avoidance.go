@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// topicAvoidanceWindow is how many recent messages are checked for the user steering away from the current topic
+const topicAvoidanceWindow = 20
+
+// detectTopicAvoidance reports whether the user, whose latest message is userMsg, is steering away from the
+// conversation's current topic, and returns that topic
+func (s *AIContextService) detectTopicAvoidance(ctx context.Context, conversationContext *models.ConversationContext, userMsg *models.Message) (bool, string) {
+	if userMsg.Text == nil || conversationContext.CurrentTopic == defaultTopic {
+		return false, ""
+	}
+
+	messages, _, _, err := s.repo.ListMessages(ctx, conversationContext.ConversationID, topicAvoidanceWindow, nil)
+	if err != nil {
+		s.options.logger().Error("Failed to load recent messages for topic avoidance", "error", err)
+		return false, ""
+	}
+
+	slices.Reverse(messages)
+	if len(messages) == 0 || messages[len(messages)-1].ID != userMsg.ID {
+		messages = append(messages, userMsg)
+	}
+	return analytics.DetectTopicAvoidance(messages, conversationContext.CurrentTopic)
+}
+
+// withTopicAvoidanceHint asks the companion to let a topic the user is avoiding rest for now and revisit it gently
+// later, leaving prompt unchanged when no topic is avoided
+func withTopicAvoidanceHint(prompt, topic string) string {
+	if topic == "" {
+		return prompt
+	}
+	return fmt.Sprintf(`%s
+
+TOPIC AVOIDANCE:
+The user keeps changing the subject away from %s. Follow their lead for now and do not bring it up again right away.
+Later, when the moment feels right, gently and briefly check in about it, and let it go if they still do not want to talk.`,
+		prompt, topic)
+}
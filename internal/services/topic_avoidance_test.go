@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/analytics"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestBuildDynamicPromptFlagsTopicAvoidance(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	buildPrompt := func(mt *mtest.T, companionFollowUp string) (string, bson.Raw) {
+		conversationID := primitive.NewObjectID()
+		start := time.Now().Add(-time.Hour)
+		message := func(minute int, senderType, text string) bson.D {
+			return bson.D{
+				{Key: "_id", Value: primitive.NewObjectIDFromTimestamp(start.Add(time.Duration(minute) * time.Minute))},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "sender_type", Value: senderType},
+				{Key: "text", Value: text},
+			}
+		}
+		// Newest first, as the repository lists them
+		recent := []bson.D{
+			message(5, "user", "I'm thinking of getting a new phone"),
+			message(4, "companion", "Ooh, which one?"),
+			message(3, "user", "Did you see the game last night?"),
+			message(2, "companion", companionFollowUp),
+			message(1, "user", "My dad's surgery is next week"),
+		}
+		text := "Maybe the green one"
+		userMsg := &models.Message{ID: primitive.NewObjectID(), ConversationID: conversationID, SenderType: "user", Text: &text}
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "current_topic", Value: "surgery"},
+			}),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, recent...),
+			mtest.CreateCursorResponse(0, "lunaria.messages", mtest.FirstBatch, recent[0]),
+			mtest.CreateCursorResponse(0, "lunaria.conversation_summaries", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.special_dates", mtest.FirstBatch),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		llm := &mockLLM{response: `{"primary_emotion": "neutral", "intensity": 0.4, "confidence": 0.8}`}
+		service := NewAIContextService(llm, repositories.NewConversationRepository(mt.DB), nil)
+		conversation := &models.Conversation{ID: conversationID, UserID: "user-1"}
+
+		prompt, err := service.BuildDynamicPrompt(context.Background(), conversation, userMsg, &models.CompanionProfile{})
+		require.NoError(mt, err)
+
+		var saved bson.Raw
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "findAndModify" {
+				saved = event.Command.Lookup("update", "$set").Document()
+			}
+		}
+		require.NotNil(mt, saved)
+		return prompt, saved
+	}
+
+	mt.Run("user changes the subject after a follow-up", func(mt *mtest.T) {
+		prompt, saved := buildPrompt(mt, "How are you feeling about the surgery?")
+
+		assert.Contains(t, prompt, "TOPIC AVOIDANCE:\nThe user keeps changing the subject away from surgery.")
+		triggers, err := saved.Lookup("user_emotional_state", "triggers").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, triggers, 1)
+		assert.Equal(t, analytics.TopicAvoidanceTrigger, triggers[0].StringValue())
+	})
+
+	mt.Run("companion did not follow up", func(mt *mtest.T) {
+		prompt, saved := buildPrompt(mt, "Oh no, I'm sorry.")
+
+		assert.NotContains(t, prompt, "TOPIC AVOIDANCE")
+		assert.NotEqual(t, bson.TypeArray, saved.Lookup("user_emotional_state", "triggers").Type)
+	})
+}
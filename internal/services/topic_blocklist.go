@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SystemEventBlockedTopic is the system event sent in place of a companion reply when the user raises a blocked topic
+const SystemEventBlockedTopic = "blocked_topic"
+
+// blockedTopicMessage is shown to the user in the blocked topic system event
+const blockedTopicMessage = "This topic can't be discussed here. Let's talk about something else."
+
+// topicBlocklistRefreshInterval is how often the blocklist stored in the database is reloaded
+const topicBlocklistRefreshInterval = 5 * time.Minute
+
+// blockedTopicSource lists the blocked topics operators have stored, implemented by
+// *repositories.ConversationRepository
+type blockedTopicSource interface {
+	ListBlockedTopics(ctx context.Context) ([]string, error)
+}
+
+// BlockedTopicError is returned by DetectAndUpdateTopic when a message's topic is on the blocklist
+type BlockedTopicError struct {
+	Topic   string
+	Pattern string // the blocklist entry the topic matched
+}
+
+func (e *BlockedTopicError) Error() string {
+	return fmt.Sprintf("topic %q is blocked by %q", e.Topic, e.Pattern)
+}
+
+// TopicBlocklistFilter keeps the companion away from topics an operator has blocked, such as on platforms for
+// minors. Topics come from configuration and the database. An entry ending in * blocks every topic starting with the
+// rest of it; other entries block that topic only. Matching ignores case. A nil filter blocks nothing.
+type TopicBlocklistFilter struct {
+	configured []string
+	source     blockedTopicSource
+
+	mu     sync.RWMutex
+	topics []string
+
+	options ServiceConfig
+}
+
+// NewTopicBlocklistFilter creates a filter blocking the configured topics. The source, which is optional, adds the
+// topics stored in the database once Load has run.
+func NewTopicBlocklistFilter(configured []string, source blockedTopicSource, opts ...Option) *TopicBlocklistFilter {
+	f := &TopicBlocklistFilter{
+		configured: normalizeBlockedTopics(configured),
+		source:     source,
+		options:    newServiceConfig(opts),
+	}
+	f.topics = f.configured
+	return f
+}
+
+// Load merges the topics stored in the database with the configured ones. On error the previous blocklist is kept.
+func (f *TopicBlocklistFilter) Load(ctx context.Context) error {
+	if f.source == nil {
+		return nil
+	}
+
+	stored, err := f.source.ListBlockedTopics(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load blocked topics: %w", err)
+	}
+
+	topics := normalizeBlockedTopics(append(slices.Clone(f.configured), stored...))
+	f.mu.Lock()
+	f.topics = topics
+	f.mu.Unlock()
+	return nil
+}
+
+// Start reloads the stored blocklist every few minutes until ctx is cancelled. Call Load first so the stored topics
+// are blocked from startup.
+func (f *TopicBlocklistFilter) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(topicBlocklistRefreshInterval):
+		}
+
+		if err := f.Load(ctx); err != nil {
+			f.options.logger().Error("Topic blocklist refresh failed", "error", err)
+		}
+	}
+}
+
+// Topics returns the blocklist entries
+func (f *TopicBlocklistFilter) Topics() []string {
+	if f == nil {
+		return nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.topics
+}
+
+// Match returns the blocklist entry that blocks topic, if any
+func (f *TopicBlocklistFilter) Match(topic string) (string, bool) {
+	topic = strings.ToLower(strings.TrimSpace(topic))
+	if topic == "" {
+		return "", false
+	}
+
+	for _, entry := range f.Topics() {
+		if prefix, wildcard := strings.CutSuffix(entry, "*"); wildcard {
+			if strings.HasPrefix(topic, prefix) {
+				return entry, true
+			}
+		} else if topic == entry {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// WithBlockedTopics appends an instruction never to discuss the blocked topics to prompt, leaving it unchanged when
+// nothing is blocked
+func (f *TopicBlocklistFilter) WithBlockedTopics(prompt string) string {
+	topics := f.Topics()
+	if len(topics) == 0 {
+		return prompt
+	}
+
+	var lines []string
+	for _, topic := range topics {
+		if prefix, wildcard := strings.CutSuffix(topic, "*"); wildcard {
+			lines = append(lines, fmt.Sprintf("- anything about %s or related subjects", prefix))
+		} else {
+			lines = append(lines, "- "+topic)
+		}
+	}
+
+	return fmt.Sprintf("%s\n\nNEVER discuss the following topics, even if the user asks directly. Gently change the subject instead:\n%s",
+		prompt, strings.Join(lines, "\n"))
+}
+
+// normalizeBlockedTopics lowercases and trims the entries, dropping empty ones and duplicates
+func normalizeBlockedTopics(entries []string) []string {
+	var topics []string
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry != "" && entry != "*" && !slices.Contains(topics, entry) {
+			topics = append(topics, entry)
+		}
+	}
+	return topics
+}
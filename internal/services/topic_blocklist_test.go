@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/enums/sendertype"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type fakeBlockedTopicSource struct {
+	topics []string
+}
+
+func (f *fakeBlockedTopicSource) ListBlockedTopics(context.Context) ([]string, error) {
+	return f.topics, nil
+}
+
+func TestTopicBlocklistMatch(t *testing.T) {
+	filter := NewTopicBlocklistFilter([]string{" Drug* ", "gambling", ""}, nil)
+
+	for _, tc := range []struct {
+		topic   string
+		pattern string
+		blocked bool
+	}{
+		{topic: "drugs", pattern: "drug*", blocked: true},
+		{topic: "Drug Use", pattern: "drug*", blocked: true},
+		{topic: "gambling", pattern: "gambling", blocked: true},
+		{topic: "GAMBLING", pattern: "gambling", blocked: true},
+		{topic: "gambling addiction", blocked: false},
+		{topic: "travel", blocked: false},
+		{topic: "", blocked: false},
+	} {
+		pattern, blocked := filter.Match(tc.topic)
+		assert.Equal(t, tc.blocked, blocked, tc.topic)
+		assert.Equal(t, tc.pattern, pattern, tc.topic)
+	}
+
+	var nilFilter *TopicBlocklistFilter
+	_, blocked := nilFilter.Match("drugs")
+	assert.False(t, blocked)
+}
+
+func TestTopicBlocklistLoadMergesStoredTopics(t *testing.T) {
+	source := &fakeBlockedTopicSource{topics: []string{"Politics", "gambling"}}
+	filter := NewTopicBlocklistFilter([]string{"gambling"}, source)
+
+	_, blocked := filter.Match("politics")
+	assert.False(t, blocked, "stored topics are blocked once loaded")
+
+	require.NoError(t, filter.Load(context.Background()))
+	assert.Equal(t, []string{"gambling", "politics"}, filter.Topics())
+
+	source.topics = nil
+	require.NoError(t, filter.Load(context.Background()))
+	assert.Equal(t, []string{"gambling"}, filter.Topics(), "configured topics survive a reload")
+}
+
+func TestTopicBlocklistWithBlockedTopics(t *testing.T) {
+	assert.Equal(t, "prompt", NewTopicBlocklistFilter(nil, nil).WithBlockedTopics("prompt"))
+
+	prompt := NewTopicBlocklistFilter([]string{"drug*", "gambling"}, nil).WithBlockedTopics("prompt")
+	assert.Contains(t, prompt, "NEVER discuss the following topics")
+	assert.Contains(t, prompt, "- anything about drug or related subjects")
+	assert.Contains(t, prompt, "- gambling")
+}
+
+func conversationContextResponse(conversationID primitive.ObjectID) bson.D {
+	return mtest.CreateCursorResponse(0, "lunaria.conversation_contexts", mtest.FirstBatch, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "conversation_id", Value: conversationID},
+		{Key: "current_topic", Value: "general"},
+	})
+}
+
+func TestDetectAndUpdateTopicBlocklist(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	blocklist := NewTopicBlocklistFilter([]string{"drug*"}, nil)
+
+	mt.Run("blocked topic is not saved", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(conversationContextResponse(conversationID))
+
+		service := NewAIContextService(&mockLLM{response: "Drugs"}, repositories.NewConversationRepository(mt.DB), nil, WithTopicBlocklist(blocklist))
+
+		err := service.DetectAndUpdateTopic(context.Background(), conversationID, "where can I buy some?")
+		var blockedErr *BlockedTopicError
+		require.ErrorAs(t, err, &blockedErr)
+		assert.Equal(t, "drugs", blockedErr.Topic)
+		assert.Equal(t, "drug*", blockedErr.Pattern)
+		assert.Len(t, mt.GetAllStartedEvents(), 1)
+	})
+
+	mt.Run("other topics are saved", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			conversationContextResponse(conversationID),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateSuccessResponse(),
+		)
+
+		service := NewAIContextService(&mockLLM{response: "Travel"}, repositories.NewConversationRepository(mt.DB), nil, WithTopicBlocklist(blocklist))
+
+		require.NoError(t, service.DetectAndUpdateTopic(context.Background(), conversationID, "I just booked flights to Lisbon!"))
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 3)
+		assert.Equal(t, "findAndModify", events[1].CommandName)
+	})
+}
+
+func TestGenerateAIResponseSendsBlockedTopicEvent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("blocked topic", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(conversationContextResponse(conversationID), mtest.CreateSuccessResponse())
+
+		repo := repositories.NewConversationRepository(mt.DB)
+		blocklist := NewTopicBlocklistFilter([]string{"drug*"}, nil)
+		aiContext := NewAIContextService(&mockLLM{response: "drugs"}, repo, nil, WithTopicBlocklist(blocklist))
+		service := NewMessageService(repo, nil, nil, aiContext, nil, nil)
+
+		text := "where can I buy some?"
+		reply, err := service.GenerateAIResponse(context.Background(), &models.Conversation{ID: conversationID}, &models.Message{Text: &text}, &models.CompanionProfile{})
+		require.NoError(t, err)
+		assert.Equal(t, sendertype.System, reply.SenderType)
+		require.NotNil(t, reply.SystemEvent)
+		assert.Equal(t, SystemEventBlockedTopic, reply.SystemEvent.EventType)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 2)
+		assert.Equal(t, "insert", events[1].CommandName)
+		assert.Equal(t, "messages", events[1].Command.Lookup("insert").StringValue())
+	})
+}
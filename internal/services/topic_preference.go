@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Topic preference signals
+const (
+	TopicSignalPositive = "positive"
+	TopicSignalNegative = "negative"
+	TopicSignalNeutral  = "neutral"
+)
+
+// TopicSignal is a single piece of evidence about a user's interest in a topic
+type TopicSignal struct {
+	Topic  string
+	Signal string
+}
+
+// TopicPreferenceLearner learns preferred topics from user reactions with a decaying moving average
+type TopicPreferenceLearner struct {
+	repo  *repositories.AnalyticsRepository
+	alpha float64
+	topN  int
+
+	options ServiceConfig
+}
+
+// NewTopicPreferenceLearner creates a learner that keeps the top three topics
+func NewTopicPreferenceLearner(repo *repositories.AnalyticsRepository, opts ...Option) *TopicPreferenceLearner {
+	return &TopicPreferenceLearner{
+		repo:    repo,
+		alpha:   0.2,
+		topN:    3,
+		options: newServiceConfig(opts),
+	}
+}
+
+// Learn folds signals into the stored topic scores and writes the top topics back to engagement analytics
+func (l *TopicPreferenceLearner) Learn(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID, signals []TopicSignal) error {
+	if len(signals) == 0 || !personalisationEnabled(ctx, l.repo, userID) {
+		return nil
+	}
+
+	analytics, err := l.repo.GetUserEngagementAnalytics(ctx, userID, companionID, conversationID)
+	if err != nil {
+		analytics = &models.UserEngagementAnalytics{
+			UserID:         userID,
+			CompanionID:    companionID,
+			ConversationID: conversationID,
+			CreatedAt:      time.Now(),
+		}
+	}
+
+	analytics.TopicScores = l.Update(analytics.TopicScores, signals)
+	analytics.PreferredTopics = TopTopics(analytics.TopicScores, l.topN)
+	analytics.UpdatedAt = time.Now()
+
+	if err := l.repo.UpsertUserEngagementAnalytics(ctx, analytics); err != nil {
+		return fmt.Errorf("failed to save topic preferences: %w", err)
+	}
+
+	return nil
+}
+
+// Update applies signals in order, moving each topic's score towards +1, -1 or 0
+func (l *TopicPreferenceLearner) Update(scores map[string]float64, signals []TopicSignal) map[string]float64 {
+	if scores == nil {
+		scores = map[string]float64{}
+	}
+
+	for _, signal := range signals {
+		var target float64
+		switch signal.Signal {
+		case TopicSignalPositive:
+			target = 1
+		case TopicSignalNegative:
+			target = -1
+		case TopicSignalNeutral:
+			target = 0
+		default:
+			continue
+		}
+
+		scores[signal.Topic] = (1-l.alpha)*scores[signal.Topic] + l.alpha*target
+	}
+
+	return scores
+}
+
+// TopTopics returns up to n topics with a positive score, highest first
+func TopTopics(scores map[string]float64, n int) []string {
+	topics := []string{}
+	for topic, score := range scores {
+		if score > 0 {
+			topics = append(topics, topic)
+		}
+	}
+
+	sort.Slice(topics, func(i, j int) bool {
+		if scores[topics[i]] != scores[topics[j]] {
+			return scores[topics[i]] > scores[topics[j]]
+		}
+		return topics[i] < topics[j]
+	})
+
+	if len(topics) > n {
+		topics = topics[:n]
+	}
+	return topics
+}
+
+// topicSignals derives preference signals from the user's latest message.
+// Changing the subject right away counts against the previous topic, a follow-up question counts for the current one.
+func topicSignals(conversationContext *models.ConversationContext, messages []*models.Message) []TopicSignal {
+	var lastUserMsg *models.Message
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].SenderType == "user" {
+			lastUserMsg = messages[i]
+			break
+		}
+	}
+	if lastUserMsg == nil || conversationContext.CurrentTopic == "" {
+		return nil
+	}
+
+	var signals []TopicSignal
+	topicChanged := !conversationContext.TopicChangedAt.IsZero() && !conversationContext.TopicChangedAt.Before(lastUserMsg.CreatedAt)
+	switch {
+	case topicChanged && len(conversationContext.TopicHistory) > 0:
+		signals = append(signals,
+			TopicSignal{Topic: conversationContext.TopicHistory[len(conversationContext.TopicHistory)-1], Signal: TopicSignalNegative},
+			TopicSignal{Topic: conversationContext.CurrentTopic, Signal: TopicSignalNeutral},
+		)
+	case lastUserMsg.Text != nil && strings.Contains(*lastUserMsg.Text, "?"):
+		signals = append(signals, TopicSignal{Topic: conversationContext.CurrentTopic, Signal: TopicSignalPositive})
+	default:
+		signals = append(signals, TopicSignal{Topic: conversationContext.CurrentTopic, Signal: TopicSignalNeutral})
+	}
+
+	// The placeholder topic says nothing about the user's interests
+	filtered := signals[:0]
+	for _, signal := range signals {
+		if signal.Topic != defaultTopic {
+			filtered = append(filtered, signal)
+		}
+	}
+	return filtered
+}
+
+// PreferredTopics returns the topics learned for the user in a conversation, best first
+func (l *TopicPreferenceLearner) PreferredTopics(ctx context.Context, userID, companionID string, conversationID primitive.ObjectID) ([]string, error) {
+	analytics, err := l.repo.GetUserEngagementAnalytics(ctx, userID, companionID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return analytics.PreferredTopics, nil
+}
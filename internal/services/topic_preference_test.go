@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/sahmaragaev/lunaria-backend/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestTopicPreferenceLearnerConverges(t *testing.T) {
+	learner := NewTopicPreferenceLearner(nil)
+	learner.topN = 2
+
+	// music and travel get follow-up questions, work and politics get the subject changed, cooking is neutral
+	round := []TopicSignal{
+		{Topic: "music", Signal: TopicSignalPositive},
+		{Topic: "work", Signal: TopicSignalNegative},
+		{Topic: "travel", Signal: TopicSignalPositive},
+		{Topic: "cooking", Signal: TopicSignalNeutral},
+		{Topic: "politics", Signal: TopicSignalNegative},
+		{Topic: "music", Signal: TopicSignalPositive},
+		{Topic: "travel", Signal: TopicSignalNeutral},
+		{Topic: "cooking", Signal: TopicSignalPositive},
+		{Topic: "work", Signal: TopicSignalNeutral},
+		{Topic: "travel", Signal: TopicSignalPositive},
+		{Topic: "cooking", Signal: TopicSignalNegative},
+	}
+
+	var scores map[string]float64
+	var top []string
+	for i := 0; i < 20; i++ {
+		scores = learner.Update(scores, round)
+		top = TopTopics(scores, learner.topN)
+	}
+
+	assert.ElementsMatch(t, []string{"music", "travel"}, top)
+	assert.Less(t, scores["work"], 0.0)
+	assert.Less(t, scores["politics"], 0.0)
+	for _, score := range scores {
+		assert.LessOrEqual(t, score, 1.0)
+		assert.GreaterOrEqual(t, score, -1.0)
+	}
+}
+
+func TestTopicSignals(t *testing.T) {
+	sent := time.Now()
+	question := "wait, which band was that?"
+	statement := "cool"
+
+	tests := []struct {
+		name     string
+		context  *models.ConversationContext
+		text     *string
+		expected []TopicSignal
+	}{
+		{
+			name:     "follow-up question",
+			context:  &models.ConversationContext{CurrentTopic: "music", TopicChangedAt: sent.Add(-time.Hour)},
+			text:     &question,
+			expected: []TopicSignal{{Topic: "music", Signal: TopicSignalPositive}},
+		},
+		{
+			name:     "changed subject",
+			context:  &models.ConversationContext{CurrentTopic: "travel", TopicHistory: []string{"music", "work"}, TopicChangedAt: sent.Add(time.Second)},
+			text:     &statement,
+			expected: []TopicSignal{{Topic: "work", Signal: TopicSignalNegative}, {Topic: "travel", Signal: TopicSignalNeutral}},
+		},
+		{
+			name:     "placeholder topic is ignored",
+			context:  &models.ConversationContext{CurrentTopic: "music", TopicHistory: []string{defaultTopic}, TopicChangedAt: sent},
+			text:     &statement,
+			expected: []TopicSignal{{Topic: "music", Signal: TopicSignalNeutral}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := []*models.Message{
+				{SenderType: "user", Text: tt.text, CreatedAt: sent},
+				{SenderType: "companion", Text: &statement},
+			}
+			assert.Equal(t, tt.expected, topicSignals(tt.context, messages))
+		})
+	}
+}
+
+func TestTopicPreferenceLearnerLearn(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("writes preferred topics", func(mt *mtest.T) {
+		conversationID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "lunaria.user_privacy_settings", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "lunaria.user_engagement_analytics", mtest.FirstBatch, bson.D{
+				{Key: "user_id", Value: "user"},
+				{Key: "companion_id", Value: "companion"},
+				{Key: "conversation_id", Value: conversationID},
+				{Key: "topic_scores", Value: bson.D{{Key: "music", Value: 0.5}, {Key: "work", Value: -0.4}}},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		learner := NewTopicPreferenceLearner(repositories.NewAnalyticsRepository(nil, mt.DB))
+		err := learner.Learn(context.Background(), "user", "companion", conversationID, []TopicSignal{
+			{Topic: "travel", Signal: TopicSignalPositive},
+		})
+		require.NoError(t, err)
+
+		events := mt.GetAllStartedEvents()
+		require.Len(t, events, 3)
+		set := events[2].Command.Lookup("updates", "0", "u", "$set").Document()
+
+		topics, err := set.Lookup("preferred_topics").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, topics, 2)
+		assert.Equal(t, "music", topics[0].StringValue())
+		assert.Equal(t, "travel", topics[1].StringValue())
+	})
+}
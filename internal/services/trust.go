@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+// factualContradictionThreshold is the factual accuracy score below which a response counts as contradicting
+// established facts, and factualContradictionTrustDecay is the trust decay amount applied for it
+const (
+	factualContradictionThreshold  = 0.5
+	factualContradictionTrustDecay = 0.1
+)
+
+// trustAnalyticsStore reads and writes relationship analytics, implemented by *repositories.AnalyticsRepository
+type trustAnalyticsStore interface {
+	GetRelationshipAnalytics(ctx context.Context, userID, companionID string) (*models.RelationshipAnalytics, error)
+	UpsertRelationshipAnalytics(ctx context.Context, analytics *models.RelationshipAnalytics) error
+	latestEngagementSource
+}
+
+// trustDecayer lowers a relationship's trust, implemented by *TrustService
+type trustDecayer interface {
+	DecayTrust(ctx context.Context, userID, companionID string, amount float64) error
+}
+
+// TrustService adjusts the trust level of relationships
+type TrustService struct {
+	store trustAnalyticsStore
+	now   func() time.Time
+
+	options ServiceConfig
+}
+
+// NewTrustService creates a new trust service
+func NewTrustService(store trustAnalyticsStore, opts ...Option) *TrustService {
+	return &TrustService{
+		store:   store,
+		now:     time.Now,
+		options: newServiceConfig(opts),
+	}
+}
+
+// DecayTrust lowers the relationship's trust level by amount * (1 - current trust), records a TrustDecayEvent,
+// lowers the health score by the same amount and rescores the relationship's chemistry. Relationships without
+// analytics have no trust to lose and are left alone.
+func (s *TrustService) DecayTrust(ctx context.Context, userID, companionID string, amount float64) error {
+	analytics, err := s.store.GetRelationshipAnalytics(ctx, userID, companionID)
+	if err != nil {
+		var notFound *apperrors.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get relationship analytics: %w", err)
+	}
+
+	trust := decayedTrust(analytics.TrustLevel, amount)
+	lost := analytics.TrustLevel - trust
+	analytics.TrustLevel = trust
+	analytics.TrustBuildingEvents = append(analytics.TrustBuildingEvents, models.TrustEvent{
+		Type:        models.TrustDecayEvent,
+		Description: "Companion contradicted established facts",
+		Impact:      -lost,
+		Timestamp:   s.now(),
+		DecayReason: models.TrustDecayReasonFactualContradiction,
+	})
+	// The stored health score already reflects earlier decay events, so only this one is subtracted
+	analytics.HealthScore = math.Max(0, analytics.HealthScore-lost)
+	if err := refreshChemistryScore(ctx, s.store, analytics); err != nil {
+		s.options.logger().Error("Failed to refresh chemistry score", "error", err)
+	}
+
+	if err := s.store.UpsertRelationshipAnalytics(ctx, analytics); err != nil {
+		return fmt.Errorf("failed to save relationship analytics: %w", err)
+	}
+	return nil
+}
+
+// decayedTrust is the trust level left after a decay of amount, clamped to [0, 1]
+func decayedTrust(current, amount float64) float64 {
+	trust := current - amount*(1-current)
+	return math.Max(0, math.Min(1, trust))
+}
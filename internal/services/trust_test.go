@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apperrors "github.com/sahmaragaev/lunaria-backend/internal/errors"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecayedTrust(t *testing.T) {
+	tests := []struct {
+		name    string
+		current float64
+		amount  float64
+		want    float64
+	}{
+		{name: "full trust is kept", current: 1, amount: 0.1, want: 1},
+		{name: "mid trust", current: 0.5, amount: 0.1, want: 0.45},
+		{name: "high trust loses little", current: 0.9, amount: 0.2, want: 0.88},
+		{name: "low trust", current: 0.2, amount: 0.1, want: 0.12},
+		{name: "clamped at zero", current: 0.05, amount: 0.5, want: 0},
+		{name: "no decay", current: 0.7, amount: 0, want: 0.7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, decayedTrust(tt.current, tt.amount), 1e-9)
+		})
+	}
+}
+
+type fakeTrustStore struct {
+	analytics  *models.RelationshipAnalytics
+	engagement []models.UserEngagementAnalytics
+	saved      *models.RelationshipAnalytics
+}
+
+func (f *fakeTrustStore) GetRelationshipAnalytics(context.Context, string, string) (*models.RelationshipAnalytics, error) {
+	if f.analytics == nil {
+		return nil, apperrors.NewNotFoundError("relationship analytics not found", nil)
+	}
+	return f.analytics, nil
+}
+
+func (f *fakeTrustStore) UpsertRelationshipAnalytics(_ context.Context, analytics *models.RelationshipAnalytics) error {
+	f.saved = analytics
+	return nil
+}
+
+func (f *fakeTrustStore) GetRecentEngagementAnalytics(context.Context, string, string, int) ([]models.UserEngagementAnalytics, error) {
+	return f.engagement, nil
+}
+
+func TestDecayTrust(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	store := &fakeTrustStore{
+		analytics: &models.RelationshipAnalytics{UserID: "user", CompanionID: "companion", TrustLevel: 0.5, IntimacyLevel: 0.5, HealthScore: 0.8},
+		engagement: []models.UserEngagementAnalytics{{
+			EngagementScore:    0.4,
+			ConversationDepth:  0.4,
+			EmotionalIntensity: 0.2,
+			TopicDiversity:     0.3,
+			VulnerabilityLevel: 0.1,
+		}},
+	}
+	service := NewTrustService(store)
+	service.now = func() time.Time { return now }
+
+	require.NoError(t, service.DecayTrust(context.Background(), "user", "companion", 0.1))
+	require.NotNil(t, store.saved)
+	assert.InDelta(t, 0.45, store.saved.TrustLevel, 1e-9)
+	assert.InDelta(t, 0.75, store.saved.HealthScore, 1e-9)
+	assert.InDelta(t, 1/(0.3/0.45+0.25/0.5+0.25/0.4+0.2/0.25), store.saved.ChemistryScore, 1e-9, "chemistry is rescored with the decayed trust")
+	require.Len(t, store.saved.TrustBuildingEvents, 1)
+	event := store.saved.TrustBuildingEvents[0]
+	assert.Equal(t, models.TrustDecayEvent, event.Type)
+	assert.Equal(t, models.TrustDecayReasonFactualContradiction, event.DecayReason)
+	assert.InDelta(t, -0.05, event.Impact, 1e-9)
+	assert.Equal(t, now, event.Timestamp)
+	assert.InDelta(t, 0.05, store.saved.RecentTrustDecay(now.Add(-models.TrustDecayHealthWindow)), 1e-9)
+
+	missing := &fakeTrustStore{}
+	require.NoError(t, NewTrustService(missing).DecayTrust(context.Background(), "user", "companion", 0.1))
+	assert.Nil(t, missing.saved, "relationships without analytics are left alone")
+}
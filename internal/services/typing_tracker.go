@@ -1,10 +1,17 @@
 package services
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
 )
 
+// typingForwardTimeout bounds how long forwarding one typing change to a peer may take
+const typingForwardTimeout = 2 * time.Second
+
 // TypingState holds the transient typing status for a conversation
 type TypingState struct {
 	IsTyping      bool
@@ -13,20 +20,47 @@ type TypingState struct {
 	LastUpdate    time.Time
 }
 
-// TypingTracker manages in-memory typing states
+// typingPeers lists the other server instances
+type typingPeers interface {
+	Self() cluster.NodeInfo
+	ListPeers() []cluster.NodeInfo
+}
+
+// typingForwarder delivers a typing change to another server instance
+type typingForwarder interface {
+	ForwardTypingEvent(ctx context.Context, peerAddr string, event *clusterpb.TypingEvent) error
+}
+
+// TypingTracker manages in-memory typing states. When sharing with peers, every local change is forwarded to the
+// other instances so typing status can be polled from any of them.
 type TypingTracker struct {
 	mu sync.RWMutex
 	m  map[string]TypingState
+
+	peers     typingPeers
+	forwarder typingForwarder
+
+	options ServiceConfig
 }
 
-func NewTypingTracker() *TypingTracker {
-	return &TypingTracker{m: make(map[string]TypingState)}
+func NewTypingTracker(opts ...Option) *TypingTracker {
+	return &TypingTracker{m: make(map[string]TypingState), options: newServiceConfig(opts)}
+}
+
+// ShareWithPeers forwards typing changes to the instances listed by peers
+func (t *TypingTracker) ShareWithPeers(peers typingPeers, forwarder typingForwarder) {
+	t.mu.Lock()
+	t.peers = peers
+	t.forwarder = forwarder
+	t.mu.Unlock()
 }
 
 func (t *TypingTracker) SetStart(convID string) {
 	t.mu.Lock()
-	t.m[convID] = TypingState{IsTyping: true, MessageIndex: 0, TotalMessages: 0, LastUpdate: time.Now()}
+	state := TypingState{IsTyping: true, MessageIndex: 0, TotalMessages: 0, LastUpdate: time.Now()}
+	t.m[convID] = state
 	t.mu.Unlock()
+	t.forward(convID, state, false)
 }
 
 func (t *TypingTracker) SetTotal(convID string, total int) {
@@ -37,6 +71,7 @@ func (t *TypingTracker) SetTotal(convID string, total int) {
 	state.LastUpdate = time.Now()
 	t.m[convID] = state
 	t.mu.Unlock()
+	t.forward(convID, state, false)
 }
 
 func (t *TypingTracker) Update(convID string, index int, total int) {
@@ -48,12 +83,68 @@ func (t *TypingTracker) Update(convID string, index int, total int) {
 	state.LastUpdate = time.Now()
 	t.m[convID] = state
 	t.mu.Unlock()
+	t.forward(convID, state, false)
 }
 
 func (t *TypingTracker) Stop(convID string) {
 	t.mu.Lock()
 	delete(t.m, convID)
 	t.mu.Unlock()
+	t.forward(convID, TypingState{LastUpdate: time.Now()}, true)
+}
+
+// ApplyRemote applies a typing change forwarded by another instance, unless a newer change is already known
+func (t *TypingTracker) ApplyRemote(event *clusterpb.TypingEvent) {
+	updatedAt := time.UnixMilli(event.GetUpdatedAtUnixMs())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, ok := t.m[event.GetConversationId()]; ok && current.LastUpdate.After(updatedAt) {
+		return
+	}
+	if event.GetStopped() {
+		delete(t.m, event.GetConversationId())
+		return
+	}
+	t.m[event.GetConversationId()] = TypingState{
+		IsTyping:      event.GetIsTyping(),
+		MessageIndex:  int(event.GetMessageIndex()),
+		TotalMessages: int(event.GetTotalMessages()),
+		LastUpdate:    updatedAt,
+	}
+}
+
+// forward sends a local typing change to every peer in the background
+func (t *TypingTracker) forward(convID string, state TypingState, stopped bool) {
+	t.mu.RLock()
+	peers, forwarder := t.peers, t.forwarder
+	t.mu.RUnlock()
+	if peers == nil || forwarder == nil {
+		return
+	}
+
+	targets := peers.ListPeers()
+	if len(targets) == 0 {
+		return
+	}
+	event := &clusterpb.TypingEvent{
+		ConversationId:  convID,
+		IsTyping:        state.IsTyping,
+		MessageIndex:    int32(state.MessageIndex),
+		TotalMessages:   int32(state.TotalMessages),
+		UpdatedAtUnixMs: state.LastUpdate.UnixMilli(),
+		Stopped:         stopped,
+		OriginNodeId:    peers.Self().NodeID,
+	}
+	for _, peer := range targets {
+		go func(addr string) {
+			ctx, cancel := context.WithTimeout(context.Background(), typingForwardTimeout)
+			defer cancel()
+			if err := forwarder.ForwardTypingEvent(ctx, addr, event); err != nil {
+				t.options.logger().Warn("Typing event not forwarded", "conversation_id", convID, "peer", addr, "error", err)
+			}
+		}(peer.Address)
+	}
 }
 
 func (t *TypingTracker) Get(convID string) (TypingState, bool) {
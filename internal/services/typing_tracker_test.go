@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/cluster"
+	"github.com/sahmaragaev/lunaria-backend/internal/clusterpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTypingPeers []cluster.NodeInfo
+
+func (p fakeTypingPeers) Self() cluster.NodeInfo        { return cluster.NodeInfo{NodeID: "node-a"} }
+func (p fakeTypingPeers) ListPeers() []cluster.NodeInfo { return p }
+
+type recordingForwarder struct {
+	events chan *clusterpb.TypingEvent
+	addrs  chan string
+}
+
+func (f *recordingForwarder) ForwardTypingEvent(ctx context.Context, peerAddr string, event *clusterpb.TypingEvent) error {
+	f.addrs <- peerAddr
+	f.events <- event
+	return nil
+}
+
+func TestTypingTrackerForwardsToPeers(t *testing.T) {
+	tracker := NewTypingTracker()
+	forwarder := &recordingForwarder{events: make(chan *clusterpb.TypingEvent, 1), addrs: make(chan string, 1)}
+	tracker.ShareWithPeers(fakeTypingPeers{{NodeID: "node-b", Address: "node-b:9090"}}, forwarder)
+
+	tracker.Update("conv", 1, 3)
+
+	select {
+	case event := <-forwarder.events:
+		assert.Equal(t, "node-b:9090", <-forwarder.addrs)
+		assert.Equal(t, "conv", event.GetConversationId())
+		assert.True(t, event.GetIsTyping())
+		assert.Equal(t, int32(1), event.GetMessageIndex())
+		assert.Equal(t, int32(3), event.GetTotalMessages())
+		assert.Equal(t, "node-a", event.GetOriginNodeId())
+	case <-time.After(time.Second):
+		t.Fatal("typing event was not forwarded")
+	}
+}
+
+type failingForwarder struct{}
+
+func (failingForwarder) ForwardTypingEvent(ctx context.Context, peerAddr string, event *clusterpb.TypingEvent) error {
+	return errors.New("peer unavailable")
+}
+
+func TestTypingTrackerLogsForwardingFailures(t *testing.T) {
+	var mu sync.Mutex
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&lockedWriter{mu: &mu, w: &buf}, nil))
+	tracker := NewTypingTracker(WithLogger(logger))
+	tracker.ShareWithPeers(fakeTypingPeers{{NodeID: "node-b", Address: "node-b:9090"}}, failingForwarder{})
+
+	tracker.Update("conv", 1, 3)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(buf.String(), "Typing event not forwarded") && strings.Contains(buf.String(), "peer=node-b:9090")
+	}, time.Second, 10*time.Millisecond)
+}
+
+// lockedWriter serialises writes to w with mu
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func TestTypingTrackerApplyRemote(t *testing.T) {
+	tracker := NewTypingTracker()
+	now := time.Now()
+
+	tracker.ApplyRemote(&clusterpb.TypingEvent{ConversationId: "conv", IsTyping: true, MessageIndex: 1, TotalMessages: 3, UpdatedAtUnixMs: now.UnixMilli()})
+	state, ok := tracker.Get("conv")
+	require.True(t, ok)
+	assert.Equal(t, 1, state.MessageIndex)
+
+	// A change made before the one already applied is ignored
+	tracker.ApplyRemote(&clusterpb.TypingEvent{ConversationId: "conv", Stopped: true, UpdatedAtUnixMs: now.Add(-time.Second).UnixMilli()})
+	_, ok = tracker.Get("conv")
+	assert.True(t, ok)
+
+	tracker.ApplyRemote(&clusterpb.TypingEvent{ConversationId: "conv", Stopped: true, UpdatedAtUnixMs: now.Add(time.Second).UnixMilli()})
+	_, ok = tracker.Get("conv")
+	assert.False(t, ok)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+)
+
+const (
+	// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body
+	WebhookSignatureHeader = "X-Lunaria-Signature"
+	// WebhookEventHeader carries the event type
+	WebhookEventHeader = "X-Lunaria-Event"
+
+	AchievementUnlockedEvent = "achievement.unlocked"
+	SafetyIncidentEvent      = "safety.incident"
+
+	webhookMaxRetries = 3
+)
+
+// webhookEndpointSource lists the endpoints events are delivered to
+type webhookEndpointSource interface {
+	ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error)
+}
+
+// WebhookService delivers signed event payloads to registered endpoints
+type WebhookService struct {
+	endpoints webhookEndpointSource
+	secret    []byte
+	client    *http.Client
+	backoff   time.Duration
+
+	options ServiceConfig
+}
+
+func NewWebhookService(cfg *config.WebhookConfig, endpoints webhookEndpointSource, opts ...Option) *WebhookService {
+	return &WebhookService{
+		endpoints: endpoints,
+		secret:    []byte(cfg.Secret),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		backoff:   500 * time.Millisecond,
+		options:   newServiceConfig(opts),
+	}
+}
+
+// Deliver posts the event to every registered endpoint, retrying failed deliveries with exponential backoff
+func (s *WebhookService) Deliver(ctx context.Context, event models.AchievementEvent) error {
+	if event.Type == "" {
+		event.Type = AchievementUnlockedEvent
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	return s.DeliverEvent(ctx, event.Type, event)
+}
+
+// DeliverEvent posts an arbitrary event payload to every registered endpoint
+func (s *WebhookService) DeliverEvent(ctx context.Context, eventType string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	signature := s.Sign(payload)
+
+	endpoints, err := s.endpoints.ListWebhookEndpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		if err := s.deliverWithRetry(ctx, endpoint.URL, eventType, payload, signature); err != nil {
+			errs = append(errs, fmt.Errorf("failed to deliver webhook to %s: %w", endpoint.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of payload using the configured secret
+func (s *WebhookService) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) deliverWithRetry(ctx context.Context, url, eventType string, payload []byte, signature string) error {
+	var err error
+	for attempt := 0; attempt <= webhookMaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.backoff * time.Duration(1<<(attempt-1))):
+			}
+		}
+
+		if err = s.post(ctx, url, eventType, payload, signature); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *WebhookService) post(ctx context.Context, url, eventType string, payload []byte, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sahmaragaev/lunaria-backend/internal/config"
+	"github.com/sahmaragaev/lunaria-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWebhookEndpoints []models.WebhookEndpoint
+
+func (f fakeWebhookEndpoints) ListWebhookEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return f, nil
+}
+
+func TestWebhookServiceDeliverSignsPayload(t *testing.T) {
+	const secret = "test-secret"
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get(WebhookSignatureHeader))
+		assert.Equal(t, AchievementUnlockedEvent, r.Header.Get(WebhookEventHeader))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service := NewWebhookService(&config.WebhookConfig{Secret: secret}, fakeWebhookEndpoints{{URL: server.URL}})
+
+	err := service.Deliver(context.Background(), models.AchievementEvent{
+		UserID:      "user",
+		CompanionID: "companion",
+		Achievement: models.UserAchievement{AchievementID: "first_conversation", Title: "First Steps", Points: 50},
+		OccurredAt:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, received)
+	assert.Equal(t, AchievementUnlockedEvent, received["type"])
+	assert.Equal(t, "user", received["user_id"])
+	assert.Equal(t, "companion", received["companion_id"])
+	assert.Equal(t, "2024-06-01T12:00:00Z", received["occurred_at"])
+	achievement, ok := received["achievement"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "first_conversation", achievement["achievement_id"])
+	assert.Equal(t, "First Steps", achievement["title"])
+}
+
+func TestWebhookServiceDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "succeeds after retries", failures: 2, wantErr: false, wantAttempts: 3},
+		{name: "gives up after three retries", failures: 10, wantErr: true, wantAttempts: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			service := NewWebhookService(&config.WebhookConfig{Secret: "secret"}, fakeWebhookEndpoints{{URL: server.URL}})
+			service.backoff = time.Millisecond
+
+			err := service.Deliver(context.Background(), models.AchievementEvent{UserID: "user"})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts.Load())
+		})
+	}
+}